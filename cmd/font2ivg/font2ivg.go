@@ -0,0 +1,157 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// font2ivg extracts glyphs from a TrueType font as IconVG graphics.
+//
+// Usage: font2ivg [-viewbox minX,minY,maxX,maxY] [-quantization q] [-fill
+// #rrggbbaa] [-fitglyph] [-out dir] font.ttf glyph...
+//
+// Each glyph argument is a single character, such as "a", a Unicode code
+// point, such as "U+E88A", or a glyph ID, such as "gid:123". Each glyph is
+// written to the -out directory, named after its code point (such as
+// "u+e88a.ivg") or its glyph ID (such as "gid123.ivg").
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/iconvg/src/go/font2ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	viewBoxFlag      = flag.String("viewbox", "-32,-32,32,32", "the IconVG viewBox: minX,minY,maxX,maxY")
+	quantizationFlag = flag.Float64("quantization", 0, "round coordinates to multiples of this, such as 0.015625; 0 means no rounding")
+	fillFlag         = flag.String("fill", "", "fill color, such as #000000; empty means custom palette color 0")
+	fitGlyphFlag     = flag.Bool("fitglyph", false, "fit the glyph's bounding box, instead of the em square, to the viewBox")
+	outFlag          = flag.String("out", ".", "output directory")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "font2ivg"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		return fmt.Errorf("Usage: %s [-viewbox minX,minY,maxX,maxY] [-quantization q] "+
+			"[-fill #rrggbbaa] [-fitglyph] [-out dir] font.ttf glyph...", cmd)
+	}
+
+	opts := &font2ivg.Options{
+		Quantization: float32(*quantizationFlag),
+		FitGlyph:     *fitGlyphFlag,
+	}
+	var err error
+	if opts.ViewBox, err = parseViewBox(*viewBoxFlag); err != nil {
+		return err
+	}
+	if *fillFlag != "" {
+		if opts.Fill, err = lowlevel.ParseColor(*fillFlag); err != nil {
+			return err
+		}
+	}
+
+	src, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	f, err := font2ivg.Parse(src)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range flag.Args()[1:] {
+		glyph, name, err := parseGlyph(f, arg)
+		if err != nil {
+			return err
+		}
+		data, err := font2ivg.Encode(f, glyph, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", arg, err)
+		}
+		if err := os.WriteFile(filepath.Join(*outFlag, name+".ivg"), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseGlyph parses a glyph argument, returning the glyph ID and the output
+// file's base name.
+func parseGlyph(f *font2ivg.Font, arg string) (glyph int, name string, retErr error) {
+	if s := strings.TrimPrefix(arg, "gid:"); s != arg {
+		g, err := strconv.Atoi(s)
+		if err != nil || g < 0 || g >= f.NumGlyphs() {
+			return 0, "", fmt.Errorf("invalid glyph ID %q", arg)
+		}
+		return g, "gid" + s, nil
+	}
+
+	r := rune(-1)
+	if s := strings.TrimPrefix(strings.ToUpper(arg), "U+"); (s != strings.ToUpper(arg)) && (s != "") {
+		u, err := strconv.ParseUint(s, 16, 32)
+		if err != nil || u > utf8.MaxRune {
+			return 0, "", fmt.Errorf("invalid code point %q", arg)
+		}
+		r = rune(u)
+	} else if c, size := utf8.DecodeRuneInString(arg); (size == len(arg)) && (c != utf8.RuneError) {
+		r = c
+	} else {
+		return 0, "", fmt.Errorf("invalid glyph %q: want a single character, U+XXXX or gid:N", arg)
+	}
+	glyph = f.GlyphIndex(r)
+	if glyph == 0 {
+		return 0, "", fmt.Errorf("font has no glyph for %U", r)
+	}
+	return glyph, fmt.Sprintf("u+%04x", r), nil
+}
+
+func parseViewBox(s string) (lowlevel.Rectangle, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	v := [4]float32{}
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+		}
+		v[i] = float32(x)
+	}
+	if !(v[0] < v[2]) || !(v[1] < v[3]) {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	return lowlevel.Rectangle{
+		Min: f32.Vec2{v[0], v[1]},
+		Max: f32.Vec2{v[2], v[3]},
+	}, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// gen-material generates a Go package of Material Design icons, encoded as
+// IconVG, from the icons' upstream sources, with package materialgen.
+//
+// Usage: gen-material [-package icons] [-style materialicons] [-size 24]
+// [-viewbox minX,minY,maxX,maxY] [-max-error 0] [-out data.go] -svg dir
+//
+// or: gen-material [-package icons] [-viewbox minX,minY,maxX,maxY]
+// [-max-error 0] [-out data.go] -font font.ttf -codepoints file
+//
+// The -svg dir is the "src" directory of a clone of the
+// github.com/google/material-design-icons repository. The -font and
+// -codepoints files are an icon font, such as MaterialIcons-Regular.ttf, and
+// its codepoints file, from that repository's "font" directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/materialgen"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	packageFlag    = flag.String("package", "icons", "the generated package's name")
+	styleFlag      = flag.String("style", "materialicons", "the SVG sources' style, such as materialiconsoutlined")
+	sizeFlag       = flag.Int("size", 24, "the SVG sources' size, in pixels")
+	viewBoxFlag    = flag.String("viewbox", "", "the IconVG viewBox: minX,minY,maxX,maxY; empty means the sources'")
+	maxErrorFlag   = flag.Float64("max-error", 0, "largest coordinate error, in graphic units; 0 means lossless")
+	outFlag        = flag.String("out", "data.go", "output file")
+	svgFlag        = flag.String("svg", "", "the SVG sources' directory")
+	fontFlag       = flag.String("font", "", "the icon font")
+	codepointsFlag = flag.String("codepoints", "", "the icon font's codepoints file")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "gen-material"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+	if (flag.NArg() != 0) || ((*svgFlag == "") == (*fontFlag == "")) || ((*fontFlag == "") != (*codepointsFlag == "")) {
+		return fmt.Errorf("Usage: %s [-package icons] [-style materialicons] [-size 24] "+
+			"[-viewbox minX,minY,maxX,maxY] [-max-error 0] [-out data.go] "+
+			"(-svg dir | -font font.ttf -codepoints file)", cmd)
+	}
+
+	opts := &materialgen.Options{
+		Style:    *styleFlag,
+		Size:     *sizeFlag,
+		MaxError: float32(*maxErrorFlag),
+	}
+	if *viewBoxFlag != "" {
+		var err error
+		if opts.ViewBox, err = parseViewBox(*viewBoxFlag); err != nil {
+			return err
+		}
+	}
+
+	var icons []materialgen.Icon
+	if *svgFlag != "" {
+		var err error
+		if icons, err = materialgen.FromSVG(os.DirFS(*svgFlag), opts); err != nil {
+			return err
+		}
+	} else {
+		font, err := os.ReadFile(*fontFlag)
+		if err != nil {
+			return err
+		}
+		codepoints, err := os.ReadFile(*codepointsFlag)
+		if err != nil {
+			return err
+		}
+		if icons, err = materialgen.FromFont(font, codepoints, opts); err != nil {
+			return err
+		}
+	}
+
+	src, err := materialgen.GoSource(*packageFlag, icons)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*outFlag, src, 0644)
+}
+
+func parseViewBox(s string) (lowlevel.Rectangle, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	v := [4]float32{}
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+		}
+		v[i] = float32(x)
+	}
+	if !(v[0] < v[2]) || !(v[1] < v[3]) {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	return lowlevel.Rectangle{
+		Min: f32.Vec2{v[0], v[1]},
+		Max: f32.Vec2{v[2], v[3]},
+	}, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+func TestParseViewBox(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    lowlevel.Rectangle
+		wantErr bool
+	}{
+		{"0,0,24,24", lowlevel.Rectangle{Max: f32.Vec2{24, 24}}, false},
+		{"-32, -32, 32, 32", lowlevel.Rectangle{Min: f32.Vec2{-32, -32}, Max: f32.Vec2{32, 32}}, false},
+		{"0,0,24", lowlevel.Rectangle{}, true},
+		{"0,0,24,24,24", lowlevel.Rectangle{}, true},
+		{"0,0,x,24", lowlevel.Rectangle{}, true},
+		{"0,0,0,24", lowlevel.Rectangle{}, true},
+		{"0,24,24,0", lowlevel.Rectangle{}, true},
+		{"", lowlevel.Rectangle{}, true},
+	}
+	for _, tc := range testCases {
+		got, err := parseViewBox(tc.s)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
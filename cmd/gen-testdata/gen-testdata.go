@@ -0,0 +1,129 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// gen-testdata regenerates the test/data golden files that are generated by
+// Go code: the specification's example graphic (action-info.hires.ivg), the
+// gradient-spreads graphic and its rendering, and every graphic's disassembly.
+// Other files, such as hand-crafted graphics and renderings by other
+// implementations, are left alone.
+//
+// Usage: gen-testdata dir
+//     dir may be omitted, in which case it is test/data.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"github.com/google/iconvg/src/go/conformance"
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "gen-testdata"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+
+	dir := filepath.Join("test", "data")
+	if len(os.Args) > 2 {
+		return fmt.Errorf("Usage: %s dir\n"+
+			"    dir may be omitted, in which case it is test/data.", cmd)
+	} else if len(os.Args) == 2 {
+		dir = os.Args[1]
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "action-info.hires.ivg"), conformance.Example.Bytes, 0644); err != nil {
+		return err
+	}
+	if err := genGradientSpreads(dir); err != nil {
+		return err
+	}
+	return genDisassemblies(dir)
+}
+
+// genGradientSpreads writes gradient-spreads.ivg and its 256 pixel rendering.
+// Its top and bottom rows are linear and radial gradients, with each
+// ivg.GradientSpread from left to right.
+func genGradientSpreads(dir string) error {
+	stops := []ivg.GradientStop{
+		{Offset: 0.0, Color: lowlevel.RGBAColor(color.RGBA{0xc0, 0x00, 0x00, 0xff})},
+		{Offset: 0.4, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0xc0, 0x00, 0xff})},
+		{Offset: 1.0, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x80, 0x80})},
+	}
+	spreads := []ivg.GradientSpread{
+		ivg.GradientSpreadNone,
+		ivg.GradientSpreadPad,
+		ivg.GradientSpreadReflect,
+		ivg.GradientSpreadRepeat,
+	}
+
+	b := ivg.NewBuilder()
+	for i, spread := range spreads {
+		x0 := float32(-32 + 16*i)
+		b.MoveTo(x0+1, -31).LineTo(x0+15, -31).LineTo(x0+15, -1).LineTo(x0+1, -1).ClosePath()
+		b.FillPaint(ivg.LinearGradient(stops, x0+6, -20, x0+10, -12, spread))
+		b.MoveTo(x0+1, 1).LineTo(x0+15, 1).LineTo(x0+15, 31).LineTo(x0+1, 31).ClosePath()
+		b.FillPaint(ivg.RadialGradient(stops, x0+8, 16, 4, spread))
+	}
+	src, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "gradient-spreads.ivg"), src, 0644); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := render.PNG(buf, src, 256, nil); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "gradient-spreads.png"), buf.Bytes(), 0644)
+}
+
+// genDisassemblies writes a .disassembly file for every .ivg file in dir.
+func genDisassemblies(dir string) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.ivg"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		buf := &bytes.Buffer{}
+		if err := lowlevel.Disassemble(buf, src); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		if err := os.WriteFile(filename+".disassembly", buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
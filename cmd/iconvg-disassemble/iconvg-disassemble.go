@@ -16,11 +16,15 @@
 
 // iconvg-disassemble prints a human-readable disassembly of IconVG byte-code.
 //
-// Usage: iconvg-disassemble in.ivg > out.ivg.disassembly
+// Usage: iconvg-disassemble [-state] in.ivg > out.ivg.disassembly
 //     in.ivg may be omitted, in which case stdin is read.
+//
+// The -state flag also prints, after each instruction, how it changed the
+// virtual machine's registers and pen.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -28,6 +32,8 @@ import (
 	"github.com/google/iconvg/src/go/lowlevel"
 )
 
+var stateFlag = flag.Bool("state", false, "print the virtual machine's state changes")
+
 func main() {
 	if err := main1(); err != nil {
 		os.Stderr.WriteString(err.Error() + "\n")
@@ -41,13 +47,14 @@ func main1() error {
 		cmd = os.Args[0]
 	}
 
+	flag.Parse()
 	data := []byte(nil)
 	in := os.Stdin
-	if len(os.Args) > 2 {
-		return fmt.Errorf("Usage: %s in.ivg > out.ivg.disassembly\n"+
+	if flag.NArg() > 1 {
+		return fmt.Errorf("Usage: %s [-state] in.ivg > out.ivg.disassembly\n"+
 			"    in.ivg may be omitted, in which case stdin is read.", cmd)
-	} else if len(os.Args) == 2 {
-		if f, err := os.Open(os.Args[1]); err != nil {
+	} else if flag.NArg() == 1 {
+		if f, err := os.Open(flag.Arg(0)); err != nil {
 			return err
 		} else {
 			defer f.Close()
@@ -59,5 +66,8 @@ func main1() error {
 		return err
 	}
 
+	if *stateFlag {
+		return lowlevel.DisassembleWithState(os.Stdout, data)
+	}
 	return lowlevel.Disassemble(os.Stdout, data)
 }
@@ -0,0 +1,74 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// ivg2js converts an IconVG graphic to JavaScript code that draws it with the
+// Canvas 2D API.
+//
+// Usage: ivg2js [-func drawIcon] [-module] in.ivg > out.js
+//
+//	in.ivg may be omitted, in which case stdin is read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivg2js"
+)
+
+var (
+	funcFlag   = flag.String("func", "drawIcon", "the generated function's name")
+	moduleFlag = flag.Bool("module", false, "whether to write an ES module that exports the function")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivg2js"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+
+	in := os.Stdin
+	if flag.NArg() > 1 {
+		return fmt.Errorf("Usage: %s [-func drawIcon] [-module] in.ivg > out.js\n"+
+			"    in.ivg may be omitted, in which case stdin is read.", cmd)
+	} else if flag.NArg() == 1 {
+		if f, err := os.Open(flag.Arg(0)); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			in = f
+		}
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	return ivg2js.Convert(os.Stdout, data, &ivg2js.Options{
+		FuncName: *funcFlag,
+		Module:   *moduleFlag,
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// ivg2png rasterizes an IconVG graphic to one PNG file per size.
+//
+// Usage: ivg2png [-sizes 16,24,48] [-palette 0=#rrggbb,...] [-background
+// #rrggbbaa] [-linear] [-snap N] [-aa mode] [-quantize mode] [-out pattern]
+// in.ivg
+//     in.ivg may be omitted, in which case stdin is read.
+//
+// Each size S produces an S×S image, written to the -out pattern with
+// "{size}" replaced by S. The default pattern is in.ivg's name, without the
+// .ivg extension, followed by "-{size}.png".
+//
+// The -linear flag interpolates blend colors and gradients in linear light
+// instead of in sRGB.
+//
+// The -snap flag snaps horizontal and vertical edges to pixel boundaries for
+// every size up to N, so that small icons are crisper.
+//
+// The -aa flag sets the anti-aliasing mode: "analytic" (the default), "none",
+// "4x" or "16x" supersampling.
+//
+// The -quantize flag quantizes gradients for low bit depth displays: "none"
+// (the default), "rgb565" or "rgb565-dither".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivgflag"
+	"github.com/google/iconvg/src/go/render"
+)
+
+var (
+	sizesFlag      = flag.String("sizes", "64", "comma-separated image sizes, in pixels")
+	paletteFlag    = flag.String("palette", "", "comma-separated palette overrides, such as 0=#ff0000,3=#00ff0080")
+	backgroundFlag = flag.String("background", "", "background color, such as #ffffff; empty means transparent")
+	linearFlag     = flag.Bool("linear", false, "interpolate blends and gradients in linear light instead of sRGB")
+	snapFlag       = flag.Int("snap", 0, "snap edges to pixels for sizes up to this; 0 means never")
+	aaFlag         = flag.String("aa", "analytic", "anti-aliasing mode: analytic, none, 4x or 16x")
+	quantizeFlag   = flag.String("quantize", "none", "gradient quantization: none, rgb565 or rgb565-dither")
+	outFlag        = flag.String("out", "", "output filename pattern, in which {size} is replaced by each size")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivg2png"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+
+	sizes, err := parseSizes(*sizesFlag)
+	if err != nil {
+		return err
+	}
+	opts := &render.Options{
+		LinearInterpolation: *linearFlag,
+		PixelSnapping:       *snapFlag,
+	}
+	if opts.Antialiasing, err = ivgflag.ParseAntialiasing(*aaFlag); err != nil {
+		return err
+	}
+	if opts.GradientQuantization, err = ivgflag.ParseGradientQuantization(*quantizeFlag); err != nil {
+		return err
+	}
+	if opts.Palette, err = ivgflag.ParsePalette(*paletteFlag); err != nil {
+		return err
+	}
+	if *backgroundFlag != "" {
+		if opts.Background, err = ivgflag.ParseColor(*backgroundFlag); err != nil {
+			return err
+		}
+	}
+
+	in, out := os.Stdin, "out-{size}.png"
+	if flag.NArg() > 1 {
+		return fmt.Errorf("Usage: %s [-sizes 16,24,48] [-palette 0=#rrggbb,...] "+
+			"[-background #rrggbbaa] [-linear] [-out pattern] in.ivg\n"+
+			"    in.ivg may be omitted, in which case stdin is read.", cmd)
+	} else if flag.NArg() == 1 {
+		if f, err := os.Open(flag.Arg(0)); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			in = f
+		}
+		out = strings.TrimSuffix(flag.Arg(0), filepath.Ext(flag.Arg(0))) + "-{size}.png"
+	}
+	if *outFlag != "" {
+		out = *outFlag
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range sizes {
+		filename := strings.ReplaceAll(out, "{size}", strconv.Itoa(size))
+		if err := writePNG(filename, data, size, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePNG(filename string, data []byte, size int, opts *render.Options) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := render.PNG(f, data, size, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func parseSizes(s string) ([]int, error) {
+	sizes := []int(nil)
+	for _, field := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ivg2vd converts an IconVG graphic to an Android VectorDrawable, or, with
+// the -reverse flag, a VectorDrawable to an IconVG graphic.
+//
+// Usage: ivg2vd [-height=N] [-palettecolors] in.ivg > out.xml
+//
+//	ivg2vd -reverse in.xml > out.ivg
+//
+//	in.ivg or in.xml may be omitted, in which case stdin is read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivg2vd"
+	"github.com/google/iconvg/src/go/svgconv"
+)
+
+var (
+	heightFlag        = flag.Int("height", 0, "the drawable's height in dp, which selects the level of detail")
+	paletteColorsFlag = flag.Bool("palettecolors", false, "whether palette colors refer to @color/iconvg_palette_N resources")
+	reverseFlag       = flag.Bool("reverse", false, "whether to convert a VectorDrawable to IconVG")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivg2vd"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+
+	in := os.Stdin
+	if flag.NArg() > 1 {
+		return fmt.Errorf("Usage: %s [-height=N] [-palettecolors] in.ivg > out.xml\n"+
+			"       %s -reverse in.xml > out.ivg\n"+
+			"    in.ivg or in.xml may be omitted, in which case stdin is read.", cmd, cmd)
+	} else if flag.NArg() == 1 {
+		if f, err := os.Open(flag.Arg(0)); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			in = f
+		}
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	if *reverseFlag {
+		svg, err := ivg2vd.ToSVG(data)
+		if err != nil {
+			return err
+		}
+		dst, err := svgconv.Convert(svg, nil)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(dst)
+		return err
+	}
+	return ivg2vd.Convert(os.Stdout, data, &ivg2vd.Options{
+		Height:        *heightFlag,
+		PaletteColors: *paletteColorsFlag,
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ivg2xcode exports IconVG graphics to an Xcode asset catalog, as vector PDF
+// image sets or, with the -symbol flag, as SF Symbols symbol sets.
+//
+// Usage: ivg2xcode [-size=N] [-symbol] [-template] -o=Assets.xcassets a.ivg b.ivg ...
+//
+//	Each asset is named after its file, without the ".ivg" extension.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg2xcode"
+)
+
+var (
+	outFlag      = flag.String("o", "", "the asset catalog directory")
+	sizeFlag     = flag.Float64("size", 24, "the image's height in points, which selects the level of detail")
+	symbolFlag   = flag.Bool("symbol", false, "whether to write SF Symbols symbol sets instead of image sets")
+	templateFlag = flag.Bool("template", false, "whether image sets are rendered as template images")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivg2xcode"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+
+	if *outFlag == "" || flag.NArg() == 0 {
+		return fmt.Errorf("Usage: %s [-size=N] [-symbol] [-template] -o=Assets.xcassets a.ivg b.ivg ...\n"+
+			"    Each asset is named after its file, without the \".ivg\" extension.", cmd)
+	}
+	opts := &ivg2xcode.Options{
+		Size:     *sizeFlag,
+		Template: *templateFlag,
+	}
+	for _, arg := range flag.Args() {
+		src, err := os.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(arg), ".ivg")
+		if *symbolFlag {
+			err = ivg2xcode.WriteSymbolSet(*outFlag, name, src, opts)
+		} else {
+			err = ivg2xcode.WriteImageSet(*outFlag, name, src, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", arg, err)
+		}
+	}
+	return nil
+}
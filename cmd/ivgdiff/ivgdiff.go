@@ -0,0 +1,88 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// ivgdiff compares two IconVG graphics.
+//
+// Usage: ivgdiff [-size 64] [-tolerance 0] a.ivg b.ivg
+//
+// It prints the differences in metadata, the shapes that were added, removed
+// or changed, and how many pixels of the renderings differ. Like diff, it
+// exits with status 1 if the graphics differ.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivgdiff"
+)
+
+var (
+	sizeFlag      = flag.Int("size", ivgdiff.DefaultSize, "width and height, in pixels, of the compared renderings")
+	toleranceFlag = flag.Uint("tolerance", 0, "largest per-channel difference, from 0 to 255, of pixels that are the same")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivgdiff"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		return fmt.Errorf("Usage: %s [-size 64] [-tolerance 0] a.ivg b.ivg", cmd)
+	}
+	if *toleranceFlag > 255 {
+		return fmt.Errorf("invalid tolerance %d", *toleranceFlag)
+	}
+
+	a, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	r, err := ivgdiff.Compare(a, b, &ivgdiff.Options{
+		Size:      *sizeFlag,
+		Tolerance: uint8(*toleranceFlag),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range r.Metadata {
+		fmt.Printf("metadata: %s\n", m)
+	}
+	for _, d := range r.Shapes {
+		fmt.Println(d)
+	}
+	if r.Pixels > 0 {
+		fmt.Printf("raster: %d of %d pixels differ\n", r.Pixels, r.TotalPixels)
+	}
+	if !r.Equal() {
+		return fmt.Errorf("%s and %s differ", flag.Arg(0), flag.Arg(1))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// ivglint checks an IconVG graphic for problems, printing one line per
+// problem: its byte offset, its severity and a message. It exits with a
+// non-zero status if there are any errors, but not if there are only warnings.
+//
+// Usage: ivglint in.ivg
+//     in.ivg may be omitted, in which case stdin is read.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivglint"
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivglint"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+
+	name, in := "stdin", os.Stdin
+	if len(os.Args) > 2 {
+		return fmt.Errorf("Usage: %s in.ivg\n"+
+			"    in.ivg may be omitted, in which case stdin is read.", cmd)
+	} else if len(os.Args) == 2 {
+		if f, err := os.Open(os.Args[1]); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			name, in = os.Args[1], f
+		}
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	nErrors := 0
+	for _, d := range ivglint.Lint(data) {
+		fmt.Printf("%s:%v\n", name, d)
+		if d.Severity == ivglint.Error {
+			nErrors++
+		}
+	}
+	if nErrors > 0 {
+		return fmt.Errorf("%s: %d error(s)", name, nErrors)
+	}
+	return nil
+}
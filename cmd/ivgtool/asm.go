@@ -0,0 +1,61 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+)
+
+const disUsage = "Usage: %s in.ivg > out.ivgasm\n" +
+	"    in.ivg may be omitted, in which case stdin is read."
+
+// runDis implements "ivgtool dis", which converts byte-code to the ivgasm
+// assembly language, like the ivgdis command.
+func runDis(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(disUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	return ivgasm.Disassemble(os.Stdout, src)
+}
+
+const asmUsage = "Usage: %s in.ivgasm > out.ivg\n" +
+	"    in.ivgasm may be omitted, in which case stdin is read."
+
+// runAsm implements "ivgtool asm", which converts the ivgasm assembly language
+// to byte-code, like the ivgasm command.
+func runAsm(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(asmUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	dst, err := ivgasm.Assemble(src)
+	if err != nil {
+		return err
+	}
+	return writeOutput(dst)
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivgdiff"
+)
+
+const diffUsage = "Usage: %s [-size 64] [-tolerance 0] a.ivg b.ivg"
+
+// runDiff implements "ivgtool diff", which compares two graphics, like the
+// ivgdiff command. Like diff, it exits with status 1 if the graphics differ.
+func runDiff(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(diffUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	sizeFlag := fs.Int("size", ivgdiff.DefaultSize, "width and height, in pixels, of the compared renderings")
+	toleranceFlag := fs.Uint("tolerance", 0, "largest per-channel difference, from 0 to 255, of pixels that are the same")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		return usageErr
+	}
+	if *toleranceFlag > 255 {
+		return fmt.Errorf("invalid tolerance %d", *toleranceFlag)
+	}
+	a, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	r, err := ivgdiff.Compare(a, b, &ivgdiff.Options{
+		Size:      *sizeFlag,
+		Tolerance: uint8(*toleranceFlag),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range r.Metadata {
+		fmt.Printf("metadata: %s\n", m)
+	}
+	for _, d := range r.Shapes {
+		fmt.Println(d)
+	}
+	if r.Pixels > 0 {
+		fmt.Printf("raster: %d of %d pixels differ\n", r.Pixels, r.TotalPixels)
+	}
+	if !r.Equal() {
+		return fmt.Errorf("%s and %s differ", fs.Arg(0), fs.Arg(1))
+	}
+	return nil
+}
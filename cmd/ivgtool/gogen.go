@@ -0,0 +1,262 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const gogenUsage = "Usage: %s [-package icons] [-func Name] in.ivg > out.go\n" +
+	"    in.ivg may be omitted, in which case stdin is read.\n" +
+	"    The function name defaults to one derived from in.ivg's file name,\n" +
+	"    such as ActionInfo for action-info.ivg, or Icon for stdin."
+
+// runGogen implements "ivgtool gogen", which prints Go source code that
+// rebuilds a graphic with an ivg.Builder, so that an existing graphic can be
+// tweaked in code. Colors that refer to the custom palette stay palette
+// references.
+func runGogen(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(gogenUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	pkgFlag := fs.String("package", "icons", "package name")
+	funcFlag := fs.String("func", "", "function name")
+	fs.Parse(args)
+
+	if !token.IsIdentifier(*pkgFlag) {
+		return fmt.Errorf("invalid -package %q", *pkgFlag)
+	}
+	name, source := *funcFlag, "stdin"
+	if len(fs.Args()) == 1 {
+		source = filepath.Base(fs.Args()[0])
+		if name == "" {
+			name = funcName(source)
+		}
+	}
+	if name == "" {
+		name = "Icon"
+	} else if !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid -func %q", name)
+	}
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	g, err := ivg.DecodeThemable(src)
+	if err != nil {
+		return err
+	}
+	dst, err := gogen(g, *pkgFlag, name, source)
+	if err != nil {
+		return err
+	}
+	return writeOutput(dst)
+}
+
+// funcName returns an exported Go identifier for a file name, such as
+// ActionInfo for "action-info.ivg".
+func funcName(filename string) string {
+	filename = strings.TrimSuffix(filename, filepath.Ext(filename))
+	b := &strings.Builder{}
+	upper := true
+	for _, r := range filename {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		} else if (b.Len() == 0) && unicode.IsDigit(r) {
+			b.WriteString("Icon")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	return b.String()
+}
+
+// gogen returns formatted Go source code for a function, in package pkg,
+// that rebuilds g with an ivg.Builder and returns its encoding.
+func gogen(g *ivg.Graphic, pkg string, name string, source string) ([]byte, error) {
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, "b := ivg.NewBuilder()\n")
+	if vb := g.Metadata.ViewBox; vb != lowlevel.DefaultViewBox {
+		fmt.Fprintf(body, "b.SetViewBox(%s, %s, %s, %s)\n",
+			goFloat(vb.Min[0]), goFloat(vb.Min[1]), goFloat(vb.Max[0]), goFloat(vb.Max[1]))
+	}
+	if pal := &g.Metadata.Palette; *pal != lowlevel.DefaultPalette {
+		fmt.Fprintf(body, "pal := lowlevel.DefaultPalette\n")
+		for i, c := range pal {
+			if c != lowlevel.DefaultPalette[i] {
+				fmt.Fprintf(body, "pal[%d] = %s\n", i, goRGBA(c))
+			}
+		}
+		fmt.Fprintf(body, "b.SetPalette(&pal)\n")
+	}
+
+	lod0, lod1, rule := ivg.DefaultLOD0, ivg.DefaultLOD1, lowlevel.FillRuleNonZero
+	for _, s := range g.Shapes {
+		fmt.Fprintf(body, "\n")
+		if (s.LOD0 != lod0) || (s.LOD1 != lod1) {
+			lod0, lod1 = s.LOD0, s.LOD1
+			l1 := goFloat(lod1)
+			if lod1 == ivg.DefaultLOD1 {
+				l1 = "ivg.DefaultLOD1"
+			}
+			fmt.Fprintf(body, "b.SetLOD(%s, %s)\n", goFloat(lod0), l1)
+		}
+		if s.FillRule != rule {
+			rule = s.FillRule
+			r := "lowlevel.FillRuleNonZero"
+			if rule == lowlevel.FillRuleEvenOdd {
+				r = "lowlevel.FillRuleEvenOdd"
+			}
+			fmt.Fprintf(body, "b.SetFillRule(%s)\n", r)
+		}
+		for i, seg := range s.Path {
+			if i == 0 {
+				fmt.Fprintf(body, "b.")
+			} else if _, ok := seg.(ivg.MoveTo); ok {
+				fmt.Fprintf(body, "\nb.")
+			} else {
+				fmt.Fprintf(body, ".\n")
+			}
+			fmt.Fprintf(body, "%s", goSegment(seg))
+		}
+		fmt.Fprintf(body, "\n")
+		if grad := s.Paint.Gradient; grad != nil {
+			fmt.Fprintf(body, "b.FillPaint(%s)\n", goGradientPaint(grad))
+		} else {
+			fmt.Fprintf(body, "b.Fill(%s)\n", goColor(s.Paint.Color))
+		}
+	}
+	fmt.Fprintf(body, "\nreturn b.Bytes()\n")
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "// Generated by \"ivgtool gogen\" from %s.\n\n", source)
+	fmt.Fprintf(b, "package %s\n\nimport (\n", pkg)
+	for _, imp := range [...]struct{ path, use string }{
+		{"image/color", "color."},
+		{"math", "math."},
+		{"", ""},
+		{"github.com/google/iconvg/src/go/ivg", "ivg."},
+		{"github.com/google/iconvg/src/go/lowlevel", "lowlevel."},
+		{"golang.org/x/image/math/f32", "f32."},
+	} {
+		if imp.path == "" {
+			// Separate the standard library imports from the others.
+			fmt.Fprintf(b, "\n")
+		} else if bytes.Contains(body.Bytes(), []byte(imp.use)) {
+			fmt.Fprintf(b, "%q\n", imp.path)
+		}
+	}
+	fmt.Fprintf(b, ")\n\n")
+	fmt.Fprintf(b, "// %s returns the IconVG encoding of %s.\n", name, source)
+	fmt.Fprintf(b, "func %s() ([]byte, error) {\n%s}\n", name, body.Bytes())
+	return format.Source(b.Bytes())
+}
+
+func goSegment(seg ivg.Segment) string {
+	switch seg := seg.(type) {
+	case ivg.MoveTo:
+		return fmt.Sprintf("MoveTo(%s)", goFloats(seg.To[0], seg.To[1]))
+	case ivg.LineTo:
+		return fmt.Sprintf("LineTo(%s)", goFloats(seg.To[0], seg.To[1]))
+	case ivg.QuadTo:
+		return fmt.Sprintf("QuadTo(%s)", goFloats(seg.Ctrl[0], seg.Ctrl[1], seg.To[0], seg.To[1]))
+	case ivg.CubeTo:
+		return fmt.Sprintf("CubeTo(%s)", goFloats(
+			seg.Ctrl0[0], seg.Ctrl0[1], seg.Ctrl1[0], seg.Ctrl1[1], seg.To[0], seg.To[1]))
+	case ivg.ArcTo:
+		return fmt.Sprintf("ArcTo(%s, %s, %t, %t, %s)",
+			goFloats(seg.Radii[0], seg.Radii[1]), goFloat(seg.XAxisRotation),
+			seg.LargeArc, seg.Sweep, goFloats(seg.To[0], seg.To[1]))
+	}
+	return "ClosePath()"
+}
+
+func goGradientPaint(g *ivg.Gradient) string {
+	shape := "ivg.GradientShapeLinear"
+	if g.Shape == ivg.GradientShapeRadial {
+		shape = "ivg.GradientShapeRadial"
+	}
+	spread := [...]string{
+		ivg.GradientSpreadNone:    "ivg.GradientSpreadNone",
+		ivg.GradientSpreadPad:     "ivg.GradientSpreadPad",
+		ivg.GradientSpreadReflect: "ivg.GradientSpreadReflect",
+		ivg.GradientSpreadRepeat:  "ivg.GradientSpreadRepeat",
+	}[g.Spread&3]
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "ivg.Paint{Gradient: &ivg.Gradient{\n")
+	fmt.Fprintf(b, "Shape: %s,\nSpread: %s,\n", shape, spread)
+	fmt.Fprintf(b, "Transform: f32.Aff3{%s},\n", goFloats(g.Transform[:]...))
+	fmt.Fprintf(b, "Stops: []ivg.GradientStop{\n")
+	for _, s := range g.Stops {
+		fmt.Fprintf(b, "{Offset: %s, Color: %s},\n", goFloat(s.Offset), goColor(s.Color))
+	}
+	fmt.Fprintf(b, "},\n}}")
+	return b.String()
+}
+
+func goColor(c lowlevel.Color) string {
+	if rgba, ok := c.RGBA(); ok {
+		return "lowlevel.RGBAColor(" + goRGBA(rgba) + ")"
+	} else if i, ok := c.PaletteIndex(); ok {
+		return fmt.Sprintf("lowlevel.PaletteIndexColor(%d)", i)
+	} else if i, ok := c.CReg(); ok {
+		return fmt.Sprintf("lowlevel.CRegColor(%d)", i)
+	}
+	t, c0, c1, _ := c.Blend()
+	return fmt.Sprintf("lowlevel.BlendColor(0x%02x, 0x%02x, 0x%02x)", t, c0, c1)
+}
+
+func goRGBA(c color.RGBA) string {
+	return fmt.Sprintf("color.RGBA{0x%02x, 0x%02x, 0x%02x, 0x%02x}", c.R, c.G, c.B, c.A)
+}
+
+func goFloats(fs ...float32) string {
+	s := make([]string, len(fs))
+	for i, f := range fs {
+		s[i] = goFloat(f)
+	}
+	return strings.Join(s, ", ")
+}
+
+// goFloat returns a Go expression for f, which is exact: it converts back to
+// the same float32 value.
+func goFloat(f float32) string {
+	switch {
+	case math.IsInf(float64(f), +1):
+		return "float32(math.Inf(+1))"
+	case math.IsInf(float64(f), -1):
+		return "float32(math.Inf(-1))"
+	case f != f:
+		return "float32(math.NaN())"
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestFuncName(t *testing.T) {
+	testCases := []struct {
+		filename string
+		want     string
+	}{
+		{"action-info.ivg", "ActionInfo"},
+		{"action-info.lores.ivg", "ActionInfoLores"},
+		{"video_005.ivg", "Video005"},
+		{"3d-rotation.ivg", "Icon3dRotation"},
+		{"cowbell", "Cowbell"},
+		{"-.ivg", ""},
+	}
+	for _, tc := range testCases {
+		if got := funcName(tc.filename); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.filename, got, tc.want)
+		}
+	}
+}
+
+func TestGoFloat(t *testing.T) {
+	testCases := []struct {
+		f    float32
+		want string
+	}{
+		{0, "0"},
+		{-1.5, "-1.5"},
+		{0.1, "0.1"},
+		{1e-7, "1e-07"},
+		{float32(math.Inf(+1)), "float32(math.Inf(+1))"},
+		{float32(math.Inf(-1)), "float32(math.Inf(-1))"},
+		{float32(math.NaN()), "float32(math.NaN())"},
+	}
+	for _, tc := range testCases {
+		got := goFloat(tc.f)
+		if got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.f, got, tc.want)
+			continue
+		}
+		// Finite values convert back to the same float32.
+		if f, err := strconv.ParseFloat(got, 32); (err == nil) && (float32(f) != tc.f) {
+			t.Errorf("%v: %q converts back to %v", tc.f, got, float32(f))
+		}
+	}
+}
+
+func TestGoColor(t *testing.T) {
+	testCases := []struct {
+		c    lowlevel.Color
+		want string
+	}{
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), "lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff})"},
+		{lowlevel.PaletteIndexColor(3), "lowlevel.PaletteIndexColor(3)"},
+		{lowlevel.CRegColor(63), "lowlevel.CRegColor(63)"},
+		{lowlevel.BlendColor(0x40, 0x81, 0xc2), "lowlevel.BlendColor(0x40, 0x81, 0xc2)"},
+	}
+	for _, tc := range testCases {
+		if got := goColor(tc.c); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestGogen(t *testing.T) {
+	testCases := []struct {
+		args    []string
+		stdin   string
+		want    []string
+		wantErr bool
+	}{{
+		args: []string{testDataDir + "action-info.lores.ivg"},
+		want: []string{
+			"package icons\n",
+			"// Generated by \"ivgtool gogen\" from action-info.lores.ivg.",
+			"func ActionInfoLores() ([]byte, error) {",
+			"b.SetViewBox(-24, -24, 24, 24)",
+			// Custom palette references stay references.
+			"b.Fill(lowlevel.PaletteIndexColor(0))",
+		},
+	}, {
+		args: []string{"-package", "gen", "-func", "Grad", testDataDir + "gradient.ivg"},
+		want: []string{
+			"package gen\n",
+			"func Grad() ([]byte, error) {",
+			"b.FillPaint(ivg.Paint{Gradient: &ivg.Gradient{",
+			"\"golang.org/x/image/math/f32\"",
+		},
+	}, {
+		args: []string{testDataDir + "lod-polygon.ivg"},
+		want: []string{"b.SetLOD("},
+	}, {
+		stdin: testDataDir + "blank.ivg",
+		want: []string{
+			"// Generated by \"ivgtool gogen\" from stdin.",
+			"func Icon() ([]byte, error) {",
+		},
+	}, {
+		args:    []string{"-package", "not a package", testDataDir + "blank.ivg"},
+		wantErr: true,
+	}, {
+		args:    []string{"-func", "1Bad", testDataDir + "blank.ivg"},
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		desc := strings.Join(tc.args, " ")
+		got, err := runCommand(t, "gogen", tc.args, tc.stdin)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", desc, err, tc.wantErr)
+			continue
+		} else if tc.wantErr {
+			continue
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", got, 0); err != nil {
+			t.Errorf("%s: ParseFile: %v", desc, err)
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(string(got), w) {
+				t.Errorf("%s: got\n%s\nwant it to contain %q", desc, got, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivginfo"
+)
+
+const infoUsage = "Usage: %s [-json] in.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read."
+
+// runInfo implements "ivgtool info", which prints a summary of a graphic, like
+// the ivginfo command.
+func runInfo(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(infoUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	jsonFlag := fs.Bool("json", false, "print JSON instead of plain text")
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	info, err := ivginfo.Inspect(src)
+	if err != nil {
+		return err
+	}
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	return info.WriteText(os.Stdout)
+}
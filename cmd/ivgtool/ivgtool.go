@@ -0,0 +1,129 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// ivgtool is a multi-purpose IconVG tool, whose first argument names one of
+// its commands.
+//
+// Usage: ivgtool command [flags] [args]
+//
+// The commands are:
+//
+//	info       summarize a graphic's metadata, instructions and complexity
+//	render     rasterize a graphic to PNG
+//	optimize   re-encode a graphic smaller, optionally lossily
+//	upgrade    re-encode a graphic in this implementation's canonical form
+//	diff       compare two graphics shape by shape and pixel by pixel
+//	dis        disassemble byte-code to the ivgasm assembly language
+//	asm        assemble the ivgasm assembly language to byte-code
+//	recolor    replace a graphic's colors
+//	transform  apply an affine transformation (scale, mirror, rotate or
+//	           translate) to a graphic's drawing
+//	trace      show how a graphic executes, instruction by instruction
+//	gogen      print Go code that rebuilds a graphic with an ivg.Builder
+//
+// Every command that reads one graphic reads it from the file named by its
+// last argument or, if that is omitted, from stdin, and every command that
+// writes one writes it to stdout. The commands wrap the same packages as the
+// single-purpose ivginfo, ivg2png, ivgdiff, ivgdis and ivgasm commands.
+//
+// Run "ivgtool command -h" for a command's usage.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is an ivgtool command.
+type command struct {
+	name    string
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"info", "summarize a graphic's metadata, instructions and complexity", runInfo},
+	{"render", "rasterize a graphic to PNG", runRender},
+	{"optimize", "re-encode a graphic smaller, optionally lossily", runOptimize},
+	{"upgrade", "re-encode a graphic in this implementation's canonical form", runUpgrade},
+	{"diff", "compare two graphics shape by shape and pixel by pixel", runDiff},
+	{"dis", "disassemble byte-code to the ivgasm assembly language", runDis},
+	{"asm", "assemble the ivgasm assembly language to byte-code", runAsm},
+	{"recolor", "replace a graphic's colors", runRecolor},
+	{"transform", "apply an affine transformation to a graphic's drawing", runTransform},
+	{"trace", "show how a graphic executes, instruction by instruction", runTrace},
+	{"gogen", "print Go code that rebuilds a graphic with an ivg.Builder", runGogen},
+}
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "ivgtool"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	if len(os.Args) < 2 {
+		return usage(cmd)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			fs := flag.NewFlagSet(cmd+" "+c.name, flag.ExitOnError)
+			return c.run(fs, os.Args[2:])
+		}
+	}
+	return usage(cmd)
+}
+
+func usage(cmd string) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Usage: %s command [flags] [args]\n\nThe commands are:\n", cmd)
+	for _, c := range commands {
+		fmt.Fprintf(b, "    %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(b, "\nRun \"%s command -h\" for a command's usage.", cmd)
+	return fmt.Errorf("%s", b.String())
+}
+
+// readInput reads the named file or, if there are no args, stdin. It returns
+// usageErr if there is more than one arg.
+func readInput(args []string, usageErr error) ([]byte, error) {
+	in := os.Stdin
+	if len(args) > 1 {
+		return nil, usageErr
+	} else if len(args) == 1 {
+		if f, err := os.Open(args[0]); err != nil {
+			return nil, err
+		} else {
+			defer f.Close()
+			in = f
+		}
+	}
+	return io.ReadAll(in)
+}
+
+// writeOutput writes b to stdout.
+func writeOutput(b []byte) error {
+	_, err := os.Stdout.Write(b)
+	return err
+}
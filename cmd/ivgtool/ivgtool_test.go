@@ -0,0 +1,155 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const testDataDir = "../../test/data/"
+
+// runCommand runs the named ivgtool command, with stdin read from the named
+// file (if non-empty), and returns what it wrote to stdout.
+func runCommand(t *testing.T, name string, args []string, stdin string) ([]byte, error) {
+	t.Helper()
+	var run func(fs *flag.FlagSet, args []string) error
+	for _, c := range commands {
+		if c.name == name {
+			run = c.run
+		}
+	}
+	if run == nil {
+		t.Fatalf("no %q command", name)
+	}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+	os.Stdout = out
+	if stdin != "" {
+		in, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		os.Stdin = in
+	}
+
+	fs := flag.NewFlagSet("ivgtool "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	runErr := run(fs, args)
+	os.Stdout = oldStdout
+	got, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, runErr
+}
+
+// isIconVG returns whether b decodes as an IconVG graphic.
+func isIconVG(b []byte) bool {
+	_, err := ivg.Decode(b, nil)
+	return err == nil
+}
+
+func TestCommands(t *testing.T) {
+	cowbell := testDataDir + "cowbell.ivg"
+	testCases := []struct {
+		name    string
+		args    []string
+		stdin   string
+		check   func(out []byte) bool
+		wantErr bool
+	}{
+		{"info", []string{cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("format:")) }, false},
+		{"info", []string{"-json", cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("{")) }, false},
+		{"info", nil, cowbell, func(b []byte) bool { return bytes.Contains(b, []byte("viewBox:       0 0 48 48")) }, false},
+		{"info", []string{cowbell, cowbell}, "", nil, true},
+		{"render", []string{"-size", "16", cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG")) }, false},
+		{"render", []string{"-size", "16", "-snap", "16", cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG")) }, false},
+		{"render", []string{"-size", "16", "-aa", "4x", cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG")) }, false},
+		{"render", []string{"-palette", "99=#ff0000", cowbell}, "", nil, true},
+		{"render", []string{"-aa", "8x", cowbell}, "", nil, true},
+		{"optimize", []string{"-max-error", "0.1", cowbell}, "", isIconVG, false},
+		{"optimize", []string{"-simplify", "0.1", cowbell}, "", isIconVG, false},
+		{"optimize", []string{"-dedup", cowbell}, "", isIconVG, false},
+		{"optimize", []string{"-levels", "3", cowbell}, "", func(b []byte) bool {
+			m, err := lowlevel.DecodeMetadata(b)
+			return err == nil && len(m.DetailLevels) == 3 && isIconVG(b)
+		}, false},
+		{"optimize", []string{"-levels", "-1", cowbell}, "", nil, true},
+		{"optimize", []string{"-checksum", cowbell}, "", func(b []byte) bool {
+			m, err := lowlevel.DecodeMetadata(b)
+			return err == nil && m.Checksum && isIconVG(b)
+		}, false},
+		{"optimize", []string{"-simplify", "-1", cowbell}, "", nil, true},
+		{"upgrade", []string{cowbell}, "", isIconVG, false},
+		{"dis", []string{cowbell}, "", func(b []byte) bool { return bytes.HasPrefix(b, []byte("magic\n")) }, false},
+		{"asm", []string{testDataDir + "cowbell.ivg.disassembly"}, "", nil, true},
+		{"recolor", []string{"-map", "#000000=#ff0000", cowbell}, "", isIconVG, false},
+		{"recolor", []string{cowbell}, "", nil, true},
+		{"recolor", []string{"-map", "#000000", cowbell}, "", nil, true},
+		{"diff", []string{cowbell, cowbell}, "", func(b []byte) bool { return len(b) == 0 }, false},
+		{"diff", []string{cowbell, testDataDir + "favicon.ivg"}, "", nil, true},
+		{"diff", []string{cowbell}, "", nil, true},
+	}
+	for _, tc := range testCases {
+		desc := tc.name + " " + strings.Join(tc.args, " ")
+		got, err := runCommand(t, tc.name, tc.args, tc.stdin)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", desc, err, tc.wantErr)
+			continue
+		}
+		if tc.check != nil && !tc.check(got) {
+			t.Errorf("%s: unexpected output:\n%.200s", desc, got)
+		}
+	}
+}
+
+func TestAsmDisRoundTrip(t *testing.T) {
+	filename := testDataDir + "lod-polygon.ivg"
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asm, err := runCommand(t, "dis", []string{filename}, "")
+	if err != nil {
+		t.Fatalf("dis: %v", err)
+	}
+	asmFilename := filepath.Join(t.TempDir(), "lod-polygon.ivgasm")
+	if err := os.WriteFile(asmFilename, asm, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := runCommand(t, "asm", []string{asmFilename}, "")
+	if err != nil {
+		t.Fatalf("asm: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x\nwant % x", got, want)
+	}
+}
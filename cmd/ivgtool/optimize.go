@@ -0,0 +1,107 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const optimizeUsage = "Usage: %s [-max-error 0] [-simplify 0] [-dedup] [-levels 0] [-checksum] in.ivg > out.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read.\n" +
+	"    A positive -max-error lets each coordinate move by up to that much,\n" +
+	"    in graphic units, if that encodes it in fewer bytes.\n" +
+	"    A positive -simplify replaces runs of path segments by fewer segments\n" +
+	"    within that distance, in graphic units, of them.\n" +
+	"    -dedup gathers repeated sub-paths into fewer paths, reporting the\n" +
+	"    bytes saved on stderr.\n" +
+	"    A positive -levels orders paths from coarse to fine in that many\n" +
+	"    progressive detail levels.\n" +
+	"    -checksum adds a CRC-32C checksum, which decoders verify."
+
+// runOptimize implements "ivgtool optimize", which re-encodes a graphic with
+// the ivg.Encoder's Optimize option and, optionally, lossy coordinates and
+// simplified paths.
+func runOptimize(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(optimizeUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	maxErrorFlag := fs.Float64("max-error", 0, "largest coordinate error, in graphic units; 0 means lossless")
+	simplifyFlag := fs.Float64("simplify", 0, "path simplification tolerance, in graphic units; 0 means none")
+	dedupFlag := fs.Bool("dedup", false, "gather repeated sub-paths")
+	levelsFlag := fs.Int("levels", 0, "number of progressive detail levels; 0 means not progressive")
+	checksumFlag := fs.Bool("checksum", false, "add a checksum")
+	fs.Parse(args)
+
+	if *maxErrorFlag < 0 {
+		return fmt.Errorf("invalid -max-error %g", *maxErrorFlag)
+	} else if *simplifyFlag < 0 {
+		return fmt.Errorf("invalid -simplify %g", *simplifyFlag)
+	} else if *levelsFlag < 0 {
+		return fmt.Errorf("invalid -levels %d", *levelsFlag)
+	}
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	if *simplifyFlag > 0 {
+		if src, err = ivg.Simplify(src, float32(*simplifyFlag)); err != nil {
+			return err
+		}
+	}
+	e := &ivg.Encoder{Optimize: true, DedupSubPaths: *dedupFlag, DetailLevels: *levelsFlag}
+	e.QuantizeCoordinates(float32(*maxErrorFlag))
+	dst, err := e.Reencode(src)
+	if err != nil {
+		return err
+	}
+	if *dedupFlag {
+		fmt.Fprintf(os.Stderr, "dedup saved %d bytes\n", e.BytesSaved())
+	}
+	if *checksumFlag {
+		if dst, err = lowlevel.AddChecksum(dst); err != nil {
+			return err
+		}
+	}
+	return writeOutput(dst)
+}
+
+const upgradeUsage = "Usage: %s in.ivg > out.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read."
+
+// runUpgrade implements "ivgtool upgrade", which re-encodes a graphic in the
+// canonical form of ivg.Canonicalize. IconVG has one file format, with no
+// version number, so upgrading does not change the format. It rewrites a
+// graphic that an older encoder wrote, such as that of the original
+// golang.org/x/exp/shiny/iconvg package, in the same encoding that this
+// implementation's encoder would write.
+func runUpgrade(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(upgradeUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	dst, err := ivg.Canonicalize(src)
+	if err != nil {
+		return err
+	}
+	return writeOutput(dst)
+}
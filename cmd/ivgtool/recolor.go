@@ -0,0 +1,70 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivgflag"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const recolorUsage = "Usage: %s -map #rrggbb=#rrggbb,... in.ivg > out.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read.\n" +
+	"    Colors are non-alpha-premultiplied CSS colors, such as #rrggbbaa or red.\n" +
+	"    Direct colors, gradient stops and suggested palette entries are replaced."
+
+// runRecolor implements "ivgtool recolor", which replaces a graphic's colors
+// with lowlevel.Recolor.
+func runRecolor(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(recolorUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	mapFlag := fs.String("map", "", "comma-separated color replacements, such as #000000=#ff0000")
+	fs.Parse(args)
+
+	if *mapFlag == "" {
+		return usageErr
+	}
+	mapping := map[color.RGBA]color.RGBA{}
+	for _, field := range strings.Split(*mapFlag, ",") {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return fmt.Errorf("invalid color replacement %q", field)
+		}
+		from, err := ivgflag.ParseColor(strings.TrimSpace(field[:i]))
+		if err != nil {
+			return err
+		}
+		to, err := ivgflag.ParseColor(strings.TrimSpace(field[i+1:]))
+		if err != nil {
+			return err
+		}
+		mapping[from] = to
+	}
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	dst, err := lowlevel.Recolor(src, mapping)
+	if err != nil {
+		return err
+	}
+	return writeOutput(dst)
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/iconvg/src/go/ivgflag"
+	"github.com/google/iconvg/src/go/render"
+)
+
+const renderUsage = "Usage: %s [-size 64] [-palette 0=#rrggbb,...] [-background #rrggbbaa] " +
+	"[-linear] [-snap N] [-aa analytic|none|4x|16x] [-quantize none|rgb565|rgb565-dither] " +
+	"in.ivg > out.png\n" +
+	"    in.ivg may be omitted, in which case stdin is read."
+
+// runRender implements "ivgtool render", which rasterizes a graphic to a
+// size×size PNG image, like the ivg2png command does for each of its sizes.
+func runRender(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(renderUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	sizeFlag := fs.Int("size", 64, "image width and height, in pixels")
+	paletteFlag := fs.String("palette", "", "comma-separated palette overrides, such as 0=#ff0000,3=#00ff0080")
+	backgroundFlag := fs.String("background", "", "background color, such as #ffffff; empty means transparent")
+	linearFlag := fs.Bool("linear", false, "interpolate blends and gradients in linear light instead of sRGB")
+	snapFlag := fs.Int("snap", 0, "snap edges to pixels if -size is at most this; 0 means never")
+	aaFlag := fs.String("aa", "analytic", "anti-aliasing mode: analytic, none, 4x or 16x")
+	quantizeFlag := fs.String("quantize", "none", "gradient quantization: none, rgb565 or rgb565-dither")
+	fs.Parse(args)
+
+	if *sizeFlag <= 0 {
+		return fmt.Errorf("invalid size %d", *sizeFlag)
+	}
+	opts := &render.Options{
+		LinearInterpolation: *linearFlag,
+		PixelSnapping:       *snapFlag,
+	}
+	var err error
+	if opts.Antialiasing, err = ivgflag.ParseAntialiasing(*aaFlag); err != nil {
+		return err
+	}
+	if opts.GradientQuantization, err = ivgflag.ParseGradientQuantization(*quantizeFlag); err != nil {
+		return err
+	}
+	if opts.Palette, err = ivgflag.ParsePalette(*paletteFlag); err != nil {
+		return err
+	}
+	if *backgroundFlag != "" {
+		if opts.Background, err = ivgflag.ParseColor(*backgroundFlag); err != nil {
+			return err
+		}
+	}
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	return render.PNG(os.Stdout, src, *sizeFlag, opts)
+}
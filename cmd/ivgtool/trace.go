@@ -0,0 +1,152 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const traceUsage = "Usage: %s in.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read."
+
+// runTrace implements "ivgtool trace", which shows how a graphic executes: for
+// each instruction, its byte offset, opcode and bytes, followed by the
+// Destination method calls that it decodes to. Decoding errors are reported
+// after the trace of the instructions before the error.
+func runTrace(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(traceUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	fmt.Fprintf(w, "viewBox %g %g %g %g\n",
+		m.ViewBox.Min[0], m.ViewBox.Min[1], m.ViewBox.Max[0], m.ViewBox.Max[1])
+	t := &tracer{}
+	err = lowlevel.Decode(t, src, &lowlevel.DecodeOptions{
+		Trace: func(offset int, op lowlevel.Opcode, args []byte) {
+			fmt.Fprintf(w, "0x%04x  %-16s % x\n", offset, op, append([]byte{op.Byte}, args...))
+			for _, c := range t.calls {
+				fmt.Fprintf(w, "        %s\n", c)
+			}
+			t.calls = t.calls[:0]
+		},
+	})
+	if err1 := w.Flush(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// tracer is a lowlevel.Destination that records its method calls, other than
+// Reset, until the next instruction is traced.
+type tracer struct {
+	calls []string
+}
+
+func (t *tracer) call(name string, args ...interface{}) {
+	s := make([]string, len(args))
+	for i, a := range args {
+		if c, ok := a.(lowlevel.Color); ok {
+			s[i] = formatColor(c)
+		} else {
+			s[i] = fmt.Sprint(a)
+		}
+	}
+	t.calls = append(t.calls, name+"("+strings.Join(s, ", ")+")")
+}
+
+// formatColor formats c like the ivgasm assembly language does.
+func formatColor(c lowlevel.Color) string {
+	switch c.Type() {
+	case lowlevel.ColorTypeRGBA:
+		rgba, _ := c.RGBA()
+		return fmt.Sprintf("#%02x%02x%02x%02x", rgba.R, rgba.G, rgba.B, rgba.A)
+	case lowlevel.ColorTypePaletteIndex:
+		i, _ := c.PaletteIndex()
+		return fmt.Sprintf("pal[%d]", i)
+	case lowlevel.ColorTypeCReg:
+		i, _ := c.CReg()
+		return fmt.Sprintf("creg[%d]", i)
+	}
+	t, c0, c1, _ := c.BlendedColors()
+	return fmt.Sprintf("blend(%d, %s, %s)", t, formatColor(c0), formatColor(c1))
+}
+
+func (t *tracer) Reset(m lowlevel.Metadata) {}
+
+func (t *tracer) SetCSel(cSel uint8) { t.call("SetCSel", cSel) }
+func (t *tracer) SetNSel(nSel uint8) { t.call("SetNSel", nSel) }
+
+func (t *tracer) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	t.call("SetCReg", adj, incr, c)
+}
+
+func (t *tracer) SetNReg(adj uint8, incr bool, f float32) { t.call("SetNReg", adj, incr, f) }
+func (t *tracer) SetLOD(lod0, lod1 float32)               { t.call("SetLOD", lod0, lod1) }
+
+func (t *tracer) StartPath(adj uint8, x, y float32) { t.call("StartPath", adj, x, y) }
+func (t *tracer) ClosePathEndPath()                 { t.call("ClosePathEndPath") }
+func (t *tracer) ClosePathAbsMoveTo(x, y float32)   { t.call("ClosePathAbsMoveTo", x, y) }
+func (t *tracer) ClosePathRelMoveTo(x, y float32)   { t.call("ClosePathRelMoveTo", x, y) }
+
+func (t *tracer) AbsHLineTo(x float32)           { t.call("AbsHLineTo", x) }
+func (t *tracer) RelHLineTo(x float32)           { t.call("RelHLineTo", x) }
+func (t *tracer) AbsVLineTo(y float32)           { t.call("AbsVLineTo", y) }
+func (t *tracer) RelVLineTo(y float32)           { t.call("RelVLineTo", y) }
+func (t *tracer) AbsLineTo(x, y float32)         { t.call("AbsLineTo", x, y) }
+func (t *tracer) RelLineTo(x, y float32)         { t.call("RelLineTo", x, y) }
+func (t *tracer) AbsSmoothQuadTo(x, y float32)   { t.call("AbsSmoothQuadTo", x, y) }
+func (t *tracer) RelSmoothQuadTo(x, y float32)   { t.call("RelSmoothQuadTo", x, y) }
+func (t *tracer) AbsQuadTo(x1, y1, x, y float32) { t.call("AbsQuadTo", x1, y1, x, y) }
+func (t *tracer) RelQuadTo(x1, y1, x, y float32) { t.call("RelQuadTo", x1, y1, x, y) }
+
+func (t *tracer) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	t.call("AbsSmoothCubeTo", x2, y2, x, y)
+}
+
+func (t *tracer) RelSmoothCubeTo(x2, y2, x, y float32) {
+	t.call("RelSmoothCubeTo", x2, y2, x, y)
+}
+
+func (t *tracer) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	t.call("AbsCubeTo", x1, y1, x2, y2, x, y)
+}
+
+func (t *tracer) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	t.call("RelCubeTo", x1, y1, x2, y2, x, y)
+}
+
+func (t *tracer) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	t.call("AbsArcTo", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+func (t *tracer) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	t.call("RelArcTo", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
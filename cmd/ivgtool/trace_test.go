@@ -0,0 +1,86 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestFormatColor(t *testing.T) {
+	testCases := []struct {
+		c    lowlevel.Color
+		want string
+	}{
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0x78}), "#12345678"},
+		{lowlevel.PaletteIndexColor(5), "pal[5]"},
+		{lowlevel.CRegColor(63), "creg[63]"},
+		{lowlevel.BlendColor(0x40, 0x85, 0xc1), "blend(64, pal[5], creg[1])"},
+	}
+	for _, tc := range testCases {
+		if got := formatColor(tc.c); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 0\n" +
+		"creg.3 [csel] #ff0000\npath [csel] 0 0\nL 10 0\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "in.ivg")
+	if err := os.WriteFile(filename, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := runCommand(t, "trace", []string{filename}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		"viewBox -32 -32 32 32\n",
+		"0x0005  creg.3           90 ff 00 00\n",
+		"        SetCReg(0, false, #ff0000ff)\n",
+		"0x0009  path             c0 80 80\n",
+		"        StartPath(0, 0, 0)\n",
+		"0x000c  L                00 94 80\n",
+		"        AbsLineTo(10, 0)\n",
+		"0x000f  z                e1\n",
+		"        ClosePathEndPath()\n",
+	}
+	if want := strings.Join(lines, ""); string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Decoding errors are reported after the trace of the instructions
+	// before the error.
+	if err := os.WriteFile(filename, src[:14], 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = runCommand(t, "trace", []string{filename}, "")
+	if err == nil {
+		t.Errorf("truncated: got nil error, want non-nil")
+	}
+	if want := strings.Join(lines[:5], ""); string(got) != want {
+		t.Errorf("truncated: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f64"
+)
+
+const transformUsage = "Usage: %s [-matrix a,b,c,d,e,f] [-scale s | sx,sy] [-mirror x | y] " +
+	"[-rotate degrees] [-translate tx,ty] in.ivg > out.ivg\n" +
+	"    in.ivg may be omitted, in which case stdin is read.\n" +
+	"    The transformations apply in that order. Scaling, mirroring and\n" +
+	"    rotating are about the center of the graphic's viewBox. Positive\n" +
+	"    angles rotate clockwise, as the y axis points down."
+
+// runTransform implements "ivgtool transform", which applies an affine
+// transformation to a graphic's drawing, such as mirroring it for
+// right-to-left locales, and re-encodes it.
+func runTransform(fs *flag.FlagSet, args []string) error {
+	usageErr := fmt.Errorf(transformUsage, fs.Name())
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usageErr) }
+	matrixFlag := fs.String("matrix", "", "affine transformation matrix, as six comma-separated numbers")
+	scaleFlag := fs.String("scale", "", "scale factor, or comma-separated x and y scale factors")
+	mirrorFlag := fs.String("mirror", "", `mirror axis: "x" flips left-right, "y" flips top-bottom`)
+	rotateFlag := fs.Float64("rotate", 0, "clockwise rotation, in degrees")
+	translateFlag := fs.String("translate", "", "comma-separated x and y translation, in graphic units")
+	fs.Parse(args)
+
+	src, err := readInput(fs.Args(), usageErr)
+	if err != nil {
+		return err
+	}
+	meta, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		return err
+	}
+	vb := &meta.ViewBox
+	cx := float64(vb.Min[0]+vb.Max[0]) / 2
+	cy := float64(vb.Min[1]+vb.Max[1]) / 2
+
+	// m accumulates the transformations. Each one is applied after those
+	// before it.
+	m := f64.Aff3{1, 0, 0, 0, 1, 0}
+	if *matrixFlag != "" {
+		f, err := parseFloats(*matrixFlag, 6, 6)
+		if err != nil {
+			return err
+		}
+		m = f64.Aff3{f[0], f[1], f[2], f[3], f[4], f[5]}
+	}
+	if *scaleFlag != "" {
+		f, err := parseFloats(*scaleFlag, 1, 2)
+		if err != nil {
+			return err
+		}
+		sx, sy := f[0], f[0]
+		if len(f) == 2 {
+			sy = f[1]
+		}
+		m = mul(aboutCenter(f64.Aff3{sx, 0, 0, 0, sy, 0}, cx, cy), m)
+	}
+	switch *mirrorFlag {
+	case "":
+	case "x":
+		m = mul(aboutCenter(f64.Aff3{-1, 0, 0, 0, 1, 0}, cx, cy), m)
+	case "y":
+		m = mul(aboutCenter(f64.Aff3{1, 0, 0, 0, -1, 0}, cx, cy), m)
+	default:
+		return fmt.Errorf("invalid -mirror axis %q", *mirrorFlag)
+	}
+	if *rotateFlag != 0 {
+		sin, cos := sincosDegrees(*rotateFlag)
+		m = mul(aboutCenter(f64.Aff3{cos, -sin, 0, sin, cos, 0}, cx, cy), m)
+	}
+	if *translateFlag != "" {
+		f, err := parseFloats(*translateFlag, 2, 2)
+		if err != nil {
+			return err
+		}
+		m = mul(f64.Aff3{1, 0, f[0], 0, 1, f[1]}, m)
+	}
+
+	dst, err := ivg.Transform(src, m)
+	if err != nil {
+		return err
+	}
+	return writeOutput(dst)
+}
+
+// mul returns the affine transformation that applies b and then a.
+func mul(a, b f64.Aff3) f64.Aff3 {
+	return f64.Aff3{
+		a[0]*b[0] + a[1]*b[3],
+		a[0]*b[1] + a[1]*b[4],
+		a[0]*b[2] + a[1]*b[5] + a[2],
+		a[3]*b[0] + a[4]*b[3],
+		a[3]*b[1] + a[4]*b[4],
+		a[3]*b[2] + a[4]*b[5] + a[5],
+	}
+}
+
+// aboutCenter returns the linear transformation m applied about the point
+// (cx, cy) instead of the origin.
+func aboutCenter(m f64.Aff3, cx, cy float64) f64.Aff3 {
+	return mul(f64.Aff3{1, 0, cx, 0, 1, cy}, mul(m, f64.Aff3{1, 0, -cx, 0, 1, -cy}))
+}
+
+// sincosDegrees is like math.Sincos but takes degrees, and is exact for
+// multiples of 90 degrees, so that quarter turns do not perturb coordinates.
+func sincosDegrees(deg float64) (sin, cos float64) {
+	switch math.Mod(deg, 360) {
+	case 0:
+		return 0, 1
+	case 90, -270:
+		return 1, 0
+	case 180, -180:
+		return 0, -1
+	case 270, -90:
+		return -1, 0
+	}
+	return math.Sincos(deg * math.Pi / 180)
+}
+
+// parseFloats parses comma-separated numbers, expecting between min and max
+// of them.
+func parseFloats(s string, min, max int) ([]float64, error) {
+	fields := strings.Split(s, ",")
+	if (len(fields) < min) || (len(fields) > max) {
+		return nil, fmt.Errorf("invalid number list %q", s)
+	}
+	f := make([]float64, len(fields))
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("invalid number %q", field)
+		}
+		f[i] = x
+	}
+	return f, nil
+}
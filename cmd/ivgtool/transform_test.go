@@ -0,0 +1,116 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/image/math/f64"
+)
+
+func TestMul(t *testing.T) {
+	testCases := []struct {
+		desc string
+		a, b f64.Aff3
+		want f64.Aff3
+	}{
+		{"identity", f64.Aff3{1, 0, 0, 0, 1, 0}, f64.Aff3{2, 3, 4, 5, 6, 7}, f64.Aff3{2, 3, 4, 5, 6, 7}},
+		{"translate after scale", f64.Aff3{1, 0, 10, 0, 1, 20}, f64.Aff3{2, 0, 0, 0, 3, 0}, f64.Aff3{2, 0, 10, 0, 3, 20}},
+		{"scale after translate", f64.Aff3{2, 0, 0, 0, 3, 0}, f64.Aff3{1, 0, 10, 0, 1, 20}, f64.Aff3{2, 0, 20, 0, 3, 60}},
+	}
+	for _, tc := range testCases {
+		if got := mul(tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestAboutCenter(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		m      f64.Aff3
+		cx, cy float64
+		want   f64.Aff3
+	}{
+		{"mirror about the origin", f64.Aff3{-1, 0, 0, 0, 1, 0}, 0, 0, f64.Aff3{-1, 0, 0, 0, 1, 0}},
+		{"mirror about x = 24", f64.Aff3{-1, 0, 0, 0, 1, 0}, 24, 24, f64.Aff3{-1, 0, 48, 0, 1, 0}},
+		{"scale about (10, 20)", f64.Aff3{2, 0, 0, 0, 2, 0}, 10, 20, f64.Aff3{2, 0, -10, 0, 2, -20}},
+	}
+	for _, tc := range testCases {
+		if got := aboutCenter(tc.m, tc.cx, tc.cy); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestSincosDegrees(t *testing.T) {
+	testCases := []struct {
+		deg              float64
+		wantSin, wantCos float64
+	}{
+		{0, 0, 1},
+		{90, 1, 0},
+		{180, 0, -1},
+		{270, -1, 0},
+		{360, 0, 1},
+		{-90, -1, 0},
+		{450, 1, 0},
+	}
+	for _, tc := range testCases {
+		sin, cos := sincosDegrees(tc.deg)
+		if sin != tc.wantSin || cos != tc.wantCos {
+			t.Errorf("%v: got (%v, %v), want (%v, %v)", tc.deg, sin, cos, tc.wantSin, tc.wantCos)
+		}
+	}
+	if sin, cos := sincosDegrees(30); sin < 0.4999 || sin > 0.5001 || cos < 0.8660 || cos > 0.8661 {
+		t.Errorf("30: got (%v, %v), want (0.5, 0.866)", sin, cos)
+	}
+}
+
+func TestParseFloats(t *testing.T) {
+	testCases := []struct {
+		s        string
+		min, max int
+		want     []float64
+		wantErr  bool
+	}{
+		{"1", 1, 2, []float64{1}, false},
+		{"1, -2.5", 1, 2, []float64{1, -2.5}, false},
+		{"1,2,3,4,5,6", 6, 6, []float64{1, 2, 3, 4, 5, 6}, false},
+		{"1,2,3", 1, 2, nil, true},
+		{"1", 2, 2, nil, true},
+		{"x", 1, 1, nil, true},
+		{"NaN", 1, 1, nil, true},
+		{"Inf", 1, 1, nil, true},
+		{"", 1, 1, nil, true},
+	}
+	for _, tc := range testCases {
+		got, err := parseFloats(tc.s, tc.min, tc.max)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js && wasm
+
+// ----------------
+
+// ivgwasm is a WebAssembly module that lets web pages decode and rasterize
+// IconVG graphics. It defines the decodeToCanvasPath2D and renderToImageData
+// global JavaScript functions described in package wasm.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o ivg.wasm ./cmd/ivgwasm
+//
+// and load it with the wasm_exec.js support file that ships with Go:
+//
+//	const go = new Go();
+//	const r = await WebAssembly.instantiateStreaming(fetch("ivg.wasm"), go.importObject);
+//	go.run(r.instance);
+//	const img = renderToImageData(new Uint8Array(await (await fetch("x.ivg")).arrayBuffer()), 48);
+//	canvas.getContext("2d").putImageData(img, 0, 0);
+package main
+
+import (
+	"github.com/google/iconvg/src/go/wasm"
+)
+
+func main() {
+	wasm.Register()
+	select {}
+}
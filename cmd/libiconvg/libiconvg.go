@@ -0,0 +1,289 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ----------------
+
+// libiconvg is a C shared library that exposes the Go IconVG encoder, so that
+// plugins for design tools, written in languages other than Go, can produce
+// IconVG without re-implementing the encoder. Build it with:
+//
+//	go build -buildmode=c-shared -o libiconvg.so ./cmd/libiconvg
+//
+// which also writes the libiconvg.h header. Its functions are:
+//
+//	uintptr_t iconvg_encode_begin(float min_x, float min_y, float max_x, float max_y);
+//	void iconvg_encode_set_palette(uintptr_t e, const uint8_t* rgba, int n);
+//	void iconvg_encode_set_lod(uintptr_t e, float lod0, float lod1);
+//	void iconvg_encode_fill_rgba(uintptr_t e, uint8_t r, uint8_t g, uint8_t b, uint8_t a);
+//	void iconvg_encode_fill_palette(uintptr_t e, uint8_t index);
+//	void iconvg_encode_fill_linear_gradient(uintptr_t e, float x1, float y1, float x2, float y2,
+//		int spread, int n_stops, const float* offsets, const uint8_t* rgba);
+//	void iconvg_encode_fill_radial_gradient(uintptr_t e, float cx, float cy, float r,
+//		int spread, int n_stops, const float* offsets, const uint8_t* rgba);
+//	void iconvg_encode_path(uintptr_t e, const uint8_t* verbs, int n_verbs,
+//		const float* coords, int n_coords);
+//	int iconvg_encode_end(uintptr_t e, uint8_t** out, size_t* out_len, char** err);
+//	void iconvg_free(void* p);
+//
+// iconvg_encode_begin starts a graphic with the given viewBox, and returns an
+// encoder handle. iconvg_encode_end encodes the graphic and releases the
+// handle, which must not be used afterwards. Every iconvg_encode_begin call
+// must be matched by exactly one iconvg_encode_end call.
+//
+// Colors are 4 bytes, R, G, B and A, that are not alpha-premultiplied, as
+// design tools usually store them. set_palette's rgba holds n colors, at most
+// 64, and fill_*_gradient's rgba holds n_stops colors. A gradient's spread is
+// 0, 1, 2 or 3 for none, pad, reflect or repeat, as for ivg.GradientSpread.
+//
+// The fill_* functions set the paint for subsequent paths, which is initially
+// opaque black. iconvg_encode_path fills a path, built from verbs, each an
+// ASCII letter consuming some coords: 'M' (move to x, y), 'L' (line to x, y),
+// 'Q' (quadratic Bézier curve to x1, y1, x, y), 'C' (cubic Bézier curve to x1,
+// y1, x2, y2, x, y), 'A' (elliptical arc to rx, ry, x_axis_rotation,
+// large_arc, sweep, x, y, where the rotation is in revolutions and non-zero
+// flags are true) and 'Z' (close path). All coordinates are absolute.
+//
+// Errors, such as an invalid verb, are sticky: the encoder ignores every call
+// after the first error, and iconvg_encode_end reports it. It returns 0 on
+// success, setting *out and *out_len to the IconVG graphic. Otherwise, it
+// returns -1 and, if err is not NULL, sets *err to the error message. The
+// caller frees *out or *err with iconvg_free.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+var (
+	errInvalidGradient = errors.New("libiconvg: invalid gradient")
+	errInvalidPalette  = errors.New("libiconvg: invalid palette")
+)
+
+// encoder is the state behind an encoder handle.
+type encoder struct {
+	b     *ivg.Builder
+	paint ivg.Paint
+	err   error
+}
+
+func main() {}
+
+func lookup(h C.uintptr_t) *encoder {
+	return cgo.Handle(h).Value().(*encoder)
+}
+
+//export iconvg_encode_begin
+func iconvg_encode_begin(minX, minY, maxX, maxY C.float) C.uintptr_t {
+	e := &encoder{
+		b:     ivg.NewBuilder(),
+		paint: ivg.Paint{Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+	}
+	e.b.SetViewBox(float32(minX), float32(minY), float32(maxX), float32(maxY))
+	return C.uintptr_t(cgo.NewHandle(e))
+}
+
+//export iconvg_encode_set_palette
+func iconvg_encode_set_palette(h C.uintptr_t, rgba *C.uint8_t, n C.int) {
+	e := lookup(h)
+	if e.err != nil {
+		return
+	}
+	p := lowlevel.DefaultPalette
+	if (n < 0) || (int(n) > len(p)) {
+		e.err = errInvalidPalette
+		return
+	}
+	for i, c := range colors(rgba, int(n)) {
+		p[i] = c
+	}
+	e.b.SetPalette(&p)
+}
+
+//export iconvg_encode_set_lod
+func iconvg_encode_set_lod(h C.uintptr_t, lod0, lod1 C.float) {
+	if e := lookup(h); e.err == nil {
+		e.b.SetLOD(float32(lod0), float32(lod1))
+	}
+}
+
+//export iconvg_encode_fill_rgba
+func iconvg_encode_fill_rgba(h C.uintptr_t, r, g, b, a C.uint8_t) {
+	if e := lookup(h); e.err == nil {
+		e.paint = ivg.Paint{Color: lowlevel.RGBAColor(premul(uint8(r), uint8(g), uint8(b), uint8(a)))}
+	}
+}
+
+//export iconvg_encode_fill_palette
+func iconvg_encode_fill_palette(h C.uintptr_t, index C.uint8_t) {
+	e := lookup(h)
+	if e.err != nil {
+		return
+	}
+	if index >= 64 {
+		e.err = errInvalidPalette
+		return
+	}
+	e.paint = ivg.Paint{Color: lowlevel.PaletteIndexColor(uint8(index))}
+}
+
+//export iconvg_encode_fill_linear_gradient
+func iconvg_encode_fill_linear_gradient(h C.uintptr_t, x1, y1, x2, y2 C.float, spread, nStops C.int, offsets *C.float, rgba *C.uint8_t) {
+	e := lookup(h)
+	if e.err != nil {
+		return
+	}
+	stops, err := gradientStops(spread, nStops, offsets, rgba)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.paint = ivg.LinearGradient(stops, float32(x1), float32(y1), float32(x2), float32(y2), ivg.GradientSpread(spread))
+}
+
+//export iconvg_encode_fill_radial_gradient
+func iconvg_encode_fill_radial_gradient(h C.uintptr_t, cx, cy, r C.float, spread, nStops C.int, offsets *C.float, rgba *C.uint8_t) {
+	e := lookup(h)
+	if e.err != nil {
+		return
+	}
+	stops, err := gradientStops(spread, nStops, offsets, rgba)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.paint = ivg.RadialGradient(stops, float32(cx), float32(cy), float32(r), ivg.GradientSpread(spread))
+}
+
+//export iconvg_encode_path
+func iconvg_encode_path(h C.uintptr_t, verbs *C.uint8_t, nVerbs C.int, coords *C.float, nCoords C.int) {
+	e := lookup(h)
+	if e.err != nil {
+		return
+	}
+	if (nVerbs < 0) || (nCoords < 0) {
+		e.err = errors.New("libiconvg: invalid path")
+		return
+	}
+	vs := unsafe.Slice((*uint8)(unsafe.Pointer(verbs)), int(nVerbs))
+	cs := unsafe.Slice((*float32)(unsafe.Pointer(coords)), int(nCoords))
+
+	// Check the whole path before adding any of it to the Builder.
+	n := 0
+	for _, v := range vs {
+		k, ok := verbCoords[v]
+		if !ok {
+			e.err = fmt.Errorf("libiconvg: invalid path verb %q", v)
+			return
+		}
+		n += k
+	}
+	if n != len(cs) {
+		e.err = fmt.Errorf("libiconvg: path verbs need %d coords, have %d", n, len(cs))
+		return
+	}
+
+	b := e.b
+	for _, v := range vs {
+		switch v {
+		case 'M':
+			b.MoveTo(cs[0], cs[1])
+		case 'L':
+			b.LineTo(cs[0], cs[1])
+		case 'Q':
+			b.QuadTo(cs[0], cs[1], cs[2], cs[3])
+		case 'C':
+			b.CubeTo(cs[0], cs[1], cs[2], cs[3], cs[4], cs[5])
+		case 'A':
+			b.ArcTo(cs[0], cs[1], cs[2], cs[3] != 0, cs[4] != 0, cs[5], cs[6])
+		case 'Z':
+			b.ClosePath()
+		}
+		cs = cs[verbCoords[v]:]
+	}
+	b.FillPaint(e.paint)
+}
+
+// verbCoords maps iconvg_encode_path's verbs to their number of coords.
+var verbCoords = map[uint8]int{
+	'M': 2,
+	'L': 2,
+	'Q': 4,
+	'C': 6,
+	'A': 7,
+	'Z': 0,
+}
+
+//export iconvg_encode_end
+func iconvg_encode_end(h C.uintptr_t, out **C.uint8_t, outLen *C.size_t, errMsg **C.char) C.int {
+	e := lookup(h)
+	cgo.Handle(h).Delete()
+
+	data, err := []byte(nil), e.err
+	if err == nil {
+		data, err = e.b.Bytes()
+	}
+	if err != nil {
+		if errMsg != nil {
+			*errMsg = C.CString(err.Error())
+		}
+		return -1
+	}
+	*out = (*C.uint8_t)(C.CBytes(data))
+	*outLen = C.size_t(len(data))
+	return 0
+}
+
+//export iconvg_free
+func iconvg_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+// colors returns the n non-alpha-premultiplied colors at rgba, premultiplied.
+func colors(rgba *C.uint8_t, n int) []color.RGBA {
+	b := unsafe.Slice((*uint8)(unsafe.Pointer(rgba)), 4*n)
+	cs := make([]color.RGBA, n)
+	for i := range cs {
+		cs[i] = premul(b[4*i+0], b[4*i+1], b[4*i+2], b[4*i+3])
+	}
+	return cs
+}
+
+func gradientStops(spread, nStops C.int, offsets *C.float, rgba *C.uint8_t) ([]ivg.GradientStop, error) {
+	if (spread < 0) || (spread > 3) || (nStops <= 0) {
+		return nil, errInvalidGradient
+	}
+	offs := unsafe.Slice((*float32)(unsafe.Pointer(offsets)), int(nStops))
+	stops := make([]ivg.GradientStop, nStops)
+	for i, c := range colors(rgba, int(nStops)) {
+		stops[i] = ivg.GradientStop{Offset: offs[i], Color: lowlevel.RGBAColor(c)}
+	}
+	return stops, nil
+}
+
+func premul(r, g, b, a uint8) color.RGBA {
+	return color.RGBAModel.Convert(color.NRGBA{r, g, b, a}).(color.RGBA)
+}
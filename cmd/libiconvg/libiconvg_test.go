@@ -0,0 +1,185 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// testProgram exercises the library's functions. It writes the encoded
+// graphic to stdout, or the error message to stderr.
+const testProgram = `
+#include <stdint.h>
+#include <stdio.h>
+#include <string.h>
+#include "libiconvg.h"
+
+int main(int argc, char** argv) {
+	uintptr_t e = iconvg_encode_begin(-32, -32, 32, 32);
+
+	uint8_t palette[4] = {0xff, 0x00, 0x00, 0xff};
+	iconvg_encode_set_palette(e, palette, 1);
+
+	uint8_t square[5] = "MLLLZ";
+	float square_coords[8] = {-32, -32, 0, -32, 0, 0, -32, 0};
+	iconvg_encode_fill_palette(e, 0);
+	iconvg_encode_path(e, square, 5, square_coords, 8);
+
+	uint8_t arc[3] = "MAZ";
+	float arc_coords[9] = {0, 0, 8, 8, 0, 0, 1, 16, 16};
+	iconvg_encode_fill_rgba(e, 0x00, 0x00, 0xff, 0x80);
+	iconvg_encode_path(e, arc, 3, arc_coords, 9);
+
+	float offsets[2] = {0, 1};
+	uint8_t stops[8] = {0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff};
+	iconvg_encode_fill_linear_gradient(e, 0, 0, 32, 0, 1, 2, offsets, stops);
+	iconvg_encode_set_lod(e, 0, 64);
+	iconvg_encode_path(e, square, 5, square_coords, 8);
+
+	if (argc > 1) {
+		uint8_t bad[1] = "X";
+		iconvg_encode_path(e, bad, 1, NULL, 0);
+	}
+
+	uint8_t* out = NULL;
+	size_t out_len = 0;
+	char* err = NULL;
+	if (iconvg_encode_end(e, &out, &out_len, &err) != 0) {
+		fputs(err, stderr);
+		iconvg_free(err);
+		return 1;
+	}
+	fwrite(out, 1, out_len, stdout);
+	iconvg_free(out);
+	return 0;
+}
+`
+
+func buildTestProgram(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	if os.Getenv("CGO_ENABLED") == "0" {
+		t.Skip("skipping without cgo")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping on " + runtime.GOOS)
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("skipping without a C compiler")
+	}
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "libiconvg.so")
+	if out, err := exec.Command("go", "build", "-buildmode=c-shared", "-o", lib, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	src := filepath.Join(dir, "test.c")
+	if err := os.WriteFile(src, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prog := filepath.Join(dir, "test")
+	cmd := exec.Command(cc, "-o", prog, src, "-L"+dir, "-liconvg", "-Wl,-rpath,"+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("cc: %v\n%s", err, out)
+	}
+	return prog
+}
+
+func TestLibrary(t *testing.T) {
+	prog := buildTestProgram(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(prog)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	g, err := ivg.Decode(stdout.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Metadata.ViewBox, lowlevel.DefaultViewBox; got != want {
+		t.Errorf("ViewBox: got %v, want %v", got, want)
+	}
+	if got, want := g.Metadata.Palette[0], (color.RGBA{0xff, 0x00, 0x00, 0xff}); got != want {
+		t.Errorf("Palette[0]: got %v, want %v", got, want)
+	}
+	if got, want := len(g.Shapes), 3; got != want {
+		t.Fatalf("Shapes: got %d, want %d", got, want)
+	}
+
+	testCases := []struct {
+		desc     string
+		s        ivg.Shape
+		wantRGBA color.RGBA
+		wantGrad bool
+		wantLOD1 float32
+	}{
+		{"palette fill", g.Shapes[0], color.RGBA{0xff, 0x00, 0x00, 0xff}, false, ivg.DefaultLOD1},
+		// fill_rgba's colors are not alpha-premultiplied.
+		{"rgba fill", g.Shapes[1], color.RGBA{0x00, 0x00, 0x80, 0x80}, false, ivg.DefaultLOD1},
+		{"gradient fill", g.Shapes[2], color.RGBA{}, true, 64},
+	}
+	for _, tc := range testCases {
+		if got := tc.s.Paint.Gradient != nil; got != tc.wantGrad {
+			t.Errorf("%s: gradient: got %t, want %t", tc.desc, got, tc.wantGrad)
+		} else if !got {
+			if rgba := tc.s.Paint.Color.Resolve(&g.Metadata.Palette, nil); rgba != tc.wantRGBA {
+				t.Errorf("%s: color: got %v, want %v", tc.desc, rgba, tc.wantRGBA)
+			}
+		}
+		if tc.s.LOD1 != tc.wantLOD1 {
+			t.Errorf("%s: LOD1: got %v, want %v", tc.desc, tc.s.LOD1, tc.wantLOD1)
+		}
+	}
+	hasArc := false
+	for _, seg := range g.Shapes[1].Path {
+		_, ok := seg.(ivg.ArcTo)
+		hasArc = hasArc || ok
+	}
+	if !hasArc {
+		t.Errorf("rgba fill: path: got no ArcTo, want one")
+	}
+}
+
+func TestLibraryError(t *testing.T) {
+	prog := buildTestProgram(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(prog, "bad")
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout: got %d bytes, want none", stdout.Len())
+	}
+	if got, want := stderr.String(), `invalid path verb 'X'`; !strings.Contains(got, want) {
+		t.Errorf("stderr: got %q, want it to contain %q", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// png2ivg traces flat-color PNG icons as IconVG graphics. It is experimental.
+//
+// Usage: png2ivg [-viewbox minX,minY,maxX,maxY] [-colors n] [-tolerance t]
+// [-minarea a] [-out dir] in.png...
+//
+// Each in.png is written to the -out directory, with its ".png" extension
+// replaced by ".ivg". A larger -tolerance, in pixels, gives smaller but less
+// faithful graphics.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/trace"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	viewBoxFlag   = flag.String("viewbox", "-32,-32,32,32", "the IconVG viewBox: minX,minY,maxX,maxY")
+	colorsFlag    = flag.Int("colors", trace.DefaultColors, "the maximum number of colors, including transparent")
+	toleranceFlag = flag.Float64("tolerance", trace.DefaultTolerance, "the maximum distance, in pixels, between the traced and the fitted outlines")
+	minAreaFlag   = flag.Float64("minarea", 0, "drop outlines that enclose fewer pixels than this")
+	outFlag       = flag.String("out", ".", "output directory")
+)
+
+func main() {
+	if err := main1(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func main1() error {
+	cmd := "png2ivg"
+	if len(os.Args) > 0 {
+		cmd = os.Args[0]
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		return fmt.Errorf("Usage: %s [-viewbox minX,minY,maxX,maxY] [-colors n] [-tolerance t] "+
+			"[-minarea a] [-out dir] in.png...", cmd)
+	}
+
+	opts := &trace.Options{
+		Colors:    *colorsFlag,
+		Tolerance: *toleranceFlag,
+		MinArea:   *minAreaFlag,
+	}
+	var err error
+	if opts.ViewBox, err = parseViewBox(*viewBoxFlag); err != nil {
+		return err
+	}
+
+	for _, arg := range flag.Args() {
+		if err := convert(arg, opts); err != nil {
+			return fmt.Errorf("%s: %v", arg, err)
+		}
+	}
+	return nil
+}
+
+func convert(filename string, opts *trace.Options) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := png.Decode(f)
+	if err != nil {
+		return err
+	}
+	data, err := trace.Encode(m, opts)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + ".ivg"
+	return os.WriteFile(filepath.Join(*outFlag, name), data, 0644)
+}
+
+func parseViewBox(s string) (lowlevel.Rectangle, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	v := [4]float32{}
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+		}
+		v[i] = float32(x)
+	}
+	if !(v[0] < v[2]) || !(v[1] < v[3]) {
+		return lowlevel.Rectangle{}, fmt.Errorf("invalid viewBox %q", s)
+	}
+	return lowlevel.Rectangle{
+		Min: f32.Vec2{v[0], v[1]},
+		Max: f32.Vec2{v[2], v[3]},
+	}, nil
+}
@@ -2,4 +2,6 @@ module github.com/google/iconvg
 
 go 1.17
 
-require golang.org/x/image v0.0.0-20210504121937-7319ad40d33e // indirect
+require golang.org/x/image v0.0.0-20210504121937-7319ad40d33e
+
+require golang.org/x/text v0.3.0 // indirect
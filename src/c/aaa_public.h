@@ -84,6 +84,7 @@
 // Other errors (invalid_etc, null_etc, unsupported_etc) are programming errors
 // instead of file format errors.
 
+extern const char iconvg_error_bad_checksum[];                    // ¶0.1
 extern const char iconvg_error_bad_color[];                       // ¶0.1
 extern const char iconvg_error_bad_coordinate[];                  // ¶0.1
 extern const char iconvg_error_bad_drawing_opcode[];              // ¶0.1
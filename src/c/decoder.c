@@ -279,6 +279,32 @@ iconvg_private_decoder__decode_metadata_suggested_palette(
   return true;
 }
 
+// iconvg_private_decoder__decode_metadata_checksum verifies a MID 3
+// (Checksum) chunk: the CRC-32C (Castagnoli) of the whole graphic, from
+// src_ptr to src_ptr + src_len, computed as if the chunk's 4 bytes were zero.
+static bool  //
+iconvg_private_decoder__decode_metadata_checksum(iconvg_private_decoder* self,
+                                                 const uint8_t* src_ptr,
+                                                 size_t src_len) {
+  if (self->len != 4) {
+    return false;
+  }
+  const uint8_t* at = self->ptr;
+  uint32_t want = iconvg_private_peek_u32le(at);
+  self->ptr += 4;
+  self->len = 0;
+
+  uint32_t crc = 0xFFFFFFFFu;
+  for (size_t i = 0; i < src_len; i++) {
+    const uint8_t* p = src_ptr + i;
+    crc ^= ((p >= at) && (p < (at + 4))) ? 0 : *p;
+    for (int j = 0; j < 8; j++) {
+      crc = (crc >> 1) ^ (0x82F63B78u & (0u - (crc & 1)));
+    }
+  }
+  return want == ~crc;
+}
+
 // ----
 
 static const char*  //
@@ -928,6 +954,11 @@ iconvg_decode_viewbox(iconvg_rectangle_f32* dst_viewbox,
       } else if (dst_viewbox) {
         *dst_viewbox = r;
       }
+    } else if (metadata_id == 3) {  // MID 3 (Checksum).
+      if (!iconvg_private_decoder__decode_metadata_checksum(&chunk, src_ptr,
+                                                            src_len)) {
+        return iconvg_error_bad_checksum;
+      }
     }
 
     iconvg_private_decoder__skip_to_the_end(&chunk);
@@ -965,6 +996,8 @@ iconvg_private_decode(iconvg_canvas* c,
   memcpy(&state.custom_palette, &iconvg_private_default_palette,
          sizeof(state.custom_palette));
 
+  const uint8_t* src_ptr = d->ptr;
+  size_t src_len = d->len;
   if (!iconvg_private_decoder__decode_magic_identifier(d)) {
     return iconvg_error_bad_magic_identifier;
   }
@@ -1006,8 +1039,22 @@ iconvg_private_decode(iconvg_canvas* c,
         }
         break;
 
+      case 2:  // MID 2 (Title and Description).
+        // This decoder does not render text, so the chunk is skipped.
+        break;
+
+      case 3:  // MID 3 (Checksum).
+        if (!iconvg_private_decoder__decode_metadata_checksum(&chunk, src_ptr,
+                                                              src_len)) {
+          return iconvg_error_bad_checksum;
+        }
+        break;
+
       default:
-        return iconvg_error_bad_metadata;
+        // Skip any other MID, such as MID 4 (Detail Levels) or a vendor MID
+        // (1024 or more). Decoders ignore chunks whose MID they do not
+        // recognize.
+        break;
     }
 
     iconvg_private_decoder__skip_to_the_end(&chunk);
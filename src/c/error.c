@@ -14,6 +14,8 @@
 
 #include "./aaa_private.h"
 
+const char iconvg_error_bad_checksum[] =  //
+    "iconvg: bad checksum";
 const char iconvg_error_bad_color[] =  //
     "iconvg: bad color";
 const char iconvg_error_bad_coordinate[] =  //
@@ -56,7 +58,8 @@ const char iconvg_private_internal_error_unreachable[] =  //
 
 bool  //
 iconvg_error_is_file_format_error(const char* err_msg) {
-  return (err_msg == iconvg_error_bad_color) ||
+  return (err_msg == iconvg_error_bad_checksum) ||
+         (err_msg == iconvg_error_bad_color) ||
          (err_msg == iconvg_error_bad_coordinate) ||
          (err_msg == iconvg_error_bad_drawing_opcode) ||
          (err_msg == iconvg_error_bad_magic_identifier) ||
@@ -493,6 +493,23 @@ class _IconVGMachine {
     }
   }
 
+  // Checks the little-endian CRC-32C (Castagnoli) at bytes[offset..offset+4]
+  // against the whole graphic, computed as if those four bytes were zero.
+  void verifyChecksum(int offset) {
+    final int expected = bytes[offset] | (bytes[offset + 1] << 8) | (bytes[offset + 2] << 16) | (bytes[offset + 3] << 24);
+    int crc = 0xFFFFFFFF;
+    for (int index = 0; index < bytes.length; index += 1) {
+      crc ^= (index >= offset && index < offset + 4) ? 0 : bytes[index];
+      for (int bit = 0; bit < 8; bit += 1) {
+        crc = (crc & 1) != 0 ? (crc >> 1) ^ 0x82F63B78 : crc >> 1;
+      }
+    }
+    crc ^= 0xFFFFFFFF;
+    if (crc != expected) {
+      throw FormatException('Checksum mismatch (expected $expected, computed $crc).');
+    }
+  }
+
   void applyCustomPalette(List<Color> palette) {
     assert(!foundPalette);
     int index = 0;
@@ -576,6 +593,19 @@ class _IconVGMachine {
             foundPalette = true;
           }
           break;
+        case 2: // Title and Description
+          // The decoder does not render text, so the block is skipped.
+          break;
+        case 3: // Checksum
+          if (blockEnd - cursor != 4) {
+            throw FormatException('Checksum block must hold 4 bytes, not ${blockEnd - cursor}.');
+          }
+          verifyChecksum(cursor);
+          break;
+        default:
+          // Other blocks, such as Detail Levels (4) or vendor blocks (1024 and
+          // up), are not recognized and are skipped.
+          break;
       }
       // TODO(ianh): apply the decisions from https://github.com/google/iconvg/issues/11 (whether cursor can go past blockEnd)
       cursor = blockEnd;
@@ -22,6 +22,12 @@ void main() {
     IconVGFile(Uint8List.fromList(<int>[0x89, 0x49, 0x56, 0x47, 0x00]));
   });
 
+  testWidgets('checksum', (WidgetTester tester) async {
+    IconVGFile(Uint8List.fromList(<int>[0x89, 0x49, 0x56, 0x47, 0x02, 0x0a, 0x06, 0xf4, 0xfb, 0x65, 0x4a]));
+    expect(() => IconVGFile(Uint8List.fromList(<int>[0x89, 0x49, 0x56, 0x47, 0x02, 0x0a, 0x06, 0xf4, 0xfb, 0x65, 0x4b])), throwsA(isA<FormatException>()));
+    expect(() => IconVGFile(Uint8List.fromList(<int>[0x89, 0x49, 0x56, 0x47, 0x02, 0x08, 0x06, 0xf4, 0xfb, 0x65])), throwsA(isA<FormatException>()));
+  });
+
   final Uint8List actionInfoHiResBytes = File('../../test/data/action-info.hires.ivg').readAsBytesSync();
   final Uint8List actionInfoLoResBytes = File('../../test/data/action-info.lores.ivg').readAsBytesSync();
   final Uint8List arcsBytes = File('../../test/data/arcs.ivg').readAsBytesSync();
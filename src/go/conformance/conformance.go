@@ -0,0 +1,116 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance holds test vectors for the worked examples in the
+// IconVG specification: the byte-by-byte encodings that it gives for numbers,
+// colors and the action/info example graphic.
+//
+// Each Vector is a complete IconVG graphic, built around one example, and the
+// lowlevel.Destination method calls that it decodes to. Check verifies that
+// the Go decoder decodes the graphic to those calls and, for encodings that
+// are the shortest possible, that the Go encoder encodes those calls to the
+// graphic, byte for byte. This guards against the implementation silently
+// drifting from the specification.
+//
+// The specification's 2 and 4 byte natural number examples are not vectors,
+// as no valid graphic contains them. Natural numbers only occur in metadata,
+// whose chunks are shorter than 8406 bytes, and in arc flags. The 4 byte
+// example's bytes are also the 4 byte real number example, which is a vector.
+//
+// IconVG is specified at
+// https://github.com/google/iconvg/blob/main/spec/iconvg-spec.md
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Vector is a test vector: an IconVG graphic and what it decodes to.
+type Vector struct {
+	// Name identifies the Vector. Section is the title of the specification
+	// section whose worked example the Vector is.
+	Name    string
+	Section string
+
+	// Bytes is the graphic's encoding.
+	Bytes []byte
+
+	// Metadata is the graphic's metadata, and Ops calls dst's methods for the
+	// graphic's operations, in order. Together, they are the Destination
+	// method calls that Bytes decodes to, as Reset is called with Metadata.
+	Metadata lowlevel.Metadata
+	Ops      func(dst lowlevel.Destination)
+
+	// Canonical is whether Bytes is the shortest encoding, which the Go
+	// encoder, lowlevel.Encoder, chooses. It is false for examples of
+	// encodings that are valid but longer than necessary.
+	Canonical bool
+}
+
+// Check checks that the Go decoder, both lowlevel.Decode and
+// lowlevel.StreamDecoder, decodes v's Bytes to v's Metadata and Ops and, if v
+// is Canonical, that lowlevel.Encoder encodes v's Metadata and Ops to v's
+// Bytes. It returns an error describing the first difference, if any.
+func Check(v *Vector) error {
+	want := &recorder{}
+	want.Reset(v.Metadata)
+	v.Ops(want)
+
+	got := &recorder{}
+	if err := lowlevel.Decode(got, v.Bytes, nil); err != nil {
+		return fmt.Errorf("conformance: %s: decode: %w", v.Name, err)
+	} else if err := compareOps(got.ops, want.ops); err != nil {
+		return fmt.Errorf("conformance: %s: decode: %w", v.Name, err)
+	}
+
+	got = &recorder{}
+	if err := lowlevel.NewStreamDecoder(bytes.NewReader(v.Bytes)).Decode(got, nil); err != nil {
+		return fmt.Errorf("conformance: %s: stream decode: %w", v.Name, err)
+	} else if err := compareOps(got.ops, want.ops); err != nil {
+		return fmt.Errorf("conformance: %s: stream decode: %w", v.Name, err)
+	}
+
+	if !v.Canonical {
+		return nil
+	}
+	e := &lowlevel.Encoder{}
+	e.Reset(v.Metadata)
+	v.Ops(e)
+	enc, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("conformance: %s: encode: %w", v.Name, err)
+	} else if !bytes.Equal(enc, v.Bytes) {
+		return fmt.Errorf("conformance: %s: encode: got\n% x\nwant\n% x", v.Name, enc, v.Bytes)
+	}
+	return nil
+}
+
+func compareOps(got, want []string) error {
+	for i := 0; i < len(got) || i < len(want); i++ {
+		g, w := "(none)", "(none)"
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g != w {
+			return fmt.Errorf("op #%d: got %s, want %s", i, g, w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/iconvg/src/go/conformance"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestVectors(t *testing.T) {
+	for _, v := range conformance.Vectors {
+		if err := conformance.Check(v); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestTestData checks the test/data golden files that cmd/gen-testdata
+// regenerates: that the example graphic matches the specification and that
+// each graphic's disassembly is up to date.
+func TestTestData(t *testing.T) {
+	got, err := os.ReadFile("../../../test/data/action-info.hires.ivg")
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, conformance.Example.Bytes) {
+		t.Errorf("action-info.hires.ivg: got\n% x\nwant\n% x", got, conformance.Example.Bytes)
+	}
+
+	filenames, err := filepath.Glob("../../../test/data/*.ivg")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(filenames) == 0 {
+		t.Fatal("no test/data files found")
+	}
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(filename + ".disassembly")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &bytes.Buffer{}
+		if err := lowlevel.Disassemble(got, src); err != nil {
+			t.Errorf("%s: %v", filepath.Base(filename), err)
+		} else if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%s: disassembly differs from %s.disassembly", filepath.Base(filename), filepath.Base(filename))
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// recorder is a lowlevel.Destination that records its method calls as
+// strings. Numbers are formatted so that two calls' strings are equal if and
+// only if their float32 arguments are.
+type recorder struct {
+	ops []string
+}
+
+var (
+	_ lowlevel.Destination            = (*recorder)(nil)
+	_ lowlevel.DetailLevelDestination = (*recorder)(nil)
+)
+
+func (r *recorder) op(name string, args ...interface{}) {
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = fmt.Sprint(a)
+	}
+	r.ops = append(r.ops, name+"("+strings.Join(s, ", ")+")")
+}
+
+func (r *recorder) Reset(m lowlevel.Metadata) {
+	r.op("Reset", m.ViewBox, m.Palette, strconv.Quote(m.Title), strconv.Quote(m.Description), m.Checksum, m.DetailLevels, m.Extra)
+}
+
+func (r *recorder) EndDetailLevel() { r.op("EndDetailLevel") }
+
+func (r *recorder) SetCSel(cSel uint8) { r.op("SetCSel", cSel) }
+func (r *recorder) SetNSel(nSel uint8) { r.op("SetNSel", nSel) }
+
+func (r *recorder) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	r.op("SetCReg", adj, incr, formatColor(c))
+}
+
+func (r *recorder) SetNReg(adj uint8, incr bool, f float32) { r.op("SetNReg", adj, incr, f) }
+func (r *recorder) SetLOD(lod0, lod1 float32)               { r.op("SetLOD", lod0, lod1) }
+
+func (r *recorder) StartPath(adj uint8, x, y float32) { r.op("StartPath", adj, x, y) }
+func (r *recorder) ClosePathEndPath()                 { r.op("ClosePathEndPath") }
+func (r *recorder) ClosePathAbsMoveTo(x, y float32)   { r.op("ClosePathAbsMoveTo", x, y) }
+func (r *recorder) ClosePathRelMoveTo(x, y float32)   { r.op("ClosePathRelMoveTo", x, y) }
+
+func (r *recorder) AbsHLineTo(x float32)         { r.op("AbsHLineTo", x) }
+func (r *recorder) RelHLineTo(x float32)         { r.op("RelHLineTo", x) }
+func (r *recorder) AbsVLineTo(y float32)         { r.op("AbsVLineTo", y) }
+func (r *recorder) RelVLineTo(y float32)         { r.op("RelVLineTo", y) }
+func (r *recorder) AbsLineTo(x, y float32)       { r.op("AbsLineTo", x, y) }
+func (r *recorder) RelLineTo(x, y float32)       { r.op("RelLineTo", x, y) }
+func (r *recorder) AbsSmoothQuadTo(x, y float32) { r.op("AbsSmoothQuadTo", x, y) }
+func (r *recorder) RelSmoothQuadTo(x, y float32) { r.op("RelSmoothQuadTo", x, y) }
+
+func (r *recorder) AbsQuadTo(x1, y1, x, y float32) { r.op("AbsQuadTo", x1, y1, x, y) }
+func (r *recorder) RelQuadTo(x1, y1, x, y float32) { r.op("RelQuadTo", x1, y1, x, y) }
+
+func (r *recorder) AbsSmoothCubeTo(x2, y2, x, y float32) { r.op("AbsSmoothCubeTo", x2, y2, x, y) }
+func (r *recorder) RelSmoothCubeTo(x2, y2, x, y float32) { r.op("RelSmoothCubeTo", x2, y2, x, y) }
+
+func (r *recorder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.op("AbsCubeTo", x1, y1, x2, y2, x, y)
+}
+
+func (r *recorder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.op("RelCubeTo", x1, y1, x2, y2, x, y)
+}
+
+func (r *recorder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.op("AbsArcTo", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+func (r *recorder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.op("RelArcTo", rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+// formatColor formats c by its type and encoded fields, not by what it
+// resolves to, so that, for example, a palette index Color and a direct Color
+// are never equal.
+func formatColor(c lowlevel.Color) string {
+	if rgba, ok := c.RGBA(); ok {
+		return fmt.Sprintf("rgba(%02x:%02x:%02x:%02x)", rgba.R, rgba.G, rgba.B, rgba.A)
+	} else if i, ok := c.PaletteIndex(); ok {
+		return fmt.Sprintf("palette(%d)", i)
+	} else if i, ok := c.CReg(); ok {
+		return fmt.Sprintf("creg(%d)", i)
+	} else if t, c0, c1, ok := c.Blend(); ok {
+		return fmt.Sprintf("blend(%02x, %02x, %02x)", t, c0, c1)
+	}
+	return "invalid"
+}
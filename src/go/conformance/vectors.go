@@ -0,0 +1,336 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// graphic returns an IconVG graphic: the magic identifier followed by b, the
+// encoded metadata and operations.
+func graphic(b ...byte) []byte {
+	return append([]byte{0x89, 0x49, 0x56, 0x47}, b...)
+}
+
+// bits returns the float32 whose IEEE 754 binary representation is u. It
+// spells out the values of 4 byte number encodings, which drop the two least
+// significant bits of a float32.
+func bits(u uint32) float32 {
+	return math.Float32frombits(u)
+}
+
+// defaultMetadata is the metadata of a graphic without metadata chunks.
+var defaultMetadata = lowlevel.Metadata{
+	ViewBox: lowlevel.DefaultViewBox,
+	Palette: lowlevel.DefaultPalette,
+}
+
+// Example is the specification's example graphic, Material Design's
+// "action/info" icon, which test/data/action-info.hires.ivg also holds.
+var Example = Vector{
+	Name:    "action-info",
+	Section: "Example",
+	Bytes: graphic(
+		0x02, 0x0a, 0x00, 0x50, 0x50, 0xb0, 0xb0, 0xc0, 0x80, 0x58, 0xa0, 0xcf,
+		0xcc, 0x30, 0xc1, 0x58, 0x58, 0xcf, 0xcc, 0x30, 0xc1, 0x58, 0x80, 0x91, 0x37, 0x33, 0x0f, 0x41,
+		0xa8, 0xa8, 0xa8, 0xa8, 0x37, 0x33, 0x0f, 0xc1, 0xa8, 0x58, 0x80, 0xcf, 0xcc, 0x30, 0x41, 0x58,
+		0x80, 0x58, 0xe3, 0x84, 0xbc, 0xe7, 0x78, 0xe8, 0x7c, 0xe7, 0x88, 0xe9, 0x98, 0xe3, 0x80, 0x60,
+		0xe7, 0x78, 0xe9, 0x78, 0xe7, 0x88, 0xe9, 0x88, 0xe1,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox: lowlevel.Rectangle{
+			Min: f32.Vec2{-24, -24},
+			Max: f32.Vec2{+24, +24},
+		},
+		Palette: lowlevel.DefaultPalette,
+	},
+	Ops: func(dst lowlevel.Destination) {
+		// The SVG form's 11.05 and 8.95 are not exactly representable, as a
+		// float32 or as a 4 byte coordinate.
+		n1105, p1105 := bits(0xc130cccc), bits(0x4130cccc)
+		n895, p895 := bits(0xc10f3334), bits(0x410f3334)
+
+		dst.StartPath(0, 0, -20)
+		dst.AbsCubeTo(n1105, -20, -20, n1105, -20, 0)
+		dst.RelSmoothCubeTo(p895, 20, 20, 20)
+		dst.RelSmoothCubeTo(20, n895, 20, -20)
+		dst.AbsSmoothCubeTo(p1105, -20, 0, -20)
+		dst.ClosePathRelMoveTo(2, 30)
+		dst.RelHLineTo(-4)
+		dst.AbsVLineTo(-2)
+		dst.RelHLineTo(4)
+		dst.RelVLineTo(12)
+		dst.ClosePathRelMoveTo(0, -16)
+		dst.RelHLineTo(-4)
+		dst.RelVLineTo(-4)
+		dst.RelHLineTo(4)
+		dst.RelVLineTo(4)
+		dst.ClosePathEndPath()
+	},
+	Canonical: true,
+}
+
+// Vectors are the test vectors for the specification's worked examples, in
+// the order that the specification gives them, ending with Example.
+var Vectors = []*Vector{{
+	// The 1 byte color 0x30 is 40:FF:C0:FF, as 48 equals (1*25 + 4*5 + 3).
+	Name:     "color-1-byte",
+	Section:  "Colors",
+	Bytes:    graphic(0x00, 0x80, 0x30),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{0x40, 0xff, 0xc0, 0xff}))
+	},
+	Canonical: true,
+}, {
+	Name:     "color-2-byte",
+	Section:  "Colors",
+	Bytes:    graphic(0x00, 0x88, 0x38, 0x0f),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{0x33, 0x88, 0x00, 0xff}))
+	},
+	Canonical: true,
+}, {
+	Name:     "color-3-byte-direct",
+	Section:  "Colors",
+	Bytes:    graphic(0x00, 0x90, 0x30, 0x66, 0x07),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{0x30, 0x66, 0x07, 0xff}))
+	},
+	Canonical: true,
+}, {
+	Name:     "color-4-byte",
+	Section:  "Colors",
+	Bytes:    graphic(0x00, 0x98, 0x30, 0x66, 0x07, 0x80),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{0x30, 0x66, 0x07, 0x80}))
+	},
+	Canonical: true,
+}, {
+	// The suggested palette's third entry is a fully opaque orange, FF:80:00:FF,
+	// and the blend is 25% of the way from transparent black (0x7F) to that
+	// palette entry (0x82).
+	Name:    "color-3-byte-indirect",
+	Section: "Colors",
+	Bytes: graphic(
+		0x02, 0x0a, 0x02, 0x02, 0x00, 0x00, 0x6e,
+		0xa0, 0x40, 0x7f, 0x82,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: func() lowlevel.Palette {
+			p := lowlevel.DefaultPalette
+			p[2] = color.RGBA{0xff, 0x80, 0x00, 0xff}
+			return p
+		}(),
+	},
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetCReg(0, false, lowlevel.BlendColor(0x40, 0x7f, 0x82))
+	},
+	Canonical: true,
+}, {
+	// The natural number 0x28, or 20, is the length of a suggested palette
+	// metadata chunk: a 1 byte MID, a 1 byte palette format and six 3 byte
+	// colors.
+	Name:    "natural-1-byte",
+	Section: "Natural Numbers",
+	Bytes: graphic(
+		0x02, 0x28, 0x02, 0x85,
+		0x30, 0x66, 0x07, 0x31, 0x66, 0x07, 0x32, 0x66, 0x07,
+		0x33, 0x66, 0x07, 0x34, 0x66, 0x07, 0x35, 0x66, 0x07,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: func() lowlevel.Palette {
+			p := lowlevel.DefaultPalette
+			for i := 0; i < 6; i++ {
+				p[i] = color.RGBA{0x30 + uint8(i), 0x66, 0x07, 0xff}
+			}
+			return p
+		}(),
+	},
+	Ops:       func(dst lowlevel.Destination) {},
+	Canonical: true,
+}, {
+	// SetNReg chooses the shortest of the real, coordinate and zero-to-one
+	// encodings, preferring a real number if there is a tie.
+	Name:     "real-1-byte",
+	Section:  "Real Numbers",
+	Bytes:    graphic(0x00, 0xa8, 0x28),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetNReg(0, false, 20)
+	},
+	Canonical: true,
+}, {
+	Name:     "real-4-byte",
+	Section:  "Real Numbers",
+	Bytes:    graphic(0x00, 0xa8, 0x07, 0x00, 0x80, 0x3f),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetNReg(0, false, 1.000000476837158203125)
+	},
+	Canonical: true,
+}, {
+	Name:     "coordinate-1-byte",
+	Section:  "Coordinate Numbers",
+	Bytes:    graphic(0x00, 0xc0, 0x8e, 0x8e, 0xe1),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.StartPath(0, 7, 7)
+		dst.ClosePathEndPath()
+	},
+	Canonical: true,
+}, {
+	Name:     "coordinate-2-byte",
+	Section:  "Coordinate Numbers",
+	Bytes:    graphic(0x00, 0xc0, 0x81, 0x87, 0x81, 0x87, 0xe1),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.StartPath(0, 7.5, 7.5)
+		dst.ClosePathEndPath()
+	},
+	Canonical: true,
+}, {
+	// 7.5 "can also be encoded" in 4 bytes, but its shortest encoding is
+	// the 2 byte one.
+	Name:     "coordinate-4-byte",
+	Section:  "Coordinate Numbers",
+	Bytes:    graphic(0x00, 0xc0, 0x03, 0x00, 0xf0, 0x40, 0x81, 0x87, 0xe1),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.StartPath(0, 7.5, 7.5)
+		dst.ClosePathEndPath()
+	},
+	Canonical: false,
+}, {
+	// 15 degrees is 1/24 of a revolution.
+	Name:     "zero-to-one-1-byte",
+	Section:  "Zero-to-One Numbers",
+	Bytes:    graphic(0x00, 0xb8, 0x0a),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetNReg(0, false, 1.0/24)
+	},
+	Canonical: true,
+}, {
+	// 40 degrees is 1/9 of a revolution.
+	Name:     "zero-to-one-2-byte",
+	Section:  "Zero-to-One Numbers",
+	Bytes:    graphic(0x00, 0xb8, 0x41, 0x1a),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetNReg(0, false, 1.0/9)
+	},
+	Canonical: true,
+}, {
+	// 1 degree, or 1/360 of a revolution, is approximated. Its 4 byte real
+	// number encoding is no longer, and SetNReg prefers real numbers.
+	Name:     "zero-to-one-4-byte",
+	Section:  "Zero-to-One Numbers",
+	Bytes:    graphic(0x00, 0xb8, 0x63, 0x0b, 0x36, 0x3b),
+	Metadata: defaultMetadata,
+	Ops: func(dst lowlevel.Destination) {
+		dst.SetNReg(0, false, bits(0x3b360b60))
+	},
+	Canonical: false,
+}, {
+	// A vendor MID's chunk is preserved, not decoded. MID 1024 is the 2 byte
+	// natural number 0x1001.
+	Name:    "vendor-metadata",
+	Section: "Metadata",
+	Bytes: graphic(
+		0x02, 0x08, 0x01, 0x10, 0x61, 0x62,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: lowlevel.DefaultPalette,
+		Extra: map[lowlevel.MID][]byte{
+			1024: []byte("ab"),
+		},
+	},
+	Ops:       func(dst lowlevel.Destination) {},
+	Canonical: true,
+}, {
+	// The description "Détails" is 8 bytes long, as "é" is 2 bytes of UTF-8.
+	Name:    "title-and-description",
+	Section: "MID 2 - Title and Description",
+	Bytes: graphic(
+		0x02, 0x1e, 0x04,
+		0x08, 0x49, 0x6e, 0x66, 0x6f,
+		0x10, 0x44, 0xc3, 0xa9, 0x74, 0x61, 0x69, 0x6c, 0x73,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox:     lowlevel.DefaultViewBox,
+		Palette:     lowlevel.DefaultPalette,
+		Title:       "Info",
+		Description: "Détails",
+	},
+	Ops:       func(dst lowlevel.Destination) {},
+	Canonical: true,
+}, {
+	// The checksum covers the whole graphic, with its own 4 bytes as zero.
+	Name:    "checksum",
+	Section: "MID 3 - Checksum",
+	Bytes: graphic(
+		0x02, 0x0a, 0x06, 0xe2, 0x45, 0x76, 0x89,
+		0xc0, 0x8e, 0x8e, 0xe1,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox:  lowlevel.DefaultViewBox,
+		Palette:  lowlevel.DefaultPalette,
+		Checksum: true,
+	},
+	Ops: func(dst lowlevel.Destination) {
+		dst.StartPath(0, 7, 7)
+		dst.ClosePathEndPath()
+	},
+	Canonical: true,
+}, {
+	// Each level's end is an opcode offset, relative to the first opcode, in
+	// a 4 byte natural number. The first path is level 1 and the second is
+	// level 2.
+	Name:    "detail-levels",
+	Section: "MID 4 - Detail Levels",
+	Bytes: graphic(
+		0x02, 0x14, 0x08, 0x04,
+		0x13, 0x00, 0x00, 0x00,
+		0x23, 0x00, 0x00, 0x00,
+		0xc0, 0x8e, 0x8e, 0xe1,
+		0xc0, 0x90, 0x90, 0xe1,
+	),
+	Metadata: lowlevel.Metadata{
+		ViewBox:      lowlevel.DefaultViewBox,
+		Palette:      lowlevel.DefaultPalette,
+		DetailLevels: []uint32{4, 8},
+	},
+	Ops: func(dst lowlevel.Destination) {
+		d := dst.(lowlevel.DetailLevelDestination)
+		dst.StartPath(0, 7, 7)
+		dst.ClosePathEndPath()
+		d.EndDetailLevel()
+		dst.StartPath(0, 8, 8)
+		dst.ClosePathEndPath()
+		d.EndDetailLevel()
+	},
+	Canonical: true,
+}, &Example}
@@ -0,0 +1,240 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package font2ivg converts font glyphs to IconVG graphics.
+//
+// Icon fonts, such as Material Symbols, are a large source of icons. This
+// package extracts individual glyphs, by rune or by glyph ID, from TrueType
+// fonts (see Parse) and encodes each one as an IconVG graphic.
+//
+// It can also lay out short strings, such as badge labels like "4K" or "HD",
+// in a font loaded by the golang.org/x/image/font/sfnt package, and add them
+// to a graphic being built (see LayoutText).
+package font2ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Options are the optional parameters to Encode.
+type Options struct {
+	// ViewBox is the IconVG graphic's viewBox. The zero value means
+	// lowlevel.DefaultViewBox.
+	ViewBox lowlevel.Rectangle
+
+	// FitGlyph is whether the glyph's bounding box, instead of the em square,
+	// is fit to the ViewBox. Either way, the aspect ratio is preserved and the
+	// fitted square is centered.
+	//
+	// The em square is unitsPerEm font units wide and tall. Its top edge is
+	// the font's ascender line, so that, for icon fonts, whose glyphs usually
+	// fill the space between the baseline and the ascender, it is the icon's
+	// design grid.
+	FitGlyph bool
+
+	// Quantization, if positive, rounds every coordinate to a multiple of it,
+	// in ViewBox units. IconVG encodes multiples of 1/64 in at most 2 bytes,
+	// and integers from -64 to +63 in 1 byte, so that values such as 1/64 or
+	// 1 make for smaller files, at the cost of precision.
+	Quantization float32
+
+	// Fill is the glyph's paint. The zero value, which would be transparent,
+	// means lowlevel.PaletteIndexColor(0) instead, so that the color can be
+	// themed by a custom palette. The default palette's color 0 is opaque
+	// black.
+	Fill lowlevel.Color
+}
+
+// EncodeRune is like Encode but takes a rune instead of a glyph ID. It
+// encodes the .notdef glyph (glyph 0) if the font has no glyph for r.
+func EncodeRune(f *Font, r rune, opts *Options) ([]byte, error) {
+	return Encode(f, f.GlyphIndex(r), opts)
+}
+
+// Encode encodes the glyph with the given ID as an IconVG graphic.
+//
+// opts may be nil, which means to use the default options.
+func Encode(f *Font, glyph int, opts *Options) ([]byte, error) {
+	g, err := Graphic(f, glyph, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ivg.Encode(g)
+}
+
+// Graphic is like Encode but returns the unencoded graphic, for further
+// editing.
+func Graphic(f *Font, glyph int, opts *Options) (*ivg.Graphic, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	contours, err := f.contours(nil, glyph, [6]float32{1, 0, 0, 1, 0, 0}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	vb := opts.ViewBox
+	if vb == (lowlevel.Rectangle{}) {
+		vb = lowlevel.DefaultViewBox
+	}
+	t := newTransform(f, contours, vb, opts)
+
+	b := ivg.NewBuilder()
+	b.SetViewBox(vb.Min[0], vb.Min[1], vb.Max[0], vb.Max[1])
+	for _, c := range contours {
+		appendContour(b, c, t)
+	}
+	fill := opts.Fill
+	if fill == (lowlevel.Color{}) {
+		fill = lowlevel.PaletteIndexColor(0)
+	}
+	b.Fill(fill)
+	return b.Graphic(), nil
+}
+
+// transform maps from font units, with the y axis increasing upwards, to
+// ViewBox units, with the y axis increasing downwards.
+type transform struct {
+	scale        float32
+	dx, dy       float32
+	quantization float32
+}
+
+func newTransform(f *Font, contours [][]point, vb lowlevel.Rectangle, opts *Options) transform {
+	// The source square is [x0, x0+size] × [y1-size, y1], in font units.
+	x0, y1, size := float32(0), float32(f.ascender), float32(f.unitsPerEm)
+	if opts.FitGlyph {
+		minX, minY := float32(math.Inf(+1)), float32(math.Inf(+1))
+		maxX, maxY := float32(math.Inf(-1)), float32(math.Inf(-1))
+		for _, c := range contours {
+			for _, p := range c {
+				minX, maxX = min32(minX, p.x), max32(maxX, p.x)
+				minY, maxY = min32(minY, p.y), max32(maxY, p.y)
+			}
+		}
+		if minX <= maxX {
+			size = max32(maxX-minX, maxY-minY)
+			if size == 0 {
+				size = 1
+			}
+			x0 = (minX+maxX)/2 - size/2
+			y1 = (minY+maxY)/2 + size/2
+		}
+	}
+
+	w, h := vb.AspectRatio()
+	scale := min32(w, h) / size
+	return transform{
+		scale:        scale,
+		dx:           (vb.Min[0] + vb.Max[0] - size*scale) / 2,
+		dy:           (vb.Min[1] + vb.Max[1] - size*scale) / 2,
+		quantization: opts.Quantization,
+	}.withOrigin(x0, y1)
+}
+
+// withOrigin adjusts t so that the font unit point (x0, y1) maps to the
+// top-left corner of the fitted square.
+func (t transform) withOrigin(x0, y1 float32) transform {
+	t.dx -= x0 * t.scale
+	t.dy += y1 * t.scale
+	return t
+}
+
+func (t transform) apply(p point) (x, y float32) {
+	x = p.x*t.scale + t.dx
+	y = t.dy - p.y*t.scale
+	if q := t.quantization; q > 0 {
+		x = float32(math.Round(float64(x/q))) * q
+		y = float32(math.Round(float64(y/q))) * q
+	}
+	return x, y
+}
+
+// appendContour adds a TrueType contour to b. A contour is a closed loop of
+// on-curve and off-curve points. Two consecutive off-curve points have an
+// implied on-curve point midway between them, and each off-curve point is
+// the control point of a quadratic Bézier curve.
+func appendContour(b *ivg.Builder, c []point, t transform) {
+	if len(c) == 0 {
+		return
+	}
+
+	// Start at an on-curve point, rotating the contour if necessary. If there
+	// is none, start at the implied point between the first two points.
+	start := -1
+	for i, p := range c {
+		if p.onCurve {
+			start = i
+			break
+		}
+	}
+	first := point{}
+	if start >= 0 {
+		first = c[start]
+		c = append(c[start+1:len(c):len(c)], c[:start]...)
+	} else {
+		first = midpoint(c[len(c)-1], c[0])
+	}
+
+	x, y := t.apply(first)
+	b.MoveTo(x, y)
+	ctrl, hasCtrl := point{}, false
+	pts := append(c, first)
+	for i, p := range pts {
+		switch {
+		case p.onCurve && !hasCtrl:
+			if i == len(pts)-1 {
+				// The ClosePath draws this line.
+				break
+			}
+			x, y := t.apply(p)
+			b.LineTo(x, y)
+		case p.onCurve:
+			cx, cy := t.apply(ctrl)
+			x, y := t.apply(p)
+			b.QuadTo(cx, cy, x, y)
+			hasCtrl = false
+		case hasCtrl:
+			m := midpoint(ctrl, p)
+			cx, cy := t.apply(ctrl)
+			x, y := t.apply(m)
+			b.QuadTo(cx, cy, x, y)
+			ctrl = p
+		default:
+			ctrl, hasCtrl = p, true
+		}
+	}
+	b.ClosePath()
+}
+
+func midpoint(p, q point) point {
+	return point{x: (p.x + q.x) / 2, y: (p.y + q.y) / 2, onCurve: true}
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
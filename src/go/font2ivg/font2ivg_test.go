@@ -0,0 +1,124 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package font2ivg_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/font2ivg"
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg/ivgtest"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestParse(t *testing.T) {
+	f, err := font2ivg.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.UnitsPerEm(); got != 2048 {
+		t.Errorf("UnitsPerEm: got %d, want %d", got, 2048)
+	}
+	if got := f.NumGlyphs(); got <= 0 {
+		t.Errorf("NumGlyphs: got %d, want > 0", got)
+	}
+	if got := f.GlyphIndex('A'); got == 0 {
+		t.Errorf("GlyphIndex('A'): got %d, want non-zero", got)
+	}
+	if got := f.GlyphIndex('\U000f0000'); got != 0 {
+		t.Errorf("GlyphIndex(U+F0000): got %d, want %d", got, 0)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"short", []byte("\x00\x01\x00\x00")},
+		{"truncated", goregular.TTF[:64]},
+	}
+	for _, tc := range testCases {
+		if _, err := font2ivg.Parse(tc.src); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestEncodeRune(t *testing.T) {
+	f, err := font2ivg.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	testCases := []struct {
+		desc string
+		r    rune
+		opts *font2ivg.Options
+	}{
+		{"nil options", 'A', nil},
+		{"FitGlyph", 'o', &font2ivg.Options{FitGlyph: true}},
+		{"Quantization", 'g', &font2ivg.Options{Quantization: 1.0 / 64}},
+		{"ViewBox", 'B', &font2ivg.Options{ViewBox: lowlevel.Rectangle{Max: [2]float32{48, 48}}}},
+		{"Fill", 'x', &font2ivg.Options{Fill: red}},
+	}
+	for _, tc := range testCases {
+		src, err := font2ivg.EncodeRune(f, tc.r, tc.opts)
+		if err != nil {
+			t.Errorf("%s: EncodeRune: %v", tc.desc, err)
+			continue
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc.desc, err)
+			continue
+		}
+		if err := ivgtest.CheckRoundTrip(g); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		}
+		m, err := render.Image(src, 32, nil)
+		if err != nil {
+			t.Errorf("%s: render.Image: %v", tc.desc, err)
+			continue
+		}
+		opaque := 0
+		for i := 3; i < len(m.Pix); i += 4 {
+			if m.Pix[i] == 0xff {
+				opaque++
+			}
+		}
+		if opaque == 0 {
+			t.Errorf("%s: got no opaque pixels, want some", tc.desc)
+		}
+	}
+}
+
+func TestEncodeInvalidGlyph(t *testing.T) {
+	f, err := font2ivg.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An out-of-range glyph ID is either rejected or treated as .notdef, but
+	// it must not panic.
+	for _, glyph := range []int{-1, f.NumGlyphs(), 1 << 30} {
+		if src, err := font2ivg.Encode(f, glyph, nil); err == nil && len(src) == 0 {
+			t.Errorf("glyph %d: got empty output and nil error", glyph)
+		}
+	}
+}
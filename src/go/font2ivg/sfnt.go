@@ -0,0 +1,454 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package font2ivg
+
+import (
+	"errors"
+)
+
+var (
+	errCFFUnsupported        = errors.New("font2ivg: CFF (PostScript) outlines are not supported")
+	errCollectionUnsupported = errors.New("font2ivg: font collections are not supported")
+	errInvalidFont           = errors.New("font2ivg: invalid font")
+	errInvalidGlyph          = errors.New("font2ivg: invalid glyph")
+	errMissingTable          = errors.New("font2ivg: missing table")
+	errNoCmap                = errors.New("font2ivg: no supported character map")
+	errTooDeeplyNested       = errors.New("font2ivg: composite glyph is too deeply nested")
+)
+
+// maxCompoundDepth bounds the recursion when decoding composite glyphs, so
+// that a malicious font cannot loop forever.
+const maxCompoundDepth = 8
+
+// Font is a parsed TrueType font: an SFNT font with quadratic ("glyf" table)
+// outlines. This includes most .ttf files and those .otf files that do not
+// use CFF outlines.
+type Font struct {
+	unitsPerEm int
+	ascender   int
+	numGlyphs  int
+	longLoca   bool
+	loca       []byte
+	glyf       []byte
+	cmap       []byte
+	cmapFormat int
+}
+
+// Parse parses a TrueType font. The Font refers to, and so src must not be
+// modified while the Font is in use.
+func Parse(src []byte) (*Font, error) {
+	if len(src) < 12 {
+		return nil, errInvalidFont
+	}
+	switch string(src[:4]) {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return nil, errCFFUnsupported
+	case "ttcf":
+		return nil, errCollectionUnsupported
+	default:
+		return nil, errInvalidFont
+	}
+
+	tables := map[string][]byte{}
+	numTables := int(u16(src, 4))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(src) {
+			return nil, errInvalidFont
+		}
+		offset, length := u32(src, rec+8), u32(src, rec+12)
+		if (uint64(offset) + uint64(length)) > uint64(len(src)) {
+			return nil, errInvalidFont
+		}
+		tables[string(src[rec:rec+4])] = src[offset : offset+length]
+	}
+	for _, tag := range [...]string{"cmap", "glyf", "head", "hhea", "loca", "maxp"} {
+		if tables[tag] == nil {
+			return nil, errMissingTable
+		}
+	}
+
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if (len(head) < 54) || (len(hhea) < 6) || (len(maxp) < 6) {
+		return nil, errInvalidFont
+	}
+	f := &Font{
+		unitsPerEm: int(u16(head, 18)),
+		ascender:   int(int16(u16(hhea, 4))),
+		numGlyphs:  int(u16(maxp, 4)),
+		longLoca:   u16(head, 50) != 0,
+		loca:       tables["loca"],
+		glyf:       tables["glyf"],
+	}
+	if (f.unitsPerEm == 0) || (len(f.loca) < f.locaEntrySize()*(f.numGlyphs+1)) {
+		return nil, errInvalidFont
+	}
+	if err := f.parseCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// NumGlyphs returns the number of glyphs in the font. Valid glyph IDs range
+// from 0 to NumGlyphs() - 1.
+func (f *Font) NumGlyphs() int { return f.numGlyphs }
+
+// UnitsPerEm returns the size of the em square, in font units.
+func (f *Font) UnitsPerEm() int { return f.unitsPerEm }
+
+func (f *Font) locaEntrySize() int {
+	if f.longLoca {
+		return 4
+	}
+	return 2
+}
+
+// parseCmap selects a Unicode character map: format 12 (the full Unicode
+// range) if there is one, otherwise format 4 (the Basic Multilingual Plane).
+func (f *Font) parseCmap(cmap []byte) error {
+	if len(cmap) < 4 {
+		return errInvalidFont
+	}
+	numSubtables := int(u16(cmap, 2))
+	for i := 0; i < numSubtables; i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			return errInvalidFont
+		}
+		platformID, encodingID, offset := u16(cmap, rec), u16(cmap, rec+2), u32(cmap, rec+4)
+		unicode := (platformID == 0) ||
+			((platformID == 3) && ((encodingID == 1) || (encodingID == 10)))
+		if !unicode || (uint64(offset)+4 > uint64(len(cmap))) {
+			continue
+		}
+		sub := cmap[offset:]
+		switch format := int(u16(sub, 0)); format {
+		case 4:
+			if (f.cmapFormat == 0) && (len(sub) >= 14) {
+				f.cmap, f.cmapFormat = sub, format
+			}
+		case 12:
+			if len(sub) >= 16 {
+				f.cmap, f.cmapFormat = sub, format
+			}
+		}
+	}
+	if f.cmapFormat == 0 {
+		return errNoCmap
+	}
+	return nil
+}
+
+// GlyphIndex returns the glyph ID for the rune r, or 0 (the .notdef glyph) if
+// the font has no glyph for r.
+func (f *Font) GlyphIndex(r rune) int {
+	if f.cmapFormat == 12 {
+		nGroups := int(u32(f.cmap, 12))
+		for i := 0; i < nGroups; i++ {
+			g := 16 + 12*i
+			if g+12 > len(f.cmap) {
+				break
+			}
+			start, end, glyph := u32(f.cmap, g), u32(f.cmap, g+4), u32(f.cmap, g+8)
+			if (start <= uint32(r)) && (uint32(r) <= end) {
+				return f.validGlyph(int(glyph + uint32(r) - start))
+			}
+		}
+		return 0
+	}
+
+	// Format 4 has parallel arrays of segCount endCodes, startCodes, idDeltas
+	// and idRangeOffsets, with a reservedPad between the first two.
+	if (r < 0) || (r > 0xffff) {
+		return 0
+	}
+	c := int(r)
+	segCount := int(u16(f.cmap, 6)) / 2
+	endCodes := 14
+	startCodes := endCodes + 2*segCount + 2
+	idDeltas := startCodes + 2*segCount
+	idRangeOffsets := idDeltas + 2*segCount
+	if idRangeOffsets+2*segCount > len(f.cmap) {
+		return 0
+	}
+	for i := 0; i < segCount; i++ {
+		if c > int(u16(f.cmap, endCodes+2*i)) {
+			continue
+		}
+		start := int(u16(f.cmap, startCodes+2*i))
+		if c < start {
+			return 0
+		}
+		delta := int(u16(f.cmap, idDeltas+2*i))
+		rangeOffset := int(u16(f.cmap, idRangeOffsets+2*i))
+		if rangeOffset == 0 {
+			return f.validGlyph((c + delta) & 0xffff)
+		}
+		// The idRangeOffset is relative to its own position.
+		o := idRangeOffsets + 2*i + rangeOffset + 2*(c-start)
+		if o+2 > len(f.cmap) {
+			return 0
+		}
+		if glyph := int(u16(f.cmap, o)); glyph != 0 {
+			return f.validGlyph((glyph + delta) & 0xffff)
+		}
+		return 0
+	}
+	return 0
+}
+
+func (f *Font) validGlyph(glyph int) int {
+	if (glyph < 0) || (glyph >= f.numGlyphs) {
+		return 0
+	}
+	return glyph
+}
+
+// glyphData returns the "glyf" table data for a glyph. It is empty for glyphs
+// with no outline, such as a space.
+func (f *Font) glyphData(glyph int) ([]byte, error) {
+	if (glyph < 0) || (glyph >= f.numGlyphs) {
+		return nil, errInvalidGlyph
+	}
+	start, end := 0, 0
+	if f.longLoca {
+		start, end = int(u32(f.loca, 4*glyph)), int(u32(f.loca, 4*glyph+4))
+	} else {
+		start, end = 2*int(u16(f.loca, 2*glyph)), 2*int(u16(f.loca, 2*glyph+2))
+	}
+	if (start > end) || (end > len(f.glyf)) {
+		return nil, errInvalidGlyph
+	}
+	return f.glyf[start:end], nil
+}
+
+// point is a point of a glyph outline, in font units, with the y axis
+// increasing upwards.
+type point struct {
+	x, y    float32
+	onCurve bool
+}
+
+// contours returns a glyph's outline as a list of closed contours. xf is the
+// transformation (a, b, c, d, dx, dy) mapping (x, y) to (a*x + c*y + dx,
+// b*x + d*y + dy) that is applied to composite glyphs' components.
+func (f *Font) contours(dst [][]point, glyph int, xf [6]float32, depth int) ([][]point, error) {
+	if depth > maxCompoundDepth {
+		return nil, errTooDeeplyNested
+	}
+	data, err := f.glyphData(glyph)
+	if err != nil {
+		return nil, err
+	} else if len(data) == 0 {
+		return dst, nil
+	} else if len(data) < 10 {
+		return nil, errInvalidGlyph
+	}
+	if numContours := int16(u16(data, 0)); numContours >= 0 {
+		return appendSimpleContours(dst, data, int(numContours), &xf)
+	}
+	return f.appendCompositeContours(dst, data, xf, depth)
+}
+
+func appendSimpleContours(dst [][]point, data []byte, numContours int, xf *[6]float32) ([][]point, error) {
+	const (
+		flagOnCurve     = 0x01
+		flagXShort      = 0x02
+		flagYShort      = 0x04
+		flagRepeat      = 0x08
+		flagXSameOrPlus = 0x10
+		flagYSameOrPlus = 0x20
+	)
+
+	p := 10
+	if p+2*numContours+2 > len(data) {
+		return nil, errInvalidGlyph
+	}
+	endPts := make([]int, numContours)
+	numPoints := 0
+	for i := range endPts {
+		endPts[i] = int(u16(data, p))
+		p += 2
+		if endPts[i] < numPoints {
+			return nil, errInvalidGlyph
+		}
+		numPoints = endPts[i] + 1
+	}
+	instructionLength := int(u16(data, p))
+	p += 2 + instructionLength
+
+	// Decode the flags, then the x coordinates, then the y coordinates.
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		if p >= len(data) {
+			return nil, errInvalidGlyph
+		}
+		flag := data[p]
+		p++
+		flags = append(flags, flag)
+		if flag&flagRepeat != 0 {
+			if p >= len(data) {
+				return nil, errInvalidGlyph
+			}
+			for n := int(data[p]); (n > 0) && (len(flags) < numPoints); n-- {
+				flags = append(flags, flag)
+			}
+			p++
+		}
+	}
+	xs := make([]int, numPoints)
+	ys := make([]int, numPoints)
+	for pass, coords := range [2][]int{xs, ys} {
+		short, sameOrPlus := byte(flagXShort), byte(flagXSameOrPlus)
+		if pass == 1 {
+			short, sameOrPlus = flagYShort, flagYSameOrPlus
+		}
+		v := 0
+		for i, flag := range flags {
+			if flag&short != 0 {
+				if p+1 > len(data) {
+					return nil, errInvalidGlyph
+				}
+				if flag&sameOrPlus != 0 {
+					v += int(data[p])
+				} else {
+					v -= int(data[p])
+				}
+				p++
+			} else if flag&sameOrPlus == 0 {
+				if p+2 > len(data) {
+					return nil, errInvalidGlyph
+				}
+				v += int(int16(u16(data, p)))
+				p += 2
+			}
+			coords[i] = v
+		}
+	}
+
+	start := 0
+	for _, end := range endPts {
+		contour := make([]point, 0, end+1-start)
+		for i := start; i <= end; i++ {
+			x, y := float32(xs[i]), float32(ys[i])
+			contour = append(contour, point{
+				x:       xf[0]*x + xf[2]*y + xf[4],
+				y:       xf[1]*x + xf[3]*y + xf[5],
+				onCurve: flags[i]&flagOnCurve != 0,
+			})
+		}
+		dst = append(dst, contour)
+		start = end + 1
+	}
+	return dst, nil
+}
+
+func (f *Font) appendCompositeContours(dst [][]point, data []byte, xf [6]float32, depth int) ([][]point, error) {
+	const (
+		flagArg1And2AreWords   = 0x0001
+		flagArgsAreXYValues    = 0x0002
+		flagWeHaveAScale       = 0x0008
+		flagMoreComponents     = 0x0020
+		flagWeHaveAnXAndYScale = 0x0040
+		flagWeHaveATwoByTwo    = 0x0080
+	)
+
+	for p := 10; ; {
+		if p+4 > len(data) {
+			return nil, errInvalidGlyph
+		}
+		flags, glyph := u16(data, p), int(u16(data, p+2))
+		p += 4
+
+		dx, dy := 0, 0
+		if flags&flagArg1And2AreWords != 0 {
+			if p+4 > len(data) {
+				return nil, errInvalidGlyph
+			}
+			dx, dy = int(int16(u16(data, p))), int(int16(u16(data, p+2)))
+			p += 4
+		} else {
+			if p+2 > len(data) {
+				return nil, errInvalidGlyph
+			}
+			dx, dy = int(int8(data[p])), int(int8(data[p+1]))
+			p += 2
+		}
+		if flags&flagArgsAreXYValues == 0 {
+			// The arguments are point numbers, for aligning the component
+			// with a point of the glyph so far. This is rare and is not
+			// supported, so place the component without an offset.
+			dx, dy = 0, 0
+		}
+
+		// The component transformation's matrix is in 2.14 fixed point.
+		a, b, c, d := float32(1), float32(0), float32(0), float32(1)
+		n := 0
+		switch {
+		case flags&flagWeHaveAScale != 0:
+			n = 1
+		case flags&flagWeHaveAnXAndYScale != 0:
+			n = 2
+		case flags&flagWeHaveATwoByTwo != 0:
+			n = 4
+		}
+		if p+2*n > len(data) {
+			return nil, errInvalidGlyph
+		}
+		f2dot14 := func(i int) float32 { return float32(int16(u16(data, p+2*i))) / (1 << 14) }
+		switch n {
+		case 1:
+			a = f2dot14(0)
+			d = a
+		case 2:
+			a, d = f2dot14(0), f2dot14(1)
+		case 4:
+			a, b, c, d = f2dot14(0), f2dot14(1), f2dot14(2), f2dot14(3)
+		}
+		p += 2 * n
+
+		// Compose the component's transformation with xf.
+		cxf := [6]float32{
+			xf[0]*a + xf[2]*b,
+			xf[1]*a + xf[3]*b,
+			xf[0]*c + xf[2]*d,
+			xf[1]*c + xf[3]*d,
+			xf[0]*float32(dx) + xf[2]*float32(dy) + xf[4],
+			xf[1]*float32(dx) + xf[3]*float32(dy) + xf[5],
+		}
+		err := error(nil)
+		if dst, err = f.contours(dst, glyph, cxf, depth+1); err != nil {
+			return nil, err
+		}
+		if flags&flagMoreComponents == 0 {
+			return dst, nil
+		}
+	}
+}
+
+func u16(b []byte, i int) uint16 {
+	if (i < 0) || (i+2 > len(b)) {
+		return 0
+	}
+	return uint16(b[i])<<8 | uint16(b[i+1])
+}
+
+func u32(b []byte, i int) uint32 {
+	if (i < 0) || (i+4 > len(b)) {
+		return 0
+	}
+	return uint32(b[i])<<24 | uint32(b[i+1])<<16 | uint32(b[i+2])<<8 | uint32(b[i+3])
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package font2ivg
+
+import (
+	"errors"
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/fixed"
+)
+
+var errInvalidTextSize = errors.New("font2ivg: invalid text size")
+
+// Alignment is how Text is positioned horizontally relative to its origin.
+type Alignment uint8
+
+const (
+	// AlignLeft puts the origin at the start of the text.
+	AlignLeft Alignment = 0
+	// AlignCenter puts the origin at the middle of the text's advance.
+	AlignCenter Alignment = 1
+	// AlignRight puts the origin at the end of the text.
+	AlignRight Alignment = 2
+)
+
+// TextOptions are the optional parameters to LayoutText.
+type TextOptions struct {
+	// Size is the font size: the height of the em square, in graphic
+	// coordinates. Zero means 16.
+	Size float32
+
+	// Align is how the text is positioned relative to its origin.
+	Align Alignment
+
+	// Tracking is extra space, in graphic coordinates, added between
+	// consecutive glyphs. It can be negative. Badge-style labels, such as
+	// "HD", often use a little positive tracking.
+	Tracking float32
+
+	// NoKerning disables the font's kerning, which adjusts the space between
+	// particular pairs of glyphs, such as "AV".
+	NoKerning bool
+}
+
+// Text is a string laid out as glyph outlines, ready to be added to a
+// Builder. Its coordinates are relative to its origin, which is on the
+// baseline, and the y axis increases downwards.
+type Text struct {
+	// Path is the glyphs' outlines, which are filled with the non-zero rule.
+	Path ivg.Path
+
+	// Advance is the text's advance width: the horizontal distance from its
+	// start to its end. The text starts at x = 0, -Advance/2 or -Advance, for
+	// AlignLeft, AlignCenter or AlignRight.
+	Advance float32
+
+	// Bounds is the bounding box of the outlines' points, including their
+	// control points, which is the ink's bounding box or slightly larger. It
+	// is the zero Rectangle if the text draws nothing, such as for spaces.
+	Bounds lowlevel.Rectangle
+}
+
+// LayoutText lays out the string s, in the font f, on a single line. Shaping
+// is simple, which suits Latin text: each rune maps to its own glyph (or the
+// font's .notdef glyph, if it has none), glyphs are spaced by their advance
+// widths and by the font's "kern" table, and there are no ligatures or
+// glyph substitutions.
+//
+// opts may be nil, which means to use the default options.
+//
+// For example, to center a badge label in a rectangle centered on (cx, cy):
+//
+//	t, err := font2ivg.LayoutText(f, "4K", &font2ivg.TextOptions{
+//		Size:  24,
+//		Align: font2ivg.AlignCenter,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	t.AppendTo(b, cx, cy-(t.Bounds.Min[1]+t.Bounds.Max[1])/2)
+//	b.Fill(lowlevel.PaletteIndexColor(1))
+func LayoutText(f *sfnt.Font, s string, opts *TextOptions) (*Text, error) {
+	if opts == nil {
+		opts = &TextOptions{}
+	}
+	size := opts.Size
+	if size == 0 {
+		size = 16
+	} else if !(size > 0) || math.IsInf(float64(size), 0) {
+		return nil, errInvalidTextSize
+	}
+
+	// Load the glyphs at a ppem of unitsPerEm, so that their coordinates are
+	// in font units (as 26.6 fixed point numbers), then scale to the size.
+	var buf sfnt.Buffer
+	upem := fixed.Int26_6(f.UnitsPerEm()) << 6
+	scale := size / float32(upem)
+
+	t := &Text{}
+	x, prev := float32(0), sfnt.GlyphIndex(0)
+	for i, r := range s {
+		g, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			x += opts.Tracking
+			if !opts.NoKerning {
+				// Fonts without a kern table, or whose kerning is in GPOS
+				// instead, give an error, which means no kerning.
+				if k, err := f.Kern(&buf, prev, g, upem, font.HintingNone); err == nil {
+					x += float32(k) * scale
+				}
+			}
+		}
+		segs, err := f.LoadGlyph(&buf, g, upem, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Path = appendSegments(t.Path, segs, x, scale)
+		adv, err := f.GlyphAdvance(&buf, g, upem, font.HintingNone)
+		if err != nil {
+			return nil, err
+		}
+		x += float32(adv) * scale
+		prev = g
+	}
+
+	t.Advance = x
+	if dx := -x * float32(opts.Align) / 2; dx != 0 {
+		for i, seg := range t.Path {
+			t.Path[i] = translateSegment(seg, dx)
+		}
+	}
+	t.Bounds = pathBounds(t.Path)
+	return t, nil
+}
+
+// AppendTo adds the text, with its origin at (x, y), to b's current path.
+// Like the Builder's other path methods, it does not fill the path.
+func (t *Text) AppendTo(b *ivg.Builder, x, y float32) {
+	at := func(v f32.Vec2) (float32, float32) { return v[0] + x, v[1] + y }
+	for _, seg := range t.Path {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			b.MoveTo(at(seg.To))
+		case ivg.LineTo:
+			b.LineTo(at(seg.To))
+		case ivg.QuadTo:
+			cx, cy := at(seg.Ctrl)
+			px, py := at(seg.To)
+			b.QuadTo(cx, cy, px, py)
+		case ivg.CubeTo:
+			c0x, c0y := at(seg.Ctrl0)
+			c1x, c1y := at(seg.Ctrl1)
+			px, py := at(seg.To)
+			b.CubeTo(c0x, c0y, c1x, c1y, px, py)
+		case ivg.ClosePath:
+			b.ClosePath()
+		}
+	}
+}
+
+// appendSegments appends a glyph's outline, whose coordinates are 26.6 fixed
+// point numbers with the y axis increasing downwards, scaled by scale and
+// translated by dx. sfnt's contours are implicitly closed, so each one is
+// explicitly closed before the next starts.
+func appendSegments(p ivg.Path, segs sfnt.Segments, dx, scale float32) ivg.Path {
+	pt := func(q fixed.Point26_6) f32.Vec2 {
+		return f32.Vec2{float32(q.X)*scale + dx, float32(q.Y) * scale}
+	}
+	open := false
+	for _, s := range segs {
+		a := &s.Args
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			if open {
+				p = append(p, ivg.ClosePath{})
+			}
+			p = append(p, ivg.MoveTo{To: pt(a[0])})
+			open = true
+		case sfnt.SegmentOpLineTo:
+			p = append(p, ivg.LineTo{To: pt(a[0])})
+		case sfnt.SegmentOpQuadTo:
+			p = append(p, ivg.QuadTo{Ctrl: pt(a[0]), To: pt(a[1])})
+		case sfnt.SegmentOpCubeTo:
+			p = append(p, ivg.CubeTo{Ctrl0: pt(a[0]), Ctrl1: pt(a[1]), To: pt(a[2])})
+		}
+	}
+	if open {
+		p = append(p, ivg.ClosePath{})
+	}
+	return p
+}
+
+func translateSegment(seg ivg.Segment, dx float32) ivg.Segment {
+	tx := func(v f32.Vec2) f32.Vec2 { return f32.Vec2{v[0] + dx, v[1]} }
+	switch seg := seg.(type) {
+	case ivg.MoveTo:
+		return ivg.MoveTo{To: tx(seg.To)}
+	case ivg.LineTo:
+		return ivg.LineTo{To: tx(seg.To)}
+	case ivg.QuadTo:
+		return ivg.QuadTo{Ctrl: tx(seg.Ctrl), To: tx(seg.To)}
+	case ivg.CubeTo:
+		return ivg.CubeTo{Ctrl0: tx(seg.Ctrl0), Ctrl1: tx(seg.Ctrl1), To: tx(seg.To)}
+	}
+	return seg
+}
+
+// pathBounds returns the bounding box of p's points, including control
+// points.
+func pathBounds(p ivg.Path) lowlevel.Rectangle {
+	r, first := lowlevel.Rectangle{}, true
+	add := func(v f32.Vec2) {
+		if first {
+			r.Min, r.Max, first = v, v, false
+			return
+		}
+		for i := 0; i < 2; i++ {
+			if r.Min[i] > v[i] {
+				r.Min[i] = v[i]
+			}
+			if r.Max[i] < v[i] {
+				r.Max[i] = v[i]
+			}
+		}
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			add(seg.To)
+		case ivg.LineTo:
+			add(seg.To)
+		case ivg.QuadTo:
+			add(seg.Ctrl)
+			add(seg.To)
+		case ivg.CubeTo:
+			add(seg.Ctrl0)
+			add(seg.Ctrl1)
+			add(seg.To)
+		}
+	}
+	return r
+}
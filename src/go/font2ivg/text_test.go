@@ -0,0 +1,221 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package font2ivg_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/font2ivg"
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/f32"
+)
+
+func layout(t *testing.T, f *sfnt.Font, s string, opts *font2ivg.TextOptions) *font2ivg.Text {
+	t.Helper()
+	text, err := font2ivg.LayoutText(f, s, opts)
+	if err != nil {
+		t.Fatalf("LayoutText(%q): %v", s, err)
+	}
+	return text
+}
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-3
+}
+
+func TestLayoutText(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		s         string
+		wantPath  bool
+		wantEmpty bool
+	}{
+		{"", false, true},
+		{" ", false, false},
+		{"  ", false, false},
+		{"HD", true, false},
+		{"4K", true, false},
+		{"a b", true, false},
+	}
+	for _, tc := range testCases {
+		text := layout(t, f, tc.s, nil)
+		if got := len(text.Path) > 0; got != tc.wantPath {
+			t.Errorf("%q: got path %t, want %t", tc.s, got, tc.wantPath)
+		}
+		if got := text.Advance == 0; got != tc.wantEmpty {
+			t.Errorf("%q: got zero Advance %t, want %t", tc.s, got, tc.wantEmpty)
+		}
+		if !tc.wantPath && (text.Bounds != lowlevel.Rectangle{}) {
+			t.Errorf("%q: Bounds: got %v, want the zero Rectangle", tc.s, text.Bounds)
+		}
+		if tc.wantPath {
+			// The outlines are above the baseline (y < 0) and within the
+			// advance, give or take a little side bearing.
+			b := text.Bounds
+			if !(b.Min[1] < 0) || !(b.Max[1] <= 1) {
+				t.Errorf("%q: Bounds: got %v, want ink above the baseline", tc.s, b)
+			}
+			if !(b.Min[0] >= -1) || !(b.Max[0] <= text.Advance+1) {
+				t.Errorf("%q: Bounds: got %v, want within the Advance %v", tc.s, b, text.Advance)
+			}
+			if _, ok := text.Path[len(text.Path)-1].(ivg.ClosePath); !ok {
+				t.Errorf("%q: got an unclosed path", tc.s)
+			}
+		}
+	}
+}
+
+func TestLayoutTextOptions(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := layout(t, f, "HD", &font2ivg.TextOptions{NoKerning: true})
+	testCases := []struct {
+		desc        string
+		opts        font2ivg.TextOptions
+		wantAdvance float32
+		wantMinX    float32
+	}{
+		{"default", font2ivg.TextOptions{NoKerning: true}, base.Advance, base.Bounds.Min[0]},
+		{"size 16", font2ivg.TextOptions{Size: 16, NoKerning: true}, base.Advance, base.Bounds.Min[0]},
+		{"size 32", font2ivg.TextOptions{Size: 32, NoKerning: true}, 2 * base.Advance, 2 * base.Bounds.Min[0]},
+		{"tracking", font2ivg.TextOptions{Tracking: 3, NoKerning: true}, base.Advance + 3, base.Bounds.Min[0]},
+		{"negative tracking", font2ivg.TextOptions{Tracking: -1, NoKerning: true}, base.Advance - 1, base.Bounds.Min[0]},
+		{"center", font2ivg.TextOptions{Align: font2ivg.AlignCenter, NoKerning: true},
+			base.Advance, base.Bounds.Min[0] - base.Advance/2},
+		{"right", font2ivg.TextOptions{Align: font2ivg.AlignRight, NoKerning: true},
+			base.Advance, base.Bounds.Min[0] - base.Advance},
+	}
+	for _, tc := range testCases {
+		text := layout(t, f, "HD", &tc.opts)
+		if !near(text.Advance, tc.wantAdvance) {
+			t.Errorf("%s: Advance: got %v, want %v", tc.desc, text.Advance, tc.wantAdvance)
+		}
+		if !near(text.Bounds.Min[0], tc.wantMinX) {
+			t.Errorf("%s: Bounds.Min[0]: got %v, want %v", tc.desc, text.Bounds.Min[0], tc.wantMinX)
+		}
+	}
+}
+
+func TestLayoutTextKerning(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Laying out a string without kerning is the same as laying out its
+	// runes one after another.
+	for _, s := range []string{"AV", "To", "HD"} {
+		sum := float32(0)
+		for _, r := range s {
+			sum += layout(t, f, string(r), &font2ivg.TextOptions{NoKerning: true}).Advance
+		}
+		unkerned := layout(t, f, s, &font2ivg.TextOptions{NoKerning: true}).Advance
+		if !near(unkerned, sum) {
+			t.Errorf("%q: NoKerning Advance: got %v, want %v", s, unkerned, sum)
+		}
+		// Kerning pairs like "AV" only ever tighten the text.
+		if kerned := layout(t, f, s, nil).Advance; kerned > unkerned {
+			t.Errorf("%q: kerned Advance: got %v, want at most %v", s, kerned, unkerned)
+		}
+	}
+}
+
+func TestLayoutTextErrors(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []float32{
+		-1,
+		float32(math.NaN()),
+		float32(math.Inf(+1)),
+		float32(math.Inf(-1)),
+	}
+	for _, tc := range testCases {
+		if _, err := font2ivg.LayoutText(f, "HD", &font2ivg.TextOptions{Size: tc}); err == nil {
+			t.Errorf("Size %v: got nil error, want non-nil", tc)
+		}
+	}
+}
+
+func TestTextAppendTo(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := layout(t, f, "4K", &font2ivg.TextOptions{Size: 24, Align: font2ivg.AlignCenter})
+	cx, cy := float32(0), float32(0)
+	y := cy - (text.Bounds.Min[1]+text.Bounds.Max[1])/2
+	b := ivg.NewBuilder().
+		MoveTo(-30, -20).LineTo(+30, -20).LineTo(+30, +20).LineTo(-30, +20).ClosePath().
+		Fill(lowlevel.PaletteIndexColor(0))
+	text.AppendTo(b, cx, y)
+	b.Fill(lowlevel.PaletteIndexColor(1))
+	g := b.Graphic()
+	if len(g.Shapes) != 2 {
+		t.Fatalf("got %d shapes, want 2", len(g.Shapes))
+	}
+
+	got := g.Shapes[1].Path
+	if len(got) != len(text.Path) {
+		t.Fatalf("got %d segments, want %d", len(got), len(text.Path))
+	}
+	d := f32.Vec2{cx, y}
+	at := func(v f32.Vec2) f32.Vec2 { return f32.Vec2{v[0] + d[0], v[1] + d[1]} }
+	for i, seg := range text.Path {
+		var want ivg.Segment
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			want = ivg.MoveTo{To: at(seg.To)}
+		case ivg.LineTo:
+			want = ivg.LineTo{To: at(seg.To)}
+		case ivg.QuadTo:
+			want = ivg.QuadTo{Ctrl: at(seg.Ctrl), To: at(seg.To)}
+		case ivg.CubeTo:
+			want = ivg.CubeTo{Ctrl0: at(seg.Ctrl0), Ctrl1: at(seg.Ctrl1), To: at(seg.To)}
+		default:
+			want = seg
+		}
+		if got[i] != want {
+			t.Errorf("segment #%d: got %v, want %v", i, got[i], want)
+		}
+	}
+
+	src, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if _, err := render.Image(src, 64, nil); err != nil {
+		t.Fatalf("render.Image: %v", err)
+	}
+
+	// The label is centered on the badge.
+	mid := (text.Bounds.Min[1] + text.Bounds.Max[1]) / 2
+	if !near(mid+y, cy) {
+		t.Errorf("vertical center: got %v, want %v", mid+y, cy)
+	}
+	if mid := (text.Bounds.Min[0] + text.Bounds.Max[0]) / 2; math.Abs(float64(mid-cx)) > 2 {
+		t.Errorf("horizontal center: got %v, want near %v", mid, cx)
+	}
+}
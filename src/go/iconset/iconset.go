@@ -0,0 +1,217 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iconset is a registry of named IconVG icons, such as those embedded
+// in a program with a go:embed directive:
+//
+//	//go:embed icons
+//	var iconFS embed.FS
+//
+//	var icons, _ = iconset.New(iconFS)
+//
+//	m, err := icons.Image("icons/action/search", 48)
+//
+// Each ".ivg" file in the file system is an icon, named by its slash-separated
+// path without the ".ivg" extension. Files are read and decoded when first
+// used, and rasterizations are cached per size. A palette can override every
+// icon's suggested palette, to theme a whole icon set at once.
+package iconset
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+var errUnknownIcon = errors.New("iconset: unknown icon")
+
+// ext is the file name extension of IconVG files.
+const ext = ".ivg"
+
+// Set is a registry of named IconVG icons.
+//
+// A Set is safe for concurrent use by multiple goroutines.
+type Set struct {
+	fsys  fs.FS
+	names []string
+
+	mu      sync.Mutex
+	icons   map[string]*icon
+	palette map[uint8]color.RGBA
+	images  map[key]*image.RGBA
+
+	// paletteGen is incremented by SetPalette.
+	paletteGen uint64
+}
+
+// icon is an icon's file, read and validated on first use.
+type icon struct {
+	path string
+	once sync.Once
+	src  []byte
+	m    lowlevel.Metadata
+	err  error
+}
+
+type key struct {
+	name string
+	size int
+}
+
+// New returns a Set of the ".ivg" files in fsys. It walks fsys to find them,
+// but does not read them.
+func New(fsys fs.FS) (*Set, error) {
+	s := &Set{
+		fsys:   fsys,
+		icons:  map[string]*icon{},
+		images: map[key]*image.RGBA{},
+	}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (path.Ext(p) != ext) {
+			return nil
+		}
+		name := strings.TrimSuffix(p, ext)
+		s.icons[name] = &icon{path: p}
+		s.names = append(s.names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(s.names)
+	return s, nil
+}
+
+// Names returns the names of the icons, in sorted order. The caller should not
+// modify the returned slice.
+func (s *Set) Names() []string {
+	return s.names
+}
+
+// Has returns whether the Set contains the named icon.
+func (s *Set) Has(name string) bool {
+	return s.icons[name] != nil
+}
+
+// Bytes returns the named icon's IconVG encoding. The caller should not
+// modify the returned slice.
+func (s *Set) Bytes(name string) ([]byte, error) {
+	ic, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	return ic.src, nil
+}
+
+// Metadata returns the named icon's metadata.
+func (s *Set) Metadata(name string) (lowlevel.Metadata, error) {
+	ic, err := s.load(name)
+	if err != nil {
+		return lowlevel.Metadata{}, err
+	}
+	return ic.m, nil
+}
+
+// Graphic decodes the named icon to a new ivg.Graphic, which the caller may
+// modify.
+func (s *Set) Graphic(name string) (*ivg.Graphic, error) {
+	ic, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	return ivg.Decode(ic.src, nil)
+}
+
+// SetPalette sets the overrides for every icon's suggested palette, keyed by
+// palette index, like render.Options.Palette. A nil or empty palette means
+// to use the icons' own palettes. It discards every cached image.
+func (s *Set) SetPalette(palette map[uint8]color.RGBA) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.palette = palette
+	s.paletteGen++
+	s.images = map[key]*image.RGBA{}
+}
+
+// Image returns the named icon rasterized to a size×size image, using the
+// Set's palette. The graphic's viewBox is scaled to fit, preserving its aspect
+// ratio, and is centered.
+//
+// The image is cached, and the same image is returned by later calls with the
+// same name and size, until the palette changes. The caller should not modify
+// it.
+func (s *Set) Image(name string, size int) (*image.RGBA, error) {
+	ic, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	k := key{name, size}
+
+	s.mu.Lock()
+	m, palette, gen := s.images[k], s.palette, s.paletteGen
+	s.mu.Unlock()
+	if m != nil {
+		return m, nil
+	}
+
+	// Rasterize without holding the lock, so that other icons can be
+	// rasterized concurrently.
+	opts := (*render.Options)(nil)
+	if len(palette) > 0 {
+		opts = &render.Options{Palette: palette}
+	}
+	m, err = render.Image(ic.src, size, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev := s.images[k]; prev != nil {
+		// Another goroutine rasterized the same icon first.
+		return prev, nil
+	} else if s.paletteGen != gen {
+		// The palette changed while rasterizing. Don't cache the stale image.
+		return m, nil
+	}
+	s.images[k] = m
+	return m, nil
+}
+
+// load returns the named icon, reading its file if this is its first use.
+func (s *Set) load(name string) (*icon, error) {
+	ic := s.icons[name]
+	if ic == nil {
+		return nil, errUnknownIcon
+	}
+	ic.once.Do(func() {
+		ic.src, ic.err = fs.ReadFile(s.fsys, ic.path)
+		if ic.err == nil {
+			ic.m, ic.err = lowlevel.DecodeMetadata(ic.src)
+		}
+	})
+	return ic, ic.err
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iconset_test
+
+import (
+	"image/color"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/iconvg/src/go/iconset"
+)
+
+func newSet(t *testing.T) *iconset.Set {
+	t.Helper()
+	info, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blank, err := os.ReadFile("../../../test/data/blank.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := iconset.New(fstest.MapFS{
+		"icons/action/info.ivg": {Data: info},
+		"icons/blank.ivg":       {Data: blank},
+		"icons/broken.ivg":      {Data: []byte("not IconVG")},
+		"icons/README.md":       {Data: []byte("# Icons\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNames(t *testing.T) {
+	s := newSet(t)
+	want := []string{"icons/action/info", "icons/blank", "icons/broken"}
+	if got := s.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names: got %q, want %q", got, want)
+	}
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{"icons/action/info", true},
+		{"icons/broken", true},
+		{"icons/action/info.ivg", false},
+		{"icons/README", false},
+		{"info", false},
+	}
+	for _, tc := range testCases {
+		if got := s.Has(tc.name); got != tc.want {
+			t.Errorf("Has(%q): got %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s := newSet(t)
+	testCases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"icons/action/info", false},
+		{"icons/blank", false},
+		{"icons/broken", true},
+		{"icons/missing", true},
+	}
+	for _, tc := range testCases {
+		_, err := s.Bytes(tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: Bytes: got error %v, want error %t", tc.name, err, tc.wantErr)
+		}
+		_, err = s.Metadata(tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: Metadata: got error %v, want error %t", tc.name, err, tc.wantErr)
+		}
+		_, err = s.Graphic(tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: Graphic: got error %v, want error %t", tc.name, err, tc.wantErr)
+		}
+		_, err = s.Image(tc.name, 16)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: Image: got error %v, want error %t", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	s := newSet(t)
+	const name = "icons/action/info"
+	m48, err := s.Image(name, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := s.Image(name, 48); err != nil || m != m48 {
+		t.Errorf("same size: got a different image or error %v, want the cached image", err)
+	}
+	if m, err := s.Image(name, 24); err != nil || m == m48 {
+		t.Errorf("different size: got the same image or error %v, want a different image", err)
+	}
+
+	// action-info's circle is painted with palette entry 0. At size 48,
+	// (24, 6) is inside of it.
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	if got := m48.RGBAAt(24, 6); got != black {
+		t.Errorf("default palette: got %v, want %v", got, black)
+	}
+	s.SetPalette(map[uint8]color.RGBA{0: red})
+	m, err := s.Image(name, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == m48 {
+		t.Errorf("SetPalette: got the cached image, want a new image")
+	}
+	if got := m.RGBAAt(24, 6); got != red {
+		t.Errorf("custom palette: got %v, want %v", got, red)
+	}
+}
+
+func TestImageConcurrent(t *testing.T) {
+	s := newSet(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%4 == 0 {
+				s.SetPalette(map[uint8]color.RGBA{0: {0x00, 0x00, 0xff, 0xff}})
+			}
+			if _, err := s.Image("icons/action/info", 16+i%2); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package arc approximates elliptical arcs by cubic Bézier curves. It is the
+// one implementation of IconVG's arc semantics that the Go packages share.
+package arc
+
+import (
+	"math"
+
+	"golang.org/x/image/math/f32"
+)
+
+// MaxCubics bounds the number of curves that an arc is approximated by,
+// however small the tolerance.
+const MaxCubics = 1024
+
+// Sink receives an arc's approximation.
+type Sink interface {
+	LineTo(p f32.Vec2)
+	CubeTo(c0, c1, p f32.Vec2)
+}
+
+// ToCubics approximates the elliptical arc from the point from to the point
+// to by one or more cubic Bézier curves, passing them to dst. It follows
+// src/c/arc.c, which follows the SVG specification's "Conversion from
+// endpoint to center parameterization", with the same deviations (marked with
+// a †) as other SVG implementations.
+//
+// Each curve is within tolerance, in the arc's coordinate space, of the true
+// arc. If tolerance is not positive, each curve spans at most a quarter turn.
+//
+// An arc with a zero radius, or whose center is not finite, is a straight
+// line, for which dst is given a single LineTo. An arc whose end points are
+// identical is omitted. Like the C implementation, the last curve ends at the
+// approximation's end point, which can differ from to by rounding error.
+func ToCubics(dst Sink, from f32.Vec2, radii f32.Vec2, xAxisRotation float32, largeArc, sweep bool, to f32.Vec2, tolerance float32) {
+	// (†) The abs isn't part of the spec. Neither is checking that rx and ry
+	// are non-zero (and non-NaN).
+	rx := math.Abs(float64(radii[0]))
+	ry := math.Abs(float64(radii[1]))
+	if !(rx > 0) || !(ry > 0) {
+		dst.LineTo(to)
+		return
+	} else if from == to {
+		// The spec says to omit an arc whose end points are identical.
+		return
+	}
+
+	x1 := float64(from[0])
+	y1 := float64(from[1])
+	x2 := float64(to[0])
+	y2 := float64(to[1])
+	phi := 2 * math.Pi * float64(xAxisRotation)
+
+	// Step 1: Compute (x1′, y1′)
+
+	halfDx := (x1 - x2) / 2
+	halfDy := (y1 - y2) / 2
+	sinPhi, cosPhi := math.Sincos(phi)
+	x1Prime := +(cosPhi * halfDx) + (sinPhi * halfDy)
+	y1Prime := -(sinPhi * halfDx) + (cosPhi * halfDy)
+
+	// Step 2: Compute (cx′, cy′)
+
+	rxSq := rx * rx
+	rySq := ry * ry
+	x1PrimeSq := x1Prime * x1Prime
+	y1PrimeSq := y1Prime * y1Prime
+
+	// (†) Check that the radii are large enough.
+	if radiiCheck := (x1PrimeSq / rxSq) + (y1PrimeSq / rySq); radiiCheck > 1 {
+		s := math.Sqrt(radiiCheck)
+		rx *= s
+		ry *= s
+		rxSq = rx * rx
+		rySq = ry * ry
+	}
+
+	denom := (rxSq * y1PrimeSq) + (rySq * x1PrimeSq)
+	step2 := 0.0
+	if a := ((rxSq * rySq) / denom) - 1; a > 0 {
+		step2 = math.Sqrt(a)
+	}
+	if largeArc == sweep {
+		step2 = -step2
+	}
+	cxPrime := +(step2 * rx * y1Prime) / ry
+	cyPrime := -(step2 * ry * x1Prime) / rx
+
+	// Step 3: Compute (cx, cy) from (cx′, cy′)
+
+	cx := +(cosPhi * cxPrime) - (sinPhi * cyPrime) + ((x1 + x2) / 2)
+	cy := +(sinPhi * cxPrime) + (cosPhi * cyPrime) + ((y1 + y2) / 2)
+
+	// Step 4: Compute θ1 and Δθ
+
+	ax := (+x1Prime - cxPrime) / rx
+	ay := (+y1Prime - cyPrime) / ry
+	bx := (-x1Prime - cxPrime) / rx
+	by := (-y1Prime - cyPrime) / ry
+	theta1 := angle(1, 0, ax, ay)
+	deltaTheta := angle(ax, ay, bx, by)
+	if sweep {
+		if deltaTheta < 0 {
+			deltaTheta += 2 * math.Pi
+		}
+	} else {
+		if deltaTheta > 0 {
+			deltaTheta -= 2 * math.Pi
+		}
+	}
+
+	// This ends the
+	// https://www.w3.org/TR/SVG/implnote.html#ArcConversionEndpointToCenter
+	// algorithm. What follows below is specific to this implementation.
+
+	if math.IsNaN(deltaTheta) || math.IsNaN(cx) || math.IsNaN(cy) || math.IsInf(cx, 0) || math.IsInf(cy, 0) {
+		dst.LineTo(to)
+		return
+	}
+
+	// We approximate an arc by one or more cubic Bézier curves.
+	n := int(math.Ceil(math.Abs(deltaTheta) / ((math.Pi / 2) + 0.001)))
+	if n == 0 {
+		dst.LineTo(to)
+		return
+	} else if tolerance > 0 {
+		// The distance between a unit circle's arc, spanning the angle θ, and
+		// its cubic approximation is at most (4/27) sin⁶(θ/4) / cos²(θ/4). An
+		// ellipse is a scaled circle, so its distance is at most that times
+		// the larger radius.
+		r := math.Max(rx, ry)
+		for ; n < MaxCubics; n++ {
+			q := math.Abs(deltaTheta) / float64(4*n)
+			sin, cos := math.Sin(q), math.Cos(q)
+			if r*(4.0/27)*math.Pow(sin, 6)/(cos*cos) <= float64(tolerance) {
+				break
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		segmentTo(dst, cx, cy,
+			theta1+deltaTheta*float64(i+0)/float64(n),
+			theta1+deltaTheta*float64(i+1)/float64(n),
+			rx, ry, cosPhi, sinPhi,
+		)
+	}
+}
+
+// segmentTo approximates an elliptical arc of at most a quarter turn by a
+// single cubic Bézier curve.
+func segmentTo(dst Sink, cx, cy, theta1, theta2, rx, ry, cosPhi, sinPhi float64) {
+	halfDeltaTheta := (theta2 - theta1) * 0.5
+	q := math.Sin(halfDeltaTheta * 0.5)
+	t := (8 * q * q) / (3 * math.Sin(halfDeltaTheta))
+	sin1, cos1 := math.Sincos(theta1)
+	sin2, cos2 := math.Sincos(theta2)
+
+	ix1 := rx * (+cos1 - (t * sin1))
+	iy1 := ry * (+sin1 + (t * cos1))
+	ix2 := rx * (+cos2 + (t * sin2))
+	iy2 := ry * (+sin2 - (t * cos2))
+	ix3 := rx * (+cos2)
+	iy3 := ry * (+sin2)
+
+	dst.CubeTo(
+		f32.Vec2{float32(cx + (cosPhi * ix1) - (sinPhi * iy1)), float32(cy + (sinPhi * ix1) + (cosPhi * iy1))},
+		f32.Vec2{float32(cx + (cosPhi * ix2) - (sinPhi * iy2)), float32(cy + (sinPhi * ix2) + (cosPhi * iy2))},
+		f32.Vec2{float32(cx + (cosPhi * ix3) - (sinPhi * iy3)), float32(cy + (sinPhi * ix3) + (cosPhi * iy3))},
+	)
+}
+
+// angle returns the angle between two vectors u and v.
+func angle(ux, uy, vx, vy float64) float64 {
+	uNorm := math.Sqrt((ux * ux) + (uy * uy))
+	vNorm := math.Sqrt((vx * vx) + (vy * vy))
+	norm := uNorm * vNorm
+	cosine := (ux*vx + uy*vy) / norm
+	ret := 0.0
+	if cosine <= -1 {
+		ret = math.Pi
+	} else if cosine >= +1 {
+		ret = 0
+	} else {
+		ret = math.Acos(cosine)
+	}
+	if (ux * vy) < (uy * vx) {
+		return -ret
+	}
+	return +ret
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arc_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/internal/arc"
+	"golang.org/x/image/math/f32"
+)
+
+type recorder struct {
+	lines  []f32.Vec2
+	cubics [][3]f32.Vec2
+}
+
+func (r *recorder) LineTo(p f32.Vec2)         { r.lines = append(r.lines, p) }
+func (r *recorder) CubeTo(c0, c1, p f32.Vec2) { r.cubics = append(r.cubics, [3]f32.Vec2{c0, c1, p}) }
+
+func TestToCubics(t *testing.T) {
+	testCases := []struct {
+		name       string
+		radii      f32.Vec2
+		largeArc   bool
+		to         f32.Vec2
+		tolerance  float32
+		wantLines  int
+		wantCubics int
+	}{
+		{"semicircle", f32.Vec2{1, 1}, false, f32.Vec2{2, 0}, 0, 0, 2},
+		{"large arc", f32.Vec2{1, 1}, true, f32.Vec2{1, 1}, 0, 0, 3},
+		{"fine tolerance", f32.Vec2{1, 1}, false, f32.Vec2{2, 0}, 1e-6, 0, 6},
+		{"zero radius", f32.Vec2{0, 1}, false, f32.Vec2{2, 0}, 0, 1, 0},
+		{"identical end points", f32.Vec2{1, 1}, false, f32.Vec2{0, 0}, 0, 0, 0},
+	}
+	for _, tc := range testCases {
+		r := &recorder{}
+		arc.ToCubics(r, f32.Vec2{0, 0}, tc.radii, 0, tc.largeArc, true, tc.to, tc.tolerance)
+		if len(r.lines) != tc.wantLines || len(r.cubics) != tc.wantCubics {
+			t.Errorf("%s: got %d lines and %d cubics, want %d and %d",
+				tc.name, len(r.lines), len(r.cubics), tc.wantLines, tc.wantCubics)
+			continue
+		}
+		if n := len(r.cubics); n > 0 {
+			got := r.cubics[n-1][2]
+			if math.Abs(float64(got[0]-tc.to[0])) > 1e-5 || math.Abs(float64(got[1]-tc.to[1])) > 1e-5 {
+				t.Errorf("%s: end point: got %v, want %v", tc.name, got, tc.to)
+			}
+		}
+	}
+}
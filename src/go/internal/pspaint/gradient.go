@@ -0,0 +1,258 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pspaint
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+const (
+	gradientShapeLinear = 0
+	gradientShapeRadial = 1
+)
+
+const (
+	gradientSpreadNone    = 0
+	gradientSpreadPad     = 1
+	gradientSpreadReflect = 2
+	gradientSpreadRepeat  = 3
+)
+
+// maxPeriods bounds how many periods of a "reflect" or "repeat" gradient are
+// unrolled. A gradient that would need more is padded instead.
+const maxPeriods = 64
+
+// Stop is a gradient stop. Its color is alpha-premultiplied.
+type Stop struct {
+	Offset float64
+	Color  color.RGBA
+}
+
+// Gradient is a gradient that fills a path, as an axial or radial shading
+// in pattern space. PDF and PostScript share the syntax of shading
+// dictionaries and of the function dictionaries within them.
+//
+// Pattern space is where linear gradients range from x=0 to x=1 and radial
+// gradients are the unit circle centered on the origin. Neither format has
+// an equivalent to IconVG's "reflect" and "repeat" spreads, so those are
+// unrolled, period by period, across the path's bounds.
+type Gradient struct {
+	Radial bool
+	Stops  []Stop
+
+	// Inverse maps from pattern space to the graphic's coordinate space.
+	// BBox is the path's bounding box, as x0, y0, x1 and y1, in pattern
+	// space.
+	Inverse f64.Aff3
+	BBox    [4]float64
+
+	spread   uint8
+	unrolled bool
+	d0       float64
+	d1       float64
+}
+
+// Opaque returns whether all of g's stops are opaque.
+func (g *Gradient) Opaque() bool {
+	for _, s := range g.Stops {
+		if s.Color.A != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// Function returns a function dictionary that maps a gradient offset to the
+// interpolation of the stops' components, as formatted by comps.
+func (g *Gradient) Function(comps func(i int) string) string {
+	f := stopFunction(g.Stops, comps)
+	if g.unrolled {
+		f = spreadFunction(f, g.spread, g.d0, g.d1)
+	}
+	return f
+}
+
+// Shading returns an axial or radial shading dictionary, whose function, as
+// returned by Function, produces colors in colorSpace.
+func (g *Gradient) Shading(colorSpace string, function string) string {
+	extend := g.spread != gradientSpreadNone
+	typ, coords := 2, Ftoa(g.d0)+" 0 "+Ftoa(g.d1)+" 0"
+	if g.Radial {
+		typ, coords = 3, "0 0 "+Ftoa(g.d0)+" 0 0 "+Ftoa(g.d1)
+	}
+	return fmt.Sprintf("<< /ShadingType %d /ColorSpace %s /Coords [%s] /Domain [%s %s] /Extend [%t %t] /Function %s >>",
+		typ, colorSpace, coords, Ftoa(g.d0), Ftoa(g.d1), extend, extend, function)
+}
+
+// gradient returns the gradient, over the current path, described by the
+// CREG value c, and by the NREG and other CREG values that it refers to. It
+// returns nil if there is nothing to fill.
+func (p *Painter) gradient(c color.RGBA) *Gradient {
+	nStops := int(c.R & 0x3f)
+	if nStops == 0 {
+		return nil
+	}
+	shape := (c.B >> 6) & 0x01
+	cBase := c.G & 0x3f
+	nBase := c.B & 0x3f
+	g := &Gradient{
+		Radial: shape == gradientShapeRadial,
+		Stops:  make([]Stop, nStops),
+		spread: c.G >> 6,
+		d1:     1,
+	}
+	for i := range g.Stops {
+		g.Stops[i] = Stop{
+			Offset: float64(p.nReg[(nBase+uint8(i))&0x3f]),
+			Color:  p.cReg[(cBase+uint8(i))&0x3f],
+		}
+	}
+
+	// The gradient's matrix maps from the graphic's coordinate space to
+	// pattern space. A linear gradient's matrix ignores the y coordinate, so
+	// it is singular, and is made invertible by setting its second row to be
+	// perpendicular to its first.
+	m := f64.Aff3{}
+	for i := range m {
+		m[i] = float64(p.nReg[(nBase-6+uint8(i))&0x3f])
+	}
+	if shape == gradientShapeLinear {
+		m[3], m[4], m[5] = -m[1], m[0], 0
+	}
+	inv, ok := lowlevel.InvertAff3(m)
+	if !ok {
+		return nil
+	}
+	g.Inverse = inv
+
+	// Find the path's bounds, and its range of gradient offsets, in pattern
+	// space. The corners of the path's bounding box suffice, as the offset
+	// is a convex function of the pattern space coordinates.
+	bbox := [4]float64{math.Inf(+1), math.Inf(+1), math.Inf(-1), math.Inf(-1)}
+	tMin, tMax := math.Inf(+1), math.Inf(-1)
+	for _, q := range [4]f32.Vec2{
+		{p.boundsMin[0], p.boundsMin[1]},
+		{p.boundsMax[0], p.boundsMin[1]},
+		{p.boundsMin[0], p.boundsMax[1]},
+		{p.boundsMax[0], p.boundsMax[1]},
+	} {
+		qx, qy := float64(q[0]), float64(q[1])
+		x := m[0]*qx + m[1]*qy + m[2]
+		y := m[3]*qx + m[4]*qy + m[5]
+		bbox[0], bbox[1] = math.Min(bbox[0], x), math.Min(bbox[1], y)
+		bbox[2], bbox[3] = math.Max(bbox[2], x), math.Max(bbox[3], y)
+		t := x
+		if shape == gradientShapeRadial {
+			t = math.Hypot(x, y)
+		}
+		tMin, tMax = math.Min(tMin, t), math.Max(tMax, t)
+	}
+	if shape == gradientShapeRadial {
+		tMin = 0
+	}
+	for _, f := range [...]float64{bbox[0], bbox[1], bbox[2], bbox[3], tMin, tMax} {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+	}
+	g.BBox = bbox
+
+	if (g.spread == gradientSpreadReflect) || (g.spread == gradientSpreadRepeat) {
+		k0, k1 := math.Floor(tMin), math.Ceil(tMax)
+		if k1 == k0 {
+			k1++
+		}
+		if k1-k0 <= maxPeriods {
+			g.unrolled, g.d0, g.d1 = true, k0, k1
+		}
+	}
+	return g
+}
+
+// stopFunction returns a function dictionary, with domain [0, 1], that maps a
+// gradient offset to the interpolation of the stops' components, as formatted
+// by comps. Like the rasterizer, offsets before the first stop or after the
+// last take that stop's components.
+func stopFunction(stops []Stop, comps func(i int) string) string {
+	type node struct {
+		offset float64
+		comps  string
+	}
+	nodes := make([]node, 0, len(stops)+2)
+	prev := 0.0
+	for i, s := range stops {
+		o := s.Offset
+		if !(o >= prev) {
+			o = prev
+		} else if o > 1 {
+			o = 1
+		}
+		nodes = append(nodes, node{o, comps(i)})
+		prev = o
+	}
+	if nodes[0].offset > 0 {
+		nodes = append([]node{{0, nodes[0].comps}}, nodes...)
+	}
+	if n := nodes[len(nodes)-1]; n.offset < 1 {
+		nodes = append(nodes, node{1, n.comps})
+	}
+
+	// Zero width intervals, between coincident stops, are omitted. The
+	// intervals either side of them still produce a hard transition.
+	funcs, bounds, encode := []string(nil), []string(nil), []string(nil)
+	for i := 1; i < len(nodes); i++ {
+		a, b := nodes[i-1], nodes[i]
+		if a.offset == b.offset {
+			continue
+		}
+		if len(funcs) > 0 {
+			bounds = append(bounds, Ftoa(a.offset))
+		}
+		funcs = append(funcs, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >>", a.comps, b.comps))
+		encode = append(encode, "0 1")
+	}
+	if len(funcs) == 1 {
+		return funcs[0]
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		strings.Join(funcs, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+}
+
+// spreadFunction returns a function dictionary, with domain [k0, k1], that
+// repeats or reflects the function f, whose domain is [0, 1], once per unit
+// interval.
+func spreadFunction(f string, spread uint8, k0, k1 float64) string {
+	funcs, bounds, encode := []string(nil), []string(nil), []string(nil)
+	for k := k0; k < k1; k++ {
+		if k > k0 {
+			bounds = append(bounds, Ftoa(k))
+		}
+		funcs = append(funcs, f)
+		if (spread == gradientSpreadReflect) && (math.Mod(k, 2) != 0) {
+			encode = append(encode, "1 0")
+		} else {
+			encode = append(encode, "0 1")
+		}
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [%s %s] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		Ftoa(k0), Ftoa(k1), strings.Join(funcs, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+}
@@ -0,0 +1,387 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pspaint paints IconVG graphics with the imaging model that PDF
+// shares with PostScript. It is the common core of packages ivg2pdf and
+// ivg2eps, which differ only in how they write a page and fill its paths.
+//
+// A Painter is a lowlevel.Destination: it executes the byte code's virtual
+// machine directly, without an intermediate raster or document model. Its
+// paths hold only the m, l, c and h operators, in the graphic's coordinate
+// space. Quadratic Bézier curves are elevated to cubic ones, and arcs are
+// approximated by cubic ones, as neither format has those curves.
+package pspaint
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/google/iconvg/src/go/internal/arc"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Canvas is the output format specific part of a Painter.
+type Canvas interface {
+	// Begin starts a graphic, whose metadata is m. It returns the height, in
+	// pixels, that the graphic's levels of detail are selected by, or an
+	// error, after which the Painter fills no paths.
+	Begin(m lowlevel.Metadata) (lodHeight float32, err error)
+
+	// FillColor fills path with the valid, non-transparent, alpha-premultiplied
+	// color c.
+	FillColor(path []byte, rule lowlevel.FillRule, c color.RGBA)
+
+	// FillGradient fills path with the gradient g.
+	FillGradient(path []byte, rule lowlevel.FillRule, g *Gradient)
+}
+
+// Page is a page that a graphic's viewBox is fitted to.
+type Page struct {
+	// Width and Height are the page size, in points.
+	Width  float64
+	Height float64
+
+	// Transform maps from the graphic's coordinate space to the page's,
+	// whose origin is the bottom left. LODHeight is the height of the fitted
+	// viewBox, which selects the levels of detail.
+	Transform f64.Aff3
+	LODHeight float32
+}
+
+// FitPage fits the viewBox vb to a page of the given size, in points (1/72
+// of an inch), scaling it uniformly and centering it.
+//
+// If both width and height are zero, the page is the size of the viewBox, at
+// one point per viewBox unit. If only one is zero, it is calculated from the
+// other and the viewBox's aspect ratio. FitPage returns false if the page or
+// the viewBox is empty, negative or infinite.
+func FitPage(vb lowlevel.Rectangle, width, height float64) (Page, bool) {
+	vw, vh := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	pw, ph := width, height
+	switch {
+	case pw == 0 && ph == 0:
+		pw, ph = vw, vh
+	case pw == 0:
+		pw = ph * vw / vh
+	case ph == 0:
+		ph = pw * vh / vw
+	}
+	if !(pw > 0) || !(ph > 0) || !(vw > 0) || !(vh > 0) || math.IsInf(pw, 0) || math.IsInf(ph, 0) {
+		return Page{}, false
+	}
+
+	// Flip the y axis, as the page's origin is the bottom left.
+	s := math.Min(pw/vw, ph/vh)
+	tx := (pw-vw*s)/2 - float64(vb.Min[0])*s
+	ty := ph - (ph-vh*s)/2 + float64(vb.Min[1])*s
+	return Page{
+		Width:     pw,
+		Height:    ph,
+		Transform: f64.Aff3{s, 0, tx, 0, -s, ty},
+		LODHeight: float32(vh * s),
+	}, true
+}
+
+// Painter is a lowlevel.Destination that fills the graphic's paths on a
+// Canvas.
+type Painter struct {
+	canvas Canvas
+	err    error
+
+	// path is the current path, which is filled when it ends, as the fill
+	// operators depend on its bounds.
+	path bytes.Buffer
+
+	metadata  lowlevel.Metadata
+	lodHeight float32
+	lod0      float32
+	lod1      float32
+	cSel      uint8
+	nSel      uint8
+	cReg      [64]color.RGBA
+	nReg      [64]float32
+
+	// disabled is whether the current path is outside of the level of detail
+	// bounds, or has an invalid or transparent paint, and so should not be
+	// drawn. paint is the current path's CREG value.
+	disabled bool
+	paint    color.RGBA
+
+	// rule is the fill rule, set by SetFillRule.
+	rule lowlevel.FillRule
+
+	// bounds is the bounding box of the current path's points, including
+	// control points.
+	boundsMin f32.Vec2
+	boundsMax f32.Vec2
+
+	// pen and smooth are the current point and the implicit control point for
+	// a subsequent smooth quadTo or cubeTo.
+	pen    f32.Vec2
+	smooth f32.Vec2
+}
+
+var _ lowlevel.Destination = (*Painter)(nil)
+
+// NewPainter returns a Painter that fills paths on c.
+func NewPainter(c Canvas) *Painter {
+	return &Painter{canvas: c}
+}
+
+// Err returns the error, if any, that the Canvas's Begin method returned.
+func (p *Painter) Err() error { return p.err }
+
+// SetFillRule implements lowlevel.FillRuleDestination.
+func (p *Painter) SetFillRule(r lowlevel.FillRule) { p.rule = r }
+
+func (p *Painter) Reset(m lowlevel.Metadata) {
+	p.metadata = m
+	p.lod0 = 0
+	p.lod1 = float32(math.Inf(+1))
+	p.cReg = m.Palette
+	p.lodHeight, p.err = p.canvas.Begin(m)
+}
+
+func (p *Painter) SetCSel(cSel uint8) { p.cSel = cSel & 0x3f }
+func (p *Painter) SetNSel(nSel uint8) { p.nSel = nSel & 0x3f }
+
+func (p *Painter) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	p.cReg[(p.cSel-adj)&0x3f] = c.Resolve(&p.metadata.Palette, &p.cReg)
+	if incr {
+		p.cSel = (p.cSel + 1) & 0x3f
+	}
+}
+
+func (p *Painter) SetNReg(adj uint8, incr bool, f float32) {
+	p.nReg[(p.nSel-adj)&0x3f] = f
+	if incr {
+		p.nSel = (p.nSel + 1) & 0x3f
+	}
+}
+
+func (p *Painter) SetLOD(lod0, lod1 float32) {
+	p.lod0, p.lod1 = lod0, lod1
+}
+
+func (p *Painter) StartPath(adj uint8, x, y float32) {
+	h := p.lodHeight
+	p.paint = p.cReg[(p.cSel-adj)&0x3f]
+	p.disabled = (p.err != nil) || !(p.lod0 <= h && h < p.lod1) ||
+		!(isGradient(p.paint) || (validAlphaPremulColor(p.paint) && p.paint.A != 0x00))
+	p.path.Reset()
+	p.boundsMin = f32.Vec2{x, y}
+	p.boundsMax = f32.Vec2{x, y}
+	p.moveTo(f32.Vec2{x, y})
+}
+
+func (p *Painter) ClosePathEndPath() {
+	p.closePath()
+	if p.disabled {
+		return
+	}
+	if !isGradient(p.paint) {
+		p.canvas.FillColor(p.path.Bytes(), p.rule, p.paint)
+	} else if g := p.gradient(p.paint); g != nil {
+		p.canvas.FillGradient(p.path.Bytes(), p.rule, g)
+	}
+}
+
+func (p *Painter) ClosePathAbsMoveTo(x, y float32) {
+	p.closePath()
+	p.moveTo(f32.Vec2{x, y})
+}
+
+func (p *Painter) ClosePathRelMoveTo(x, y float32) {
+	p.closePath()
+	p.moveTo(p.rel(x, y))
+}
+
+func (p *Painter) AbsHLineTo(x float32) { p.lineTo(f32.Vec2{x, p.pen[1]}) }
+func (p *Painter) RelHLineTo(x float32) { p.lineTo(f32.Vec2{p.pen[0] + x, p.pen[1]}) }
+func (p *Painter) AbsVLineTo(y float32) { p.lineTo(f32.Vec2{p.pen[0], y}) }
+func (p *Painter) RelVLineTo(y float32) { p.lineTo(f32.Vec2{p.pen[0], p.pen[1] + y}) }
+
+func (p *Painter) AbsLineTo(x, y float32) { p.lineTo(f32.Vec2{x, y}) }
+func (p *Painter) RelLineTo(x, y float32) { p.lineTo(p.rel(x, y)) }
+
+func (p *Painter) AbsSmoothQuadTo(x, y float32) { p.quadTo(p.smooth, f32.Vec2{x, y}) }
+func (p *Painter) RelSmoothQuadTo(x, y float32) { p.quadTo(p.smooth, p.rel(x, y)) }
+
+func (p *Painter) AbsQuadTo(x1, y1, x, y float32) { p.quadTo(f32.Vec2{x1, y1}, f32.Vec2{x, y}) }
+func (p *Painter) RelQuadTo(x1, y1, x, y float32) { p.quadTo(p.rel(x1, y1), p.rel(x, y)) }
+
+func (p *Painter) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	p.cubeTo(p.smooth, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (p *Painter) RelSmoothCubeTo(x2, y2, x, y float32) {
+	p.cubeTo(p.smooth, p.rel(x2, y2), p.rel(x, y))
+}
+
+func (p *Painter) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	p.cubeTo(f32.Vec2{x1, y1}, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (p *Painter) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	p.cubeTo(p.rel(x1, y1), p.rel(x2, y2), p.rel(x, y))
+}
+
+func (p *Painter) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	p.arcTo(rx, ry, xAxisRotation, largeArc, sweep, f32.Vec2{x, y})
+}
+
+func (p *Painter) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	p.arcTo(rx, ry, xAxisRotation, largeArc, sweep, p.rel(x, y))
+}
+
+func (p *Painter) rel(x, y float32) f32.Vec2 {
+	return f32.Vec2{p.pen[0] + x, p.pen[1] + y}
+}
+
+// closePath closes the current sub-path. Like the C implementation, it does
+// not move the pen back to the start of the sub-path.
+func (p *Painter) closePath() {
+	if !p.disabled {
+		p.path.WriteString("h\n")
+	}
+}
+
+func (p *Painter) moveTo(q f32.Vec2) {
+	if !p.disabled {
+		p.addPoints(q)
+		p.printfPath("%s m\n", points(q))
+	}
+	p.pen, p.smooth = q, q
+}
+
+func (p *Painter) lineTo(q f32.Vec2) {
+	if !p.disabled {
+		p.addPoints(q)
+		p.printfPath("%s l\n", points(q))
+	}
+	p.pen, p.smooth = q, q
+}
+
+// quadTo elevates a quadratic Bézier curve to a cubic one.
+func (p *Painter) quadTo(c, q f32.Vec2) {
+	p0 := p.pen
+	p.cubeToNoSmooth(
+		f32.Vec2{p0[0] + (2.0/3)*(c[0]-p0[0]), p0[1] + (2.0/3)*(c[1]-p0[1])},
+		f32.Vec2{q[0] + (2.0/3)*(c[0]-q[0]), q[1] + (2.0/3)*(c[1]-q[1])},
+		q,
+	)
+	p.smooth = reflect(c, q)
+}
+
+func (p *Painter) cubeTo(c0, c1, q f32.Vec2) {
+	p.cubeToNoSmooth(c0, c1, q)
+	p.smooth = reflect(c1, q)
+}
+
+func (p *Painter) cubeToNoSmooth(c0, c1, q f32.Vec2) {
+	if !p.disabled {
+		p.addPoints(c0, c1, q)
+		p.printfPath("%s c\n", points(c0, c1, q))
+	}
+	p.pen = q
+}
+
+// arcTo approximates an elliptical arc by one or more cubic Bézier curves.
+func (p *Painter) arcTo(radiusX, radiusY, xAxisRotation float32, largeArc, sweep bool, final f32.Vec2) {
+	arc.ToCubics((*arcSink)(p), p.pen, f32.Vec2{radiusX, radiusY}, xAxisRotation, largeArc, sweep, final, 0)
+	// Like the C implementation, the pen moves to the arc's nominal end point,
+	// not to the approximating curves' end point.
+	p.pen, p.smooth = final, final
+}
+
+// arcSink is an arc.Sink that adds an arc's approximation to a Painter's
+// path.
+type arcSink Painter
+
+func (s *arcSink) LineTo(q f32.Vec2)         { (*Painter)(s).lineTo(q) }
+func (s *arcSink) CubeTo(c0, c1, q f32.Vec2) { (*Painter)(s).cubeToNoSmooth(c0, c1, q) }
+
+func (p *Painter) addPoints(qs ...f32.Vec2) {
+	for _, q := range qs {
+		for i := 0; i < 2; i++ {
+			if p.boundsMin[i] > q[i] {
+				p.boundsMin[i] = q[i]
+			}
+			if p.boundsMax[i] < q[i] {
+				p.boundsMax[i] = q[i]
+			}
+		}
+	}
+}
+
+func (p *Painter) printfPath(format string, args ...interface{}) {
+	fmt.Fprintf(&p.path, format, args...)
+}
+
+// reflect returns the reflection of the control point c through p.
+func reflect(c, p f32.Vec2) f32.Vec2 {
+	return f32.Vec2{2*p[0] - c[0], 2*p[1] - c[1]}
+}
+
+func validAlphaPremulColor(c color.RGBA) bool {
+	return c.R <= c.A && c.G <= c.A && c.B <= c.A
+}
+
+func isGradient(c color.RGBA) bool {
+	return (c.A == 0x00) && (c.B&0x80 != 0)
+}
+
+func points(ps ...f32.Vec2) string {
+	s := ""
+	for i, p := range ps {
+		if i > 0 {
+			s += " "
+		}
+		s += Ftoa32(p[0]) + " " + Ftoa32(p[1])
+	}
+	return s
+}
+
+// Matrix formats the affine transformation m as the six operands of PDF's cm
+// operator, or the six elements of a PostScript matrix.
+func Matrix(m *f64.Aff3) string {
+	return Ftoa(m[0]) + " " + Ftoa(m[3]) + " " + Ftoa(m[1]) + " " + Ftoa(m[4]) + " " + Ftoa(m[2]) + " " + Ftoa(m[5])
+}
+
+// Ftoa formats a real number. PDF has no exponential notation, infinities or
+// NaNs, so those are clamped.
+func Ftoa(f float64) string {
+	if f != f {
+		return "0"
+	} else if f > math.MaxFloat32 {
+		f = math.MaxFloat32
+	} else if f < -math.MaxFloat32 {
+		f = -math.MaxFloat32
+	}
+	return strconv.FormatFloat(f, 'f', -1, 32)
+}
+
+// Ftoa32 is like Ftoa but for a float32.
+func Ftoa32(f float32) string { return Ftoa(float64(f)) }
+
+// FtoaUnit formats a color component, scaled from [0, 0xff] to [0, 1]. Four
+// decimal places distinguish all 256 values.
+func FtoaUnit(x uint8) string {
+	return strconv.FormatFloat(math.Round(float64(x)*1e4/0xff)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pspaint_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/internal/pspaint"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+func TestFitPage(t *testing.T) {
+	vb := lowlevel.Rectangle{Min: f32.Vec2{-32, -32}, Max: f32.Vec2{+32, +32}}
+	testCases := []struct {
+		width, height float64
+		want          pspaint.Page
+		wantOK        bool
+	}{
+		{0, 0, pspaint.Page{64, 64, f64.Aff3{1, 0, 32, 0, -1, 32}, 64}, true},
+		{128, 0, pspaint.Page{128, 128, f64.Aff3{2, 0, 64, 0, -2, 64}, 128}, true},
+		{128, 64, pspaint.Page{128, 64, f64.Aff3{1, 0, 64, 0, -1, 32}, 64}, true},
+		{-1, 0, pspaint.Page{}, false},
+	}
+	for _, tc := range testCases {
+		got, ok := pspaint.FitPage(vb, tc.width, tc.height)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%vx%v: got %v, %t, want %v, %t", tc.width, tc.height, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+// recorder is a pspaint.Canvas that records the paths that it fills.
+type recorder struct {
+	paths []string
+}
+
+func (r *recorder) Begin(m lowlevel.Metadata) (float32, error) { return 64, nil }
+
+func (r *recorder) FillColor(path []byte, rule lowlevel.FillRule, c color.RGBA) {
+	r.paths = append(r.paths, string(path))
+}
+
+func (r *recorder) FillGradient(path []byte, rule lowlevel.FillRule, g *pspaint.Gradient) {
+	r.paths = append(r.paths, string(path))
+}
+
+func TestPainter(t *testing.T) {
+	r := &recorder{}
+	p := pspaint.NewPainter(r)
+	p.Reset(lowlevel.Metadata{ViewBox: lowlevel.DefaultViewBox, Palette: lowlevel.DefaultPalette})
+	p.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff}))
+	p.StartPath(0, 0, 0)
+	p.AbsLineTo(8, 0)
+	p.AbsQuadTo(8, 6, 0, 6)
+	p.ClosePathEndPath()
+
+	// A transparent path is not filled.
+	p.SetCReg(0, false, lowlevel.RGBAColor(color.RGBA{}))
+	p.StartPath(0, 0, 0)
+	p.AbsLineTo(8, 0)
+	p.ClosePathEndPath()
+
+	want := "0 0 m\n8 0 l\n8 4 5.3333335 6 0 6 c\nh\n"
+	if len(r.paths) != 1 || r.paths[0] != want {
+		t.Errorf("got %q, want %q", r.paths, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"github.com/google/iconvg/src/go/internal/arc"
+	"golang.org/x/image/math/f32"
+)
+
+// Cubics returns cubic Bézier curves that approximate the arc from the point
+// from. Each curve is within tolerance, in graphic coordinate space, of the
+// true arc. If tolerance is not positive, each curve spans at most a quarter
+// turn, like the rasterizers' own approximation.
+//
+// Like the rasterizers, an arc with a zero radius is a straight line, which
+// Cubics returns as a single LineTo, and an arc whose end points are
+// identical is omitted, for which Cubics returns an empty Path. The last
+// curve ends exactly at s.To.
+func (s ArcTo) Cubics(from f32.Vec2, tolerance float32) Path {
+	c := &cubicsSink{p: Path{}}
+	arc.ToCubics(c, from, s.Radii, s.XAxisRotation, s.LargeArc, s.Sweep, s.To, tolerance)
+	if n := len(c.p); n > 0 {
+		if cube, ok := c.p[n-1].(CubeTo); ok {
+			cube.To = s.To
+			c.p[n-1] = cube
+		}
+	}
+	return c.p
+}
+
+// cubicsSink collects an arc's approximation as a Path.
+type cubicsSink struct {
+	p Path
+}
+
+func (c *cubicsSink) LineTo(p f32.Vec2) {
+	c.p = append(c.p, LineTo{To: p})
+}
+
+func (c *cubicsSink) CubeTo(c0, c1, p f32.Vec2) {
+	c.p = append(c.p, CubeTo{Ctrl0: c0, Ctrl1: c1, To: p})
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+func TestArcCubics(t *testing.T) {
+	// The circular arcs are centered on the origin, with radius 10.
+	testCases := []struct {
+		desc      string
+		from      f32.Vec2
+		arc       ivg.ArcTo
+		tolerance float32
+		wantLen   int
+		circular  bool
+	}{{
+		desc:    "zero radius",
+		from:    f32.Vec2{0, 0},
+		arc:     ivg.ArcTo{Radii: f32.Vec2{0, 5}, To: f32.Vec2{10, 0}},
+		wantLen: 1,
+	}, {
+		desc:    "same end points",
+		from:    f32.Vec2{10, 0},
+		arc:     ivg.ArcTo{Radii: f32.Vec2{10, 10}, To: f32.Vec2{10, 0}},
+		wantLen: 0,
+	}, {
+		desc:     "quarter turn",
+		from:     f32.Vec2{10, 0},
+		arc:      ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{0, 10}},
+		wantLen:  1,
+		circular: true,
+	}, {
+		desc:     "half turn",
+		from:     f32.Vec2{10, 0},
+		arc:      ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+		wantLen:  2,
+		circular: true,
+	}, {
+		desc:     "three quarter turn",
+		from:     f32.Vec2{10, 0},
+		arc:      ivg.ArcTo{Radii: f32.Vec2{10, 10}, LargeArc: true, To: f32.Vec2{0, 10}},
+		wantLen:  3,
+		circular: true,
+	}, {
+		desc:      "fine tolerance",
+		from:      f32.Vec2{10, 0},
+		arc:       ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+		tolerance: 1e-5,
+		wantLen:   -1,
+		circular:  true,
+	}}
+	for _, tc := range testCases {
+		got := tc.arc.Cubics(tc.from, tc.tolerance)
+		if tc.wantLen >= 0 {
+			if len(got) != tc.wantLen {
+				t.Errorf("%s: len: got %d, want %d", tc.desc, len(got), tc.wantLen)
+				continue
+			}
+		} else if len(got) <= 2 {
+			t.Errorf("%s: len: got %d, want > 2", tc.desc, len(got))
+			continue
+		}
+		if len(got) == 0 {
+			continue
+		}
+		if end := got[len(got)-1].EndPoint(f32.Vec2{}, f32.Vec2{}); end != tc.arc.To {
+			t.Errorf("%s: end point: got %v, want %v", tc.desc, end, tc.arc.To)
+		}
+		if !tc.circular {
+			continue
+		}
+
+		// Sample each curve: every sample should be close to the circle.
+		tolerance := float64(tc.tolerance)
+		if tolerance <= 0 {
+			tolerance = 0.05
+		}
+		pen := tc.from
+		for i, seg := range got {
+			c, ok := seg.(ivg.CubeTo)
+			if !ok {
+				t.Errorf("%s: segment %d: got %T, want ivg.CubeTo", tc.desc, i, seg)
+				break
+			}
+			for k := 0; k <= 8; k++ {
+				s := float64(k) / 8
+				u := 1 - s
+				x := u*u*u*float64(pen[0]) + 3*u*u*s*float64(c.Ctrl0[0]) + 3*u*s*s*float64(c.Ctrl1[0]) + s*s*s*float64(c.To[0])
+				y := u*u*u*float64(pen[1]) + 3*u*u*s*float64(c.Ctrl0[1]) + 3*u*s*s*float64(c.Ctrl1[1]) + s*s*s*float64(c.To[1])
+				if d := math.Abs(math.Hypot(x, y) - 10); d > tolerance+1e-4 {
+					t.Errorf("%s: segment %d, t=%g: distance from circle: got %g, want <= %g",
+						tc.desc, i, s, d, tolerance)
+				}
+			}
+			pen = c.To
+		}
+	}
+}
+
+func TestLowerArcs(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/arcs.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tolerance := range []float32{0, 0.01} {
+		enc, err := (&ivg.Encoder{LowerArcs: true, ArcTolerance: tolerance}).Encode(g)
+		if err != nil {
+			t.Errorf("tolerance %g: Encode: %v", tolerance, err)
+			continue
+		}
+		g2, err := ivg.Decode(enc, nil)
+		if err != nil {
+			t.Errorf("tolerance %g: Decode: %v", tolerance, err)
+			continue
+		}
+		for i, s := range g2.Shapes {
+			for j, seg := range s.Path {
+				if _, ok := seg.(ivg.ArcTo); ok {
+					t.Errorf("tolerance %g: shape %d segment %d: got an ArcTo", tolerance, i, j)
+				}
+			}
+		}
+		checkSameRendering(t, "arcs.ivg", enc, src, 2)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+)
+
+// benchmarkCorpus is the test/data graphics that the benchmarks run on, from
+// simple to complex: a one-path icon, an illustration with gradients and an
+// illustration with many paths and blends.
+var benchmarkCorpus = []string{
+	"action-info.lores",
+	"cowbell",
+	"favicon",
+}
+
+// runBenchmarkCorpus runs f as a sub-benchmark for each graphic in the
+// benchmark corpus, reporting allocations.
+func runBenchmarkCorpus(b *testing.B, f func(b *testing.B, src []byte)) {
+	for _, name := range benchmarkCorpus {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			f(b, src)
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	runBenchmarkCorpus(b, func(b *testing.B, src []byte) {
+		for i := 0; i < b.N; i++ {
+			if _, err := ivg.Decode(src, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEncode(b *testing.B) {
+	benchmarkEncode(b, &ivg.Encoder{})
+}
+
+func BenchmarkEncodeOptimize(b *testing.B) {
+	benchmarkEncode(b, &ivg.Encoder{Optimize: true})
+}
+
+func benchmarkEncode(b *testing.B, e *ivg.Encoder) {
+	runBenchmarkCorpus(b, func(b *testing.B, src []byte) {
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := e.Encode(g); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,478 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// maxFlattenSegments bounds the number of line segments that a curve is
+// flattened to, however small the tolerance.
+const maxFlattenSegments = 1024
+
+// Union returns a Path that fills the area filled by a or b, or by both.
+//
+// Union, Intersect and Subtract let composite icons, such as an icon with a
+// badge cut out of it, be built programmatically:
+//
+//	icon.Shapes[0].Path = ivg.Subtract(icon.Shapes[0].Path, badgeCircle, 0)
+//
+// Like a Shape with the default FillRule, the input Paths are filled with the
+// nonzero winding rule, and their sub-paths are filled as if they were
+// closed. Curves and arcs are flattened to line segments that are within
+// tolerance, in graphic coordinate space, of the true curve. If tolerance is
+// not positive, it is 1/4096th of the larger dimension of the Paths' combined
+// bounding box.
+//
+// The result consists of closed polygons: MoveTo, LineTo and ClosePath
+// segments, with holes wound in the opposite direction to the areas around
+// them. It is empty if the result fills no area.
+func Union(a, b Path, tolerance float32) Path {
+	return boolean(a, b, tolerance, func(inA, inB bool) bool { return inA || inB })
+}
+
+// Intersect returns a Path that fills the area filled by both a and b. See
+// Union for details.
+func Intersect(a, b Path, tolerance float32) Path {
+	return boolean(a, b, tolerance, func(inA, inB bool) bool { return inA && inB })
+}
+
+// Subtract returns a Path that fills the area filled by a but not by b. See
+// Union for details.
+func Subtract(a, b Path, tolerance float32) Path {
+	return boolean(a, b, tolerance, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// point is a polygon vertex. The clipping uses float64 arithmetic.
+type point struct {
+	x, y float64
+}
+
+// edge is a directed polygon edge, from p to q.
+type edge struct {
+	p, q point
+}
+
+// boolean implements Union, Intersect and Subtract. It flattens the Paths
+// and then clips them.
+//
+// Clipping is an edge classification algorithm: every edge of both polygons
+// is split wherever it crosses or touches another edge, and each piece is
+// kept, as an edge of the result, if op gives different answers on either
+// side of it.
+func boolean(a, b Path, tolerance float32, op func(inA, inB bool) bool) Path {
+	scale := clipScale(a, b)
+	if !(scale > 0) || math.IsInf(scale, 0) {
+		return Path{}
+	}
+	tol := float64(tolerance)
+	if !(tol > 0) {
+		tol = scale / 4096
+	}
+	return clip(flatten(a, tol), flatten(b, tol), scale, lowlevel.FillRuleNonZero, op)
+}
+
+// clip returns the polygons that fill the area where op, given whether a
+// point is inside the polygons ea and eb under the fill rule, is true. scale
+// is the size of the polygons' bounding box.
+func clip(ea, eb []edge, scale float64, rule lowlevel.FillRule, op func(inA, inB bool) bool) Path {
+	inside := func(edges []edge, p point) bool {
+		w := winding(edges, p)
+		if rule == lowlevel.FillRuleEvenOdd {
+			return w&1 != 0
+		}
+		return w != 0
+	}
+	all := append(append([]edge(nil), ea...), eb...)
+	pieces := splitEdges(all)
+
+	// eps is how far either side of a piece its inside-ness is sampled.
+	eps := scale * 1e-9
+	kept := map[edge]bool{}
+	keptOrder := []edge(nil)
+	for _, e := range pieces {
+		dx, dy := e.q.x-e.p.x, e.q.y-e.p.y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		mx, my := (e.p.x+e.q.x)/2, (e.p.y+e.q.y)/2
+		nx, ny := -dy/length*eps, dx/length*eps
+		l, r := point{mx + nx, my + ny}, point{mx - nx, my - ny}
+		inL := op(inside(ea, l), inside(eb, l))
+		inR := op(inside(ea, r), inside(eb, r))
+		if inL == inR {
+			continue
+		} else if !inL {
+			e = edge{e.q, e.p}
+		}
+		// Coincident edges, from a and b or from overlapping sub-paths, are
+		// kept once.
+		if !kept[e] {
+			kept[e] = true
+			keptOrder = append(keptOrder, e)
+		}
+	}
+	return chain(keptOrder)
+}
+
+// clipScale returns the larger dimension of the bounding box of the Paths'
+// points, including control points.
+func clipScale(paths ...Path) float64 {
+	bMin := point{math.Inf(+1), math.Inf(+1)}
+	bMax := point{math.Inf(-1), math.Inf(-1)}
+	add := func(v f32.Vec2, r float64) {
+		bMin.x, bMin.y = math.Min(bMin.x, float64(v[0])-r), math.Min(bMin.y, float64(v[1])-r)
+		bMax.x, bMax.y = math.Max(bMax.x, float64(v[0])+r), math.Max(bMax.y, float64(v[1])+r)
+	}
+	for _, p := range paths {
+		for _, seg := range p {
+			switch seg := seg.(type) {
+			case MoveTo:
+				add(seg.To, 0)
+			case LineTo:
+				add(seg.To, 0)
+			case QuadTo:
+				add(seg.Ctrl, 0)
+				add(seg.To, 0)
+			case CubeTo:
+				add(seg.Ctrl0, 0)
+				add(seg.Ctrl1, 0)
+				add(seg.To, 0)
+			case ArcTo:
+				// This is approximate, as an arc's radii can be scaled up,
+				// but it only sets the default tolerance.
+				r := math.Max(math.Abs(float64(seg.Radii[0])), math.Abs(float64(seg.Radii[1])))
+				add(seg.To, 2*r)
+			}
+		}
+	}
+	return math.Max(bMax.x-bMin.x, bMax.y-bMin.y)
+}
+
+// polyline is a flattened sub-path. Consecutive points are distinct.
+type polyline struct {
+	pts    []point
+	closed bool
+}
+
+// flatten returns p's sub-paths as closed polygons.
+func flatten(p Path, tol float64) []edge {
+	return polylineEdges(flattenPolylines(p, tol))
+}
+
+// polylineEdges returns the polylines as closed polygons.
+func polylineEdges(pls []polyline) []edge {
+	edges := []edge(nil)
+	for _, pl := range pls {
+		n := len(pl.pts)
+		for i := 1; i < n; i++ {
+			edges = append(edges, edge{pl.pts[i-1], pl.pts[i]})
+		}
+		if (n > 1) && (pl.pts[n-1] != pl.pts[0]) {
+			edges = append(edges, edge{pl.pts[n-1], pl.pts[0]})
+		}
+	}
+	return edges
+}
+
+// flattenPolylines returns p's sub-paths as polylines, which are closed if
+// their sub-path ends with a ClosePath.
+func flattenPolylines(p Path, tol float64) []polyline {
+	pls := []polyline(nil)
+	pen, start := point{}, point{}
+	// cur is the index in pls of the current polyline, or -1 after a
+	// ClosePath.
+	cur := -1
+	lineTo := func(q point) {
+		if q != pen {
+			pls[cur].pts = append(pls[cur].pts, q)
+		}
+		pen = q
+	}
+	for _, seg := range p {
+		if _, ok := seg.(MoveTo); !ok && (cur < 0) {
+			pls = append(pls, polyline{pts: []point{pen}})
+			cur, start = len(pls)-1, pen
+		}
+		switch seg := seg.(type) {
+		case MoveTo:
+			pen, start = pt(seg.To), pt(seg.To)
+			pls = append(pls, polyline{pts: []point{pen}})
+			cur = len(pls) - 1
+		case LineTo:
+			lineTo(pt(seg.To))
+		case QuadTo:
+			p0, p1, p2 := pen, pt(seg.Ctrl), pt(seg.To)
+			dd := math.Hypot(p0.x-2*p1.x+p2.x, p0.y-2*p1.y+p2.y)
+			n := flattenSegments(dd/4, tol)
+			for i := 1; i < n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				lineTo(point{
+					u*u*p0.x + 2*u*t*p1.x + t*t*p2.x,
+					u*u*p0.y + 2*u*t*p1.y + t*t*p2.y,
+				})
+			}
+			lineTo(p2)
+		case CubeTo:
+			flattenCubic(lineTo, pen, pt(seg.Ctrl0), pt(seg.Ctrl1), pt(seg.To), tol)
+		case ArcTo:
+			// Split the tolerance between approximating the arc with cubics
+			// and flattening those cubics.
+			from := f32.Vec2{float32(pen.x), float32(pen.y)}
+			for _, c := range seg.Cubics(from, float32(tol/2)) {
+				switch c := c.(type) {
+				case LineTo:
+					lineTo(pt(c.To))
+				case CubeTo:
+					flattenCubic(lineTo, pen, pt(c.Ctrl0), pt(c.Ctrl1), pt(c.To), tol/2)
+				}
+			}
+		case ClosePath:
+			pl := &pls[cur]
+			pl.closed = true
+			if n := len(pl.pts); (n > 1) && (pl.pts[n-1] == start) {
+				pl.pts = pl.pts[:n-1]
+			}
+			pen, cur = start, -1
+		}
+	}
+	return pls
+}
+
+// flattenCubic calls lineTo with the points of a polygonal approximation,
+// within tol, to the cubic Bézier curve from p0 to p3.
+func flattenCubic(lineTo func(point), p0, p1, p2, p3 point, tol float64) {
+	dd := math.Max(
+		math.Hypot(p0.x-2*p1.x+p2.x, p0.y-2*p1.y+p2.y),
+		math.Hypot(p1.x-2*p2.x+p3.x, p1.y-2*p2.y+p3.y),
+	)
+	n := flattenSegments(dd*3/4, tol)
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		lineTo(point{
+			u*u*u*p0.x + 3*u*u*t*p1.x + 3*u*t*t*p2.x + t*t*t*p3.x,
+			u*u*u*p0.y + 3*u*u*t*p1.y + 3*u*t*t*p2.y + t*t*t*p3.y,
+		})
+	}
+	lineTo(p3)
+}
+
+// flattenSegments returns how many line segments approximate a curve, within
+// tol, given a bound k on its second derivative divided by 2: the distance
+// between a curve and its chord, over a parameter interval h, is at most
+// k*h*h/4.
+func flattenSegments(k, tol float64) int {
+	n := math.Ceil(math.Sqrt(k / tol))
+	if !(n >= 1) {
+		return 1
+	} else if n > maxFlattenSegments {
+		return maxFlattenSegments
+	}
+	return int(n)
+}
+
+func pt(v f32.Vec2) point {
+	return point{float64(v[0]), float64(v[1])}
+}
+
+// splitEdges splits the edges at their intersections with each other,
+// including where an edge's end point touches another edge and where
+// collinear edges overlap. An intersection point is computed once, so the
+// pieces either side of it share that exact point.
+func splitEdges(edges []edge) []edge {
+	type split struct {
+		t float64
+		p point
+	}
+	splits := make([][]split, len(edges))
+	addSplit := func(i int, t float64, p point) {
+		if (0 < t) && (t < 1) && (p != edges[i].p) && (p != edges[i].q) {
+			splits[i] = append(splits[i], split{t, p})
+		}
+	}
+	// param returns where p projects onto edge e, as a fraction of its
+	// length.
+	param := func(e edge, p point) float64 {
+		dx, dy := e.q.x-e.p.x, e.q.y-e.p.y
+		return ((p.x-e.p.x)*dx + (p.y-e.p.y)*dy) / (dx*dx + dy*dy)
+	}
+
+	const (
+		tEps        = 1e-12
+		parallelEps = 1e-9
+	)
+	for i := range edges {
+		e := edges[i]
+		for j := i + 1; j < len(edges); j++ {
+			f := edges[j]
+			if (math.Max(e.p.x, e.q.x) < math.Min(f.p.x, f.q.x)) ||
+				(math.Max(f.p.x, f.q.x) < math.Min(e.p.x, e.q.x)) ||
+				(math.Max(e.p.y, e.q.y) < math.Min(f.p.y, f.q.y)) ||
+				(math.Max(f.p.y, f.q.y) < math.Min(e.p.y, e.q.y)) {
+				continue
+			}
+			rx, ry := e.q.x-e.p.x, e.q.y-e.p.y
+			sx, sy := f.q.x-f.p.x, f.q.y-f.p.y
+			wx, wy := f.p.x-e.p.x, f.p.y-e.p.y
+			denom := rx*sy - ry*sx
+
+			if lr, ls := math.Hypot(rx, ry), math.Hypot(sx, sy); math.Abs(denom) <= parallelEps*lr*ls {
+				// Parallel edges only interact if they are collinear. Edges
+				// that are nearly so, such as those computed from the same
+				// line by different arithmetic, are treated the same, as
+				// their intersection is ill-conditioned.
+				d := parallelEps * (lr + ls)
+				if (math.Abs(wx*ry-wy*rx) > d*lr) || (math.Abs((f.q.x-e.p.x)*ry-(f.q.y-e.p.y)*rx) > d*lr) {
+					continue
+				}
+				for _, p := range [2]point{f.p, f.q} {
+					addSplit(i, param(e, p), p)
+				}
+				for _, p := range [2]point{e.p, e.q} {
+					addSplit(j, param(f, p), p)
+				}
+				continue
+			}
+
+			t := (wx*sy - wy*sx) / denom
+			u := (wx*ry - wy*rx) / denom
+			if (t < -tEps) || (t > 1+tEps) || (u < -tEps) || (u > 1+tEps) {
+				continue
+			}
+			// Prefer an exact end point to a computed intersection.
+			p := point{e.p.x + t*rx, e.p.y + t*ry}
+			switch {
+			case t <= tEps:
+				p = e.p
+			case t >= 1-tEps:
+				p = e.q
+			case u <= tEps:
+				p = f.p
+			case u >= 1-tEps:
+				p = f.q
+			}
+			addSplit(i, t, p)
+			addSplit(j, u, p)
+		}
+	}
+
+	pieces := make([]edge, 0, len(edges))
+	for i, e := range edges {
+		s := splits[i]
+		sort.Slice(s, func(a, b int) bool { return s[a].t < s[b].t })
+		p := e.p
+		for _, x := range s {
+			if x.p != p {
+				pieces = append(pieces, edge{p, x.p})
+				p = x.p
+			}
+		}
+		if e.q != p {
+			pieces = append(pieces, edge{p, e.q})
+		}
+	}
+	return pieces
+}
+
+// winding returns the winding number of the polygon edges around p.
+func winding(edges []edge, p point) int {
+	w := 0
+	for _, e := range edges {
+		c := (e.q.x-e.p.x)*(p.y-e.p.y) - (p.x-e.p.x)*(e.q.y-e.p.y)
+		if e.p.y <= p.y {
+			if (e.q.y > p.y) && (c > 0) {
+				w++
+			}
+		} else if (e.q.y <= p.y) && (c < 0) {
+			w--
+		}
+	}
+	return w
+}
+
+// chain joins edges, end to start, into closed polygons. Collinear vertices
+// are dropped.
+func chain(edges []edge) Path {
+	out := map[point][]int{}
+	for i, e := range edges {
+		out[e.p] = append(out[e.p], i)
+	}
+	used := make([]bool, len(edges))
+	path := Path{}
+	for i := range edges {
+		if used[i] {
+			continue
+		}
+		loop := []point{edges[i].p}
+		for j := i; ; {
+			used[j] = true
+			q := edges[j].q
+			if q == loop[0] {
+				break
+			}
+			loop = append(loop, q)
+			next := -1
+			for _, k := range out[q] {
+				if !used[k] {
+					next = k
+					break
+				}
+			}
+			if next < 0 {
+				// A dangling edge, from a misclassified sliver. The
+				// polygon is closed implicitly.
+				break
+			}
+			j = next
+		}
+
+		loop = dropCollinear(loop)
+		if len(loop) < 3 {
+			continue
+		}
+		path = append(path, MoveTo{To: f32.Vec2{float32(loop[0].x), float32(loop[0].y)}})
+		for _, p := range loop[1:] {
+			path = append(path, LineTo{To: f32.Vec2{float32(p.x), float32(p.y)}})
+		}
+		path = append(path, ClosePath{})
+	}
+	return path
+}
+
+// dropCollinear removes the vertices of a closed polygon that lie on the
+// straight line between their neighbors.
+func dropCollinear(loop []point) []point {
+	for changed := true; changed && (len(loop) >= 3); {
+		changed = false
+		for i := 0; i < len(loop) && len(loop) >= 3; i++ {
+			a, b, c := loop[(i+len(loop)-1)%len(loop)], loop[i], loop[(i+1)%len(loop)]
+			cross := (b.x-a.x)*(c.y-b.y) - (b.y-a.y)*(c.x-b.x)
+			dot := (b.x-a.x)*(c.x-b.x) + (b.y-a.y)*(c.y-b.y)
+			if (cross == 0) && (dot > 0) {
+				loop = append(loop[:i], loop[i+1:]...)
+				i--
+				changed = true
+			}
+		}
+	}
+	return loop
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+// area returns the area filled by a Path of MoveTo, LineTo and ClosePath
+// segments, such as the result of Union, and its number of sub-paths. Holes,
+// wound in the opposite direction, subtract from the area.
+func area(t *testing.T, p ivg.Path) (a float64, loops int) {
+	t.Helper()
+	start, pen := f32.Vec2{}, f32.Vec2{}
+	cross := func(p, q f32.Vec2) float64 {
+		return float64(p[0])*float64(q[1]) - float64(q[0])*float64(p[1])
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			start, pen = seg.To, seg.To
+			loops++
+		case ivg.LineTo:
+			a += cross(pen, seg.To)
+			pen = seg.To
+		case ivg.ClosePath:
+			a += cross(pen, start)
+			pen = start
+		default:
+			t.Fatalf("got %T segment, want only MoveTo, LineTo and ClosePath", seg)
+		}
+	}
+	return math.Abs(a / 2), loops
+}
+
+func rect(x0, y0, x1, y1 float32) ivg.Path {
+	return ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{x0, y0}},
+		ivg.LineTo{To: f32.Vec2{x1, y0}},
+		ivg.LineTo{To: f32.Vec2{x1, y1}},
+		ivg.LineTo{To: f32.Vec2{x0, y1}},
+		ivg.ClosePath{},
+	}
+}
+
+func TestBoolean(t *testing.T) {
+	circle := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{10, 0}},
+		ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+		ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{10, 0}},
+		ivg.ClosePath{},
+	}
+	testCases := []struct {
+		desc      string
+		op        func(a, b ivg.Path, tolerance float32) ivg.Path
+		a, b      ivg.Path
+		wantArea  float64
+		wantLoops int
+	}{
+		{"union overlapping", ivg.Union, rect(0, 0, 10, 10), rect(5, 5, 15, 15), 175, 1},
+		{"intersect overlapping", ivg.Intersect, rect(0, 0, 10, 10), rect(5, 5, 15, 15), 25, 1},
+		{"subtract overlapping", ivg.Subtract, rect(0, 0, 10, 10), rect(5, 5, 15, 15), 75, 1},
+		{"union disjoint", ivg.Union, rect(0, 0, 10, 10), rect(20, 0, 30, 10), 200, 2},
+		{"intersect disjoint", ivg.Intersect, rect(0, 0, 10, 10), rect(20, 0, 30, 10), 0, 0},
+		{"subtract disjoint", ivg.Subtract, rect(0, 0, 10, 10), rect(20, 0, 30, 10), 100, 1},
+		{"union adjacent", ivg.Union, rect(0, 0, 10, 10), rect(10, 0, 20, 10), 200, 1},
+		{"subtract a hole", ivg.Subtract, rect(0, 0, 10, 10), rect(3, 3, 7, 7), 84, 2},
+		{"subtract everything", ivg.Subtract, rect(3, 3, 7, 7), rect(0, 0, 10, 10), 0, 0},
+		{"intersect identical", ivg.Intersect, rect(0, 0, 10, 10), rect(0, 0, 10, 10), 100, 1},
+		{"intersect circles", ivg.Intersect, circle, circle, 100 * math.Pi, 1},
+		{"subtract circle", ivg.Subtract, rect(-12, -12, 12, 12), circle, 576 - 100*math.Pi, 2},
+		{"empty", ivg.Union, nil, nil, 0, 0},
+	}
+	for _, tc := range testCases {
+		got := tc.op(tc.a, tc.b, 0)
+		gotArea, gotLoops := area(t, got)
+		// Flattening the circle, to within the default tolerance of 20/4096,
+		// loses about 0.2 of its area.
+		if math.Abs(gotArea-tc.wantArea) > 0.25 {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, gotArea, tc.wantArea)
+		}
+		if gotLoops != tc.wantLoops {
+			t.Errorf("%s: loops: got %d, want %d", tc.desc, gotLoops, tc.wantLoops)
+		}
+	}
+}
+
+func TestBooleanTolerance(t *testing.T) {
+	circle := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{10, 0}},
+		ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+		ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{10, 0}},
+		ivg.ClosePath{},
+	}
+	// A coarser tolerance flattens the circle to fewer line segments, and
+	// the polygon inscribed within the circle has less area.
+	fine, _ := area(t, ivg.Union(circle, nil, 0.001))
+	coarse, _ := area(t, ivg.Union(circle, nil, 1))
+	if !(coarse < fine) {
+		t.Errorf("got coarse area %v, want less than fine area %v", coarse, fine)
+	}
+	if len(ivg.Union(circle, nil, 1)) >= len(ivg.Union(circle, nil, 0.001)) {
+		t.Errorf("got no fewer segments with a coarser tolerance")
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Builder builds a Graphic one path segment at a time. Its methods return the
+// Builder, so that calls can be chained:
+//
+//	b := ivg.NewBuilder()
+//	b.MoveTo(-24, -24).LineTo(+24, -24).LineTo(0, +24).ClosePath()
+//	b.Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff}))
+//	data, err := b.Bytes()
+//
+// All coordinates are absolute. When encoding, the Encoder chooses between
+// absolute and relative coordinates, and other encoding details, to minimize
+// the size of the IconVG byte code.
+type Builder struct {
+	g    Graphic
+	path Path
+	lod0 float32
+	lod1 float32
+	rule lowlevel.FillRule
+
+	// pen is the current point. start is the start of the current sub-path.
+	pen   f32.Vec2
+	start f32.Vec2
+}
+
+// NewBuilder returns a Builder for a Graphic with the default metadata.
+func NewBuilder() *Builder {
+	return &Builder{
+		g:    *NewGraphic(),
+		lod0: DefaultLOD0,
+		lod1: DefaultLOD1,
+	}
+}
+
+// SetViewBox sets the Graphic's ViewBox.
+func (b *Builder) SetViewBox(minX, minY, maxX, maxY float32) *Builder {
+	b.g.Metadata.ViewBox = lowlevel.Rectangle{
+		Min: f32.Vec2{minX, minY},
+		Max: f32.Vec2{maxX, maxY},
+	}
+	return b
+}
+
+// SetPalette sets the Graphic's suggested palette.
+func (b *Builder) SetPalette(p *lowlevel.Palette) *Builder {
+	b.g.Metadata.Palette = *p
+	return b
+}
+
+// SetLOD sets the level of detail bounds for subsequently filled Shapes.
+func (b *Builder) SetLOD(lod0, lod1 float32) *Builder {
+	b.lod0, b.lod1 = lod0, lod1
+	return b
+}
+
+// SetFillRule sets the fill rule for subsequently filled Shapes. It is
+// lowlevel.FillRuleNonZero by default.
+func (b *Builder) SetFillRule(r lowlevel.FillRule) *Builder {
+	b.rule = r
+	return b
+}
+
+// MoveTo starts a new sub-path.
+func (b *Builder) MoveTo(x, y float32) *Builder {
+	b.pen = f32.Vec2{x, y}
+	b.start = b.pen
+	b.path = append(b.path, MoveTo{To: b.pen})
+	return b
+}
+
+// implicitMoveTo starts a sub-path at the pen position, if there is no current
+// path.
+func (b *Builder) implicitMoveTo() {
+	if len(b.path) == 0 {
+		b.path = append(b.path, MoveTo{To: b.pen})
+		b.start = b.pen
+	}
+}
+
+// LineTo adds a straight line to the current sub-path.
+func (b *Builder) LineTo(x, y float32) *Builder {
+	b.implicitMoveTo()
+	b.pen = f32.Vec2{x, y}
+	b.path = append(b.path, LineTo{To: b.pen})
+	return b
+}
+
+// QuadTo adds a quadratic Bézier curve to the current sub-path.
+func (b *Builder) QuadTo(x1, y1, x, y float32) *Builder {
+	b.implicitMoveTo()
+	b.pen = f32.Vec2{x, y}
+	b.path = append(b.path, QuadTo{Ctrl: f32.Vec2{x1, y1}, To: b.pen})
+	return b
+}
+
+// CubeTo adds a cubic Bézier curve to the current sub-path.
+func (b *Builder) CubeTo(x1, y1, x2, y2, x, y float32) *Builder {
+	b.implicitMoveTo()
+	b.pen = f32.Vec2{x, y}
+	b.path = append(b.path, CubeTo{Ctrl0: f32.Vec2{x1, y1}, Ctrl1: f32.Vec2{x2, y2}, To: b.pen})
+	return b
+}
+
+// ArcTo adds an elliptical arc to the current sub-path, with the same
+// semantics as SVG's "A" path data command, except that xAxisRotation is
+// measured in revolutions (a fraction of 360 degrees).
+func (b *Builder) ArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) *Builder {
+	b.implicitMoveTo()
+	b.pen = f32.Vec2{x, y}
+	b.path = append(b.path, ArcTo{
+		Radii:         f32.Vec2{rx, ry},
+		XAxisRotation: xAxisRotation,
+		LargeArc:      largeArc,
+		Sweep:         sweep,
+		To:            b.pen,
+	})
+	return b
+}
+
+// ClosePath closes the current sub-path.
+func (b *Builder) ClosePath() *Builder {
+	if len(b.path) > 0 {
+		b.path = append(b.path, ClosePath{})
+		b.pen = b.start
+	}
+	return b
+}
+
+// Fill fills the current path with a flat color, adding a Shape to the
+// Graphic, and starts a new, empty path.
+func (b *Builder) Fill(c lowlevel.Color) *Builder {
+	return b.FillPaint(Paint{Color: c})
+}
+
+// FillPaint is like Fill but with an arbitrary Paint, such as a gradient from
+// LinearGradient or RadialGradient.
+func (b *Builder) FillPaint(p Paint) *Builder {
+	if len(b.path) > 0 {
+		b.g.Shapes = append(b.g.Shapes, Shape{
+			Paint:    p,
+			Path:     b.path,
+			LOD0:     b.lod0,
+			LOD1:     b.lod1,
+			FillRule: b.rule,
+		})
+		b.path = nil
+	}
+	return b
+}
+
+// DefineSymbol adds a named, reusable sub-drawing to the Graphic, replacing
+// any previous Symbol with that name. Its Shapes can come from another
+// Builder's Graphic:
+//
+//	tick := ivg.NewBuilder()
+//	tick.MoveTo(-1, -30).LineTo(+1, -30).LineTo(+1, -24).LineTo(-1, -24).ClosePath()
+//	tick.Fill(lowlevel.PaletteIndexColor(0))
+//	b.DefineSymbol("tick", tick.Graphic().Shapes)
+//	for i := 0; i < 12; i++ {
+//		sin, cos := math.Sincos(float64(i) * math.Pi / 6)
+//		b.Use("tick", f64.Aff3{cos, -sin, 0, sin, cos, 0})
+//	}
+func (b *Builder) DefineSymbol(name string, shapes []Shape) *Builder {
+	if b.g.Symbols == nil {
+		b.g.Symbols = map[string][]Shape{}
+	}
+	b.g.Symbols[name] = shapes
+	return b
+}
+
+// Use adds a Shape that draws the named Symbol, transformed by m, at the
+// current level of detail. It does not fill the current path.
+func (b *Builder) Use(name string, m f64.Aff3) *Builder {
+	b.g.Shapes = append(b.g.Shapes, Shape{
+		LOD0: b.lod0,
+		LOD1: b.lod1,
+		Use:  &Use{Symbol: name, Transform: m},
+	})
+	return b
+}
+
+// Graphic returns the Graphic built so far. Any unfilled path is not part of
+// the result.
+func (b *Builder) Graphic() *Graphic {
+	g := b.g
+	g.Shapes = append([]Shape(nil), b.g.Shapes...)
+	if b.g.Symbols != nil {
+		g.Symbols = make(map[string][]Shape, len(b.g.Symbols))
+		for name, shapes := range b.g.Symbols {
+			g.Symbols[name] = shapes
+		}
+	}
+	return &g
+}
+
+// Bytes encodes the Graphic built so far.
+func (b *Builder) Bytes() ([]byte, error) {
+	return Encode(&b.g)
+}
+
+// Pather is the path construction interface of the golang.org/x/image/vector
+// package's Rasterizer. Code that draws paths through a Pather can target
+// either a Rasterizer or, via a Builder's Pather method, an IconVG graphic.
+type Pather interface {
+	MoveTo(ax, ay float32)
+	LineTo(bx, by float32)
+	QuadTo(bx, by, cx, cy float32)
+	CubeTo(bx, by, cx, cy, dx, dy float32)
+	ClosePath()
+}
+
+// Pather returns a Pather that adds its path segments to b's current path. It
+// can convert font glyphs, such as those loaded by the
+// golang.org/x/image/font/sfnt package, to IconVG. Like IconVG, sfnt's y axis
+// increases downwards, and its coordinates are fixed.Point26_6 values, in
+// pixels:
+//
+//	p := b.Pather()
+//	for _, seg := range segments {
+//		a := seg.Args
+//		switch seg.Op {
+//		case sfnt.SegmentOpMoveTo:
+//			p.MoveTo(float32(a[0].X)/64, float32(a[0].Y)/64)
+//		case sfnt.SegmentOpLineTo:
+//			p.LineTo(float32(a[0].X)/64, float32(a[0].Y)/64)
+//		case sfnt.SegmentOpQuadTo:
+//			p.QuadTo(float32(a[0].X)/64, float32(a[0].Y)/64,
+//				float32(a[1].X)/64, float32(a[1].Y)/64)
+//		case sfnt.SegmentOpCubeTo:
+//			p.CubeTo(float32(a[0].X)/64, float32(a[0].Y)/64,
+//				float32(a[1].X)/64, float32(a[1].Y)/64,
+//				float32(a[2].X)/64, float32(a[2].Y)/64)
+//		}
+//	}
+//	b.Fill(lowlevel.PaletteIndexColor(0))
+//
+// A glyph's contours are implicitly closed, which suits IconVG: a Path's
+// sub-paths are filled as if they were closed.
+func (b *Builder) Pather() Pather {
+	return builderPather{b}
+}
+
+// builderPather adapts a Builder, whose methods return the Builder for
+// chaining, to the Pather interface, whose methods return nothing.
+type builderPather struct {
+	b *Builder
+}
+
+func (p builderPather) MoveTo(ax, ay float32)         { p.b.MoveTo(ax, ay) }
+func (p builderPather) LineTo(bx, by float32)         { p.b.LineTo(bx, by) }
+func (p builderPather) QuadTo(bx, by, cx, cy float32) { p.b.QuadTo(bx, by, cx, cy) }
+func (p builderPather) ClosePath()                    { p.b.ClosePath() }
+
+func (p builderPather) CubeTo(bx, by, cx, cy, dx, dy float32) {
+	p.b.CubeTo(bx, by, cx, cy, dx, dy)
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/vector"
+)
+
+// A vector.Rasterizer is a Pather.
+var _ ivg.Pather = (*vector.Rasterizer)(nil)
+
+func TestPather(t *testing.T) {
+	testCases := []struct {
+		desc string
+		draw func(p ivg.Pather)
+		want ivg.Path
+	}{{
+		desc: "lines",
+		draw: func(p ivg.Pather) {
+			p.MoveTo(1, 2)
+			p.LineTo(3, 4)
+			p.LineTo(5, 2)
+			p.ClosePath()
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{1, 2}},
+			ivg.LineTo{To: f32.Vec2{3, 4}},
+			ivg.LineTo{To: f32.Vec2{5, 2}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "curves",
+		draw: func(p ivg.Pather) {
+			p.MoveTo(0, 0)
+			p.QuadTo(1, 2, 3, 0)
+			p.CubeTo(4, 1, 5, 1, 6, 0)
+			p.ClosePath()
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{1, 2}, To: f32.Vec2{3, 0}},
+			ivg.CubeTo{Ctrl0: f32.Vec2{4, 1}, Ctrl1: f32.Vec2{5, 1}, To: f32.Vec2{6, 0}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "two contours",
+		draw: func(p ivg.Pather) {
+			p.MoveTo(0, 0)
+			p.LineTo(1, 0)
+			p.LineTo(1, 1)
+			p.MoveTo(4, 4)
+			p.LineTo(5, 4)
+			p.LineTo(5, 5)
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 1}},
+			ivg.MoveTo{To: f32.Vec2{4, 4}},
+			ivg.LineTo{To: f32.Vec2{5, 4}},
+			ivg.LineTo{To: f32.Vec2{5, 5}},
+		},
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.draw(b.Pather())
+		g := b.Fill(lowlevel.PaletteIndexColor(0)).Graphic()
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: got %d shapes, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		if got := g.Shapes[0].Path; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
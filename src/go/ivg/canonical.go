@@ -0,0 +1,62 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"bytes"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// maxCanonicalizePasses bounds the decode and re-encode passes that
+// Canonicalize makes. Re-encoding an encoding is almost always a no-op, but
+// lossy coordinate encodings can occasionally take a second pass to settle.
+const maxCanonicalizePasses = 4
+
+// Canonicalize re-encodes the IconVG graphic src into a canonical form, so
+// that graphics that decode to the same shapes, paints and path segments
+// encode to the same bytes, however they were originally encoded. This suits
+// content-addressed storage and byte-level diffs.
+//
+// The canonical form is what the default Encoder (without Optimize) produces
+// from the Graphic that src decodes to, with two refinements:
+//
+//   - colors that refer to the custom palette stay palette references, so
+//     that the canonical form can still be themed. Blends that involve the
+//     custom palette, which are rare, are resolved to the suggested palette.
+//   - the decode and re-encode is repeated until the output is stable, so
+//     that Canonicalize is idempotent.
+//
+// Register allocation is therefore fixed: a paint is loaded into CREG[0] and a
+// gradient's transformation and stops are loaded into NREG[4 .. 9] and into
+// CREG and NREG[10 .. 10+NSTOPS-1]. Changes to
+// the default Encoder that would change its output must preserve this
+// function's output, as callers may persist it.
+func Canonicalize(src []byte) ([]byte, error) {
+	for pass := 0; ; pass++ {
+		d := &decoder{keepPalette: true}
+		if err := lowlevel.Decode(d, src, nil); err != nil {
+			return nil, err
+		}
+		dst, err := Encode(&d.g)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(dst, src) || (pass+1 == maxCanonicalizePasses) {
+			return dst, nil
+		}
+		src = dst
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/render"
+)
+
+func TestCanonicalize(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		canon, err := ivg.Canonicalize(src)
+		if err != nil {
+			t.Errorf("%s: Canonicalize: %v", tc, err)
+			continue
+		}
+		again, err := ivg.Canonicalize(canon)
+		if err != nil {
+			t.Errorf("%s: Canonicalize (again): %v", tc, err)
+			continue
+		}
+		if !bytes.Equal(again, canon) {
+			t.Errorf("%s: Canonicalize is not idempotent", tc)
+		}
+		checkSameRendering(t, tc, canon, src, 1)
+
+	}
+}
+
+func TestCanonicalizeEquivalentEncodings(t *testing.T) {
+	// Each test case lists encodings that decode to the same shapes, paints
+	// and path segments, and so must have the same canonical form.
+	testCases := []struct {
+		desc string
+		srcs []string
+	}{{
+		desc: "line opcodes",
+		srcs: []string{
+			"path [csel] 0 0\nL 10 0 10 10\nz",
+			"path [csel] 0 0\nl 10 0 0 10\nz",
+			"path [csel] 0 0\nH 10\nV 10\nz",
+			"path [csel] 0:4 0:4\nL 10:4 0:4\nL 10:4 10:4\nz",
+		},
+	}, {
+		desc: "color registers",
+		srcs: []string{
+			"creg.4 [csel] #ff0000ff\npath [csel] 0 0\nL 10 0 10 10\nz",
+			"creg.3 [csel] #ff0000\npath [csel] 0 0\nL 10 0 10 10\nz",
+			"csel 5\ncreg.3 [csel-1] #ff0000\npath [csel-1] 0 0\nL 10 0 10 10\nz",
+		},
+	}}
+	for _, tc := range testCases {
+		var want []byte
+		for i, s := range tc.srcs {
+			src, err := ivgasm.Assemble([]byte("magic\nmetadata 0\n" + s))
+			if err != nil {
+				t.Fatalf("%s #%d: Assemble: %v", tc.desc, i, err)
+			}
+			got, err := ivg.Canonicalize(src)
+			if err != nil {
+				t.Errorf("%s #%d: Canonicalize: %v", tc.desc, i, err)
+				continue
+			}
+			if i == 0 {
+				want = got
+			} else if !bytes.Equal(got, want) {
+				t.Errorf("%s #%d: got % x, want % x", tc.desc, i, got, want)
+			}
+		}
+	}
+}
+
+func TestCanonicalizeKeepsPalette(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	canon, err := ivg.Canonicalize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// action-info.lores is painted with custom palette entry 0. At size 48,
+	// (24, 6) is inside of its circle.
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	m, err := render.Image(canon, 48, &render.Options{Palette: map[uint8]color.RGBA{0: red}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.RGBAAt(24, 6); got != red {
+		t.Errorf("got %v, want %v", got, red)
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"bad magic", []byte("\x8a\x49\x56\x48\x00")},
+	}
+	for _, tc := range testCases {
+		if _, err := ivg.Canonicalize(tc.src); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import "math"
+
+// dashes is a normalized dash pattern: an even number of non-negative
+// lengths, alternating between dashes and gaps, that sum to a positive total.
+// i and rem are where the pattern starts: the index of the current dash or
+// gap and how much of it remains.
+type dashes struct {
+	lengths []float64
+	i       int
+	rem     float64
+}
+
+// makeDashes returns the dash pattern for an SVG-like dash array and offset,
+// or nil if the stroke is solid.
+func makeDashes(dashArray []float32, dashOffset float32) *dashes {
+	if len(dashArray) == 0 {
+		return nil
+	}
+	lengths, total := make([]float64, 0, 2*len(dashArray)), 0.0
+	for _, x := range dashArray {
+		f := float64(x)
+		if !(f >= 0) || math.IsInf(f, 0) {
+			return nil
+		}
+		lengths = append(lengths, f)
+		total += f
+	}
+	if len(lengths)%2 != 0 {
+		lengths = append(lengths, lengths...)
+		total *= 2
+	}
+	if !(total > 0) || math.IsInf(total, 0) {
+		return nil
+	}
+
+	off := 0.0
+	if o := float64(dashOffset); !math.IsNaN(o) && !math.IsInf(o, 0) {
+		off = math.Mod(o, total)
+		if off < 0 {
+			off += total
+		}
+	}
+	// Skip the dashes and gaps that end before off, or at off, but not a
+	// zero length dash at off, which still draws a dot.
+	i := 0
+	for (off > lengths[i]) || ((off == lengths[i]) && (lengths[i] > 0)) {
+		off -= lengths[i]
+		i = (i + 1) % len(lengths)
+		if (i == 0) && (off >= total) {
+			// Rounding error: off is within an ulp of total.
+			off = 0
+		}
+	}
+	return &dashes{lengths: lengths, i: i, rem: lengths[i] - off}
+}
+
+// split splits each polyline into its dashes, as open polylines. A dash's
+// polyline has one point if and only if the dash has zero length.
+func (d *dashes) split(pls []polyline) []polyline {
+	ret := []polyline(nil)
+	for _, pl := range pls {
+		if len(pl.pts) < 2 {
+			ret = append(ret, pl)
+			continue
+		}
+		pts := pl.pts
+		if pl.closed {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+
+		i, rem := d.i, d.rem
+		var cur []point
+		add := func(q point) {
+			if n := len(cur); (n == 0) || (cur[n-1] != q) {
+				cur = append(cur, q)
+			}
+		}
+		if i%2 == 0 {
+			add(pts[0])
+		}
+		split := false
+		for j := 1; j < len(pts); j++ {
+			a, b := pts[j-1], pts[j]
+			length := math.Hypot(b.x-a.x, b.y-a.y)
+			t := 0.0
+			for length-t > rem {
+				t += rem
+				q := point{a.x + (b.x-a.x)*t/length, a.y + (b.y-a.y)*t/length}
+				add(q)
+				if i%2 == 0 {
+					ret = append(ret, polyline{pts: cur})
+					cur = nil
+				}
+				i = (i + 1) % len(d.lengths)
+				rem, split = d.lengths[i], true
+			}
+			rem -= length - t
+			if i%2 == 0 {
+				add(b)
+			}
+		}
+
+		if !split && (i%2 == 0) {
+			// The whole sub-path is within one dash.
+			ret = append(ret, pl)
+		} else if len(cur) > 1 {
+			ret = append(ret, polyline{pts: cur})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"image/color"
+	"io"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// Decode decodes an IconVG graphic.
+//
+// opts may be nil, which means to use the default options.
+func Decode(src []byte, opts *lowlevel.DecodeOptions) (*Graphic, error) {
+	d := &decoder{}
+	if err := lowlevel.Decode(d, src, opts); err != nil {
+		return nil, err
+	}
+	return &d.g, nil
+}
+
+// DecodeThemable is like Decode but, like Canonicalize, it keeps colors that
+// refer to the custom palette as lowlevel.PaletteIndexColor values, instead
+// of resolving them, so that re-encoding the Graphic gives a graphic that can
+// still be themed.
+func DecodeThemable(src []byte) (*Graphic, error) {
+	d := &decoder{keepPalette: true}
+	if err := lowlevel.Decode(d, src, nil); err != nil {
+		return nil, err
+	}
+	return &d.g, nil
+}
+
+// DecodeReader is like Decode but reads the IconVG graphic from r.
+func DecodeReader(r io.Reader, opts *lowlevel.DecodeOptions) (*Graphic, error) {
+	d := &decoder{}
+	if err := lowlevel.NewStreamDecoder(r).Decode(d, opts); err != nil {
+		return nil, err
+	}
+	return &d.g, nil
+}
+
+// decoder is a lowlevel.Destination that builds a Graphic. It executes the
+// virtual machine's register operations and converts relative, smooth and
+// horizontal or vertical drawing ops to absolute Segments.
+type decoder struct {
+	g Graphic
+
+	cSel uint8
+	nSel uint8
+	cReg [64]color.RGBA
+	nReg [64]float32
+	lod0 float32
+	lod1 float32
+	rule lowlevel.FillRule
+
+	// keepPalette is whether flat colors and gradient stops that refer to the
+	// custom palette are kept as lowlevel.PaletteIndexColor values, instead
+	// of being resolved to RGBA values. If so, cRegColors holds the
+	// unresolved color of each CREG register.
+	keepPalette bool
+	cRegColors  [64]lowlevel.Color
+
+	paint Paint
+	path  Path
+
+	// pen is the current point. smooth is the implicit control point for a
+	// subsequent smooth quadTo or cubeTo: the reflection of the previous
+	// segment's final control point, or the pen if that segment was not a
+	// Bézier curve. This matches the C implementation.
+	pen    f32.Vec2
+	smooth f32.Vec2
+}
+
+// SetFillRule implements lowlevel.FillRuleDestination, so that decoding with
+// a DecodeOptions.FillRule sets each Shape's FillRule.
+func (d *decoder) SetFillRule(r lowlevel.FillRule) {
+	d.rule = r
+}
+
+func (d *decoder) Reset(m lowlevel.Metadata) {
+	d.g = Graphic{Metadata: m}
+	d.cSel = 0
+	d.nSel = 0
+	d.cReg = m.Palette
+	d.nReg = [64]float32{}
+	d.lod0 = DefaultLOD0
+	d.lod1 = DefaultLOD1
+	if d.keepPalette {
+		// Each CREG register starts as the custom palette's same-numbered
+		// entry, so that a graphic can be themed without any SetCReg.
+		for i := range d.cRegColors {
+			d.cRegColors[i] = lowlevel.PaletteIndexColor(uint8(i))
+		}
+	}
+}
+
+func (d *decoder) SetCSel(cSel uint8) { d.cSel = cSel & 0x3f }
+func (d *decoder) SetNSel(nSel uint8) { d.nSel = nSel & 0x3f }
+
+func (d *decoder) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	if d.keepPalette {
+		d.cRegColors[(d.cSel-adj)&0x3f] = d.unresolvedColor(c)
+	}
+	d.cReg[(d.cSel-adj)&0x3f] = c.Resolve(&d.g.Metadata.Palette, &d.cReg)
+	if incr {
+		d.cSel = (d.cSel + 1) & 0x3f
+	}
+}
+
+func (d *decoder) SetNReg(adj uint8, incr bool, f float32) {
+	d.nReg[(d.nSel-adj)&0x3f] = f
+	if incr {
+		d.nSel = (d.nSel + 1) & 0x3f
+	}
+}
+
+func (d *decoder) SetLOD(lod0, lod1 float32) {
+	d.lod0, d.lod1 = lod0, lod1
+}
+
+func (d *decoder) StartPath(adj uint8, x, y float32) {
+	d.paint = d.resolvePaint(d.cReg[(d.cSel-adj)&0x3f], (d.cSel-adj)&0x3f)
+	d.path = nil
+	d.moveTo(f32.Vec2{x, y})
+}
+
+// unresolvedColor returns the color to record in cRegColors when c is loaded
+// into a CREG register. Direct and palette index colors are kept as is. CREG
+// colors are replaced by that register's unresolved color. Blended colors are
+// resolved, as their blend of the custom palette cannot, in general, be
+// expressed as a single Color.
+func (d *decoder) unresolvedColor(c lowlevel.Color) lowlevel.Color {
+	switch c.Type() {
+	case lowlevel.ColorTypeRGBA, lowlevel.ColorTypePaletteIndex:
+		return c
+	case lowlevel.ColorTypeCReg:
+		i, _ := c.CReg()
+		return d.cRegColors[i&0x3f]
+	}
+	return lowlevel.RGBAColor(c.Resolve(&d.g.Metadata.Palette, &d.cReg))
+}
+
+// resolvePaint converts a CREG value, which is either a flat color or a
+// gradient, to a Paint. cReg is the register that holds it.
+func (d *decoder) resolvePaint(rgba color.RGBA, cReg uint8) Paint {
+	if (rgba.A != 0) || (rgba.B&0x80 == 0) {
+		if d.keepPalette {
+			return Paint{Color: d.cRegColors[cReg]}
+		}
+		return Paint{Color: lowlevel.RGBAColor(rgba)}
+	}
+
+	nStops := int(rgba.R & 0x3f)
+	cBase := rgba.G & 0x3f
+	nBase := rgba.B & 0x3f
+	g := &Gradient{
+		Shape:  GradientShape((rgba.B >> 6) & 0x01),
+		Spread: GradientSpread(rgba.G >> 6),
+		Stops:  make([]GradientStop, nStops),
+	}
+	for i := range g.Transform {
+		g.Transform[i] = d.nReg[(nBase-6+uint8(i))&0x3f]
+	}
+	for i := range g.Stops {
+		j := (cBase + uint8(i)) & 0x3f
+		g.Stops[i] = GradientStop{
+			Offset: d.nReg[(nBase+uint8(i))&0x3f],
+			Color:  lowlevel.RGBAColor(d.cReg[j]),
+		}
+		if d.keepPalette {
+			g.Stops[i].Color = d.cRegColors[j]
+		}
+	}
+	return Paint{Gradient: g}
+}
+
+func (d *decoder) ClosePathEndPath() {
+	d.path = append(d.path, ClosePath{})
+	d.g.Shapes = append(d.g.Shapes, Shape{
+		Paint:    d.paint,
+		Path:     d.path,
+		LOD0:     d.lod0,
+		LOD1:     d.lod1,
+		FillRule: d.rule,
+	})
+	d.paint = Paint{}
+	d.path = nil
+}
+
+func (d *decoder) ClosePathAbsMoveTo(x, y float32) {
+	d.path = append(d.path, ClosePath{})
+	d.moveTo(f32.Vec2{x, y})
+}
+
+func (d *decoder) ClosePathRelMoveTo(x, y float32) {
+	d.path = append(d.path, ClosePath{})
+	d.moveTo(d.rel(x, y))
+}
+
+func (d *decoder) AbsHLineTo(x float32) { d.lineTo(f32.Vec2{x, d.pen[1]}) }
+func (d *decoder) RelHLineTo(x float32) { d.lineTo(f32.Vec2{d.pen[0] + x, d.pen[1]}) }
+func (d *decoder) AbsVLineTo(y float32) { d.lineTo(f32.Vec2{d.pen[0], y}) }
+func (d *decoder) RelVLineTo(y float32) { d.lineTo(f32.Vec2{d.pen[0], d.pen[1] + y}) }
+
+func (d *decoder) AbsLineTo(x, y float32) { d.lineTo(f32.Vec2{x, y}) }
+func (d *decoder) RelLineTo(x, y float32) { d.lineTo(d.rel(x, y)) }
+
+func (d *decoder) AbsSmoothQuadTo(x, y float32) { d.quadTo(d.smooth, f32.Vec2{x, y}) }
+func (d *decoder) RelSmoothQuadTo(x, y float32) { d.quadTo(d.smooth, d.rel(x, y)) }
+
+func (d *decoder) AbsQuadTo(x1, y1, x, y float32) { d.quadTo(f32.Vec2{x1, y1}, f32.Vec2{x, y}) }
+func (d *decoder) RelQuadTo(x1, y1, x, y float32) { d.quadTo(d.rel(x1, y1), d.rel(x, y)) }
+
+func (d *decoder) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	d.cubeTo(d.smooth, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (d *decoder) RelSmoothCubeTo(x2, y2, x, y float32) {
+	d.cubeTo(d.smooth, d.rel(x2, y2), d.rel(x, y))
+}
+
+func (d *decoder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	d.cubeTo(f32.Vec2{x1, y1}, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (d *decoder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	d.cubeTo(d.rel(x1, y1), d.rel(x2, y2), d.rel(x, y))
+}
+
+func (d *decoder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	d.arcTo(rx, ry, xAxisRotation, largeArc, sweep, f32.Vec2{x, y})
+}
+
+func (d *decoder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	d.arcTo(rx, ry, xAxisRotation, largeArc, sweep, d.rel(x, y))
+}
+
+func (d *decoder) rel(x, y float32) f32.Vec2 {
+	return f32.Vec2{d.pen[0] + x, d.pen[1] + y}
+}
+
+func (d *decoder) moveTo(p f32.Vec2) {
+	d.path = append(d.path, MoveTo{To: p})
+	d.pen, d.smooth = p, p
+}
+
+func (d *decoder) lineTo(p f32.Vec2) {
+	d.path = append(d.path, LineTo{To: p})
+	d.pen, d.smooth = p, p
+}
+
+func (d *decoder) quadTo(c, p f32.Vec2) {
+	d.path = append(d.path, QuadTo{Ctrl: c, To: p})
+	d.pen, d.smooth = p, reflect(c, p)
+}
+
+func (d *decoder) cubeTo(c0, c1, p f32.Vec2) {
+	d.path = append(d.path, CubeTo{Ctrl0: c0, Ctrl1: c1, To: p})
+	d.pen, d.smooth = p, reflect(c1, p)
+}
+
+func (d *decoder) arcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, p f32.Vec2) {
+	d.path = append(d.path, ArcTo{
+		Radii:         f32.Vec2{rx, ry},
+		XAxisRotation: xAxisRotation,
+		LargeArc:      largeArc,
+		Sweep:         sweep,
+		To:            p,
+	})
+	d.pen, d.smooth = p, p
+}
+
+// reflect returns the reflection of the control point c through p.
+func reflect(c, p f32.Vec2) f32.Vec2 {
+	return f32.Vec2{2*p[0] - c[0], 2*p[1] - c[1]}
+}
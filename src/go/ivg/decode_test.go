@@ -0,0 +1,178 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+func TestDecodeReader(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient-spreads.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc, err)
+			continue
+		}
+		got, err := ivg.DecodeReader(iotest.OneByteReader(bytes.NewReader(src)), nil)
+		if err != nil {
+			t.Errorf("%s: DecodeReader: %v", tc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: DecodeReader and Decode disagree", tc)
+		}
+	}
+
+	if _, err := ivg.DecodeReader(bytes.NewReader([]byte("not IconVG")), nil); err == nil {
+		t.Errorf("bad magic: got nil error, want non-nil")
+	}
+}
+
+func TestDecodeSegments(t *testing.T) {
+	// The Builder's coordinates are all exactly representable, so that the
+	// decoded Path, whose segments are absolute, matches them exactly.
+	src, err := ivg.NewBuilder().
+		MoveTo(-20, -20).
+		LineTo(10, -20).
+		QuadTo(20, -20, 20, -10).
+		CubeTo(20, 0, 10, 10, 0, 10).
+		ArcTo(10, 5, 0.25, false, true, -20, 0).
+		ClosePath().
+		Fill(lowlevel.PaletteIndexColor(0)).
+		Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{-20, -20}},
+		ivg.LineTo{To: f32.Vec2{10, -20}},
+		ivg.QuadTo{Ctrl: f32.Vec2{20, -20}, To: f32.Vec2{20, -10}},
+		ivg.CubeTo{Ctrl0: f32.Vec2{20, 0}, Ctrl1: f32.Vec2{10, 10}, To: f32.Vec2{0, 10}},
+		ivg.ArcTo{Radii: f32.Vec2{10, 5}, XAxisRotation: 0.25, Sweep: true, To: f32.Vec2{-20, 0}},
+		ivg.ClosePath{},
+	}
+	if len(g.Shapes) != 1 {
+		t.Fatalf("got %d shapes, want 1", len(g.Shapes))
+	}
+	if got := g.Shapes[0].Path; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeEditEncode(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(g.Shapes)
+
+	// Recolor the first shape, move the second and drop the last.
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	g.Shapes[0].Paint = ivg.Paint{Color: red}
+	p := g.Shapes[1].Path
+	if mt, ok := p[0].(ivg.MoveTo); ok {
+		p[0] = ivg.MoveTo{To: f32.Vec2{mt.To[0] + 1, mt.To[1]}}
+	} else {
+		t.Fatalf("got %T first segment, want MoveTo", p[0])
+	}
+	g.Shapes = g.Shapes[:n-1]
+
+	edited, err := ivg.Encode(g)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	h, err := ivg.Decode(edited, nil)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(h.Shapes) != n-1 {
+		t.Fatalf("got %d shapes, want %d", len(h.Shapes), n-1)
+	}
+	if got := h.Shapes[0].Paint; (got.Gradient != nil) || (got.Color != red) {
+		t.Errorf("paint: got %v, want %v", got, red)
+	}
+	if got, want := h.Shapes[1].Path[0], p[0]; got != want {
+		t.Errorf("first segment: got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeThemable(t *testing.T) {
+	// action-info.lores is painted with custom palette entry 0, which is
+	// opaque black by default.
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc   string
+		decode func([]byte) (*ivg.Graphic, error)
+		want   lowlevel.Color
+	}{
+		{"Decode", func(src []byte) (*ivg.Graphic, error) { return ivg.Decode(src, nil) },
+			lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+		{"DecodeThemable", ivg.DecodeThemable, lowlevel.PaletteIndexColor(0)},
+	}
+	for _, tc := range testCases {
+		g, err := tc.decode(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: got %d shapes, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		if got := g.Shapes[0].Paint.Color; got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+
+	if _, err := ivg.DecodeThemable([]byte("not IconVG")); err == nil {
+		t.Errorf("bad magic: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/image/math/f32"
+)
+
+// subPath is a sub-path, which starts with a MoveTo, of a Shape being
+// deduplicated: its segments, their bounds and its key.
+type subPath struct {
+	path Path
+	b    bounds
+	key  string
+}
+
+// dedupShape is a Shape being deduplicated, as its sub-paths.
+type dedupShape struct {
+	Shape
+	subPaths []subPath
+	keys     map[string]bool
+}
+
+// dedupSubPaths returns shapes with repeated sub-paths gathered, as per the
+// Encoder's DedupSubPaths option. It does not modify shapes or their paths.
+func dedupSubPaths(shapes []Shape) []Shape {
+	ds := make([]dedupShape, 0, len(shapes))
+	counts := map[string]int{}
+	for _, s := range shapes {
+		d := dedupShape{Shape: s}
+		if p := cleanPath(s.Path); len(p) > 0 {
+			if _, ok := p[0].(MoveTo); ok {
+				d.subPaths = splitSubPaths(p)
+				for _, sp := range d.subPaths {
+					counts[sp.key]++
+				}
+			}
+		}
+		ds = append(ds, d)
+	}
+
+	dst := make([]dedupShape, 0, len(ds))
+	for _, d := range ds {
+		if d.subPaths == nil {
+			dst = append(dst, d)
+			continue
+		}
+		kept := []subPath(nil)
+		for i, sp := range d.subPaths {
+			if counts[sp.key] > 1 && moveSubPath(dst, &d.Shape, d.subPaths, i) {
+				continue
+			}
+			kept = append(kept, sp)
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		d.subPaths, d.keys = kept, map[string]bool{}
+		for _, sp := range kept {
+			d.keys[sp.key] = true
+		}
+		dst = append(dst, d)
+	}
+
+	ret := make([]Shape, len(dst))
+	for i, d := range dst {
+		ret[i] = d.Shape
+		if d.subPaths != nil {
+			p := Path(nil)
+			for _, sp := range d.subPaths {
+				p = append(p, sp.path...)
+			}
+			ret[i].Path = p
+		}
+	}
+	return ret
+}
+
+// moveSubPath moves the sub-path ss[i], of the Shape s, to the latest Shape
+// in dst that can take it, if any, returning whether it did. Such a Shape
+// can be merged with s and has a sub-path with the same key. The sub-path
+// must be disjoint from s's other sub-paths, so that they do not cover each
+// other's pixels, and from every sub-path of dst that it moves past or
+// joins, so that painter's order is preserved.
+func moveSubPath(dst []dedupShape, s *Shape, ss []subPath, i int) bool {
+	sp := &ss[i]
+	for j, other := range ss {
+		if (j != i) && !sp.b.disjoint(other.b) {
+			return false
+		}
+	}
+	for j := len(dst) - 1; j >= 0; j-- {
+		d := &dst[j]
+		if d.subPaths == nil {
+			return false
+		}
+		for _, other := range d.subPaths {
+			if !sp.b.disjoint(other.b) {
+				return false
+			}
+		}
+		if d.keys[sp.key] && canMerge(&d.Shape, s) {
+			d.subPaths = append(d.subPaths, *sp)
+			return true
+		}
+	}
+	return false
+}
+
+// splitSubPaths splits p, which starts with a MoveTo and has no ClosePath
+// that is followed by anything other than a MoveTo, into its sub-paths.
+func splitSubPaths(p Path) []subPath {
+	ret := []subPath(nil)
+	for i := 0; i < len(p); {
+		j := i + 1
+		for ; j < len(p); j++ {
+			if _, ok := p[j].(MoveTo); ok {
+				break
+			}
+		}
+		q := p[i:j:j]
+		ret = append(ret, subPath{
+			path: q,
+			b:    pathBounds(q),
+			key:  subPathKey(q),
+		})
+		i = j
+	}
+	return ret
+}
+
+// subPathKey returns a key for the sub-path p, which starts with a MoveTo,
+// that is equal for sub-paths that are identical other than their position.
+func subPathKey(p Path) string {
+	origin := p[0].(MoveTo).To
+	rel := func(v f32.Vec2) f32.Vec2 {
+		return f32.Vec2{v[0] - origin[0], v[1] - origin[1]}
+	}
+	sb := strings.Builder{}
+	for _, seg := range p[1:] {
+		switch s := seg.(type) {
+		case LineTo:
+			s.To = rel(s.To)
+			seg = s
+		case QuadTo:
+			s.Ctrl, s.To = rel(s.Ctrl), rel(s.To)
+			seg = s
+		case CubeTo:
+			s.Ctrl0, s.Ctrl1, s.To = rel(s.Ctrl0), rel(s.Ctrl1), rel(s.To)
+			seg = s
+		case ArcTo:
+			s.To = rel(s.To)
+			seg = s
+		}
+		fmt.Fprintf(&sb, "%T%v;", seg, seg)
+	}
+	return sb.String()
+}
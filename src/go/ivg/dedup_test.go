@@ -0,0 +1,117 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDedupSubPaths(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	blue := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})
+	square := func(b *ivg.Builder, x, y float32) *ivg.Builder {
+		return b.MoveTo(x, y).LineTo(x+8, y).LineTo(x+8, y+8).LineTo(x, y+8).ClosePath()
+	}
+	triangle := func(b *ivg.Builder, x, y float32) *ivg.Builder {
+		return b.MoveTo(x, y).LineTo(x+8, y).LineTo(x, y+8).ClosePath()
+	}
+	testCases := []struct {
+		desc       string
+		build      func(b *ivg.Builder)
+		wantShapes int
+		wantSaved  bool
+	}{{
+		desc: "repeat past a disjoint shape",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			triangle(b, -4, -4).Fill(blue)
+			square(b, 10, 10).Fill(red)
+		},
+		wantShapes: 2,
+		wantSaved:  true,
+	}, {
+		desc: "repeat past an overlapping shape",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			triangle(b, 8, 8).Fill(blue)
+			square(b, 10, 10).Fill(red)
+		},
+		wantShapes: 3,
+		wantSaved:  false,
+	}, {
+		desc: "no repeats",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			triangle(b, -4, -4).Fill(blue)
+			triangle(b, 10, 10).Fill(red)
+		},
+		wantShapes: 3,
+		wantSaved:  false,
+	}, {
+		desc: "repeat with a different paint",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			triangle(b, -4, -4).Fill(blue)
+			square(b, 10, 10).Fill(blue)
+		},
+		wantShapes: 3,
+		wantSaved:  false,
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.build(b)
+		g := b.Graphic()
+		plain, err := (&ivg.Encoder{}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		e := &ivg.Encoder{DedupSubPaths: true}
+		deduped, err := e.Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got, want := e.BytesSaved(), len(plain)-len(deduped); got != want {
+			t.Errorf("%s: BytesSaved: got %d, want %d", tc.desc, got, want)
+		}
+		if gotSaved := e.BytesSaved() > 0; gotSaved != tc.wantSaved {
+			t.Errorf("%s: BytesSaved: got %d, want non-zero %t", tc.desc, e.BytesSaved(), tc.wantSaved)
+		}
+		if !tc.wantSaved && !bytes.Equal(deduped, plain) {
+			t.Errorf("%s: got %x, want the plain encoding %x", tc.desc, deduped, plain)
+		}
+		h, err := ivg.Decode(deduped, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := len(h.Shapes); got != tc.wantShapes {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, got, tc.wantShapes)
+		}
+		checkSameRendering(t, tc.desc, deduped, plain, 0)
+
+		// BytesSaved is reset by the next Encode.
+		e.DedupSubPaths = false
+		if _, err := e.Encode(g); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := e.BytesSaved(); got != 0 {
+			t.Errorf("%s: BytesSaved without DedupSubPaths: got %d, want 0", tc.desc, got)
+		}
+	}
+}
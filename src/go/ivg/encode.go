@@ -0,0 +1,687 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	errPathDoesNotStartWithMoveTo = errors.New("iconvg: path does not start with a MoveTo")
+	errTooManyGradientStops       = errors.New("iconvg: too many gradient stops")
+	errUnsupportedSegment         = errors.New("iconvg: unsupported path segment")
+)
+
+// gradientBase is the CBASE and NBASE used for gradients. The gradient's
+// transformation matrix occupies NREG[gradientBase-6 .. gradientBase-1] and
+// its stops occupy CREG and NREG[gradientBase .. gradientBase+NSTOPS-1].
+// CREG[0] holds the paint itself.
+const gradientBase = 10
+
+// MaxGradientStops is the maximum number of stops in an encodable Gradient,
+// unless the Encoder's ResampleGradients option is set.
+const MaxGradientStops = 64 - gradientBase
+
+// Encoder encodes Graphics as IconVG byte code.
+//
+// It chooses the shortest encoding for each color and path segment: whether
+// to use absolute or relative coordinates, horizontal or vertical lineTo ops
+// and smooth quadTo or cubeTo ops (whose first control point is implicit).
+//
+// IconVG byte code fills every path with the non-zero rule, so a Shape whose
+// FillRule is even-odd is encoded as its EvenOddToNonZero equivalent. No
+// version of the file format can call or jump to shared drawing ops, so a
+// Shape with a Use is encoded as its Symbol's Shapes, as per Graphic.Expand.
+// The DedupSubPaths option can then gather the copies into fewer Shapes.
+type Encoder struct {
+	// Optimize enables an optimization pass that makes the encoding smaller,
+	// at the cost of encoding time, without changing how it renders:
+	//
+	//   - colors and gradients are loaded into several CREG registers, so
+	//     that a repeated paint can refer to a register that already holds
+	//     it, and registers already holding a gradient stop or number are not
+	//     loaded again.
+	//   - path segments that draw nothing, such as zero-length lineTo's and
+	//     lineTo's back to the start of a closed sub-path, are dropped.
+	//   - consecutive Shapes with the same paint, level of detail and fill
+	//     rule are merged into one, if their bounding boxes are disjoint. The merged
+	//     Shape renders the same, other than in anti-aliased pixels where the
+	//     original Shapes nearly touch.
+	//
+	// The decoded Graphic may therefore have fewer Shapes, and fewer path
+	// segments, than the encoded one.
+	Optimize bool
+
+	// LowerArcs replaces every ArcTo segment by CubeTo segments (see
+	// ArcTo.Cubics), for decoders that do not implement the arcTo opcodes.
+	// Every version of the file format has those opcodes, so by default,
+	// ArcTo segments are encoded natively.
+	//
+	// ArcTolerance is the maximum distance, in graphic coordinate space,
+	// between a lowered arc and the true arc. If zero, each CubeTo spans at
+	// most a quarter turn, like the rasterizers' own approximation.
+	LowerArcs    bool
+	ArcTolerance float32
+
+	// DedupSubPaths enables a pass that gathers repeated sub-paths: those
+	// that are identical other than their position, such as the cells of a
+	// grid or pattern icon. IconVG byte code cannot refer back to an earlier
+	// sub-path, so each repeat is still encoded, but a repeat in a later
+	// Shape is moved into an earlier Shape, with the same paint, level of
+	// detail and fill rule, that has one. That saves the paint and the path
+	// start and end of every Shape that is emptied. A sub-path is only moved
+	// if its bounding box is disjoint from those of the sub-paths that it
+	// moves past or joins, so that the graphic renders the same, other than
+	// rounding errors.
+	//
+	// The pass costs two extra encodings, to measure its savings, which
+	// BytesSaved reports. If it saves nothing, its result is discarded.
+	DedupSubPaths bool
+
+	// DetailLevels, if positive, makes the encoding progressive, in that many
+	// detail levels (see lowlevel.Metadata.DetailLevels), so that decoding
+	// with a lowlevel.DecodeOptions MaxLOD gives a coarse approximation of
+	// the graphic. Shapes are reordered from coarse to fine, by the size of
+	// their bounding boxes relative to the viewBox: level 1 holds those that
+	// span at least half of it, level 2 those that span at least a quarter,
+	// and so on, with the last level holding the rest. A Shape is never moved
+	// past a Shape that it overlaps, so the graphic renders the same: a small
+	// Shape beneath a larger one is promoted to the larger one's level.
+	//
+	// If DetailLevels is zero, the encoding is not progressive, regardless
+	// of the Graphic's Metadata.DetailLevels.
+	DetailLevels int
+
+	// ResampleGradients makes a Gradient with more than MaxGradientStops
+	// stops encodable, instead of an error, by keeping only MaxGradientStops
+	// of its stops, chosen by ResampleStops. GradientError reports how much
+	// that changes the gradients' colors.
+	ResampleGradients bool
+
+	// maxError is the QuantizeCoordinates bound. worstError is the
+	// QuantizationError of the most recent call to Encode. bytesSaved is the
+	// BytesSaved of the most recent call to Encode. gradientError is the
+	// GradientError of the most recent call to Encode.
+	maxError      float32
+	worstError    float32
+	bytesSaved    int
+	gradientError float32
+}
+
+// QuantizeCoordinates makes Encode lossy. Each coordinate is encoded as the
+// cheapest (shortest) encoding that decodes to within maxError of it, instead
+// of the cheapest encoding that decodes to it exactly. For example, a
+// maxError of 1/128 lets a coordinate snap to a multiple of 1/64, which takes
+// 2 bytes instead of 4, and a maxError of 0.5 lets it snap to an integer,
+// which takes 1 byte.
+//
+// The bound is on each coordinate, in viewBox units, so that every point,
+// including implicit control points, moves by at most maxError horizontally
+// and at most maxError vertically. As a Bézier curve is a weighted average of
+// its points, no part of any such path segment moves by more than that
+// either. Arc segments' end points are encoded exactly, as moving an arc's
+// end point can change its shape by more than it moves the end point.
+//
+// A maxError of zero, the default, means lossless encoding.
+func (e *Encoder) QuantizeCoordinates(maxError float32) {
+	if !(maxError > 0) {
+		maxError = 0
+	}
+	e.maxError = maxError
+}
+
+// QuantizationError returns the worst-case error, over every coordinate
+// encoded by the most recent call to Encode: the largest difference between a
+// coordinate and what it decodes as. It is at most the QuantizeCoordinates
+// bound. For lossless encoding, it is zero unless a coordinate needed the
+// 4 byte encoding, which may lose a little precision.
+func (e *Encoder) QuantizationError() float32 {
+	return e.worstError
+}
+
+// BytesSaved returns how many bytes the DedupSubPaths pass saved, in the
+// most recent call to Encode. It is zero if that option is not set.
+func (e *Encoder) BytesSaved() int {
+	return e.bytesSaved
+}
+
+// GradientError returns the largest ResampleStops error, over every Gradient
+// that the ResampleGradients option resampled, in the most recent call to
+// Encode. It is zero if that option is not set or no Gradient needed it.
+func (e *Encoder) GradientError() float32 {
+	return e.gradientError
+}
+
+// Encode encodes g.
+func (e *Encoder) Encode(g *Graphic) ([]byte, error) {
+	x, err := e.encode(nil, g)
+	if err != nil {
+		return nil, err
+	}
+	return x.dst.Bytes()
+}
+
+// EncodeTo is like Encode but writes the encoding of g to w, instead of
+// returning it, so that streaming many Graphics into one archive needs no
+// intermediate copy of each. It returns the number of bytes written. If it
+// returns an error then w may have received part of the encoding.
+func (e *Encoder) EncodeTo(w io.Writer, g *Graphic) (int, error) {
+	x, err := e.encode(w, g)
+	if err != nil {
+		return 0, err
+	}
+	err = x.dst.Flush()
+	return x.dst.PredictedSize(), err
+}
+
+// encode encodes g to the returned encoder's dst, which writes to w if w is
+// non-nil.
+func (e *Encoder) encode(w io.Writer, g *Graphic) (*encoder, error) {
+	e.bytesSaved, e.gradientError = 0, 0
+	shapes, err := expandShapes(g.Shapes, g.Symbols, nil)
+	if err != nil {
+		return nil, err
+	}
+	shapes = lowerFocalGradients(shapes, &g.Metadata.Palette)
+	if e.ResampleGradients {
+		shapes, e.gradientError = resampleGradients(shapes, &g.Metadata.Palette)
+	}
+	if e.DedupSubPaths {
+		plain, err := e.encodeShapes(io.Discard, g.Metadata, shapes)
+		if err != nil {
+			return nil, err
+		}
+		deduped := dedupSubPaths(shapes)
+		x, err := e.encodeShapes(io.Discard, g.Metadata, deduped)
+		if err != nil {
+			return nil, err
+		}
+		if saved := plain.dst.PredictedSize() - x.dst.PredictedSize(); saved > 0 {
+			shapes, e.bytesSaved = deduped, saved
+		}
+	}
+	return e.encodeShapes(w, g.Metadata, shapes)
+}
+
+// encodeShapes is like encode but with the Graphic's metadata and shapes
+// passed separately.
+func (e *Encoder) encodeShapes(w io.Writer, m lowlevel.Metadata, shapes []Shape) (*encoder, error) {
+	x := &encoder{
+		lod0:         DefaultLOD0,
+		lod1:         DefaultLOD1,
+		maxError:     e.maxError,
+		lowerArcs:    e.LowerArcs,
+		arcTolerance: e.ArcTolerance,
+	}
+	e.worstError = 0
+	levels := [][]Shape{shapes}
+	m.DetailLevels = nil
+	if e.DetailLevels > 0 {
+		levels = detailLevels(shapes, m.ViewBox, e.DetailLevels)
+		m.DetailLevels = make([]uint32, len(levels))
+	}
+	if e.Optimize {
+		x.optimize = true
+		for i := range levels {
+			levels[i] = optimizeShapes(levels[i])
+			x.optimize = x.optimize && !usesCRegColors(levels[i])
+		}
+		x.nRegKnown = ^uint64(0)
+	}
+	x.dst.SetWriter(w)
+	x.dst.Reset(m)
+	for _, level := range levels {
+		for i := range level {
+			if err := x.encodeShape(&level[i]); err != nil {
+				return nil, err
+			}
+		}
+		if e.DetailLevels > 0 {
+			x.dst.EndDetailLevel()
+		}
+	}
+	e.worstError = x.worstError
+	return x, nil
+}
+
+// Reencode decodes the IconVG graphic src and encodes it again with e's
+// options, such as to optimize a graphic that another encoder wrote. Like
+// Canonicalize, and unlike Decode, it keeps colors that refer to the custom
+// palette as palette references, so that the result can still be themed.
+func (e *Encoder) Reencode(src []byte) ([]byte, error) {
+	d := &decoder{keepPalette: true}
+	if err := lowlevel.Decode(d, src, nil); err != nil {
+		return nil, err
+	}
+	return e.Encode(&d.g)
+}
+
+// Encode encodes g with the default Encoder options.
+func Encode(g *Graphic) ([]byte, error) {
+	return (&Encoder{}).Encode(g)
+}
+
+// encoder holds the state of a single call to Encoder.Encode. It tracks the
+// virtual machine state that a decoder will see, including the effect of
+// lossy coordinate encodings on the pen position.
+type encoder struct {
+	dst lowlevel.Encoder
+
+	lod0 float32
+	lod1 float32
+
+	// cReg0 is the value loaded into CREG[0], if cReg0Valid.
+	cReg0      lowlevel.Color
+	cReg0Valid bool
+
+	pen    f32.Vec2
+	smooth f32.Vec2
+	start  f32.Vec2
+
+	// lastOp is the mnemonic of the previous drawing op, if it was
+	// repeatable, so that ties can favor sharing its opcode.
+	lastOp byte
+
+	// maxError is the Encoder's QuantizeCoordinates bound. worstError is the
+	// largest error of any coordinate encoded so far.
+	maxError   float32
+	worstError float32
+
+	// lowerArcs and arcTolerance are the Encoder's LowerArcs and
+	// ArcTolerance.
+	lowerArcs    bool
+	arcTolerance float32
+
+	// The remaining fields track the register contents for the optimizing
+	// encodePaint. Bit i of cRegKnown or nRegKnown is set if CREG[i] or
+	// NREG[i] is known to hold cReg[i] or nReg[i]. slotUses records when
+	// each paint slot (see paintSlot) was last used.
+	optimize  bool
+	cSel      uint8
+	nSel      uint8
+	cReg      [64]lowlevel.Color
+	nReg      [64]float32
+	cRegKnown uint64
+	nRegKnown uint64
+	slotUses  [numPaintSlots]int
+	numUses   int
+}
+
+func (x *encoder) encodeShape(s *Shape) error {
+	if len(s.Path) == 0 {
+		return nil
+	}
+	m, ok := s.Path[0].(MoveTo)
+	if !ok {
+		return errPathDoesNotStartWithMoveTo
+	}
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		p := EvenOddToNonZero(s.Path, 0)
+		if len(p) == 0 {
+			return nil
+		}
+		m = p[0].(MoveTo)
+		s = &Shape{Paint: s.Paint, Path: p, LOD0: s.LOD0, LOD1: s.LOD1}
+	}
+
+	if (s.LOD0 != x.lod0) || (s.LOD1 != x.lod1) {
+		x.dst.SetLOD(s.LOD0, s.LOD1)
+		x.lod0, x.lod1 = s.LOD0, s.LOD1
+	}
+	adj, err := uint8(0), error(nil)
+	if x.optimize {
+		adj, err = x.encodePaintOptimized(&s.Paint)
+	} else {
+		err = x.encodePaint(&s.Paint)
+	}
+	if err != nil {
+		return err
+	}
+
+	start := candidate{op: 'M'}
+	x.pen = start.addCoords(x.maxError, nil, m.To)[0]
+	x.noteError(start.err)
+	x.dst.StartPath(adj, x.pen[0], x.pen[1])
+	x.smooth, x.start, x.lastOp = x.pen, x.pen, 0
+
+	closed := false
+	for _, seg := range s.Path[1:] {
+		switch seg := seg.(type) {
+		case MoveTo:
+			x.moveTo(seg.To)
+			closed = false
+			continue
+		case ClosePath:
+			closed = true
+			continue
+		}
+		if closed {
+			x.moveTo(x.start)
+			closed = false
+		}
+
+		switch seg := seg.(type) {
+		case LineTo:
+			x.lineTo(seg.To)
+		case QuadTo:
+			x.quadTo(seg.Ctrl, seg.To)
+		case CubeTo:
+			x.cubeTo(seg.Ctrl0, seg.Ctrl1, seg.To)
+		case ArcTo:
+			if !x.lowerArcs {
+				x.arcTo(&seg)
+				break
+			}
+			for _, low := range seg.Cubics(x.pen, x.arcTolerance) {
+				switch low := low.(type) {
+				case LineTo:
+					x.lineTo(low.To)
+				case CubeTo:
+					x.cubeTo(low.Ctrl0, low.Ctrl1, low.To)
+				}
+			}
+		default:
+			return errUnsupportedSegment
+		}
+	}
+	x.dst.ClosePathEndPath()
+	return nil
+}
+
+func (x *encoder) encodePaint(p *Paint) error {
+	if p.Gradient == nil {
+		if !x.cReg0Valid || (x.cReg0 != p.Color) {
+			x.dst.SetCReg(0, false, p.Color)
+			x.cReg0, x.cReg0Valid = p.Color, true
+		}
+		return nil
+	}
+
+	g := p.Gradient
+	if len(g.Stops) > MaxGradientStops {
+		return errTooManyGradientStops
+	}
+	c := lowlevel.RGBAColor(color.RGBA{
+		R: uint8(len(g.Stops)),
+		G: uint8(g.Spread&0x03)<<6 | gradientBase,
+		B: 0x80 | uint8(g.Shape&0x01)<<6 | gradientBase,
+		A: 0x00,
+	})
+	x.dst.SetCReg(0, false, c)
+	x.dst.SetCSel(gradientBase)
+	x.dst.SetNSel(gradientBase)
+	for i, f := range g.Transform {
+		x.dst.SetNReg(uint8(6-i), false, f)
+	}
+	for _, stop := range g.Stops {
+		x.dst.SetCReg(0, true, stop.Color)
+		x.dst.SetNReg(0, true, stop.Offset)
+	}
+	x.dst.SetCSel(0)
+	x.dst.SetNSel(0)
+	x.cReg0, x.cReg0Valid = c, true
+	return nil
+}
+
+// candidate is one way to encode a path segment.
+type candidate struct {
+	op     byte
+	cost   int
+	coords [6]float32
+	// ctrl is the decoded final control point, for smooth quadTo and cubeTo
+	// ops. to is the decoded end point.
+	ctrl f32.Vec2
+	to   f32.Vec2
+	// err is the largest error of any of the candidate's coordinates,
+	// including those of an implicit control point.
+	err float32
+}
+
+// addCoords sets c's coordinates (relative to base, unless base is nil) and
+// adds their encoded length to c's cost. It returns the decoded points, each
+// of whose coordinates is within maxError of the corresponding point's.
+func (c *candidate) addCoords(maxError float32, base *f32.Vec2, points ...f32.Vec2) (decoded [3]f32.Vec2) {
+	for i, p := range points {
+		for j := 0; j < 2; j++ {
+			b := float32(0)
+			if base != nil {
+				b = base[j]
+			}
+			q, n := quantize(maxError, b, p[j])
+			c.coords[2*i+j] = q
+			c.cost += n
+			decoded[i][j] = b + q
+			c.noteError(absDiff(b+q, p[j]))
+		}
+	}
+	return decoded
+}
+
+func (c *candidate) noteError(e float32) {
+	if c.err < e {
+		c.err = e
+	}
+}
+
+func (x *encoder) noteError(e float32) {
+	if x.worstError < e {
+		x.worstError = e
+	}
+}
+
+// quantize returns the coordinate number, relative to base, that best encodes
+// the coordinate want, and that number's encoded length. If maxError is
+// positive, it is the cheapest number such that base plus that number is
+// within maxError of want. Otherwise, it is the exact encoding of want minus
+// base.
+func quantize(maxError float32, base float32, want float32) (q float32, n int) {
+	f := want - base
+	if maxError > 0 {
+		if q = float32(math.Round(float64(f))); (-64 <= q) && (q < 64) && (absDiff(base+q, want) <= maxError) {
+			return q, 1
+		}
+		if q = float32(math.Round(float64(f)*64) / 64); (-128 <= q) && (q < 128) && (absDiff(base+q, want) <= maxError) {
+			return q, 2
+		}
+	}
+	return lowlevel.QuantizeCoordinate(f)
+}
+
+// near returns whether p and q are equal, or within maxError of each other in
+// both dimensions, and the larger of those two differences.
+func (x *encoder) near(p, q f32.Vec2) (bool, float32) {
+	e := absDiff(p[0], q[0])
+	if e1 := absDiff(p[1], q[1]); e < e1 {
+		e = e1
+	}
+	return (p == q) || (e <= x.maxError), e
+}
+
+// absDiff returns the distance between two coordinates. Equal coordinates,
+// including equal infinities and two NaNs, are zero apart. A NaN is
+// infinitely far from any other number, so that a NaN coordinate is never
+// near enough to be replaced by another.
+func absDiff(a, b float32) float32 {
+	if a < b {
+		return b - a
+	} else if b < a {
+		return a - b
+	} else if (a == b) || (math.IsNaN(float64(a)) && math.IsNaN(float64(b))) {
+		return 0
+	}
+	return float32(math.Inf(+1))
+}
+
+// choose returns the cheapest of the candidates, favoring earlier ones (and
+// the previous op, whose opcode may be shared) in case of a tie.
+func (x *encoder) choose(cs []candidate) *candidate {
+	best := -1
+	for i := range cs {
+		if cs[i].op != x.lastOp {
+			cs[i].cost++
+		}
+		if (best < 0) || (cs[i].cost < cs[best].cost) {
+			best = i
+		}
+	}
+	return &cs[best]
+}
+
+func (x *encoder) moveTo(p f32.Vec2) {
+	abs, rel := candidate{op: 'M'}, candidate{op: 'm'}
+	abs.to = abs.addCoords(x.maxError, nil, p)[0]
+	rel.to = rel.addCoords(x.maxError, &x.pen, p)[0]
+	x.lastOp = 0
+	cs := [2]candidate{abs, rel}
+	c := x.choose(cs[:])
+	x.noteError(c.err)
+	if c.op == 'M' {
+		x.dst.ClosePathAbsMoveTo(c.coords[0], c.coords[1])
+	} else {
+		x.dst.ClosePathRelMoveTo(c.coords[0], c.coords[1])
+	}
+	x.pen, x.smooth, x.start, x.lastOp = c.to, c.to, c.to, 0
+}
+
+func (x *encoder) lineTo(p f32.Vec2) {
+	cs := make([]candidate, 0, 4)
+	if ok, e := x.near(f32.Vec2{0, p[1]}, f32.Vec2{0, x.pen[1]}); ok {
+		h, n := quantize(x.maxError, 0, p[0])
+		cs = append(cs, candidate{op: 'H', cost: n, coords: [6]float32{h}, to: f32.Vec2{h, x.pen[1]}, err: e})
+		h, n = quantize(x.maxError, x.pen[0], p[0])
+		cs = append(cs, candidate{op: 'h', cost: n, coords: [6]float32{h}, to: f32.Vec2{x.pen[0] + h, x.pen[1]}, err: e})
+	} else if ok, e := x.near(f32.Vec2{p[0], 0}, f32.Vec2{x.pen[0], 0}); ok {
+		v, n := quantize(x.maxError, 0, p[1])
+		cs = append(cs, candidate{op: 'V', cost: n, coords: [6]float32{v}, to: f32.Vec2{x.pen[0], v}, err: e})
+		v, n = quantize(x.maxError, x.pen[1], p[1])
+		cs = append(cs, candidate{op: 'v', cost: n, coords: [6]float32{v}, to: f32.Vec2{x.pen[0], x.pen[1] + v}, err: e})
+	}
+	for i := range cs {
+		cs[i].noteError(absDiff(cs[i].to[0], p[0]))
+		cs[i].noteError(absDiff(cs[i].to[1], p[1]))
+	}
+	abs, rel := candidate{op: 'L'}, candidate{op: 'l'}
+	abs.to = abs.addCoords(x.maxError, nil, p)[0]
+	rel.to = rel.addCoords(x.maxError, &x.pen, p)[0]
+	cs = append(cs, abs, rel)
+
+	c := x.choose(cs)
+	x.noteError(c.err)
+	switch c.op {
+	case 'H':
+		x.dst.AbsHLineTo(c.coords[0])
+	case 'h':
+		x.dst.RelHLineTo(c.coords[0])
+	case 'V':
+		x.dst.AbsVLineTo(c.coords[0])
+	case 'v':
+		x.dst.RelVLineTo(c.coords[0])
+	case 'L':
+		x.dst.AbsLineTo(c.coords[0], c.coords[1])
+	case 'l':
+		x.dst.RelLineTo(c.coords[0], c.coords[1])
+	}
+	x.pen, x.smooth = c.to, c.to
+	x.lastOp = 0
+	if (c.op == 'L') || (c.op == 'l') {
+		x.lastOp = c.op
+	}
+}
+
+func (x *encoder) quadTo(ctrl, p f32.Vec2) {
+	cs := make([]candidate, 0, 4)
+	if ok, e := x.near(ctrl, x.smooth); ok {
+		abs, rel := candidate{op: 'T', ctrl: x.smooth, err: e}, candidate{op: 't', ctrl: x.smooth, err: e}
+		abs.to = abs.addCoords(x.maxError, nil, p)[0]
+		rel.to = rel.addCoords(x.maxError, &x.pen, p)[0]
+		cs = append(cs, abs, rel)
+	}
+	abs, rel := candidate{op: 'Q'}, candidate{op: 'q'}
+	d := abs.addCoords(x.maxError, nil, ctrl, p)
+	abs.ctrl, abs.to = d[0], d[1]
+	d = rel.addCoords(x.maxError, &x.pen, ctrl, p)
+	rel.ctrl, rel.to = d[0], d[1]
+	cs = append(cs, abs, rel)
+
+	c := x.choose(cs)
+	x.noteError(c.err)
+	k := &c.coords
+	switch c.op {
+	case 'T':
+		x.dst.AbsSmoothQuadTo(k[0], k[1])
+	case 't':
+		x.dst.RelSmoothQuadTo(k[0], k[1])
+	case 'Q':
+		x.dst.AbsQuadTo(k[0], k[1], k[2], k[3])
+	case 'q':
+		x.dst.RelQuadTo(k[0], k[1], k[2], k[3])
+	}
+	x.pen, x.smooth, x.lastOp = c.to, reflect(c.ctrl, c.to), c.op
+}
+
+func (x *encoder) cubeTo(ctrl0, ctrl1, p f32.Vec2) {
+	cs := make([]candidate, 0, 4)
+	if ok, e := x.near(ctrl0, x.smooth); ok {
+		abs, rel := candidate{op: 'S', err: e}, candidate{op: 's', err: e}
+		d := abs.addCoords(x.maxError, nil, ctrl1, p)
+		abs.ctrl, abs.to = d[0], d[1]
+		d = rel.addCoords(x.maxError, &x.pen, ctrl1, p)
+		rel.ctrl, rel.to = d[0], d[1]
+		cs = append(cs, abs, rel)
+	}
+	abs, rel := candidate{op: 'C'}, candidate{op: 'c'}
+	d := abs.addCoords(x.maxError, nil, ctrl0, ctrl1, p)
+	abs.ctrl, abs.to = d[1], d[2]
+	d = rel.addCoords(x.maxError, &x.pen, ctrl0, ctrl1, p)
+	rel.ctrl, rel.to = d[1], d[2]
+	cs = append(cs, abs, rel)
+
+	c := x.choose(cs)
+	x.noteError(c.err)
+	k := &c.coords
+	switch c.op {
+	case 'S':
+		x.dst.AbsSmoothCubeTo(k[0], k[1], k[2], k[3])
+	case 's':
+		x.dst.RelSmoothCubeTo(k[0], k[1], k[2], k[3])
+	case 'C':
+		x.dst.AbsCubeTo(k[0], k[1], k[2], k[3], k[4], k[5])
+	case 'c':
+		x.dst.RelCubeTo(k[0], k[1], k[2], k[3], k[4], k[5])
+	}
+	x.pen, x.smooth, x.lastOp = c.to, reflect(c.ctrl, c.to), c.op
+}
+
+func (x *encoder) arcTo(a *ArcTo) {
+	abs, rel := candidate{op: 'A'}, candidate{op: 'a'}
+	abs.to = abs.addCoords(0, nil, a.To)[0]
+	rel.to = rel.addCoords(0, &x.pen, a.To)[0]
+	cs := [2]candidate{abs, rel}
+
+	c := x.choose(cs[:])
+	x.noteError(c.err)
+	if c.op == 'A' {
+		x.dst.AbsArcTo(a.Radii[0], a.Radii[1], a.XAxisRotation, a.LargeArc, a.Sweep, c.coords[0], c.coords[1])
+	} else {
+		x.dst.RelArcTo(a.Radii[0], a.Radii[1], a.XAxisRotation, a.LargeArc, a.Sweep, c.coords[0], c.coords[1])
+	}
+	x.pen, x.smooth, x.lastOp = c.to, c.to, c.op
+}
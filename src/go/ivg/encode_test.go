@@ -0,0 +1,210 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg/ivgtest"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/math/f32"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc, err)
+			continue
+		}
+		if err := ivgtest.CheckRoundTrip(g); err != nil {
+			t.Errorf("%s: %v", tc, err)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	testCases := []struct {
+		desc  string
+		build func(b *ivg.Builder)
+		want  ivg.Path
+	}{{
+		desc:  "empty path",
+		build: func(b *ivg.Builder) { b.ClosePath() },
+		want:  nil,
+	}, {
+		desc:  "implicit MoveTo",
+		build: func(b *ivg.Builder) { b.LineTo(1, 2).LineTo(3, 4).ClosePath() },
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 2}},
+			ivg.LineTo{To: f32.Vec2{3, 4}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "curves",
+		build: func(b *ivg.Builder) {
+			b.MoveTo(-8, -8).QuadTo(0, -16, 8, -8).CubeTo(16, 0, 16, 8, 8, 8).ClosePath()
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{-8, -8}},
+			ivg.QuadTo{Ctrl: f32.Vec2{0, -16}, To: f32.Vec2{8, -8}},
+			ivg.CubeTo{Ctrl0: f32.Vec2{16, 0}, Ctrl1: f32.Vec2{16, 8}, To: f32.Vec2{8, 8}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "arc",
+		build: func(b *ivg.Builder) {
+			b.MoveTo(-4, 0).ArcTo(4, 4, 0, false, true, 4, 0).ClosePath()
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{-4, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{4, 4}, Sweep: true, To: f32.Vec2{4, 0}},
+			ivg.ClosePath{},
+		},
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.build(b)
+		g := b.Fill(red).Graphic()
+		if tc.want == nil {
+			if len(g.Shapes) != 0 {
+				t.Errorf("%s: got %d shapes, want 0", tc.desc, len(g.Shapes))
+			}
+			continue
+		}
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: got %d shapes, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		if got := g.Shapes[0].Path; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+		if err := ivgtest.CheckRoundTrip(g); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		}
+	}
+}
+
+func TestReencode(t *testing.T) {
+	testCases := []struct {
+		filename string
+		e        ivg.Encoder
+	}{
+		{"action-info.lores.ivg", ivg.Encoder{}},
+		{"action-info.lores.ivg", ivg.Encoder{Optimize: true}},
+		{"arcs.ivg", ivg.Encoder{LowerArcs: true}},
+		{"cowbell.ivg", ivg.Encoder{Optimize: true}},
+		{"gradient.ivg", ivg.Encoder{}},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tc.e.Reencode(src)
+		if err != nil {
+			t.Errorf("%s, %+v: %v", tc.filename, tc.e, err)
+			continue
+		}
+		checkSameRendering(t, tc.filename, got, src, 2)
+	}
+
+	// Palette references stay palette references.
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&ivg.Encoder{Optimize: true}).Reencode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// action-info.lores is painted with custom palette entry 0. At size 48,
+	// (24, 6) is inside of its circle.
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	m, err := render.Image(got, 48, &render.Options{Palette: map[uint8]color.RGBA{0: red}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := m.RGBAAt(24, 6); c != red {
+		t.Errorf("palette reference: got %v, want %v", c, red)
+	}
+
+	if _, err := (&ivg.Encoder{}).Reencode(nil); err == nil {
+		t.Errorf("invalid src: got nil error, want non-nil")
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	testCases := []struct {
+		filename string
+		e        ivg.Encoder
+	}{
+		{"cowbell.ivg", ivg.Encoder{}},
+		{"favicon.ivg", ivg.Encoder{Optimize: true}},
+		{"gradient.ivg", ivg.Encoder{}},
+		{"video-005.primitive.ivg", ivg.Encoder{}},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := tc.e.Encode(g)
+		if err != nil {
+			t.Errorf("%s: Encode: %v", tc.filename, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		n, err := tc.e.EncodeTo(buf, g)
+		if err != nil {
+			t.Errorf("%s: EncodeTo: %v", tc.filename, err)
+			continue
+		}
+		if n != len(want) {
+			t.Errorf("%s: EncodeTo: got n = %d, want %d", tc.filename, n, len(want))
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: EncodeTo and Encode differ", tc.filename)
+		}
+	}
+}
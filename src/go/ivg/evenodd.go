@@ -0,0 +1,189 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// EvenOddToNonZero returns a Path that, filled with the nonzero winding rule,
+// fills the same area as p filled with the even-odd rule. IconVG byte code
+// only has the nonzero rule, so the Encoder uses it to encode Shapes whose
+// FillRule is even-odd.
+//
+// If p's sub-paths neither cross nor touch themselves or each other, as is
+// usual for a glyph or an icon with holes, the result is p with some of its
+// sub-paths reversed, so that their directions alternate from the outermost
+// inwards, and its curves are kept. Otherwise, the result is like Union's:
+// closed polygons, with curves flattened to within tolerance. In both cases,
+// tolerance is also that of the flattening used to test the sub-paths, and
+// if it is not positive, it is 1/4096th of the larger dimension of p's
+// bounding box.
+func EvenOddToNonZero(p Path, tolerance float32) Path {
+	scale := clipScale(p)
+	if !(scale > 0) || math.IsInf(scale, 0) {
+		return Path{}
+	}
+	tol := float64(tolerance)
+	if !(tol > 0) {
+		tol = scale / 4096
+	}
+	pls := flattenPolylines(p, tol)
+	edges := polylineEdges(pls)
+	if q, ok := reorient(p, pls, edges); ok {
+		return q
+	}
+	return clip(edges, nil, scale, lowlevel.FillRuleEvenOdd, func(inA, inB bool) bool { return inA })
+}
+
+// reorient implements EvenOddToNonZero for sub-paths that are simple and
+// disjoint, whose flattened polylines are pls and whose polygon edges are
+// edges. It returns ok == false if they are not.
+//
+// A point inside such sub-paths is inside a chain of them, each nested inside
+// the next. With alternating directions, the winding numbers of that chain
+// alternate between +1 and -1, and sum to 1 or 0 when the even-odd rule
+// gives odd or even.
+func reorient(p Path, pls []polyline, edges []edge) (q Path, ok bool) {
+	if len(splitEdges(edges)) != len(edges) {
+		return nil, false
+	}
+	// A vertex shared by two sub-paths, or visited twice by one, is where
+	// they touch.
+	seen := map[point]bool{}
+	for _, pl := range pls {
+		for _, v := range pl.pts {
+			if seen[v] {
+				return nil, false
+			}
+			seen[v] = true
+		}
+	}
+
+	subs := subPaths(p)
+	if len(subs) != len(pls) {
+		return nil, false
+	}
+	polys := make([][]edge, len(pls))
+	for i := range pls {
+		polys[i] = polylineEdges(pls[i : i+1])
+	}
+	q = make(Path, 0, len(p))
+	for i, pl := range pls {
+		area := 0.0
+		for _, e := range polys[i] {
+			area += e.p.x*e.q.y - e.q.x*e.p.y
+		}
+		depth := 0
+		for j := range polys {
+			if (j != i) && (winding(polys[j], pl.pts[0]) != 0) {
+				depth++
+			}
+		}
+		if (area != 0) && ((area > 0) != (depth%2 == 0)) {
+			q = append(q, reverseSubPath(subs[i])...)
+		} else {
+			q = append(q, subs[i]...)
+		}
+	}
+	return q, true
+}
+
+// subPaths splits p into sub-paths, each starting with a MoveTo, in the same
+// way as flattenPolylines: a sub-path starts at every MoveTo, and at any
+// other segment that does not follow one, such as a segment after a
+// ClosePath.
+func subPaths(p Path) []Path {
+	subs := []Path(nil)
+	pen, start, open := f32.Vec2{}, f32.Vec2{}, false
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case MoveTo:
+			pen, start, open = seg.To, seg.To, true
+			subs = append(subs, Path{seg})
+			continue
+		}
+		if !open {
+			subs = append(subs, Path{MoveTo{To: pen}})
+			start, open = pen, true
+		}
+		n := len(subs) - 1
+		subs[n] = append(subs[n], seg)
+		switch seg := seg.(type) {
+		case LineTo:
+			pen = seg.To
+		case QuadTo:
+			pen = seg.To
+		case CubeTo:
+			pen = seg.To
+		case ArcTo:
+			pen = seg.To
+		case ClosePath:
+			pen, open = start, false
+		}
+	}
+	return subs
+}
+
+// reverseSubPath returns the sub-path sp, which starts with a MoveTo,
+// traversed in the opposite direction. It ends with a ClosePath if sp does.
+func reverseSubPath(sp Path) Path {
+	closed := false
+	if _, ok := sp[len(sp)-1].(ClosePath); ok {
+		closed, sp = true, sp[:len(sp)-1]
+	}
+	// from[i] is where the i'th segment starts.
+	from := make([]f32.Vec2, len(sp))
+	pen := f32.Vec2{}
+	for i, seg := range sp {
+		from[i] = pen
+		switch seg := seg.(type) {
+		case MoveTo:
+			pen = seg.To
+		case LineTo:
+			pen = seg.To
+		case QuadTo:
+			pen = seg.To
+		case CubeTo:
+			pen = seg.To
+		case ArcTo:
+			pen = seg.To
+		}
+	}
+
+	r := make(Path, 0, len(sp)+1)
+	r = append(r, MoveTo{To: pen})
+	for i := len(sp) - 1; i > 0; i-- {
+		switch seg := sp[i].(type) {
+		case LineTo:
+			r = append(r, LineTo{To: from[i]})
+		case QuadTo:
+			r = append(r, QuadTo{Ctrl: seg.Ctrl, To: from[i]})
+		case CubeTo:
+			r = append(r, CubeTo{Ctrl0: seg.Ctrl1, Ctrl1: seg.Ctrl0, To: from[i]})
+		case ArcTo:
+			seg.Sweep = !seg.Sweep
+			seg.To = from[i]
+			r = append(r, seg)
+		}
+	}
+	if closed {
+		r = append(r, ClosePath{})
+	}
+	return r
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+const (
+	// maxFocalRadius bounds the distance, in gradient coordinate space,
+	// between a radial Gradient's Focal point and its center. Like SVG, a
+	// focal point outside the unit circle is moved onto it, but only nearly
+	// onto it, as a focal point on the circle is degenerate.
+	maxFocalRadius = 0.99
+
+	// focalBandError bounds the offset error of each band of a lowered focal
+	// gradient. See lowerFocalGradients.
+	focalBandError = 1.0 / 128
+)
+
+// FocalRadialGradient is like RadialGradient, but offset 0 is at the focal
+// point (fx, fy) instead of at the center, like SVG's fx and fy attributes.
+// Each offset's color is on a circle whose center moves from the focal point
+// to (cx, cy), and whose radius grows from 0 to r, as the offset goes from 0
+// to 1. A focal point outside that last circle is moved onto it.
+//
+// IconVG byte code has no focal point, so the Encoder approximates such a
+// gradient (see Gradient.Focal).
+func FocalRadialGradient(stops []GradientStop, cx, cy, r, fx, fy float32, spread GradientSpread) Paint {
+	p := RadialGradient(stops, cx, cy, r, spread)
+	if r > 0 {
+		p.Gradient.Focal = f32.Vec2{(fx - cx) / r, (fy - cy) / r}
+	}
+	return p
+}
+
+// clampFocal returns the Gradient's focal point, moved to within
+// maxFocalRadius of the center.
+func clampFocal(g *Gradient) (fx, fy float64) {
+	fx, fy = float64(g.Focal[0]), float64(g.Focal[1])
+	if d := math.Hypot(fx, fy); d > maxFocalRadius {
+		fx, fy = fx*maxFocalRadius/d, fy*maxFocalRadius/d
+	}
+	return fx, fy
+}
+
+// focalOffset returns the offset, for the focal point (fx, fy), at the
+// gradient coordinate space point (x, y): the t such that (x, y) is on the
+// circle with center (1-t)*(fx, fy) and radius t.
+func focalOffset(x, y, fx, fy float64) float64 {
+	vx, vy := x-fx, y-fy
+	vf := vx*fx + vy*fy
+	a := 1 - (fx*fx + fy*fy)
+	return (vf + math.Sqrt(vf*vf+a*(vx*vx+vy*vy))) / a
+}
+
+// lowerFocalGradients returns shapes with every Shape whose Paint is a radial
+// Gradient with a Focal point replaced by Shapes whose Paints have none. The
+// Shape is cut into bands, each between two of the focal gradient's circles,
+// and each band is painted with a centered radial gradient whose center is
+// that of the band's outer circle. The two gradients agree on that circle,
+// and within the band their offsets differ by at most the band's width times
+// the focal point's distance from the center, which the number of bands keeps
+// within focalBandError. Inside the innermost band and, for the none and pad
+// spreads, outside the outermost band, every offset's color is the same, so
+// the two gradients agree exactly.
+//
+// If every stop color is opaque, resolved against pal, then the bands are
+// nested discs instead of rings, painted from the outside in, each over the
+// last, so that there are no anti-aliasing seams between them.
+//
+// shapes itself is returned if no Shape needs lowering.
+func lowerFocalGradients(shapes []Shape, pal *lowlevel.Palette) []Shape {
+	ret, copied := shapes, false
+	for i := range shapes {
+		g := shapes[i].Paint.Gradient
+		if (g == nil) || (g.Shape != GradientShapeRadial) || (g.Focal == f32.Vec2{}) {
+			if copied {
+				ret = append(ret, shapes[i])
+			}
+			continue
+		}
+		if !copied {
+			ret, copied = append([]Shape(nil), shapes[:i]...), true
+		}
+		ret = append(ret, focalBands(&shapes[i], pal)...)
+	}
+	return ret
+}
+
+// focalBands returns the Shapes that lowerFocalGradients replaces s by.
+func focalBands(s *Shape, pal *lowlevel.Palette) []Shape {
+	g := s.Paint.Gradient
+	band := func(p Path, t float64, fx, fy float64) Shape {
+		h := *g
+		h.Focal = f32.Vec2{}
+		h.Transform[2] -= float32((1 - t) * fx)
+		h.Transform[5] -= float32((1 - t) * fy)
+		return Shape{Paint: Paint{Gradient: &h}, Path: p, LOD0: s.LOD0, LOD1: s.LOD1}
+	}
+
+	inv, ok := lowlevel.InvertGradientTransform(g.Transform, false)
+	if !ok {
+		return []Shape{band(s.Path, 1, 0, 0)}
+	}
+	p := s.Path
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		p = EvenOddToNonZero(p, 0)
+	}
+	p = Union(p, nil, 0)
+	if len(p) == 0 {
+		return nil
+	}
+	tol := float32(clipScale(p) / 1024)
+	fx, fy := clampFocal(g)
+
+	// The offset is a convex function of position, so its maximum over the
+	// Shape is at most its maximum over the corners of the Shape's bounds.
+	b, tMax := pathBounds(p), 0.0
+	for _, x := range [2]float32{b.Min[0], b.Max[0]} {
+		for _, y := range [2]float32{b.Min[1], b.Max[1]} {
+			m := &g.Transform
+			gx := float64(m[0]*x + m[1]*y + m[2])
+			gy := float64(m[3]*x + m[4]*y + m[5])
+			tMax = math.Max(tMax, focalOffset(gx, gy, fx, fy))
+		}
+	}
+
+	// Only offsets in [lo, hi] need bands. With the none and pad spreads,
+	// the color is constant below the first stop's offset, and above the
+	// last stop's offset (or, for none, above 1).
+	lo, hi := 0.0, tMax
+	if (g.Spread == GradientSpreadNone || g.Spread == GradientSpreadPad) && (len(g.Stops) > 0) {
+		first, last := 1.0, 0.0
+		for _, stop := range g.Stops {
+			o := math.Max(0, math.Min(1, float64(stop.Offset)))
+			first, last = math.Min(first, o), math.Max(last, o)
+		}
+		if g.Spread == GradientSpreadNone {
+			last = 1
+		}
+		lo, hi = math.Min(first, tMax), math.Min(last, tMax)
+		hi = math.Max(hi, lo)
+	}
+	n := int(math.Ceil((hi - lo) * math.Hypot(fx, fy) / focalBandError))
+
+	// boundary returns the offset of the i'th of the n+1 band boundaries.
+	boundary := func(i int) float64 {
+		if i == n {
+			return hi
+		}
+		return lo + (hi-lo)*float64(i)/float64(n)
+	}
+	ret := []Shape(nil)
+	add := func(q Path, t float64) {
+		if len(q) > 0 {
+			ret = append(ret, band(q, t, fx, fy))
+		}
+	}
+	circle := func(t float64, reverse bool) Path {
+		return focalCircle(&inv, (1-t)*fx, (1-t)*fy, t, reverse)
+	}
+
+	if opaqueStops(g.Stops, pal) {
+		add(p, hi)
+		for i := n - 1; i >= 0; i-- {
+			if t := boundary(i); t > 0 {
+				add(Intersect(p, circle(t, false), tol), t)
+			}
+		}
+		return ret
+	}
+
+	if lo > 0 {
+		add(Intersect(p, circle(lo, false), tol), lo)
+	}
+	for i := 1; i <= n; i++ {
+		t0, t1 := boundary(i-1), boundary(i)
+		ring := circle(t1, false)
+		if t0 > 0 {
+			ring = append(ring, circle(t0, true)...)
+		}
+		add(Intersect(p, ring, tol), t1)
+	}
+	if hi > 0 {
+		add(Subtract(p, circle(hi, false), tol), hi)
+	} else {
+		add(p, hi)
+	}
+	return ret
+}
+
+// opaqueStops returns whether every stop's color, resolved against pal, is
+// opaque. Colors that refer to CREG registers are not known to be opaque.
+func opaqueStops(stops []GradientStop, pal *lowlevel.Palette) bool {
+	var cReg [64]color.RGBA
+	for _, stop := range stops {
+		if stop.Color.Resolve(pal, &cReg).A != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// focalCircle returns, in graphic coordinate space, the gradient coordinate
+// space circle with center (cx, cy) and radius r. inv maps from gradient to
+// graphic coordinate space. The circle is drawn as four cubic Bézier curves,
+// clockwise in gradient coordinate space unless reverse is set.
+func focalCircle(inv *f64.Aff3, cx, cy, r float64, reverse bool) Path {
+	// k is the distance, as a fraction of r, from a quarter circle's end
+	// points to its control points.
+	const k = 0.5522847498307936
+	pt := func(dx, dy float64) f32.Vec2 {
+		x, y := cx+r*dx, cy+r*dy
+		return f32.Vec2{
+			float32(inv[0]*x + inv[1]*y + inv[2]),
+			float32(inv[3]*x + inv[4]*y + inv[5]),
+		}
+	}
+	sy := 1.0
+	if reverse {
+		sy = -1
+	}
+	p := Path{MoveTo{To: pt(1, 0)}}
+	for i := 0; i < 4; i++ {
+		// (ux, uy) is the quarter circle's start and (vx, vy) its end.
+		ux, uy := [4]float64{1, 0, -1, 0}[i], [4]float64{0, 1, 0, -1}[i]*sy
+		vx, vy := -uy*sy, ux*sy
+		p = append(p, CubeTo{
+			Ctrl0: pt(ux+k*vx, uy+k*vy),
+			Ctrl1: pt(vx+k*ux, vy+k*uy),
+			To:    pt(vx, vy),
+		})
+	}
+	return append(p, ClosePath{})
+}
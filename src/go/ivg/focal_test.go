@@ -0,0 +1,154 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/math/f32"
+)
+
+func TestFocalRadialGradient(t *testing.T) {
+	testCases := []struct {
+		cx, cy, r, fx, fy float32
+		want              f32.Vec2
+	}{
+		{5, 5, 5, 7.5, 5, f32.Vec2{0.5, 0}},
+		{0, 0, 10, 0, -5, f32.Vec2{0, -0.5}},
+		{0, 0, 10, 0, 0, f32.Vec2{}},
+		{0, 0, 0, 1, 1, f32.Vec2{}},
+	}
+	for _, tc := range testCases {
+		got := ivg.FocalRadialGradient(testStops, tc.cx, tc.cy, tc.r, tc.fx, tc.fy, ivg.GradientSpreadPad)
+		want := ivg.RadialGradient(testStops, tc.cx, tc.cy, tc.r, ivg.GradientSpreadPad)
+		if got.Gradient.Focal != tc.want {
+			t.Errorf("%v: Focal: got %v, want %v", tc, got.Gradient.Focal, tc.want)
+		}
+		if got.Gradient.Transform != want.Gradient.Transform {
+			t.Errorf("%v: Transform: got %v, want %v", tc, got.Gradient.Transform, want.Gradient.Transform)
+		}
+	}
+}
+
+// focalOffset returns the offset of a focal radial gradient, whose focal
+// point is (fx, fy), at the gradient coordinate space point (x, y).
+func focalOffset(x, y, fx, fy float64) float64 {
+	if d := math.Hypot(fx, fy); d > 0.99 {
+		fx, fy = fx*0.99/d, fy*0.99/d
+	}
+	vx, vy := x-fx, y-fy
+	vf := vx*fx + vy*fy
+	a := 1 - (fx*fx + fy*fy)
+	return (vf + math.Sqrt(vf*vf+a*(vx*vx+vy*vy))) / a
+}
+
+func TestFocalGradientEncode(t *testing.T) {
+	opaque := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0xff, 0xff, 0xff})},
+	}
+	translucent := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0x80})},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0x80, 0x80, 0x80, 0x80})},
+	}
+	testCases := []struct {
+		desc   string
+		stops  []ivg.GradientStop
+		fx, fy float32
+		// want is the red value, and wantAlpha the alpha value, at offset
+		// t. Translucent bands are rings, not nested discs, and where a
+		// pixel straddles two rings, their anti-aliased edges composite to
+		// less than the gradient's alpha, so their tolerance is larger.
+		want      func(t float64) float64
+		wantAlpha float64
+		tolerance float64
+	}{
+		{"opaque", opaque, 16, 0, func(t float64) float64 { return 0xff * t }, 0xff, 4},
+		{"opaque, diagonal", opaque, -10, 10, func(t float64) float64 { return 0xff * t }, 0xff, 4},
+		{"opaque, focal outside", opaque, 0, 40, func(t float64) float64 { return 0xff * t }, 0xff, 4},
+		{"translucent", translucent, 16, 0, func(t float64) float64 { return 0x80 * t }, 0x80, 28},
+	}
+	for _, tc := range testCases {
+		// The square fills the default viewBox, from -32 to +32, and the
+		// gradient's circle has radius 32.
+		g := ivg.NewBuilder().
+			MoveTo(-32, -32).LineTo(32, -32).LineTo(32, 32).LineTo(-32, 32).ClosePath().
+			FillPaint(ivg.FocalRadialGradient(tc.stops, 0, 0, 32, tc.fx, tc.fy, ivg.GradientSpreadPad)).
+			Graphic()
+		src, err := ivg.Encode(g)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc.desc, err)
+		}
+		m, err := render.Image(src, 64, nil)
+		if err != nil {
+			t.Fatalf("%s: Image: %v", tc.desc, err)
+		}
+		worst := 0.0
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				// Near a focal point close to the circle, the offset can
+				// change too quickly within a pixel for a pixel-sized
+				// comparison to be meaningful. Skip such pixels.
+				fx, fy := float64(tc.fx)/32, float64(tc.fy)/32
+				lo, hi := math.Inf(+1), math.Inf(-1)
+				for _, dy := range [2]float64{0, 1} {
+					for _, dx := range [2]float64{0, 1} {
+						o := focalOffset((float64(x)+dx-32)/32, (float64(y)+dy-32)/32, fx, fy)
+						lo, hi = math.Min(lo, o), math.Max(hi, o)
+					}
+				}
+				if hi-lo > 1.0/16 {
+					continue
+				}
+
+				want := 0.0
+				for j := 0; j < 4; j++ {
+					for i := 0; i < 4; i++ {
+						gx := (float64(x) + (float64(i)+0.5)/4 - 32) / 32
+						gy := (float64(y) + (float64(j)+0.5)/4 - 32) / 32
+						o := focalOffset(gx, gy, fx, fy)
+						want += tc.want(math.Min(o, 1)) / 16
+					}
+				}
+				c := m.RGBAAt(x, y)
+				worst = math.Max(worst, math.Abs(float64(c.R)-want))
+				worst = math.Max(worst, math.Abs(float64(c.A)-tc.wantAlpha))
+			}
+		}
+		if worst > tc.tolerance {
+			t.Errorf("%s: worst difference: got %g, want at most %g", tc.desc, worst, tc.tolerance)
+		}
+
+		// Decoding never gives a focal point.
+		h, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tc.desc, err)
+		}
+		if len(h.Shapes) < 2 {
+			t.Errorf("%s: got %d shapes, want the gradient lowered to bands", tc.desc, len(h.Shapes))
+		}
+		for i, s := range h.Shapes {
+			if (s.Paint.Gradient == nil) || (s.Paint.Gradient.Focal != f32.Vec2{}) {
+				t.Errorf("%s: shape %d: got %v, want a centered gradient", tc.desc, i, s.Paint.Gradient)
+				break
+			}
+		}
+	}
+}
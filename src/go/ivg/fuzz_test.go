@@ -0,0 +1,62 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+
+package ivg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg/ivgtest"
+)
+
+// addSeedCorpus adds the test/data IconVG graphics to f's seed corpus.
+func addSeedCorpus(f *testing.F) {
+	filenames, err := filepath.Glob("../../../test/data/*.ivg")
+	if err != nil {
+		f.Fatal(err)
+	} else if len(filenames) == 0 {
+		f.Fatal("no seed corpus files found")
+	}
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addSeedCorpus(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			return
+		}
+		for _, s := range g.Shapes {
+			// Not every valid graphic can be encoded by an ivg.Encoder.
+			if (s.Paint.Gradient != nil) && (len(s.Paint.Gradient.Stops) > ivg.MaxGradientStops) {
+				return
+			}
+		}
+		if err := ivgtest.CheckRoundTrip(g); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
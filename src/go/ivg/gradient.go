@@ -0,0 +1,195 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// degenerateGradientTransform maps every point to offset 1, so that a
+// degenerate gradient is painted with its last stop's color, like SVG.
+var degenerateGradientTransform = f32.Aff3{0, 0, 1, 0, 0, 0}
+
+// LinearGradient returns a Paint for a linear gradient whose offset 0 is at
+// (x1, y1) and whose offset 1 is at (x2, y2), in graphic coordinate space.
+// Lines perpendicular to the vector between those points have constant color.
+// Equal points are degenerate.
+//
+// When encoding, the Encoder sets up the CREG and NREG registers that the
+// gradient refers to, so callers need not compute register layouts.
+func LinearGradient(stops []GradientStop, x1, y1, x2, y2 float32, spread GradientSpread) Paint {
+	g := &Gradient{
+		Shape:     GradientShapeLinear,
+		Spread:    spread,
+		Transform: degenerateGradientTransform,
+		Stops:     stops,
+	}
+	dx, dy := float64(x2-x1), float64(y2-y1)
+	if d2 := dx*dx + dy*dy; d2 != 0 {
+		g.Transform = f32.Aff3{
+			float32(dx / d2),
+			float32(dy / d2),
+			float32(-(float64(x1)*dx + float64(y1)*dy) / d2),
+			0, 0, 0,
+		}
+	}
+	return Paint{Gradient: g}
+}
+
+// RadialGradient returns a Paint for a radial gradient whose offset 0 is at
+// the center (cx, cy) and whose offset 1 is on the circle of radius r, in
+// graphic coordinate space. A non-positive r is degenerate.
+func RadialGradient(stops []GradientStop, cx, cy, r float32, spread GradientSpread) Paint {
+	g := &Gradient{
+		Shape:     GradientShapeRadial,
+		Spread:    spread,
+		Transform: degenerateGradientTransform,
+		Stops:     stops,
+	}
+	if r > 0 {
+		g.Transform = f32.Aff3{1 / r, 0, -cx / r, 0, 1 / r, -cy / r}
+	}
+	return Paint{Gradient: g}
+}
+
+// ResampleStops returns at most n of stops, in order and always including the
+// first and last, chosen so that a gradient with the returned stops looks as
+// much like one with all of the stops as it can. Stop colors are kept, not
+// recomputed, so that palette references stay palette references.
+//
+// It also returns the resampling error: the largest difference, over every
+// offset and every color channel, between the two gradients'
+// alpha-premultiplied colors, as a fraction of full intensity (so that one
+// 8-bit level is 1/255). Both gradients interpolate linearly between their
+// stops, so that is the largest difference at a removed stop's offset. Colors
+// are resolved against pal, with colors that refer to CREG registers treated
+// as transparent black.
+//
+// If len(stops) is at most n, it returns stops and a zero error. An n less
+// than 2 is treated as 2.
+func ResampleStops(stops []GradientStop, n int, pal *lowlevel.Palette) ([]GradientStop, float32) {
+	if n < 2 {
+		n = 2
+	}
+	if len(stops) <= n {
+		return stops, 0
+	}
+	r := newResampler(stops, pal)
+
+	// Repeatedly remove the stop whose removal adds the least error.
+	cost := make([]float64, len(stops))
+	for i := 1; i < len(stops)-1; i++ {
+		cost[i] = r.segmentError(i-1, i+1)
+	}
+	for alive := len(stops); alive > n; alive-- {
+		best, bestCost := -1, math.Inf(+1)
+		for i := r.next[0]; i != len(stops)-1; i = r.next[i] {
+			if cost[i] < bestCost {
+				best, bestCost = i, cost[i]
+			}
+		}
+		prev, next := r.prev[best], r.next[best]
+		r.next[prev], r.prev[next] = next, prev
+		if prev != 0 {
+			cost[prev] = r.segmentError(r.prev[prev], next)
+		}
+		if next != len(stops)-1 {
+			cost[next] = r.segmentError(prev, r.next[next])
+		}
+	}
+
+	kept, worst := make([]GradientStop, 0, n), 0.0
+	for i := 0; ; i = r.next[i] {
+		kept = append(kept, stops[i])
+		if i == len(stops)-1 {
+			break
+		}
+		worst = math.Max(worst, r.segmentError(i, r.next[i]))
+	}
+	return kept, float32(worst)
+}
+
+// resampler holds ResampleStops' state: each stop's offset and resolved color,
+// and a doubly linked list of the stops not yet removed.
+type resampler struct {
+	offsets []float64
+	colors  [][4]float64
+	prev    []int
+	next    []int
+}
+
+func newResampler(stops []GradientStop, pal *lowlevel.Palette) *resampler {
+	var cReg [64]color.RGBA
+	r := &resampler{
+		offsets: make([]float64, len(stops)),
+		colors:  make([][4]float64, len(stops)),
+		prev:    make([]int, len(stops)),
+		next:    make([]int, len(stops)),
+	}
+	for i, s := range stops {
+		c := s.Color.Resolve(pal, &cReg)
+		r.offsets[i] = float64(s.Offset)
+		r.colors[i] = [4]float64{float64(c.R) / 0xff, float64(c.G) / 0xff, float64(c.B) / 0xff, float64(c.A) / 0xff}
+		r.prev[i], r.next[i] = i-1, i+1
+	}
+	return r
+}
+
+// segmentError returns the error of interpolating between stops i and j
+// instead of through the stops between them. Stops between two stops at the
+// same offset are never seen, so they add no error.
+func (r *resampler) segmentError(i, j int) float64 {
+	d := r.offsets[j] - r.offsets[i]
+	if !(d > 0) {
+		return 0
+	}
+	worst, ci, cj := 0.0, &r.colors[i], &r.colors[j]
+	for k := i + 1; k < j; k++ {
+		f := math.Max(0, math.Min(1, (r.offsets[k]-r.offsets[i])/d))
+		for c := range ci {
+			worst = math.Max(worst, math.Abs(ci[c]+f*(cj[c]-ci[c])-r.colors[k][c]))
+		}
+	}
+	return worst
+}
+
+// resampleGradients returns shapes with every Gradient that has more than
+// MaxGradientStops stops resampled by ResampleStops, and the largest
+// resampling error. The shapes and gradients are copied, not modified, and
+// shapes itself is returned if no Gradient needs resampling.
+func resampleGradients(shapes []Shape, pal *lowlevel.Palette) ([]Shape, float32) {
+	worst, copied := float32(0), false
+	for i := range shapes {
+		g := shapes[i].Paint.Gradient
+		if (g == nil) || (len(g.Stops) <= MaxGradientStops) {
+			continue
+		}
+		if !copied {
+			shapes, copied = append([]Shape(nil), shapes...), true
+		}
+		h := *g
+		var err float32
+		h.Stops, err = ResampleStops(g.Stops, MaxGradientStops, pal)
+		if err > worst {
+			worst = err
+		}
+		shapes[i].Paint.Gradient = &h
+	}
+	return shapes, worst
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg/ivgtest"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var testStops = []ivg.GradientStop{
+	{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})},
+	{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})},
+}
+
+// gradientOffset returns the offset of p, in graphic coordinate space, along
+// the gradient g.
+func gradientOffset(g *ivg.Gradient, p f32.Vec2) float64 {
+	m := &g.Transform
+	x := float64(m[0]*p[0] + m[1]*p[1] + m[2])
+	y := float64(m[3]*p[0] + m[4]*p[1] + m[5])
+	if g.Shape == ivg.GradientShapeRadial {
+		return math.Hypot(x, y)
+	}
+	return x
+}
+
+func TestLinearGradient(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		x1, y1, x2, y2 float32
+		p              f32.Vec2
+		want           float64
+	}{
+		{"horizontal, start", -10, 0, 10, 0, f32.Vec2{-10, 5}, 0},
+		{"horizontal, middle", -10, 0, 10, 0, f32.Vec2{0, -7}, 0.5},
+		{"horizontal, end", -10, 0, 10, 0, f32.Vec2{10, 3}, 1},
+		{"horizontal, past the end", -10, 0, 10, 0, f32.Vec2{20, 0}, 1.5},
+		{"vertical", 0, 8, 0, -8, f32.Vec2{3, 4}, 0.25},
+		{"diagonal", 0, 0, 4, 4, f32.Vec2{4, 0}, 0.5},
+		{"degenerate", 1, 1, 1, 1, f32.Vec2{-5, 5}, 1},
+	}
+	for _, tc := range testCases {
+		p := ivg.LinearGradient(testStops, tc.x1, tc.y1, tc.x2, tc.y2, ivg.GradientSpreadPad)
+		if p.Gradient == nil || p.Gradient.Shape != ivg.GradientShapeLinear {
+			t.Errorf("%s: got %v, want a linear gradient", tc.desc, p)
+			continue
+		}
+		if got := gradientOffset(p.Gradient, tc.p); math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("%s: got offset %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestRadialGradient(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		cx, cy, r float32
+		p         f32.Vec2
+		want      float64
+	}{
+		{"center", 2, 3, 4, f32.Vec2{2, 3}, 0},
+		{"inside", 2, 3, 4, f32.Vec2{2, 5}, 0.5},
+		{"circle", 2, 3, 4, f32.Vec2{-2, 3}, 1},
+		{"outside", 0, 0, 4, f32.Vec2{6, 8}, 2.5},
+		{"zero radius", 0, 0, 0, f32.Vec2{6, 8}, 1},
+		{"negative radius", 0, 0, -4, f32.Vec2{6, 8}, 1},
+	}
+	for _, tc := range testCases {
+		p := ivg.RadialGradient(testStops, tc.cx, tc.cy, tc.r, ivg.GradientSpreadPad)
+		if p.Gradient == nil || p.Gradient.Shape != ivg.GradientShapeRadial {
+			t.Errorf("%s: got %v, want a radial gradient", tc.desc, p)
+			continue
+		}
+		if got := gradientOffset(p.Gradient, tc.p); math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("%s: got offset %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestGradientRoundTrip(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		paint ivg.Paint
+	}{
+		{"linear", ivg.LinearGradient(testStops, -10, 0, 10, 0, ivg.GradientSpreadNone)},
+		{"linear, reflect", ivg.LinearGradient(testStops, 0, -10, 0, 10, ivg.GradientSpreadReflect)},
+		{"radial", ivg.RadialGradient(testStops, 0, 0, 20, ivg.GradientSpreadPad)},
+		{"radial, repeat", ivg.RadialGradient(testStops, 5, 5, 8, ivg.GradientSpreadRepeat)},
+	}
+	for _, tc := range testCases {
+		g := ivg.NewBuilder().
+			MoveTo(-20, -20).LineTo(20, -20).LineTo(20, 20).LineTo(-20, 20).ClosePath().
+			FillPaint(tc.paint).
+			Graphic()
+		if err := ivgtest.CheckRoundTrip(g); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		}
+	}
+}
+
+func TestResampleStops(t *testing.T) {
+	gray := func(offset float32, y uint8) ivg.GradientStop {
+		return ivg.GradientStop{Offset: offset, Color: lowlevel.RGBAColor(color.RGBA{y, y, y, 0xff})}
+	}
+	pal := lowlevel.DefaultPalette
+	testCases := []struct {
+		desc  string
+		stops []ivg.GradientStop
+		n     int
+		// wantOffsets are the kept stops' offsets. The error must be at
+		// most maxErr.
+		wantOffsets []float32
+		maxErr      float32
+	}{{
+		desc:        "few enough stops",
+		stops:       []ivg.GradientStop{gray(0, 0x00), gray(0.5, 0xff), gray(1, 0x00)},
+		n:           3,
+		wantOffsets: []float32{0, 0.5, 1},
+		maxErr:      0,
+	}, {
+		desc:        "ramp",
+		stops:       []ivg.GradientStop{gray(0, 0x00), gray(0.25, 0x40), gray(0.5, 0x80), gray(0.75, 0xc0), gray(1, 0xff)},
+		n:           2,
+		wantOffsets: []float32{0, 1},
+		maxErr:      1.0 / 255,
+	}, {
+		desc:        "n less than 2",
+		stops:       []ivg.GradientStop{gray(0, 0x00), gray(0.5, 0x80), gray(1, 0xff)},
+		n:           0,
+		wantOffsets: []float32{0, 1},
+		maxErr:      1.0 / 255,
+	}, {
+		desc: "peak",
+		stops: []ivg.GradientStop{
+			gray(0, 0x00), gray(0.25, 0x80), gray(0.5, 0xff), gray(0.75, 0x80), gray(1, 0x00),
+		},
+		n:           3,
+		wantOffsets: []float32{0, 0.5, 1},
+		maxErr:      1.0 / 255,
+	}, {
+		desc: "lossy",
+		stops: []ivg.GradientStop{
+			gray(0, 0x00), gray(0.25, 0xff), gray(0.5, 0x00), gray(0.75, 0x80), gray(1, 0x00),
+		},
+		n:           4,
+		wantOffsets: []float32{0, 0.25, 0.5, 1},
+		maxErr:      0.51,
+	}, {
+		desc: "coincident offsets",
+		stops: []ivg.GradientStop{
+			gray(0, 0x00), gray(0.5, 0x00), gray(0.5, 0xff), gray(0.5, 0x80), gray(1, 0xff),
+		},
+		n:           4,
+		wantOffsets: []float32{0, 0.5, 0.5, 1},
+		maxErr:      1.0 / 255,
+	}}
+	for _, tc := range testCases {
+		got, gotErr := ivg.ResampleStops(tc.stops, tc.n, &pal)
+		offsets := make([]float32, len(got))
+		for i, s := range got {
+			offsets[i] = s.Offset
+		}
+		if fmt.Sprint(offsets) != fmt.Sprint(tc.wantOffsets) {
+			t.Errorf("%s: offsets: got %v, want %v", tc.desc, offsets, tc.wantOffsets)
+		}
+		if (gotErr < 0) || (gotErr > tc.maxErr) {
+			t.Errorf("%s: error: got %g, want at most %g", tc.desc, gotErr, tc.maxErr)
+		}
+	}
+
+	// Palette references stay palette references.
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.PaletteIndexColor(0)},
+		{Offset: 0.5, Color: lowlevel.PaletteIndexColor(1)},
+		{Offset: 1, Color: lowlevel.PaletteIndexColor(2)},
+	}
+	got, _ := ivg.ResampleStops(stops, 2, &pal)
+	if (len(got) != 2) || (got[0] != stops[0]) || (got[1] != stops[2]) {
+		t.Errorf("palette: got %v, want %v", got, []ivg.GradientStop{stops[0], stops[2]})
+	}
+}
+
+func TestResampleGradients(t *testing.T) {
+	// A 100 stop ramp from black to white.
+	stops := make([]ivg.GradientStop, 100)
+	for i := range stops {
+		y := uint8(i * 0xff / 99)
+		stops[i] = ivg.GradientStop{
+			Offset: float32(i) / 99,
+			Color:  lowlevel.RGBAColor(color.RGBA{y, y, y, 0xff}),
+		}
+	}
+	g := ivg.NewBuilder().
+		MoveTo(-20, -20).LineTo(20, -20).LineTo(20, 20).LineTo(-20, 20).ClosePath().
+		FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).
+		Graphic()
+
+	e := &ivg.Encoder{}
+	if _, err := e.Encode(g); err == nil {
+		t.Errorf("without ResampleGradients: got nil error, want non-nil")
+	}
+	e.ResampleGradients = true
+	src, err := e.Encode(g)
+	if err != nil {
+		t.Fatalf("with ResampleGradients: %v", err)
+	}
+	if got := e.GradientError(); (got <= 0) || (got > 1.0/255) {
+		t.Errorf("GradientError: got %g, want in (0, %g]", got, 1.0/255)
+	}
+	if n := len(g.Shapes[0].Paint.Gradient.Stops); n != 100 {
+		t.Errorf("the Graphic was modified: got %d stops, want 100", n)
+	}
+	h, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(h.Shapes[0].Paint.Gradient.Stops); n != ivg.MaxGradientStops {
+		t.Errorf("decoded: got %d stops, want %d", n, ivg.MaxGradientStops)
+	}
+
+	// GradientError is reset by the next Encode.
+	g.Shapes[0].Paint.Gradient.Stops = stops[:2]
+	if _, err := e.Encode(g); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.GradientError(); got != 0 {
+		t.Errorf("GradientError after a small gradient: got %g, want 0", got)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// ShapeID identifies one of a Graphic's Shapes by its index in Shapes.
+type ShapeID int
+
+// Bounds returns the bounding box of p's curves. Unlike a bounding box of
+// p's points, it is tight: Bézier control points and arcs' ellipses only
+// contribute the extrema of their curves. It is the zero Rectangle if p draws
+// nothing.
+func (p Path) Bounds() lowlevel.Rectangle {
+	b := tightBounds(p)
+	if b.Min[0] > b.Max[0] {
+		return lowlevel.Rectangle{}
+	}
+	return lowlevel.Rectangle{Min: b.Min, Max: b.Max}
+}
+
+// ShapeBounds returns the bounding box, as per Path.Bounds, of the Shape
+// identified by id or, if it has a Use, of its Symbol's Shapes, transformed.
+// It is the zero Rectangle if id is out of range, if the Shape draws nothing
+// or if its Use is invalid (see Expand).
+func (g *Graphic) ShapeBounds(id ShapeID) lowlevel.Rectangle {
+	if (id < 0) || (int(id) >= len(g.Shapes)) {
+		return lowlevel.Rectangle{}
+	}
+	shapes, err := expandShapes(g.Shapes[id:id+1], g.Symbols, nil)
+	if err != nil {
+		return lowlevel.Rectangle{}
+	}
+	b := emptyBounds
+	for _, s := range shapes {
+		b = b.union(tightBounds(s.Path))
+	}
+	if b.Min[0] > b.Max[0] {
+		return lowlevel.Rectangle{}
+	}
+	return lowlevel.Rectangle{Min: b.Min, Max: b.Max}
+}
+
+// TightBounds returns the bounding box, in graphic coordinates, of the ink of
+// the IconVG graphic src: the union of the bounds, as per Path.Bounds, of the
+// paths that it fills at any level of detail, other than those whose paint is
+// entirely transparent with the suggested palette. Unlike the viewBox, it
+// fits the drawing itself, for tools that crop or Refit graphics to their
+// content. It is not clipped to the viewBox, and it is the zero Rectangle if
+// src draws nothing.
+func TightBounds(src []byte) (lowlevel.Rectangle, error) {
+	g, err := Decode(src, nil)
+	if err != nil {
+		return lowlevel.Rectangle{}, err
+	}
+	b := emptyBounds
+	for _, s := range g.Shapes {
+		if (s.LOD0 < s.LOD1) && !transparent(s.Paint) {
+			b = b.union(tightBounds(s.Path))
+		}
+	}
+	if b.Min[0] > b.Max[0] {
+		return lowlevel.Rectangle{}, nil
+	}
+	return lowlevel.Rectangle{Min: b.Min, Max: b.Max}, nil
+}
+
+// transparent returns whether p is a flat color, or a gradient whose stops
+// are all colors, whose alpha is zero.
+func transparent(p Paint) bool {
+	if p.Gradient == nil {
+		rgba, ok := p.Color.RGBA()
+		return ok && (rgba.A == 0)
+	}
+	for _, stop := range p.Gradient.Stops {
+		if rgba, ok := stop.Color.RGBA(); !ok || (rgba.A != 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// HitTest returns the Shapes that fill the point (x, y), in graphic
+// coordinates, topmost (last drawn) first. It lets an interactive editor
+// select shapes without rasterizing.
+//
+// A Shape fills a point if its Path, filled with its FillRule, contains it,
+// regardless of its Paint, so that transparent shapes can still be selected.
+// A Shape with a Use fills the points that any of its Symbol's Shapes do, as
+// expanded by Expand. Like Union, curves are flattened to line segments that
+// are within 1/4096th of the Path's larger dimension of the true curve.
+func (g *Graphic) HitTest(x, y float32) []ShapeID {
+	ids := []ShapeID(nil)
+	for i := len(g.Shapes) - 1; i >= 0; i-- {
+		shapes, err := expandShapes(g.Shapes[i:i+1], g.Symbols, nil)
+		if err != nil {
+			continue
+		}
+		for _, s := range shapes {
+			if pathContains(s.Path, s.FillRule, x, y) {
+				ids = append(ids, ShapeID(i))
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// pathContains returns whether p, filled with the given rule, contains the
+// point (x, y).
+func pathContains(p Path, rule lowlevel.FillRule, x, y float32) bool {
+	b := tightBounds(p)
+	if (x < b.Min[0]) || (b.Max[0] < x) || (y < b.Min[1]) || (b.Max[1] < y) {
+		return false
+	}
+	scale := clipScale(p)
+	if !(scale > 0) || math.IsInf(scale, 0) {
+		return false
+	}
+	w := winding(flatten(p, scale/4096), point{float64(x), float64(y)})
+	if rule == lowlevel.FillRuleEvenOdd {
+		return w%2 != 0
+	}
+	return w != 0
+}
+
+// tightBounds returns the bounds of p's curves, as per Path.Bounds. It is
+// empty if p draws nothing.
+func tightBounds(p Path) bounds {
+	b := emptyBounds
+	add := func(q point) {
+		v := f32.Vec2{float32(q.x), float32(q.y)}
+		b = b.union(bounds{v, v})
+	}
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case MoveTo:
+			start = seg.To
+		case LineTo:
+			add(pt(pen))
+			add(pt(seg.To))
+		case QuadTo:
+			// A quadratic Bézier is a degree-elevated cubic.
+			p0, p1, p2 := pt(pen), pt(seg.Ctrl), pt(seg.To)
+			c1 := point{p0.x + 2*(p1.x-p0.x)/3, p0.y + 2*(p1.y-p0.y)/3}
+			c2 := point{p2.x + 2*(p1.x-p2.x)/3, p2.y + 2*(p1.y-p2.y)/3}
+			addCubicBounds(add, p0, c1, c2, p2)
+		case CubeTo:
+			addCubicBounds(add, pt(pen), pt(seg.Ctrl0), pt(seg.Ctrl1), pt(seg.To))
+		case ArcTo:
+			// Bound the arc's cubic approximation, whose tolerance is far
+			// finer than float32 precision.
+			r := math.Max(math.Abs(float64(seg.Radii[0])), math.Abs(float64(seg.Radii[1])))
+			q := pen
+			for _, c := range seg.Cubics(pen, float32(r/65536)) {
+				switch c := c.(type) {
+				case LineTo:
+					add(pt(q))
+					add(pt(c.To))
+				case CubeTo:
+					addCubicBounds(add, pt(q), pt(c.Ctrl0), pt(c.Ctrl1), pt(c.To))
+				}
+				q = c.EndPoint(q, pen)
+			}
+		case ClosePath:
+			add(pt(pen))
+			add(pt(start))
+		}
+		pen = seg.EndPoint(pen, start)
+	}
+	return b
+}
+
+// addCubicBounds calls add with the end points of a cubic Bézier curve and
+// with the points where its x or y coordinate has a turning point.
+func addCubicBounds(add func(point), p0, p1, p2, p3 point) {
+	add(p0)
+	add(p3)
+	eval := func(t float64) {
+		if (0 < t) && (t < 1) {
+			u := 1 - t
+			a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+			add(point{a*p0.x + b*p1.x + c*p2.x + d*p3.x, a*p0.y + b*p1.y + c*p2.y + d*p3.y})
+		}
+	}
+	for _, v := range [2][4]float64{{p0.x, p1.x, p2.x, p3.x}, {p0.y, p1.y, p2.y, p3.y}} {
+		// The derivative, divided by 3, is a*t*t + b*t + c.
+		a := -v[0] + 3*v[1] - 3*v[2] + v[3]
+		b := 2 * (v[0] - 2*v[1] + v[2])
+		c := v[1] - v[0]
+		if a == 0 {
+			if b != 0 {
+				eval(-c / b)
+			}
+			continue
+		}
+		disc := b*b - 4*a*c
+		if disc < 0 {
+			continue
+		}
+		// This form of the quadratic formula avoids cancellation when a is
+		// small.
+		q := -(b + math.Copysign(math.Sqrt(disc), b)) / 2
+		eval(q / a)
+		if q != 0 {
+			eval(c / q)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+func nearRect(a, b lowlevel.Rectangle) bool {
+	for i := 0; i < 2; i++ {
+		if math.Abs(float64(a.Min[i]-b.Min[i])) > 1e-3 || math.Abs(float64(a.Max[i]-b.Max[i])) > 1e-3 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPathBounds(t *testing.T) {
+	testCases := []struct {
+		desc string
+		p    ivg.Path
+		want lowlevel.Rectangle
+	}{{
+		desc: "empty",
+		p:    ivg.Path{},
+		want: lowlevel.Rectangle{},
+	}, {
+		desc: "move only",
+		p:    ivg.Path{ivg.MoveTo{To: f32.Vec2{3, 4}}},
+		want: lowlevel.Rectangle{},
+	}, {
+		desc: "rect",
+		p:    rect(1, 2, 3, 4),
+		want: lowlevel.Rectangle{Min: f32.Vec2{1, 2}, Max: f32.Vec2{3, 4}},
+	}, {
+		desc: "two sub-paths",
+		p:    append(rect(1, 2, 3, 4), rect(-5, 0, -4, 1)...),
+		want: lowlevel.Rectangle{Min: f32.Vec2{-5, 0}, Max: f32.Vec2{3, 4}},
+	}, {
+		desc: "quad",
+		p: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{5, 10}, To: f32.Vec2{10, 0}},
+			ivg.ClosePath{},
+		},
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{10, 5}},
+	}, {
+		desc: "cube",
+		p: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.CubeTo{Ctrl0: f32.Vec2{0, 10}, Ctrl1: f32.Vec2{10, 10}, To: f32.Vec2{10, 0}},
+			ivg.ClosePath{},
+		},
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{10, 7.5}},
+	}, {
+		desc: "s-shaped cube",
+		p: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.CubeTo{Ctrl0: f32.Vec2{0, 12}, Ctrl1: f32.Vec2{10, -12}, To: f32.Vec2{10, 0}},
+		},
+		// The extrema, at t = (3 ± √3) / 6, are ±2√3.
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, -3.4641016}, Max: f32.Vec2{10, 3.4641016}},
+	}, {
+		desc: "circle",
+		p: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{10, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{10, 0}},
+			ivg.ClosePath{},
+		},
+		want: lowlevel.Rectangle{Min: f32.Vec2{-10, -10}, Max: f32.Vec2{10, 10}},
+	}, {
+		desc: "half circle",
+		p: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{10, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{10, 10}, Sweep: true, To: f32.Vec2{-10, 0}},
+			ivg.ClosePath{},
+		},
+		want: lowlevel.Rectangle{Min: f32.Vec2{-10, 0}, Max: f32.Vec2{10, 10}},
+	}}
+	for _, tc := range testCases {
+		if got := tc.p.Bounds(); !nearRect(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+// hitTestGraphic returns a Graphic whose Shapes are:
+//   - #0: an opaque square from (0, 0) to (10, 10).
+//   - #1: a transparent square from (5, 5) to (15, 15).
+//   - #2: an even-odd square from (20, 0) to (30, 10), with a hole from
+//     (23, 3) to (27, 7).
+//   - #3: a Use of a unit square, scaled by 4 and moved to (40, 0).
+//   - #4: a non-zero square from (20, 20) to (30, 30), whose inner square
+//     from (23, 23) to (27, 27), wound the same way, is not a hole.
+//   - #5: a Use of a missing Symbol.
+func hitTestGraphic() *ivg.Graphic {
+	inner := func(b *ivg.Builder, x0, y0, x1, y1 float32) *ivg.Builder {
+		return b.MoveTo(x0, y0).LineTo(x1, y0).LineTo(x1, y1).LineTo(x0, y1).ClosePath()
+	}
+	b := ivg.NewBuilder()
+	inner(b, 0, 0, 10, 10).Fill(lowlevel.PaletteIndexColor(0))
+	inner(b, 5, 5, 15, 15).Fill(lowlevel.RGBAColor(color.RGBA{}))
+	b.SetFillRule(lowlevel.FillRuleEvenOdd)
+	inner(inner(b, 20, 0, 30, 10), 23, 3, 27, 7).Fill(lowlevel.PaletteIndexColor(0))
+	b.SetFillRule(lowlevel.FillRuleNonZero)
+	b.DefineSymbol("unit", inner(ivg.NewBuilder(), 0, 0, 1, 1).Fill(lowlevel.PaletteIndexColor(0)).Graphic().Shapes)
+	b.Use("unit", f64.Aff3{4, 0, 40, 0, 4, 0})
+	inner(inner(b, 20, 20, 30, 30), 23, 23, 27, 27).Fill(lowlevel.PaletteIndexColor(0))
+	b.Use("missing", f64.Aff3{1, 0, 0, 0, 1, 0})
+	return b.Graphic()
+}
+
+func TestHitTest(t *testing.T) {
+	g := hitTestGraphic()
+	testCases := []struct {
+		x, y float32
+		want []ivg.ShapeID
+	}{
+		{2, 2, []ivg.ShapeID{0}},
+		{7, 7, []ivg.ShapeID{1, 0}},
+		{12, 12, []ivg.ShapeID{1}},
+		{21, 5, []ivg.ShapeID{2}},
+		{25, 5, nil},
+		{42, 2, []ivg.ShapeID{3}},
+		{45, 2, nil},
+		{21, 25, []ivg.ShapeID{4}},
+		{25, 25, []ivg.ShapeID{4}},
+		{50, 50, nil},
+		{-1, 5, nil},
+	}
+	for _, tc := range testCases {
+		if got := g.HitTest(tc.x, tc.y); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("(%v, %v): got %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestShapeBounds(t *testing.T) {
+	g := hitTestGraphic()
+	testCases := []struct {
+		id   ivg.ShapeID
+		want lowlevel.Rectangle
+	}{
+		{-1, lowlevel.Rectangle{}},
+		{0, lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{10, 10}}},
+		{1, lowlevel.Rectangle{Min: f32.Vec2{5, 5}, Max: f32.Vec2{15, 15}}},
+		{2, lowlevel.Rectangle{Min: f32.Vec2{20, 0}, Max: f32.Vec2{30, 10}}},
+		{3, lowlevel.Rectangle{Min: f32.Vec2{40, 0}, Max: f32.Vec2{44, 4}}},
+		{4, lowlevel.Rectangle{Min: f32.Vec2{20, 20}, Max: f32.Vec2{30, 30}}},
+		{5, lowlevel.Rectangle{}},
+		{6, lowlevel.Rectangle{}},
+	}
+	for _, tc := range testCases {
+		if got := g.ShapeBounds(tc.id); got != tc.want {
+			t.Errorf("#%d: got %v, want %v", tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestTightBounds(t *testing.T) {
+	transparent := lowlevel.RGBAColor(color.RGBA{})
+	clear := lowlevel.DefaultPalette
+	clear[1] = color.RGBA{}
+	clearStops := []ivg.GradientStop{
+		{Offset: 0, Color: transparent},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{})},
+	}
+	fadeStops := []ivg.GradientStop{
+		{Offset: 0, Color: transparent},
+		{Offset: 1, Color: lowlevel.PaletteIndexColor(0)},
+	}
+	inf := float32(math.Inf(+1))
+	testCases := []struct {
+		desc string
+		b    *ivg.Builder
+		want lowlevel.Rectangle
+	}{{
+		desc: "blank",
+		b:    ivg.NewBuilder(),
+		want: lowlevel.Rectangle{},
+	}, {
+		desc: "rect",
+		b:    ivg.NewBuilder().Rect(2, 3, 8, 17).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{2, 3}, Max: f32.Vec2{10, 20}},
+	}, {
+		desc: "circle",
+		b:    ivg.NewBuilder().Circle(-4, 4, 10).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{-14, -6}, Max: f32.Vec2{6, 14}},
+	}, {
+		desc: "not clipped to the viewBox",
+		b:    ivg.NewBuilder().Rect(-40, -8, 80, 16).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{-40, -8}, Max: f32.Vec2{40, 8}},
+	}, {
+		desc: "transparent color",
+		b: ivg.NewBuilder().
+			Rect(-30, -30, 60, 60).Fill(transparent).
+			Rect(0, 0, 4, 4).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{4, 4}},
+	}, {
+		desc: "transparent palette entry",
+		b: ivg.NewBuilder().SetPalette(&clear).
+			Rect(-30, -30, 60, 60).Fill(lowlevel.PaletteIndexColor(1)).
+			Rect(0, 0, 4, 4).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{4, 4}},
+	}, {
+		desc: "transparent gradient",
+		b: ivg.NewBuilder().
+			Rect(-30, -30, 60, 60).FillPaint(ivg.LinearGradient(clearStops, -30, 0, 30, 0, ivg.GradientSpreadPad)).
+			Rect(0, 0, 4, 4).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{4, 4}},
+	}, {
+		desc: "gradient",
+		b: ivg.NewBuilder().
+			Rect(-30, -30, 60, 60).FillPaint(ivg.LinearGradient(fadeStops, -30, 0, 30, 0, ivg.GradientSpreadPad)).
+			Rect(0, 0, 4, 4).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{-30, -30}, Max: f32.Vec2{30, 30}},
+	}, {
+		desc: "every level of detail",
+		b: ivg.NewBuilder().
+			SetLOD(0, 80).Rect(-20, -20, 4, 4).Fill(lowlevel.PaletteIndexColor(0)).
+			SetLOD(80, inf).Rect(10, 10, 4, 4).Fill(lowlevel.PaletteIndexColor(0)),
+		want: lowlevel.Rectangle{Min: f32.Vec2{-20, -20}, Max: f32.Vec2{14, 14}},
+	}}
+	for _, tc := range testCases {
+		src, err := tc.b.Bytes()
+		if err != nil {
+			t.Errorf("%s: Bytes: %v", tc.desc, err)
+			continue
+		}
+		got, err := ivg.TightBounds(src)
+		if err != nil {
+			t.Errorf("%s: TightBounds: %v", tc.desc, err)
+			continue
+		}
+		if !nearRect(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+
+	if _, err := ivg.TightBounds([]byte("not an IconVG graphic")); err == nil {
+		t.Errorf("invalid: got nil error, want non-nil")
+	}
+}
+
+func TestTightBoundsTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ivg.TightBounds(src)
+		if err != nil {
+			t.Errorf("%s: TightBounds: %v", tc, err)
+			continue
+		}
+		if !(got.Min[0] < got.Max[0]) || !(got.Min[1] < got.Max[1]) {
+			t.Errorf("%s: got empty bounds %v", tc, got)
+			continue
+		}
+		// The bounds are within the union of the Shapes' bounds.
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tc, err)
+		}
+		union := g.Shapes[0].Path.Bounds()
+		for _, s := range g.Shapes[1:] {
+			b := s.Path.Bounds()
+			for i := 0; i < 2; i++ {
+				union.Min[i] = float32(math.Min(float64(union.Min[i]), float64(b.Min[i])))
+				union.Max[i] = float32(math.Max(float64(union.Max[i]), float64(b.Max[i])))
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if (got.Min[i] < union.Min[i]) || (union.Max[i] < got.Max[i]) {
+				t.Errorf("%s: got %v, want within %v", tc, got, union)
+				break
+			}
+		}
+	}
+}
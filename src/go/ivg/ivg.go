@@ -0,0 +1,162 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg provides a high-level, in-memory model of an IconVG graphic.
+//
+// Where package lowlevel exposes the virtual machine's byte code, with its
+// registers and relative, smooth and repeated drawing ops, package ivg
+// exposes the graphic as a list of filled Shapes, each having a Paint and a
+// Path of absolute path Segments. A Graphic can be inspected and mutated
+// programmatically.
+//
+// IconVG is specified at
+// https://github.com/google/iconvg/blob/main/spec/iconvg-spec.md
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Graphic is an IconVG graphic: its metadata and the Shapes that it draws, in
+// painter's order.
+type Graphic struct {
+	Metadata lowlevel.Metadata
+	Shapes   []Shape
+
+	// Symbols are named, reusable sub-drawings, like SVG's <symbol> elements,
+	// that Shapes can draw by reference (see Use). A Symbol's Shapes can
+	// themselves refer to other Symbols, but not recursively.
+	Symbols map[string][]Shape
+}
+
+// NewGraphic returns an empty Graphic with the default metadata.
+func NewGraphic() *Graphic {
+	return &Graphic{
+		Metadata: lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		},
+	}
+}
+
+// Shape is a filled path or, if Use is non-nil, an instance of a Symbol.
+type Shape struct {
+	Paint Paint
+	Path  Path
+
+	// LOD0 and LOD1 are the level of detail bounds. The Shape is only drawn
+	// when the height H in pixels of the rasterization satisfies (LOD0 <= H)
+	// and (H < LOD1). DefaultLOD0 and DefaultLOD1 mean that the Shape is
+	// always drawn.
+	LOD0, LOD1 float32
+
+	// FillRule is the rule that Path is filled with. IconVG byte code only
+	// has the non-zero rule, so the Encoder rewrites an even-odd Path with
+	// EvenOddToNonZero.
+	FillRule lowlevel.FillRule
+
+	// Use, if non-nil, makes the Shape draw a Symbol's Shapes, instead of
+	// filling Path with Paint. Its level of detail bounds still apply, in
+	// addition to those of the Symbol's Shapes.
+	Use *Use
+}
+
+// Use is a reference, like SVG's <use> element, from a Shape to one of the
+// Graphic's Symbols.
+//
+// IconVG byte code has no mechanism to call or jump to shared drawing ops, so
+// the Encoder expands each Use into copies of its Symbol's Shapes (see
+// Graphic.Expand). Decoding never gives a non-nil Use.
+type Use struct {
+	// Symbol is the name of the Symbol, a key of the Graphic's Symbols.
+	Symbol string
+
+	// Transform maps from the Symbol's coordinate space to graphic
+	// coordinate space, like Graphic.Transform's argument. The zero value
+	// collapses the Symbol to a point: the identity transformation is
+	// f64.Aff3{1, 0, 0, 0, 1, 0}.
+	Transform f64.Aff3
+}
+
+// DefaultLOD0 and DefaultLOD1 are the initial level of detail bounds: zero
+// and positive infinity.
+var (
+	DefaultLOD0 = float32(0)
+	DefaultLOD1 = float32(math.Inf(+1))
+)
+
+// Paint is how a Shape is filled: either with a flat color or, if Gradient is
+// non-nil, with a gradient.
+type Paint struct {
+	Color    lowlevel.Color
+	Gradient *Gradient
+}
+
+// GradientShape is whether a Gradient is linear or radial.
+type GradientShape uint8
+
+const (
+	GradientShapeLinear GradientShape = 0
+	GradientShapeRadial GradientShape = 1
+)
+
+// GradientSpread is how to spread a Gradient past its nominal bounds (from
+// offset being 0.0 to offset being 1.0).
+type GradientSpread uint8
+
+const (
+	GradientSpreadNone    GradientSpread = 0
+	GradientSpreadPad     GradientSpread = 1
+	GradientSpreadReflect GradientSpread = 2
+	GradientSpreadRepeat  GradientSpread = 3
+)
+
+// Gradient is a linear or radial gradient.
+//
+// See the "Colors and Gradients" section in the specification for details.
+type Gradient struct {
+	Shape  GradientShape
+	Spread GradientSpread
+
+	// Transform maps from graphic coordinate space (defined by the metadata's
+	// ViewBox) to gradient coordinate space. Gradient coordinate space is
+	// where a linear gradient ranges from x=0 to x=1, and a radial gradient
+	// has center (0, 0) and radius 1.
+	Transform f32.Aff3
+
+	// Focal is, for a radial gradient, where offset 0 is, in gradient
+	// coordinate space, like SVG's fx and fy attributes. The zero value is the
+	// center. Each offset's color is on a circle whose center moves from
+	// Focal to (0, 0), and whose radius grows from 0 to 1, as the offset goes
+	// from 0 to 1. A Focal point outside the unit circle is moved onto it.
+	//
+	// IconVG byte code has no focal point, so the Encoder approximates a
+	// Gradient with one by cutting its Shape into bands, each painted with a
+	// centered radial gradient, so that offsets are within 1/128 of the
+	// focal gradient's. If any stop is translucent, the bands can show faint
+	// anti-aliasing seams. Decoding never gives a non-zero Focal.
+	Focal f32.Vec2
+
+	Stops []GradientStop
+}
+
+// GradientStop is a color/offset stop of a Gradient.
+type GradientStop struct {
+	Offset float32
+	Color  lowlevel.Color
+}
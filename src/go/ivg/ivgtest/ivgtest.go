@@ -0,0 +1,206 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgtest provides utilities for testing code that produces or
+// consumes IconVG graphics via package ivg.
+package ivgtest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+// CheckRoundTrip checks the round-trip property: that encoding g, with the
+// default ivg.Encoder, and decoding the result gives a Graphic equivalent to
+// g. It returns an error describing the first difference, if any, or why g
+// could not be encoded.
+//
+// Equivalent Graphics have the same metadata, the same number of Shapes and,
+// for each Shape, the same level of detail, paint and path. Path and gradient
+// numbers need only be approximately equal, as the encoder quantizes
+// coordinates and may choose relative encodings, whose round-off depends on
+// the pen position. Arc rotations need only be equal modulo one revolution.
+func CheckRoundTrip(g *ivg.Graphic) error {
+	enc, err := ivg.Encode(g)
+	if err != nil {
+		return fmt.Errorf("ivgtest: encode: %w", err)
+	}
+	h, err := ivg.Decode(enc, nil)
+	if err != nil {
+		return fmt.Errorf("ivgtest: decode: %w", err)
+	}
+
+	if !g.Metadata.Equal(&h.Metadata) {
+		return fmt.Errorf("ivgtest: metadata: got %v, want %v", h.Metadata, g.Metadata)
+	}
+	c := comparer{tolerance: tolerance(g)}
+	shapes := g.Shapes[:0:0]
+	for _, s := range g.Shapes {
+		// The encoder drops empty Shapes, which draw nothing.
+		if len(s.Path) > 0 {
+			shapes = append(shapes, s)
+		}
+	}
+	if len(h.Shapes) != len(shapes) {
+		return fmt.Errorf("ivgtest: number of shapes: got %d, want %d", len(h.Shapes), len(shapes))
+	}
+	for i := range shapes {
+		if err := c.shape(&h.Shapes[i], &shapes[i]); err != nil {
+			return fmt.Errorf("ivgtest: shape #%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// tolerance returns how far apart two of g's path coordinates can be and still
+// be considered equivalent. The encoding's precision is relative to the
+// magnitude of the numbers encoded, so the tolerance grows with g's extent.
+func tolerance(g *ivg.Graphic) float64 {
+	extent := 1.0
+	for _, s := range g.Shapes {
+		for _, seg := range s.Path {
+			points := []f32.Vec2(nil)
+			switch seg := seg.(type) {
+			case ivg.MoveTo:
+				points = []f32.Vec2{seg.To}
+			case ivg.LineTo:
+				points = []f32.Vec2{seg.To}
+			case ivg.QuadTo:
+				points = []f32.Vec2{seg.Ctrl, seg.To}
+			case ivg.CubeTo:
+				points = []f32.Vec2{seg.Ctrl0, seg.Ctrl1, seg.To}
+			case ivg.ArcTo:
+				points = []f32.Vec2{seg.To}
+			}
+			for _, p := range points {
+				for _, f := range p {
+					if f := math.Abs(float64(f)); (f > extent) && !math.IsInf(f, 0) {
+						extent = f
+					}
+				}
+			}
+		}
+	}
+	return extent / (1 << 16)
+}
+
+type comparer struct {
+	tolerance float64
+}
+
+func (c *comparer) shape(got, want *ivg.Shape) error {
+	if !sameFloat(got.LOD0, want.LOD0) || !sameFloat(got.LOD1, want.LOD1) {
+		return fmt.Errorf("level of detail: got [%g, %g), want [%g, %g)",
+			got.LOD0, got.LOD1, want.LOD0, want.LOD1)
+	}
+	if err := c.paint(&got.Paint, &want.Paint); err != nil {
+		return err
+	}
+	if len(got.Path) != len(want.Path) {
+		return fmt.Errorf("number of path segments: got %d, want %d", len(got.Path), len(want.Path))
+	}
+	for i := range want.Path {
+		if err := c.segment(got.Path[i], want.Path[i]); err != nil {
+			return fmt.Errorf("path segment #%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *comparer) paint(got, want *ivg.Paint) error {
+	if (got.Gradient == nil) != (want.Gradient == nil) {
+		return fmt.Errorf("paint: got gradient %t, want gradient %t", got.Gradient != nil, want.Gradient != nil)
+	} else if want.Gradient == nil {
+		if got.Color != want.Color {
+			return fmt.Errorf("paint: got %v, want %v", got.Color, want.Color)
+		}
+		return nil
+	}
+
+	g, w := got.Gradient, want.Gradient
+	if (g.Shape != w.Shape) || (g.Spread != w.Spread) || (len(g.Stops) != len(w.Stops)) {
+		return fmt.Errorf("gradient: got %v %v with %d stops, want %v %v with %d stops",
+			g.Shape, g.Spread, len(g.Stops), w.Shape, w.Spread, len(w.Stops))
+	}
+	for i := range w.Transform {
+		if !c.closeFloat(g.Transform[i], w.Transform[i]) {
+			return fmt.Errorf("gradient: transform: got %v, want %v", g.Transform, w.Transform)
+		}
+	}
+	for i := range w.Stops {
+		if (g.Stops[i].Color != w.Stops[i].Color) || !c.closeFloat(g.Stops[i].Offset, w.Stops[i].Offset) {
+			return fmt.Errorf("gradient: stop #%d: got %v, want %v", i, g.Stops[i], w.Stops[i])
+		}
+	}
+	return nil
+}
+
+func (c *comparer) segment(got, want ivg.Segment) error {
+	ok := false
+	switch w := want.(type) {
+	case ivg.MoveTo:
+		g, o := got.(ivg.MoveTo)
+		ok = o && c.closeVec2(g.To, w.To)
+	case ivg.LineTo:
+		g, o := got.(ivg.LineTo)
+		ok = o && c.closeVec2(g.To, w.To)
+	case ivg.QuadTo:
+		g, o := got.(ivg.QuadTo)
+		ok = o && c.closeVec2(g.Ctrl, w.Ctrl) && c.closeVec2(g.To, w.To)
+	case ivg.CubeTo:
+		g, o := got.(ivg.CubeTo)
+		ok = o && c.closeVec2(g.Ctrl0, w.Ctrl0) && c.closeVec2(g.Ctrl1, w.Ctrl1) && c.closeVec2(g.To, w.To)
+	case ivg.ArcTo:
+		g, o := got.(ivg.ArcTo)
+		ok = o && sameFloat(g.Radii[0], w.Radii[0]) && sameFloat(g.Radii[1], w.Radii[1]) &&
+			sameAngle(g.XAxisRotation, w.XAxisRotation) &&
+			(g.LargeArc == w.LargeArc) && (g.Sweep == w.Sweep) && c.closeVec2(g.To, w.To)
+	case ivg.ClosePath:
+		_, ok = got.(ivg.ClosePath)
+	}
+	if !ok {
+		return fmt.Errorf("got %#v, want %#v", got, want)
+	}
+	return nil
+}
+
+func (c *comparer) closeVec2(got, want f32.Vec2) bool {
+	return c.closeFloat(got[0], want[0]) && c.closeFloat(got[1], want[1])
+}
+
+func (c *comparer) closeFloat(got, want float32) bool {
+	if sameFloat(got, want) {
+		return true
+	}
+	return math.Abs(float64(got)-float64(want)) <= c.tolerance
+}
+
+// sameAngle is whether x and y, measured in revolutions, are approximately
+// the same angle. The encoder normalizes angles to the range [0, 1).
+func sameAngle(x, y float32) bool {
+	if sameFloat(x, y) {
+		return true
+	}
+	d := float64(x) - float64(y)
+	d -= math.Round(d)
+	return math.Abs(d) <= 1.0/(1<<16)
+}
+
+// sameFloat is like ==, except that NaN is the same as NaN.
+func sameFloat(x, y float32) bool {
+	return (x == y) || ((x != x) && (y != y))
+}
@@ -0,0 +1,473 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	errInvalidJSONFillRule = errors.New("iconvg: invalid JSON fill rule")
+	errInvalidJSONGradient = errors.New("iconvg: invalid JSON gradient")
+	errInvalidJSONNumber   = errors.New("iconvg: invalid JSON number")
+	errInvalidJSONPaint    = errors.New("iconvg: invalid JSON paint")
+	errInvalidJSONPalette  = errors.New("iconvg: invalid JSON palette")
+	errInvalidJSONSegment  = errors.New("iconvg: invalid JSON segment")
+	errInvalidJSONUse      = errors.New("iconvg: invalid JSON use")
+)
+
+type jsonGraphic struct {
+	ViewBox [4]jsonFloat           `json:"viewBox"`
+	Palette []lowlevel.Color       `json:"palette,omitempty"`
+	Shapes  []jsonShape            `json:"shapes"`
+	Symbols map[string][]jsonShape `json:"symbols,omitempty"`
+}
+
+type jsonShape struct {
+	Paint    *jsonPaint    `json:"paint,omitempty"`
+	Use      *jsonUse      `json:"use,omitempty"`
+	LOD      *[2]jsonFloat `json:"lod,omitempty"`
+	FillRule string        `json:"fillRule,omitempty"`
+	Path     Path          `json:"path"`
+}
+
+type jsonUse struct {
+	Symbol    string     `json:"symbol"`
+	Transform [6]float64 `json:"transform"`
+}
+
+type jsonPaint struct {
+	Color    *lowlevel.Color `json:"color,omitempty"`
+	Gradient *jsonGradient   `json:"gradient,omitempty"`
+}
+
+type jsonGradient struct {
+	Shape     string             `json:"shape"`
+	Spread    string             `json:"spread"`
+	Transform [6]jsonFloat       `json:"transform"`
+	Focal     *[2]jsonFloat      `json:"focal,omitempty"`
+	Stops     []jsonGradientStop `json:"stops"`
+}
+
+type jsonGradientStop struct {
+	Offset jsonFloat      `json:"offset"`
+	Color  lowlevel.Color `json:"color"`
+}
+
+var (
+	gradientShapeNames  = [2]string{"linear", "radial"}
+	gradientSpreadNames = [4]string{"none", "pad", "reflect", "repeat"}
+)
+
+// MarshalJSON implements the json.Marshaler interface. The JSON form of a
+// Graphic is an object like:
+//
+//	{
+//	  "viewBox": [-32, -32, 32, 32],
+//	  "palette": ["#ff0000ff"],
+//	  "shapes": [{
+//	    "paint": {"color": {"palette": 0}},
+//	    "lod": [0, 80],
+//	    "path": [["M", -8, -8], ["L", 8, -8], ["A", 8, 8, 0, false, true, 8, 8], ["Z"]]
+//	  }]
+//	}
+//
+// The palette omits trailing entries that are the default (opaque black), and
+// is omitted if it is the DefaultPalette. A Shape's level of detail is omitted
+// if it is the default, as is its "fillRule" ("nonzero" or "evenodd"). Colors
+// are as per lowlevel.Color's MarshalJSON. Path segments are arrays whose
+// first element is "M", "L", "Q", "C", "A" or "Z", for MoveTo, LineTo, QuadTo,
+// CubeTo, ArcTo and ClosePath, followed by the segment's fields in order. A
+// gradient paint is like:
+//
+//	{"gradient": {
+//	  "shape": "linear",
+//	  "spread": "pad",
+//	  "transform": [0.03125, 0, 0.5, 0, 0, 0],
+//	  "stops": [{"offset": 0, "color": "#000000ff"}, {"offset": 1, "color": "#ffffffff"}]
+//	}}
+//
+// A radial gradient with a non-zero Focal point also has a "focal" field,
+// such as "focal": [0.25, 0].
+//
+// Symbols, if any, are a "symbols" object that maps each name to an array of
+// Shapes. A Shape with a Use has no "paint" but a "use" field instead, such as
+// "use": {"symbol": "tick", "transform": [1, 0, 0, 0, 1, 0]}, and an empty
+// "path".
+//
+// Numbers that are not finite, which JSON cannot represent, are the strings
+// "NaN", "+Inf" and "-Inf". Other numbers are written with the fewest digits
+// that parse back to the same float32, so that converting from IconVG to JSON
+// and back loses nothing.
+func (g *Graphic) MarshalJSON() ([]byte, error) {
+	vb := &g.Metadata.ViewBox
+	j := jsonGraphic{
+		ViewBox: [4]jsonFloat{jsonFloat(vb.Min[0]), jsonFloat(vb.Min[1]), jsonFloat(vb.Max[0]), jsonFloat(vb.Max[1])},
+		Shapes:  make([]jsonShape, len(g.Shapes)),
+	}
+	if pal := &g.Metadata.Palette; *pal != lowlevel.DefaultPalette {
+		n := len(pal)
+		for (n > 0) && (pal[n-1] == lowlevel.DefaultPalette[n-1]) {
+			n--
+		}
+		for _, c := range pal[:n] {
+			j.Palette = append(j.Palette, lowlevel.RGBAColor(c))
+		}
+	}
+	for i := range g.Shapes {
+		marshalShape(&j.Shapes[i], &g.Shapes[i])
+	}
+	if g.Symbols != nil {
+		j.Symbols = make(map[string][]jsonShape, len(g.Symbols))
+		for name, shapes := range g.Symbols {
+			js := make([]jsonShape, len(shapes))
+			for i := range shapes {
+				marshalShape(&js[i], &shapes[i])
+			}
+			j.Symbols[name] = js
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting the JSON
+// form that MarshalJSON produces. Omitted fields take their default values.
+func (g *Graphic) UnmarshalJSON(b []byte) error {
+	vb := lowlevel.DefaultViewBox
+	j := jsonGraphic{
+		ViewBox: [4]jsonFloat{jsonFloat(vb.Min[0]), jsonFloat(vb.Min[1]), jsonFloat(vb.Max[0]), jsonFloat(vb.Max[1])},
+	}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	h := NewGraphic()
+	h.Metadata.ViewBox = lowlevel.Rectangle{
+		Min: f32.Vec2{float32(j.ViewBox[0]), float32(j.ViewBox[1])},
+		Max: f32.Vec2{float32(j.ViewBox[2]), float32(j.ViewBox[3])},
+	}
+	if len(j.Palette) > len(h.Metadata.Palette) {
+		return errInvalidJSONPalette
+	}
+	for i, c := range j.Palette {
+		rgba, ok := c.RGBA()
+		if !ok {
+			return errInvalidJSONPalette
+		}
+		h.Metadata.Palette[i] = rgba
+	}
+
+	h.Shapes = make([]Shape, len(j.Shapes))
+	for i := range j.Shapes {
+		if err := unmarshalShape(&h.Shapes[i], &j.Shapes[i]); err != nil {
+			return err
+		}
+	}
+	if j.Symbols != nil {
+		h.Symbols = make(map[string][]Shape, len(j.Symbols))
+		for name, js := range j.Symbols {
+			shapes := make([]Shape, len(js))
+			for i := range js {
+				if err := unmarshalShape(&shapes[i], &js[i]); err != nil {
+					return err
+				}
+			}
+			h.Symbols[name] = shapes
+		}
+	}
+	*g = *h
+	return nil
+}
+
+// marshalShape sets js to the JSON form of s.
+func marshalShape(js *jsonShape, s *Shape) {
+	js.Path = s.Path
+	if js.Path == nil {
+		js.Path = Path{}
+	}
+	if (s.LOD0 != DefaultLOD0) || (s.LOD1 != DefaultLOD1) {
+		js.LOD = &[2]jsonFloat{jsonFloat(s.LOD0), jsonFloat(s.LOD1)}
+	}
+	if s.FillRule != lowlevel.FillRuleNonZero {
+		js.FillRule = s.FillRule.String()
+	}
+	if u := s.Use; u != nil {
+		js.Use = &jsonUse{Symbol: u.Symbol, Transform: u.Transform}
+		return
+	}
+	js.Paint = &jsonPaint{}
+	if gr := s.Paint.Gradient; gr == nil {
+		c := s.Paint.Color
+		js.Paint.Color = &c
+	} else {
+		jg := &jsonGradient{
+			Shape:  gradientShapeNames[gr.Shape&1],
+			Spread: gradientSpreadNames[gr.Spread&3],
+			Stops:  make([]jsonGradientStop, len(gr.Stops)),
+		}
+		for k, f := range gr.Transform {
+			jg.Transform[k] = jsonFloat(f)
+		}
+		if gr.Focal != (f32.Vec2{}) {
+			jg.Focal = &[2]jsonFloat{jsonFloat(gr.Focal[0]), jsonFloat(gr.Focal[1])}
+		}
+		for k, stop := range gr.Stops {
+			jg.Stops[k] = jsonGradientStop{jsonFloat(stop.Offset), stop.Color}
+		}
+		js.Paint.Gradient = jg
+	}
+}
+
+// unmarshalShape sets s to the Shape whose JSON form is js.
+func unmarshalShape(s *Shape, js *jsonShape) error {
+	s.Path = js.Path
+	s.LOD0, s.LOD1 = DefaultLOD0, DefaultLOD1
+	if js.LOD != nil {
+		s.LOD0, s.LOD1 = float32(js.LOD[0]), float32(js.LOD[1])
+	}
+	switch js.FillRule {
+	case "", "nonzero":
+	case "evenodd":
+		s.FillRule = lowlevel.FillRuleEvenOdd
+	default:
+		return errInvalidJSONFillRule
+	}
+	if ju := js.Use; ju != nil {
+		if js.Paint != nil {
+			return errInvalidJSONUse
+		}
+		s.Use = &Use{Symbol: ju.Symbol, Transform: ju.Transform}
+		return nil
+	}
+	if js.Paint == nil {
+		return errInvalidJSONPaint
+	}
+	switch jg := js.Paint.Gradient; {
+	case (jg == nil) && (js.Paint.Color != nil):
+		s.Paint.Color = *js.Paint.Color
+	case (jg != nil) && (js.Paint.Color == nil):
+		shape := lookupName(gradientShapeNames[:], jg.Shape)
+		spread := lookupName(gradientSpreadNames[:], jg.Spread)
+		if (shape == 0xff) || (spread == 0xff) {
+			return errInvalidJSONGradient
+		}
+		gr := &Gradient{
+			Shape:  GradientShape(shape),
+			Spread: GradientSpread(spread),
+			Stops:  make([]GradientStop, len(jg.Stops)),
+		}
+		for k, f := range jg.Transform {
+			gr.Transform[k] = float32(f)
+		}
+		if jg.Focal != nil {
+			gr.Focal = f32.Vec2{float32(jg.Focal[0]), float32(jg.Focal[1])}
+		}
+		for k, stop := range jg.Stops {
+			gr.Stops[k] = GradientStop{float32(stop.Offset), stop.Color}
+		}
+		s.Paint.Gradient = gr
+	default:
+		return errInvalidJSONPaint
+	}
+	return nil
+}
+
+// lookupName returns the index of name in names, or 0xff if it is not there.
+func lookupName(names []string, name string) uint8 {
+	for i, n := range names {
+		if n == name {
+			return uint8(i)
+		}
+	}
+	return 0xff
+}
+
+// MarshalJSON implements the json.Marshaler interface, encoding the Path as
+// an array of segments, each an array like ["L", x, y]. See Graphic's
+// MarshalJSON.
+func (p Path) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	b := []byte{'['}
+	for i, seg := range p {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		switch seg := seg.(type) {
+		case MoveTo:
+			b = appendSegment(b, "M", seg.To[0], seg.To[1])
+		case LineTo:
+			b = appendSegment(b, "L", seg.To[0], seg.To[1])
+		case QuadTo:
+			b = appendSegment(b, "Q", seg.Ctrl[0], seg.Ctrl[1], seg.To[0], seg.To[1])
+		case CubeTo:
+			b = appendSegment(b, "C", seg.Ctrl0[0], seg.Ctrl0[1], seg.Ctrl1[0], seg.Ctrl1[1], seg.To[0], seg.To[1])
+		case ArcTo:
+			b = append(b, `["A",`...)
+			b = appendFloat(b, seg.Radii[0])
+			b = append(b, ',')
+			b = appendFloat(b, seg.Radii[1])
+			b = append(b, ',')
+			b = appendFloat(b, seg.XAxisRotation)
+			b = append(b, ',')
+			b = strconv.AppendBool(b, seg.LargeArc)
+			b = append(b, ',')
+			b = strconv.AppendBool(b, seg.Sweep)
+			b = append(b, ',')
+			b = appendFloat(b, seg.To[0])
+			b = append(b, ',')
+			b = appendFloat(b, seg.To[1])
+			b = append(b, ']')
+		case ClosePath:
+			b = append(b, `["Z"]`...)
+		default:
+			return nil, errInvalidJSONSegment
+		}
+	}
+	return append(b, ']'), nil
+}
+
+func appendSegment(b []byte, cmd string, coords ...float32) []byte {
+	b = append(b, `["`...)
+	b = append(b, cmd...)
+	b = append(b, '"')
+	for _, f := range coords {
+		b = append(b, ',')
+		b = appendFloat(b, f)
+	}
+	return append(b, ']')
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *Path) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*p = nil
+		return nil
+	}
+	var segs [][]json.RawMessage
+	if err := json.Unmarshal(b, &segs); err != nil {
+		return err
+	}
+	q := make(Path, 0, len(segs))
+	for _, args := range segs {
+		if len(args) == 0 {
+			return errInvalidJSONSegment
+		}
+		cmd := ""
+		if err := json.Unmarshal(args[0], &cmd); err != nil {
+			return errInvalidJSONSegment
+		}
+		args = args[1:]
+
+		if cmd == "A" {
+			if len(args) != 7 {
+				return errInvalidJSONSegment
+			}
+			s := ArcTo{}
+			var f [5]jsonFloat
+			for k, a := range [5]json.RawMessage{args[0], args[1], args[2], args[5], args[6]} {
+				if err := f[k].UnmarshalJSON(a); err != nil {
+					return err
+				}
+			}
+			if err := json.Unmarshal(args[3], &s.LargeArc); err != nil {
+				return errInvalidJSONSegment
+			} else if err := json.Unmarshal(args[4], &s.Sweep); err != nil {
+				return errInvalidJSONSegment
+			}
+			s.Radii = f32.Vec2{float32(f[0]), float32(f[1])}
+			s.XAxisRotation = float32(f[2])
+			s.To = f32.Vec2{float32(f[3]), float32(f[4])}
+			q = append(q, s)
+			continue
+		}
+
+		n := map[string]int{"M": 2, "L": 2, "Q": 4, "C": 6, "Z": 0}
+		want, ok := n[cmd]
+		if !ok || (len(args) != want) {
+			return errInvalidJSONSegment
+		}
+		var f [6]float32
+		for k, a := range args {
+			x := jsonFloat(0)
+			if err := x.UnmarshalJSON(a); err != nil {
+				return err
+			}
+			f[k] = float32(x)
+		}
+		switch cmd {
+		case "M":
+			q = append(q, MoveTo{To: f32.Vec2{f[0], f[1]}})
+		case "L":
+			q = append(q, LineTo{To: f32.Vec2{f[0], f[1]}})
+		case "Q":
+			q = append(q, QuadTo{Ctrl: f32.Vec2{f[0], f[1]}, To: f32.Vec2{f[2], f[3]}})
+		case "C":
+			q = append(q, CubeTo{Ctrl0: f32.Vec2{f[0], f[1]}, Ctrl1: f32.Vec2{f[2], f[3]}, To: f32.Vec2{f[4], f[5]}})
+		case "Z":
+			q = append(q, ClosePath{})
+		}
+	}
+	*p = q
+	return nil
+}
+
+// jsonFloat is a float32 whose JSON form is a number, or a string for values
+// that are not finite.
+type jsonFloat float32
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	return appendFloat(nil, float32(f)), nil
+}
+
+func (f *jsonFloat) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case `"NaN"`:
+		*f = jsonFloat(math.NaN())
+		return nil
+	case `"+Inf"`:
+		*f = jsonFloat(math.Inf(+1))
+		return nil
+	case `"-Inf"`:
+		*f = jsonFloat(math.Inf(-1))
+		return nil
+	}
+	x, err := strconv.ParseFloat(string(b), 32)
+	if err != nil {
+		return errInvalidJSONNumber
+	}
+	*f = jsonFloat(x)
+	return nil
+}
+
+func appendFloat(b []byte, f float32) []byte {
+	switch x := float64(f); {
+	case math.IsNaN(x):
+		return append(b, `"NaN"`...)
+	case math.IsInf(x, +1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(x, -1):
+		return append(b, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(b, float64(f), 'g', -1, 32)
+}
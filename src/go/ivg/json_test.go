@@ -0,0 +1,182 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+func TestPathJSON(t *testing.T) {
+	testCases := []struct {
+		p    ivg.Path
+		want string
+	}{
+		{nil, `null`},
+		{ivg.Path{}, `[]`},
+		{ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{-8, -8}},
+			ivg.LineTo{To: f32.Vec2{8, -8}},
+			ivg.QuadTo{Ctrl: f32.Vec2{0.5, 1}, To: f32.Vec2{2, 3}},
+			ivg.CubeTo{Ctrl0: f32.Vec2{1, 2}, Ctrl1: f32.Vec2{3, 4}, To: f32.Vec2{5, 6}},
+			ivg.ArcTo{Radii: f32.Vec2{8, 8}, XAxisRotation: 0.25, Sweep: true, To: f32.Vec2{8, 8}},
+			ivg.ClosePath{},
+		}, `[["M",-8,-8],["L",8,-8],["Q",0.5,1,2,3],["C",1,2,3,4,5,6],["A",8,8,0.25,false,true,8,8],["Z"]]`},
+		{ivg.Path{ivg.LineTo{To: f32.Vec2{0.1, 1e-7}}}, `[["L",0.1,1e-07]]`},
+		{ivg.Path{ivg.LineTo{To: f32.Vec2{float32(math.Inf(+1)), float32(math.Inf(-1))}}}, `[["L","+Inf","-Inf"]]`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.p)
+		if err != nil {
+			t.Errorf("%v: Marshal: %v", tc.p, err)
+			continue
+		}
+		if got := string(b); got != tc.want {
+			t.Errorf("%v: Marshal:\ngot  %s\nwant %s", tc.p, got, tc.want)
+		}
+		got := ivg.Path(nil)
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%v: Unmarshal: %v", tc.p, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.p) {
+			t.Errorf("%v: Unmarshal: got %v, want %v", tc.p, got, tc.p)
+		}
+	}
+}
+
+func TestPathJSONErrors(t *testing.T) {
+	testCases := []string{
+		`[[]]`,
+		`[["X",1,2]]`,
+		`[["L",1]]`,
+		`[["L",1,2,3]]`,
+		`[["L","one",2]]`,
+		`[["A",1,2,3,4,5,6,7]]`,
+		`[["Z",1]]`,
+		`{}`,
+	}
+	for _, tc := range testCases {
+		p := ivg.Path(nil)
+		if err := json.Unmarshal([]byte(tc), &p); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc)
+		}
+	}
+}
+
+func TestGraphicJSON(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tc, err)
+		}
+		want, err := ivg.Encode(g)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc, err)
+		}
+
+		b, err := json.Marshal(g)
+		if err != nil {
+			t.Errorf("%s: Marshal: %v", tc, err)
+			continue
+		}
+		g2 := &ivg.Graphic{}
+		if err := json.Unmarshal(b, g2); err != nil {
+			t.Errorf("%s: Unmarshal: %v", tc, err)
+			continue
+		}
+		got, err := ivg.Encode(g2)
+		if err != nil {
+			t.Errorf("%s: Encode: %v", tc, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: JSON round trip changed the encoding", tc)
+		}
+	}
+}
+
+func TestGraphicJSONDefaults(t *testing.T) {
+	g := &ivg.Graphic{}
+	if err := json.Unmarshal([]byte(`{"shapes":[{"paint":{"color":{"palette":0}},"path":[["M",0,0],["L",1,1],["Z"]]}]}`), g); err != nil {
+		t.Fatal(err)
+	}
+	want := ivg.NewBuilder().Graphic().Metadata
+	if g.Metadata.ViewBox != want.ViewBox {
+		t.Errorf("ViewBox: got %v, want %v", g.Metadata.ViewBox, want.ViewBox)
+	}
+	if g.Metadata.Palette != want.Palette {
+		t.Errorf("Palette: got %v, want %v", g.Metadata.Palette, want.Palette)
+	}
+	if len(g.Shapes) != 1 {
+		t.Fatalf("Shapes: got %d, want 1", len(g.Shapes))
+	}
+	if s := g.Shapes[0]; (s.LOD0 != ivg.DefaultLOD0) || (s.LOD1 != ivg.DefaultLOD1) {
+		t.Errorf("LOD: got %v, %v, want %v, %v", s.LOD0, s.LOD1, ivg.DefaultLOD0, ivg.DefaultLOD1)
+	}
+}
+
+func TestGraphicJSONFocal(t *testing.T) {
+	testCases := []struct {
+		focal     f32.Vec2
+		wantField bool
+	}{
+		{f32.Vec2{0.25, 0}, true},
+		{f32.Vec2{-0.5, 0.125}, true},
+		{f32.Vec2{}, false},
+	}
+	for _, tc := range testCases {
+		g := ivg.NewBuilder().
+			MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath().
+			FillPaint(ivg.FocalRadialGradient(testStops, 0, 0, 20, 20*tc.focal[0], 20*tc.focal[1], ivg.GradientSpreadPad)).
+			Graphic()
+		b, err := json.Marshal(g)
+		if err != nil {
+			t.Fatalf("%v: Marshal: %v", tc.focal, err)
+		}
+		if got := bytes.Contains(b, []byte(`"focal"`)); got != tc.wantField {
+			t.Errorf("%v: got \"focal\" field %t, want %t:\n%s", tc.focal, got, tc.wantField, b)
+		}
+		g2 := &ivg.Graphic{}
+		if err := json.Unmarshal(b, g2); err != nil {
+			t.Fatalf("%v: Unmarshal: %v", tc.focal, err)
+		}
+		if got := g2.Shapes[0].Paint.Gradient.Focal; got != tc.focal {
+			t.Errorf("%v: round trip: got %v, want %v", tc.focal, got, tc.focal)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// numPaintSlots is the number of CREG registers that the optimizing encoder
+// loads flat colors and gradients into. Paint slot adj is CREG[CSEL-adj], with
+// CSEL being zero outside of loading a gradient, so that StartPath can refer
+// to any of them. The slots are CREG[0] and CREG[58 .. 63], which gradient
+// stops only overwrite if there are more than 48 of them.
+const numPaintSlots = 7
+
+// encodePaintOptimized is like encodePaint, but it returns the paint slot,
+// holding p, for StartPath to use. It only encodes the registers that are not
+// already known to hold the right values.
+func (x *encoder) encodePaintOptimized(p *Paint) (adj uint8, retErr error) {
+	c, nStops := p.Color, 0
+	if g := p.Gradient; g != nil {
+		nStops = len(g.Stops)
+		if len(g.Stops) > MaxGradientStops {
+			return 0, errTooManyGradientStops
+		}
+		c = lowlevel.RGBAColor(color.RGBA{
+			R: uint8(len(g.Stops)),
+			G: uint8(g.Spread&0x03)<<6 | gradientBase,
+			B: 0x80 | uint8(g.Shape&0x01)<<6 | gradientBase,
+			A: 0x00,
+		})
+		for i, f := range g.Transform {
+			x.setNReg(gradientBase-6+uint8(i), f)
+		}
+		for i, stop := range g.Stops {
+			x.setCReg(gradientBase+uint8(i), stop.Color, true)
+			x.setNReg(gradientBase+uint8(i), stop.Offset)
+		}
+		if x.cSel != 0 {
+			x.dst.SetCSel(0)
+			x.cSel = 0
+		}
+	}
+
+	// Use a slot that already holds c or, failing that, the least recently
+	// used slot. A gradient cannot use a slot that holds one of its stops.
+	best := -1
+	for i := range x.slotUses {
+		j := paintSlot(uint8(i))
+		if (gradientBase <= j) && (int(j) < gradientBase+nStops) {
+			continue
+		} else if (x.cRegKnown&(1<<j) != 0) && (x.cReg[j] == c) {
+			best = i
+			break
+		} else if (best < 0) || (x.slotUses[i] < x.slotUses[best]) {
+			best = i
+		}
+	}
+	x.setCReg(paintSlot(uint8(best)), c, false)
+	x.numUses++
+	x.slotUses[best] = x.numUses
+	return uint8(best), nil
+}
+
+// paintSlot returns the CREG index of paint slot adj.
+func paintSlot(adj uint8) uint8 {
+	return -adj & 0x3f
+}
+
+// setCReg sets CREG[i] to c, unless it is already known to hold c. If incr,
+// it may leave CSEL incremented past i.
+func (x *encoder) setCReg(i uint8, c lowlevel.Color, incr bool) {
+	if (x.cRegKnown&(1<<i) != 0) && (x.cReg[i] == c) {
+		return
+	}
+	if adj := (x.cSel - i) & 0x3f; adj < 7 && !(incr && adj == 0) {
+		x.dst.SetCReg(adj, false, c)
+	} else {
+		if adj != 0 {
+			x.dst.SetCSel(i)
+		}
+		if incr {
+			x.dst.SetCReg(0, true, c)
+			x.cSel = (i + 1) & 0x3f
+		} else {
+			x.dst.SetCReg(0, false, c)
+			x.cSel = i
+		}
+	}
+	x.cReg[i] = c
+	x.cRegKnown |= 1 << i
+}
+
+// setNReg sets NREG[i] to f, unless it is already known to hold f. It may
+// leave NSEL incremented past i.
+func (x *encoder) setNReg(i uint8, f float32) {
+	if (x.nRegKnown&(1<<i) != 0) && (x.nReg[i] == f) {
+		return
+	}
+	if adj := (x.nSel - i) & 0x3f; (adj != 0) && (adj < 7) {
+		x.dst.SetNReg(adj, false, f)
+	} else {
+		if adj != 0 {
+			x.dst.SetNSel(i)
+		}
+		x.dst.SetNReg(0, true, f)
+		x.nSel = (i + 1) & 0x3f
+	}
+	x.nReg[i] = f
+	x.nRegKnown |= 1 << i
+}
+
+// usesCRegColors returns whether any of the shapes' colors depend on the CREG
+// registers' contents. The optimizing encodePaint changes which registers
+// hold what, so it cannot be used for such shapes.
+func usesCRegColors(shapes []Shape) bool {
+	// Resolving a Color against two different sets of CREG registers gives
+	// different results if the Color refers to them.
+	var pal lowlevel.Palette
+	var cReg0, cReg1 [64]color.RGBA
+	for i := range cReg1 {
+		cReg1[i] = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	dependent := func(c lowlevel.Color) bool {
+		return c.Resolve(&pal, &cReg0) != c.Resolve(&pal, &cReg1)
+	}
+
+	for i := range shapes {
+		p := &shapes[i].Paint
+		if p.Gradient == nil {
+			if dependent(p.Color) {
+				return true
+			}
+			continue
+		}
+		for _, stop := range p.Gradient.Stops {
+			if dependent(stop.Color) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// optimizeShapes returns shapes with their paths cleaned up (see cleanPath)
+// and with consecutive shapes merged where that does not change how they
+// render. It does not modify shapes or their paths.
+func optimizeShapes(shapes []Shape) []Shape {
+	dst := make([]Shape, 0, len(shapes))
+	dstBounds := bounds{}
+	for _, s := range shapes {
+		s.Path = cleanPath(s.Path)
+		if len(s.Path) == 0 {
+			continue
+		}
+		b := pathBounds(s.Path)
+		if n := len(dst); n > 0 {
+			if last := &dst[n-1]; canMerge(last, &s) && dstBounds.disjoint(b) {
+				last.Path = append(last.Path, s.Path...)
+				dstBounds = dstBounds.union(b)
+				continue
+			}
+		}
+		dst = append(dst, s)
+		dstBounds = b
+	}
+	return dst
+}
+
+// canMerge returns whether a and b have the same paint, level of detail and
+// fill rule and whether their paths start with a MoveTo, so that the two
+// paths can be concatenated. It does not consider whether they overlap.
+func canMerge(a *Shape, b *Shape) bool {
+	if (a.LOD0 != b.LOD0) || (a.LOD1 != b.LOD1) || (a.FillRule != b.FillRule) ||
+		!samePaint(&a.Paint, &b.Paint) {
+		return false
+	}
+	_, ok0 := a.Path[0].(MoveTo)
+	_, ok1 := b.Path[0].(MoveTo)
+	return ok0 && ok1
+}
+
+func samePaint(a *Paint, b *Paint) bool {
+	if (a.Gradient == nil) || (b.Gradient == nil) {
+		return (a.Gradient == nil) && (b.Gradient == nil) && (a.Color == b.Color)
+	}
+	g, h := a.Gradient, b.Gradient
+	if (g.Shape != h.Shape) || (g.Spread != h.Spread) || (g.Transform != h.Transform) ||
+		(len(g.Stops) != len(h.Stops)) {
+		return false
+	}
+	for i := range g.Stops {
+		if g.Stops[i] != h.Stops[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanPath returns p without the segments that do not affect how p renders:
+// zero-length lineTo's, lineTo's back to the start of a sub-path that is about
+// to be closed (explicitly or implicitly), redundant ClosePath's and empty
+// sub-paths. Every sub-path of the result starts with a MoveTo. It does not
+// modify p. If p does not start with a MoveTo, it returns p unchanged, for the
+// encoder to reject.
+func cleanPath(p Path) Path {
+	if len(p) == 0 {
+		return p
+	} else if _, ok := p[0].(MoveTo); !ok {
+		return p
+	}
+
+	dst := make(Path, 0, len(p))
+	pen, start, closed := f32.Vec2{}, f32.Vec2{}, false
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case MoveTo:
+			dst = trimSubPath(dst, start)
+			dst = append(dst, seg)
+			pen, start, closed = seg.To, seg.To, false
+			continue
+		case ClosePath:
+			dst = trimSubPath(dst, start)
+			if n := len(dst); n > 0 {
+				if _, ok := dst[n-1].(ClosePath); !ok {
+					dst = append(dst, seg)
+				}
+			}
+			pen, closed = start, true
+			continue
+		case LineTo:
+			if seg.To == pen {
+				continue
+			}
+		}
+		if closed {
+			// A sub-path continues from the start of a closed one.
+			dst = append(dst, MoveTo{start})
+			closed = false
+		}
+		dst = append(dst, seg)
+		pen = seg.EndPoint(pen, start)
+	}
+	return trimSubPath(dst, start)
+}
+
+// trimSubPath removes, from the end of p, lineTo's back to start, the start
+// of the last sub-path, and then that sub-path's MoveTo if it is empty.
+func trimSubPath(p Path, start f32.Vec2) Path {
+	for n := len(p); n > 0; n-- {
+		if l, ok := p[n-1].(LineTo); !ok || (l.To != start) {
+			break
+		}
+		p = p[:n-1]
+	}
+	if n := len(p); n > 0 {
+		if _, ok := p[n-1].(MoveTo); ok {
+			p = p[:n-1]
+		}
+	}
+	return p
+}
+
+// bounds is an axis-aligned bounding box. It is empty if Min[0] > Max[0].
+type bounds struct {
+	Min, Max f32.Vec2
+}
+
+var emptyBounds = bounds{Min: f32.Vec2{+1, +1}, Max: f32.Vec2{-1, -1}}
+
+// disjoint returns whether b and c are separated by a positive distance. It
+// returns false if either contains NaN.
+func (b bounds) disjoint(c bounds) bool {
+	return (b.Max[0] < c.Min[0]) || (c.Max[0] < b.Min[0]) ||
+		(b.Max[1] < c.Min[1]) || (c.Max[1] < b.Min[1])
+}
+
+func (b bounds) union(c bounds) bounds {
+	if b.Min[0] > b.Max[0] {
+		return c
+	} else if c.Min[0] > c.Max[0] {
+		return b
+	}
+	for i := 0; i < 2; i++ {
+		if c.Min[i] < b.Min[i] || c.Min[i] != c.Min[i] {
+			b.Min[i] = c.Min[i]
+		}
+		if c.Max[i] > b.Max[i] || c.Max[i] != c.Max[i] {
+			b.Max[i] = c.Max[i]
+		}
+	}
+	return b
+}
+
+// pathBounds returns the bounds of p's points, including Bézier control
+// points, which bound the curves. An ArcTo's curve is not bounded by its
+// points, so a Path containing one has bounds that contain NaN, which are
+// not disjoint from any other bounds.
+func pathBounds(p Path) bounds {
+	b := emptyBounds
+	add := func(v f32.Vec2) {
+		b = b.union(bounds{v, v})
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case MoveTo:
+			add(seg.To)
+		case LineTo:
+			add(seg.To)
+		case QuadTo:
+			add(seg.Ctrl)
+			add(seg.To)
+		case CubeTo:
+			add(seg.Ctrl0)
+			add(seg.Ctrl1)
+			add(seg.To)
+		case ArcTo:
+			nan := float32(math.NaN())
+			add(f32.Vec2{nan, nan})
+		}
+	}
+	return b
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// checkSameRendering checks that two IconVG graphics render the same, give or
+// take tolerance in each channel of each pixel.
+func checkSameRendering(t *testing.T, desc string, got, want []byte, tolerance int) {
+	t.Helper()
+	m0, err := render.Image(want, 64, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", desc, err)
+	}
+	m1, err := render.Image(got, 64, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", desc, err)
+	}
+	for i := range m0.Pix {
+		if d := int(m0.Pix[i]) - int(m1.Pix[i]); (d < -tolerance) || (tolerance < d) {
+			t.Errorf("%s: pixel (%d, %d): got %v, want %v", desc, (i/4)%64, (i/4)/64,
+				m1.Pix[i&^3:i&^3+4], m0.Pix[i&^3:i&^3+4])
+			return
+		}
+	}
+}
+
+func TestOptimizeTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc, err)
+		}
+		plain, err := (&ivg.Encoder{}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc, err)
+		}
+		optimized, err := (&ivg.Encoder{Optimize: true}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc, err)
+		}
+		if len(optimized) > len(plain) {
+			t.Errorf("%s: got %d bytes, want at most %d", tc, len(optimized), len(plain))
+		}
+		checkSameRendering(t, tc, optimized, plain, 2)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	blue := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})
+	square := func(b *ivg.Builder, x, y float32) *ivg.Builder {
+		return b.MoveTo(x, y).LineTo(x+8, y).LineTo(x+8, y+8).LineTo(x, y+8).ClosePath()
+	}
+	testCases := []struct {
+		desc        string
+		build       func(b *ivg.Builder)
+		wantShapes  int
+		wantSmaller bool
+	}{{
+		desc: "disjoint shapes with the same paint",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			square(b, 10, 10).Fill(red)
+		},
+		wantShapes:  1,
+		wantSmaller: true,
+	}, {
+		desc: "overlapping shapes with the same paint",
+		build: func(b *ivg.Builder) {
+			square(b, -4, -4).Fill(red)
+			square(b, 0, 0).Fill(red)
+		},
+		wantShapes:  2,
+		wantSmaller: false,
+	}, {
+		desc: "disjoint shapes with different paints",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			square(b, 10, 10).Fill(blue)
+		},
+		wantShapes:  2,
+		wantSmaller: false,
+	}, {
+		desc: "alternating paints",
+		build: func(b *ivg.Builder) {
+			square(b, -20, -20).Fill(red)
+			square(b, -4, -4).Fill(blue)
+			square(b, 0, 0).Fill(red)
+			square(b, 4, 4).Fill(blue)
+		},
+		wantShapes:  4,
+		wantSmaller: true,
+	}, {
+		desc: "zero-length lines",
+		build: func(b *ivg.Builder) {
+			b.MoveTo(0, 0).LineTo(0, 0).LineTo(8, 0).LineTo(8, 0).LineTo(8, 8).LineTo(0, 0).ClosePath().Fill(red)
+		},
+		wantShapes:  1,
+		wantSmaller: true,
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.build(b)
+		g := b.Graphic()
+		plain, err := (&ivg.Encoder{}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		optimized, err := (&ivg.Encoder{Optimize: true}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if len(optimized) > len(plain) {
+			t.Errorf("%s: got %d bytes, want at most %d", tc.desc, len(optimized), len(plain))
+		} else if tc.wantSmaller && (len(optimized) == len(plain)) {
+			t.Errorf("%s: got %d bytes, want fewer", tc.desc, len(optimized))
+		}
+		h, err := ivg.Decode(optimized, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := len(h.Shapes); got != tc.wantShapes {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, got, tc.wantShapes)
+		}
+		checkSameRendering(t, tc.desc, optimized, plain, 0)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"golang.org/x/image/math/f32"
+)
+
+// Path is a sequence of Segments. It consists of one or more sub-paths, each
+// starting with a MoveTo. All coordinates are absolute, in graphic coordinate
+// space.
+//
+// A Path's sub-paths are filled as if they were closed, whether or not they
+// end with an explicit ClosePath.
+type Path []Segment
+
+// Segment is a path segment: one of MoveTo, LineTo, QuadTo, CubeTo, ArcTo or
+// ClosePath.
+type Segment interface {
+	// EndPoint returns the pen position after the Segment, given the pen
+	// position before the Segment and the start of the current sub-path.
+	EndPoint(pen, start f32.Vec2) f32.Vec2
+
+	isSegment()
+}
+
+// MoveTo starts a new sub-path at To.
+type MoveTo struct {
+	To f32.Vec2
+}
+
+// LineTo is a straight line to To.
+type LineTo struct {
+	To f32.Vec2
+}
+
+// QuadTo is a quadratic Bézier curve with control point Ctrl.
+type QuadTo struct {
+	Ctrl, To f32.Vec2
+}
+
+// CubeTo is a cubic Bézier curve with control points Ctrl0 and Ctrl1.
+type CubeTo struct {
+	Ctrl0, Ctrl1, To f32.Vec2
+}
+
+// ArcTo is an elliptical arc, with the same semantics as SVG's "A" path data
+// command. XAxisRotation is measured in revolutions (a fraction of 360
+// degrees), not degrees or radians.
+type ArcTo struct {
+	Radii         f32.Vec2
+	XAxisRotation float32
+	LargeArc      bool
+	Sweep         bool
+	To            f32.Vec2
+}
+
+// ClosePath closes the current sub-path, drawing a straight line back to its
+// start.
+type ClosePath struct{}
+
+func (s MoveTo) EndPoint(pen, start f32.Vec2) f32.Vec2    { return s.To }
+func (s LineTo) EndPoint(pen, start f32.Vec2) f32.Vec2    { return s.To }
+func (s QuadTo) EndPoint(pen, start f32.Vec2) f32.Vec2    { return s.To }
+func (s CubeTo) EndPoint(pen, start f32.Vec2) f32.Vec2    { return s.To }
+func (s ArcTo) EndPoint(pen, start f32.Vec2) f32.Vec2     { return s.To }
+func (s ClosePath) EndPoint(pen, start f32.Vec2) f32.Vec2 { return start }
+
+func (MoveTo) isSegment()    {}
+func (LineTo) isSegment()    {}
+func (QuadTo) isSegment()    {}
+func (CubeTo) isSegment()    {}
+func (ArcTo) isSegment()     {}
+func (ClosePath) isSegment() {}
@@ -0,0 +1,164 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"golang.org/x/image/math/f32"
+)
+
+// circleK is the distance, as a fraction of the radius, from a quarter
+// circle's end points to the control points of the cubic Bézier curve that
+// approximates it. This value, unlike the common 4*(√2-1)/3 (about 0.55228),
+// minimizes the maximum radial error, which is then about 0.02% of the
+// radius.
+const circleK = 0.5519150244935106
+
+// The Builder methods in this file each add one or more closed sub-paths to
+// the current path, clockwise (as seen with the y axis increasing downwards),
+// like the SVG basic shapes that they mirror. Like the other path methods,
+// they do not fill the path, so that several primitives can make up one
+// Shape. A clockwise primitive inside another clockwise primitive does not
+// make a hole under the non-zero fill rule, but does under the even-odd rule
+// (see SetFillRule).
+
+// Rect adds a rectangle with top-left corner (x, y). It does nothing if width
+// or height is not positive.
+func (b *Builder) Rect(x, y, width, height float32) *Builder {
+	if !(width > 0) || !(height > 0) {
+		return b
+	}
+	return b.MoveTo(x, y).
+		LineTo(x+width, y).
+		LineTo(x+width, y+height).
+		LineTo(x, y+height).
+		ClosePath()
+}
+
+// RoundedRect is like Rect but with elliptical corners of radii rx and ry.
+// Like SVG's <rect>, the radii are clamped to half of the width and height,
+// and if either is zero, the corners are square.
+func (b *Builder) RoundedRect(x, y, width, height, rx, ry float32) *Builder {
+	if !(width > 0) || !(height > 0) {
+		return b
+	}
+	rx = clamp32(rx, 0, width/2)
+	ry = clamp32(ry, 0, height/2)
+	if (rx == 0) || (ry == 0) {
+		return b.Rect(x, y, width, height)
+	}
+	kx, ky := circleK*rx, circleK*ry
+	x1, y1 := x+width, y+height
+	return b.MoveTo(x+rx, y).
+		LineTo(x1-rx, y).
+		CubeTo(x1-rx+kx, y, x1, y+ry-ky, x1, y+ry).
+		LineTo(x1, y1-ry).
+		CubeTo(x1, y1-ry+ky, x1-rx+kx, y1, x1-rx, y1).
+		LineTo(x+rx, y1).
+		CubeTo(x+rx-kx, y1, x, y1-ry+ky, x, y1-ry).
+		LineTo(x, y+ry).
+		CubeTo(x, y+ry-ky, x+rx-kx, y, x+rx, y).
+		ClosePath()
+}
+
+// Circle adds a circle with center (cx, cy) and radius r. It does nothing if
+// r is not positive.
+func (b *Builder) Circle(cx, cy, r float32) *Builder {
+	return b.Ellipse(cx, cy, r, r)
+}
+
+// Ellipse adds an axis-aligned ellipse with center (cx, cy) and radii rx and
+// ry, as four cubic Bézier curves, starting at (cx+rx, cy). It does nothing if
+// rx or ry is not positive.
+func (b *Builder) Ellipse(cx, cy, rx, ry float32) *Builder {
+	if !(rx > 0) || !(ry > 0) {
+		return b
+	}
+	kx, ky := circleK*rx, circleK*ry
+	return b.MoveTo(cx+rx, cy).
+		CubeTo(cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry).
+		CubeTo(cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy).
+		CubeTo(cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry).
+		CubeTo(cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy).
+		ClosePath()
+}
+
+// Polygon adds a polygon through the given points, like SVG's <polygon>. Its
+// winding is the points' order. It does nothing if there are fewer than two
+// points.
+func (b *Builder) Polygon(points ...f32.Vec2) *Builder {
+	if len(points) < 2 {
+		return b
+	}
+	b.MoveTo(points[0][0], points[0][1])
+	for _, p := range points[1:] {
+		b.LineTo(p[0], p[1])
+	}
+	return b.ClosePath()
+}
+
+// RegularPolygon adds a regular polygon with n vertices on the circle with
+// center (cx, cy) and radius r. With zero rotation, the first vertex is at
+// the top, (cx, cy-r). rotation is measured clockwise, in revolutions (a
+// fraction of 360 degrees), like ArcTo's XAxisRotation. It does nothing if n
+// is less than 3 or r is not positive.
+func (b *Builder) RegularPolygon(cx, cy, r float32, n int, rotation float32) *Builder {
+	if (n < 3) || !(r > 0) {
+		return b
+	}
+	return b.Polygon(ring(cx, cy, n, rotation, r)...)
+}
+
+// Star adds a star with n points, whose tips are on the circle with center
+// (cx, cy) and radius outerR and whose inner vertices, between the tips, are
+// on the concentric circle of radius innerR. With zero rotation, the first
+// tip is at the top, (cx, cy-outerR). rotation is as per RegularPolygon. It
+// does nothing if n is less than 2 or either radius is not positive.
+//
+// For a regular five-pointed star, whose edges are collinear in pairs,
+// innerR is about 0.382 times outerR.
+func (b *Builder) Star(cx, cy, outerR, innerR float32, n int, rotation float32) *Builder {
+	if (n < 2) || !(outerR > 0) || !(innerR > 0) {
+		return b
+	}
+	return b.Polygon(ring(cx, cy, 2*n, rotation, outerR, innerR)...)
+}
+
+// ring returns n points around the center (cx, cy), evenly spaced in angle,
+// clockwise from the top, rotated by rotation revolutions. Their radii cycle
+// through radii.
+func ring(cx, cy float32, n int, rotation float32, radii ...float32) []f32.Vec2 {
+	points := make([]f32.Vec2, n)
+	for i := range points {
+		a := 2 * math.Pi * (float64(rotation) + float64(i)/float64(n))
+		sin, cos := math.Sincos(a)
+		r := float64(radii[i%len(radii)])
+		points[i] = f32.Vec2{
+			cx + float32(r*sin),
+			cy - float32(r*cos),
+		}
+	}
+	return points
+}
+
+func clamp32(x, lo, hi float32) float32 {
+	if !(x > lo) {
+		return lo
+	} else if x > hi {
+		return hi
+	}
+	return x
+}
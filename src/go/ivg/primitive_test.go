@@ -0,0 +1,168 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// signedArea returns the area enclosed by p, flattening its CubeTo segments,
+// and its number of sub-paths. The area is positive for sub-paths that are
+// clockwise, with the y axis increasing downwards.
+func signedArea(t *testing.T, p ivg.Path) (a float64, loops int) {
+	t.Helper()
+	start, pen := f32.Vec2{}, f32.Vec2{}
+	lineTo := func(q f32.Vec2) {
+		a += float64(pen[0])*float64(q[1]) - float64(q[0])*float64(pen[1])
+		pen = q
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			start, pen = seg.To, seg.To
+			loops++
+		case ivg.LineTo:
+			lineTo(seg.To)
+		case ivg.CubeTo:
+			p0 := pen
+			for i := 1; i <= 64; i++ {
+				u := float32(i) / 64
+				v := 1 - u
+				var q f32.Vec2
+				for k := range q {
+					q[k] = v*v*v*p0[k] + 3*v*v*u*seg.Ctrl0[k] + 3*v*u*u*seg.Ctrl1[k] + u*u*u*seg.To[k]
+				}
+				lineTo(q)
+			}
+		case ivg.ClosePath:
+			lineTo(start)
+		default:
+			t.Fatalf("got %T segment, want only MoveTo, LineTo, CubeTo and ClosePath", seg)
+		}
+	}
+	return a / 2, loops
+}
+
+func TestPrimitives(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		add       func(b *ivg.Builder)
+		wantArea  float64
+		wantLoops int
+	}{
+		{"rect", func(b *ivg.Builder) { b.Rect(1, 2, 10, 20) }, 200, 1},
+		{"rect zero width", func(b *ivg.Builder) { b.Rect(1, 2, 0, 20) }, 0, 0},
+		{"rect negative height", func(b *ivg.Builder) { b.Rect(1, 2, 10, -20) }, 0, 0},
+		{"rounded rect", func(b *ivg.Builder) { b.RoundedRect(0, 0, 20, 10, 2, 2) }, 200 - (4-math.Pi)*4, 1},
+		{"rounded rect clamped", func(b *ivg.Builder) { b.RoundedRect(0, 0, 20, 10, 100, 100) }, math.Pi * 10 * 5, 1},
+		{"rounded rect square corners", func(b *ivg.Builder) { b.RoundedRect(0, 0, 20, 10, 0, 2) }, 200, 1},
+		{"rounded rect negative radius", func(b *ivg.Builder) { b.RoundedRect(0, 0, 20, 10, -3, 2) }, 200, 1},
+		{"rounded rect empty", func(b *ivg.Builder) { b.RoundedRect(0, 0, 0, 10, 2, 2) }, 0, 0},
+		{"circle", func(b *ivg.Builder) { b.Circle(5, -5, 10) }, math.Pi * 100, 1},
+		{"circle zero radius", func(b *ivg.Builder) { b.Circle(5, -5, 0) }, 0, 0},
+		{"ellipse", func(b *ivg.Builder) { b.Ellipse(0, 0, 10, 5) }, math.Pi * 50, 1},
+		{"ellipse negative radius", func(b *ivg.Builder) { b.Ellipse(0, 0, 10, -5) }, 0, 0},
+		{"triangle", func(b *ivg.Builder) {
+			b.Polygon(f32.Vec2{0, 0}, f32.Vec2{10, 0}, f32.Vec2{0, 10})
+		}, 50, 1},
+		{"counter-clockwise triangle", func(b *ivg.Builder) {
+			b.Polygon(f32.Vec2{0, 0}, f32.Vec2{0, 10}, f32.Vec2{10, 0})
+		}, -50, 1},
+		{"polygon of two points", func(b *ivg.Builder) { b.Polygon(f32.Vec2{0, 0}, f32.Vec2{10, 0}) }, 0, 1},
+		{"polygon of one point", func(b *ivg.Builder) { b.Polygon(f32.Vec2{0, 0}) }, 0, 0},
+		{"square", func(b *ivg.Builder) { b.RegularPolygon(0, 0, 10, 4, 0) }, 200, 1},
+		{"hexagon", func(b *ivg.Builder) { b.RegularPolygon(0, 0, 10, 6, 0.1) }, 1.5 * math.Sqrt(3) * 100, 1},
+		{"digon", func(b *ivg.Builder) { b.RegularPolygon(0, 0, 10, 2, 0) }, 0, 0},
+		{"star", func(b *ivg.Builder) { b.Star(0, 0, 10, 4, 5, 0) }, 5 * 10 * 4 * math.Sin(math.Pi/5), 1},
+		{"two-pointed star", func(b *ivg.Builder) { b.Star(0, 0, 10, 4, 2, 0) }, 2 * 10 * 4 * math.Sin(math.Pi/2), 1},
+		{"one-pointed star", func(b *ivg.Builder) { b.Star(0, 0, 10, 4, 1, 0) }, 0, 0},
+		{"star zero inner radius", func(b *ivg.Builder) { b.Star(0, 0, 10, 0, 5, 0) }, 0, 0},
+		{"several primitives", func(b *ivg.Builder) {
+			b.Rect(0, 0, 10, 10).Circle(20, 20, 5).RegularPolygon(-20, -20, 5, 3, 0)
+		}, 100 + math.Pi*25 + 0.75*math.Sqrt(3)*25, 3},
+	}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.add(b)
+		b.Fill(lowlevel.PaletteIndexColor(0))
+		g := b.Graphic()
+		if tc.wantLoops == 0 {
+			if len(g.Shapes) != 0 {
+				t.Errorf("%s: got %d shapes, want 0", tc.desc, len(g.Shapes))
+			}
+			continue
+		}
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: got %d shapes, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		a, loops := signedArea(t, g.Shapes[0].Path)
+		if loops != tc.wantLoops {
+			t.Errorf("%s: got %d sub-paths, want %d", tc.desc, loops, tc.wantLoops)
+		}
+		if math.Abs(a-tc.wantArea) > 1e-3*math.Max(1, math.Abs(tc.wantArea)) {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, a, tc.wantArea)
+		}
+	}
+}
+
+func TestPrimitiveVertices(t *testing.T) {
+	testCases := []struct {
+		desc string
+		add  func(b *ivg.Builder)
+		want []f32.Vec2
+	}{
+		{"square", func(b *ivg.Builder) { b.RegularPolygon(10, 20, 4, 4, 0) },
+			[]f32.Vec2{{10, 16}, {14, 20}, {10, 24}, {6, 20}}},
+		{"rotated square", func(b *ivg.Builder) { b.RegularPolygon(10, 20, 4, 4, 0.25) },
+			[]f32.Vec2{{14, 20}, {10, 24}, {6, 20}, {10, 16}}},
+		{"two-pointed star", func(b *ivg.Builder) { b.Star(0, 0, 8, 2, 2, 0) },
+			[]f32.Vec2{{0, -8}, {2, 0}, {0, 8}, {-2, 0}}},
+		{"rect", func(b *ivg.Builder) { b.Rect(1, 2, 3, 4) },
+			[]f32.Vec2{{1, 2}, {4, 2}, {4, 6}, {1, 6}}},
+		{"ellipse", func(b *ivg.Builder) { b.Ellipse(1, 2, 3, 4) },
+			[]f32.Vec2{{4, 2}, {1, 6}, {-2, 2}, {1, -2}, {4, 2}}},
+	}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.add(b)
+		g := b.Fill(lowlevel.PaletteIndexColor(0)).Graphic()
+		var got []f32.Vec2
+		for _, seg := range g.Shapes[0].Path {
+			switch seg := seg.(type) {
+			case ivg.MoveTo:
+				got = append(got, seg.To)
+			case ivg.LineTo:
+				got = append(got, seg.To)
+			case ivg.CubeTo:
+				got = append(got, seg.To)
+			}
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %d vertices %v, want %d %v", tc.desc, len(got), got, len(tc.want), tc.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(float64(got[i][0]-tc.want[i][0])) > 1e-4 || math.Abs(float64(got[i][1]-tc.want[i][1])) > 1e-4 {
+				t.Errorf("%s: vertex #%d: got %v, want %v", tc.desc, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
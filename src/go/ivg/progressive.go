@@ -0,0 +1,70 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// detailLevels groups shapes into n progressive detail levels, from coarse to
+// fine, for the Encoder's DetailLevels option. A Shape's level is 1 if its
+// bounding box spans at least half of the viewBox's width or height, 2 if at
+// least a quarter, and so on, with level n holding the rest.
+//
+// Reordering Shapes must not change how they render, so a Shape is promoted
+// to the level of any later Shape that it overlaps, keeping it beneath that
+// Shape. Within a level, Shapes keep their painter's order.
+func detailLevels(shapes []Shape, vb lowlevel.Rectangle, n int) [][]Shape {
+	dx, dy := vb.AspectRatio()
+	bs := make([]bounds, len(shapes))
+	level := make([]int, len(shapes))
+	for i := len(shapes) - 1; i >= 0; i-- {
+		bs[i] = pathBounds(shapes[i].Path)
+		level[i] = sizeLevel(bs[i], dx, dy, n)
+		for j := i + 1; j < len(shapes); j++ {
+			if (level[j] < level[i]) && !bs[i].disjoint(bs[j]) {
+				level[i] = level[j]
+			}
+		}
+	}
+
+	levels := make([][]Shape, n)
+	for i, s := range shapes {
+		levels[level[i]-1] = append(levels[level[i]-1], s)
+	}
+	return levels
+}
+
+// sizeLevel returns the detail level, from 1 to n, of a Shape with bounds b in
+// a viewBox whose width and height are dx and dy.
+func sizeLevel(b bounds, dx float32, dy float32, n int) int {
+	extent := math.Max(
+		float64((b.Max[0]-b.Min[0])/dx),
+		float64((b.Max[1]-b.Min[1])/dy),
+	)
+	// An ArcTo's NaN bounds, or an empty Path's, count as coarse.
+	if (extent != extent) || (b.Min[0] > b.Max[0]) || (extent >= 0.5) {
+		return 1
+	} else if extent <= 0 {
+		return n
+	}
+	level := int(math.Ceil(-math.Log2(extent)))
+	if level > n {
+		level = n
+	}
+	return level
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDetailLevels(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	blue := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})
+	square := func(b *ivg.Builder, x, y, size float32) *ivg.Builder {
+		return b.MoveTo(x, y).LineTo(x+size, y).LineTo(x+size, y+size).LineTo(x, y+size).ClosePath()
+	}
+	// The default viewBox is 64 units wide, so a 40 unit square is in level
+	// 1, a 16 unit square in level 2 and a 4 unit square in level 4 (or the
+	// last level, if there are fewer).
+	testCases := []struct {
+		desc   string
+		build  func(b *ivg.Builder)
+		levels int
+		// wantShapes are the number of Shapes decoded with a MaxLOD of 1, 2,
+		// etc.
+		wantShapes []int
+	}{{
+		desc: "coarse to fine",
+		build: func(b *ivg.Builder) {
+			square(b, 20, 20, 4).Fill(red)
+			square(b, -30, -30, 40).Fill(blue)
+			square(b, -30, 12, 16).Fill(red)
+		},
+		levels:     3,
+		wantShapes: []int{1, 2, 3},
+	}, {
+		desc: "small shape beneath a large one",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 4).Fill(red)
+			square(b, -30, -30, 40).Fill(blue)
+			square(b, -30, 12, 16).Fill(red)
+		},
+		levels:     3,
+		wantShapes: []int{2, 3, 3},
+	}, {
+		desc: "one level",
+		build: func(b *ivg.Builder) {
+			square(b, 20, 20, 4).Fill(red)
+			square(b, -30, -30, 40).Fill(blue)
+		},
+		levels:     1,
+		wantShapes: []int{2},
+	}, {
+		desc: "empty levels",
+		build: func(b *ivg.Builder) {
+			square(b, 20, 20, 4).Fill(red)
+		},
+		levels:     3,
+		wantShapes: []int{0, 0, 1},
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.build(b)
+		g := b.Graphic()
+		plain, err := (&ivg.Encoder{}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		progressive, err := (&ivg.Encoder{DetailLevels: tc.levels}).Encode(g)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		checkSameRendering(t, tc.desc, progressive, plain, 0)
+
+		m, err := lowlevel.DecodeMetadata(progressive)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := len(m.DetailLevels); got != tc.levels {
+			t.Errorf("%s: got %d detail levels, want %d", tc.desc, got, tc.levels)
+		}
+		for i, want := range tc.wantShapes {
+			h, err := ivg.Decode(progressive, &lowlevel.DecodeOptions{MaxLOD: i + 1})
+			if err != nil {
+				t.Errorf("%s: MaxLOD=%d: %v", tc.desc, i+1, err)
+			} else if got := len(h.Shapes); got != want {
+				t.Errorf("%s: MaxLOD=%d: got %d shapes, want %d", tc.desc, i+1, got, want)
+			}
+		}
+
+		// Without the DetailLevels option, the encoding is not progressive,
+		// even if the Graphic's Metadata says that it is.
+		h, err := ivg.Decode(progressive, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		reencoded, err := (&ivg.Encoder{}).Encode(h)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		} else if m, err := lowlevel.DecodeMetadata(reencoded); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		} else if len(m.DetailLevels) != 0 {
+			t.Errorf("%s: re-encoded: got %d detail levels, want 0", tc.desc, len(m.DetailLevels))
+		}
+	}
+}
+
+func TestDetailLevelsOptimize(t *testing.T) {
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := &ivg.Encoder{Optimize: true, DetailLevels: 4}
+		got, err := e.Reencode(src)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		checkSameRendering(t, filename, got, src, 0)
+		for maxLOD := 1; maxLOD <= 4; maxLOD++ {
+			if _, err := ivg.Decode(got, &lowlevel.DecodeOptions{MaxLOD: maxLOD}); err != nil {
+				t.Errorf("%s: MaxLOD=%d: %v", filename, maxLOD, err)
+			}
+		}
+	}
+}
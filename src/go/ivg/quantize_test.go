@@ -0,0 +1,116 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+// points returns a Segment's explicit points.
+func points(s ivg.Segment) []f32.Vec2 {
+	switch s := s.(type) {
+	case ivg.MoveTo:
+		return []f32.Vec2{s.To}
+	case ivg.LineTo:
+		return []f32.Vec2{s.To}
+	case ivg.QuadTo:
+		return []f32.Vec2{s.Ctrl, s.To}
+	case ivg.CubeTo:
+		return []f32.Vec2{s.Ctrl0, s.Ctrl1, s.To}
+	case ivg.ArcTo:
+		return []f32.Vec2{s.To}
+	}
+	return nil
+}
+
+func TestQuantizeCoordinates(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"lod-polygon.ivg",
+	}
+	maxErrors := []float32{0, 1.0 / 128, 0.5}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tc, err)
+		}
+		prevLen := -1
+		for _, maxError := range maxErrors {
+			e := &ivg.Encoder{}
+			e.QuantizeCoordinates(maxError)
+			enc, err := e.Encode(g)
+			if err != nil {
+				t.Errorf("%s, maxError %g: Encode: %v", tc, maxError, err)
+				continue
+			}
+			if (maxError > 0) && (e.QuantizationError() > maxError) {
+				t.Errorf("%s, maxError %g: QuantizationError: got %g, want <= %g",
+					tc, maxError, e.QuantizationError(), maxError)
+			}
+			if (prevLen >= 0) && (len(enc) > prevLen) {
+				t.Errorf("%s, maxError %g: length: got %d, want <= %d", tc, maxError, len(enc), prevLen)
+			}
+			prevLen = len(enc)
+
+			// Every decoded point must be within the bound of the original,
+			// plus the 4 byte encoding's own loss of precision.
+			g2, err := ivg.Decode(enc, nil)
+			if err != nil {
+				t.Errorf("%s, maxError %g: Decode: %v", tc, maxError, err)
+				continue
+			}
+			if len(g2.Shapes) != len(g.Shapes) {
+				t.Errorf("%s, maxError %g: shapes: got %d, want %d", tc, maxError, len(g2.Shapes), len(g.Shapes))
+				continue
+			}
+			bound := maxError + 1e-3
+			for i := range g.Shapes {
+				p0, p1 := g.Shapes[i].Path, g2.Shapes[i].Path
+				if len(p0) != len(p1) {
+					t.Errorf("%s, maxError %g: shape %d: segments: got %d, want %d",
+						tc, maxError, i, len(p1), len(p0))
+					continue
+				}
+				for j := range p0 {
+					q0, q1 := points(p0[j]), points(p1[j])
+					if len(q0) != len(q1) {
+						t.Errorf("%s, maxError %g: shape %d segment %d: got %T, want %T",
+							tc, maxError, i, j, p1[j], p0[j])
+						continue
+					}
+					for k := range q0 {
+						if dx, dy := q1[k][0]-q0[k][0], q1[k][1]-q0[k][1]; (dx < -bound) || (bound < dx) ||
+							(dy < -bound) || (bound < dy) {
+							t.Errorf("%s, maxError %g: shape %d segment %d: got %v, want %v",
+								tc, maxError, i, j, q1[k], q0[k])
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"errors"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f64"
+)
+
+var errInvalidRefit = errors.New("iconvg: invalid view box or padding for Refit")
+
+// Refit re-encodes the IconVG graphic src with a new viewBox, scaling and
+// translating every path coordinate, arc radius and gradient transformation so
+// that the drawing keeps its place relative to its old viewBox. The old
+// viewBox is scaled uniformly, preserving its aspect ratio, to fit within the
+// new viewBox inset by padding on each side, and is centered there.
+//
+// For example, an icon set drawn in a mix of 0..24 and -32..+32 boxes can be
+// normalized to the canonical -24..+24 box, with a 2 unit margin, by
+//
+//	dst, err := ivg.Refit(src, lowlevel.Rectangle{
+//		Min: f32.Vec2{-24, -24},
+//		Max: f32.Vec2{+24, +24},
+//	}, 2)
+//
+// Like Canonicalize, colors that refer to the custom palette stay palette
+// references. Level of detail bounds are in pixels, not in graphic
+// coordinates, so they are unchanged.
+func Refit(src []byte, viewBox lowlevel.Rectangle, padding float32) ([]byte, error) {
+	d := &decoder{keepPalette: true}
+	if err := lowlevel.Decode(d, src, nil); err != nil {
+		return nil, err
+	}
+	g := &d.g
+
+	oldDx, oldDy := g.Metadata.ViewBox.AspectRatio()
+	newDx, newDy := viewBox.AspectRatio()
+	newDx, newDy = newDx-2*padding, newDy-2*padding
+	if !(oldDx > 0) || !(oldDy > 0) || !(newDx > 0) || !(newDy > 0) {
+		return nil, errInvalidRefit
+	}
+	scale := float64(newDx) / float64(oldDx)
+	if sy := float64(newDy) / float64(oldDy); scale > sy {
+		scale = sy
+	}
+	offset := [2]float64{
+		float64(viewBox.Min[0]+padding) + (float64(newDx)-scale*float64(oldDx))/2 - scale*float64(g.Metadata.ViewBox.Min[0]),
+		float64(viewBox.Min[1]+padding) + (float64(newDy)-scale*float64(oldDy))/2 - scale*float64(g.Metadata.ViewBox.Min[1]),
+	}
+	if math.IsInf(scale, 0) || math.IsInf(offset[0], 0) || math.IsInf(offset[1], 0) {
+		return nil, errInvalidRefit
+	}
+
+	g.Metadata.ViewBox = viewBox
+	g.Transform(f64.Aff3{scale, 0, offset[0], 0, scale, offset[1]})
+	return Encode(g)
+}
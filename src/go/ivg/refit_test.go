@@ -0,0 +1,157 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+func TestRefitTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Doubling and shifting the viewBox keeps its aspect ratio, so the
+		// refit graphic renders just like the original.
+		vb := g.Metadata.ViewBox
+		for i := range vb.Min {
+			vb.Min[i] = 2*vb.Min[i] + 10
+			vb.Max[i] = 2*vb.Max[i] + 10
+		}
+		got, err := ivg.Refit(src, vb, 0)
+		if err != nil {
+			t.Errorf("%s: Refit: %v", tc, err)
+			continue
+		}
+		checkSameRendering(t, tc, got, src, 2)
+	}
+}
+
+func TestRefit(t *testing.T) {
+	square := lowlevel.Rectangle{Min: f32.Vec2{-24, -24}, Max: f32.Vec2{+24, +24}}
+	testCases := []struct {
+		desc    string
+		oldVB   string
+		viewBox lowlevel.Rectangle
+		padding float32
+		wantMin f32.Vec2
+		wantMax f32.Vec2
+	}{
+		{"same box", "-24 -24 24 24", square, 0, f32.Vec2{-24, -24}, f32.Vec2{+24, +24}},
+		{"translate", "0 0 48 48", square, 0, f32.Vec2{-24, -24}, f32.Vec2{+24, +24}},
+		{"scale up with padding", "0 0 24 24", square, 2, f32.Vec2{-22, -22}, f32.Vec2{+22, +22}},
+		{"scale down with padding", "-48 -48 48 48", square, 12, f32.Vec2{-12, -12}, f32.Vec2{+12, +12}},
+		{"wide centered", "0 0 48 24", square, 0, f32.Vec2{-24, -12}, f32.Vec2{+24, +12}},
+		{"tall centered", "0 0 12 24", square, 4, f32.Vec2{-10, -20}, f32.Vec2{+10, +20}},
+	}
+	for _, tc := range testCases {
+		// The path traces the old viewBox's bounds.
+		src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\nviewBox " + tc.oldVB + "\n" +
+			"path [csel] " + corners(tc.oldVB) + "\nz"))
+		if err != nil {
+			t.Fatalf("%s: Assemble: %v", tc.desc, err)
+		}
+		dst, err := ivg.Refit(src, tc.viewBox, tc.padding)
+		if err != nil {
+			t.Errorf("%s: Refit: %v", tc.desc, err)
+			continue
+		}
+		g, err := ivg.Decode(dst, nil)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc.desc, err)
+			continue
+		}
+		if got := g.Metadata.ViewBox; got != tc.viewBox {
+			t.Errorf("%s: ViewBox: got %v, want %v", tc.desc, got, tc.viewBox)
+		}
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: Shapes: got %d, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		gotMin, gotMax := f32.Vec2{+1e9, +1e9}, f32.Vec2{-1e9, -1e9}
+		for _, seg := range g.Shapes[0].Path {
+			for _, p := range points(seg) {
+				for i := range p {
+					if gotMin[i] > p[i] {
+						gotMin[i] = p[i]
+					}
+					if gotMax[i] < p[i] {
+						gotMax[i] = p[i]
+					}
+				}
+			}
+		}
+		if got := gotMin; got != tc.wantMin {
+			t.Errorf("%s: min: got %v, want %v", tc.desc, got, tc.wantMin)
+		}
+		if got := gotMax; got != tc.wantMax {
+			t.Errorf("%s: max: got %v, want %v", tc.desc, got, tc.wantMax)
+		}
+	}
+}
+
+func TestRefitErrors(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc    string
+		viewBox lowlevel.Rectangle
+		padding float32
+	}{
+		{"empty view box", lowlevel.Rectangle{}, 0},
+		{"inverted view box", lowlevel.Rectangle{Min: f32.Vec2{24, 24}, Max: f32.Vec2{-24, -24}}, 0},
+		{"padding fills the box", lowlevel.DefaultViewBox, 32},
+		{"padding overflows the box", lowlevel.DefaultViewBox, 40},
+	}
+	for _, tc := range testCases {
+		if _, err := ivg.Refit(src, tc.viewBox, tc.padding); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+	if _, err := ivg.Refit(nil, lowlevel.DefaultViewBox, 0); err == nil {
+		t.Errorf("invalid src: got nil error, want non-nil")
+	}
+}
+
+// corners returns the ivgasm coordinates of a view box's four corners,
+// clockwise from its top left.
+func corners(vb string) string {
+	f := strings.Fields(vb)
+	x0, y0, x1, y1 := f[0], f[1], f[2], f[3]
+	return x0 + " " + y0 + "\nL " + x1 + " " + y0 + " " + x1 + " " + y1 + " " + x0 + " " + y1
+}
@@ -0,0 +1,361 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// Simplify re-encodes the IconVG graphic src with its paths simplified, as
+// per Graphic.Simplify. Like Canonicalize, colors that refer to the custom
+// palette stay palette references.
+func Simplify(src []byte, tolerance float32) ([]byte, error) {
+	d := &decoder{keepPalette: true}
+	if err := lowlevel.Decode(d, src, nil); err != nil {
+		return nil, err
+	}
+	d.g.Simplify(tolerance)
+	return Encode(&d.g)
+}
+
+// Simplify replaces runs of path segments by fewer segments that are within
+// tolerance, in graphic coordinate space, of them. It suits the output of
+// image tracing tools, whose paths consist of many short segments:
+//
+//   - a run of LineTo segments is simplified with the Ramer–Douglas–Peucker
+//     algorithm, which keeps only the vertices that are further than
+//     tolerance from the straight line through their kept neighbors.
+//   - a run of QuadTo and CubeTo segments is refit as one CubeTo, keeping the
+//     run's end points and end tangents, if that CubeTo is within tolerance
+//     of points sampled along the run. If not, the run is split, at the
+//     segment boundary furthest from that CubeTo, and each half is refit.
+//
+// MoveTo, ArcTo and ClosePath segments are unchanged, as are Paints. A
+// negative tolerance is equivalent to zero, which only merges exactly
+// collinear LineTo segments.
+func (g *Graphic) Simplify(tolerance float32) {
+	tol := float64(tolerance)
+	if !(tol > 0) {
+		tol = 0
+	}
+	for i := range g.Shapes {
+		s := &g.Shapes[i]
+		s.Path = simplifyPath(s.Path, tol)
+	}
+}
+
+// simplifyPath returns p simplified, as per Graphic.Simplify. It does not
+// modify p.
+func simplifyPath(p Path, tol float64) Path {
+	q := make(Path, 0, len(p))
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for i := 0; i < len(p); {
+		j := i + 1
+		switch p[i].(type) {
+		case LineTo:
+			for ; j < len(p); j++ {
+				if _, ok := p[j].(LineTo); !ok {
+					break
+				}
+			}
+			q = simplifyLines(q, pen, p[i:j], tol)
+		case QuadTo, CubeTo:
+			for ; j < len(p); j++ {
+				if !isCurve(p[j]) {
+					break
+				}
+			}
+			q = simplifyCurves(q, pen, p[i:j], tol)
+		default:
+			q = append(q, p[i])
+		}
+		for _, seg := range p[i:j] {
+			pen = seg.EndPoint(pen, start)
+			if _, ok := seg.(MoveTo); ok {
+				start = pen
+			}
+		}
+		i = j
+	}
+	return q
+}
+
+func isCurve(s Segment) bool {
+	switch s.(type) {
+	case QuadTo, CubeTo:
+		return true
+	}
+	return false
+}
+
+// simplifyLines appends to dst the Ramer–Douglas–Peucker simplification of
+// the polyline that starts at pen and continues through the LineTo segments
+// in run.
+func simplifyLines(dst Path, pen f32.Vec2, run Path, tol float64) Path {
+	pts := make([]point, 1, len(run)+1)
+	pts[0] = pt(pen)
+	for _, seg := range run {
+		pts = append(pts, pt(seg.(LineTo).To))
+	}
+	keep := make([]bool, len(pts))
+	keep[len(pts)-1] = true
+	rdp(keep, pts, 0, len(pts)-1, tol)
+	for i, k := range keep {
+		if k && (i > 0) {
+			dst = append(dst, run[i-1])
+		}
+	}
+	return dst
+}
+
+// rdp marks, in keep, which of the points strictly between pts[i] and pts[j]
+// to keep.
+func rdp(keep []bool, pts []point, i, j int, tol float64) {
+	if j-i < 2 {
+		return
+	}
+	worst, worstDist := 0, -1.0
+	for k := i + 1; k < j; k++ {
+		if d := segmentDist(pts[k], pts[i], pts[j]); d > worstDist {
+			worst, worstDist = k, d
+		}
+	}
+	if worstDist <= tol {
+		return
+	}
+	keep[worst] = true
+	rdp(keep, pts, i, worst, tol)
+	rdp(keep, pts, worst, j, tol)
+}
+
+// segmentDist returns the distance from p to the line segment from a to b.
+func segmentDist(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	t := 0.0
+	if ll := dx*dx + dy*dy; ll > 0 {
+		t = math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/ll))
+	}
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+// cubic is a cubic Bézier curve's four points.
+type cubic [4]point
+
+func (c *cubic) at(t float64) point {
+	u := 1 - t
+	a, b, cc, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return point{
+		a*c[0].x + b*c[1].x + cc*c[2].x + d*c[3].x,
+		a*c[0].y + b*c[1].y + cc*c[2].y + d*c[3].y,
+	}
+}
+
+// derivs returns the first and second derivatives of c at t.
+func (c *cubic) derivs(t float64) (d1 point, d2 point) {
+	u := 1 - t
+	d1 = point{
+		3 * (u*u*(c[1].x-c[0].x) + 2*u*t*(c[2].x-c[1].x) + t*t*(c[3].x-c[2].x)),
+		3 * (u*u*(c[1].y-c[0].y) + 2*u*t*(c[2].y-c[1].y) + t*t*(c[3].y-c[2].y)),
+	}
+	d2 = point{
+		6 * (u*(c[2].x-2*c[1].x+c[0].x) + t*(c[3].x-2*c[2].x+c[1].x)),
+		6 * (u*(c[2].y-2*c[1].y+c[0].y) + t*(c[3].y-2*c[2].y+c[1].y)),
+	}
+	return d1, d2
+}
+
+// startTangent and endTangent return the unit tangent vectors at c's ends,
+// pointing into c, or false if c is a single point.
+func (c *cubic) startTangent() (point, bool) {
+	for i := 1; i < 4; i++ {
+		if c[i] != c[0] {
+			return unit(c[i], c[0]), true
+		}
+	}
+	return point{}, false
+}
+
+func (c *cubic) endTangent() (point, bool) {
+	for i := 2; i >= 0; i-- {
+		if c[i] != c[3] {
+			return unit(c[i], c[3]), true
+		}
+	}
+	return point{}, false
+}
+
+// simplifySamples is how many points, per segment, that a run of curves is
+// sampled at, to measure how far a refit curve is from it.
+const simplifySamples = 8
+
+// simplifyCurves appends to dst the run of QuadTo and CubeTo segments, which
+// starts at pen, refit as per Graphic.Simplify.
+func simplifyCurves(dst Path, pen f32.Vec2, run Path, tol float64) Path {
+	cs := make([]cubic, len(run))
+	p0 := pt(pen)
+	for i, seg := range run {
+		switch seg := seg.(type) {
+		case QuadTo:
+			c, p2 := pt(seg.Ctrl), pt(seg.To)
+			cs[i] = cubic{
+				p0,
+				point{p0.x + (c.x-p0.x)*2/3, p0.y + (c.y-p0.y)*2/3},
+				point{p2.x + (c.x-p2.x)*2/3, p2.y + (c.y-p2.y)*2/3},
+				p2,
+			}
+		case CubeTo:
+			cs[i] = cubic{p0, pt(seg.Ctrl0), pt(seg.Ctrl1), pt(seg.To)}
+		}
+		p0 = cs[i][3]
+	}
+	return refit(dst, run, cs, tol)
+}
+
+// refit appends to dst the curves cs, which are the segments run as cubics,
+// refit as per Graphic.Simplify.
+func refit(dst Path, run Path, cs []cubic, tol float64) Path {
+	if len(cs) == 1 {
+		return append(dst, run[0])
+	}
+	c, errs, ok := fitCubic(cs, tol)
+	if ok {
+		return append(dst, CubeTo{
+			Ctrl0: f32.Vec2{float32(c[1].x), float32(c[1].y)},
+			Ctrl1: f32.Vec2{float32(c[2].x), float32(c[2].y)},
+			// The run's last segment is a QuadTo or CubeTo, whose EndPoint
+			// does not depend on the pen or sub-path start.
+			To: run[len(run)-1].EndPoint(f32.Vec2{}, f32.Vec2{}),
+		})
+	}
+	k, worst := len(cs)/2, -1.0
+	for i := 1; i < len(cs); i++ {
+		if e := errs[i*simplifySamples]; e > worst {
+			k, worst = i, e
+		}
+	}
+	dst = refit(dst, run[:k], cs[:k], tol)
+	return refit(dst, run[k:], cs[k:], tol)
+}
+
+// fitCubic fits one cubic to the curves cs, whose end points and end tangents
+// it shares, using Schneider's least squares method ("An Algorithm for
+// Automatically Fitting Digitized Curves", Graphics Gems, 1990). It returns
+// the fit, its error at each sample point and whether every error is within
+// tol.
+func fitCubic(cs []cubic, tol float64) (fit cubic, errs []float64, ok bool) {
+	n := len(cs)
+	pts := make([]point, 0, n*simplifySamples+1)
+	for i := range cs {
+		for j := 0; j < simplifySamples; j++ {
+			pts = append(pts, cs[i].at(float64(j)/simplifySamples))
+		}
+	}
+	pts = append(pts, cs[n-1][3])
+	errs = make([]float64, len(pts))
+
+	t0, ok0 := cs[0].startTangent()
+	t1, ok1 := cs[n-1].endTangent()
+	if !ok0 || !ok1 {
+		for i := range errs {
+			errs[i] = math.Inf(+1)
+		}
+		return cubic{}, errs, false
+	}
+
+	// Parameterize the sample points by chord length.
+	us := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		us[i] = us[i-1] + math.Hypot(pts[i].x-pts[i-1].x, pts[i].y-pts[i-1].y)
+	}
+	if total := us[len(us)-1]; total > 0 {
+		for i := range us {
+			us[i] /= total
+		}
+	}
+
+	const iterations = 4
+	for iter := 0; ; iter++ {
+		fit = fitTangents(pts, us, t0, t1)
+		worst := 0.0
+		for i, p := range pts {
+			q := fit.at(us[i])
+			errs[i] = math.Hypot(q.x-p.x, q.y-p.y)
+			if !(errs[i] <= worst) {
+				worst = errs[i]
+			}
+		}
+		if worst <= tol {
+			return fit, errs, true
+		} else if iter == iterations {
+			return fit, errs, false
+		}
+
+		// Improve the parameterization with a Newton-Raphson step towards
+		// each sample point's nearest point on the fit.
+		for i, p := range pts {
+			q := fit.at(us[i])
+			d1, d2 := fit.derivs(us[i])
+			dx, dy := q.x-p.x, q.y-p.y
+			den := d1.x*d1.x + d1.y*d1.y + dx*d2.x + dy*d2.y
+			if den != 0 {
+				us[i] = math.Max(0, math.Min(1, us[i]-(dx*d1.x+dy*d1.y)/den))
+			}
+		}
+	}
+}
+
+// fitTangents returns the cubic, from pts[0] to pts[len(pts)-1], whose
+// control points are along the unit tangents t0 and t1, that best fits the
+// points pts at parameters us, in the least squares sense.
+func fitTangents(pts []point, us []float64, t0, t1 point) cubic {
+	p0, p3 := pts[0], pts[len(pts)-1]
+	var c00, c01, c11, x0, x1 float64
+	for i, p := range pts {
+		u := us[i]
+		v := 1 - u
+		b0, b1, b2, b3 := v*v*v, 3*v*v*u, 3*v*u*u, u*u*u
+		a0 := point{t0.x * b1, t0.y * b1}
+		a1 := point{t1.x * b2, t1.y * b2}
+		c00 += a0.x*a0.x + a0.y*a0.y
+		c01 += a0.x*a1.x + a0.y*a1.y
+		c11 += a1.x*a1.x + a1.y*a1.y
+		rx := p.x - (b0+b1)*p0.x - (b2+b3)*p3.x
+		ry := p.y - (b0+b1)*p0.y - (b2+b3)*p3.y
+		x0 += a0.x*rx + a0.y*ry
+		x1 += a1.x*rx + a1.y*ry
+	}
+
+	// Solve for the distances alpha0 and alpha1 of the control points from
+	// the end points. If that fails, or gives control points behind the end
+	// points, fall back to a third of the chord length.
+	chord := math.Hypot(p3.x-p0.x, p3.y-p0.y)
+	alpha0, alpha1 := 0.0, 0.0
+	if det := c00*c11 - c01*c01; det != 0 {
+		alpha0 = (x0*c11 - x1*c01) / det
+		alpha1 = (c00*x1 - c01*x0) / det
+	}
+	if eps := 1e-6 * chord; !(alpha0 >= eps) || !(alpha1 >= eps) {
+		alpha0, alpha1 = chord/3, chord/3
+	}
+	return cubic{
+		p0,
+		point{p0.x + alpha0*t0.x, p0.y + alpha0*t0.y},
+		point{p3.x + alpha1*t1.x, p3.y + alpha1*t1.y},
+		p3,
+	}
+}
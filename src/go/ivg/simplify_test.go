@@ -0,0 +1,202 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+func TestSimplify(t *testing.T) {
+	arc := ivg.ArcTo{Radii: f32.Vec2{1, 1}, To: f32.Vec2{4, 0}}
+	testCases := []struct {
+		desc      string
+		tolerance float32
+		path      ivg.Path
+		want      ivg.Path
+	}{{
+		desc:      "collinear",
+		tolerance: 0,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+			ivg.LineTo{To: f32.Vec2{3, 0}},
+			ivg.ClosePath{},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{3, 0}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc:      "near collinear, outside tolerance",
+		tolerance: 0.05,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0.1}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0.1}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+	}, {
+		desc:      "near collinear, within tolerance",
+		tolerance: 0.2,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0.1}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+	}, {
+		desc:      "negative tolerance",
+		tolerance: -1,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0.1}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0.1}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+		},
+	}, {
+		// An ArcTo separates two runs of LineTo segments.
+		desc:      "arc",
+		tolerance: 0,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{1, 0}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+			arc,
+			ivg.LineTo{To: f32.Vec2{5, 0}},
+			ivg.LineTo{To: f32.Vec2{6, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{2, 0}},
+			arc,
+			ivg.LineTo{To: f32.Vec2{6, 0}},
+		},
+	}, {
+		// A lone curve is never refit.
+		desc:      "one quad",
+		tolerance: 1,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{5, 10}, To: f32.Vec2{10, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{5, 10}, To: f32.Vec2{10, 0}},
+		},
+	}, {
+		// Two humps are not one cubic.
+		desc:      "two humps",
+		tolerance: 0.01,
+		path: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{2.5, 5}, To: f32.Vec2{5, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{7.5, 5}, To: f32.Vec2{10, 0}},
+		},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{2.5, 5}, To: f32.Vec2{5, 0}},
+			ivg.QuadTo{Ctrl: f32.Vec2{7.5, 5}, To: f32.Vec2{10, 0}},
+		},
+	}}
+	for _, tc := range testCases {
+		g := &ivg.Graphic{Shapes: []ivg.Shape{{Path: tc.path}}}
+		g.Simplify(tc.tolerance)
+		if got := g.Shapes[0].Path; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestSimplifyRefit(t *testing.T) {
+	// The two halves, split at t = 0.5, of the cubic from (0, 0) to (10, 0)
+	// with control points (0, 10) and (10, 10), refit as that cubic.
+	g := &ivg.Graphic{Shapes: []ivg.Shape{{Path: ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{0, 0}},
+		ivg.CubeTo{Ctrl0: f32.Vec2{0, 5}, Ctrl1: f32.Vec2{2.5, 7.5}, To: f32.Vec2{5, 7.5}},
+		ivg.CubeTo{Ctrl0: f32.Vec2{7.5, 7.5}, Ctrl1: f32.Vec2{10, 5}, To: f32.Vec2{10, 0}},
+	}}}}
+	g.Simplify(0.01)
+	got := g.Shapes[0].Path
+	if len(got) != 2 {
+		t.Fatalf("got %v, want 2 segments", got)
+	}
+	c, ok := got[1].(ivg.CubeTo)
+	if !ok {
+		t.Fatalf("got %v, want a CubeTo", got[1])
+	}
+	want := [3]f32.Vec2{{0, 10}, {10, 10}, {10, 0}}
+	for i, p := range [3]f32.Vec2{c.Ctrl0, c.Ctrl1, c.To} {
+		if math.Hypot(float64(p[0]-want[i][0]), float64(p[1]-want[i][1])) > 0.01 {
+			t.Errorf("point #%d: got %v, want %v", i, p, want[i])
+		}
+	}
+}
+
+func TestSimplifyTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"lod-polygon.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A tolerance of 0.1 graphic units is up to a seventh of a pixel at
+		// 64×64, for a 48 unit viewBox, or about 34 levels of anti-aliased
+		// coverage.
+		prevLen := -1
+		for _, tol := range []struct {
+			simplify float32
+			pixel    int
+		}{{0, 2}, {0.1, 40}} {
+			desc := fmt.Sprintf("%s, tolerance %g", tc, tol.simplify)
+			got, err := ivg.Simplify(src, tol.simplify)
+			if err != nil {
+				t.Errorf("%s: %v", desc, err)
+				continue
+			}
+			if (prevLen >= 0) && (len(got) > prevLen) {
+				t.Errorf("%s: length: got %d, want <= %d", desc, len(got), prevLen)
+			}
+			prevLen = len(got)
+			checkSameRendering(t, desc, got, src, tol.pixel)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// LineCap is the shape at the ends of a stroked open sub-path.
+type LineCap uint8
+
+const (
+	LineCapButt   LineCap = 0
+	LineCapRound  LineCap = 1
+	LineCapSquare LineCap = 2
+)
+
+// LineJoin is the shape where a stroked path's segments meet.
+type LineJoin uint8
+
+const (
+	LineJoinMiter LineJoin = 0
+	LineJoinRound LineJoin = 1
+	LineJoinBevel LineJoin = 2
+)
+
+// StrokeToFill returns a Path that fills the area that stroking p, with a pen
+// of the given width, would paint. IconVG only fills paths, so stroked source
+// art, such as SVG with a stroke property, is converted to fills.
+//
+// The caps, joins and miter limit are as for SVG's stroke-linecap,
+// stroke-linejoin and stroke-miterlimit properties. Sub-paths that end with a
+// ClosePath are joined where they start, and other sub-paths are capped. A
+// miter limit less than 1 is treated as 1.
+//
+// Like Union, the result consists of closed polygons, with curves, arcs,
+// round caps and round joins flattened to line segments that are within
+// 1/4096th of the stroke's larger dimension of the true curve. It is empty if
+// width is not positive.
+func StrokeToFill(p Path, width float32, cap LineCap, join LineJoin, miterLimit float32) Path {
+	return DashedStrokeToFill(p, width, cap, join, miterLimit, nil, 0)
+}
+
+// DashedStrokeToFill is like StrokeToFill, but the stroke is dashed, as for
+// SVG's stroke-dasharray and stroke-dashoffset properties. The dash array
+// alternates between the lengths of dashes and of gaps, and is repeated twice
+// if it has an odd number of elements. The dash offset is how far into the
+// pattern each sub-path starts.
+//
+// Each dash is capped like an open sub-path, including where a dash crosses
+// the start of a closed sub-path, and a zero length dash is capped like a
+// zero length sub-path. A closed sub-path that is entirely within one dash is
+// still joined where it starts. The stroke is solid if the dash array is
+// empty, sums to zero or has a negative or non-finite element.
+func DashedStrokeToFill(p Path, width float32, cap LineCap, join LineJoin, miterLimit float32, dashArray []float32, dashOffset float32) Path {
+	w := float64(width)
+	if !(w > 0) || math.IsInf(w, 0) {
+		return Path{}
+	}
+	scale := clipScale(p) + w
+	if !(scale > 0) || math.IsInf(scale, 0) {
+		return Path{}
+	}
+	s := &stroker{
+		r:          w / 2,
+		tol:        scale / 4096,
+		cap:        cap,
+		join:       join,
+		miterLimit: math.Max(1, float64(miterLimit)),
+	}
+	pls := flattenPolylines(p, s.tol)
+	if d := makeDashes(dashArray, dashOffset); d != nil {
+		pls = d.split(pls)
+	}
+	for _, pl := range pls {
+		s.stroke(pl)
+	}
+	return clip(s.edges, nil, scale, lowlevel.FillRuleNonZero, func(inA, inB bool) bool { return inA })
+}
+
+// stroker converts a stroke to outlines: for each sub-path, the offset lines
+// either side of it, connected by caps or, if it is closed, as two loops. The
+// outlines all wind the same way, so that, with the nonzero winding rule,
+// they fill the stroke, which clip then simplifies.
+//
+// On the outside of each turn, the offset lines are connected by the join.
+// On the inside, they are connected by pivoting through the vertex, which is
+// equivalent to overlapping each segment's rectangle with its neighbors'.
+type stroker struct {
+	r          float64
+	tol        float64
+	cap        LineCap
+	join       LineJoin
+	miterLimit float64
+
+	edges []edge
+}
+
+func (s *stroker) stroke(pl polyline) {
+	pts := pl.pts
+	n := len(pts)
+	if n == 1 {
+		// Like SVG, a zero length sub-path has caps but no direction.
+		switch s.cap {
+		case LineCapRound:
+			s.addPolygon(s.arc(pts[0], 0, 2*math.Pi))
+		case LineCapSquare:
+			c, r := pts[0], s.r
+			s.addPolygon([]point{{c.x - r, c.y - r}, {c.x + r, c.y - r}, {c.x + r, c.y + r}, {c.x - r, c.y + r}})
+		}
+		return
+	}
+
+	rev := make([]point, n)
+	for i, p := range pts {
+		rev[n-1-i] = p
+	}
+	if pl.closed {
+		s.addLoop(s.offset(pts, true, true))
+		s.addLoop(s.offset(rev, true, false))
+		return
+	}
+	loop := s.offset(pts, false, true)
+	loop = append(loop, s.capEnd(pts[n-1], unit(pts[n-1], pts[n-2]))...)
+	loop = append(loop, s.offset(rev, false, false)...)
+	loop = append(loop, s.capEnd(pts[0], unit(pts[0], pts[1]))...)
+	s.addLoop(loop)
+}
+
+// offset returns the line to the left of the polyline pts, at the pen's
+// radius, with joins at its interior vertices, or at all of its vertices if
+// it is closed. The left is the direction of the normals (-d.y, d.x).
+//
+// A 180 degree turn is on the outside of both sides of the polyline. Its join
+// is made on the side for which first is true.
+func (s *stroker) offset(pts []point, closed bool, first bool) []point {
+	n := len(pts)
+	m := n - 1
+	if closed {
+		m = n
+	}
+	dirs := make([]point, m)
+	lens := make([]float64, m)
+	for i := range dirs {
+		a, b := pts[i], pts[(i+1)%n]
+		dirs[i] = unit(b, a)
+		lens[i] = math.Hypot(b.x-a.x, b.y-a.y)
+	}
+
+	out := []point(nil)
+	if closed {
+		for i := 0; i < n; i++ {
+			j := (i + m - 1) % m
+			out = s.joint(out, pts[i], dirs[j], dirs[i], math.Min(lens[j], lens[i]), first)
+		}
+		return out
+	}
+	out = append(out, point{pts[0].x - dirs[0].y*s.r, pts[0].y + dirs[0].x*s.r})
+	for i := 1; i < n-1; i++ {
+		out = s.joint(out, pts[i], dirs[i-1], dirs[i], math.Min(lens[i-1], lens[i]), first)
+	}
+	d := dirs[m-1]
+	return append(out, point{pts[n-1].x - d.y*s.r, pts[n-1].y + d.x*s.r})
+}
+
+// joint appends, to the offset line out, the points where it turns at the
+// vertex v, from the direction d0 to d1. length is the shorter of the two
+// adjacent segments' lengths.
+func (s *stroker) joint(out []point, v, d0, d1 point, length float64, first bool) []point {
+	o0 := point{v.x - d0.y*s.r, v.y + d0.x*s.r}
+	o1 := point{v.x - d1.y*s.r, v.y + d1.x*s.r}
+	cross := d0.x*d1.y - d0.y*d1.x
+	dot := d0.x*d1.x + d0.y*d1.y
+	if (cross == 0) && (dot > 0) {
+		return append(out, o0)
+	}
+
+	// A positive cross product turns towards the normals, so this side of
+	// the turn is the inside.
+	if (cross > 0) || ((cross == 0) && !first) {
+		// Where both segments are long enough, the offset lines cross at a
+		// distance r·tan(θ/2) back from o0 and on from o1, and that crossing
+		// point can replace the pivot. Cutting the corner removes area that
+		// both segments' rectangles cover, as long as each rectangle also
+		// covers the other's corner, r·sin(θ) along it.
+		if k := 1 + dot; k > 0 {
+			t := s.r * cross / k
+			if math.Max(t, s.r*cross) <= length {
+				return append(out, point{o0.x - d0.x*t, o0.y - d0.y*t})
+			}
+		}
+		return append(out, o0, v, o1)
+	}
+
+	theta := math.Atan2(math.Abs(cross), dot)
+	switch s.join {
+	case LineJoinRound:
+		if s.r*(1-math.Cos(theta/2)) > s.tol {
+			// Turning by -theta turns the normal from o0 to o1, through the
+			// outside of the turn.
+			arc := s.arc(v, math.Atan2(o0.y-v.y, o0.x-v.x), -theta)
+			out = append(out, o0)
+			out = append(out, arc[1:len(arc)-1]...)
+			return append(out, o1)
+		}
+	case LineJoinMiter:
+		// The miter length, relative to the stroke width, is 1/sin(φ/2)
+		// where φ is the angle between the segments, which is π - theta.
+		if cos := math.Cos(theta / 2); (cos > 0) && (1/cos <= s.miterLimit) {
+			mx, my := (o0.x+o1.x)/2-v.x, (o0.y+o1.y)/2-v.y
+			k := s.r / cos / math.Hypot(mx, my)
+			return append(out, o0, point{v.x + mx*k, v.y + my*k}, o1)
+		}
+	}
+	return append(out, o0, o1)
+}
+
+// capEnd returns the points of the cap, if any, between the two offset lines
+// at the end point e of a sub-path, where d is the unit vector pointing away
+// from the sub-path.
+func (s *stroker) capEnd(e, d point) []point {
+	nx, ny := -d.y*s.r, d.x*s.r
+	switch s.cap {
+	case LineCapRound:
+		// The normal (nx, ny) is d rotated by +90 degrees, so the half disc
+		// sweeps from there by -180 degrees, through d.
+		arc := s.arc(e, math.Atan2(ny, nx), -math.Pi)
+		return arc[1 : len(arc)-1]
+	case LineCapSquare:
+		ex, ey := d.x*s.r, d.y*s.r
+		return []point{
+			{e.x + nx + ex, e.y + ny + ey},
+			{e.x - nx + ex, e.y - ny + ey},
+		}
+	}
+	return nil
+}
+
+// arc returns points, within tolerance, along the pen's circle centered on c,
+// from the angle a0 through to the angle (a0 + sweep).
+func (s *stroker) arc(c point, a0, sweep float64) []point {
+	step := math.Pi / 2
+	if s.tol < s.r {
+		step = math.Min(step, 2*math.Acos(1-s.tol/s.r))
+	}
+	n := int(math.Ceil(math.Abs(sweep) / step))
+	if n < 1 {
+		n = 1
+	} else if n > maxFlattenSegments {
+		n = maxFlattenSegments
+	}
+	pts := make([]point, n+1)
+	for i := range pts {
+		sin, cos := math.Sincos(a0 + sweep*float64(i)/float64(n))
+		pts[i] = point{c.x + s.r*cos, c.y + s.r*sin}
+	}
+	return pts
+}
+
+// addPolygon adds the closed polygon pts, reversed if necessary so that it
+// winds the same way as the outlines, whose signed area (as computed here) is
+// negative.
+func (s *stroker) addPolygon(pts []point) {
+	area := 0.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if area > 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	s.addLoop(pts)
+}
+
+// addLoop adds the closed polygon pts.
+func (s *stroker) addLoop(pts []point) {
+	for i, p := range pts {
+		if q := pts[(i+1)%len(pts)]; p != q {
+			s.edges = append(s.edges, edge{p, q})
+		}
+	}
+}
+
+// unit returns the unit vector in the direction from b to a.
+func unit(a, b point) point {
+	dx, dy := a.x-b.x, a.y-b.y
+	l := math.Hypot(dx, dy)
+	return point{dx / l, dy / l}
+}
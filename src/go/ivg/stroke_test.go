@@ -0,0 +1,149 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+func TestStrokeToFill(t *testing.T) {
+	line := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{0, 0}},
+		ivg.LineTo{To: f32.Vec2{10, 0}},
+	}
+	square := rect(0, 0, 10, 10)
+	testCases := []struct {
+		desc      string
+		p         ivg.Path
+		width     float32
+		cap       ivg.LineCap
+		join      ivg.LineJoin
+		wantArea  float64
+		wantLoops int
+	}{
+		{"butt cap", line, 2, ivg.LineCapButt, ivg.LineJoinMiter, 20, 1},
+		{"square cap", line, 2, ivg.LineCapSquare, ivg.LineJoinMiter, 24, 1},
+		{"round cap", line, 2, ivg.LineCapRound, ivg.LineJoinMiter, 20 + math.Pi, 1},
+		{"miter join", square, 2, ivg.LineCapButt, ivg.LineJoinMiter, 144 - 64, 2},
+		{"bevel join", square, 2, ivg.LineCapButt, ivg.LineJoinBevel, 144 - 64 - 4*0.5, 2},
+		{"round join", square, 2, ivg.LineCapButt, ivg.LineJoinRound, 144 - 64 - 4*(1-math.Pi/4), 2},
+		{"zero width", line, 0, ivg.LineCapButt, ivg.LineJoinMiter, 0, 0},
+		{"negative width", line, -1, ivg.LineCapButt, ivg.LineJoinMiter, 0, 0},
+	}
+	for _, tc := range testCases {
+		got := ivg.StrokeToFill(tc.p, tc.width, tc.cap, tc.join, 4)
+		gotArea, gotLoops := area(t, got)
+		if math.Abs(gotArea-tc.wantArea) > 0.05 {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, gotArea, tc.wantArea)
+		}
+		if gotLoops != tc.wantLoops {
+			t.Errorf("%s: loops: got %d, want %d", tc.desc, gotLoops, tc.wantLoops)
+		}
+	}
+}
+
+func TestDashedStrokeToFill(t *testing.T) {
+	line := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{0, 0}},
+		ivg.LineTo{To: f32.Vec2{10, 0}},
+	}
+	testCases := []struct {
+		desc       string
+		dashArray  []float32
+		dashOffset float32
+		wantArea   float64
+		wantLoops  int
+	}{
+		{"solid", nil, 0, 20, 1},
+		{"dashed", []float32{2, 2}, 0, 12, 3},
+		{"offset", []float32{2, 2}, 1, 10, 3},
+		{"odd length", []float32{2, 2, 2}, 0, 12, 3},
+		{"zero sum", []float32{0, 0}, 0, 20, 1},
+		{"negative", []float32{2, -2}, 0, 20, 1},
+		{"negative offset", []float32{2, 2}, -1, 10, 3},
+		{"offset of a whole pattern", []float32{2, 2}, 4, 12, 3},
+		{"NaN offset", []float32{2, 2}, float32(math.NaN()), 12, 3},
+		{"dash longer than the path", []float32{20, 1}, 0, 20, 1},
+		{"infinite", []float32{2, float32(math.Inf(+1))}, 0, 20, 1},
+	}
+	for _, tc := range testCases {
+		got := ivg.DashedStrokeToFill(line, 2, ivg.LineCapButt, ivg.LineJoinMiter, 4, tc.dashArray, tc.dashOffset)
+		gotArea, gotLoops := area(t, got)
+		if math.Abs(gotArea-tc.wantArea) > 0.05 {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, gotArea, tc.wantArea)
+		}
+		if gotLoops != tc.wantLoops {
+			t.Errorf("%s: loops: got %d, want %d", tc.desc, gotLoops, tc.wantLoops)
+		}
+	}
+}
+
+func TestDashedStrokeToFillShapes(t *testing.T) {
+	line := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{0, 0}},
+		ivg.LineTo{To: f32.Vec2{10, 0}},
+	}
+	testCases := []struct {
+		desc      string
+		p         ivg.Path
+		cap       ivg.LineCap
+		dashArray []float32
+		wantArea  float64
+		wantLoops int
+	}{
+		// Each dash, including one along each side of the closed square, is
+		// capped like an open sub-path.
+		{"square", rect(0, 0, 10, 10), ivg.LineCapButt, []float32{5, 5}, 40, 4},
+		{"square caps", rect(0, 0, 10, 10), ivg.LineCapSquare, []float32{4, 6}, 4 * (4 + 2) * 2, 4},
+		// A closed sub-path within one dash is joined, not capped.
+		{"square within one dash", rect(0, 0, 10, 10), ivg.LineCapSquare, []float32{50, 1}, 144 - 64, 2},
+		// Zero length dashes, with round caps, are dots at 0, 4 and 8.
+		{"dots", line, ivg.LineCapRound, []float32{0, 4}, 3 * math.Pi, 3},
+		{"butt dots", line, ivg.LineCapButt, []float32{0, 4}, 0, 0},
+	}
+	for _, tc := range testCases {
+		got := ivg.DashedStrokeToFill(tc.p, 2, tc.cap, ivg.LineJoinMiter, 4, tc.dashArray, 0)
+		gotArea, gotLoops := area(t, got)
+		if math.Abs(gotArea-tc.wantArea) > 0.05 {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, gotArea, tc.wantArea)
+		}
+		if gotLoops != tc.wantLoops {
+			t.Errorf("%s: loops: got %d, want %d", tc.desc, gotLoops, tc.wantLoops)
+		}
+	}
+}
+
+func TestStrokeToFillMiterLimit(t *testing.T) {
+	// The sharp corner's miter length, relative to the stroke width, is
+	// about 10, so a miter limit of 4 bevels it and 20 does not.
+	sharp := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{0, 0}},
+		ivg.LineTo{To: f32.Vec2{10, 1}},
+		ivg.LineTo{To: f32.Vec2{0, 2}},
+	}
+	mitered, _ := area(t, ivg.StrokeToFill(sharp, 1, ivg.LineCapButt, ivg.LineJoinMiter, 20))
+	limited, _ := area(t, ivg.StrokeToFill(sharp, 1, ivg.LineCapButt, ivg.LineJoinMiter, 4))
+	beveled, _ := area(t, ivg.StrokeToFill(sharp, 1, ivg.LineCapButt, ivg.LineJoinBevel, 20))
+	if math.Abs(limited-beveled) > 0.01 {
+		t.Errorf("limited: got area %v, want the beveled area %v", limited, beveled)
+	}
+	if !(mitered > beveled+1) {
+		t.Errorf("mitered: got area %v, want more than the beveled area %v", mitered, beveled)
+	}
+}
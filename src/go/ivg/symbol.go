@@ -0,0 +1,102 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"errors"
+
+	"golang.org/x/image/math/f64"
+)
+
+var (
+	errRecursiveSymbol = errors.New("iconvg: recursive symbol")
+	errUnknownSymbol   = errors.New("iconvg: unknown symbol")
+)
+
+// Expand replaces every Shape that has a Use by copies of its Symbol's Shapes,
+// transformed by the Use's Transform, as per Graphic.Transform, and with their
+// level of detail bounds narrowed to the Use's. Uses within Symbols are
+// expanded too. The Symbols themselves are unchanged.
+//
+// It returns an error, leaving g unchanged, if a Use refers to a Symbol that
+// does not exist or, directly or indirectly, to a Symbol that contains it.
+func (g *Graphic) Expand() error {
+	shapes, err := expandShapes(g.Shapes, g.Symbols, nil)
+	if err != nil {
+		return err
+	}
+	g.Shapes = shapes
+	return nil
+}
+
+// expandShapes returns shapes, with every Use expanded. active holds the
+// names of the Symbols being expanded, to detect recursion. If shapes has no
+// Uses, it is returned as is.
+func expandShapes(shapes []Shape, symbols map[string][]Shape, active []string) ([]Shape, error) {
+	n := 0
+	for ; (n < len(shapes)) && (shapes[n].Use == nil); n++ {
+	}
+	if n == len(shapes) {
+		return shapes, nil
+	}
+
+	out := append([]Shape(nil), shapes[:n]...)
+	for _, s := range shapes[n:] {
+		u := s.Use
+		if u == nil {
+			out = append(out, s)
+			continue
+		}
+		for _, name := range active {
+			if name == u.Symbol {
+				return nil, errRecursiveSymbol
+			}
+		}
+		sym, ok := symbols[u.Symbol]
+		if !ok {
+			return nil, errUnknownSymbol
+		}
+		sym, err := expandShapes(sym, symbols, append(active, u.Symbol))
+		if err != nil {
+			return nil, err
+		}
+		instance := Graphic{Shapes: append([]Shape(nil), sym...)}
+		instance.Transform(u.Transform)
+		for _, t := range instance.Shapes {
+			if t.LOD0 < s.LOD0 {
+				t.LOD0 = s.LOD0
+			}
+			if t.LOD1 > s.LOD1 {
+				t.LOD1 = s.LOD1
+			}
+			if t.LOD0 < t.LOD1 {
+				out = append(out, t)
+			}
+		}
+	}
+	return out, nil
+}
+
+// composeAff3 returns the affine transformation that applies b then a.
+func composeAff3(a, b f64.Aff3) f64.Aff3 {
+	return f64.Aff3{
+		a[0]*b[0] + a[1]*b[3],
+		a[0]*b[1] + a[1]*b[4],
+		a[0]*b[2] + a[1]*b[5] + a[2],
+		a[3]*b[0] + a[4]*b[3],
+		a[3]*b[1] + a[4]*b[4],
+		a[3]*b[2] + a[4]*b[5] + a[5],
+	}
+}
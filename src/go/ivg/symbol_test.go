@@ -0,0 +1,317 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+var identity = f64.Aff3{1, 0, 0, 0, 1, 0}
+
+// square returns a Symbol's Shapes: a unit square, centered on the origin,
+// filled with the given palette color and drawn at the given level of
+// detail.
+func square(palette uint8, lod0, lod1 float32) []ivg.Shape {
+	return ivg.NewBuilder().
+		SetLOD(lod0, lod1).
+		MoveTo(-1, -1).LineTo(+1, -1).LineTo(+1, +1).LineTo(-1, +1).ClosePath().
+		Fill(lowlevel.PaletteIndexColor(palette)).
+		Graphic().Shapes
+}
+
+func squarePath(minX, minY, maxX, maxY float32) ivg.Path {
+	return ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{minX, minY}},
+		ivg.LineTo{To: f32.Vec2{maxX, minY}},
+		ivg.LineTo{To: f32.Vec2{maxX, maxY}},
+		ivg.LineTo{To: f32.Vec2{minX, maxY}},
+		ivg.ClosePath{},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	inf := float32(math.Inf(+1))
+	type want struct {
+		path       ivg.Path
+		lod0, lod1 float32
+	}
+	testCases := []struct {
+		desc string
+		b    *ivg.Builder
+		want []want
+	}{{
+		desc: "identity",
+		b:    ivg.NewBuilder().DefineSymbol("sq", square(0, 0, inf)).Use("sq", identity),
+		want: []want{{squarePath(-1, -1, +1, +1), 0, inf}},
+	}, {
+		desc: "translate and scale",
+		b: ivg.NewBuilder().DefineSymbol("sq", square(0, 0, inf)).
+			Use("sq", f64.Aff3{1, 0, 10, 0, 1, 20}).
+			Use("sq", f64.Aff3{2, 0, 0, 0, 3, 0}),
+		want: []want{
+			{squarePath(9, 19, 11, 21), 0, inf},
+			{squarePath(-2, -3, +2, +3), 0, inf},
+		},
+	}, {
+		desc: "interleaved with paths",
+		b: ivg.NewBuilder().DefineSymbol("sq", square(0, 0, inf)).
+			MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().Fill(lowlevel.PaletteIndexColor(1)).
+			Use("sq", f64.Aff3{1, 0, 5, 0, 1, 5}),
+		want: []want{
+			{ivg.Path{
+				ivg.MoveTo{To: f32.Vec2{0, 0}},
+				ivg.LineTo{To: f32.Vec2{1, 0}},
+				ivg.LineTo{To: f32.Vec2{1, 1}},
+				ivg.ClosePath{},
+			}, 0, inf},
+			{squarePath(4, 4, 6, 6), 0, inf},
+		},
+	}, {
+		desc: "level of detail is narrowed",
+		b: ivg.NewBuilder().DefineSymbol("sq", square(0, 16, inf)).
+			SetLOD(0, 64).Use("sq", identity).
+			SetLOD(0, 8).Use("sq", identity),
+		want: []want{{squarePath(-1, -1, +1, +1), 16, 64}},
+	}, {
+		desc: "nested",
+		b: ivg.NewBuilder().
+			DefineSymbol("sq", square(0, 0, inf)).
+			DefineSymbol("pair", ivg.NewBuilder().
+				Use("sq", f64.Aff3{1, 0, -2, 0, 1, 0}).
+				Use("sq", f64.Aff3{1, 0, +2, 0, 1, 0}).
+				Graphic().Shapes).
+			Use("pair", f64.Aff3{2, 0, 0, 0, 2, 0}),
+		want: []want{
+			{squarePath(-6, -2, -2, +2), 0, inf},
+			{squarePath(+2, -2, +6, +2), 0, inf},
+		},
+	}}
+	for _, tc := range testCases {
+		g := tc.b.Graphic()
+		nSymbols := len(g.Symbols)
+		if err := g.Expand(); err != nil {
+			t.Errorf("%s: Expand: %v", tc.desc, err)
+			continue
+		}
+		if len(g.Symbols) != nSymbols {
+			t.Errorf("%s: Symbols: got %d, want %d", tc.desc, len(g.Symbols), nSymbols)
+		}
+		if len(g.Shapes) != len(tc.want) {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, len(g.Shapes), len(tc.want))
+			continue
+		}
+		for i, s := range g.Shapes {
+			w := tc.want[i]
+			if s.Use != nil {
+				t.Errorf("%s: shape #%d: got Use %v, want nil", tc.desc, i, s.Use)
+			}
+			if (s.LOD0 != w.lod0) || (s.LOD1 != w.lod1) {
+				t.Errorf("%s: shape #%d: LOD: got %v, %v, want %v, %v", tc.desc, i, s.LOD0, s.LOD1, w.lod0, w.lod1)
+			}
+			if len(s.Path) != len(w.path) {
+				t.Errorf("%s: shape #%d: got %d segments, want %d", tc.desc, i, len(s.Path), len(w.path))
+				continue
+			}
+			for j := range s.Path {
+				if s.Path[j] != w.path[j] {
+					t.Errorf("%s: shape #%d: segment #%d: got %v, want %v", tc.desc, i, j, s.Path[j], w.path[j])
+				}
+			}
+		}
+	}
+}
+
+func TestExpandDoesNotModifySymbols(t *testing.T) {
+	g := ivg.NewBuilder().
+		DefineSymbol("sq", square(0, 0, float32(math.Inf(+1)))).
+		Use("sq", f64.Aff3{3, 0, 7, 0, 3, 7}).
+		Graphic()
+	if err := g.Expand(); err != nil {
+		t.Fatal(err)
+	}
+	want := squarePath(-1, -1, +1, +1)
+	got := g.Symbols["sq"][0].Path
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("segment #%d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	inf := float32(math.Inf(+1))
+	testCases := []struct {
+		desc string
+		b    *ivg.Builder
+	}{{
+		desc: "unknown",
+		b:    ivg.NewBuilder().Use("sq", identity),
+	}, {
+		desc: "unknown within a symbol",
+		b: ivg.NewBuilder().
+			DefineSymbol("a", ivg.NewBuilder().Use("b", identity).Graphic().Shapes).
+			Use("a", identity),
+	}, {
+		desc: "directly recursive",
+		b: ivg.NewBuilder().
+			DefineSymbol("a", ivg.NewBuilder().Use("a", identity).Graphic().Shapes).
+			Use("a", identity),
+	}, {
+		desc: "indirectly recursive",
+		b: ivg.NewBuilder().
+			DefineSymbol("sq", square(0, 0, inf)).
+			DefineSymbol("a", ivg.NewBuilder().Use("sq", identity).Use("b", identity).Graphic().Shapes).
+			DefineSymbol("b", ivg.NewBuilder().Use("a", identity).Graphic().Shapes).
+			Use("a", identity),
+	}}
+	for _, tc := range testCases {
+		g := tc.b.Graphic()
+		n := len(g.Shapes)
+		if err := g.Expand(); err == nil {
+			t.Errorf("%s: Expand: got nil error, want non-nil", tc.desc)
+		}
+		if len(g.Shapes) != n {
+			t.Errorf("%s: Expand modified the Graphic", tc.desc)
+		}
+		if _, err := ivg.Encode(g); err == nil {
+			t.Errorf("%s: Encode: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestEncodeSymbols(t *testing.T) {
+	g := ivg.NewBuilder().
+		DefineSymbol("sq", square(2, 0, float32(math.Inf(+1)))).
+		Use("sq", f64.Aff3{8, 0, -16, 0, 8, -16}).
+		MoveTo(0, 0).LineTo(20, 0).LineTo(20, 20).ClosePath().Fill(lowlevel.PaletteIndexColor(1)).
+		Use("sq", f64.Aff3{0, -4, 16, 4, 0, 16}).
+		Graphic()
+	got, err := ivg.Encode(g)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if err := g.Expand(); err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	want, err := ivg.Encode(g)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encoding with Uses differs from encoding the expanded Graphic")
+	}
+
+	h, err := ivg.Decode(got, nil)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(h.Symbols) != 0 {
+		t.Errorf("Decode: got %d symbols, want 0", len(h.Symbols))
+	}
+	for i, s := range h.Shapes {
+		if s.Use != nil {
+			t.Errorf("Decode: shape #%d: got Use %v, want nil", i, s.Use)
+		}
+	}
+}
+
+func TestTransformUse(t *testing.T) {
+	testCases := []struct {
+		m, u, want f64.Aff3
+	}{
+		{identity, f64.Aff3{2, 0, 3, 0, 2, 4}, f64.Aff3{2, 0, 3, 0, 2, 4}},
+		{f64.Aff3{1, 0, 10, 0, 1, 20}, f64.Aff3{2, 0, 3, 0, 2, 4}, f64.Aff3{2, 0, 13, 0, 2, 24}},
+		{f64.Aff3{2, 0, 0, 0, 2, 0}, f64.Aff3{1, 0, 3, 0, 1, 4}, f64.Aff3{2, 0, 6, 0, 2, 8}},
+		{f64.Aff3{0, -1, 0, 1, 0, 0}, f64.Aff3{1, 0, 3, 0, 1, 4}, f64.Aff3{0, -1, -4, 1, 0, 3}},
+	}
+	for _, tc := range testCases {
+		g := ivg.NewBuilder().DefineSymbol("sq", square(0, 0, float32(math.Inf(+1)))).Use("sq", tc.u).Graphic()
+		before := g.Shapes[0].Use
+		g.Transform(tc.m)
+		if got := g.Shapes[0].Use.Transform; got != tc.want {
+			t.Errorf("m=%v, u=%v: got %v, want %v", tc.m, tc.u, got, tc.want)
+		}
+		if before.Transform != tc.u {
+			t.Errorf("m=%v, u=%v: Transform modified the original Use", tc.m, tc.u)
+		}
+		if got := g.Symbols["sq"][0].Path[0]; got != (ivg.MoveTo{To: f32.Vec2{-1, -1}}) {
+			t.Errorf("m=%v, u=%v: Transform modified the Symbol: got %v", tc.m, tc.u, got)
+		}
+	}
+}
+
+func TestGraphicJSONSymbols(t *testing.T) {
+	g := ivg.NewBuilder().
+		DefineSymbol("sq", square(0, 4, 32)).
+		SetLOD(2, 16).
+		Use("sq", f64.Aff3{0.5, 0, 1, 0, 0.5, 2}).
+		Graphic()
+	b, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{`"symbols":{"sq":[`, `"use":{"symbol":"sq","transform":[0.5,0,1,0,0.5,2]}`} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("Marshal: got %s, want it to contain %s", b, want)
+		}
+	}
+
+	h := &ivg.Graphic{}
+	if err := json.Unmarshal(b, h); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(h.Shapes) != 1 {
+		t.Fatalf("Shapes: got %d, want 1", len(h.Shapes))
+	}
+	s := h.Shapes[0]
+	if (s.Use == nil) || (*s.Use != *g.Shapes[0].Use) {
+		t.Errorf("Use: got %v, want %v", s.Use, g.Shapes[0].Use)
+	}
+	if (s.LOD0 != 2) || (s.LOD1 != 16) {
+		t.Errorf("LOD: got %v, %v, want 2, 16", s.LOD0, s.LOD1)
+	}
+	sym := h.Symbols["sq"]
+	if len(sym) != 1 {
+		t.Fatalf("Symbols: got %d shapes, want 1", len(sym))
+	}
+	if (sym[0].LOD0 != 4) || (sym[0].LOD1 != 32) {
+		t.Errorf("Symbol LOD: got %v, %v, want 4, 32", sym[0].LOD0, sym[0].LOD1)
+	}
+	got, err := ivg.Encode(h)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	want, err := ivg.Encode(g)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON round trip changed the encoding")
+	}
+
+	// A Shape cannot have both a paint and a use.
+	bad := `{"shapes":[{"paint":{"color":{"palette":0}},"use":{"symbol":"sq","transform":[1,0,0,0,1,0]},"path":[]}]}`
+	if err := json.Unmarshal([]byte(bad), &ivg.Graphic{}); err == nil {
+		t.Errorf("Unmarshal paint and use: got nil error, want non-nil")
+	}
+}
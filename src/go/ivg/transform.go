@@ -0,0 +1,176 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Transform re-encodes the IconVG graphic src with the affine transformation m
+// applied to its drawing, as per Graphic.Transform. Like Canonicalize, colors
+// that refer to the custom palette stay palette references.
+func Transform(src []byte, m f64.Aff3) ([]byte, error) {
+	d := &decoder{keepPalette: true}
+	if err := lowlevel.Decode(d, src, nil); err != nil {
+		return nil, err
+	}
+	d.g.Transform(m)
+	return Encode(&d.g)
+}
+
+// Transform applies the affine transformation m, which maps from the old to
+// the new graphic coordinate space, to every path coordinate and gradient. A
+// point (x, y) maps to (m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]).
+//
+// The ViewBox is unchanged, so scaling, rotating or mirroring an icon in place
+// needs m to fix the ViewBox's center. For example, mirroring an icon for
+// right-to-left locales maps x to (minX + maxX - x):
+//
+//	vb := &g.Metadata.ViewBox
+//	g.Transform(f64.Aff3{-1, 0, float64(vb.Min[0] + vb.Max[0]), 0, 1, 0})
+//
+// Arcs remain arcs, as an affine transformation maps an ellipse to an
+// ellipse, unless m is singular, in which case they are lowered to CubeTo
+// segments. Level of detail bounds are in pixels, not in graphic coordinates,
+// so they are unchanged. A Use's Transform is composed with m, and the
+// Symbols, which have their own coordinate spaces, are unchanged.
+func (g *Graphic) Transform(m f64.Aff3) {
+	for i := range g.Shapes {
+		s := &g.Shapes[i]
+		if s.Use != nil {
+			u := *s.Use
+			u.Transform = composeAff3(m, u.Transform)
+			s.Use = &u
+		}
+		s.Path = transformPath(s.Path, m)
+		if grad := s.Paint.Gradient; grad != nil {
+			gg := *grad
+			gg.Transform = transformGradient(gg.Transform, m)
+			s.Paint.Gradient = &gg
+		}
+	}
+}
+
+// transformPath returns p with the affine transformation m applied.
+func transformPath(p Path, m f64.Aff3) Path {
+	det := m[0]*m[4] - m[1]*m[3]
+	singular := (det == 0) || math.IsNaN(det) || math.IsInf(det, 0)
+	tx := func(v f32.Vec2) f32.Vec2 {
+		x, y := float64(v[0]), float64(v[1])
+		return f32.Vec2{
+			float32(m[0]*x + m[1]*y + m[2]),
+			float32(m[3]*x + m[4]*y + m[5]),
+		}
+	}
+
+	q := make(Path, 0, len(p))
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case MoveTo:
+			q = append(q, MoveTo{To: tx(seg.To)})
+		case LineTo:
+			q = append(q, LineTo{To: tx(seg.To)})
+		case QuadTo:
+			q = append(q, QuadTo{Ctrl: tx(seg.Ctrl), To: tx(seg.To)})
+		case CubeTo:
+			q = append(q, CubeTo{Ctrl0: tx(seg.Ctrl0), Ctrl1: tx(seg.Ctrl1), To: tx(seg.To)})
+		case ArcTo:
+			if singular {
+				for _, c := range seg.Cubics(pen, 0) {
+					switch c := c.(type) {
+					case LineTo:
+						q = append(q, LineTo{To: tx(c.To)})
+					case CubeTo:
+						q = append(q, CubeTo{Ctrl0: tx(c.Ctrl0), Ctrl1: tx(c.Ctrl1), To: tx(c.To)})
+					}
+				}
+			} else {
+				q = append(q, transformArc(seg, m, det, tx(seg.To)))
+			}
+		default:
+			q = append(q, seg)
+		}
+		pen = seg.EndPoint(pen, start)
+		if _, ok := seg.(MoveTo); ok {
+			start = pen
+		}
+	}
+	return q
+}
+
+// transformArc returns the arc s with the non-singular affine transformation
+// m, whose determinant is det, applied. to is the transformed end point.
+func transformArc(s ArcTo, m f64.Aff3, det float64, to f32.Vec2) ArcTo {
+	// u and v are the transformed ellipse's conjugate semi-diameters: the
+	// images of its radii along and across its x axis. An ellipse is the
+	// set of points (u cos t + v sin t), relative to its center.
+	sin, cos := math.Sincos(2 * math.Pi * float64(s.XAxisRotation))
+	rx, ry := float64(s.Radii[0]), float64(s.Radii[1])
+	ux, uy := rx*(m[0]*cos+m[1]*sin), rx*(m[3]*cos+m[4]*sin)
+	vx, vy := ry*(m[1]*cos-m[0]*sin), ry*(m[4]*cos-m[3]*sin)
+
+	// If u and v are not perpendicular, which happens for skews and
+	// non-uniform scales of rotated ellipses, rotate them (by varying t) to
+	// the ellipse's principal axes.
+	if dot := ux*vx + uy*vy; dot != 0 {
+		t := 0.5 * math.Atan2(2*dot, (ux*ux+uy*uy)-(vx*vx+vy*vy))
+		sinT, cosT := math.Sincos(t)
+		ux, uy, vx, vy = ux*cosT+vx*sinT, uy*cosT+vy*sinT, vx*cosT-ux*sinT, vy*cosT-uy*sinT
+	}
+
+	rot := math.Atan2(uy, ux) / (2 * math.Pi)
+	if rot < 0 {
+		rot++
+	}
+	return ArcTo{
+		Radii:         f32.Vec2{float32(math.Hypot(ux, uy)), float32(math.Hypot(vx, vy))},
+		XAxisRotation: float32(rot),
+		LargeArc:      s.LargeArc,
+		// A reflection reverses the direction of travel around the ellipse.
+		Sweep: s.Sweep != (det < 0),
+		To:    to,
+	}
+}
+
+// transformGradient returns the gradient transformation g, which maps from
+// the old graphic coordinate space, composed with the inverse of m, so that
+// it maps from the new graphic coordinate space.
+func transformGradient(g f32.Aff3, m f64.Aff3) f32.Aff3 {
+	det := m[0]*m[4] - m[1]*m[3]
+	if (det == 0) || math.IsNaN(det) || math.IsInf(det, 0) {
+		return degenerateGradientTransform
+	}
+	inv := [6]float64{
+		+m[4] / det,
+		-m[1] / det,
+		(m[1]*m[5] - m[2]*m[4]) / det,
+		-m[3] / det,
+		+m[0] / det,
+		(m[2]*m[3] - m[0]*m[5]) / det,
+	}
+	ret := f32.Aff3{}
+	for row := 0; row < 6; row += 3 {
+		a, b, c := float64(g[row+0]), float64(g[row+1]), float64(g[row+2])
+		ret[row+0] = float32(a*inv[0] + b*inv[3])
+		ret[row+1] = float32(a*inv[1] + b*inv[4])
+		ret[row+2] = float32(a*inv[2] + b*inv[5] + c)
+	}
+	return ret
+}
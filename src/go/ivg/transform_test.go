@@ -0,0 +1,177 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+func TestTransformTestData(t *testing.T) {
+	const size = 64
+	testCases := []struct {
+		desc string
+		// m is about the center of the default view box, -32..+32.
+		m f64.Aff3
+		// mapXY maps a pixel of the transformed graphic's rendering to the
+		// same pixel of the original's.
+		mapXY func(x, y int) (int, int)
+	}{
+		{"identity", f64.Aff3{1, 0, 0, 0, 1, 0}, func(x, y int) (int, int) { return x, y }},
+		{"mirror x", f64.Aff3{-1, 0, 0, 0, 1, 0}, func(x, y int) (int, int) { return size - 1 - x, y }},
+		{"mirror y", f64.Aff3{1, 0, 0, 0, -1, 0}, func(x, y int) (int, int) { return x, size - 1 - y }},
+		{"rotate 90", f64.Aff3{0, -1, 0, 1, 0, 0}, func(x, y int) (int, int) { return y, size - 1 - x }},
+		{"rotate 180", f64.Aff3{-1, 0, 0, 0, -1, 0}, func(x, y int) (int, int) { return size - 1 - x, size - 1 - y }},
+	}
+	filenames := []string{
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"elliptical.ivg",
+		"gradient.ivg",
+	}
+	for _, filename := range filenames {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := render.Image(src, size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range testCases {
+			dst, err := ivg.Transform(src, tc.m)
+			if err != nil {
+				t.Errorf("%s, %s: Transform: %v", filename, tc.desc, err)
+				continue
+			}
+			got, err := render.Image(dst, size, nil)
+			if err != nil {
+				t.Errorf("%s, %s: render: %v", filename, tc.desc, err)
+				continue
+			}
+			// Anti-aliasing is not exactly symmetric, so allow a small
+			// tolerance.
+			const tolerance = 4
+		loop:
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					g := got.RGBAAt(x, y)
+					w := want.RGBAAt(tc.mapXY(x, y))
+					for _, d := range [4]int{
+						int(g.R) - int(w.R), int(g.G) - int(w.G),
+						int(g.B) - int(w.B), int(g.A) - int(w.A),
+					} {
+						if (d < -tolerance) || (tolerance < d) {
+							t.Errorf("%s, %s: pixel (%d, %d): got %v, want %v", filename, tc.desc, x, y, g, w)
+							break loop
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTransformPath(t *testing.T) {
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 0\n" +
+		"path [csel] 0 0\nL 10 0\nA 10 5 0 0 1 10 10\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc string
+		m    f64.Aff3
+		want ivg.Path
+	}{{
+		desc: "translate",
+		m:    f64.Aff3{1, 0, 5, 0, 1, -5},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{5, -5}},
+			ivg.LineTo{To: f32.Vec2{15, -5}},
+			ivg.ArcTo{Radii: f32.Vec2{10, 5}, Sweep: true, To: f32.Vec2{15, 5}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "scale",
+		m:    f64.Aff3{2, 0, 0, 0, 2, 0},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{20, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{20, 10}, Sweep: true, To: f32.Vec2{20, 20}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "mirror reverses the sweep",
+		m:    f64.Aff3{-1, 0, 0, 0, 1, 0},
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{0, 0}},
+			ivg.LineTo{To: f32.Vec2{-10, 0}},
+			ivg.ArcTo{Radii: f32.Vec2{10, 5}, XAxisRotation: 0.5, Sweep: false, To: f32.Vec2{-10, 10}},
+			ivg.ClosePath{},
+		},
+	}}
+	for _, tc := range testCases {
+		h := *g
+		h.Shapes = append([]ivg.Shape(nil), g.Shapes...)
+		h.Transform(tc.m)
+		got := h.Shapes[0].Path
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %d segments, want %d", tc.desc, len(got), len(tc.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: segment #%d: got %v, want %v", tc.desc, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestTransformSingular(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/arcs.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := ivg.Transform(src, f64.Aff3{1, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range g.Shapes {
+		for _, seg := range s.Path {
+			if _, ok := seg.(ivg.ArcTo); ok {
+				t.Fatalf("shape #%d: got an ArcTo, want it lowered to CubeTo segments", i)
+			}
+			for _, p := range points(seg) {
+				if p[1] != 0 {
+					t.Fatalf("shape #%d: got y = %v, want 0", i, p[1])
+				}
+			}
+		}
+	}
+}
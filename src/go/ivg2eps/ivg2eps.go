@@ -0,0 +1,212 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2eps converts IconVG graphics to Encapsulated PostScript (EPS).
+//
+// The result is an EPS file whose single page holds the graphic as vector
+// paths, for print and plotting workflows that consume EPS. Like package
+// ivg2pdf, the conversion paints the graphic with package pspaint, executing
+// the byte code's virtual machine directly, without an intermediate raster or
+// document model.
+//
+// Flat colors become RGB fill colors. Gradients become PostScript LanguageLevel
+// 3 axial or radial shadings, clipped to the path, and the file declares
+// LanguageLevel 3 if it has any. PostScript shadings have no equivalent to
+// IconVG's "reflect" and "repeat" spreads, so those are unrolled, period by
+// period, across the path's bounds.
+//
+// PostScript has no transparency, so semi-transparent colors, including
+// gradient stops, are composited over the Options' Background color, as if
+// the path had nothing else beneath it, and fully transparent paths are not
+// drawn. This is exact for graphics whose semi-transparent paths do not
+// overlap others. Compositing the stops first, then interpolating, gives the
+// same colors as IconVG's premultiplied interpolation.
+package ivg2eps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/google/iconvg/src/go/internal/pspaint"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+var errInvalidPageSize = errors.New("ivg2eps: invalid page size")
+
+// Options are the optional parameters to the Convert function.
+type Options struct {
+	// Width and Height are the page size, in points (1/72 of an inch). The
+	// graphic's viewBox is scaled uniformly to fit the page, and centered.
+	//
+	// If both are zero, the page is the size of the viewBox, at one point per
+	// viewBox unit. If only one is zero, it is calculated from the other and
+	// the viewBox's aspect ratio. Height also selects which level of detail
+	// is converted.
+	Width  float64
+	Height float64
+
+	// FillRule is the rule that paths are filled with, as for
+	// lowlevel.DecodeOptions.FillRule. It is the nonzero rule by default.
+	FillRule lowlevel.FillRule
+
+	// Background is the color that semi-transparent colors are composited
+	// over. If nil, it is opaque white. The page itself is not painted.
+	Background color.Color
+}
+
+// Convert converts the IconVG graphic src to an EPS file, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	c := &canvas{opts: opts, background: color.NRGBA{0xff, 0xff, 0xff, 0xff}}
+	if opts.Background != nil {
+		c.background = color.NRGBAModel.Convert(opts.Background).(color.NRGBA)
+		c.background.A = 0xff
+	}
+	p := pspaint.NewPainter(c)
+	if err := lowlevel.Decode(p, src, &lowlevel.DecodeOptions{FillRule: opts.FillRule}); err != nil {
+		return err
+	} else if err := p.Err(); err != nil {
+		return err
+	}
+	return c.writeDocument(w)
+}
+
+// canvas is a pspaint.Canvas that paints onto a PostScript page. Its user
+// space is the graphic's coordinate space: the page starts by transforming
+// from that to the default user space.
+type canvas struct {
+	opts       *Options
+	background color.NRGBA
+
+	pageWidth  float64
+	pageHeight float64
+	metadata   lowlevel.Metadata
+
+	// content is the page's PostScript. level3 is whether content uses
+	// LanguageLevel 3 shadings.
+	content bytes.Buffer
+	level3  bool
+}
+
+var _ pspaint.Canvas = (*canvas)(nil)
+
+func (c *canvas) Begin(m lowlevel.Metadata) (lodHeight float32, err error) {
+	c.metadata = m
+	page, ok := pspaint.FitPage(m.ViewBox, c.opts.Width, c.opts.Height)
+	if !ok {
+		return 0, errInvalidPageSize
+	}
+	c.pageWidth, c.pageHeight = page.Width, page.Height
+
+	// The page's transform flips the y axis, as PostScript's origin is the
+	// bottom left.
+	t, vb := &page.Transform, &m.ViewBox
+	c.printf("[%s 0 0 %s %s %s] concat\n", pspaint.Ftoa(t[0]), pspaint.Ftoa(t[4]), pspaint.Ftoa(t[2]), pspaint.Ftoa(t[5]))
+	c.printf("%s %s %s %s rectclip\n", pspaint.Ftoa32(vb.Min[0]), pspaint.Ftoa32(vb.Min[1]),
+		pspaint.Ftoa(float64(vb.Max[0]-vb.Min[0])), pspaint.Ftoa(float64(vb.Max[1]-vb.Min[1])))
+	return page.LODHeight, nil
+}
+
+func (c *canvas) FillColor(path []byte, rule lowlevel.FillRule, rgba color.RGBA) {
+	c.printf("%s rg\n", c.rgb(rgba))
+	c.content.Write(path)
+	if rule == lowlevel.FillRuleEvenOdd {
+		c.printf("eofill\n")
+	} else {
+		c.printf("f\n")
+	}
+}
+
+// FillGradient fills path with a LanguageLevel 3 axial or radial shading,
+// clipped to the path.
+func (c *canvas) FillGradient(path []byte, rule lowlevel.FillRule, g *pspaint.Gradient) {
+	colorFunc := g.Function(func(i int) string { return c.rgb(g.Stops[i].Color) })
+	c.level3 = true
+	c.printf("gsave\n")
+	c.content.Write(path)
+	if rule == lowlevel.FillRuleEvenOdd {
+		c.printf("eo")
+	}
+	c.printf("clip newpath\n[%s] concat\n", pspaint.Matrix(&g.Inverse))
+	c.printf("%s shfill\ngrestore\n", g.Shading("/DeviceRGB", colorFunc))
+}
+
+// rgb formats the alpha-premultiplied color rgba, composited over the
+// background, as PostScript RGB components.
+func (c *canvas) rgb(rgba color.RGBA) string {
+	if (rgba.R > rgba.A) || (rgba.G > rgba.A) || (rgba.B > rgba.A) {
+		return "0 0 0"
+	}
+	a := 0xff - uint32(rgba.A)
+	over := func(x uint8, bg uint8) string {
+		return pspaint.FtoaUnit(uint8((uint32(x)*0xff + a*uint32(bg) + 0x7f) / 0xff))
+	}
+	return over(rgba.R, c.background.R) + " " + over(rgba.G, c.background.G) + " " + over(rgba.B, c.background.B)
+}
+
+func (c *canvas) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&c.content, format, args...)
+}
+
+// prolog defines the abbreviated operators that the page uses.
+const prolog = `/m /moveto load def
+/l /lineto load def
+/c /curveto load def
+/h /closepath load def
+/f /fill load def
+/rg /setrgbcolor load def
+`
+
+func (c *canvas) writeDocument(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(bw, "%%%%BoundingBox: 0 0 %d %d\n",
+		int(math.Ceil(c.pageWidth)), int(math.Ceil(c.pageHeight)))
+	fmt.Fprintf(bw, "%%%%HiResBoundingBox: 0 0 %s %s\n", pspaint.Ftoa(c.pageWidth), pspaint.Ftoa(c.pageHeight))
+	fmt.Fprintf(bw, "%%%%Creator: ivg2eps\n")
+	if t := dscText(c.metadata.Title); t != "" {
+		fmt.Fprintf(bw, "%%%%Title: %s\n", t)
+	}
+	if c.level3 {
+		fmt.Fprintf(bw, "%%%%LanguageLevel: 3\n")
+	}
+	fmt.Fprintf(bw, "%%%%Pages: 1\n%%%%EndComments\n")
+	fmt.Fprintf(bw, "%%%%BeginProlog\n%s%%%%EndProlog\n", prolog)
+	fmt.Fprintf(bw, "%%%%Page: 1 1\ngsave\n")
+	bw.Write(c.content.Bytes())
+	fmt.Fprintf(bw, "grestore\nshowpage\n%%%%Trailer\n%%%%EOF\n")
+	return bw.Flush()
+}
+
+// dscText returns s as the text of a Document Structuring Conventions
+// comment, which is a single line of printable ASCII.
+func dscText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r >= 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
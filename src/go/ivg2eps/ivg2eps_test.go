@@ -0,0 +1,267 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2eps_test
+
+import (
+	"bytes"
+	"image/color"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2eps"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestConvertTestData(t *testing.T) {
+	testCases := []struct {
+		filename string
+		level3   bool
+	}{
+		{"action-info.lores.ivg", false},
+		{"arcs.ivg", false},
+		{"blank.ivg", false},
+		{"cowbell.ivg", true},
+		{"favicon.ivg", false},
+		{"gradient.ivg", true},
+		{"lod-polygon.ivg", false},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2eps.Convert(buf, src, nil); err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		eps := buf.String()
+		if !strings.HasPrefix(eps, "%!PS-Adobe-3.0 EPSF-3.0\n%%BoundingBox: 0 0 ") {
+			t.Errorf("%s: output does not start with an EPS header:\n%s", tc.filename, eps)
+		}
+		if !strings.HasSuffix(eps, "grestore\nshowpage\n%%Trailer\n%%EOF\n") {
+			t.Errorf("%s: output does not end with an EPS trailer", tc.filename)
+		}
+		if got := strings.Contains(eps, "%%LanguageLevel: 3\n"); got != tc.level3 {
+			t.Errorf("%s: got LanguageLevel 3 %t, want %t", tc.filename, got, tc.level3)
+		}
+		if got := strings.Contains(eps, " shfill\n"); got != tc.level3 {
+			t.Errorf("%s: got shfill %t, want %t", tc.filename, got, tc.level3)
+		}
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	src, err := ivg.NewBuilder().SetViewBox(0, 0, 48, 24).
+		MoveTo(0, 0).LineTo(8, 0).LineTo(8, 8).ClosePath().
+		Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		opts    *ivg2eps.Options
+		want    string
+		wantErr bool
+	}{
+		{nil, "%%BoundingBox: 0 0 48 24\n%%HiResBoundingBox: 0 0 48 24\n", false},
+		{&ivg2eps.Options{Width: 96}, "%%BoundingBox: 0 0 96 48\n", false},
+		{&ivg2eps.Options{Height: 12.5}, "%%BoundingBox: 0 0 25 13\n%%HiResBoundingBox: 0 0 25 12.5\n", false},
+		{&ivg2eps.Options{Width: 100, Height: 100}, "%%BoundingBox: 0 0 100 100\n", false},
+		{&ivg2eps.Options{Width: -1}, "", true},
+		{&ivg2eps.Options{Height: math.Inf(+1)}, "", true},
+		{&ivg2eps.Options{Width: math.NaN(), Height: 10}, "", true},
+	}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		err := ivg2eps.Convert(buf, src, tc.opts)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%+v: got error %v, want error %t", tc.opts, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("%+v: output does not contain %q:\n%s", tc.opts, tc.want, buf.Bytes())
+		}
+	}
+}
+
+func TestPaint(t *testing.T) {
+	triangle := func() *ivg.Builder {
+		return ivg.NewBuilder().MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath()
+	}
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	translucent := lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80})
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: red},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})},
+	}
+	testCases := []struct {
+		desc     string
+		g        *ivg.Graphic
+		opts     *ivg2eps.Options
+		want     []string
+		dontWant []string
+	}{{
+		"opaque color",
+		triangle().Fill(red).Graphic(),
+		nil,
+		[]string{
+			"[1 0 0 -1 32 32] concat\n-32 -32 64 64 rectclip\n",
+			"1 0 0 rg\n-20 -20 m\n20 -20 l\n20 20 l\nh\nf\n",
+		},
+		[]string{"%%LanguageLevel", "%%Title"},
+	}, {
+		"translucent color",
+		triangle().Fill(translucent).Graphic(),
+		nil,
+		[]string{"0.749 0.498 0.498 rg\n"},
+		nil,
+	}, {
+		"translucent color, black background",
+		triangle().Fill(translucent).Graphic(),
+		&ivg2eps.Options{Background: color.Black},
+		[]string{"0.251 0 0 rg\n"},
+		nil,
+	}, {
+		"transparent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{})).Graphic(),
+		nil,
+		nil,
+		[]string{" m\n", " rg\n"},
+	}, {
+		"levels of detail",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			b.SetLOD(0, 30).MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().Fill(red)
+			b.SetLOD(30, ivg.DefaultLOD1).MoveTo(0, 0).LineTo(2, 0).LineTo(2, 2).ClosePath().Fill(red)
+			return b.Graphic()
+		}(),
+		&ivg2eps.Options{Height: 48},
+		[]string{"2 0 l\n"},
+		[]string{"1 0 l\n"},
+	}, {
+		"curves",
+		ivg.NewBuilder().MoveTo(0, 0).QuadTo(3, 0, 3, 3).ArcTo(3, 3, 0, false, true, -3, 3).ClosePath().Fill(red).Graphic(),
+		nil,
+		[]string{"0 0 m\n2 0 3 1 3 3 c\n", "-3 3 c\nh\n"},
+		[]string{" l\n"},
+	}, {
+		"title",
+		func() *ivg.Graphic {
+			g := triangle().Fill(red).Graphic()
+			g.Metadata.Title = "Cow\nbell\u00e9"
+			return g
+		}(),
+		nil,
+		[]string{"%%Title: Cow bell\n"},
+		nil,
+	}, {
+		"linear gradient",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadPad)).Graphic(),
+		nil,
+		[]string{
+			"%%LanguageLevel: 3\n",
+			"h\nclip newpath\n",
+			"<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [0 0 1 0] /Domain [0 1] /Extend [true true] " +
+				"/Function << /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0 0 1] /N 1 >> >> shfill\n",
+		},
+		nil,
+	}, {
+		"linear gradient, none",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).Graphic(),
+		nil,
+		[]string{"/Extend [false false]"},
+		nil,
+	}, {
+		"linear gradient, reflect",
+		triangle().FillPaint(ivg.LinearGradient(stops, -5, 0, 5, 0, ivg.GradientSpreadReflect)).Graphic(),
+		nil,
+		[]string{"/Coords [-2 0 3 0] /Domain [-2 3]", "/Bounds [-1 0 1 2] /Encode [0 1 1 0 0 1 1 0 0 1]"},
+		nil,
+	}, {
+		"radial gradient, repeat",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 10, ivg.GradientSpreadRepeat)).Graphic(),
+		nil,
+		[]string{"/ShadingType 3", "/Coords [0 0 0 0 0 3] /Domain [0 3]", "/Encode [0 1 0 1 0 1]"},
+		nil,
+	}, {
+		"gradient stops",
+		triangle().FillPaint(ivg.LinearGradient([]ivg.GradientStop{
+			{Offset: 0.25, Color: red},
+			{Offset: 0.5, Color: translucent},
+			{Offset: 0.5, Color: red},
+		}, -20, 0, 20, 0, ivg.GradientSpreadPad)).Graphic(),
+		nil,
+		[]string{"/Functions [<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [1 0 0] /N 1 >> " +
+			"<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0.749 0.498 0.498] /N 1 >> " +
+			"<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [1 0 0] /N 1 >>] /Bounds [0.25 0.5]"},
+		nil,
+	}}
+	for _, tc := range testCases {
+		src, err := ivg.Encode(tc.g)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc.desc, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2eps.Convert(buf, src, tc.opts); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		got := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output does not contain %q:\n%s", tc.desc, want, got)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(got, dontWant) {
+				t.Errorf("%s: output contains %q:\n%s", tc.desc, dontWant, got)
+			}
+		}
+	}
+}
+
+func TestFillRule(t *testing.T) {
+	testCases := []struct {
+		filename string
+		rule     lowlevel.FillRule
+		want     string
+		dontWant string
+	}{
+		{"action-info.lores.ivg", lowlevel.FillRuleNonZero, "\nf\n", "eofill"},
+		{"action-info.lores.ivg", lowlevel.FillRuleEvenOdd, "\neofill\n", "\nf\n"},
+		{"gradient.ivg", lowlevel.FillRuleNonZero, "\nclip newpath\n", "eoclip"},
+		{"gradient.ivg", lowlevel.FillRuleEvenOdd, "\neoclip newpath\n", "\nclip newpath\n"},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2eps.Convert(buf, src, &ivg2eps.Options{FillRule: tc.rule}); err != nil {
+			t.Errorf("%s, rule %d: %v", tc.filename, tc.rule, err)
+			continue
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(tc.want)) {
+			t.Errorf("%s, rule %d: output does not contain %q:\n%s", tc.filename, tc.rule, tc.want, buf.Bytes())
+		}
+		if bytes.Contains(buf.Bytes(), []byte(tc.dontWant)) {
+			t.Errorf("%s, rule %d: output contains %q:\n%s", tc.filename, tc.rule, tc.dontWant, buf.Bytes())
+		}
+	}
+}
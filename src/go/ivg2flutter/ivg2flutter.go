@@ -0,0 +1,279 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2flutter converts IconVG graphics to a JSON drawing command list
+// for Flutter, so that a thin Dart shim can use IconVG as an asset format,
+// drawing each command with the dart:ui Canvas API.
+//
+// The JSON is like:
+//
+//	{
+//	  "viewBox": [-32, -32, 32, 32],
+//	  "palette": [4294901760],
+//	  "paths": [{
+//	    "maxHeight": 80,
+//	    "fillType": "nonZero",
+//	    "path": [["moveTo", -8, -8], ["lineTo", 8, -8], ["arcToPoint", 8, 8, 8, 8, 0, false, true], ["close"]],
+//	    "paint": {"color": 4294901760, "paletteIndex": 0}
+//	  }]
+//	}
+//
+// Colors are non-premultiplied 32-bit ARGB values, as taken by dart:ui's
+// Color constructor. The palette is the graphic's suggested palette, without
+// trailing opaque black entries. Each path has the dart:ui PathFillType name
+// and the dart:ui Path method calls, with their arguments, that build it. An
+// arcToPoint's arguments are the arc's end point, its radii, its rotation in
+// degrees, and its largeArc and clockwise flags. A path is only drawn if the
+// height, in pixels, that the viewBox is drawn at is at least its
+// "minHeight" and less than its "maxHeight", which are omitted if they are
+// the defaults (zero and infinity).
+//
+// A paint is either a flat color or a gradient "shader":
+//
+//	{"shader": {
+//	  "type": "linear",
+//	  "colors": [4290772992, 4294950912],
+//	  "colorStops": [0, 1],
+//	  "tileMode": "clamp",
+//	  "matrix4": [32, 0, 0, 0, 0, 32, 0, 0, 0, 0, 1, 0, -16, 0, 0, 1]
+//	}}
+//
+// whose arguments are those of dart:ui's Gradient.linear, from (0, 0) to
+// (1, 0), or Gradient.radial, with center (0, 0) and radius 1, and optionally
+// a "focal" point with a zero focal radius. All four of IconVG's gradient
+// spreads have an equivalent TileMode. The Matrix4, in column-major order,
+// maps from gradient coordinate space to the viewBox's coordinate space.
+//
+// Flat colors that refer to the palette, possibly blended with transparent
+// black, have a "paletteIndex", and an "opacity" if blended, so that the shim
+// can theme them. Gradient stops that refer to the palette unblended are
+// listed in the shader's "paletteIndices", which holds -1 for the other
+// stops.
+//
+// Like SVG, and unlike IconVG, Skia (and hence Flutter) interpolates gradient
+// stops in non-premultiplied color. These only differ for gradients with
+// semi-transparent stops.
+package ivg2flutter
+
+import (
+	"encoding/json"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Options are the optional parameters to the Convert and Write functions.
+type Options struct {
+	// Indent, if non-empty, indents the JSON, one Indent per level of
+	// nesting, as per json.MarshalIndent. Otherwise, the JSON is compact.
+	Indent string
+}
+
+// Convert converts the IconVG graphic src to Flutter JSON, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	g, err := ivg.DecodeThemable(src)
+	if err != nil {
+		return err
+	}
+	return Write(w, g, opts)
+}
+
+// Write writes g as Flutter JSON to w.
+//
+// opts may be nil, which means to use the default options.
+func Write(w io.Writer, g *ivg.Graphic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", opts.Indent)
+	return enc.Encode(convert(g))
+}
+
+type jsonGraphic struct {
+	ViewBox     [4]float32 `json:"viewBox"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Palette     []uint32   `json:"palette,omitempty"`
+	Paths       []jsonPath `json:"paths"`
+}
+
+type jsonPath struct {
+	MinHeight float32         `json:"minHeight,omitempty"`
+	MaxHeight float32         `json:"maxHeight,omitempty"`
+	FillType  string          `json:"fillType"`
+	Path      [][]interface{} `json:"path"`
+	Paint     jsonPaint       `json:"paint"`
+}
+
+type jsonPaint struct {
+	Color        *uint32     `json:"color,omitempty"`
+	PaletteIndex *uint8      `json:"paletteIndex,omitempty"`
+	Opacity      float32     `json:"opacity,omitempty"`
+	Shader       *jsonShader `json:"shader,omitempty"`
+}
+
+type jsonShader struct {
+	Type           string      `json:"type"`
+	Focal          *[2]float32 `json:"focal,omitempty"`
+	Colors         []uint32    `json:"colors"`
+	ColorStops     []float32   `json:"colorStops"`
+	TileMode       string      `json:"tileMode"`
+	Matrix4        [16]float32 `json:"matrix4"`
+	PaletteIndices []int       `json:"paletteIndices,omitempty"`
+}
+
+// tileModes are the dart:ui TileMode names of the IconVG gradient spreads.
+var tileModes = [4]string{"decal", "clamp", "mirror", "repeated"}
+
+func convert(g *ivg.Graphic) *jsonGraphic {
+	m := &g.Metadata
+	j := &jsonGraphic{
+		ViewBox:     [4]float32{m.ViewBox.Min[0], m.ViewBox.Min[1], m.ViewBox.Max[0], m.ViewBox.Max[1]},
+		Title:       m.Title,
+		Description: m.Description,
+		Paths:       []jsonPath{},
+	}
+	if m.Palette != lowlevel.DefaultPalette {
+		n := len(m.Palette)
+		for (n > 0) && (m.Palette[n-1] == lowlevel.DefaultPalette[n-1]) {
+			n--
+		}
+		for _, rgba := range m.Palette[:n] {
+			j.Palette = append(j.Palette, argb(rgba))
+		}
+	}
+
+	for _, s := range g.Shapes {
+		if (len(s.Path) == 0) || !(s.LOD0 < s.LOD1) || !(s.LOD1 > 0) {
+			continue
+		}
+		p := jsonPath{
+			MinHeight: s.LOD0,
+			FillType:  "nonZero",
+			Path:      pathCalls(s.Path),
+		}
+		if !math.IsInf(float64(s.LOD1), +1) {
+			p.MaxHeight = s.LOD1
+		}
+		if s.FillRule == lowlevel.FillRuleEvenOdd {
+			p.FillType = "evenOdd"
+		}
+		col := s.Paint.Color
+		if grad := s.Paint.Gradient; grad != nil {
+			if inv, ok := lowlevel.InvertGradientTransform(grad.Transform, grad.Shape == ivg.GradientShapeLinear); ok {
+				p.Paint.Shader = shader(grad, &inv, &m.Palette)
+			} else if n := len(grad.Stops); n > 0 {
+				// Like SVG, a degenerate gradient is painted with its last
+				// stop's color.
+				col = grad.Stops[n-1].Color
+			} else {
+				col = lowlevel.RGBAColor(color.RGBA{})
+			}
+		}
+		if p.Paint.Shader == nil {
+			c := argb(col.Resolve(&m.Palette, nil))
+			p.Paint.Color = &c
+			if i, opacity, ok := col.PaletteRef(); ok {
+				p.Paint.PaletteIndex = &i
+				if opacity != 1 {
+					p.Paint.Opacity = opacity
+				}
+			}
+		}
+		j.Paths = append(j.Paths, p)
+	}
+	return j
+}
+
+// pathCalls returns the dart:ui Path method calls that build p.
+func pathCalls(p ivg.Path) [][]interface{} {
+	calls := make([][]interface{}, 0, len(p))
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			calls = append(calls, []interface{}{"moveTo", seg.To[0], seg.To[1]})
+		case ivg.LineTo:
+			calls = append(calls, []interface{}{"lineTo", seg.To[0], seg.To[1]})
+		case ivg.QuadTo:
+			calls = append(calls, []interface{}{"quadraticBezierTo",
+				seg.Ctrl[0], seg.Ctrl[1], seg.To[0], seg.To[1]})
+		case ivg.CubeTo:
+			calls = append(calls, []interface{}{"cubicTo",
+				seg.Ctrl0[0], seg.Ctrl0[1], seg.Ctrl1[0], seg.Ctrl1[1], seg.To[0], seg.To[1]})
+		case ivg.ArcTo:
+			// With the y axis pointing down, SVG's positive-angle sweep
+			// direction is clockwise.
+			calls = append(calls, []interface{}{"arcToPoint", seg.To[0], seg.To[1],
+				seg.Radii[0], seg.Radii[1], seg.XAxisRotation * 360, seg.LargeArc, seg.Sweep})
+		case ivg.ClosePath:
+			calls = append(calls, []interface{}{"close"})
+		}
+	}
+	return calls
+}
+
+// shader returns the shader for the gradient g, whose transform's inverse is
+// inv.
+func shader(g *ivg.Gradient, inv *f64.Aff3, pal *lowlevel.Palette) *jsonShader {
+	sh := &jsonShader{
+		Type:       "linear",
+		Colors:     make([]uint32, len(g.Stops)),
+		ColorStops: make([]float32, len(g.Stops)),
+		TileMode:   tileModes[g.Spread&3],
+		Matrix4: [16]float32{
+			float32(inv[0]), float32(inv[3]), 0, 0,
+			float32(inv[1]), float32(inv[4]), 0, 0,
+			0, 0, 1, 0,
+			float32(inv[2]), float32(inv[5]), 0, 1,
+		},
+	}
+	if g.Shape == ivg.GradientShapeRadial {
+		sh.Type = "radial"
+		if g.Focal != (f32.Vec2{}) {
+			sh.Focal = &[2]float32{g.Focal[0], g.Focal[1]}
+		}
+	}
+	themed := false
+	indices := make([]int, len(g.Stops))
+	for i, stop := range g.Stops {
+		sh.Colors[i] = argb(stop.Color.Resolve(pal, nil))
+		sh.ColorStops[i] = stop.Offset
+		indices[i] = -1
+		if j, ok := stop.Color.PaletteIndex(); ok {
+			indices[i], themed = int(j), true
+		}
+	}
+	if themed {
+		sh.PaletteIndices = indices
+	}
+	return sh
+}
+
+// argb returns an alpha-premultiplied color as a non-premultiplied 32-bit
+// ARGB value. Invalid alpha-premultiplied colors become opaque black.
+func argb(rgba color.RGBA) uint32 {
+	if (rgba.R > rgba.A) || (rgba.G > rgba.A) || (rgba.B > rgba.A) {
+		return 0xff000000
+	}
+	c := color.NRGBAModel.Convert(rgba).(color.NRGBA)
+	return uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
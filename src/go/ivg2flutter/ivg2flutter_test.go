@@ -0,0 +1,230 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2flutter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2flutter"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestConvertTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := ivg.DecodeThemable(src)
+		if err != nil {
+			t.Fatalf("%s: DecodeThemable: %v", tc, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2flutter.Convert(buf, src, nil); err != nil {
+			t.Errorf("%s: %v", tc, err)
+			continue
+		}
+		got := struct {
+			ViewBox [4]float32
+			Paths   []json.RawMessage
+		}{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Errorf("%s: Unmarshal: %v", tc, err)
+			continue
+		}
+		vb := g.Metadata.ViewBox
+		if want := [4]float32{vb.Min[0], vb.Min[1], vb.Max[0], vb.Max[1]}; got.ViewBox != want {
+			t.Errorf("%s: viewBox: got %v, want %v", tc, got.ViewBox, want)
+		}
+		if len(got.Paths) != len(g.Shapes) {
+			t.Errorf("%s: got %d paths, want %d", tc, len(got.Paths), len(g.Shapes))
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, indented := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := ivg2flutter.Convert(compact, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ivg2flutter.Convert(indented, src, &ivg2flutter.Options{Indent: "\t"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(compact.Bytes(), []byte("\n")); n != 1 {
+		t.Errorf("compact: got %d lines, want 1", n)
+	}
+	if !bytes.Contains(indented.Bytes(), []byte("\n\t\"paths\": [\n")) {
+		t.Errorf("indented: got:\n%s", indented.Bytes())
+	}
+	got := &bytes.Buffer{}
+	if err := json.Compact(got, indented.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if want := bytes.TrimSuffix(compact.Bytes(), []byte("\n")); !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("indented and compact JSON differ:\n%s\n%s", got.Bytes(), want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: red},
+		{Offset: 1, Color: lowlevel.PaletteIndexColor(1)},
+	}
+	triangle := func() *ivg.Builder {
+		return ivg.NewBuilder().MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath()
+	}
+	testCases := []struct {
+		desc     string
+		g        *ivg.Graphic
+		want     []string
+		dontWant []string
+	}{{
+		"RGBA color",
+		triangle().Fill(red).Graphic(),
+		[]string{
+			`"viewBox":[-32,-32,32,32]`,
+			`"fillType":"nonZero","path":[["moveTo",-20,-20],["lineTo",20,-20],["lineTo",20,20],["close"]]`,
+			`"paint":{"color":4294901760}`,
+		},
+		[]string{`"palette"`, `"minHeight"`, `"maxHeight"`},
+	}, {
+		"translucent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80})).Graphic(),
+		[]string{`"paint":{"color":2155806720}`},
+		nil,
+	}, {
+		"palette color",
+		triangle().Fill(lowlevel.PaletteIndexColor(2)).Graphic(),
+		[]string{`"paint":{"color":4278190080,"paletteIndex":2}`},
+		[]string{`"opacity"`},
+	}, {
+		"blended palette color",
+		triangle().Fill(lowlevel.BlendColor(0x80, 0x82, 0x7f)).Graphic(),
+		[]string{`"paletteIndex":2,"opacity":0.49803922}`},
+		nil,
+	}, {
+		"custom palette",
+		func() *ivg.Graphic {
+			pal := lowlevel.DefaultPalette
+			pal[0] = color.RGBA{0xff, 0x00, 0x00, 0xff}
+			pal[1] = color.RGBA{0x00, 0x00, 0x80, 0x80}
+			return triangle().SetPalette(&pal).Fill(lowlevel.PaletteIndexColor(1)).Graphic()
+		}(),
+		[]string{`"palette":[4294901760,2147483903]`, `"paint":{"color":2147483903,"paletteIndex":1}`},
+		nil,
+	}, {
+		"even-odd fill rule",
+		triangle().SetFillRule(lowlevel.FillRuleEvenOdd).Fill(red).Graphic(),
+		[]string{`"fillType":"evenOdd"`},
+		[]string{`"nonZero"`},
+	}, {
+		"levels of detail",
+		triangle().SetLOD(10, 20).Fill(red).Graphic(),
+		[]string{`{"minHeight":10,"maxHeight":20,`},
+		nil,
+	}, {
+		"empty level of detail",
+		triangle().SetLOD(20, 10).Fill(red).Graphic(),
+		[]string{`"paths":[]`},
+		nil,
+	}, {
+		"curves",
+		ivg.NewBuilder().MoveTo(0, 0).QuadTo(10, 0, 10, 10).CubeTo(10, 20, 0, 20, 0, 10).
+			ArcTo(5, 4, 0.25, false, true, 0, 0).ClosePath().Fill(red).Graphic(),
+		[]string{
+			`["quadraticBezierTo",10,0,10,10]`,
+			`["cubicTo",10,20,0,20,0,10]`,
+			`["arcToPoint",0,0,5,4,90,false,true]`,
+		},
+		nil,
+	}, {
+		"metadata",
+		func() *ivg.Graphic {
+			g := triangle().SetViewBox(0, 0, 48, 24).Fill(red).Graphic()
+			g.Metadata.Title = "Cowbell"
+			g.Metadata.Description = "A bell"
+			return g
+		}(),
+		[]string{`{"viewBox":[0,0,48,24],"title":"Cowbell","description":"A bell",`},
+		nil,
+	}, {
+		"linear gradient",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadPad)).Graphic(),
+		[]string{
+			`"type":"linear","colors":[4294901760,4278190080],"colorStops":[0,1],"tileMode":"clamp",` +
+				`"matrix4":[40,0,0,0,-0,40,0,0,0,0,1,0,-20,-0,0,1],"paletteIndices":[-1,1]`,
+		},
+		[]string{`"color":`, `"focal"`},
+	}, {
+		"radial gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops[:1], 0, 0, 10, ivg.GradientSpreadReflect)).Graphic(),
+		[]string{`"type":"radial","colors":[4294901760],"colorStops":[0],"tileMode":"mirror"`},
+		[]string{`"paletteIndices"`, `"focal"`},
+	}, {
+		"radial gradient, focal",
+		triangle().FillPaint(ivg.FocalRadialGradient(stops, 0, 0, 20, 10, 0, ivg.GradientSpreadRepeat)).Graphic(),
+		[]string{`"type":"radial","focal":[0.5,0],`, `"tileMode":"repeated"`},
+		nil,
+	}, {
+		"linear gradient, none",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).Graphic(),
+		[]string{`"tileMode":"decal"`},
+		nil,
+	}, {
+		"degenerate gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 0, ivg.GradientSpreadPad)).Graphic(),
+		[]string{`"paint":{"color":4278190080,"paletteIndex":1}`},
+		[]string{`"shader"`},
+	}}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		if err := ivg2flutter.Write(buf, tc.g, nil); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		got := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output does not contain %s:\n%s", tc.desc, want, got)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(got, dontWant) {
+				t.Errorf("%s: output contains %s:\n%s", tc.desc, dontWant, got)
+			}
+		}
+	}
+}
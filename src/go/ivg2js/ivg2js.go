@@ -0,0 +1,478 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2js converts IconVG graphics to JavaScript code that draws them
+// with the Canvas 2D API, so that web pages can inline icons without a
+// WebAssembly decoder (see package wasm).
+//
+// The generated function takes a CanvasRenderingContext2D (or an
+// OffscreenCanvasRenderingContext2D), a destination rectangle and an optional
+// palette:
+//
+//	drawIcon(ctx, x, y, width, height, palette)
+//
+// The graphic's viewBox is fitted to, and centered within, the rectangle,
+// preserving its aspect ratio. Levels of detail are selected at run time, by
+// the fitted viewBox's height in pixels. The palette, if given, is an array of
+// CSS colors that override the graphic's suggested palette, entry by entry.
+//
+// Fills whose colors refer to the palette, possibly blended with transparent
+// black, use the palette's CSS colors, so that the drawing can be themed.
+// Gradient stops only do so if they refer to the palette unblended.
+//
+// Arcs are converted to cubic Bézier curves. All four of IconVG's gradient
+// spreads are converted: the Canvas 2D API only pads, so the reflect and
+// repeat spreads are emulated by repeating the stops across each filled
+// path's bounds, and the none spread by transparent stops at either end. Like
+// SVG, and unlike IconVG, the Canvas 2D API interpolates gradient stops in
+// non-premultiplied color. These only differ for gradients with
+// semi-transparent stops.
+package ivg2js
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// maxPeriods bounds how many times a reflected or repeated gradient's stops
+// are repeated. Past that, the gradient pads.
+const maxPeriods = 256
+
+var errInvalidFuncName = errors.New("ivg2js: invalid function name")
+
+// Options are the optional parameters to the Convert and Write functions.
+type Options struct {
+	// FuncName is the generated function's name. If empty, it is "drawIcon".
+	FuncName string
+
+	// Module is whether to write an ES module that exports the function,
+	// instead of a classic script that declares it.
+	Module bool
+}
+
+// Convert converts the IconVG graphic src to JavaScript, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	g, err := ivg.DecodeThemable(src)
+	if err != nil {
+		return err
+	}
+	return Write(w, g, opts)
+}
+
+// Write writes g as JavaScript to w.
+//
+// opts may be nil, which means to use the default options.
+func Write(w io.Writer, g *ivg.Graphic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	name := opts.FuncName
+	if name == "" {
+		name = "drawIcon"
+	} else if !validIdentifier(name) {
+		return errInvalidFuncName
+	}
+	bw := bufio.NewWriter(w)
+	c := &converter{w: bw, g: g}
+	c.convert(name, opts.Module)
+	return bw.Flush()
+}
+
+// validIdentifier returns whether s is a JavaScript identifier. Only ASCII
+// identifiers are accepted, and reserved words are not rejected.
+func validIdentifier(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c == '_') || (c == '$') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') ||
+			(i > 0 && '0' <= c && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return s != ""
+}
+
+type converter struct {
+	w *bufio.Writer
+	g *ivg.Graphic
+}
+
+func (c *converter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, format, args...)
+}
+
+func (c *converter) convert(name string, module bool) {
+	m := &c.g.Metadata
+	for _, s := range [2]string{m.Title, m.Description} {
+		if s = comment(s); s != "" {
+			c.printf("// %s\n", s)
+		}
+	}
+	if module {
+		c.printf("export ")
+	}
+	c.printf("function %s(ctx, x, y, width, height, palette) {\n", name)
+
+	if c.usesPalette() {
+		c.printf("  const P = [")
+		n := len(m.Palette)
+		for (n > 1) && (m.Palette[n-1] == lowlevel.DefaultPalette[n-1]) {
+			n--
+		}
+		for i, rgba := range m.Palette[:n] {
+			if i > 0 {
+				c.printf(", ")
+			}
+			c.printf("%q", cssColor(rgba))
+		}
+		c.printf("];\n")
+		c.printf("  const p = (i) => (palette && palette[i]) || P[i] || %q;\n", cssColor(lowlevel.DefaultPalette[0]))
+	}
+
+	vb := &m.ViewBox
+	dx, dy := vb.AspectRatio()
+	c.printf("  const s = Math.min(width / %s, height / %s);\n", ftoa(dx), ftoa(dy))
+	c.printf("  const h = %s * s;\n", ftoa(dy))
+	c.printf("  let g;\n")
+	c.printf("  ctx.save();\n")
+	c.printf("  ctx.translate(x + (width - %s * s) / 2, y + (height - h) / 2);\n", ftoa(dx))
+	c.printf("  ctx.scale(s, s);\n")
+	if (vb.Min[0] != 0) || (vb.Min[1] != 0) {
+		c.printf("  ctx.translate(%s, %s);\n", ftoa(-vb.Min[0]), ftoa(-vb.Min[1]))
+	}
+	for i := range c.g.Shapes {
+		c.writeShape(&c.g.Shapes[i])
+	}
+	c.printf("  ctx.restore();\n")
+	c.printf("}\n")
+}
+
+// usesPalette returns whether any of the Graphic's colors refer to the
+// palette, as per lowlevel.Color.PaletteRef.
+func (c *converter) usesPalette() bool {
+	for _, s := range c.g.Shapes {
+		if g := s.Paint.Gradient; g == nil {
+			if _, _, ok := s.Paint.Color.PaletteRef(); ok {
+				return true
+			}
+		} else {
+			for _, stop := range g.Stops {
+				if _, ok := stop.Color.PaletteIndex(); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (c *converter) writeShape(s *ivg.Shape) {
+	path := lowerArcs(s.Path)
+	if len(path) == 0 {
+		return
+	}
+	indent := "  "
+	if (s.LOD0 != ivg.DefaultLOD0) || (s.LOD1 != ivg.DefaultLOD1) {
+		cond := fmt.Sprintf("h >= %s", ftoa(s.LOD0))
+		if s.LOD0 <= 0 {
+			cond = ""
+		}
+		if !math.IsInf(float64(s.LOD1), +1) {
+			if cond != "" {
+				cond += " && "
+			}
+			cond += fmt.Sprintf("h < %s", ftoa(s.LOD1))
+		}
+		if cond == "" {
+			cond = "true"
+		}
+		c.printf("  if (%s) {\n", cond)
+		indent = "    "
+	}
+
+	fill := "ctx.fill();"
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		fill = `ctx.fill("evenodd");`
+	}
+	c.printf("%sctx.beginPath();\n", indent)
+	c.writePath(indent, path)
+	g := s.Paint.Gradient
+	inv, invertible := f64.Aff3{}, false
+	if g != nil {
+		inv, invertible = lowlevel.InvertGradientTransform(g.Transform, g.Shape == ivg.GradientShapeLinear)
+	}
+	if (g != nil) && invertible {
+		c.writeGradient(indent, g, path)
+		c.printf("%sctx.save();\n", indent)
+		c.printf("%sctx.transform(%s, %s, %s, %s, %s, %s);\n", indent,
+			ftoa64(inv[0]), ftoa64(inv[3]), ftoa64(inv[1]), ftoa64(inv[4]), ftoa64(inv[2]), ftoa64(inv[5]))
+		c.printf("%sctx.fillStyle = g;\n", indent)
+		c.printf("%s%s\n", indent, fill)
+		c.printf("%sctx.restore();\n", indent)
+	} else if g != nil {
+		// Like SVG, a degenerate gradient is painted with its last stop's
+		// color.
+		if n := len(g.Stops); n > 0 {
+			c.printf("%sctx.fillStyle = %s;\n", indent, c.stopColor(g.Stops[n-1].Color))
+			c.printf("%s%s\n", indent, fill)
+		}
+	} else if i, opacity, ok := s.Paint.Color.PaletteRef(); ok {
+		c.printf("%sctx.fillStyle = p(%d);\n", indent, i)
+		if opacity != 1 {
+			c.printf("%sctx.globalAlpha = %s;\n", indent, ftoa(opacity))
+		}
+		c.printf("%s%s\n", indent, fill)
+		if opacity != 1 {
+			c.printf("%sctx.globalAlpha = 1;\n", indent)
+		}
+	} else if rgba := s.Paint.Color.Resolve(&c.g.Metadata.Palette, nil); rgba.A != 0 {
+		c.printf("%sctx.fillStyle = %q;\n", indent, cssColor(rgba))
+		c.printf("%s%s\n", indent, fill)
+	}
+	if indent != "  " {
+		c.printf("  }\n")
+	}
+}
+
+func (c *converter) writePath(indent string, path ivg.Path) {
+	for _, seg := range path {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			c.printf("%sctx.moveTo(%s);\n", indent, points(seg.To))
+		case ivg.LineTo:
+			c.printf("%sctx.lineTo(%s);\n", indent, points(seg.To))
+		case ivg.QuadTo:
+			c.printf("%sctx.quadraticCurveTo(%s);\n", indent, points(seg.Ctrl, seg.To))
+		case ivg.CubeTo:
+			c.printf("%sctx.bezierCurveTo(%s);\n", indent, points(seg.Ctrl0, seg.Ctrl1, seg.To))
+		case ivg.ClosePath:
+			c.printf("%sctx.closePath();\n", indent)
+		}
+	}
+}
+
+// writeGradient writes code that sets g, the JavaScript variable, to a
+// CanvasGradient in gradient coordinate space, for a gradient that fills
+// path.
+func (c *converter) writeGradient(indent string, grad *ivg.Gradient, path ivg.Path) {
+	// [k0, k1) are the periods of the gradient's offsets that the path's
+	// bounds cover. Only the reflect and repeat spreads need more than one.
+	k0, k1 := 0, 1
+	if (grad.Spread == ivg.GradientSpreadReflect) || (grad.Spread == ivg.GradientSpreadRepeat) {
+		lo, hi := offsetRange(grad, path)
+		k0 = int(math.Max(-maxPeriods, math.Floor(lo)))
+		k1 = int(math.Min(maxPeriods, math.Ceil(hi)))
+		if grad.Shape == ivg.GradientShapeRadial {
+			k0 = 0
+		}
+		if k1 <= k0 {
+			k1 = k0 + 1
+		}
+		if k1-k0 > maxPeriods {
+			k1 = k0 + maxPeriods
+		}
+	}
+
+	n := float64(k1 - k0)
+	if grad.Shape == ivg.GradientShapeRadial {
+		c.printf("%sg = ctx.createRadialGradient(%s, 0, 0, 0, %s);\n",
+			indent, points(grad.Focal), ftoa(float32(n)))
+	} else {
+		c.printf("%sg = ctx.createLinearGradient(%d, 0, %d, 0);\n", indent, k0, k1)
+	}
+
+	stop := func(offset float64, col lowlevel.Color) {
+		o := float32((offset - float64(k0)) / n)
+		if o < 0 {
+			o = 0
+		} else if o > 1 {
+			o = 1
+		}
+		c.printf("%sg.addColorStop(%s, %s);\n", indent, ftoa(o), c.stopColor(col))
+	}
+	stops := grad.Stops
+	if len(stops) == 0 {
+		stop(float64(k0), lowlevel.RGBAColor(color.RGBA{}))
+		return
+	}
+	none := grad.Spread == ivg.GradientSpreadNone
+	if none {
+		// Like IconVG, the Canvas 2D API gives the later of two stops at the
+		// same offset precedence, past that offset.
+		stop(0, lowlevel.RGBAColor(color.RGBA{}))
+		stop(0, stops[0].Color)
+	}
+	for k := k0; k < k1; k++ {
+		reflect := (grad.Spread == ivg.GradientSpreadReflect) && (k&1 != 0)
+		for i := range stops {
+			s := &stops[i]
+			if reflect {
+				s = &stops[len(stops)-1-i]
+				stop(float64(k)+1-float64(s.Offset), s.Color)
+			} else {
+				stop(float64(k)+float64(s.Offset), s.Color)
+			}
+		}
+	}
+	if none {
+		stop(1, stops[len(stops)-1].Color)
+		stop(1, lowlevel.RGBAColor(color.RGBA{}))
+	}
+}
+
+// stopColor returns the JavaScript expression for a gradient stop's color.
+func (c *converter) stopColor(col lowlevel.Color) string {
+	if i, ok := col.PaletteIndex(); ok {
+		return fmt.Sprintf("p(%d)", i)
+	}
+	return strconv.Quote(cssColor(col.Resolve(&c.g.Metadata.Palette, nil)))
+}
+
+// offsetRange returns the range of the gradient's offsets, before spreading,
+// over the bounds of path's points. For a radial gradient, the lower bound is
+// not tight.
+func offsetRange(grad *ivg.Gradient, path ivg.Path) (lo, hi float64) {
+	bMin := f32.Vec2{float32(math.Inf(+1)), float32(math.Inf(+1))}
+	bMax := f32.Vec2{float32(math.Inf(-1)), float32(math.Inf(-1))}
+	add := func(ps ...f32.Vec2) {
+		for _, p := range ps {
+			bMin[0], bMin[1] = minF32(bMin[0], p[0]), minF32(bMin[1], p[1])
+			bMax[0], bMax[1] = maxF32(bMax[0], p[0]), maxF32(bMax[1], p[1])
+		}
+	}
+	for _, seg := range path {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			add(seg.To)
+		case ivg.LineTo:
+			add(seg.To)
+		case ivg.QuadTo:
+			add(seg.Ctrl, seg.To)
+		case ivg.CubeTo:
+			add(seg.Ctrl0, seg.Ctrl1, seg.To)
+		}
+	}
+
+	lo, hi = math.Inf(+1), math.Inf(-1)
+	m := &grad.Transform
+	for _, x := range [2]float32{bMin[0], bMax[0]} {
+		for _, y := range [2]float32{bMin[1], bMax[1]} {
+			gx := float64(m[0]*x + m[1]*y + m[2])
+			gy := float64(m[3]*x + m[4]*y + m[5])
+			o := gx
+			if grad.Shape == ivg.GradientShapeRadial {
+				// The distance from the focal point bounds the offset, as
+				// the focal point is inside the unit circle.
+				f := grad.Focal
+				o = math.Hypot(gx-float64(f[0]), gy-float64(f[1])) / (1 - math.Hypot(float64(f[0]), float64(f[1])))
+			}
+			lo, hi = math.Min(lo, o), math.Max(hi, o)
+		}
+	}
+	if math.IsNaN(lo) || math.IsNaN(hi) {
+		return 0, 1
+	}
+	return lo, hi
+}
+
+// lowerArcs returns p with its ArcTo segments replaced by CubeTo segments.
+func lowerArcs(p ivg.Path) ivg.Path {
+	ret := make(ivg.Path, 0, len(p))
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			pen, start = seg.To, seg.To
+		case ivg.LineTo:
+			pen = seg.To
+		case ivg.QuadTo:
+			pen = seg.To
+		case ivg.CubeTo:
+			pen = seg.To
+		case ivg.ArcTo:
+			ret = append(ret, seg.Cubics(pen, 0)...)
+			pen = seg.To
+			continue
+		case ivg.ClosePath:
+			pen = start
+		}
+		ret = append(ret, seg)
+	}
+	return ret
+}
+
+// comment returns s as the text of a single-line JavaScript comment.
+func comment(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cssColor returns an alpha-premultiplied color as a CSS color. Invalid
+// alpha-premultiplied colors become opaque black.
+func cssColor(rgba color.RGBA) string {
+	if rgba.A == 0x00 {
+		return "transparent"
+	}
+	nrgba := color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	if (rgba.R <= rgba.A) && (rgba.G <= rgba.A) && (rgba.B <= rgba.A) {
+		nrgba = color.NRGBAModel.Convert(rgba).(color.NRGBA)
+	}
+	if nrgba.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+}
+
+func points(ps ...f32.Vec2) string {
+	s := ""
+	for i, p := range ps {
+		if i > 0 {
+			s += ", "
+		}
+		s += ftoa(p[0]) + ", " + ftoa(p[1])
+	}
+	return s
+}
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+func ftoa64(f float64) string { return ftoa(float32(f)) }
+
+func minF32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2js_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2js"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestConvertTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2js.Convert(buf, src, nil); err != nil {
+			t.Errorf("%s: %v", tc, err)
+			continue
+		}
+		js := buf.String()
+		if !strings.Contains(js, "function drawIcon(ctx, x, y, width, height, palette) {\n") {
+			t.Errorf("%s: output does not declare drawIcon:\n%s", tc, js)
+		}
+		if !strings.HasSuffix(js, "  ctx.restore();\n}\n") {
+			t.Errorf("%s: output does not end with the function's end:\n%s", tc, js)
+		}
+		if got, want := strings.Count(js, "{"), strings.Count(js, "}"); got != want {
+			t.Errorf("%s: got %d '{' and %d '}', want them balanced", tc, got, want)
+		}
+		if strings.Contains(js, "ctx.arc") || strings.Contains(js, "ctx.ellipse") {
+			t.Errorf("%s: output contains arcs, want them converted to curves", tc)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	testCases := []struct {
+		opts    *ivg2js.Options
+		want    string
+		wantErr bool
+	}{
+		{nil, "function drawIcon(", false},
+		{&ivg2js.Options{}, "function drawIcon(", false},
+		{&ivg2js.Options{FuncName: "drawCowbell"}, "function drawCowbell(", false},
+		{&ivg2js.Options{FuncName: "_$icon2"}, "function _$icon2(", false},
+		{&ivg2js.Options{Module: true}, "export function drawIcon(", false},
+		{&ivg2js.Options{FuncName: "2icon"}, "", true},
+		{&ivg2js.Options{FuncName: "draw-icon"}, "", true},
+		{&ivg2js.Options{FuncName: "draw icon"}, "", true},
+		{&ivg2js.Options{FuncName: "drawÍcon"}, "", true},
+	}
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		err := ivg2js.Convert(buf, src, tc.opts)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%+v: got error %v, want error %t", tc.opts, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		js := buf.String()
+		if !strings.HasPrefix(js, tc.want) && !strings.Contains(js, "\n"+tc.want) {
+			t.Errorf("%+v: output does not contain %q:\n%s", tc.opts, tc.want, js)
+		}
+		if module := tc.opts != nil && tc.opts.Module; module != strings.Contains(js, "export ") {
+			t.Errorf("%+v: got export %t, want %t", tc.opts, !module, module)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: red},
+		{Offset: 1, Color: lowlevel.PaletteIndexColor(1)},
+	}
+	triangle := func() *ivg.Builder {
+		return ivg.NewBuilder().MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath()
+	}
+	testCases := []struct {
+		desc     string
+		g        *ivg.Graphic
+		want     []string
+		dontWant []string
+	}{{
+		"RGBA color",
+		triangle().Fill(red).Graphic(),
+		[]string{"ctx.moveTo(-20, -20);\n", "ctx.closePath();\n", `ctx.fillStyle = "#ff0000";`, "ctx.fill();\n"},
+		[]string{"const P", "palette[", "if ("},
+	}, {
+		"translucent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80})).Graphic(),
+		[]string{`ctx.fillStyle = "#7f000080";`},
+		nil,
+	}, {
+		"transparent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{})).Graphic(),
+		[]string{"ctx.beginPath();\n"},
+		[]string{"ctx.fill"},
+	}, {
+		"palette color",
+		triangle().Fill(lowlevel.PaletteIndexColor(0)).Graphic(),
+		[]string{`const P = ["#000000"];`, "const p = (i) => (palette && palette[i]) || P[i]", "ctx.fillStyle = p(0);"},
+		[]string{"globalAlpha"},
+	}, {
+		"blended palette color",
+		triangle().Fill(lowlevel.BlendColor(0x80, 0x82, 0x7f)).Graphic(),
+		[]string{"ctx.fillStyle = p(2);", "ctx.globalAlpha = 0.49803922;", "ctx.globalAlpha = 1;"},
+		nil,
+	}, {
+		"even-odd fill rule",
+		triangle().SetFillRule(lowlevel.FillRuleEvenOdd).Fill(red).Graphic(),
+		[]string{`ctx.fill("evenodd");`},
+		[]string{"ctx.fill();"},
+	}, {
+		"levels of detail",
+		triangle().SetLOD(10, 20).Fill(red).Graphic(),
+		[]string{"  if (h >= 10 && h < 20) {\n    ctx.beginPath();\n"},
+		nil,
+	}, {
+		"curves",
+		ivg.NewBuilder().MoveTo(0, 0).QuadTo(10, 0, 10, 10).CubeTo(10, 20, 0, 20, 0, 10).ArcTo(5, 5, 0, false, true, 0, 0).ClosePath().Fill(red).Graphic(),
+		[]string{"ctx.quadraticCurveTo(10, 0, 10, 10);\n", "ctx.bezierCurveTo(10, 20, 0, 20, 0, 10);\n"},
+		nil,
+	}, {
+		"viewBox",
+		triangle().SetViewBox(0, 0, 48, 24).Fill(red).Graphic(),
+		[]string{"const s = Math.min(width / 48, height / 24);\n", "ctx.translate(x + (width - 48 * s) / 2, y + (height - h) / 2);\n"},
+		[]string{"ctx.translate(0, 0)"},
+	}, {
+		"linear gradient, pad",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadPad)).Graphic(),
+		[]string{"g = ctx.createLinearGradient(0, 0, 1, 0);\n", `g.addColorStop(0, "#ff0000");`, "g.addColorStop(1, p(1));", "ctx.fillStyle = g;"},
+		[]string{`"transparent"`},
+	}, {
+		"linear gradient, none",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).Graphic(),
+		[]string{`g.addColorStop(0, "transparent");` + "\n" + `  g.addColorStop(0, "#ff0000");`, "g.addColorStop(1, p(1));\n  g.addColorStop(1, \"transparent\");"},
+		nil,
+	}, {
+		"linear gradient, repeat",
+		triangle().FillPaint(ivg.LinearGradient(stops, -5, 0, 5, 0, ivg.GradientSpreadRepeat)).Graphic(),
+		[]string{"g = ctx.createLinearGradient(-2, 0, 3, 0);\n", `g.addColorStop(0.2, "#ff0000");`},
+		nil,
+	}, {
+		"radial gradient, reflect",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 10, ivg.GradientSpreadReflect)).Graphic(),
+		[]string{"g = ctx.createRadialGradient(0, 0, 0, 0, 0, 3);\n", "g.addColorStop(0.33333334, p(1));\n  g.addColorStop(0.33333334, p(1));\n  g.addColorStop(0.6666667, \"#ff0000\");"},
+		nil,
+	}, {
+		"radial gradient, focal",
+		triangle().FillPaint(ivg.FocalRadialGradient(stops, 0, 0, 20, 10, 0, ivg.GradientSpreadPad)).Graphic(),
+		[]string{"g = ctx.createRadialGradient(0.5, 0, 0, 0, 0, 1);\n"},
+		nil,
+	}, {
+		"degenerate gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 0, ivg.GradientSpreadPad)).Graphic(),
+		[]string{"ctx.fillStyle = p(1);"},
+		[]string{"createRadialGradient"},
+	}}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		if err := ivg2js.Write(buf, tc.g, nil); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		js := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(js, want) {
+				t.Errorf("%s: output does not contain %q:\n%s", tc.desc, want, js)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(js, dontWant) {
+				t.Errorf("%s: output contains %q:\n%s", tc.desc, dontWant, js)
+			}
+		}
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	g := ivg.NewBuilder().MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().
+		Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})).Graphic()
+	g.Metadata.Title = "Cow\nbell"
+	g.Metadata.Description = "  */ not a block comment  "
+	buf := &bytes.Buffer{}
+	if err := ivg2js.Write(buf, g, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "// Cow bell\n// */ not a block comment\nfunction drawIcon("
+	if got := buf.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("got:\n%s\nwant prefix:\n%s", got, want)
+	}
+}
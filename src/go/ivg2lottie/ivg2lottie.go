@@ -0,0 +1,649 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2lottie converts IconVG animations to Lottie (Bodymovin) JSON,
+// so that Lottie's mobile and web players can play IconVG icon animations.
+//
+// Each frame of a lowlevel.Animation becomes a shape layer that is shown for
+// that frame's duration, like a flip-book. The frame rate is the largest that
+// puts every frame boundary on a whole Lottie frame, with frame durations in
+// whole milliseconds, so that the timing is exact. A still IconVG graphic
+// becomes a single frame animation, one second long. Lottie has no loop
+// count: how many times to play is the player's setting.
+//
+// Each frame's viewBox is fitted to, and centered within, the composition.
+// Paths are converted to Lottie's cubic Bézier shapes, with arcs and
+// quadratic curves converted to cubic ones, and keep their fill rule. Only the
+// level of detail for the composition's height is converted. Flat fills whose
+// colors refer to the palette, possibly blended with transparent black, are
+// named like "iconvg-palette-3", so that players' dynamic properties, such
+// as lottie-android's KeyPath("**", "iconvg-palette-3"), can theme them.
+//
+// Lottie gradients only pad, so the reflect and repeat spreads are emulated by
+// repeating the stops across each filled path's bounds, and the none spread
+// by transparent stops at either end. A radial gradient whose transform is
+// not a similarity is drawn in its own group, whose transform maps a circular
+// gradient onto the ellipse. Lottie interpolates gradient stops'
+// non-premultiplied colors and opacities separately, whereas IconVG
+// interpolates premultiplied color. These only differ for gradients with
+// semi-transparent stops.
+package ivg2lottie
+
+import (
+	"encoding/json"
+	"errors"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+var errNoFrames = errors.New("ivg2lottie: animation has no frames to show")
+
+// maxPeriods bounds how many times a reflected or repeated gradient's stops
+// are repeated. Past that, the gradient pads.
+const maxPeriods = 256
+
+// lottieVersion is the Bodymovin format version that the JSON conforms to.
+const lottieVersion = "5.7.4"
+
+// Options are the optional parameters to the Convert function.
+type Options struct {
+	// Width and Height are the composition's size, in pixels. Height also
+	// selects which level of detail is converted. If both are zero, they are
+	// the first frame's viewBox size, rounded. If only one is zero, it
+	// follows the other and the first frame's viewBox's aspect ratio.
+	Width  int
+	Height int
+
+	// Indent, if non-empty, indents the JSON, one Indent per level of
+	// nesting, as per json.MarshalIndent. Otherwise, the JSON is compact.
+	Indent string
+}
+
+// Convert converts src, an encoded lowlevel.Animation or an IconVG graphic,
+// to Lottie JSON, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	a := &lowlevel.Animation{Frames: []lowlevel.Frame{{Duration: time.Second, Graphic: src}}}
+	if lowlevel.IsAnimation(src) {
+		var err error
+		if a, err = lowlevel.DecodeAnimation(src); err != nil {
+			return err
+		}
+	}
+
+	// Frames are shown for whole milliseconds. The frame rate's period is the
+	// greatest common divisor of the non-zero durations.
+	gs := make([]*ivg.Graphic, len(a.Frames))
+	period, total := int64(0), int64(0)
+	for i, f := range a.Frames {
+		g, err := ivg.DecodeThemable(f.Graphic)
+		if err != nil {
+			return err
+		}
+		gs[i] = g
+		if ms := f.Duration.Milliseconds(); ms > 0 {
+			period = gcd(period, ms)
+			total += ms
+		}
+	}
+	if period == 0 {
+		return errNoFrames
+	}
+
+	width, height := opts.Width, opts.Height
+	dx, dy := gs[0].Metadata.ViewBox.AspectRatio()
+	switch {
+	case width <= 0 && height <= 0:
+		width, height = roundSize(float64(dx)), roundSize(float64(dy))
+	case width <= 0:
+		width = roundSize(float64(height) * float64(dx) / float64(dy))
+	case height <= 0:
+		height = roundSize(float64(width) * float64(dy) / float64(dx))
+	}
+
+	j := &jsonAnimation{
+		Version:   lottieVersion,
+		FrameRate: 1000 / float64(period),
+		OutPoint:  float64(total / period),
+		Width:     width,
+		Height:    height,
+		Name:      gs[0].Metadata.Title,
+		Assets:    []interface{}{},
+		Layers:    []jsonLayer{},
+	}
+	start := int64(0)
+	for i, f := range a.Frames {
+		ms := f.Duration.Milliseconds()
+		if ms <= 0 {
+			continue
+		}
+		j.Layers = append(j.Layers, frameLayer(gs[i], len(j.Layers)+1, width, height,
+			float64(start/period), float64((start+ms)/period)))
+		start += ms
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", opts.Indent)
+	return enc.Encode(j)
+}
+
+type jsonAnimation struct {
+	Version   string        `json:"v"`
+	FrameRate float64       `json:"fr"`
+	InPoint   float64       `json:"ip"`
+	OutPoint  float64       `json:"op"`
+	Width     int           `json:"w"`
+	Height    int           `json:"h"`
+	Name      string        `json:"nm,omitempty"`
+	ThreeD    int           `json:"ddd"`
+	Assets    []interface{} `json:"assets"`
+	Layers    []jsonLayer   `json:"layers"`
+}
+
+type jsonLayer struct {
+	ThreeD     int             `json:"ddd"`
+	Index      int             `json:"ind"`
+	Type       int             `json:"ty"`
+	Name       string          `json:"nm"`
+	Stretch    float64         `json:"sr"`
+	Transform  *jsonTransform  `json:"ks"`
+	AutoOrient int             `json:"ao"`
+	Shapes     []jsonShapeItem `json:"shapes"`
+	InPoint    float64         `json:"ip"`
+	OutPoint   float64         `json:"op"`
+	StartTime  float64         `json:"st"`
+	BlendMode  int             `json:"bm"`
+}
+
+// jsonTransform is a layer's or a group's transform. Lottie applies it as
+// scale, then rotation, then translation.
+type jsonTransform struct {
+	Type     string     `json:"ty,omitempty"`
+	Anchor   jsonValue  `json:"a"`
+	Position jsonValue  `json:"p"`
+	Scale    jsonValue  `json:"s"`
+	Rotation jsonValue  `json:"r"`
+	Opacity  jsonValue  `json:"o"`
+	Skew     *jsonValue `json:"sk,omitempty"`
+	SkewAxis *jsonValue `json:"sa,omitempty"`
+}
+
+// jsonValue is a Lottie property that is not animated.
+type jsonValue struct {
+	Animated int         `json:"a"`
+	Value    interface{} `json:"k"`
+}
+
+// jsonShapeItem is any of Lottie's shape items. Which fields are used depends
+// on the Type: "gr" (group), "sh" (path), "fl" (fill), "gf" (gradient fill)
+// or "tr" (a group's transform).
+type jsonShapeItem struct {
+	Type string `json:"ty"`
+	Name string `json:"nm,omitempty"`
+
+	Items []interface{} `json:"it,omitempty"`
+
+	Bezier *jsonValue `json:"ks,omitempty"`
+
+	Color     *jsonValue `json:"c,omitempty"`
+	Opacity   *jsonValue `json:"o,omitempty"`
+	FillRule  int        `json:"r,omitempty"`
+	GradType  int        `json:"t,omitempty"`
+	Start     *jsonValue `json:"s,omitempty"`
+	End       *jsonValue `json:"e,omitempty"`
+	Highlight *jsonValue `json:"h,omitempty"`
+	HighAngle *jsonValue `json:"a,omitempty"`
+	Gradient  *jsonStops `json:"g,omitempty"`
+}
+
+type jsonStops struct {
+	Count int       `json:"p"`
+	Stops jsonValue `json:"k"`
+}
+
+type jsonBezier struct {
+	In     [][2]float32 `json:"i"`
+	Out    [][2]float32 `json:"o"`
+	Vertex [][2]float32 `json:"v"`
+	Closed bool         `json:"c"`
+}
+
+func value(v interface{}) jsonValue { return jsonValue{Value: v} }
+
+func valuePtr(v interface{}) *jsonValue { return &jsonValue{Value: v} }
+
+// frameLayer returns the shape layer for the graphic g, shown from Lottie
+// frame ip until op.
+func frameLayer(g *ivg.Graphic, index int, width int, height int, ip float64, op float64) jsonLayer {
+	// Fit the viewBox to the composition.
+	vb := &g.Metadata.ViewBox
+	dx, dy := vb.AspectRatio()
+	s := math.Min(float64(width)/float64(dx), float64(height)/float64(dy))
+	tx := (float64(width)-float64(dx)*s)/2 - float64(vb.Min[0])*s
+	ty := (float64(height)-float64(dy)*s)/2 - float64(vb.Min[1])*s
+	h := float32(float64(dy) * s)
+
+	name := g.Metadata.Title
+	if name == "" {
+		name = "frame " + strconv.Itoa(index-1)
+	}
+	l := jsonLayer{
+		Index:     index,
+		Type:      4,
+		Name:      name,
+		Stretch:   1,
+		Transform: transform(tx, ty, 0, s, s),
+		Shapes:    []jsonShapeItem{},
+		InPoint:   ip,
+		OutPoint:  op,
+	}
+	// Lottie draws earlier shape items above later ones.
+	for i := len(g.Shapes) - 1; i >= 0; i-- {
+		sh := &g.Shapes[i]
+		if !(sh.LOD0 <= h && h < sh.LOD1) {
+			continue
+		}
+		if item, ok := shapeGroup(sh, &g.Metadata.Palette); ok {
+			l.Shapes = append(l.Shapes, item)
+		}
+	}
+	return l
+}
+
+// transform returns the transform that scales by (sx, sy), rotates by
+// rotation degrees and translates by (tx, ty).
+func transform(tx, ty, rotation, sx, sy float64) *jsonTransform {
+	return &jsonTransform{
+		Anchor:   value([2]float64{0, 0}),
+		Position: value([2]float64{tx, ty}),
+		Scale:    value([2]float64{sx * 100, sy * 100}),
+		Rotation: value(rotation),
+		Opacity:  value(100),
+	}
+}
+
+// shapeGroup returns the group of shape items that fills s.
+func shapeGroup(s *ivg.Shape, pal *lowlevel.Palette) (jsonShapeItem, bool) {
+	path := s.Path
+	fillRule := 1
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		fillRule = 2
+	}
+
+	var fill jsonShapeItem
+	groupTransform := transform(0, 0, 0, 1, 1)
+	col := s.Paint.Color
+	if grad := s.Paint.Gradient; grad != nil {
+		linear := grad.Shape == ivg.GradientShapeLinear
+		if inv, ok := lowlevel.InvertGradientTransform(grad.Transform, linear); ok {
+			var local f64.Aff3
+			groupTransform, local, path = gradientSpace(inv, path)
+			fill = gradientFill(grad, local, path, pal)
+		} else if n := len(grad.Stops); n > 0 {
+			// Like SVG, a degenerate gradient is painted with its last stop's
+			// color.
+			col = grad.Stops[n-1].Color
+		} else {
+			col = lowlevel.RGBAColor(color.RGBA{})
+		}
+	}
+	if fill.Type == "" {
+		rgba := col.Resolve(pal, nil)
+		i, _, themed := col.PaletteRef()
+		if rgba.A == 0 && !themed {
+			return jsonShapeItem{}, false
+		}
+		c, a := nonPremul(rgba)
+		fill = jsonShapeItem{
+			Type:    "fl",
+			Color:   valuePtr(c),
+			Opacity: valuePtr(a * 100),
+		}
+		if themed {
+			fill.Name = "iconvg-palette-" + strconv.Itoa(int(i))
+		}
+	}
+	fill.FillRule = fillRule
+
+	items := []interface{}(nil)
+	for _, b := range beziers(path) {
+		items = append(items, jsonShapeItem{Type: "sh", Bezier: valuePtr(b)})
+	}
+	if len(items) == 0 {
+		return jsonShapeItem{}, false
+	}
+	groupTransform.Type = "tr"
+	groupTransform.Skew = valuePtr(0)
+	groupTransform.SkewAxis = valuePtr(0)
+	items = append(items, fill, groupTransform)
+	return jsonShapeItem{Type: "gr", Items: items}, true
+}
+
+// gradientSpace returns the group transform, the gradient-to-group transform
+// and the path, in group coordinates, for a gradient fill whose inverse
+// transform is inv. The gradient-to-group transform is a similarity, which
+// Lottie's circular radial gradients can represent. The group transform is
+// the identity unless inv is not a similarity.
+func gradientSpace(inv f64.Aff3, path ivg.Path) (*jsonTransform, f64.Aff3, ivg.Path) {
+	// Decompose inv's linear part as R(φ) × S(sx, sy) × R(θ), where R is a
+	// rotation and S is a scale.
+	a, b, c, d := inv[0], inv[1], inv[3], inv[4]
+	e, f := (a+d)/2, (a-d)/2
+	g, h := (c+b)/2, (c-b)/2
+	q, r := math.Hypot(e, h), math.Hypot(f, g)
+	if r <= q*1e-6 {
+		return transform(0, 0, 0, 1, 1), inv, path
+	}
+	sx, sy := q+r, q-r
+	phi := (math.Atan2(h, e) + math.Atan2(g, f)) / 2
+	theta := (math.Atan2(h, e) - math.Atan2(g, f)) / 2
+
+	// The group's transform is T × R(φ) × S. Map the path by its inverse.
+	sinP, cosP := math.Sincos(phi)
+	m := f64.Aff3{
+		+cosP / sx, +sinP / sx, 0,
+		-sinP / sy, +cosP / sy, 0,
+	}
+	m[2] = -(m[0]*inv[2] + m[1]*inv[5])
+	m[5] = -(m[3]*inv[2] + m[4]*inv[5])
+	tmp := ivg.Graphic{Shapes: []ivg.Shape{{Path: path}}}
+	tmp.Transform(m)
+
+	sinT, cosT := math.Sincos(theta)
+	return transform(inv[2], inv[5], phi*180/math.Pi, sx, sy),
+		f64.Aff3{cosT, -sinT, 0, sinT, cosT, 0},
+		tmp.Shapes[0].Path
+}
+
+// gradientFill returns the gradient fill item for a gradient, where local
+// maps from gradient coordinate space to the space that path is in.
+func gradientFill(grad *ivg.Gradient, local f64.Aff3, path ivg.Path, pal *lowlevel.Palette) jsonShapeItem {
+	apply := func(x, y float64) [2]float64 {
+		return [2]float64{
+			local[0]*x + local[1]*y + local[2],
+			local[3]*x + local[4]*y + local[5],
+		}
+	}
+
+	// [k0, k1) are the periods of the gradient's offsets that the path's
+	// bounds cover. Only the reflect and repeat spreads need more than one.
+	k0, k1 := 0, 1
+	if (grad.Spread == ivg.GradientSpreadReflect) || (grad.Spread == ivg.GradientSpreadRepeat) {
+		lo, hi := offsetRange(grad, local, path)
+		k0 = int(math.Max(-maxPeriods, math.Floor(lo)))
+		k1 = int(math.Min(maxPeriods, math.Ceil(hi)))
+		if grad.Shape == ivg.GradientShapeRadial {
+			k0 = 0
+		}
+		if k1 <= k0 {
+			k1 = k0 + 1
+		}
+		if k1-k0 > maxPeriods {
+			k1 = k0 + maxPeriods
+		}
+	}
+	n := float64(k1 - k0)
+
+	fill := jsonShapeItem{
+		Type:     "gf",
+		Opacity:  valuePtr(100),
+		GradType: 1,
+		Start:    valuePtr(apply(float64(k0), 0)),
+		End:      valuePtr(apply(float64(k1), 0)),
+	}
+	if grad.Shape == ivg.GradientShapeRadial {
+		// Lottie's highlight is the focal point, as a percentage of the
+		// radius, at an angle relative to the start-to-end direction.
+		fill.GradType = 2
+		fill.Start = valuePtr(apply(0, 0))
+		fill.End = valuePtr(apply(n, 0))
+		fx, fy := float64(grad.Focal[0]), float64(grad.Focal[1])
+		fill.Highlight = valuePtr(math.Hypot(fx, fy) / n * 100)
+		fill.HighAngle = valuePtr(math.Atan2(fy, fx) * 180 / math.Pi)
+	}
+
+	var offsets []float64
+	var colors []color.RGBA
+	stop := func(offset float64, col lowlevel.Color) {
+		o := (offset - float64(k0)) / n
+		offsets = append(offsets, math.Max(0, math.Min(1, o)))
+		colors = append(colors, col.Resolve(pal, nil))
+	}
+	stops := grad.Stops
+	if len(stops) == 0 {
+		stop(float64(k0), lowlevel.RGBAColor(color.RGBA{}))
+	}
+	none := (grad.Spread == ivg.GradientSpreadNone) && (len(stops) > 0)
+	if none {
+		stop(0, lowlevel.RGBAColor(color.RGBA{}))
+		stop(0, stops[0].Color)
+	}
+	for k := k0; (k < k1) && (len(stops) > 0); k++ {
+		reflect := (grad.Spread == ivg.GradientSpreadReflect) && (k&1 != 0)
+		for i := range stops {
+			s := &stops[i]
+			if reflect {
+				s = &stops[len(stops)-1-i]
+				stop(float64(k)+1-float64(s.Offset), s.Color)
+			} else {
+				stop(float64(k)+float64(s.Offset), s.Color)
+			}
+		}
+	}
+	if none {
+		stop(1, stops[len(stops)-1].Color)
+		stop(1, lowlevel.RGBAColor(color.RGBA{}))
+	}
+
+	// The color stops are followed, if any stop is not opaque, by the
+	// opacity stops.
+	k, opaque := []float64(nil), true
+	for i, rgba := range colors {
+		c, _ := nonPremul(rgba)
+		k = append(k, offsets[i], c[0], c[1], c[2])
+		opaque = opaque && rgba.A == 0xff
+	}
+	if !opaque {
+		for i, rgba := range colors {
+			_, a := nonPremul(rgba)
+			k = append(k, offsets[i], a)
+		}
+	}
+	fill.Gradient = &jsonStops{Count: len(colors), Stops: value(k)}
+	return fill
+}
+
+// offsetRange returns the range of the gradient's offsets, before spreading,
+// over the bounds of path's points, where local maps from gradient coordinate
+// space to path's coordinate space. For a radial gradient, the lower bound is
+// not tight.
+func offsetRange(grad *ivg.Gradient, local f64.Aff3, path ivg.Path) (lo, hi float64) {
+	bMin := [2]float64{math.Inf(+1), math.Inf(+1)}
+	bMax := [2]float64{math.Inf(-1), math.Inf(-1)}
+	for _, p := range pathPoints(path) {
+		for i := range p {
+			bMin[i] = math.Min(bMin[i], float64(p[i]))
+			bMax[i] = math.Max(bMax[i], float64(p[i]))
+		}
+	}
+
+	// Map the bounds' corners back to gradient coordinate space.
+	m, ok := lowlevel.InvertAff3(local)
+	if !ok {
+		return 0, 1
+	}
+	lo, hi = math.Inf(+1), math.Inf(-1)
+	for _, x := range [2]float64{bMin[0], bMax[0]} {
+		for _, y := range [2]float64{bMin[1], bMax[1]} {
+			gx := m[0]*x + m[1]*y + m[2]
+			gy := m[3]*x + m[4]*y + m[5]
+			o := gx
+			if grad.Shape == ivg.GradientShapeRadial {
+				// The distance from the focal point bounds the offset, as
+				// the focal point is inside the unit circle.
+				f := grad.Focal
+				o = math.Hypot(gx-float64(f[0]), gy-float64(f[1])) / (1 - math.Hypot(float64(f[0]), float64(f[1])))
+			}
+			lo, hi = math.Min(lo, o), math.Max(hi, o)
+		}
+	}
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return 0, 1
+	}
+	return lo, hi
+}
+
+// pathPoints returns the end and control points of p's segments.
+func pathPoints(p ivg.Path) []f32.Vec2 {
+	var ps []f32.Vec2
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			ps = append(ps, seg.To)
+		case ivg.LineTo:
+			ps = append(ps, seg.To)
+		case ivg.QuadTo:
+			ps = append(ps, seg.Ctrl, seg.To)
+		case ivg.CubeTo:
+			ps = append(ps, seg.Ctrl0, seg.Ctrl1, seg.To)
+		case ivg.ArcTo:
+			ps = append(ps, seg.To)
+		}
+	}
+	return ps
+}
+
+// beziers returns p's sub-paths as Lottie's closed cubic Bézier shapes, whose
+// in and out tangents are relative to their vertices.
+func beziers(p ivg.Path) []jsonBezier {
+	var bs []jsonBezier
+	var b *jsonBezier
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	// begin starts a shape at the pen, if there is no current shape.
+	begin := func() {
+		if b == nil {
+			bs = append(bs, jsonBezier{
+				In:     [][2]float32{{}},
+				Out:    [][2]float32{{}},
+				Vertex: [][2]float32{{pen[0], pen[1]}},
+				Closed: true,
+			})
+			b = &bs[len(bs)-1]
+		}
+	}
+	// curveTo adds a vertex v, with out and in control points c0 and c1.
+	curveTo := func(c0, c1, v f32.Vec2) {
+		begin()
+		n := len(b.Vertex) - 1
+		b.Out[n] = [2]float32{c0[0] - b.Vertex[n][0], c0[1] - b.Vertex[n][1]}
+		b.In = append(b.In, [2]float32{c1[0] - v[0], c1[1] - v[1]})
+		b.Out = append(b.Out, [2]float32{})
+		b.Vertex = append(b.Vertex, [2]float32{v[0], v[1]})
+		pen = v
+	}
+	closePath := func() {
+		// A closing vertex that repeats the first is merged into it.
+		if b != nil {
+			if n := len(b.Vertex) - 1; n > 0 && b.Vertex[n] == b.Vertex[0] {
+				b.In[0] = b.In[n]
+				b.In, b.Out, b.Vertex = b.In[:n], b.Out[:n], b.Vertex[:n]
+			}
+		}
+		b = nil
+	}
+
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			closePath()
+			pen, start = seg.To, seg.To
+		case ivg.LineTo:
+			curveTo(pen, seg.To, seg.To)
+		case ivg.QuadTo:
+			// Elevate the quadratic curve to a cubic one.
+			curveTo(
+				f32.Vec2{pen[0] + (seg.Ctrl[0]-pen[0])*2/3, pen[1] + (seg.Ctrl[1]-pen[1])*2/3},
+				f32.Vec2{seg.To[0] + (seg.Ctrl[0]-seg.To[0])*2/3, seg.To[1] + (seg.Ctrl[1]-seg.To[1])*2/3},
+				seg.To)
+		case ivg.CubeTo:
+			curveTo(seg.Ctrl0, seg.Ctrl1, seg.To)
+		case ivg.ArcTo:
+			for _, c := range seg.Cubics(pen, 0) {
+				switch c := c.(type) {
+				case ivg.LineTo:
+					curveTo(pen, c.To, c.To)
+				case ivg.CubeTo:
+					curveTo(c.Ctrl0, c.Ctrl1, c.To)
+				}
+			}
+			pen = seg.To
+		case ivg.ClosePath:
+			closePath()
+			pen = start
+		}
+	}
+	closePath()
+	return bs
+}
+
+// nonPremul returns an alpha-premultiplied color as Lottie's non-premultiplied
+// RGBA color, whose alpha is always 1, and its separate opacity, in the range
+// [0, 1]. Invalid alpha-premultiplied colors become opaque black.
+func nonPremul(rgba color.RGBA) (c [4]float64, opacity float64) {
+	if (rgba.R > rgba.A) || (rgba.G > rgba.A) || (rgba.B > rgba.A) {
+		return [4]float64{0, 0, 0, 1}, 1
+	}
+	n := color.NRGBAModel.Convert(rgba).(color.NRGBA)
+	return [4]float64{
+		round3(float64(n.R) / 0xff),
+		round3(float64(n.G) / 0xff),
+		round3(float64(n.B) / 0xff),
+		1,
+	}, round3(float64(n.A) / 0xff)
+}
+
+// round3 rounds f to 3 decimal places, which distinguishes all 8-bit color
+// values, to keep the JSON short.
+func round3(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// roundSize rounds a composition dimension, which is at least 1.
+func roundSize(f float64) int {
+	if !(f >= 1) {
+		return 1
+	} else if f > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(math.Round(f))
+}
@@ -0,0 +1,363 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2lottie_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2lottie"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// lottie holds the parts of the Lottie JSON that the tests check.
+type lottie struct {
+	Version   string  `json:"v"`
+	FrameRate float64 `json:"fr"`
+	InPoint   float64 `json:"ip"`
+	OutPoint  float64 `json:"op"`
+	Width     int     `json:"w"`
+	Height    int     `json:"h"`
+	Name      string  `json:"nm"`
+	Layers    []struct {
+		Index    int               `json:"ind"`
+		Type     int               `json:"ty"`
+		Name     string            `json:"nm"`
+		InPoint  float64           `json:"ip"`
+		OutPoint float64           `json:"op"`
+		Shapes   []json.RawMessage `json:"shapes"`
+	} `json:"layers"`
+}
+
+func convert(t *testing.T, src []byte, opts *ivg2lottie.Options) (*lottie, []byte) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := ivg2lottie.Convert(buf, src, opts); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	l := &lottie{}
+	if err := json.Unmarshal(buf.Bytes(), l); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	return l, buf.Bytes()
+}
+
+func TestConvertStill(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := convert(t, src, nil)
+	if l.Version != "5.7.4" {
+		t.Errorf("v: got %q, want %q", l.Version, "5.7.4")
+	}
+	if (l.FrameRate != 1) || (l.InPoint != 0) || (l.OutPoint != 1) {
+		t.Errorf("fr, ip, op: got %g, %g, %g, want 1, 0, 1", l.FrameRate, l.InPoint, l.OutPoint)
+	}
+	dx, dy := g.Metadata.ViewBox.AspectRatio()
+	if (l.Width != int(dx)) || (l.Height != int(dy)) {
+		t.Errorf("w, h: got %d, %d, want %g, %g", l.Width, l.Height, dx, dy)
+	}
+	if len(l.Layers) != 1 {
+		t.Fatalf("got %d layers, want 1", len(l.Layers))
+	}
+	layer := l.Layers[0]
+	if (layer.Index != 1) || (layer.Type != 4) || (layer.InPoint != 0) || (layer.OutPoint != 1) {
+		t.Errorf("layer: got ind %d, ty %d, ip %g, op %g, want 1, 4, 0, 1",
+			layer.Index, layer.Type, layer.InPoint, layer.OutPoint)
+	}
+	if len(layer.Shapes) != len(g.Shapes) {
+		t.Errorf("got %d shapes, want %d", len(layer.Shapes), len(g.Shapes))
+	}
+}
+
+func TestConvertAnimation(t *testing.T) {
+	black := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	frame := func(title string) []byte {
+		g := ivg.NewBuilder().MoveTo(0, 0).LineTo(8, 0).LineTo(8, 8).ClosePath().Fill(black).Graphic()
+		g.Metadata.Title = title
+		src, err := ivg.Encode(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return src
+	}
+	src, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		LoopCount: 3,
+		Frames: []lowlevel.Frame{
+			{Duration: 100 * time.Millisecond, Graphic: frame("First")},
+			{Duration: 250 * time.Millisecond, Graphic: frame("")},
+			{Duration: 0, Graphic: frame("Skipped")},
+			{Duration: 50 * time.Millisecond, Graphic: frame("")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The frame rate's period is 50ms, the greatest common divisor of the
+	// non-zero durations.
+	l, _ := convert(t, src, nil)
+	if (l.FrameRate != 20) || (l.OutPoint != 8) {
+		t.Errorf("fr, op: got %g, %g, want 20, 8", l.FrameRate, l.OutPoint)
+	}
+	if l.Name != "First" {
+		t.Errorf("nm: got %q, want %q", l.Name, "First")
+	}
+	want := []struct {
+		name   string
+		ip, op float64
+	}{
+		{"First", 0, 2},
+		{"frame 1", 2, 7},
+		{"frame 2", 7, 8},
+	}
+	if len(l.Layers) != len(want) {
+		t.Fatalf("got %d layers, want %d", len(l.Layers), len(want))
+	}
+	for i, w := range want {
+		got := l.Layers[i]
+		if (got.Index != i+1) || (got.Name != w.name) || (got.InPoint != w.ip) || (got.OutPoint != w.op) {
+			t.Errorf("layer %d: got ind %d, nm %q, ip %g, op %g, want %d, %q, %g, %g",
+				i, got.Index, got.Name, got.InPoint, got.OutPoint, i+1, w.name, w.ip, w.op)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	cowbell, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noFrames, err := lowlevel.EncodeAnimation(&lowlevel.Animation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroDuration, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		Frames: []lowlevel.Frame{{Duration: 0, Graphic: cowbell}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	badFrame, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		Frames: []lowlevel.Frame{{Duration: time.Second, Graphic: cowbell[:len(cowbell)/2]}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"truncated graphic", cowbell[:len(cowbell)/2]},
+		{"no frames", noFrames},
+		{"zero duration", zeroDuration},
+		{"bad frame", badFrame},
+	}
+	for _, tc := range testCases {
+		if err := ivg2lottie.Convert(&bytes.Buffer{}, tc.src, nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	src, err := ivg.NewBuilder().SetViewBox(0, 0, 48, 24).
+		MoveTo(0, 0).LineTo(8, 0).LineTo(8, 8).ClosePath().
+		Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		opts        *ivg2lottie.Options
+		wantW       int
+		wantH       int
+		wantPrefix  string
+		wantIndents bool
+	}{
+		{nil, 48, 24, `{"v":"5.7.4",`, false},
+		{&ivg2lottie.Options{Height: 100}, 200, 100, `{"v":"5.7.4",`, false},
+		{&ivg2lottie.Options{Width: 100}, 100, 50, `{"v":"5.7.4",`, false},
+		{&ivg2lottie.Options{Width: 10, Height: 20}, 10, 20, `{"v":"5.7.4",`, false},
+		{&ivg2lottie.Options{Indent: "  "}, 48, 24, "{\n  \"v\": \"5.7.4\",\n", true},
+	}
+	for _, tc := range testCases {
+		l, out := convert(t, src, tc.opts)
+		if (l.Width != tc.wantW) || (l.Height != tc.wantH) {
+			t.Errorf("%+v: got %dx%d, want %dx%d", tc.opts, l.Width, l.Height, tc.wantW, tc.wantH)
+		}
+		if !bytes.HasPrefix(out, []byte(tc.wantPrefix)) {
+			t.Errorf("%+v: got prefix %.40q, want %q", tc.opts, out, tc.wantPrefix)
+		}
+	}
+}
+
+func TestShapes(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: red},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})},
+	}
+	triangle := func() *ivg.Builder {
+		return ivg.NewBuilder().MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath()
+	}
+	testCases := []struct {
+		desc       string
+		g          *ivg.Graphic
+		wantShapes int
+		want       []string
+		dontWant   []string
+	}{{
+		"RGBA color",
+		triangle().Fill(red).Graphic(),
+		1,
+		[]string{
+			`"ks":{"a":0,"k":{"i":[[0,0],[0,0],[0,0]],"o":[[0,0],[0,0],[0,0]],"v":[[-20,-20],[20,-20],[20,20]],"c":true}}`,
+			`{"ty":"fl","c":{"a":0,"k":[1,0,0,1]},"o":{"a":0,"k":100},"r":1}`,
+			`"ks":{"a":{"a":0,"k":[0,0]},"p":{"a":0,"k":[32,32]},"s":{"a":0,"k":[100,100]},"r":{"a":0,"k":0},"o":{"a":0,"k":100}}`,
+		},
+		[]string{`"ty":"gf"`, `iconvg-palette`},
+	}, {
+		"translucent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80})).Graphic(),
+		1,
+		[]string{`"c":{"a":0,"k":[0.498,0,0,1]},"o":{"a":0,"k":50.2}`},
+		nil,
+	}, {
+		"transparent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{})).Graphic(),
+		0,
+		nil,
+		nil,
+	}, {
+		"palette color",
+		triangle().Fill(lowlevel.PaletteIndexColor(2)).Graphic(),
+		1,
+		[]string{`{"ty":"fl","nm":"iconvg-palette-2",`},
+		nil,
+	}, {
+		"levels of detail",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			b.SetLOD(0, 30).MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().Fill(red)
+			b.SetLOD(30, ivg.DefaultLOD1).MoveTo(0, 0).LineTo(2, 0).LineTo(2, 2).ClosePath().Fill(red)
+			return b.Graphic()
+		}(),
+		1,
+		[]string{`"v":[[0,0],[2,0],[2,2]]`},
+		nil,
+	}, {
+		"drawing order",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			b.MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().Fill(red)
+			b.MoveTo(0, 0).LineTo(2, 0).LineTo(2, 2).ClosePath().Fill(red)
+			return b.Graphic()
+		}(),
+		2,
+		[]string{`"v":[[0,0],[2,0],[2,2]],"c":true}}},{"ty":"fl"`},
+		nil,
+	}, {
+		"curves",
+		ivg.NewBuilder().MoveTo(0, 0).QuadTo(3, 0, 3, 3).CubeTo(3, 6, 0, 6, 0, 3).ClosePath().Fill(red).Graphic(),
+		1,
+		[]string{`"i":[[0,0],[0,-2],[0,3]],"o":[[2,0],[0,3],[0,0]],"v":[[0,0],[3,3],[0,3]],"c":true`},
+		nil,
+	}, {
+		"linear gradient",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadPad)).Graphic(),
+		1,
+		[]string{
+			`{"ty":"gf","o":{"a":0,"k":100},"r":1,"t":1,"s":{"a":0,"k":[-19.99999`,
+			`"g":{"p":2,"k":{"a":0,"k":[0,1,0,0,1,0,0,1]}}}`,
+		},
+		nil,
+	}, {
+		"linear gradient, repeat",
+		triangle().FillPaint(ivg.LinearGradient(stops, -5, 0, 5, 0, ivg.GradientSpreadRepeat)).Graphic(),
+		1,
+		[]string{`"s":{"a":0,"k":[-24.99999`, `"g":{"p":10,"k":{"a":0,"k":[0,1,0,0,0.2,0,0,1,0.2,1,0,0,0.4,0,0,1,`},
+		nil,
+	}, {
+		"linear gradient, none",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).Graphic(),
+		1,
+		[]string{`"g":{"p":6,"k":{"a":0,"k":[0,0,0,0,0,1,0,0,0,1,0,0,1,0,0,1,1,0,0,1,1,0,0,0,0,0,0,1,0,1,1,1,1,1,1,0]}}`},
+		nil,
+	}, {
+		"radial gradient, reflect",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 10, ivg.GradientSpreadReflect)).Graphic(),
+		1,
+		[]string{`"t":2,"s":{"a":0,"k":[0,0]},"e":{"a":0,"k":[29.99999`, `"h":{"a":0,"k":0},"a":{"a":0,"k":0},"g":{"p":6,`},
+		nil,
+	}, {
+		"elliptical radial gradient",
+		func() *ivg.Graphic {
+			p := ivg.RadialGradient(stops, 0, 0, 1, ivg.GradientSpreadPad)
+			p.Gradient.Transform[0] = 1.0 / 20
+			p.Gradient.Transform[4] = 1.0 / 10
+			return triangle().FillPaint(p).Graphic()
+		}(),
+		1,
+		[]string{
+			`"v":[[-1,-2],[1,-2],[1,2]]`,
+			`"s":{"a":0,"k":[0,0]},"e":{"a":0,"k":[1,0]}`,
+			`{"ty":"tr","a":{"a":0,"k":[0,0]},"p":{"a":0,"k":[0,0]},"s":{"a":0,"k":[1999.99`,
+		},
+		nil,
+	}, {
+		"degenerate gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 0, ivg.GradientSpreadPad)).Graphic(),
+		1,
+		[]string{`{"ty":"fl","c":{"a":0,"k":[0,0,1,1]}`},
+		[]string{`"ty":"gf"`},
+	}}
+	for _, tc := range testCases {
+		src, err := ivg.Encode(tc.g)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc.desc, err)
+		}
+		l, out := convert(t, src, nil)
+		if len(l.Layers) != 1 {
+			t.Errorf("%s: got %d layers, want 1", tc.desc, len(l.Layers))
+			continue
+		}
+		if n := len(l.Layers[0].Shapes); n != tc.wantShapes {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, n, tc.wantShapes)
+		}
+		got := string(out)
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output does not contain %s:\n%s", tc.desc, want, got)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(got, dontWant) {
+				t.Errorf("%s: output contains %s:\n%s", tc.desc, dontWant, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/iconvg/src/go/internal/pspaint"
+)
+
+// firstMaskObject is the object number of the first soft mask form XObject.
+// Objects 1 to 5 are the catalog, the page tree, the page, its resources and
+// its content stream.
+const firstMaskObject = 6
+
+// document writes the objects of a PDF document, recording their byte offsets
+// for the cross-reference table.
+type document struct {
+	w       *bufio.Writer
+	n       int
+	err     error
+	offsets []int
+}
+
+func (d *document) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(d.w, format, args...)
+	d.n += n
+	d.err = err
+}
+
+func (d *document) object(body string) {
+	d.offsets = append(d.offsets, d.n)
+	d.printf("%d 0 obj\n%s\nendobj\n", len(d.offsets), body)
+}
+
+func (d *document) stream(dict string, data []byte) {
+	d.offsets = append(d.offsets, d.n)
+	d.printf("%d 0 obj\n<< %s/Length %d >>\nstream\n%s\nendstream\nendobj\n", len(d.offsets), dict, len(data), data)
+}
+
+func (c *canvas) writeDocument(w io.Writer) error {
+	content := &bytes.Buffer{}
+	zw := zlib.NewWriter(content)
+	zw.Write(c.content.Bytes())
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	d := &document{w: bufio.NewWriter(w)}
+	d.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	d.object("<< /Type /Catalog /Pages 2 0 R >>")
+	d.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	d.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources 4 0 R /Contents 5 0 R >>",
+		pspaint.Ftoa(c.pageWidth), pspaint.Ftoa(c.pageHeight)))
+	d.object(c.resources())
+	d.stream("/Filter /FlateDecode ", content.Bytes())
+	for _, m := range c.masks {
+		d.stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [%s %s %s %s] "+
+			"/Group << /S /Transparency /CS /DeviceGray >> /Resources << /Shading << /Sh0 %s >> >> ",
+			pspaint.Ftoa(m.bbox[0]), pspaint.Ftoa(m.bbox[1]), pspaint.Ftoa(m.bbox[2]), pspaint.Ftoa(m.bbox[3]), m.shading),
+			[]byte("/Sh0 sh"))
+	}
+
+	xref := d.n
+	d.printf("xref\n0 %d\n0000000000 65535 f \n", len(d.offsets)+1)
+	for _, o := range d.offsets {
+		d.printf("%010d 00000 n \n", o)
+	}
+	d.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.offsets)+1, xref)
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+// resources returns the page's resource dictionary.
+func (c *canvas) resources() string {
+	b := &strings.Builder{}
+	b.WriteString("<<")
+	if len(c.extGStates) > 0 {
+		b.WriteString(" /ExtGState <<")
+		for i, s := range c.extGStates {
+			fmt.Fprintf(b, " /GS%d %s", i, s)
+		}
+		b.WriteString(" >>")
+	}
+	if len(c.shadings) > 0 {
+		b.WriteString(" /Shading <<")
+		for i, s := range c.shadings {
+			fmt.Fprintf(b, " /Sh%d %s", i, s)
+		}
+		b.WriteString(" >>")
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2pdf
+
+import (
+	"fmt"
+
+	"github.com/google/iconvg/src/go/internal/pspaint"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// mask is a soft mask form XObject. Its content paints its shading, whose
+// gray levels are a gradient's alpha values, over its bounding box.
+type mask struct {
+	bbox    [4]float64
+	shading string
+}
+
+// FillGradient fills path with an axial or radial shading, clipped to the
+// path, with a soft mask for semi-transparent stops.
+func (c *canvas) FillGradient(path []byte, rule lowlevel.FillRule, g *pspaint.Gradient) {
+	c.printf("q\n")
+	c.content.Write(path)
+	if rule == lowlevel.FillRuleEvenOdd {
+		c.printf("W* ")
+	} else {
+		c.printf("W ")
+	}
+	c.printf("n\n%s cm\n", pspaint.Matrix(&g.Inverse))
+	if !g.Opaque() {
+		alphaFunc := g.Function(func(i int) string { return pspaint.FtoaUnit(g.Stops[i].Color.A) })
+		c.masks = append(c.masks, mask{
+			bbox:    g.BBox,
+			shading: g.Shading("/DeviceGray", alphaFunc),
+		})
+		c.printf("/GS%d gs\n", len(c.extGStates))
+		c.extGStates = append(c.extGStates, fmt.Sprintf(
+			"<< /SMask << /Type /Mask /S /Luminosity /G %d 0 R >> >>", firstMaskObject+len(c.masks)-1))
+	}
+	c.printf("/Sh%d sh\nQ\n", len(c.shadings))
+	colorFunc := g.Function(func(i int) string { return stopRGB(g.Stops, i) })
+	c.shadings = append(c.shadings, g.Shading("/DeviceRGB", colorFunc))
+}
+
+// stopRGB formats the i'th stop's non-premultiplied color. A transparent
+// stop's color does not contribute to premultiplied interpolation, so it
+// takes that of the nearest following (or preceding) stop that is not
+// transparent, which makes fading to transparent look the same in
+// non-premultiplied interpolation.
+func stopRGB(stops []pspaint.Stop, i int) string {
+	j := i
+	for ; (j < len(stops)) && (stops[j].Color.A == 0x00); j++ {
+	}
+	if j == len(stops) {
+		for j = i; (j >= 0) && (stops[j].Color.A == 0x00); j-- {
+		}
+	}
+	if j < 0 {
+		return "0 0 0"
+	}
+	c := nonPremul(stops[j].Color)
+	return pspaint.FtoaUnit(c.R) + " " + pspaint.FtoaUnit(c.G) + " " + pspaint.FtoaUnit(c.B)
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2pdf converts IconVG graphics to PDF.
+//
+// The result is a single page PDF document whose content stream holds the
+// graphic as vector paths, so that documentation and print pipelines can
+// embed it at any resolution. Like package ivg2eps, the conversion paints the
+// graphic with package pspaint, executing the byte code's virtual machine
+// directly, without an intermediate raster or document model.
+//
+// Flat colors become fill colors, with a constant alpha graphics state for
+// semi-transparent colors. Gradients become axial or radial shadings, clipped
+// to the path, with a soft mask for semi-transparent stops. PDF shadings
+// have no equivalent to IconVG's "reflect" and "repeat" spreads, so those are
+// unrolled, period by period, across the path's bounds. Like ivg2svg, PDF
+// interpolates gradient stops in non-premultiplied color, whereas IconVG uses
+// premultiplied color. These only differ for gradients with semi-transparent
+// stops.
+package ivg2pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/google/iconvg/src/go/internal/pspaint"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+var errInvalidPageSize = errors.New("ivg2pdf: invalid page size")
+
+// Options are the optional parameters to the Convert function.
+type Options struct {
+	// Width and Height are the page size, in points (1/72 of an inch). The
+	// graphic's viewBox is scaled uniformly to fit the page, and centered.
+	//
+	// If both are zero, the page is the size of the viewBox, at one point per
+	// viewBox unit. If only one is zero, it is calculated from the other and
+	// the viewBox's aspect ratio. Height also selects which level of detail
+	// is converted.
+	Width  float64
+	Height float64
+
+	// FillRule is the rule that paths are filled with, as for
+	// lowlevel.DecodeOptions.FillRule. It is the nonzero rule by default.
+	FillRule lowlevel.FillRule
+}
+
+// Convert converts the IconVG graphic src to a PDF document, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	c := &canvas{opts: opts}
+	p := pspaint.NewPainter(c)
+	if err := lowlevel.Decode(p, src, &lowlevel.DecodeOptions{FillRule: opts.FillRule}); err != nil {
+		return err
+	} else if err := p.Err(); err != nil {
+		return err
+	}
+	return c.writeDocument(w)
+}
+
+// canvas is a pspaint.Canvas that paints onto a PDF content stream. Its user
+// space is the graphic's coordinate space: the content stream starts by
+// transforming from that to the page's coordinate space.
+type canvas struct {
+	opts *Options
+
+	pageWidth  float64
+	pageHeight float64
+	metadata   lowlevel.Metadata
+
+	// content is the page's content stream.
+	content bytes.Buffer
+
+	// resources are the page's named resources. masks are the soft mask
+	// form XObjects referred to by the extGStates.
+	extGStates []string
+	shadings   []string
+	masks      []mask
+	alphas     map[uint8]int
+}
+
+var _ pspaint.Canvas = (*canvas)(nil)
+
+func (c *canvas) Begin(m lowlevel.Metadata) (lodHeight float32, err error) {
+	c.metadata = m
+	page, ok := pspaint.FitPage(m.ViewBox, c.opts.Width, c.opts.Height)
+	if !ok {
+		return 0, errInvalidPageSize
+	}
+	c.pageWidth, c.pageHeight = page.Width, page.Height
+
+	// The page's transform flips the y axis, as PDF's origin is the bottom
+	// left.
+	t, vb := &page.Transform, &m.ViewBox
+	c.printf("%s 0 0 %s %s %s cm\n", pspaint.Ftoa(t[0]), pspaint.Ftoa(t[4]), pspaint.Ftoa(t[2]), pspaint.Ftoa(t[5]))
+	c.printf("%s %s %s %s re W n\n", pspaint.Ftoa32(vb.Min[0]), pspaint.Ftoa32(vb.Min[1]),
+		pspaint.Ftoa(float64(vb.Max[0]-vb.Min[0])), pspaint.Ftoa(float64(vb.Max[1]-vb.Min[1])))
+	return page.LODHeight, nil
+}
+
+func (c *canvas) FillColor(path []byte, rule lowlevel.FillRule, rgba color.RGBA) {
+	if rgba.A != 0xff {
+		c.printf("q /GS%d gs\n", c.alphaGState(rgba.A))
+	}
+	nrgba := nonPremul(rgba)
+	c.printf("%s %s %s rg\n", pspaint.FtoaUnit(nrgba.R), pspaint.FtoaUnit(nrgba.G), pspaint.FtoaUnit(nrgba.B))
+	c.content.Write(path)
+	if rule == lowlevel.FillRuleEvenOdd {
+		c.printf("f*\n")
+	} else {
+		c.printf("f\n")
+	}
+	if rgba.A != 0xff {
+		c.printf("Q\n")
+	}
+}
+
+// alphaGState returns the index of an ExtGState resource that sets the
+// constant alpha for fills.
+func (c *canvas) alphaGState(a uint8) int {
+	if i, ok := c.alphas[a]; ok {
+		return i
+	}
+	if c.alphas == nil {
+		c.alphas = map[uint8]int{}
+	}
+	i := len(c.extGStates)
+	c.alphas[a] = i
+	c.extGStates = append(c.extGStates, "<< /ca "+pspaint.FtoaUnit(a)+" >>")
+	return i
+}
+
+func (c *canvas) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&c.content, format, args...)
+}
+
+// nonPremul converts from alpha-premultiplied to non-premultiplied color.
+// Invalid alpha-premultiplied colors, such as gradients, become opaque black.
+func nonPremul(c color.RGBA) color.NRGBA {
+	if c.A == 0x00 {
+		return color.NRGBA{}
+	} else if (c.R > c.A) || (c.G > c.A) || (c.B > c.A) {
+		return color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	} else if c.A == 0xff {
+		return color.NRGBA{c.R, c.G, c.B, c.A}
+	}
+	a := uint32(c.A)
+	return color.NRGBA{
+		R: uint8((uint32(c.R)*0xff + a/2) / a),
+		G: uint8((uint32(c.G)*0xff + a/2) / a),
+		B: uint8((uint32(c.B)*0xff + a/2) / a),
+		A: c.A,
+	}
+}
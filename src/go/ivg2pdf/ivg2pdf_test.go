@@ -0,0 +1,154 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg2pdf"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestFillRule(t *testing.T) {
+	testCases := []struct {
+		filename string
+		rule     lowlevel.FillRule
+		want     string
+		dontWant string
+	}{
+		{"action-info.lores.ivg", lowlevel.FillRuleNonZero, "\nf\n", "f*"},
+		{"action-info.lores.ivg", lowlevel.FillRuleEvenOdd, "\nf*\n", "\nf\n"},
+		{"gradient.ivg", lowlevel.FillRuleNonZero, "\nW n\n", "W*"},
+		{"gradient.ivg", lowlevel.FillRuleEvenOdd, "\nW* n\n", "\nW n\n"},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2pdf.Convert(buf, src, &ivg2pdf.Options{FillRule: tc.rule}); err != nil {
+			t.Errorf("%s, rule %d: %v", tc.filename, tc.rule, err)
+			continue
+		}
+		content, err := contentStream(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s, rule %d: %v", tc.filename, tc.rule, err)
+			continue
+		}
+		if !bytes.Contains(content, []byte(tc.want)) {
+			t.Errorf("%s, rule %d: content stream does not contain %q:\n%s", tc.filename, tc.rule, tc.want, content)
+		}
+		if bytes.Contains(content, []byte(tc.dontWant)) {
+			t.Errorf("%s, rule %d: content stream contains %q:\n%s", tc.filename, tc.rule, tc.dontWant, content)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		filename     string
+		opts         *ivg2pdf.Options
+		wantMediaBox string
+		wantContent  string
+	}{
+		{"action-info.lores.ivg", nil, "/MediaBox [0 0 48 48]", "\nf\n"},
+		{"action-info.lores.ivg", &ivg2pdf.Options{Width: 96}, "/MediaBox [0 0 96 96]", "\nf\n"},
+		{"action-info.lores.ivg", &ivg2pdf.Options{Height: 24}, "/MediaBox [0 0 24 24]", "\nf\n"},
+		{"arcs.ivg", nil, "/MediaBox [0 0 64 64]", " c\n"},
+		{"gradient.ivg", nil, "/MediaBox [0 0 64 64]", " sh\n"},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2pdf.Convert(buf, src, tc.opts); err != nil {
+			t.Errorf("%s, %+v: %v", tc.filename, tc.opts, err)
+			continue
+		}
+		pdf := buf.Bytes()
+		if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+			t.Errorf("%s, %+v: not a PDF document", tc.filename, tc.opts)
+		}
+		if !bytes.Contains(pdf, []byte(tc.wantMediaBox)) {
+			t.Errorf("%s, %+v: document does not contain %q", tc.filename, tc.opts, tc.wantMediaBox)
+		}
+		content, err := contentStream(pdf)
+		if err != nil {
+			t.Errorf("%s, %+v: %v", tc.filename, tc.opts, err)
+			continue
+		}
+		if !bytes.Contains(content, []byte(tc.wantContent)) {
+			t.Errorf("%s, %+v: content stream does not contain %q:\n%s", tc.filename, tc.opts, tc.wantContent, content)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc string
+		src  []byte
+		opts *ivg2pdf.Options
+	}{
+		{"negative width", src, &ivg2pdf.Options{Width: -1}},
+		{"negative height", src, &ivg2pdf.Options{Width: 10, Height: -1}},
+		{"truncated", src[:len(src)-2], nil},
+		{"not IconVG", []byte("%PDF-1.4"), nil},
+	}
+	for _, tc := range testCases {
+		if err := ivg2pdf.Convert(io.Discard, tc.src, tc.opts); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+// contentStream returns the decompressed page content stream of a PDF
+// document written by Convert, which is its only FlateDecode stream.
+func contentStream(pdf []byte) ([]byte, error) {
+	const prefix = "/Filter /FlateDecode /Length "
+	i := bytes.Index(pdf, []byte(prefix))
+	if i < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	pdf = pdf[i+len(prefix):]
+	j := bytes.Index(pdf, []byte(" >>\nstream\n"))
+	if j < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	n, err := strconv.Atoi(string(pdf[:j]))
+	if err != nil {
+		return nil, err
+	}
+	pdf = pdf[j+len(" >>\nstream\n"):]
+	if n > len(pdf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	r, err := zlib.NewReader(bytes.NewReader(pdf[:n]))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,363 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2svg converts IconVG graphics to SVG.
+//
+// The conversion preserves the viewBox, flat colors (converted from IconVG's
+// alpha-premultiplied color to SVG's non-premultiplied color and opacity) and
+// linear and radial gradients. The suggested palette is exported as CSS custom
+// properties (variables) named --iconvg-palette-0, --iconvg-palette-1, etc.,
+// on the root svg element. The title and description metadata become the
+// root's title and desc elements. Shapes filled with the even-odd rule have
+// SVG's fill-rule="evenodd" attribute.
+//
+// Fills and gradient stops whose colors refer to the palette, possibly blended
+// with transparent black, refer to those custom properties, in their style
+// attribute, so that the SVG can be re-colored by overriding them. Their
+// presentation attributes hold the resolved colors, for SVG renderers that do
+// not support custom properties. Package svgconv maps the custom properties
+// back onto palette indices.
+//
+// All four of IconVG's gradient spreads are converted. SVG has no "none"
+// spread, so it is emulated by SVG's "pad" spread with transparent stops at
+// either end. Some IconVG features have no exact SVG equivalent: SVG
+// interpolates gradient stops in non-premultiplied color, whereas IconVG uses
+// premultiplied color. These only differ for gradients with semi-transparent
+// stops.
+package ivg2svg
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Options are the optional parameters to the Convert and Write functions.
+type Options struct {
+	// Width and Height, if positive, are the svg element's width and height
+	// attributes, in pixels. Height also selects which level of detail is
+	// converted, as SVG has no equivalent concept. If Height is zero, the
+	// viewBox height is used instead.
+	Width  int
+	Height int
+}
+
+// Convert converts the IconVG graphic src to SVG, writing it to w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		return err
+	}
+	return Write(w, g, opts)
+}
+
+// Write writes g as SVG to w.
+//
+// opts may be nil, which means to use the default options.
+func Write(w io.Writer, g *ivg.Graphic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	bw := bufio.NewWriter(w)
+	c := &converter{w: bw, g: g, opts: opts}
+	c.convert()
+	return bw.Flush()
+}
+
+type converter struct {
+	w    *bufio.Writer
+	g    *ivg.Graphic
+	opts *Options
+}
+
+func (c *converter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, format, args...)
+}
+
+func (c *converter) convert() {
+	vb := &c.g.Metadata.ViewBox
+	dx, dy := vb.AspectRatio()
+	c.printf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s"`,
+		ftoa(vb.Min[0]), ftoa(vb.Min[1]), ftoa(dx), ftoa(dy))
+	if c.opts.Width > 0 {
+		c.printf(` width="%d"`, c.opts.Width)
+	}
+	if c.opts.Height > 0 {
+		c.printf(` height="%d"`, c.opts.Height)
+	}
+	c.writePaletteStyle()
+	c.printf(">\n")
+	c.writeText("title", c.g.Metadata.Title)
+	c.writeText("desc", c.g.Metadata.Description)
+
+	h := dy
+	if c.opts.Height > 0 {
+		h = float32(c.opts.Height)
+	}
+	nGradients := 0
+	for i := range c.g.Shapes {
+		s := &c.g.Shapes[i]
+		if !(s.LOD0 <= h && h < s.LOD1) || (len(s.Path) == 0) {
+			continue
+		}
+		fill := ""
+		if grad := s.Paint.Gradient; grad != nil {
+			id := fmt.Sprintf("iconvg-gradient-%d", nGradients)
+			nGradients++
+			c.writeGradient(id, grad)
+			fill = fmt.Sprintf(`fill="url(#%s)"`, id)
+		} else {
+			rgba := s.Paint.Color.Resolve(&c.g.Metadata.Palette, nil)
+			fill = fillAttributes(rgba)
+			if i, f, ok := s.Paint.Color.PaletteRef(); ok && rgba.A != 0 {
+				fill += varStyle("fill", "fill-opacity", i, f, rgba)
+			}
+		}
+		if s.FillRule == lowlevel.FillRuleEvenOdd {
+			fill += ` fill-rule="evenodd"`
+		}
+		c.printf(`<path %s d="`, fill)
+		c.writePathData(s.Path)
+		c.printf("\"/>\n")
+	}
+	c.printf("</svg>\n")
+}
+
+// writeText writes s as an element with the given name, if s is non-empty.
+func (c *converter) writeText(name string, s string) {
+	if s == "" {
+		return
+	}
+	c.printf("<%s>", name)
+	xml.EscapeText(c.w, []byte(s))
+	c.printf("</%s>\n", name)
+}
+
+// writePaletteStyle writes the suggested palette as CSS custom properties, if
+// it is not the default palette or if the graphic refers to it. Trailing
+// opaque black colors are omitted, unless they are referred to.
+func (c *converter) writePaletteStyle() {
+	p := &c.g.Metadata.Palette
+	n := 0
+	if *p != lowlevel.DefaultPalette {
+		n = len(p)
+		for ; n > 1; n-- {
+			if p[n-1] != (color.RGBA{0x00, 0x00, 0x00, 0xff}) {
+				break
+			}
+		}
+	}
+	ref := func(col lowlevel.Color) {
+		if i, _, ok := col.PaletteRef(); ok && n <= int(i) {
+			n = int(i) + 1
+		}
+	}
+	for _, s := range c.g.Shapes {
+		if g := s.Paint.Gradient; g != nil {
+			for _, stop := range g.Stops {
+				ref(stop.Color)
+			}
+		} else {
+			ref(s.Paint.Color)
+		}
+	}
+	if n == 0 {
+		return
+	}
+	c.printf(` style="`)
+	for i, rgba := range p[:n] {
+		if i > 0 {
+			c.printf(" ")
+		}
+		c.printf("--iconvg-palette-%d: %s;", i, cssColor(rgba))
+	}
+	c.printf(`"`)
+}
+
+func (c *converter) writeGradient(id string, g *ivg.Gradient) {
+	// The IconVG transform maps from graphic coordinate space to gradient
+	// coordinate space. SVG's gradientTransform maps the other way.
+	inv, ok := lowlevel.InvertGradientTransform(g.Transform, g.Shape == ivg.GradientShapeLinear)
+	if !ok {
+		inv = f64.Aff3{1, 0, 0, 0, 1, 0}
+	}
+	transform := fmt.Sprintf(`gradientTransform="matrix(%s %s %s %s %s %s)"`,
+		ftoa64(inv[0]), ftoa64(inv[3]), ftoa64(inv[1]), ftoa64(inv[4]), ftoa64(inv[2]), ftoa64(inv[5]))
+
+	spread := ""
+	switch g.Spread {
+	case ivg.GradientSpreadReflect:
+		spread = ` spreadMethod="reflect"`
+	case ivg.GradientSpreadRepeat:
+		spread = ` spreadMethod="repeat"`
+	}
+
+	elem := "linearGradient"
+	if g.Shape == ivg.GradientShapeRadial {
+		elem = "radialGradient"
+		// A focal point is in gradient coordinate space, like the circle.
+		focal := ""
+		if g.Focal != (f32.Vec2{}) {
+			focal = fmt.Sprintf(` fx="%s" fy="%s"`, ftoa(g.Focal[0]), ftoa(g.Focal[1]))
+		}
+		c.printf(`<defs><%s id="%s" gradientUnits="userSpaceOnUse" cx="0" cy="0" r="1"%s %s%s>`,
+			elem, id, focal, transform, spread)
+	} else {
+		c.printf(`<defs><%s id="%s" gradientUnits="userSpaceOnUse" x1="0" y1="0" x2="1" y2="0" %s%s>`,
+			elem, id, transform, spread)
+	}
+	c.printf("\n")
+	none := (g.Spread == ivg.GradientSpreadNone) && (len(g.Stops) > 0)
+	if none {
+		// SVG has no "none" spread. Emulate it with SVG's "pad" spread and
+		// transparent stops at offsets 0 and 1. Like IconVG, SVG gives the
+		// later of two stops at the same offset precedence, at that offset.
+		c.writeStop(0, lowlevel.RGBAColor(color.RGBA{}))
+		c.writeStop(0, g.Stops[0].Color)
+	}
+	for _, stop := range g.Stops {
+		c.writeStop(stop.Offset, stop.Color)
+	}
+	if none {
+		c.writeStop(1, g.Stops[len(g.Stops)-1].Color)
+		c.writeStop(1, lowlevel.RGBAColor(color.RGBA{}))
+	}
+	c.printf("</%s></defs>\n", elem)
+}
+
+func (c *converter) writeStop(offset float32, col lowlevel.Color) {
+	rgba := col.Resolve(&c.g.Metadata.Palette, nil)
+	nrgba := nonPremul(rgba)
+	c.printf(`<stop offset="%s" stop-color="#%02x%02x%02x"`, ftoa(offset), nrgba.R, nrgba.G, nrgba.B)
+	if nrgba.A != 0xff {
+		c.printf(` stop-opacity="%s"`, ftoa(float32(nrgba.A)/0xff))
+	}
+	if i, f, ok := col.PaletteRef(); ok && rgba.A != 0 {
+		c.printf("%s", varStyle("stop-color", "stop-opacity", i, f, rgba))
+	}
+	c.printf("/>\n")
+}
+
+func (c *converter) writePathData(p ivg.Path) {
+	for i, seg := range p {
+		if i > 0 {
+			c.printf(" ")
+		}
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			c.printf("M%s", points(seg.To))
+		case ivg.LineTo:
+			c.printf("L%s", points(seg.To))
+		case ivg.QuadTo:
+			c.printf("Q%s", points(seg.Ctrl, seg.To))
+		case ivg.CubeTo:
+			c.printf("C%s", points(seg.Ctrl0, seg.Ctrl1, seg.To))
+		case ivg.ArcTo:
+			largeArc, sweep := 0, 0
+			if seg.LargeArc {
+				largeArc = 1
+			}
+			if seg.Sweep {
+				sweep = 1
+			}
+			c.printf("A%s %s %s %d %d %s", ftoa(seg.Radii[0]), ftoa(seg.Radii[1]),
+				ftoa(seg.XAxisRotation*360), largeArc, sweep, points(seg.To))
+		case ivg.ClosePath:
+			c.printf("Z")
+		}
+	}
+}
+
+// fillAttributes returns the SVG fill attributes for an alpha-premultiplied
+// color.
+func fillAttributes(rgba color.RGBA) string {
+	if rgba.A == 0x00 {
+		return `fill="none"`
+	}
+	nrgba := nonPremul(rgba)
+	if nrgba.A == 0xff {
+		return fmt.Sprintf(`fill="#%02x%02x%02x"`, nrgba.R, nrgba.G, nrgba.B)
+	}
+	return fmt.Sprintf(`fill="#%02x%02x%02x" fill-opacity="%s"`,
+		nrgba.R, nrgba.G, nrgba.B, ftoa(float32(nrgba.A)/0xff))
+}
+
+// varStyle returns the style attribute for a color property, such as fill,
+// whose color refers to palette index i, applied with the given opacity via
+// the corresponding opacity property, such as fill-opacity. rgba is the
+// resolved color, which the presentation attributes hold. The style's opacity
+// overrides the presentation attribute's, as the palette color's own alpha is
+// part of the custom property's value.
+func varStyle(colorProp string, opacityProp string, i uint8, opacity float32, rgba color.RGBA) string {
+	s := fmt.Sprintf(` style="%s:var(--iconvg-palette-%d)`, colorProp, i)
+	if (opacity != 1) || (nonPremul(rgba).A != 0xff) {
+		s += fmt.Sprintf(";%s:%s", opacityProp, ftoa(opacity))
+	}
+	return s + `"`
+}
+
+// cssColor returns an alpha-premultiplied color as a CSS hex color.
+func cssColor(rgba color.RGBA) string {
+	nrgba := nonPremul(rgba)
+	if nrgba.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+}
+
+// nonPremul converts from alpha-premultiplied to non-premultiplied color.
+// Invalid alpha-premultiplied colors, such as gradients, become opaque black.
+func nonPremul(c color.RGBA) color.NRGBA {
+	if c.A == 0x00 {
+		return color.NRGBA{}
+	} else if (c.R > c.A) || (c.G > c.A) || (c.B > c.A) {
+		return color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	} else if c.A == 0xff {
+		return color.NRGBA{c.R, c.G, c.B, c.A}
+	}
+	a := uint32(c.A)
+	return color.NRGBA{
+		R: uint8((uint32(c.R)*0xff + a/2) / a),
+		G: uint8((uint32(c.G)*0xff + a/2) / a),
+		B: uint8((uint32(c.B)*0xff + a/2) / a),
+		A: c.A,
+	}
+}
+
+func points(ps ...f32.Vec2) string {
+	s := ""
+	for i, p := range ps {
+		if i > 0 {
+			s += " "
+		}
+		s += ftoa(p[0]) + " " + ftoa(p[1])
+	}
+	return s
+}
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+func ftoa64(f float64) string { return ftoa(float32(f)) }
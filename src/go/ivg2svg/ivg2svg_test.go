@@ -0,0 +1,301 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2svg_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2svg"
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"github.com/google/iconvg/src/go/svgconv"
+)
+
+// TestGradientSpreads checks that gradients, including their spreads, survive
+// a round trip through SVG: converting an IconVG graphic with this package and
+// converting the result back with package svgconv.
+func TestGradientSpreads(t *testing.T) {
+	for _, name := range []string{"gradient", "gradient-spreads"} {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g0, err := ivg.Decode(src, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		svg := &bytes.Buffer{}
+		if err := ivg2svg.Write(svg, g0, nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		g1, err := svgconv.Parse(svg.Bytes(), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		s0, s1 := spreads(g0), spreads(g1)
+		if len(s0) != len(s1) {
+			t.Fatalf("%s: got %d gradients, want %d", name, len(s1), len(s0))
+		}
+		for i := range s0 {
+			if s0[i] != s1[i] {
+				t.Errorf("%s: gradient #%d: got spread %d, want %d", name, i, s1[i], s0[i])
+			}
+		}
+
+		// The round trip converts gradient stops to non-premultiplied color
+		// and back, so allow a small difference in each channel.
+		dst1, err := ivg.Encode(g1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m0, err := render.Image(src, 64, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m1, err := render.Image(dst1, 64, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		const tolerance = 3
+		for i := range m0.Pix {
+			if d := int(m0.Pix[i]) - int(m1.Pix[i]); (d < -tolerance) || (tolerance < d) {
+				t.Errorf("%s: pixel (%d, %d): got %v, want %v", name, (i/4)%64, (i/4)/64,
+					m1.Pix[i&^3:i&^3+4], m0.Pix[i&^3:i&^3+4])
+				break
+			}
+		}
+	}
+}
+
+func spreads(g *ivg.Graphic) (s []ivg.GradientSpread) {
+	for _, shape := range g.Shapes {
+		if shape.Paint.Gradient != nil {
+			s = append(s, shape.Paint.Gradient.Spread)
+		}
+	}
+	return s
+}
+
+// TestPaletteRoundTrip checks that colors that refer to the suggested palette
+// still do so after a round trip through SVG, where they are CSS variables.
+func TestPaletteRoundTrip(t *testing.T) {
+	pal := lowlevel.DefaultPalette
+	pal[0] = color.RGBA{0x00, 0x80, 0x00, 0xff}
+	pal[2] = color.RGBA{0x40, 0x00, 0x00, 0x80}
+	want := []lowlevel.Color{
+		lowlevel.PaletteIndexColor(0),
+		lowlevel.PaletteIndexColor(2),
+		lowlevel.BlendColor(0x40, 0x80, 0x7f),
+		lowlevel.BlendColor(0x80, 0x82, 0x7f),
+		lowlevel.PaletteIndexColor(5),
+		lowlevel.RGBAColor(color.RGBA{0x11, 0x22, 0x33, 0xff}),
+	}
+	b := ivg.NewBuilder().SetPalette(&pal)
+	for _, c := range want {
+		b.MoveTo(-10, -10).LineTo(+10, -10).LineTo(0, +10).ClosePath().Fill(c)
+	}
+	b.MoveTo(-10, -10).LineTo(+10, -10).LineTo(0, +10).ClosePath().FillPaint(ivg.LinearGradient([]ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.PaletteIndexColor(2)},
+		{Offset: 1, Color: lowlevel.BlendColor(0x40, 0x80, 0x7f)},
+	}, -10, 0, +10, 0, ivg.GradientSpreadPad))
+	g0 := b.Graphic()
+
+	svg := &bytes.Buffer{}
+	if err := ivg2svg.Write(svg, g0, nil); err != nil {
+		t.Fatal(err)
+	}
+	g1, err := svgconv.Parse(svg.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g1.Shapes) != len(want)+1 {
+		t.Fatalf("got %d shapes, want %d", len(g1.Shapes), len(want)+1)
+	}
+	for i, c := range want {
+		if got := g1.Shapes[i].Paint.Color; got != c {
+			t.Errorf("shape #%d: got color %v, want %v", i, got, c)
+		}
+	}
+	grad := g1.Shapes[len(want)].Paint.Gradient
+	if grad == nil || len(grad.Stops) != 2 {
+		t.Fatalf("gradient: got %v, want 2 stops", grad)
+	}
+	for i, stop := range g0.Shapes[len(want)].Paint.Gradient.Stops {
+		if got := grad.Stops[i].Color; got != stop.Color {
+			t.Errorf("gradient stop #%d: got color %v, want %v", i, got, stop.Color)
+		}
+	}
+	for _, i := range []int{0, 2, 5} {
+		if got := g1.Metadata.Palette[i]; got != pal[i] {
+			t.Errorf("palette[%d]: got %v, want %v", i, got, pal[i])
+		}
+	}
+}
+
+// TestFillRule checks that a Shape's FillRule is written as a fill-rule
+// attribute, and survives a round trip through SVG.
+func TestFillRule(t *testing.T) {
+	testCases := []struct {
+		rule    lowlevel.FillRule
+		wantSVG bool
+	}{
+		{lowlevel.FillRuleNonZero, false},
+		{lowlevel.FillRuleEvenOdd, true},
+	}
+	for _, tc := range testCases {
+		g0 := ivg.NewBuilder().SetFillRule(tc.rule).
+			MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).LineTo(-20, +20).ClosePath().
+			MoveTo(-10, -10).LineTo(+10, -10).LineTo(+10, +10).LineTo(-10, +10).ClosePath().
+			Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})).
+			Graphic()
+		svg := &bytes.Buffer{}
+		if err := ivg2svg.Write(svg, g0, nil); err != nil {
+			t.Fatalf("rule %d: %v", tc.rule, err)
+		}
+		if got := bytes.Contains(svg.Bytes(), []byte(`fill-rule="evenodd"`)); got != tc.wantSVG {
+			t.Errorf("rule %d: got fill-rule=\"evenodd\" %t, want %t:\n%s", tc.rule, got, tc.wantSVG, svg.Bytes())
+		}
+		g1, err := svgconv.Parse(svg.Bytes(), nil)
+		if err != nil {
+			t.Fatalf("rule %d: %v", tc.rule, err)
+		}
+		if len(g1.Shapes) != 1 {
+			t.Fatalf("rule %d: got %d shapes, want 1", tc.rule, len(g1.Shapes))
+		}
+		if got := g1.Shapes[0].FillRule; got != tc.rule {
+			t.Errorf("rule %d: round trip: got rule %d, want %d", tc.rule, got, tc.rule)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		filename  string
+		opts      *ivg2svg.Options
+		wantAttrs string
+		wantPaths int
+		wantData  string
+	}{
+		{"blank.ivg", nil, `viewBox="-32 -32 64 64">`, 0, ""},
+		{"action-info.lores.ivg", nil, `viewBox="-24 -24 48 48">`, 1, "M0 -20 C"},
+		{"action-info.lores.ivg", &ivg2svg.Options{Width: 32, Height: 32},
+			`viewBox="-24 -24 48 48" width="32" height="32">`, 1, "M0 -20 C"},
+		// The viewBox height, 64, is below the pentagon's LOD0 of 80, so the
+		// triangle is drawn instead.
+		{"lod-polygon.ivg", nil, `viewBox="-32 -32 64 64">`, 3, "M28 0 L-14 24.25 L-14 -24.25 Z"},
+		{"lod-polygon.ivg", &ivg2svg.Options{Height: 100},
+			`viewBox="-32 -32 64 64" height="100">`, 3, "M28 0 L8.65625 26.625 L"},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2svg.Convert(buf, src, tc.opts); err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		got := buf.String()
+		if !strings.HasPrefix(got, `<svg xmlns="http://www.w3.org/2000/svg" `+tc.wantAttrs) {
+			t.Errorf("%s: got %q, want svg element attributes %q", tc.filename, got, tc.wantAttrs)
+		}
+		if n := strings.Count(got, "<path "); n != tc.wantPaths {
+			t.Errorf("%s: got %d paths, want %d", tc.filename, n, tc.wantPaths)
+		}
+		if !strings.Contains(got, tc.wantData) {
+			t.Errorf("%s: got %q, want path data containing %q", tc.filename, got, tc.wantData)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	testCases := []struct {
+		asm  string
+		want string
+	}{
+		{`text "" ""`, ""},
+		{`text "Info" ""`, "<title>Info</title>\n"},
+		{`text "" "D\xc3\xa9tails"`, "<desc>Détails</desc>\n"},
+		{`text "A\x20&\x20<B>" "x"`, "<title>A &amp; &lt;B&gt;</title>\n<desc>x</desc>\n"},
+	}
+	for _, tc := range testCases {
+		src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\n" + tc.asm + "\n"))
+		if err != nil {
+			t.Fatalf("%s: Assemble: %v", tc.asm, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2svg.Convert(buf, src, nil); err != nil {
+			t.Errorf("%s: %v", tc.asm, err)
+			continue
+		}
+		got := buf.String()
+		if i := strings.IndexByte(got, '\n'); i >= 0 {
+			got = got[i+1:]
+		}
+		if !strings.HasPrefix(got, tc.want) || strings.Contains(got[len(tc.want):], "<title>") ||
+			strings.Contains(got[len(tc.want):], "<desc>") {
+			t.Errorf("%s: got %q, want the svg element's text %q", tc.asm, got, tc.want)
+		}
+	}
+}
+
+func TestFocal(t *testing.T) {
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0xff, 0xff, 0xff})},
+	}
+	testCases := []struct {
+		fx, fy  float32
+		wantSVG string
+	}{
+		{10, -5, ` fx="0.5" fy="-0.25"`},
+		{0, 5, ` fx="0" fy="0.25"`},
+		{0, 0, ``},
+	}
+	for _, tc := range testCases {
+		p := ivg.FocalRadialGradient(stops, 0, 0, 20, tc.fx, tc.fy, ivg.GradientSpreadPad)
+		g0 := ivg.NewBuilder().
+			MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).LineTo(-20, +20).ClosePath().
+			FillPaint(p).
+			Graphic()
+		svg := &bytes.Buffer{}
+		if err := ivg2svg.Write(svg, g0, nil); err != nil {
+			t.Fatalf("%q: %v", tc.wantSVG, err)
+		}
+		if got := bytes.Contains(svg.Bytes(), []byte(" fx=")); got != (tc.wantSVG != "") {
+			t.Errorf("%q: got fx %t:\n%s", tc.wantSVG, got, svg.Bytes())
+		} else if !bytes.Contains(svg.Bytes(), []byte(tc.wantSVG)) {
+			t.Errorf("%q: got:\n%s", tc.wantSVG, svg.Bytes())
+		}
+		g1, err := svgconv.Parse(svg.Bytes(), nil)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.wantSVG, err)
+		}
+		if len(g1.Shapes) != 1 || g1.Shapes[0].Paint.Gradient == nil {
+			t.Fatalf("%q: got %d shapes, want 1 with a gradient", tc.wantSVG, len(g1.Shapes))
+		}
+		if got, want := g1.Shapes[0].Paint.Gradient.Focal, p.Gradient.Focal; got != want {
+			t.Errorf("%q: round trip: got %v, want %v", tc.wantSVG, got, want)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2vd converts between IconVG graphics and Android
+// VectorDrawable XML, so that one set of IconVG icons can be shared with
+// Android apps.
+//
+// Write converts to a VectorDrawable. Paths keep their arcs, as
+// VectorDrawable path data is SVG path data, and even-odd filled paths have
+// an evenOdd fillType. Flat colors become #AARRGGBB colors (or #RRGGBB if
+// opaque). The viewBox becomes the viewport, with a translating group if its
+// top-left corner is not at the origin. VectorDrawable has no levels of
+// detail, so only the level of detail for the height in dp is converted.
+//
+// Gradients become aapt:attr inline gradient resources, which require
+// Android Gradle Plugin 3.0 or later to build and API level 24 or later to
+// render. All four of IconVG's gradient spreads are converted, with the
+// "none" spread emulated by "clamp" and transparent stops at either end. A
+// radial gradient whose transform is not a similarity, which would need an
+// elliptical VectorDrawable gradient, is drawn in its own group, whose
+// transform maps a circular gradient onto the ellipse. VectorDrawable
+// gradients have no focal point, so a focal point is dropped. Like SVG,
+// Android interpolates gradient stops in non-premultiplied color, whereas
+// IconVG uses premultiplied color. These only differ for gradients with
+// semi-transparent stops.
+//
+// Parse converts from a VectorDrawable, via package svgconv, and so has the
+// same support for strokes and gradients. Color resource and theme attribute
+// references, such as "@color/accent" or "?attr/colorPrimary", become
+// themable palette entries, defaulting to opaque black. A reference to
+// "@color/iconvg_palette_3", as written by Write with the PaletteColors
+// option, becomes palette index 3. Clip paths, sweep gradients and trimmed
+// paths are not supported.
+package ivg2vd
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// Options are the optional parameters to the Convert and Write functions.
+type Options struct {
+	// Width and Height are the vector element's width and height, in dp.
+	// Height also selects which level of detail is converted. If both are
+	// zero, Height is 24. If only one is zero, it follows the other and the
+	// viewBox's aspect ratio.
+	Width  int
+	Height int
+
+	// PaletteColors is whether colors that refer to the palette, possibly
+	// blended with transparent black, refer to the color resources
+	// @color/iconvg_palette_0, @color/iconvg_palette_1, etc., instead of
+	// holding the palette's colors. The app must define those resources,
+	// which lets it theme the icons.
+	PaletteColors bool
+}
+
+// Convert converts the IconVG graphic src to a VectorDrawable, writing it to
+// w.
+//
+// opts may be nil, which means to use the default options.
+func Convert(w io.Writer, src []byte, opts *Options) error {
+	g, err := ivg.DecodeThemable(src)
+	if err != nil {
+		return err
+	}
+	return Write(w, g, opts)
+}
+
+// Write writes g as a VectorDrawable to w.
+//
+// opts may be nil, which means to use the default options.
+func Write(w io.Writer, g *ivg.Graphic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	bw := bufio.NewWriter(w)
+	c := &converter{w: bw, g: g, opts: opts}
+	c.convert()
+	return bw.Flush()
+}
+
+type converter struct {
+	w     *bufio.Writer
+	g     *ivg.Graphic
+	opts  *Options
+	depth int
+}
+
+func (c *converter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, format, args...)
+}
+
+// element writes an element's start tag, with attrs holding alternating
+// attribute names and values. Unless the element is empty, its end tag is
+// written by calling end.
+func (c *converter) element(name string, empty bool, attrs ...string) {
+	indent := strings.Repeat("    ", c.depth)
+	c.printf("%s<%s", indent, name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		c.printf("\n%s    %s=\"%s\"", indent, attrs[i], escape(attrs[i+1]))
+	}
+	if empty {
+		c.printf("/>\n")
+	} else {
+		c.printf(">\n")
+		c.depth++
+	}
+}
+
+func (c *converter) end(name string) {
+	c.depth--
+	c.printf("%s</%s>\n", strings.Repeat("    ", c.depth), name)
+}
+
+func (c *converter) convert() {
+	m := &c.g.Metadata
+	dx, dy := m.ViewBox.AspectRatio()
+	width, height := c.opts.Width, c.opts.Height
+	if (width <= 0) && (height <= 0) {
+		height = 24
+	}
+	if width <= 0 {
+		width = int(math.Round(float64(height) * float64(dx) / float64(dy)))
+	} else if height <= 0 {
+		height = int(math.Round(float64(width) * float64(dy) / float64(dx)))
+	}
+
+	for _, s := range []string{m.Title, m.Description} {
+		if s != "" {
+			// XML comments cannot contain "--".
+			c.printf("<!-- %s -->\n", strings.ReplaceAll(s, "--", "- -"))
+		}
+	}
+	attrs := []string{"xmlns:android", "http://schemas.android.com/apk/res/android"}
+	for _, s := range c.g.Shapes {
+		if s.Paint.Gradient != nil {
+			attrs = append(attrs, "xmlns:aapt", "http://schemas.android.com/aapt")
+			break
+		}
+	}
+	attrs = append(attrs,
+		"android:width", strconv.Itoa(width)+"dp",
+		"android:height", strconv.Itoa(height)+"dp",
+		"android:viewportWidth", ftoa(dx),
+		"android:viewportHeight", ftoa(dy),
+	)
+	c.element("vector", false, attrs...)
+	translated := m.ViewBox.Min != (f32.Vec2{})
+	if translated {
+		c.element("group", false,
+			"android:translateX", ftoa(-m.ViewBox.Min[0]),
+			"android:translateY", ftoa(-m.ViewBox.Min[1]))
+	}
+
+	h := float32(height)
+	for i := range c.g.Shapes {
+		s := &c.g.Shapes[i]
+		if !(s.LOD0 <= h && h < s.LOD1) || (len(s.Path) == 0) {
+			continue
+		}
+		c.writeShape(s)
+	}
+
+	if translated {
+		c.end("group")
+	}
+	c.end("vector")
+}
+
+func (c *converter) writeShape(s *ivg.Shape) {
+	fillType := []string(nil)
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		fillType = []string{"android:fillType", "evenOdd"}
+	}
+
+	col := s.Paint.Color
+	if grad := s.Paint.Gradient; grad != nil {
+		linear := grad.Shape == ivg.GradientShapeLinear
+		if inv, ok := lowlevel.InvertGradientTransform(grad.Transform, linear); !ok {
+			// Like SVG, a degenerate gradient is painted with its last stop's
+			// color.
+			if n := len(grad.Stops); n > 0 {
+				col = grad.Stops[n-1].Color
+			} else {
+				col = lowlevel.RGBAColor(color.RGBA{})
+			}
+		} else if linear {
+			// The gradient runs from where inv maps (0, 0) to where it maps
+			// (1, 0).
+			c.writeGradientPath(s.Path, fillType, grad, []string{
+				"android:type", "linear",
+				"android:startX", ftoa(float32(inv[2])),
+				"android:startY", ftoa(float32(inv[5])),
+				"android:endX", ftoa(float32(inv[0] + inv[2])),
+				"android:endY", ftoa(float32(inv[3] + inv[5])),
+			})
+			return
+		} else {
+			c.writeRadialGradientPath(s.Path, fillType, grad, inv)
+			return
+		}
+	}
+
+	attrs := []string{"android:pathData", pathData(s.Path)}
+	rgba := col.Resolve(&c.g.Metadata.Palette, nil)
+	if i, opacity, ok := col.PaletteRef(); ok && c.opts.PaletteColors {
+		attrs = append(attrs, "android:fillColor", paletteResource(i))
+		if opacity != 1 {
+			attrs = append(attrs, "android:fillAlpha", ftoa(opacity))
+		}
+	} else if rgba.A != 0 {
+		attrs = append(attrs, "android:fillColor", vdColor(rgba))
+	}
+	c.element("path", true, append(attrs, fillType...)...)
+}
+
+// writeRadialGradientPath writes a path filled with a radial gradient. inv is
+// the inverse of the gradient's transform, mapping the unit circle in
+// gradient coordinate space to an ellipse in graphic coordinate space.
+func (c *converter) writeRadialGradientPath(p ivg.Path, fillType []string, g *ivg.Gradient, inv f64.Aff3) {
+	// Decompose inv's linear part as R(φ) × S(sx, sy) × R(θ), where R is a
+	// rotation and S is a scale. R(θ) maps the unit circle to itself, so the
+	// ellipse is the unit circle scaled, rotated and translated.
+	a, b, cc, d := inv[0], inv[1], inv[3], inv[4]
+	e, f := (a+d)/2, (a-d)/2
+	gg, hh := (cc+b)/2, (cc-b)/2
+	q, r := math.Hypot(e, hh), math.Hypot(f, gg)
+	sx, sy := q+r, q-r
+	phi := (math.Atan2(hh, e) + math.Atan2(gg, f)) / 2
+
+	if r <= q*1e-6 {
+		// The ellipse is a circle, of radius sx.
+		c.writeGradientPath(p, fillType, g, []string{
+			"android:type", "radial",
+			"android:centerX", ftoa(float32(inv[2])),
+			"android:centerY", ftoa(float32(inv[5])),
+			"android:gradientRadius", ftoa(float32(sx)),
+		})
+		return
+	}
+
+	// Draw the path in a group whose transform, which Android applies as
+	// scale, then rotation, then translation, maps the unit circle to the
+	// ellipse. The path's coordinates are mapped by that transform's inverse.
+	sin, cos := math.Sincos(phi)
+	c.element("group", false,
+		"android:translateX", ftoa(float32(inv[2])),
+		"android:translateY", ftoa(float32(inv[5])),
+		"android:rotation", ftoa(float32(phi*180/math.Pi)),
+		"android:scaleX", ftoa(float32(sx)),
+		"android:scaleY", ftoa(float32(sy)),
+	)
+	m := f64.Aff3{
+		+cos / sx, +sin / sx, 0,
+		-sin / sy, +cos / sy, 0,
+	}
+	m[2] = -(m[0]*inv[2] + m[1]*inv[5])
+	m[5] = -(m[3]*inv[2] + m[4]*inv[5])
+	local := ivg.Graphic{Shapes: []ivg.Shape{{Path: p}}}
+	local.Transform(m)
+	c.writeGradientPath(local.Shapes[0].Path, fillType, g, []string{
+		"android:type", "radial",
+		"android:centerX", "0",
+		"android:centerY", "0",
+		"android:gradientRadius", "1",
+	})
+	c.end("group")
+}
+
+// writeGradientPath writes a path filled with a gradient, whose geometry is
+// given by the gradient element's attrs.
+func (c *converter) writeGradientPath(p ivg.Path, fillType []string, g *ivg.Gradient, attrs []string) {
+	c.element("path", false, append([]string{"android:pathData", pathData(p)}, fillType...)...)
+	c.element("aapt:attr", false, "name", "android:fillColor")
+	c.element("gradient", false, append(attrs, "android:tileMode", tileModes[g.Spread&3])...)
+
+	none := (g.Spread == ivg.GradientSpreadNone) && (len(g.Stops) > 0)
+	if none {
+		// VectorDrawable has no "none" spread. Emulate it with "clamp" and
+		// transparent stops at offsets 0 and 1.
+		c.writeItem(0, lowlevel.RGBAColor(color.RGBA{}))
+		c.writeItem(0, g.Stops[0].Color)
+	}
+	for _, stop := range g.Stops {
+		c.writeItem(stop.Offset, stop.Color)
+	}
+	if none {
+		c.writeItem(1, g.Stops[len(g.Stops)-1].Color)
+		c.writeItem(1, lowlevel.RGBAColor(color.RGBA{}))
+	}
+
+	c.end("gradient")
+	c.end("aapt:attr")
+	c.end("path")
+}
+
+func (c *converter) writeItem(offset float32, col lowlevel.Color) {
+	s := ""
+	if i, ok := col.PaletteIndex(); ok && c.opts.PaletteColors {
+		s = paletteResource(i)
+	} else {
+		s = vdColor(col.Resolve(&c.g.Metadata.Palette, nil))
+	}
+	c.element("item", true, "android:offset", ftoa(offset), "android:color", s)
+}
+
+// tileModes are the VectorDrawable tileMode names of the IconVG gradient
+// spreads. The "none" spread is emulated by "clamp".
+var tileModes = [4]string{"clamp", "clamp", "mirror", "repeat"}
+
+// pathData returns p as SVG path data, which VectorDrawable also uses.
+func pathData(p ivg.Path) string {
+	buf := []byte(nil)
+	for i, seg := range p {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			buf = append(buf, 'M')
+			buf = appendPoints(buf, seg.To)
+		case ivg.LineTo:
+			buf = append(buf, 'L')
+			buf = appendPoints(buf, seg.To)
+		case ivg.QuadTo:
+			buf = append(buf, 'Q')
+			buf = appendPoints(buf, seg.Ctrl, seg.To)
+		case ivg.CubeTo:
+			buf = append(buf, 'C')
+			buf = appendPoints(buf, seg.Ctrl0, seg.Ctrl1, seg.To)
+		case ivg.ArcTo:
+			largeArc, sweep := "0", "0"
+			if seg.LargeArc {
+				largeArc = "1"
+			}
+			if seg.Sweep {
+				sweep = "1"
+			}
+			buf = append(buf, 'A')
+			buf = append(buf, ftoa(seg.Radii[0])+" "+ftoa(seg.Radii[1])+" "+
+				ftoa(seg.XAxisRotation*360)+" "+largeArc+" "+sweep+" "...)
+			buf = appendPoints(buf, seg.To)
+		case ivg.ClosePath:
+			buf = append(buf, 'Z')
+		}
+	}
+	return string(buf)
+}
+
+func appendPoints(buf []byte, ps ...f32.Vec2) []byte {
+	for i, p := range ps {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, ftoa(p[0])+" "+ftoa(p[1])...)
+	}
+	return buf
+}
+
+// vdColor returns an alpha-premultiplied color as a VectorDrawable color.
+// Invalid alpha-premultiplied colors become opaque black.
+func vdColor(rgba color.RGBA) string {
+	if (rgba.R > rgba.A) || (rgba.G > rgba.A) || (rgba.B > rgba.A) {
+		return "#000000"
+	}
+	c := color.NRGBAModel.Convert(rgba).(color.NRGBA)
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02X%02X%02X%02X", c.A, c.R, c.G, c.B)
+}
+
+// paletteResource returns the color resource reference for palette index i.
+func paletteResource(i uint8) string {
+	return fmt.Sprintf("@color/iconvg_palette_%d", i)
+}
+
+// escape escapes s for use in an XML attribute value.
+func escape(s string) string {
+	return attrEscaper.Replace(s)
+}
+
+var attrEscaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;", `"`, "&quot;")
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
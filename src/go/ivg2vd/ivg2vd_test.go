@@ -0,0 +1,348 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2vd_test
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2vd"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/math/f32"
+)
+
+// TestRoundTrip checks that graphics survive a round trip through a
+// VectorDrawable: converting an IconVG graphic with Write and converting the
+// result back with Parse.
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		filename  string
+		tolerance int
+	}{
+		{"action-info.lores.ivg", 2},
+		{"arcs.ivg", 2},
+		{"cowbell.ivg", 2},
+		{"favicon.ivg", 2},
+		{"gradient.ivg", 8},
+		{"lod-polygon.ivg", 2},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vd := &bytes.Buffer{}
+		if err := ivg2vd.Convert(vd, src, &ivg2vd.Options{Height: 64}); err != nil {
+			t.Errorf("%s: Convert: %v", tc.filename, err)
+			continue
+		}
+		g, err := ivg2vd.Parse(vd.Bytes(), nil)
+		if err != nil {
+			t.Errorf("%s: Parse: %v", tc.filename, err)
+			continue
+		}
+		got, err := ivg.Encode(g)
+		if err != nil {
+			t.Errorf("%s: Encode: %v", tc.filename, err)
+			continue
+		}
+		m0, err := render.Image(src, 64, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filename, err)
+		}
+		m1, err := render.Image(got, 64, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filename, err)
+		}
+		for i := range m0.Pix {
+			if d := int(m0.Pix[i]) - int(m1.Pix[i]); (d < -tc.tolerance) || (tc.tolerance < d) {
+				t.Errorf("%s: pixel (%d, %d): got %v, want %v", tc.filename, (i/4)%64, (i/4)/64,
+					m1.Pix[i&^3:i&^3+4], m0.Pix[i&^3:i&^3+4])
+				break
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: red},
+		{Offset: 1, Color: lowlevel.PaletteIndexColor(1)},
+	}
+	triangle := func() *ivg.Builder {
+		return ivg.NewBuilder().MoveTo(-20, -20).LineTo(+20, -20).LineTo(+20, +20).ClosePath()
+	}
+	paletteColors := &ivg2vd.Options{PaletteColors: true}
+	testCases := []struct {
+		desc     string
+		g        *ivg.Graphic
+		opts     *ivg2vd.Options
+		want     []string
+		dontWant []string
+	}{{
+		"RGBA color",
+		triangle().Fill(red).Graphic(),
+		nil,
+		[]string{
+			"<vector\n    xmlns:android=\"http://schemas.android.com/apk/res/android\"\n" +
+				"    android:width=\"24dp\"\n    android:height=\"24dp\"\n" +
+				"    android:viewportWidth=\"64\"\n    android:viewportHeight=\"64\">\n",
+			"<group\n        android:translateX=\"32\"\n        android:translateY=\"32\">\n",
+			"android:pathData=\"M-20 -20 L20 -20 L20 20 Z\"\n",
+			"android:fillColor=\"#FF0000\"/>\n",
+		},
+		[]string{"xmlns:aapt", "fillType", "<!--"},
+	}, {
+		"translucent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80})).Graphic(),
+		nil,
+		[]string{`android:fillColor="#807F0000"`},
+		nil,
+	}, {
+		"transparent color",
+		triangle().Fill(lowlevel.RGBAColor(color.RGBA{})).Graphic(),
+		nil,
+		[]string{"android:pathData="},
+		[]string{"fillColor"},
+	}, {
+		"palette color",
+		triangle().Fill(lowlevel.PaletteIndexColor(2)).Graphic(),
+		nil,
+		[]string{`android:fillColor="#000000"`},
+		[]string{"@color/"},
+	}, {
+		"palette color, PaletteColors",
+		triangle().Fill(lowlevel.PaletteIndexColor(2)).Graphic(),
+		paletteColors,
+		[]string{`android:fillColor="@color/iconvg_palette_2"`},
+		[]string{"fillAlpha"},
+	}, {
+		"blended palette color, PaletteColors",
+		triangle().Fill(lowlevel.BlendColor(0x80, 0x82, 0x7f)).Graphic(),
+		paletteColors,
+		[]string{`android:fillColor="@color/iconvg_palette_2"`, `android:fillAlpha="0.49803922"`},
+		nil,
+	}, {
+		"even-odd fill rule",
+		triangle().SetFillRule(lowlevel.FillRuleEvenOdd).Fill(red).Graphic(),
+		nil,
+		[]string{`android:fillType="evenOdd"`},
+		nil,
+	}, {
+		"levels of detail",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			b.SetLOD(0, 30).MoveTo(0, 0).LineTo(1, 0).LineTo(1, 1).ClosePath().Fill(red)
+			b.SetLOD(30, ivg.DefaultLOD1).MoveTo(0, 0).LineTo(2, 0).LineTo(2, 2).ClosePath().Fill(red)
+			return b.Graphic()
+		}(),
+		&ivg2vd.Options{Height: 48},
+		[]string{`android:pathData="M0 0 L2 0 L2 2 Z"`},
+		[]string{`android:pathData="M0 0 L1 0 L1 1 Z"`},
+	}, {
+		"curves",
+		ivg.NewBuilder().MoveTo(0, 0).QuadTo(10, 0, 10, 10).CubeTo(10, 20, 0, 20, 0, 10).
+			ArcTo(5, 4, 0.25, true, false, 0, 0).ClosePath().Fill(red).Graphic(),
+		nil,
+		[]string{`android:pathData="M0 0 Q10 0 10 10 C10 20 0 20 0 10 A5 4 90 1 0 0 0 Z"`},
+		nil,
+	}, {
+		"viewBox and size",
+		triangle().SetViewBox(0, 0, 48, 24).Fill(red).Graphic(),
+		&ivg2vd.Options{Width: 96},
+		[]string{`android:width="96dp"`, `android:height="48dp"`, `android:viewportWidth="48"`, `android:viewportHeight="24"`},
+		[]string{"<group"},
+	}, {
+		"metadata",
+		func() *ivg.Graphic {
+			g := triangle().Fill(red).Graphic()
+			g.Metadata.Title = "Cow--bell"
+			g.Metadata.Description = "A <bell>"
+			return g
+		}(),
+		nil,
+		[]string{"<!-- Cow- -bell -->\n<!-- A <bell> -->\n<vector"},
+		nil,
+	}, {
+		"linear gradient",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadReflect)).Graphic(),
+		paletteColors,
+		[]string{
+			`xmlns:aapt="http://schemas.android.com/aapt"`,
+			"<aapt:attr\n                name=\"android:fillColor\">\n",
+			`android:type="linear"`,
+			`android:startX="-20"`, `android:endX="20"`, `android:endY="0"`,
+			`android:tileMode="mirror"`,
+			`android:offset="0"` + "\n" + `                        android:color="#FF0000"/>`,
+			`android:offset="1"` + "\n" + `                        android:color="@color/iconvg_palette_1"/>`,
+		},
+		[]string{"fillColor=\""},
+	}, {
+		"linear gradient, none",
+		triangle().FillPaint(ivg.LinearGradient(stops, -20, 0, 20, 0, ivg.GradientSpreadNone)).Graphic(),
+		nil,
+		[]string{`android:tileMode="clamp"`, `android:offset="0"` + "\n" + `                        android:color="#00000000"/>`},
+		nil,
+	}, {
+		"radial gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops, 4, 5, 10, ivg.GradientSpreadRepeat)).Graphic(),
+		nil,
+		[]string{`android:type="radial"`, `android:centerX="4"`, `android:centerY="5"`, `android:gradientRadius="10"`, `android:tileMode="repeat"`},
+		[]string{"android:rotation"},
+	}, {
+		"elliptical radial gradient",
+		func() *ivg.Graphic {
+			p := ivg.RadialGradient(stops, 0, 0, 1, ivg.GradientSpreadPad)
+			p.Gradient.Transform = f32.Aff3{1.0 / 20, 0, 0, 0, 1.0 / 10, 0}
+			return triangle().FillPaint(p).Graphic()
+		}(),
+		nil,
+		[]string{`android:scaleX="20"`, `android:scaleY="10"`, `android:centerX="0"`, `android:gradientRadius="1"`},
+		nil,
+	}, {
+		"degenerate gradient",
+		triangle().FillPaint(ivg.RadialGradient(stops, 0, 0, 0, ivg.GradientSpreadPad)).Graphic(),
+		nil,
+		[]string{`android:fillColor="#000000"`},
+		[]string{"<gradient"},
+	}}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		if err := ivg2vd.Write(buf, tc.g, tc.opts); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		got := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output does not contain %q:\n%s", tc.desc, want, got)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(got, dontWant) {
+				t.Errorf("%s: output contains %q:\n%s", tc.desc, dontWant, got)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	const header = `<vector xmlns:android="http://schemas.android.com/apk/res/android" ` +
+		`xmlns:aapt="http://schemas.android.com/aapt" ` +
+		`android:width="24dp" android:height="24dp" android:viewportWidth="24" android:viewportHeight="24">`
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	testCases := []struct {
+		desc string
+		body string
+		// want is the first Shape's Color, ignored if wantGradient.
+		want         lowlevel.Color
+		wantGradient bool
+		wantShapes   int
+	}{
+		{"RGB", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#F00"/>`, red, false, 1},
+		{"RRGGBB", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#FF0000"/>`, red, false, 1},
+		{"AARRGGBB", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#80FF0000"/>`,
+			lowlevel.RGBAColor(color.RGBA{0x80, 0x00, 0x00, 0x80}), false, 1},
+		{"fillAlpha", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#FF0000" android:fillAlpha="0.5"/>`,
+			lowlevel.RGBAColor(color.RGBA{0x80, 0x00, 0x00, 0x80}), false, 1},
+		{"no fill", `<path android:pathData="M0 0L4 0L4 4Z"/>`, lowlevel.Color{}, false, 0},
+		{"palette resource", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="@color/iconvg_palette_3"/>`,
+			lowlevel.PaletteIndexColor(3), false, 1},
+		{"group", `<group android:translateX="2"><path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#F00"/></group>`,
+			red, false, 1},
+		{"gradient", `<path android:pathData="M0 0L4 0L4 4Z"><aapt:attr name="android:fillColor">` +
+			`<gradient android:type="linear" android:startX="0" android:startY="0" android:endX="4" android:endY="0" ` +
+			`android:startColor="#F00" android:endColor="#00F"/></aapt:attr></path>`,
+			lowlevel.Color{}, true, 1},
+		{"sweep gradient", `<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#F00"><aapt:attr name="android:fillColor">` +
+			`<gradient android:type="sweep" android:startColor="#F00" android:endColor="#00F"/></aapt:attr></path>`,
+			red, false, 1},
+	}
+	for _, tc := range testCases {
+		g, err := ivg2vd.Parse([]byte(header+tc.body+`</vector>`), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if len(g.Shapes) != tc.wantShapes {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, len(g.Shapes), tc.wantShapes)
+			continue
+		}
+		if tc.wantShapes == 0 {
+			continue
+		}
+		p := g.Shapes[0].Paint
+		if got := p.Gradient != nil; got != tc.wantGradient {
+			t.Errorf("%s: got gradient %t, want %t", tc.desc, got, tc.wantGradient)
+		} else if !tc.wantGradient && p.Color != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, p.Color, tc.want)
+		}
+	}
+}
+
+func TestParseThemable(t *testing.T) {
+	src := `<vector xmlns:android="http://schemas.android.com/apk/res/android" ` +
+		`android:viewportWidth="24" android:viewportHeight="24">` +
+		`<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="?attr/colorPrimary"/>` +
+		`<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="@color/accent"/>` +
+		`</vector>`
+	g, err := ivg2vd.Parse([]byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Shapes) != 2 {
+		t.Fatalf("got %d shapes, want 2", len(g.Shapes))
+	}
+	i0, ok0 := g.Shapes[0].Paint.Color.PaletteIndex()
+	i1, ok1 := g.Shapes[1].Paint.Color.PaletteIndex()
+	if !ok0 || !ok1 || (i0 == i1) {
+		t.Fatalf("got %v and %v, want two different palette indexes", g.Shapes[0].Paint.Color, g.Shapes[1].Paint.Color)
+	}
+	if want := (color.RGBA{0x00, 0x00, 0x00, 0xff}); g.Metadata.Palette[i0] != want {
+		t.Errorf("palette[%d]: got %v, want %v", i0, g.Metadata.Palette[i0], want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []string{
+		``,
+		`<svg viewBox="0 0 24 24"/>`,
+		`<vector android:viewportWidth="24"/>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="-1"/>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="24">` +
+			`<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="red"/></vector>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="24">` +
+			`<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#12345"/></vector>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="24">` +
+			`<path android:pathData="M0 0L4 0L4 4Z" android:fillColor="#F00" android:fillAlpha="half"/></vector>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="24">` +
+			`<group android:rotation="a lot"/></vector>`,
+		`<vector android:viewportWidth="24" android:viewportHeight="24">` +
+			`<path android:pathData="M0 0L4 0L4 4Z"><aapt:attr name="android:fillColor">` +
+			`<gradient android:tileMode="wrap" android:startColor="#F00" android:endColor="#00F"/>` +
+			`</aapt:attr></path></vector>`,
+	}
+	for _, tc := range testCases {
+		if _, err := ivg2vd.Parse([]byte(tc), nil); err == nil {
+			t.Errorf("%q: got nil error, want non-nil", tc)
+		}
+	}
+}
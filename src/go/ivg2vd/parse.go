@@ -0,0 +1,380 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2vd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/svgconv"
+)
+
+var (
+	errInvalidColor      = errors.New("ivg2vd: invalid color")
+	errInvalidVector     = errors.New("ivg2vd: invalid vector element")
+	errNotVectorDrawable = errors.New("ivg2vd: not a VectorDrawable")
+)
+
+// Parse converts the VectorDrawable src to an IconVG graphic.
+//
+// opts may be nil, which means to use the default options.
+func Parse(src []byte, opts *svgconv.Options) (*ivg.Graphic, error) {
+	svg, err := ToSVG(src)
+	if err != nil {
+		return nil, err
+	}
+	return svgconv.Parse(svg, opts)
+}
+
+// ToSVG converts the VectorDrawable src to an equivalent SVG document, as
+// accepted by package svgconv.
+func ToSVG(src []byte) ([]byte, error) {
+	root, err := parseXML(src)
+	if err != nil {
+		return nil, err
+	}
+	if root.name != "vector" {
+		return nil, errNotVectorDrawable
+	}
+	vw, err0 := strconv.ParseFloat(root.attrs["viewportWidth"], 64)
+	vh, err1 := strconv.ParseFloat(root.attrs["viewportHeight"], 64)
+	if err0 != nil || err1 != nil || !(vw > 0) || !(vh > 0) {
+		return nil, errInvalidVector
+	}
+
+	t := &translator{}
+	t.printf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %s %s">`,
+		root.attrs["viewportWidth"], root.attrs["viewportHeight"])
+	t.printf("\n")
+	if a, ok := root.attrs["alpha"]; ok {
+		t.printf("<g opacity=\"%s\">\n", escape(a))
+	}
+	if err := t.children(root); err != nil {
+		return nil, err
+	}
+	if _, ok := root.attrs["alpha"]; ok {
+		t.printf("</g>\n")
+	}
+	t.printf("</svg>\n")
+	return t.buf.Bytes(), nil
+}
+
+// vdNode is a VectorDrawable XML element. Attribute names are without their
+// namespace prefix, so that "android:pathData" is "pathData".
+type vdNode struct {
+	name     string
+	attrs    map[string]string
+	children []*vdNode
+}
+
+func parseXML(src []byte) (*vdNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	stack := []*vdNode(nil)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, errNotVectorDrawable
+		} else if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &vdNode{name: tok.Name.Local, attrs: map[string]string{}}
+			for _, a := range tok.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					n.attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return stack[0], nil
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// translator writes the SVG equivalent of VectorDrawable elements.
+type translator struct {
+	buf        bytes.Buffer
+	nGradients int
+}
+
+func (t *translator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&t.buf, format, args...)
+}
+
+func (t *translator) children(n *vdNode) error {
+	for _, child := range n.children {
+		switch child.name {
+		case "group":
+			if err := t.group(child); err != nil {
+				return err
+			}
+		case "path":
+			if err := t.path(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// group writes a g element whose transform is the VectorDrawable group's:
+// scale and rotation about the pivot, then translation.
+func (t *translator) group(n *vdNode) error {
+	v := [7]float64{0, 0, 0, 0, 0, 1, 1}
+	for i, name := range [7]string{"pivotX", "pivotY", "translateX", "translateY", "rotation", "scaleX", "scaleY"} {
+		if s := n.attrs[name]; s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return errInvalidVector
+			}
+			v[i] = f
+		}
+	}
+	t.printf(`<g transform="translate(%s %s) rotate(%s) scale(%s %s) translate(%s %s)">`,
+		ftoa64(v[2]+v[0]), ftoa64(v[3]+v[1]), ftoa64(v[4]), ftoa64(v[5]), ftoa64(v[6]),
+		ftoa64(0-v[0]), ftoa64(0-v[1]))
+	t.printf("\n")
+	if err := t.children(n); err != nil {
+		return err
+	}
+	t.printf("</g>\n")
+	return nil
+}
+
+func (t *translator) path(n *vdNode) error {
+	fill, stroke := n.attrs["fillColor"], n.attrs["strokeColor"]
+	var fillGradient, strokeGradient *vdNode
+	for _, child := range n.children {
+		if child.name != "attr" || len(child.children) == 0 || child.children[0].name != "gradient" {
+			continue
+		}
+		switch child.attrs["name"] {
+		case "android:fillColor":
+			fillGradient = child.children[0]
+		case "android:strokeColor":
+			strokeGradient = child.children[0]
+		}
+	}
+
+	attrs := []string{"d", n.attrs["pathData"]}
+	var err error
+	if attrs, err = t.paint(attrs, "fill", fill, n.attrs["fillAlpha"], fillGradient); err != nil {
+		return err
+	}
+	if n.attrs["fillType"] == "evenOdd" {
+		attrs = append(attrs, "fill-rule", "evenodd")
+	}
+	if w := n.attrs["strokeWidth"]; w != "" && w != "0" {
+		if attrs, err = t.paint(attrs, "stroke", stroke, n.attrs["strokeAlpha"], strokeGradient); err != nil {
+			return err
+		}
+		attrs = append(attrs, "stroke-width", w)
+		if s := n.attrs["strokeLineCap"]; s != "" {
+			attrs = append(attrs, "stroke-linecap", s)
+		}
+		if s := n.attrs["strokeLineJoin"]; s != "" {
+			attrs = append(attrs, "stroke-linejoin", s)
+		}
+		if s := n.attrs["strokeMiterLimit"]; s != "" {
+			attrs = append(attrs, "stroke-miterlimit", s)
+		}
+	}
+
+	t.printf("<path")
+	for i := 0; i+1 < len(attrs); i += 2 {
+		t.printf(` %s="%s"`, attrs[i], escape(attrs[i+1]))
+	}
+	t.printf("/>\n")
+	return nil
+}
+
+// paint appends the SVG attributes for a fill or stroke (as per prop) of the
+// given VectorDrawable color, alpha and gradient to attrs. A gradient takes
+// precedence over a color.
+func (t *translator) paint(attrs []string, prop string, col string, alpha string, gradient *vdNode) ([]string, error) {
+	if gradient != nil {
+		id, err := t.gradient(gradient)
+		if err != nil {
+			return nil, err
+		} else if id != "" {
+			attrs = append(attrs, prop, "url(#"+id+")")
+			if alpha != "" {
+				attrs = append(attrs, prop+"-opacity", alpha)
+			}
+			return attrs, nil
+		}
+	}
+	if col == "" {
+		// A VectorDrawable's default fill and stroke colors are transparent.
+		return append(attrs, prop, "none"), nil
+	}
+	css, opacity, err := cssColor(col)
+	if err != nil {
+		return nil, err
+	}
+	if alpha != "" {
+		a, err := strconv.ParseFloat(alpha, 64)
+		if err != nil {
+			return nil, errInvalidVector
+		}
+		opacity *= a
+	}
+	attrs = append(attrs, prop, css)
+	if opacity != 1 {
+		attrs = append(attrs, prop+"-opacity", ftoa64(opacity))
+	}
+	return attrs, nil
+}
+
+// spreadMethods are the SVG spreadMethod values of the VectorDrawable
+// tileMode values.
+var spreadMethods = map[string]string{
+	"":       "pad",
+	"clamp":  "pad",
+	"mirror": "reflect",
+	"repeat": "repeat",
+}
+
+// gradient writes an SVG gradient, in a defs element, for a VectorDrawable
+// gradient element, and returns its id. It returns an empty id for sweep
+// gradients, which SVG does not have.
+func (t *translator) gradient(n *vdNode) (id string, err error) {
+	a := n.attrs
+	elem, geometry := "", []string(nil)
+	switch a["type"] {
+	case "", "linear":
+		elem = "linearGradient"
+		geometry = []string{"x1", a["startX"], "y1", a["startY"], "x2", a["endX"], "y2", a["endY"]}
+	case "radial":
+		elem = "radialGradient"
+		geometry = []string{"cx", a["centerX"], "cy", a["centerY"], "r", a["gradientRadius"]}
+	default:
+		return "", nil
+	}
+	spread, ok := spreadMethods[a["tileMode"]]
+	if !ok {
+		return "", errInvalidVector
+	}
+
+	id = fmt.Sprintf("vd-gradient-%d", t.nGradients)
+	t.nGradients++
+	t.printf(`<defs><%s id="%s" gradientUnits="userSpaceOnUse" spreadMethod="%s"`, elem, id, spread)
+	for i := 0; i < len(geometry); i += 2 {
+		s := geometry[i+1]
+		if s == "" {
+			s = "0"
+		} else if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", errInvalidVector
+		}
+		t.printf(` %s="%s"`, geometry[i], s)
+	}
+	t.printf(">\n")
+
+	// Without item elements, the stops are given by the startColor,
+	// centerColor and endColor attributes.
+	type stop struct{ offset, color string }
+	stops := []stop(nil)
+	for _, item := range n.children {
+		if item.name == "item" {
+			stops = append(stops, stop{item.attrs["offset"], item.attrs["color"]})
+		}
+	}
+	if len(stops) == 0 {
+		stops = append(stops, stop{"0", a["startColor"]})
+		if s := a["centerColor"]; s != "" {
+			stops = append(stops, stop{"0.5", s})
+		}
+		stops = append(stops, stop{"1", a["endColor"]})
+	}
+	for _, s := range stops {
+		if _, err := strconv.ParseFloat(s.offset, 64); err != nil {
+			return "", errInvalidVector
+		}
+		css, opacity, err := cssColor(s.color)
+		if err != nil {
+			return "", err
+		}
+		t.printf(`<stop offset="%s" stop-color="%s"`, s.offset, escape(css))
+		if opacity != 1 {
+			t.printf(` stop-opacity="%s"`, ftoa64(opacity))
+		}
+		t.printf("/>\n")
+	}
+	t.printf("</%s></defs>\n", elem)
+	return id, nil
+}
+
+// cssColor converts a VectorDrawable color, either #RGB, #ARGB, #RRGGBB or
+// #AARRGGBB, or a resource or theme attribute reference, to a CSS color and
+// an opacity. A reference becomes a custom property, with an opaque black
+// fallback, so that package svgconv makes it a themable palette entry.
+func cssColor(s string) (css string, opacity float64, err error) {
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "?") {
+		name := s[1:]
+		if strings.HasPrefix(name, "color/iconvg_palette_") {
+			name = "iconvg-palette-" + name[len("color/iconvg_palette_"):]
+		} else {
+			name = strings.Map(func(r rune) rune {
+				if ('0' <= r && r <= '9') || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z') || r == '_' {
+					return r
+				}
+				return '-'
+			}, name)
+		}
+		return "var(--" + name + ", black)", 1, nil
+	}
+
+	if !strings.HasPrefix(s, "#") {
+		return "", 0, errInvalidColor
+	}
+	hex := s[1:]
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return "", 0, errInvalidColor
+	}
+	alpha := ""
+	switch len(hex) {
+	case 3, 6:
+	case 4:
+		alpha, hex = hex[:1]+hex[:1], hex[1:]
+	case 8:
+		alpha, hex = hex[:2], hex[2:]
+	default:
+		return "", 0, errInvalidColor
+	}
+	opacity = 1
+	if alpha != "" {
+		a, _ := strconv.ParseUint(alpha, 16, 8)
+		opacity = float64(a) / 0xff
+	}
+	return "#" + hex, opacity, nil
+}
+
+func ftoa64(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivg2xcode exports IconVG graphics to Xcode asset catalogs, so that
+// iOS and macOS apps can use the same IconVG icons as other platforms.
+//
+// WriteImageSet writes an image set holding a single page vector PDF, as
+// converted by package ivg2pdf, with the "Preserve Vector Data" property set,
+// so that Xcode can rasterize it at any scale.
+//
+// WriteSymbolSet writes a symbol set holding an SF Symbols template, which
+// lets the icon be used like a system symbol, aligned with text and tinted.
+// SF Symbols are monochrome, so the template holds the union of the icon's
+// visible paths, ignoring their colors. The icon is scaled so that the
+// viewBox's height is the template's 100 point glyph size, and centered
+// vertically on the cap height. Its Ultralight, Regular and Black weights, at
+// the small scale, are all the same glyph, from which Xcode interpolates the
+// other weights and scales.
+package ivg2xcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2pdf"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+var errInvalidName = errors.New("ivg2xcode: invalid name")
+
+// Options are the optional parameters to the Write functions.
+type Options struct {
+	// Size is the image's height, in points. The width follows the viewBox's
+	// aspect ratio. Size also selects which level of detail is converted. If
+	// it is zero, it is 24.
+	Size float64
+
+	// Template is whether an image set is rendered as a template image,
+	// whose colors are replaced by the tint color, instead of as an original
+	// image. Symbol sets are always template images.
+	Template bool
+}
+
+func (o *Options) size() float64 {
+	if o == nil || !(o.Size > 0) {
+		return 24
+	}
+	return o.Size
+}
+
+// WriteImageSet writes the IconVG graphic src as an image set, a directory
+// named name+".imageset" in dir, the asset catalog directory. The directory
+// holds name+".pdf" and a Contents.json file.
+//
+// opts may be nil, which means to use the default options.
+func WriteImageSet(dir string, name string, src []byte, opts *Options) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	var pdf strings.Builder
+	if err := ivg2pdf.Convert(&pdf, src, &ivg2pdf.Options{Height: opts.size()}); err != nil {
+		return err
+	}
+
+	properties := map[string]interface{}{
+		"preserves-vector-representation": true,
+	}
+	if opts != nil && opts.Template {
+		properties["template-rendering-intent"] = "template"
+	}
+	return writeSet(filepath.Join(dir, name+".imageset"), name+".pdf", []byte(pdf.String()), map[string]interface{}{
+		"images":     []interface{}{map[string]string{"filename": name + ".pdf", "idiom": "universal"}},
+		"info":       contentsInfo,
+		"properties": properties,
+	})
+}
+
+// WriteSymbolSet writes the IconVG graphic src as a symbol set, a directory
+// named name+".symbolset" in dir, the asset catalog directory. The directory
+// holds name+".svg", an SF Symbols template, and a Contents.json file.
+//
+// opts may be nil, which means to use the default options.
+func WriteSymbolSet(dir string, name string, src []byte, opts *Options) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	var svg strings.Builder
+	if err := WriteSymbolTemplate(&svg, src, opts); err != nil {
+		return err
+	}
+	return writeSet(filepath.Join(dir, name+".symbolset"), name+".svg", []byte(svg.String()), map[string]interface{}{
+		"symbols": []interface{}{map[string]string{"filename": name + ".svg", "idiom": "universal"}},
+		"info":    contentsInfo,
+	})
+}
+
+// contentsInfo is the "info" of a Contents.json file.
+var contentsInfo = map[string]interface{}{"author": "xcode", "version": 1}
+
+// validName returns whether name can name an asset's directory and file.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// writeSet creates the directory setDir and writes the asset file, named
+// filename, and its Contents.json file to it.
+func writeSet(setDir string, filename string, data []byte, contents map[string]interface{}) error {
+	j, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(setDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(setDir, filename), data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(setDir, "Contents.json"), append(j, '\n'), 0644)
+}
+
+// The SF Symbols template's layout: its size, the columns of its weights and
+// the baselines of its scales, and the 100 point glyph size's cap height.
+const (
+	templateWidth  = 3300
+	templateHeight = 2200
+	capHeight      = 70.459
+	glyphSize      = 100
+	marginTop      = 600.785
+	marginBottom   = 720.121
+	guideLeft      = 263
+	guideRight     = 3036
+)
+
+var (
+	weights   = [3]string{"Ultralight", "Regular", "Black"}
+	columns   = [3]float64{559, 1449, 2339}
+	scales    = [3]string{"S", "M", "L"}
+	baselines = [3]float64{696, 1126, 1556}
+)
+
+// WriteSymbolTemplate writes the IconVG graphic src as an SF Symbols
+// template, an SVG document, to w.
+//
+// opts may be nil, which means to use the default options.
+func WriteSymbolTemplate(w io.Writer, src []byte, opts *Options) error {
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		return err
+	}
+	vb := &g.Metadata.ViewBox
+	dx, dy := vb.AspectRatio()
+	s := glyphSize / float64(dy)
+	lodHeight := float32(opts.size())
+
+	// Union the visible paths, in glyph coordinates, whose origin is on the
+	// baseline, at the left margin.
+	tolerance := float32(math.Max(float64(dx), float64(dy)) / 4096)
+	glyph, n := ivg.Path(nil), 0
+	for i := range g.Shapes {
+		sh := &g.Shapes[i]
+		if !(sh.LOD0 <= lodHeight && lodHeight < sh.LOD1) || (len(sh.Path) == 0) {
+			continue
+		} else if sh.Paint.Gradient == nil && sh.Paint.Color.Resolve(&g.Metadata.Palette, nil).A == 0 {
+			continue
+		}
+		p := sh.Path
+		if sh.FillRule == lowlevel.FillRuleEvenOdd {
+			p = ivg.EvenOddToNonZero(p, tolerance)
+		}
+		if n++; n == 1 {
+			glyph = p
+		} else {
+			glyph = ivg.Union(glyph, p, tolerance)
+		}
+	}
+	cy := float64(vb.Min[1]+vb.Max[1]) / 2
+	tmp := ivg.Graphic{Shapes: []ivg.Shape{{Path: glyph}}}
+	tmp.Transform(f64.Aff3{
+		s, 0, -float64(vb.Min[0]) * s,
+		0, s, -cy*s - capHeight/2,
+	})
+	glyph = tmp.Shapes[0].Path
+	width := float64(dx) * s
+
+	b := &strings.Builder{}
+	p := func(format string, args ...interface{}) { fmt.Fprintf(b, format, args...) }
+	p(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	p(`<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">` + "\n")
+	p(`<svg version="1.1" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d">`+"\n",
+		templateWidth, templateHeight)
+	p(" <g id=\"Notes\">\n")
+	p(`  <rect height="%d" id="artboard" style="fill:white;opacity:1" width="%d" x="0" y="0"/>`+"\n",
+		templateHeight, templateWidth)
+	if g.Metadata.Title != "" {
+		p(`  <text style="stroke:none;fill:black;font-family:sans-serif;font-size:13;" transform="matrix(1 0 0 1 %d 1933)">%s</text>`+"\n",
+			guideLeft, escapeText(g.Metadata.Title))
+	}
+	p(`  <text id="template-version" style="stroke:none;fill:black;font-family:sans-serif;font-size:13;" transform="matrix(1 0 0 1 3036 1933)">Template v.3.0</text>` + "\n")
+	p(" </g>\n")
+
+	p(" <g id=\"Guides\">\n")
+	for i, scale := range scales {
+		p(`  <line id="Baseline-%s" style="fill:none;stroke:#27AAE1;opacity:1;stroke-width:0.5;" x1="%d" x2="%d" y1="%s" y2="%s"/>`+"\n",
+			scale, guideLeft, guideRight, ftoa(baselines[i]), ftoa(baselines[i]))
+		p(`  <line id="Capline-%s" style="fill:none;stroke:#27AAE1;opacity:1;stroke-width:0.5;" x1="%d" x2="%d" y1="%s" y2="%s"/>`+"\n",
+			scale, guideLeft, guideRight, ftoa(baselines[i]-capHeight), ftoa(baselines[i]-capHeight))
+	}
+	for i, weight := range weights {
+		for j, side := range [2]string{"left", "right"} {
+			x := columns[i] + float64(j)*width
+			p(`  <line id="%s-margin-%s-S" style="fill:none;stroke:#00AEEF;stroke-width:0.5;opacity:1.0;" x1="%s" x2="%s" y1="%s" y2="%s"/>`+"\n",
+				side, weight, ftoa(x), ftoa(x), ftoa(marginTop), ftoa(marginBottom))
+		}
+	}
+	p(" </g>\n")
+
+	p(" <g id=\"Symbols\">\n")
+	d := pathData(glyph)
+	for i, weight := range weights {
+		p(`  <g id="%s-S" transform="matrix(1 0 0 1 %s %s)">`+"\n", weight, ftoa(columns[i]), ftoa(baselines[0]))
+		p(`   <path d="%s"/>`+"\n", d)
+		p("  </g>\n")
+	}
+	p(" </g>\n")
+	p("</svg>\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// pathData returns p as SVG path data, with arcs lowered to cubic Bézier
+// curves.
+func pathData(p ivg.Path) string {
+	b := &strings.Builder{}
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			fmt.Fprintf(b, "M%s", points(seg.To))
+			pen, start = seg.To, seg.To
+		case ivg.LineTo:
+			fmt.Fprintf(b, "L%s", points(seg.To))
+			pen = seg.To
+		case ivg.QuadTo:
+			fmt.Fprintf(b, "Q%s", points(seg.Ctrl, seg.To))
+			pen = seg.To
+		case ivg.CubeTo:
+			fmt.Fprintf(b, "C%s", points(seg.Ctrl0, seg.Ctrl1, seg.To))
+			pen = seg.To
+		case ivg.ArcTo:
+			b.WriteString(pathData(seg.Cubics(pen, 0)))
+			pen = seg.To
+		case ivg.ClosePath:
+			b.WriteString("Z")
+			pen = start
+		}
+	}
+	return b.String()
+}
+
+func points(ps ...f32.Vec2) string {
+	s := ""
+	for i, p := range ps {
+		if i > 0 {
+			s += " "
+		}
+		s += ftoa(float64(p[0])) + " " + ftoa(float64(p[1]))
+	}
+	return s
+}
+
+var textEscaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 32)
+}
@@ -0,0 +1,230 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivg2xcode_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/ivg2xcode"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func readContents(t *testing.T, setDir string) map[string]interface{} {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(setDir, "Contents.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Contents.json: %v", err)
+	}
+	return m
+}
+
+func TestWriteImageSet(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		opts           *ivg2xcode.Options
+		wantProperties map[string]interface{}
+	}{
+		{nil, map[string]interface{}{"preserves-vector-representation": true}},
+		{&ivg2xcode.Options{Size: 48}, map[string]interface{}{"preserves-vector-representation": true}},
+		{&ivg2xcode.Options{Template: true}, map[string]interface{}{
+			"preserves-vector-representation": true,
+			"template-rendering-intent":       "template",
+		}},
+	}
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		if err := ivg2xcode.WriteImageSet(dir, "cowbell", src, tc.opts); err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		setDir := filepath.Join(dir, "cowbell.imageset")
+		pdf, err := os.ReadFile(filepath.Join(setDir, "cowbell.pdf"))
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+			t.Errorf("%+v: cowbell.pdf is not a PDF", tc.opts)
+		}
+		contents := readContents(t, setDir)
+		wantImages := []interface{}{map[string]interface{}{"filename": "cowbell.pdf", "idiom": "universal"}}
+		if got := contents["images"]; !reflect.DeepEqual(got, wantImages) {
+			t.Errorf("%+v: images: got %v, want %v", tc.opts, got, wantImages)
+		}
+		if got := contents["properties"]; !reflect.DeepEqual(got, tc.wantProperties) {
+			t.Errorf("%+v: properties: got %v, want %v", tc.opts, got, tc.wantProperties)
+		}
+	}
+}
+
+func TestWriteSymbolSet(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ivg2xcode.WriteSymbolSet(dir, "cowbell", src, nil); err != nil {
+		t.Fatal(err)
+	}
+	setDir := filepath.Join(dir, "cowbell.symbolset")
+	svg, err := os.ReadFile(filepath.Join(setDir, "cowbell.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	if err := ivg2xcode.WriteSymbolTemplate(want, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(svg, want.Bytes()) {
+		t.Errorf("cowbell.svg differs from WriteSymbolTemplate's output")
+	}
+	contents := readContents(t, setDir)
+	wantSymbols := []interface{}{map[string]interface{}{"filename": "cowbell.svg", "idiom": "universal"}}
+	if got := contents["symbols"]; !reflect.DeepEqual(got, wantSymbols) {
+		t.Errorf("symbols: got %v, want %v", got, wantSymbols)
+	}
+	if _, ok := contents["properties"]; ok {
+		t.Errorf("got properties, want none")
+	}
+}
+
+func TestInvalidName(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`, "../cowbell"} {
+		dir := t.TempDir()
+		if err := ivg2xcode.WriteImageSet(dir, name, src, nil); err == nil {
+			t.Errorf("%q: WriteImageSet: got nil error, want non-nil", name)
+		}
+		if err := ivg2xcode.WriteSymbolSet(dir, name, src, nil); err == nil {
+			t.Errorf("%q: WriteSymbolSet: got nil error, want non-nil", name)
+		}
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+			t.Errorf("%q: got %d entries (%v), want none", name, len(entries), err)
+		}
+	}
+}
+
+func TestWriteSymbolTemplate(t *testing.T) {
+	black := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	square := func(b *ivg.Builder, x0, y0, x1, y1 float32) *ivg.Builder {
+		return b.MoveTo(x0, y0).LineTo(x1, y0).LineTo(x1, y1).LineTo(x0, y1).ClosePath()
+	}
+	testCases := []struct {
+		desc string
+		g    *ivg.Graphic
+		opts *ivg2xcode.Options
+		// wantD is the glyph's path data. The viewBox's height is 100
+		// points, and its vertical center is half of the 70.459 point cap
+		// height above the baseline.
+		wantD string
+		want  []string
+	}{{
+		"full square",
+		square(ivg.NewBuilder(), -32, -32, 32, 32).Fill(black).Graphic(),
+		nil,
+		"M0 -85.2295L100 -85.2295L100 14.7705L0 14.7705Z",
+		nil,
+	}, {
+		"overlapping squares",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			square(b, -32, -32, 0, 0).Fill(black)
+			square(b, -16, -16, 16, 16).Fill(lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff}))
+			return b.Graphic()
+		}(),
+		nil,
+		"",
+		nil,
+	}, {
+		"transparent and LOD shapes",
+		func() *ivg.Graphic {
+			b := ivg.NewBuilder()
+			square(b, -32, -32, 32, 32).Fill(lowlevel.RGBAColor(color.RGBA{}))
+			square(b.SetLOD(0, 30), -32, -32, 32, 32).Fill(black)
+			square(b.SetLOD(30, ivg.DefaultLOD1), -16, -16, 16, 16).Fill(black)
+			return b.Graphic()
+		}(),
+		&ivg2xcode.Options{Size: 48},
+		"M25 -60.2295L75 -60.2295L75 -10.2295L25 -10.2295Z",
+		nil,
+	}, {
+		"title",
+		func() *ivg.Graphic {
+			g := square(ivg.NewBuilder(), -32, -32, 32, 32).Fill(black).Graphic()
+			g.Metadata.Title = "Cow & <bell>"
+			return g
+		}(),
+		nil,
+		"M0 -85.2295L100 -85.2295L100 14.7705L0 14.7705Z",
+		[]string{">Cow &amp; &lt;bell&gt;</text>"},
+	}}
+	for _, tc := range testCases {
+		src, err := ivg.Encode(tc.g)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc.desc, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := ivg2xcode.WriteSymbolTemplate(buf, src, tc.opts); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		got := buf.String()
+		for _, weight := range []string{"Ultralight", "Regular", "Black"} {
+			if !strings.Contains(got, `<g id="`+weight+`-S" transform="matrix(1 0 0 1 `) {
+				t.Errorf("%s: output has no %s-S glyph", tc.desc, weight)
+			}
+		}
+		ds := []string(nil)
+		for _, line := range strings.Split(got, "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, `<path d="`) {
+				ds = append(ds, strings.TrimSuffix(strings.TrimPrefix(line, `<path d="`), `"/>`))
+			}
+		}
+		if len(ds) != 3 {
+			t.Errorf("%s: got %d paths, want 3", tc.desc, len(ds))
+			continue
+		}
+		if (ds[0] != ds[1]) || (ds[0] != ds[2]) {
+			t.Errorf("%s: got different paths for different weights", tc.desc)
+		}
+		if tc.wantD != "" && ds[0] != tc.wantD {
+			t.Errorf("%s: got %q, want %q", tc.desc, ds[0], tc.wantD)
+		}
+		if n := strings.Count(ds[0], "M"); n != 1 {
+			t.Errorf("%s: got %d sub-paths, want 1", tc.desc, n)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output does not contain %q:\n%s", tc.desc, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgasm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Assemble converts assembly language source to IconVG byte-code.
+//
+// It does not check that the result is a valid IconVG graphic. Statements are
+// assembled in order, regardless of whether the decoder would be in the
+// styling or drawing mode at that point.
+func Assemble(src []byte) ([]byte, error) {
+	dst, checksumAt := []byte(nil), 0
+	for i, line := range strings.Split(string(src), "\n") {
+		err := error(nil)
+		if dst, err = assembleLine(dst, line, &checksumAt); err != nil {
+			return nil, fmt.Errorf("ivgasm: line %d: %v", i+1, err)
+		}
+	}
+	if checksumAt > 0 {
+		putChecksum(dst, checksumAt)
+	}
+	return dst, nil
+}
+
+// assembleLine appends the byte-code for a single line of assembly language
+// to dst. If the line is the first checksum statement without an operand, it
+// sets *checksumAt to where that checksum goes, for Assemble to compute.
+func assembleLine(dst []byte, line string, checksumAt *int) ([]byte, error) {
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return dst, nil
+	}
+	op, args := fields[0], fields[1:]
+
+	switch op {
+	case "bytes":
+		for _, arg := range args {
+			b, err := hex.DecodeString(arg)
+			if err != nil {
+				return dst, fmt.Errorf("invalid bytes %q", arg)
+			}
+			dst = append(dst, b...)
+		}
+		return dst, nil
+
+	case "magic":
+		if err := wantArgs(op, args, 0); err != nil {
+			return dst, err
+		}
+		return append(dst, magic...), nil
+
+	case "metadata":
+		if err := wantArgs(op, args, 1); err != nil {
+			return dst, err
+		}
+		return appendNumber(dst, kindNatural, args[0])
+
+	case "viewBox":
+		if err := wantArgs(op, args, 4); err != nil {
+			return dst, err
+		}
+		body, err := appendNumbers([]byte{midViewBox << 1}, kindCoordinate, args)
+		if err != nil {
+			return dst, err
+		}
+		return appendChunk(dst, body), nil
+
+	case "text":
+		if err := wantArgs(op, args, 2); err != nil {
+			return dst, err
+		}
+		body := []byte{midTitleAndDescription << 1}
+		for _, arg := range args {
+			s, err := strconv.Unquote(arg)
+			if err != nil || len(s) >= 1<<30 {
+				return dst, fmt.Errorf("invalid text %q", arg)
+			}
+			body, _ = appendNatural(body, uint32(len(s)), naturalWidth(uint32(len(s))))
+			body = append(body, s...)
+		}
+		return appendChunk(dst, body), nil
+
+	case "checksum":
+		if len(args) == 0 {
+			if *checksumAt == 0 {
+				*checksumAt = len(dst) + 2
+			}
+			return append(dst, 0x0a, midChecksum<<1, 0, 0, 0, 0), nil
+		}
+		if err := wantArgs(op, args, 1); err != nil {
+			return dst, err
+		}
+		u, err := strconv.ParseUint(args[0], 16, 32)
+		if err != nil || len(args[0]) != 8 {
+			return dst, fmt.Errorf("invalid checksum %q", args[0])
+		}
+		return append(dst, 0x0a, midChecksum<<1, uint8(u), uint8(u>>8), uint8(u>>16), uint8(u>>24)), nil
+
+	case "levels":
+		body, _ := appendNatural([]byte{midDetailLevels << 1}, uint32(len(args)), naturalWidth(uint32(len(args))))
+		body, err := appendNumbers(body, kindNatural, args)
+		if err != nil {
+			return dst, err
+		}
+		return appendChunk(dst, body), nil
+
+	case "csel", "nsel":
+		if err := wantArgs(op, args, 1); err != nil {
+			return dst, err
+		}
+		i, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil || i >= 64 {
+			return dst, fmt.Errorf("invalid %s %q", op, args[0])
+		}
+		if op == "nsel" {
+			i |= 0x40
+		}
+		return append(dst, uint8(i)), nil
+
+	case "lod":
+		if err := wantArgs(op, args, 2); err != nil {
+			return dst, err
+		}
+		return appendNumbers(append(dst, 0xc7), kindReal, args)
+
+	case "path":
+		if err := wantArgs(op, args, 3); err != nil {
+			return dst, err
+		}
+		adj, err := parseSel(args[0], "csel", false)
+		if err != nil {
+			return dst, err
+		}
+		return appendNumbers(append(dst, 0xc0+adj), kindCoordinate, args[1:])
+
+	case "z":
+		if err := wantArgs(op, args, 0); err != nil {
+			return dst, err
+		}
+		return append(dst, 0xe1), nil
+	}
+
+	for i, o := range singleDrawingOps {
+		if o.name == op {
+			if err := wantArgs(op, args, o.nNumbers); err != nil {
+				return dst, err
+			}
+			return appendNumbers(append(dst, 0xe0+uint8(i)), kindCoordinate, args)
+		}
+	}
+	for i, o := range drawingOps {
+		if o.name == op {
+			return appendDrawing(dst, uint8(i), args)
+		}
+	}
+
+	if prefix, suffix, ok := cut(op, '.'); ok {
+		switch prefix {
+		case "palette":
+			return appendPalette(dst, suffix, args)
+		case "creg":
+			return appendSetCReg(dst, suffix, args)
+		case "nreg":
+			return appendSetNReg(dst, suffix, args)
+		}
+	}
+	return dst, fmt.Errorf("unknown statement %q", op)
+}
+
+func appendPalette(dst []byte, suffix string, args []string) ([]byte, error) {
+	width, err := strconv.Atoi(suffix)
+	if err != nil || width < 1 || 4 < width {
+		return dst, fmt.Errorf("invalid palette color width %q", suffix)
+	}
+	if len(args) < 1 || 64 < len(args) {
+		return dst, fmt.Errorf("palette has %d colors, want between 1 and 64", len(args))
+	}
+	body := []byte{midSuggestedPalette << 1, uint8(width-1)<<6 | uint8(len(args)-1)}
+	for _, arg := range args {
+		if body, err = appendColor(body, arg, width); err != nil {
+			return dst, err
+		}
+	}
+	return appendChunk(dst, body), nil
+}
+
+func appendSetCReg(dst []byte, suffix string, args []string) ([]byte, error) {
+	k, nArgs := 0, 2
+	switch suffix {
+	case "1", "2", "3", "4":
+		k = int(suffix[0] - '1')
+	case "blend":
+		k, nArgs = 4, 4
+	default:
+		return dst, fmt.Errorf("unknown statement %q", "creg."+suffix)
+	}
+	if err := wantArgs("creg."+suffix, args, nArgs); err != nil {
+		return dst, err
+	}
+	adj, err := parseSel(args[0], "csel", true)
+	if err != nil {
+		return dst, err
+	}
+	dst = append(dst, 0x80+uint8(k<<3)+adj)
+
+	if k < 4 {
+		return appendColor(dst, args[1], k+1)
+	}
+	t, err := strconv.ParseUint(args[1], 10, 8)
+	if err != nil {
+		return dst, fmt.Errorf("invalid blend weight %q", args[1])
+	}
+	dst = append(dst, uint8(t))
+	if dst, err = appendColor(dst, args[2], 1); err != nil {
+		return dst, err
+	}
+	return appendColor(dst, args[3], 1)
+}
+
+func appendSetNReg(dst []byte, suffix string, args []string) ([]byte, error) {
+	for k, nk := range nregKinds {
+		if nk.name != suffix {
+			continue
+		}
+		if err := wantArgs("nreg."+suffix, args, 2); err != nil {
+			return dst, err
+		}
+		adj, err := parseSel(args[0], "nsel", true)
+		if err != nil {
+			return dst, err
+		}
+		return appendNumber(append(dst, 0xa8+uint8(k<<3)+adj), nk.kind, args[1])
+	}
+	return dst, fmt.Errorf("unknown statement %q", "nreg."+suffix)
+}
+
+// appendDrawing appends a drawing opcode with repeated operands. i indexes
+// drawingOps.
+func appendDrawing(dst []byte, i uint8, args []string) ([]byte, error) {
+	o, maxReps := drawingOps[i], 16
+	if i < 4 {
+		maxReps = 32
+	}
+	nReps := len(args) / o.nNumbers
+	if (len(args)%o.nNumbers != 0) || (nReps < 1) || (maxReps < nReps) {
+		return dst, fmt.Errorf("%s has %d arguments, want a multiple of %d, up to %d",
+			o.name, len(args), o.nNumbers, maxReps*o.nNumbers)
+	}
+	dst = append(dst, i<<4+uint8(nReps-1))
+
+	err := error(nil)
+	for ; len(args) > 0; args = args[o.nNumbers:] {
+		if o.nNumbers != 7 {
+			if dst, err = appendNumbers(dst, kindCoordinate, args[:o.nNumbers]); err != nil {
+				return dst, err
+			}
+			continue
+		}
+
+		// We have an absolute or relative arcTo.
+		if dst, err = appendNumbers(dst, kindCoordinate, args[0:2]); err != nil {
+			return dst, err
+		}
+		if dst, err = appendNumber(dst, kindZeroToOne, args[2]); err != nil {
+			return dst, err
+		}
+		flags := uint8(0)
+		for j, arg := range args[3:5] {
+			switch arg {
+			case "0":
+			case "1":
+				flags |= 1 << j
+			default:
+				return dst, fmt.Errorf("invalid arc flag %q", arg)
+			}
+		}
+		dst = append(dst, flags<<1)
+		if dst, err = appendNumbers(dst, kindCoordinate, args[5:7]); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+// appendChunk appends a metadata chunk, prefixed by its length, to dst.
+func appendChunk(dst []byte, body []byte) []byte {
+	dst, _ = appendNatural(dst, uint32(len(body)), naturalWidth(uint32(len(body))))
+	return append(dst, body...)
+}
+
+func appendNumbers(dst []byte, k numberKind, args []string) ([]byte, error) {
+	err := error(nil)
+	for _, arg := range args {
+		if dst, err = appendNumber(dst, k, arg); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+// appendNumber appends the number s, of the given kind and with an optional
+// ":1", ":2" or ":4" width suffix, to dst.
+func appendNumber(dst []byte, k numberKind, s string) ([]byte, error) {
+	v, width := s, 0
+	if prefix, suffix, ok := cut(s, ':'); ok {
+		switch suffix {
+		case "1", "2", "4":
+			v, width = prefix, int(suffix[0]-'0')
+		default:
+			return dst, fmt.Errorf("invalid number %q", s)
+		}
+	}
+
+	u := uint32(0)
+	if k == kindNatural {
+		x, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return dst, fmt.Errorf("invalid number %q", s)
+		}
+		u = uint32(x)
+		if width == 0 {
+			width = naturalWidth(u)
+		}
+	} else {
+		x, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return dst, fmt.Errorf("invalid number %q", s)
+		}
+		f, ok := float32(x), false
+		if width == 0 {
+			width = numberWidth(k, f)
+		}
+		if u, ok = toNatural(k, f, width); !ok {
+			return dst, fmt.Errorf("cannot encode number %q in %d bytes", v, width)
+		}
+	}
+
+	dst, ok := appendNatural(dst, u, width)
+	if !ok {
+		return dst, fmt.Errorf("cannot encode number %q in %d bytes", v, width)
+	}
+	return dst, nil
+}
+
+// parseSel parses a register operand such as "[csel-2]", returning the
+// opcode's low 3 bits.
+func parseSel(s string, name string, incrOK bool) (adj uint8, err error) {
+	switch s {
+	case "[" + name + "]":
+		return 0, nil
+	case "[" + name + "++]":
+		if incrOK {
+			return 7, nil
+		}
+	}
+	if strings.HasPrefix(s, "["+name+"-") && strings.HasSuffix(s, "]") {
+		if i, err := strconv.ParseUint(s[len(name)+2:len(s)-1], 10, 8); err == nil && i < 7 {
+			return uint8(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid register operand %q", s)
+}
+
+func wantArgs(op string, args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s has %d arguments, want %d", op, len(args), n)
+	}
+	return nil
+}
+
+// cut slices s around the last instance of sep.
+func cut(s string, sep byte) (before, after string, found bool) {
+	if i := strings.LastIndexByte(s, sep); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var dc1Table = [5]byte{0x00, 0x40, 0x80, 0xc0, 0xff}
+
+// rgba1 returns the RGBA value of a 1 byte color x, which must be less than
+// 0x80.
+func rgba1(x byte) [4]byte {
+	switch x {
+	case 125:
+		return [4]byte{0xc0, 0xc0, 0xc0, 0xc0}
+	case 126:
+		return [4]byte{0x80, 0x80, 0x80, 0x80}
+	case 127:
+		return [4]byte{0x00, 0x00, 0x00, 0x00}
+	}
+	return [4]byte{dc1Table[x/25], dc1Table[(x/5)%5], dc1Table[x%5], 0xff}
+}
+
+func formatRGBA(c [4]byte) string {
+	if c[3] == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c[0], c[1], c[2], c[3])
+}
+
+// formatColor returns the assembly text for a 1, 2, 3 (direct) or 4 byte
+// color, whose encoding is b.
+func formatColor(b []byte) string {
+	switch len(b) {
+	case 1:
+		switch x := b[0]; {
+		case x < 0x80:
+			return formatRGBA(rgba1(x))
+		case x < 0xc0:
+			return fmt.Sprintf("pal[%d]", x&0x3f)
+		default:
+			return fmt.Sprintf("creg[%d]", x&0x3f)
+		}
+	case 2:
+		return formatRGBA([4]byte{
+			0x11 * (b[0] >> 4),
+			0x11 * (b[0] & 0x0f),
+			0x11 * (b[1] >> 4),
+			0x11 * (b[1] & 0x0f),
+		})
+	case 3:
+		return formatRGBA([4]byte{b[0], b[1], b[2], 0xff})
+	}
+	return formatRGBA([4]byte{b[0], b[1], b[2], b[3]})
+}
+
+// appendColor appends the width byte encoding of the color s to b.
+func appendColor(b []byte, s string, width int) ([]byte, error) {
+	if width == 1 {
+		for _, prefix := range [2]string{"pal[", "creg["} {
+			if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, "]") {
+				continue
+			}
+			i, err := strconv.ParseUint(s[len(prefix):len(s)-1], 10, 8)
+			if err != nil || i >= 64 {
+				return b, fmt.Errorf("invalid color %q", s)
+			}
+			if prefix == "pal[" {
+				return append(b, 0x80|uint8(i)), nil
+			}
+			return append(b, 0xc0|uint8(i)), nil
+		}
+	}
+
+	c, ok := parseRGBA(s)
+	if !ok {
+		return b, fmt.Errorf("invalid color %q", s)
+	}
+	switch width {
+	case 1:
+		for x := byte(0); x < 0x80; x++ {
+			if rgba1(x) == c {
+				return append(b, x), nil
+			}
+		}
+	case 2:
+		if c[0]%0x11 == 0 && c[1]%0x11 == 0 && c[2]%0x11 == 0 && c[3]%0x11 == 0 {
+			return append(b, (c[0]/0x11)<<4|(c[1]/0x11), (c[2]/0x11)<<4|(c[3]/0x11)), nil
+		}
+	case 3:
+		if c[3] == 0xff {
+			return append(b, c[0], c[1], c[2]), nil
+		}
+	case 4:
+		return append(b, c[0], c[1], c[2], c[3]), nil
+	}
+	return b, fmt.Errorf("cannot encode color %q in %d bytes", s, width)
+}
+
+// parseRGBA parses "#rrggbb" or "#rrggbbaa".
+func parseRGBA(s string) (c [4]byte, ok bool) {
+	if (len(s) != 7 && len(s) != 9) || s[0] != '#' {
+		return c, false
+	}
+	c[3] = 0xff
+	for i := 0; 1+2*i < len(s); i++ {
+		x, err := strconv.ParseUint(s[1+2*i:3+2*i], 16, 8)
+		if err != nil {
+			return c, false
+		}
+		c[i] = uint8(x)
+	}
+	return c, true
+}
+
+// gradientComment returns a description of the 4 byte color c if it is a
+// gradient descriptor, or "" otherwise.
+func gradientComment(c []byte) string {
+	if c[3] != 0 || c[2]&0x80 == 0 {
+		return ""
+	}
+	shape := [2]string{"linear", "radial"}[(c[2]>>6)&0x01]
+	spread := [4]string{"none", "pad", "reflect", "repeat"}[c[1]>>6]
+	return fmt.Sprintf("gradient (NSTOPS=%d, CBASE=%d, NBASE=%d, %s, %s)",
+		c[0]&0x3f, c[1]&0x3f, c[2]&0x3f, shape, spread)
+}
@@ -0,0 +1,351 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Disassemble writes the assembly language form of the IconVG byte-code src
+// to w. Assembling that text reproduces src exactly.
+func Disassemble(w io.Writer, src []byte) error {
+	d := &disassembler{graphic: src, src: src}
+	d.disassemble()
+	_, err := w.Write(d.out)
+	return err
+}
+
+type disassembler struct {
+	graphic []byte
+	src     []byte
+	out     []byte
+	drawing bool
+
+	// sawChecksum is whether a checksum statement without an operand has
+	// been written. Assemble only computes the first such checksum.
+	sawChecksum bool
+}
+
+func (d *disassembler) disassemble() {
+	if !bytes.HasPrefix(d.src, magic) {
+		d.emitRest("invalid magic identifier")
+		return
+	}
+	d.emit(len(magic), "magic", "")
+
+	c := cursor{b: d.src}
+	nChunks, s := c.natural()
+	if c.err != "" {
+		d.emitRest("invalid number of metadata chunks")
+		return
+	}
+	d.emit(c.n, "metadata "+s, "")
+	for ; nChunks > 0; nChunks-- {
+		if !d.metadataChunk() {
+			d.emitRest("invalid metadata chunk")
+			return
+		}
+	}
+
+	for len(d.src) > 0 {
+		reason := ""
+		if d.drawing {
+			reason = d.drawingOp()
+		} else {
+			reason = d.stylingOp()
+		}
+		if reason != "" {
+			d.emitRest(reason)
+			return
+		}
+	}
+}
+
+func (d *disassembler) metadataChunk() bool {
+	length, w := decodeNatural(d.src)
+	if w == 0 || uint64(len(d.src)-w) < uint64(length) {
+		return false
+	}
+	n := w + int(length)
+	c := cursor{b: d.src[w:n]}
+	stmt := ""
+	switch mid, _ := c.natural(); {
+	case c.err != "":
+	case mid == midViewBox:
+		stmt = "viewBox " + c.numbers(kindCoordinate, 4)
+	case mid == midSuggestedPalette:
+		if b := c.bytes(1); c.err == "" {
+			width, nColors := 1+int(b[0]>>6), 1+int(b[0]&0x3f)
+			stmt = fmt.Sprintf("palette.%d", width)
+			for i := 0; i < nColors; i++ {
+				if b := c.bytes(width); c.err == "" {
+					stmt += " " + formatColor(b)
+				}
+			}
+		}
+	case mid == midTitleAndDescription:
+		stmt = "text " + c.text() + " " + c.text()
+	case mid == midDetailLevels:
+		if n, _ := c.natural(); c.err == "" && int(n) <= len(c.b) {
+			stmt = "levels"
+			if n > 0 {
+				stmt += " " + c.numbers(kindNatural, int(n))
+			}
+		}
+	case mid == midChecksum:
+		if b := c.bytes(4); c.err == "" {
+			at := len(d.graphic) - len(d.src) + w + c.n - 4
+			stmt = fmt.Sprintf("checksum %02x%02x%02x%02x", b[3], b[2], b[1], b[0])
+			if !d.sawChecksum && checksum(d.graphic, at) == binary.LittleEndian.Uint32(b) {
+				stmt, d.sawChecksum = "checksum", true
+			}
+		}
+	}
+	comment := ""
+	if c.n != len(c.b) || c.err != "" {
+		stmt, comment = "", "unsupported metadata chunk"
+	}
+	d.emit(n, stmt, comment)
+	return true
+}
+
+// stylingOp disassembles the next styling mode instruction. It returns why
+// it could not do so, or "" on success.
+func (d *disassembler) stylingOp() (reason string) {
+	op := d.src[0]
+	c := cursor{b: d.src, n: 1}
+	stmt, comment := "", ""
+	switch {
+	case op < 0x40:
+		stmt = fmt.Sprintf("csel %d", op)
+	case op < 0x80:
+		stmt = fmt.Sprintf("nsel %d", op&0x3f)
+	case op < 0xa0:
+		width := 1 + int((op-0x80)>>3)
+		if b := c.bytes(width); c.err == "" {
+			stmt = fmt.Sprintf("creg.%d %s %s", width, formatSel("csel", op), formatColor(b))
+			if width == 4 {
+				comment = gradientComment(b)
+			}
+		}
+	case op < 0xa8:
+		if b := c.bytes(3); c.err == "" {
+			stmt = fmt.Sprintf("creg.blend %s %d %s %s",
+				formatSel("csel", op), b[0], formatColor(b[1:2]), formatColor(b[2:3]))
+		}
+	case op < 0xc0:
+		nk := nregKinds[(op-0xa8)>>3]
+		stmt = fmt.Sprintf("nreg.%s %s %s", nk.name, formatSel("nsel", op), c.number(nk.kind))
+	case op < 0xc7:
+		stmt = fmt.Sprintf("path %s %s", formatSel("csel", op), c.numbers(kindCoordinate, 2))
+	case op == 0xc7:
+		stmt = "lod " + c.numbers(kindReal, 2)
+	default:
+		return "unsupported styling opcode"
+	}
+	if c.err != "" {
+		return c.err
+	}
+	d.emit(c.n, stmt, comment)
+	if 0xc0 <= op && op < 0xc7 {
+		d.drawing = true
+	}
+	return ""
+}
+
+// drawingOp disassembles the next drawing mode instruction. It returns why it
+// could not do so, or "" on success.
+func (d *disassembler) drawingOp() (reason string) {
+	op := d.src[0]
+	c := cursor{b: d.src, n: 1}
+	parts := []string(nil)
+	if op < 0xe0 {
+		i := op >> 4
+		o, nReps := drawingOps[i], 1+int(op&0x0f)
+		if i < 4 {
+			nReps = 1 + int(op&0x1f)
+		}
+		parts = append(parts, o.name)
+		for ; nReps > 0; nReps-- {
+			if o.nNumbers != 7 {
+				parts = append(parts, c.numbers(kindCoordinate, o.nNumbers))
+				continue
+			}
+			radii := c.numbers(kindCoordinate, 2)
+			angle := c.number(kindZeroToOne)
+			flags, _ := c.natural()
+			parts = append(parts, radii, angle,
+				fmt.Sprintf("%d %d", (flags>>0)&0x01, (flags>>1)&0x01),
+				c.numbers(kindCoordinate, 2))
+		}
+	} else if i := op - 0xe0; int(i) < len(singleDrawingOps) && singleDrawingOps[i].name != "" {
+		o := singleDrawingOps[i]
+		parts = append(parts, o.name)
+		if o.nNumbers > 0 {
+			parts = append(parts, c.numbers(kindCoordinate, o.nNumbers))
+		}
+	} else {
+		return "unsupported drawing opcode"
+	}
+	if c.err != "" {
+		return c.err
+	}
+	d.emit(c.n, strings.Join(parts, " "), "")
+	if op == 0xe1 {
+		d.drawing = false
+	}
+	return ""
+}
+
+// emit writes stmt as the disassembly of the next n source bytes. If
+// assembling stmt would not reproduce those bytes exactly, it writes a bytes
+// statement instead, annotated by comment if stmt is empty.
+func (d *disassembler) emit(n int, stmt string, comment string) {
+	b := d.src[:n]
+	d.src = d.src[n:]
+	checksumAt := 0
+	got, err := assembleLine(nil, stmt, &checksumAt)
+	if checksumAt > 0 && len(got) == len(b) {
+		// metadataChunk has already checked that the checksum is correct.
+		copy(got[checksumAt:], b[checksumAt:checksumAt+4])
+	}
+	if (err != nil) || !bytes.Equal(got, b) {
+		if stmt != "" {
+			comment = "non-canonical encoding"
+		}
+		stmt = formatBytes(b)
+	}
+	if d.drawing {
+		d.out = append(d.out, "    "...)
+	}
+	d.out = append(d.out, stmt...)
+	if comment != "" {
+		d.out = append(d.out, " ; "...)
+		d.out = append(d.out, comment...)
+	}
+	d.out = append(d.out, '\n')
+}
+
+// emitRest writes the remaining source bytes as bytes statements.
+func (d *disassembler) emitRest(comment string) {
+	for len(d.src) > 0 {
+		n := len(d.src)
+		if n > 16 {
+			n = 16
+		}
+		d.emit(n, formatBytes(d.src[:n]), comment)
+		comment = ""
+	}
+}
+
+func formatBytes(b []byte) string {
+	s := []byte("bytes")
+	for _, x := range b {
+		s = append(s, fmt.Sprintf(" %02x", x)...)
+	}
+	return string(s)
+}
+
+// formatSel formats the register operand of a styling opcode.
+func formatSel(name string, op byte) string {
+	switch adj := op & 0x07; adj {
+	case 0:
+		return "[" + name + "]"
+	case 7:
+		return "[" + name + "++]"
+	default:
+		return fmt.Sprintf("[%s-%d]", name, adj)
+	}
+}
+
+// cursor reads an instruction's operands. After the first failed read, err is
+// non-empty and subsequent reads return zero values.
+type cursor struct {
+	b   []byte
+	n   int
+	err string
+}
+
+func (c *cursor) bytes(n int) []byte {
+	if c.err != "" {
+		return nil
+	} else if len(c.b)-c.n < n {
+		c.err = "truncated instruction"
+		return nil
+	}
+	c.n += n
+	return c.b[c.n-n : c.n]
+}
+
+func (c *cursor) natural() (u uint32, s string) {
+	if c.err != "" {
+		return 0, ""
+	}
+	u, w := decodeNatural(c.b[c.n:])
+	if w == 0 {
+		c.err = "truncated instruction"
+		return 0, ""
+	}
+	c.n += w
+	return u, formatNumber(kindNatural, u, w)
+}
+
+func (c *cursor) number(k numberKind) string {
+	if c.err != "" {
+		return ""
+	}
+	u, w := decodeNatural(c.b[c.n:])
+	if w == 0 {
+		c.err = "truncated instruction"
+		return ""
+	}
+	c.n += w
+	return formatNumber(k, u, w)
+}
+
+// text reads a length-prefixed string and formats it for a text statement.
+// It fails unless the length is in its shortest encoding, as a text statement
+// cannot reproduce any other.
+func (c *cursor) text() string {
+	if c.err != "" {
+		return ""
+	}
+	u, w := decodeNatural(c.b[c.n:])
+	if w == 0 || w != naturalWidth(u) {
+		c.err = "unsupported text"
+		return ""
+	}
+	c.n += w
+	b := c.bytes(int(u))
+	if c.err != "" {
+		return ""
+	}
+	s := strconv.Quote(string(b))
+	s = strings.Replace(s, " ", `\x20`, -1)
+	return strings.Replace(s, ";", `\x3b`, -1)
+}
+
+func (c *cursor) numbers(k numberKind, n int) string {
+	s := make([]string, n)
+	for i := range s {
+		s[i] = c.number(k)
+	}
+	return strings.Join(s, " ")
+}
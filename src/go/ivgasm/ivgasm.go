@@ -0,0 +1,305 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgasm converts between IconVG byte-code and a human-readable
+// assembly language.
+//
+// Unlike the lowlevel package's Disassemble output, which is a commentary on
+// the bytes, the assembly language is itself a source format: Assemble turns
+// it back into byte-code, and Disassemble followed by Assemble reproduces the
+// original bytes exactly, even for non-canonical or invalid input.
+//
+// The assembly language is line oriented, with one statement per line. A
+// semicolon starts a comment that runs to the end of the line. Blank lines and
+// indentation are ignored. Each statement produces bytes in order:
+//
+//	magic                              the "\x89IVG" magic identifier
+//	metadata N                         the number of metadata chunks
+//	viewBox minX minY maxX maxY        a viewBox metadata chunk
+//	palette.W color...                 a suggested palette metadata chunk
+//	text "title" "description"         a title and description metadata chunk
+//	checksum [XXXXXXXX]                a checksum metadata chunk
+//	levels end...                      a detail levels metadata chunk
+//	csel N                             Set CSEL = N
+//	nsel N                             Set NSEL = N
+//	creg.W [csel-A] color              Set CREG[CSEL-A] to a color
+//	creg.blend [csel-A] T color color  Set CREG[CSEL-A] to a blended color
+//	nreg.K [nsel-A] number             Set NREG[NSEL-A] to a number
+//	lod lod0 lod1                      Set LOD
+//	path [csel-A] x y                  Start path; M (absolute moveTo)
+//	L x y ...                          (and l, T, t, Q, q, S, s, C, c)
+//	A rx ry angle largeArc sweep x y   (and a)
+//	H x                                (and h, V, v)
+//	zM x y                             z (closePath); M (absolute moveTo)
+//	zm x y                             z (closePath); m (relative moveTo)
+//	z                                  z (closePath); end path
+//	bytes XX...                        literal bytes, in hexadecimal
+//
+// W is a color's encoded width in bytes: 1, 2, 3 or 4. K is the kind of
+// number: real, coord or zero-to-one. The register operand [csel-A] may also
+// be written as [csel] (meaning [csel-0]) or, for creg statements, [csel++]
+// (meaning [csel-0] followed by CSEL++), and similarly for nsel.
+//
+// Drawing statements have one opcode's worth of repetitions: L with six
+// operands is a single opcode with 3 reps. The opcode limits how many reps a
+// statement may have: 32 for L and l and 16 for the others.
+//
+// Colors are written as #rrggbb (opaque), #rrggbbaa, pal[N] (a custom palette
+// index, 1 byte colors only) or creg[N] (a CREG index, 1 byte colors only). T
+// is the blend weight, from 0 to 255.
+//
+// A checksum statement without an operand holds the graphic's correct
+// CRC-32C, which Assemble computes once the whole graphic is assembled. With
+// an operand, it holds that value, in hexadecimal, even if it is wrong.
+// Disassemble only writes the operand for a wrong checksum.
+//
+// A text statement's strings are double-quoted with Go escapes. They contain
+// no literal spaces or semicolons: Disassemble writes those as \x20 and \x3b.
+//
+// Numbers are written in decimal and encoded in as few bytes as possible. A
+// ":1", ":2" or ":4" suffix, such as "48:4", forces a particular encoding
+// width. Disassemble only writes such suffixes when the original encoding was
+// not the shortest one.
+//
+// Anything that the other statements cannot reproduce exactly, such as a
+// truncated instruction or an unsupported opcode, is disassembled as a bytes
+// statement.
+package ivgasm
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"strconv"
+)
+
+var magic = []byte("\x89IVG")
+
+const (
+	midViewBox             = 0
+	midSuggestedPalette    = 1
+	midTitleAndDescription = 2
+	midChecksum            = 3
+	midDetailLevels        = 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the CRC-32C of the graphic b, computed as if the 4 byte
+// checksum at b[at:at+4] were zero.
+func checksum(b []byte, at int) uint32 {
+	c := crc32.Update(0, castagnoli, b[:at])
+	c = crc32.Update(c, castagnoli, make([]byte, 4))
+	return crc32.Update(c, castagnoli, b[at+4:])
+}
+
+// putChecksum sets the 4 byte checksum at b[at:at+4].
+func putChecksum(b []byte, at int) {
+	binary.LittleEndian.PutUint32(b[at:], checksum(b, at))
+}
+
+// numberKind is how a number's encoded natural number maps to its value.
+type numberKind uint8
+
+const (
+	kindNatural numberKind = iota
+	kindReal
+	kindCoordinate
+	kindZeroToOne
+)
+
+var nregKinds = [3]struct {
+	name string
+	kind numberKind
+}{
+	{"real", kindReal},
+	{"coord", kindCoordinate},
+	{"zero-to-one", kindZeroToOne},
+}
+
+// drawingOps lists the drawing opcodes that take repeated operands, indexed
+// by the opcode's high nibble (after folding 0x1? into 0x0? and 0x3? into
+// 0x2?).
+var drawingOps = [14]struct {
+	name     string
+	nNumbers int
+}{
+	{"L", 2}, {"L", 2}, {"l", 2}, {"l", 2},
+	{"T", 2}, {"t", 2}, {"Q", 4}, {"q", 4},
+	{"S", 4}, {"s", 4}, {"C", 6}, {"c", 6},
+	{"A", 7}, {"a", 7},
+}
+
+// singleDrawingOps lists the drawing opcodes, from 0xe0 onwards, that take no
+// repetitions. A zero nNumbers with an empty name means an unsupported opcode.
+var singleDrawingOps = [10]struct {
+	name     string
+	nNumbers int
+}{
+	1: {"z", 0},
+	2: {"zM", 2},
+	3: {"zm", 2},
+	6: {"H", 1},
+	7: {"h", 1},
+	8: {"V", 1},
+	9: {"v", 1},
+}
+
+// decodeNatural returns the natural number encoded at the start of b and the
+// width of that encoding, or a zero width if b is too short.
+func decodeNatural(b []byte) (u uint32, width int) {
+	if len(b) < 1 {
+		return 0, 0
+	}
+	x := b[0]
+	if x&0x01 == 0 {
+		return uint32(x) >> 1, 1
+	}
+	if x&0x02 == 0 {
+		if len(b) >= 2 {
+			y := uint16(b[0]) | uint16(b[1])<<8
+			return uint32(y) >> 2, 2
+		}
+		return 0, 0
+	}
+	if len(b) >= 4 {
+		y := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+		return y >> 2, 4
+	}
+	return 0, 0
+}
+
+// appendNatural appends u, encoded in width bytes, to b. It returns false if
+// u is too large for that width.
+func appendNatural(b []byte, u uint32, width int) ([]byte, bool) {
+	switch width {
+	case 1:
+		if u < 1<<7 {
+			return append(b, uint8(u<<1)), true
+		}
+	case 2:
+		if u < 1<<14 {
+			u = (u << 2) | 1
+			return append(b, uint8(u), uint8(u>>8)), true
+		}
+	case 4:
+		if u < 1<<30 {
+			u = (u << 2) | 3
+			return append(b, uint8(u), uint8(u>>8), uint8(u>>16), uint8(u>>24)), true
+		}
+	}
+	return b, false
+}
+
+// naturalWidth returns the shortest encoding width for u.
+func naturalWidth(u uint32) int {
+	if u < 1<<7 {
+		return 1
+	} else if u < 1<<14 {
+		return 2
+	}
+	return 4
+}
+
+// toFloat returns the value of a number of the given kind whose encoding is
+// the natural number u in width bytes. It matches the lowlevel package's
+// decoder.
+func toFloat(k numberKind, u uint32, width int) float32 {
+	if width == 4 {
+		return math.Float32frombits(u << 2)
+	}
+	switch k {
+	case kindCoordinate:
+		if width == 1 {
+			return float32(int32(u) - 64)
+		}
+		return float32(int32(u)-64*128) / 64
+	case kindZeroToOne:
+		if width == 1 {
+			return float32(u) / 120
+		}
+		return float32(u) / 15120
+	}
+	return float32(u)
+}
+
+// toNatural returns the natural number that encodes f, as a number of the
+// given kind, in width bytes. It returns false if f cannot be encoded exactly
+// in 1 or 2 bytes. 4 byte encodings round f's fractional bits.
+func toNatural(k numberKind, f float32, width int) (u uint32, ok bool) {
+	if width == 4 {
+		// Round the fractional bits (the low 23 bits) to the nearest multiple
+		// of 4, being careful not to overflow into the upper bits.
+		u := math.Float32bits(f)
+		v := u & 0x007fffff
+		if v < 0x007ffffe {
+			v += 2
+		}
+		return ((u & 0xff800000) | v) >> 2, true
+	}
+
+	c, limit := float64(f), float64(1<<7)
+	if width == 2 {
+		limit = 1 << 14
+	}
+	switch k {
+	case kindCoordinate:
+		if width == 1 {
+			c += 64
+		} else {
+			c = c*64 + 64*128
+		}
+	case kindZeroToOne:
+		if width == 1 {
+			c *= 120
+		} else {
+			c *= 15120
+		}
+	}
+	c = math.Round(c)
+	if !(0 <= c && c < limit) {
+		return 0, false
+	}
+	u = uint32(c)
+	if math.Float32bits(toFloat(k, u, width)) != math.Float32bits(f) {
+		return 0, false
+	}
+	return u, true
+}
+
+// numberWidth returns the shortest encoding width for f.
+func numberWidth(k numberKind, f float32) int {
+	for width := 1; width < 4; width *= 2 {
+		if _, ok := toNatural(k, f, width); ok {
+			return width
+		}
+	}
+	return 4
+}
+
+// formatNumber returns the assembly text for a number of the given kind whose
+// encoding is the natural number u in width bytes.
+func formatNumber(k numberKind, u uint32, width int) string {
+	s, shortest := "", 0
+	if k == kindNatural {
+		s, shortest = strconv.FormatUint(uint64(u), 10), naturalWidth(u)
+	} else {
+		f := toFloat(k, u, width)
+		s, shortest = strconv.FormatFloat(float64(f), 'g', -1, 32), numberWidth(k, f)
+	}
+	if width != shortest {
+		s += ":" + strconv.Itoa(width)
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgasm_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestAssemble(t *testing.T) {
+	testCases := []struct {
+		src     string
+		want    []byte
+		wantErr bool
+	}{
+		{"magic\nmetadata 0\n", []byte{0x89, 0x49, 0x56, 0x47, 0x00}, false},
+		{"viewBox -24 -24 24 24", []byte{0x0a, 0x00, 0x50, 0x50, 0xb0, 0xb0}, false},
+		{"csel 10 ; a comment", []byte{0x0a}, false},
+		{"  nsel 10", []byte{0x4a}, false},
+		{"path [csel] 1 2\nz", []byte{0xc0, 0x82, 0x84, 0xe1}, false},
+		{"L 1 2 3 4", []byte{0x01, 0x82, 0x84, 0x86, 0x88}, false},
+		{"L 1 2:2", []byte{0x00, 0x82, 0x01, 0x82}, false},
+		{"bytes 01ff", []byte{0x01, 0xff}, false},
+		{`text "Info" "D\xc3\xa9tails"`, []byte{
+			0x1e, 0x04,
+			0x08, 0x49, 0x6e, 0x66, 0x6f,
+			0x10, 0x44, 0xc3, 0xa9, 0x74, 0x61, 0x69, 0x6c, 0x73,
+		}, false},
+		{`text "" ""`, []byte{0x06, 0x04, 0x00, 0x00}, false},
+		{"checksum 01020304", []byte{0x0a, 0x06, 0x04, 0x03, 0x02, 0x01}, false},
+		{"levels 4 8", []byte{0x08, 0x08, 0x04, 0x08, 0x10}, false},
+		{"levels", []byte{0x04, 0x08, 0x00}, false},
+		{"bogus", nil, true},
+		{"csel", nil, true},
+		{"L 1", nil, true},
+		{"creg.5 [csel] #000000", nil, true},
+		{`text "Info"`, nil, true},
+		{`text Info ""`, nil, true},
+		{"checksum 0102", nil, true},
+		{"checksum 0102030g", nil, true},
+		{"checksum 01020304 05060708", nil, true},
+		{"levels -1", nil, true},
+	}
+	for _, tc := range testCases {
+		got, err := ivgasm.Assemble([]byte(tc.src))
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.src, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !bytes.Equal(got, tc.want) {
+			t.Errorf("%q: got % x, want % x", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestAssembleChecksum(t *testing.T) {
+	testCases := []struct {
+		src     string
+		wantErr bool
+	}{
+		{"magic\nmetadata 1\nchecksum\npath [csel] 1 2\nL 3 4\nz", false},
+		{"magic\nmetadata 2\nviewBox -24 -24 24 24\nchecksum\npath [csel] 1 2\nL 3 4\nz", false},
+		{"magic\nmetadata 1\nchecksum 01020304\npath [csel] 1 2\nL 3 4\nz", true},
+	}
+	for _, tc := range testCases {
+		src, err := ivgasm.Assemble([]byte(tc.src))
+		if err != nil {
+			t.Errorf("%q: Assemble: %v", tc.src, err)
+			continue
+		}
+		err = lowlevel.Decode(lowlevel.NopDestination{}, src, nil)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.src, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	cowbell, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksummed, err := lowlevel.AddChecksum(cowbell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongChecksum := append([]byte(nil), checksummed...)
+	wrongChecksum[len(wrongChecksum)-2] ^= 0x40
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"truncated", cowbell[:len(cowbell)-3]},
+		{"bad magic identifier", []byte("\x89IVH\x00")},
+		{"unsupported opcode", []byte("\x89IVG\x00\xff\xfe")},
+		{"non-canonical number", []byte("\x89IVG\x00\xc0\x01\x00\x01\x00\xe1")},
+		{"text with spaces and semicolons", []byte("\x89IVG\x02\x10\x04\x06a b\x04;;")},
+		{"non-canonical text length", []byte("\x89IVG\x02\x0a\x04\x05\x00a\x00")},
+		{"checksum", checksummed},
+		{"wrong checksum", wrongChecksum},
+		{"short checksum", []byte("\x89IVG\x02\x08\x06\x00\x00\x00")},
+		{"detail levels", []byte("\x89IVG\x02\x06\x08\x02\x08\xc0\x82\x84\xe1")},
+		{"invalid detail levels", []byte("\x89IVG\x02\x06\x08\x7e\x08\xc0\x82\x84\xe1")},
+	}
+	for _, filename := range []string{
+		"action-info.hires.ivg",
+		"action-info.lores.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient-spreads.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testCases = append(testCases, struct {
+			desc string
+			src  []byte
+		}{filename, src})
+	}
+	for _, tc := range testCases {
+		asm := &bytes.Buffer{}
+		if err := ivgasm.Disassemble(asm, tc.src); err != nil {
+			t.Errorf("%s: Disassemble: %v", tc.desc, err)
+			continue
+		}
+		got, err := ivgasm.Assemble(asm.Bytes())
+		if err != nil {
+			t.Errorf("%s: Assemble: %v\n%s", tc.desc, err, asm.Bytes())
+			continue
+		}
+		if !bytes.Equal(got, tc.src) {
+			t.Errorf("%s: got % x, want % x", tc.desc, got, tc.src)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgbundle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+var errIsDirectory = errors.New("ivgbundle: is a directory")
+
+// A Bundle is an fs.FS, so that code that reads files, such as
+// http.FileServer (via http.FS) and the iconset package, can read a bundle's
+// graphics instead. Its entries' names are the file names, and the
+// directories are implied by those names. For example, the iconset package
+// looks for names that end with ".ivg".
+var (
+	_ fs.FS         = (*Bundle)(nil)
+	_ fs.ReadDirFS  = (*Bundle)(nil)
+	_ fs.ReadFileFS = (*Bundle)(nil)
+	_ fs.StatFS     = (*Bundle)(nil)
+)
+
+// Open opens the named graphic, or directory, as per fs.FS. A graphic's
+// fs.File also implements io.ReaderAt and io.Seeker. A compressed graphic is
+// decompressed into memory.
+func (b *Bundle) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if i, ok := b.byName[name]; ok {
+		e := &b.entries[i]
+		r := (*io.SectionReader)(nil)
+		if e.Method == Store {
+			r = io.NewSectionReader(b.r, e.offset, e.CompressedSize)
+		} else if data, err := b.read(e); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		} else {
+			r = io.NewSectionReader(bytes.NewReader(data), 0, e.Size)
+		}
+		return &file{
+			SectionReader: r,
+			info:          fileInfo{name: path.Base(name), size: e.Size},
+		}, nil
+	}
+	if children, ok := b.dirs[name]; ok {
+		return &dir{
+			path:     name,
+			info:     fileInfo{name: path.Base(name), isDir: true},
+			children: children,
+		}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile returns the named graphic, decompressing it if necessary, as per
+// fs.ReadFileFS.
+func (b *Bundle) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	i, ok := b.byName[name]
+	if !ok {
+		err := fs.ErrNotExist
+		if _, ok := b.dirs[name]; ok {
+			err = errIsDirectory
+		}
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	data, err := b.read(&b.entries[i])
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// ReadDir returns the named directory's entries, sorted by name, as per
+// fs.ReadDirFS.
+func (b *Bundle) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	children, ok := b.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]fs.DirEntry(nil), children...), nil
+}
+
+// Stat returns the named graphic's or directory's fs.FileInfo, as per
+// fs.StatFS.
+func (b *Bundle) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if i, ok := b.byName[name]; ok {
+		return &fileInfo{name: path.Base(name), size: b.entries[i].Size}, nil
+	} else if _, ok := b.dirs[name]; ok {
+		return &fileInfo{name: path.Base(name), isDir: true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// fileInfo is both the fs.FileInfo and the fs.DirEntry for a graphic or a
+// directory. Bundles do not record modification times or permissions.
+type fileInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (fi *fileInfo) Name() string               { return fi.name }
+func (fi *fileInfo) Size() int64                { return fi.size }
+func (fi *fileInfo) ModTime() time.Time         { return time.Time{} }
+func (fi *fileInfo) IsDir() bool                { return fi.isDir }
+func (fi *fileInfo) Sys() interface{}           { return nil }
+func (fi *fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// file is an open graphic.
+type file struct {
+	*io.SectionReader
+	info fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return &f.info, nil }
+func (f *file) Close() error               { return nil }
+
+// dir is an open directory.
+type dir struct {
+	path     string
+	info     fileInfo
+	children []fs.DirEntry
+
+	// offset is how many children ReadDir has returned.
+	offset int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return &d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errIsDirectory}
+}
+
+// ReadDir returns the directory's next n children, as per fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.children[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.children)
+		return append([]fs.DirEntry(nil), rest...), nil
+	} else if len(rest) == 0 {
+		return nil, io.EOF
+	} else if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return append([]fs.DirEntry(nil), rest[:n]...), nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+
+package ivgbundle_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgbundle"
+)
+
+// FuzzNew checks that reading an arbitrary bundle, and every entry in it,
+// fails gracefully instead of panicking or allocating without bound.
+func FuzzNew(f *testing.F) {
+	src := []byte("\x89IVG\x00\x00")
+	for _, m := range []ivgbundle.Method{ivgbundle.Store, ivgbundle.Deflate} {
+		buf := &bytes.Buffer{}
+		w, err := ivgbundle.NewWriter(buf)
+		if err != nil {
+			f.Fatal(err)
+		}
+		w.Method = m
+		if err := w.Add("a", src); err != nil {
+			f.Fatal(err)
+		} else if err := w.Add("b/c", src); err != nil {
+			f.Fatal(err)
+		} else if err := w.Close(); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, err := ivgbundle.New(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		for i := 0; i < b.Len(); i++ {
+			e := b.Entry(i)
+			if got, err := b.ReadFile(e.Name); (err == nil) && (int64(len(got)) != e.Size) {
+				t.Fatalf("ReadFile(%q): got %d bytes, want %d", e.Name, len(got), e.Size)
+			}
+		}
+	})
+}
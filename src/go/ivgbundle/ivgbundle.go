@@ -0,0 +1,358 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgbundle reads and writes bundles: single files that hold many
+// named IconVG graphics, so that an application can ship one asset file
+// instead of hundreds of tiny ones.
+//
+// Bundles are not part of the IconVG specification. A bundle is:
+//
+//   - the magic identifier "\x89IVB",
+//   - the entries' payloads, concatenated,
+//   - the index: a uvarint number of entries N, then N index entries,
+//   - the index's offset, as an 8 byte little-endian integer.
+//
+// Each index entry is a uvarint name length, the name, a Method byte, a
+// uvarint payload length and a uvarint uncompressed length. Payloads are in
+// index order, so their offsets are implied. Keeping the index at the end
+// lets Append add entries without moving the existing payloads, and lets a
+// reader find any entry without reading the others.
+//
+// Uvarints are as in the encoding/binary package.
+//
+// Payloads are stored uncompressed or compressed with DEFLATE, which the
+// standard library implements. Zstandard compression is not built in, as it
+// would make the IconVG module depend on a third-party implementation. The
+// Zstd Method is reserved for it, and a program can supply an implementation
+// with RegisterCompressor and RegisterDecompressor.
+package ivgbundle
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+var (
+	errDuplicateName      = errors.New("ivgbundle: duplicate name")
+	errInvalidBundle      = errors.New("ivgbundle: invalid bundle")
+	errInvalidGraphic     = errors.New("ivgbundle: invalid IconVG graphic")
+	errInvalidName        = errors.New("ivgbundle: invalid name")
+	errUncompressedLength = errors.New("ivgbundle: invalid uncompressed length")
+	errUnsupportedMethod  = errors.New("ivgbundle: unsupported compression method")
+	errWriterClosed       = errors.New("ivgbundle: Writer is closed")
+)
+
+const (
+	magic = "\x89IVB"
+
+	// trailerLength is the length of the index offset that ends a bundle.
+	trailerLength = 8
+
+	// maxPreallocation is the largest buffer, in bytes, that is allocated for
+	// reading an entry before reading its payload. IconVG graphics are
+	// typically far smaller.
+	maxPreallocation = 1 << 20
+)
+
+// Method is how an entry's payload is compressed.
+type Method uint8
+
+const (
+	// Store means no compression.
+	Store Method = 0
+
+	// Deflate is the DEFLATE compression of the compress/flate package.
+	Deflate Method = 1
+
+	// Zstd is Zstandard compression. This package does not implement it, to
+	// avoid the dependency, so a program that writes or reads Zstd entries
+	// must first call RegisterCompressor or RegisterDecompressor with a Zstd
+	// implementation, such as the github.com/klauspost/compress/zstd
+	// package's.
+	Zstd Method = 2
+)
+
+// Compressor returns a new compressing writer, writing to w. Closing the
+// writer flushes it.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor returns a new decompressing reader, reading from r.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+var (
+	codecsMu      sync.RWMutex
+	compressors   = map[Method]Compressor{}
+	decompressors = map[Method]Decompressor{}
+)
+
+func init() {
+	compressors[Deflate] = func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	}
+	decompressors[Deflate] = flate.NewReader
+}
+
+// RegisterCompressor registers a Compressor for a Method, replacing any
+// previously registered one. Store needs no Compressor.
+func RegisterCompressor(m Method, c Compressor) {
+	codecsMu.Lock()
+	compressors[m] = c
+	codecsMu.Unlock()
+}
+
+// RegisterDecompressor registers a Decompressor for a Method, replacing any
+// previously registered one. Store needs no Decompressor.
+func RegisterDecompressor(m Method, d Decompressor) {
+	codecsMu.Lock()
+	decompressors[m] = d
+	codecsMu.Unlock()
+}
+
+func compressor(m Method) Compressor {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return compressors[m]
+}
+
+func decompressor(m Method) Decompressor {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return decompressors[m]
+}
+
+// Entry is a named graphic in a bundle.
+type Entry struct {
+	Name   string
+	Method Method
+
+	// CompressedSize and Size are the lengths of the stored payload and of
+	// the IconVG graphic.
+	CompressedSize int64
+	Size           int64
+
+	// offset is the payload's position in the bundle.
+	offset int64
+}
+
+// Bundle is a bundle, read through an io.ReaderAt.
+//
+// A Bundle is safe for concurrent use by multiple goroutines, if its
+// io.ReaderAt is.
+type Bundle struct {
+	r       io.ReaderAt
+	entries []Entry
+	byName  map[string]int
+
+	// dirs maps each directory implied by the entries' names, including the
+	// root ".", to its sorted children.
+	dirs map[string][]fs.DirEntry
+
+	// indexOffset is where the index starts, after the last payload.
+	indexOffset int64
+}
+
+// New returns a Bundle for the bundle in r, which has the given size.
+// It reads the index, but not the payloads.
+func New(r io.ReaderAt, size int64) (*Bundle, error) {
+	if size < int64(len(magic)+trailerLength) {
+		return nil, errInvalidBundle
+	}
+	var trailer [trailerLength]byte
+	if _, err := r.ReadAt(trailer[:], size-trailerLength); err != nil {
+		return nil, err
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(trailer[:]))
+	if (indexOffset < int64(len(magic))) || (indexOffset > size-trailerLength) {
+		return nil, errInvalidBundle
+	}
+
+	header := make([]byte, len(magic))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	} else if string(header) != magic {
+		return nil, errInvalidBundle
+	}
+	index := make([]byte, size-trailerLength-indexOffset)
+	if _, err := r.ReadAt(index, indexOffset); err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		r:           r,
+		indexOffset: indexOffset,
+	}
+	if err := b.decodeIndex(index); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// decodeIndex decodes the index, whose payloads are before b.indexOffset.
+func (b *Bundle) decodeIndex(index []byte) error {
+	n, err := readUvarint(&index)
+	if err != nil {
+		return err
+	}
+	// Each index entry is at least 4 bytes long, so an impossibly large
+	// number of entries is rejected before allocating for them.
+	if n > uint64(len(index))/4 {
+		return errInvalidBundle
+	}
+	b.entries = make([]Entry, n)
+	b.byName = make(map[string]int, n)
+	offset := int64(len(magic))
+	for i := range b.entries {
+		e := &b.entries[i]
+		nameLen, err := readUvarint(&index)
+		if err != nil {
+			return err
+		} else if nameLen >= uint64(len(index)) {
+			return errInvalidBundle
+		}
+		e.Name = string(index[:nameLen])
+		e.Method = Method(index[nameLen])
+		index = index[nameLen+1:]
+		if !fs.ValidPath(e.Name) {
+			return errInvalidBundle
+		} else if _, ok := b.byName[e.Name]; ok {
+			return errInvalidBundle
+		}
+		b.byName[e.Name] = i
+
+		compressedSize, err := readUvarint(&index)
+		if err != nil {
+			return err
+		} else if compressedSize > uint64(b.indexOffset-offset) {
+			return errInvalidBundle
+		}
+		size, err := readUvarint(&index)
+		if err != nil {
+			return err
+		} else if (e.Method == Store) && (size != compressedSize) {
+			return errInvalidBundle
+		} else if size >= 1<<62 {
+			return errInvalidBundle
+		}
+		e.CompressedSize, e.Size, e.offset = int64(compressedSize), int64(size), offset
+		offset += e.CompressedSize
+	}
+	if (len(index) != 0) || (offset != b.indexOffset) {
+		return errInvalidBundle
+	}
+	return b.buildDirs()
+}
+
+// buildDirs builds b.dirs. It fails if a name is both a file and a directory.
+func (b *Bundle) buildDirs() error {
+	b.dirs = map[string][]fs.DirEntry{".": nil}
+	for i := range b.entries {
+		e := &b.entries[i]
+		name, child := e.Name, fs.DirEntry(&fileInfo{name: path.Base(e.Name), size: e.Size})
+		for name != "." {
+			dir := path.Dir(name)
+			if _, ok := b.byName[dir]; ok {
+				return errInvalidBundle
+			}
+			children, ok := b.dirs[dir]
+			b.dirs[dir] = append(children, child)
+			if ok {
+				break
+			}
+			name, child = dir, &fileInfo{name: path.Base(dir), isDir: true}
+		}
+	}
+	for dir, children := range b.dirs {
+		if _, ok := b.byName[dir]; ok {
+			return errInvalidBundle
+		}
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Name() < children[j].Name()
+		})
+	}
+	return nil
+}
+
+func readUvarint(b *[]byte) (uint64, error) {
+	u, n := binary.Uvarint(*b)
+	if n <= 0 {
+		return 0, errInvalidBundle
+	}
+	*b = (*b)[n:]
+	return u, nil
+}
+
+// Len returns the number of entries.
+func (b *Bundle) Len() int {
+	return len(b.entries)
+}
+
+// Entry returns the i'th entry, in the order that they were written, for
+// 0 <= i < Len().
+func (b *Bundle) Entry(i int) Entry {
+	return b.entries[i]
+}
+
+// Lookup returns the named entry and whether it exists.
+func (b *Bundle) Lookup(name string) (Entry, bool) {
+	if i, ok := b.byName[name]; ok {
+		return b.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// payload returns a reader for e's payload, decompressing it if necessary.
+func (b *Bundle) payload(e *Entry) (io.ReadCloser, error) {
+	r := io.NewSectionReader(b.r, e.offset, e.CompressedSize)
+	if e.Method == Store {
+		return io.NopCloser(r), nil
+	}
+	d := decompressor(e.Method)
+	if d == nil {
+		return nil, errUnsupportedMethod
+	}
+	return d(r), nil
+}
+
+// read returns e's graphic.
+func (b *Bundle) read(e *Entry) ([]byte, error) {
+	rc, err := b.payload(e)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Read one byte more than expected, to detect a payload that
+	// decompresses to more than its recorded size. The index's Size is not
+	// trusted for more than maxPreallocation bytes of the buffer's initial
+	// capacity: a corrupt index could claim any size. The buffer grows
+	// beyond that as the payload is read.
+	n := e.Size + 1
+	if n > maxPreallocation {
+		n = maxPreallocation
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, n))
+	if _, err := io.Copy(buf, io.LimitReader(rc, e.Size+1)); err != nil {
+		return nil, err
+	} else if int64(buf.Len()) != e.Size {
+		return nil, errUncompressedLength
+	}
+	return buf.Bytes(), nil
+}
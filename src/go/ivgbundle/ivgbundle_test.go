@@ -0,0 +1,415 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgbundle_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/iconvg/src/go/ivgbundle"
+)
+
+// readTestData returns the test/data IconVG graphics, keyed by file name.
+func readTestData(t *testing.T) map[string][]byte {
+	filenames, err := filepath.Glob("../../../test/data/*.ivg")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(filenames) == 0 {
+		t.Fatal("no test data files found")
+	}
+	graphics := map[string][]byte{}
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		graphics[filepath.Base(filename)] = src
+	}
+	return graphics
+}
+
+func TestRoundTrip(t *testing.T) {
+	graphics := readTestData(t)
+	buf := &bytes.Buffer{}
+	w, err := ivgbundle.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string(nil)
+	for filename, src := range graphics {
+		// Alternate between the built-in methods, and between the root
+		// directory and a subdirectory.
+		name := filename
+		if len(names)%2 == 0 {
+			w.Method = ivgbundle.Store
+		} else {
+			w.Method = ivgbundle.Deflate
+			name = "icons/" + filename
+		}
+		if err := w.Add(name, src); err != nil {
+			t.Fatalf("Add(%q): %v", name, err)
+		}
+		names = append(names, name)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ivgbundle.New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.Len(), len(names); got != want {
+		t.Fatalf("Len: got %d, want %d", got, want)
+	}
+	for _, name := range names {
+		got, err := b.ReadFile(name)
+		if err != nil {
+			t.Errorf("ReadFile(%q): %v", name, err)
+		} else if want := graphics[filepath.Base(name)]; !bytes.Equal(got, want) {
+			t.Errorf("ReadFile(%q): got %d bytes, want %d", name, len(got), len(want))
+		}
+	}
+	if err := fstest.TestFS(b, names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	graphics := readTestData(t)
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.ivb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := ivgbundle.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("cowbell.ivg", graphics["cowbell.ivg"]); err != nil {
+		t.Fatal(err)
+	} else if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = ivgbundle.Append(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Method = ivgbundle.Deflate
+	if err := w.Add("cowbell.ivg", graphics["cowbell.ivg"]); err == nil {
+		t.Error("Add(existing name): got nil error, want non-nil")
+	}
+	if err := w.Add("favicon.ivg", graphics["favicon.ivg"]); err != nil {
+		t.Fatal(err)
+	} else if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ivgbundle.New(f, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"cowbell.ivg", "favicon.ivg"} {
+		if got := b.Entry(i).Name; got != name {
+			t.Errorf("Entry(%d).Name: got %q, want %q", i, got, name)
+		}
+		if got, err := b.ReadFile(name); err != nil {
+			t.Errorf("ReadFile(%q): %v", name, err)
+		} else if !bytes.Equal(got, graphics[name]) {
+			t.Errorf("ReadFile(%q): contents differ", name)
+		}
+	}
+}
+
+// indexEntry is an index entry, for constructing bundles by hand.
+type indexEntry struct {
+	name           string
+	method         ivgbundle.Method
+	compressedSize uint64
+	size           uint64
+}
+
+// rawBundle returns a bundle with the given payloads and index entries,
+// which need not be consistent.
+func rawBundle(magic string, payloads []byte, entries ...indexEntry) []byte {
+	b := append([]byte(magic), payloads...)
+	b = appendUvarint(b, uint64(len(entries)))
+	for _, e := range entries {
+		b = appendUvarint(b, uint64(len(e.name)))
+		b = append(b, e.name...)
+		b = append(b, byte(e.method))
+		b = appendUvarint(b, e.compressedSize)
+		b = appendUvarint(b, e.size)
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint64(trailer[:], uint64(len(magic)+len(payloads)))
+	return append(b, trailer[:]...)
+}
+
+func appendUvarint(b []byte, u uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], u)]...)
+}
+
+func TestInvalidBundles(t *testing.T) {
+	deflated := &bytes.Buffer{}
+	fw, _ := flate.NewWriter(deflated, flate.BestCompression)
+	fw.Write([]byte("hello"))
+	fw.Close()
+	d := uint64(deflated.Len())
+
+	testCases := []struct {
+		desc        string
+		data        []byte
+		wantNewErr  bool
+		wantReadErr bool
+	}{
+		{"valid, stored", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 5, 5}), false, false},
+		{"valid, deflated", rawBundle("\x89IVB", deflated.Bytes(), indexEntry{"a", ivgbundle.Deflate, d, 5}), false, false},
+		{"empty", nil, true, false},
+		{"bad magic", rawBundle("\x89IVX", nil), true, false},
+		{"truncated", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 5, 5})[1:], true, false},
+		{"too many entries", append([]byte("\x89IVB\xe8\x07"), 4, 0, 0, 0, 0, 0, 0, 0), true, false},
+		{"payloads overlap the index", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 6, 6}), true, false},
+		{"stored size mismatch", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 5, 4}), true, false},
+		{"size too large", rawBundle("\x89IVB", deflated.Bytes(), indexEntry{"a", ivgbundle.Deflate, d, 1 << 62}), true, false},
+		{"duplicate name", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 5, 5}, indexEntry{"a", ivgbundle.Store, 0, 0}), true, false},
+		{"file and directory", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Store, 5, 5}, indexEntry{"a/b", ivgbundle.Store, 0, 0}), true, false},
+		{"invalid name", rawBundle("\x89IVB", []byte("hello"), indexEntry{"../a", ivgbundle.Store, 5, 5}), true, false},
+		// A corrupt index's size must not be trusted for allocating.
+		{"huge size", rawBundle("\x89IVB", deflated.Bytes(), indexEntry{"a", ivgbundle.Deflate, d, 1 << 61}), false, true},
+		{"size too small", rawBundle("\x89IVB", deflated.Bytes(), indexEntry{"a", ivgbundle.Deflate, d, 4}), false, true},
+		{"unregistered method", rawBundle("\x89IVB", []byte("hello"), indexEntry{"a", ivgbundle.Zstd, 5, 5}), false, true},
+	}
+	for _, tc := range testCases {
+		b, err := ivgbundle.New(bytes.NewReader(tc.data), int64(len(tc.data)))
+		if gotErr := err != nil; gotErr != tc.wantNewErr {
+			t.Errorf("%s: New: got %v, want error %t", tc.desc, err, tc.wantNewErr)
+			continue
+		} else if gotErr {
+			continue
+		}
+		got, err := b.ReadFile("a")
+		if gotErr := err != nil; gotErr != tc.wantReadErr {
+			t.Errorf("%s: ReadFile: got %v, want error %t", tc.desc, err, tc.wantReadErr)
+		} else if !gotErr && (string(got) != "hello") {
+			t.Errorf("%s: ReadFile: got %q, want %q", tc.desc, got, "hello")
+		}
+	}
+}
+
+func TestReadFileNotExist(t *testing.T) {
+	data := rawBundle("\x89IVB", []byte("hello"), indexEntry{"a/b", ivgbundle.Store, 5, 5})
+	b, err := ivgbundle.New(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b", "a", "a/c", "/a/b"} {
+		if _, err := b.ReadFile(name); err == nil {
+			t.Errorf("ReadFile(%q): got nil error, want non-nil", name)
+		} else if !strings.Contains(err.Error(), name) {
+			t.Errorf("ReadFile(%q): got %v, want an error that names the file", name, err)
+		}
+	}
+}
+
+// testBundle returns a bundle with stored and deflated entries, in the root
+// directory and in nested directories, and the entries' contents.
+func testBundle(t *testing.T) (*ivgbundle.Bundle, map[string][]byte) {
+	t.Helper()
+	graphics := readTestData(t)
+	contents := map[string][]byte{
+		"root.ivg":          graphics["cowbell.ivg"],
+		"icons/a.ivg":       graphics["favicon.ivg"],
+		"icons/b.ivg":       graphics["arcs.ivg"],
+		"icons/large/c.ivg": graphics["gradient.ivg"],
+	}
+	buf := &bytes.Buffer{}
+	w, err := ivgbundle.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct {
+		name   string
+		method ivgbundle.Method
+	}{
+		{"root.ivg", ivgbundle.Store},
+		{"icons/a.ivg", ivgbundle.Deflate},
+		{"icons/b.ivg", ivgbundle.Store},
+		{"icons/large/c.ivg", ivgbundle.Deflate},
+	} {
+		w.Method = x.method
+		if err := w.Add(x.name, contents[x.name]); err != nil {
+			t.Fatalf("Add(%q): %v", x.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ivgbundle.New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, contents
+}
+
+func TestReadDir(t *testing.T) {
+	b, _ := testBundle(t)
+	testCases := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{".", "icons/ root.ivg", false},
+		{"icons", "a.ivg b.ivg large/", false},
+		{"icons/large", "c.ivg", false},
+		{"root.ivg", "", true},
+		{"missing", "", true},
+		{"/icons", "", true},
+	}
+	for _, tc := range testCases {
+		entries, err := fs.ReadDir(b, tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.name, err, tc.wantErr)
+			continue
+		}
+		var names []string
+		for _, e := range entries {
+			n := e.Name()
+			if e.IsDir() {
+				n += "/"
+			}
+			names = append(names, n)
+		}
+		if got := strings.Join(names, " "); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStat(t *testing.T) {
+	b, contents := testBundle(t)
+	testCases := []struct {
+		name      string
+		wantName  string
+		wantSize  int64
+		wantIsDir bool
+		wantErr   error
+	}{
+		{".", ".", 0, true, nil},
+		{"root.ivg", "root.ivg", int64(len(contents["root.ivg"])), false, nil},
+		{"icons/a.ivg", "a.ivg", int64(len(contents["icons/a.ivg"])), false, nil},
+		{"icons/large", "large", 0, true, nil},
+		{"icons/missing.ivg", "", 0, false, fs.ErrNotExist},
+		{"icons/", "", 0, false, fs.ErrInvalid},
+	}
+	for _, tc := range testCases {
+		fi, err := fs.Stat(b, tc.name)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%q: got %v, want %v", tc.name, err, tc.wantErr)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: %v", tc.name, err)
+			continue
+		}
+		if fi.Name() != tc.wantName || fi.Size() != tc.wantSize || fi.IsDir() != tc.wantIsDir {
+			t.Errorf("%q: got (%q, %d, %t), want (%q, %d, %t)", tc.name,
+				fi.Name(), fi.Size(), fi.IsDir(), tc.wantName, tc.wantSize, tc.wantIsDir)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	b, contents := testBundle(t)
+	for _, name := range []string{"root.ivg", "icons/a.ivg"} {
+		f, err := b.Open(name)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		want := string(contents[name])
+		// Stored and deflated graphics both support io.ReaderAt and
+		// io.Seeker.
+		got := make([]byte, 8)
+		if _, err := f.(io.ReaderAt).ReadAt(got, 3); err != nil || string(got) != want[3:11] {
+			t.Errorf("%q: ReadAt: got %q, %v, want %q", name, got, err, want[3:11])
+		}
+		if _, err := f.(io.Seeker).Seek(-4, io.SeekEnd); err != nil {
+			t.Errorf("%q: Seek: %v", name, err)
+		} else if rest, err := io.ReadAll(f); err != nil || string(rest) != want[len(want)-4:] {
+			t.Errorf("%q: read after Seek: got %q, %v, want %q", name, rest, err, want[len(want)-4:])
+		}
+		f.Close()
+	}
+
+	d, err := b.Open("icons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Read(make([]byte, 1)); err == nil {
+		t.Errorf("reading a directory: got nil error, want non-nil")
+	}
+	if _, err := b.ReadFile("icons"); err == nil {
+		t.Errorf("ReadFile of a directory: got nil error, want non-nil")
+	}
+}
+
+func TestWriterNameConflicts(t *testing.T) {
+	src := readTestData(t)["blank.ivg"]
+	testCases := []struct {
+		desc    string
+		names   []string
+		wantErr bool
+	}{
+		{"siblings", []string{"a/b", "a/c"}, false},
+		{"cousins", []string{"a/b/c", "a/d/e"}, false},
+		{"duplicate", []string{"a", "a"}, true},
+		{"file then descendant", []string{"a", "a/b"}, true},
+		{"file then deep descendant", []string{"a", "a/b/c"}, true},
+		{"descendant then file", []string{"a/b", "a"}, true},
+		{"deep descendant then file", []string{"a/b/c", "a/b"}, true},
+	}
+	for _, tc := range testCases {
+		w, err := ivgbundle.NewWriter(io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = nil
+		for _, name := range tc.names {
+			if err = w.Add(name, src); err != nil {
+				break
+			}
+		}
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgbundle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Writer writes a bundle. Its Method applies to subsequently added entries.
+// Close must be called after the final Add, to write the index.
+type Writer struct {
+	Method Method
+
+	w       io.Writer
+	offset  int64
+	entries []Entry
+	closed  bool
+
+	// files and dirs are the names of the entries and of the directories
+	// that those names imply, which must not overlap.
+	files map[string]bool
+	dirs  map[string]bool
+
+	// buf holds a compressed payload.
+	buf bytes.Buffer
+}
+
+// NewWriter returns a Writer that writes a new bundle to w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		offset: int64(len(magic)),
+		files:  map[string]bool{},
+		dirs:   map[string]bool{},
+	}, nil
+}
+
+// File is what Append needs to modify a bundle in place. An *os.File opened
+// for reading and writing implements it.
+type File interface {
+	io.ReaderAt
+	io.WriteSeeker
+}
+
+// Append returns a Writer that adds entries to the existing bundle in f. It
+// overwrites the old index, so that f is not a valid bundle again until the
+// Writer is closed. The new index lists the existing entries first.
+func Append(f File) (*Writer, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	r, err := New(f, size)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(r.indexOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		w:       f,
+		offset:  r.indexOffset,
+		entries: r.entries,
+		files:   make(map[string]bool, len(r.entries)),
+		dirs:    map[string]bool{},
+	}
+	for _, e := range r.entries {
+		w.claim(e.Name)
+	}
+	return w, nil
+}
+
+// Add adds a named IconVG graphic, or an animation, to the bundle. Names are
+// slash-separated paths, as for the io/fs package. They must be unique, and
+// no name can be a directory of another, such as "a" and "a/b".
+func (w *Writer) Add(name string, graphic []byte) error {
+	if w.closed {
+		return errWriterClosed
+	} else if !fs.ValidPath(name) {
+		return errInvalidName
+	} else if !w.available(name) {
+		return errDuplicateName
+	} else if !lowlevel.IsAnimation(graphic) {
+		if _, err := lowlevel.DecodeMetadata(graphic); err != nil {
+			return errInvalidGraphic
+		}
+	}
+
+	payload := graphic
+	if w.Method != Store {
+		c := compressor(w.Method)
+		if c == nil {
+			return errUnsupportedMethod
+		}
+		w.buf.Reset()
+		cw, err := c(&w.buf)
+		if err != nil {
+			return err
+		}
+		if _, err := cw.Write(graphic); err != nil {
+			return err
+		} else if err := cw.Close(); err != nil {
+			return err
+		}
+		payload = w.buf.Bytes()
+	}
+
+	if _, err := w.w.Write(payload); err != nil {
+		return err
+	}
+	w.entries = append(w.entries, Entry{
+		Name:           name,
+		Method:         w.Method,
+		CompressedSize: int64(len(payload)),
+		Size:           int64(len(graphic)),
+		offset:         w.offset,
+	})
+	w.claim(name)
+	w.offset += int64(len(payload))
+	return nil
+}
+
+// available returns whether name is neither an entry, a directory nor a
+// descendant of an entry.
+func (w *Writer) available(name string) bool {
+	if w.files[name] || w.dirs[name] {
+		return false
+	}
+	for d := path.Dir(name); d != "."; d = path.Dir(d) {
+		if w.files[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// claim records an entry's name and the directories that it implies.
+func (w *Writer) claim(name string) {
+	w.files[name] = true
+	for d := path.Dir(name); (d != ".") && !w.dirs[d]; d = path.Dir(d) {
+		w.dirs[d] = true
+	}
+}
+
+// Close writes the index. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	w.closed = true
+
+	index := appendUvarint(nil, uint64(len(w.entries)))
+	for _, e := range w.entries {
+		index = appendUvarint(index, uint64(len(e.Name)))
+		index = append(index, e.Name...)
+		index = append(index, byte(e.Method))
+		index = appendUvarint(index, uint64(e.CompressedSize))
+		index = appendUvarint(index, uint64(e.Size))
+	}
+	var trailer [trailerLength]byte
+	binary.LittleEndian.PutUint64(trailer[:], uint64(w.offset))
+	index = append(index, trailer[:]...)
+	_, err := w.w.Write(index)
+	return err
+}
+
+func appendUvarint(b []byte, u uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], u)]...)
+}
@@ -0,0 +1,326 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgdiff compares IconVG graphics, both structurally (their decoded
+// shapes, paints and path segments) and as rendered pixels.
+//
+// It is intended for golden-file tests of icon pipelines, where a change in
+// the encoded bytes matters less than a change in what is drawn.
+package ivgdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"reflect"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// DefaultSize is the default Options.Size.
+const DefaultSize = 64
+
+// Options are the optional parameters to Compare.
+type Options struct {
+	// Size is the width and height, in pixels, of the renderings that are
+	// compared. Zero means DefaultSize.
+	Size int
+
+	// Tolerance is the largest per-channel difference, in the range [0, 255],
+	// between two pixels that are still considered the same.
+	Tolerance uint8
+}
+
+// Result is the result of comparing two IconVG graphics, a and b.
+type Result struct {
+	// SameBytes is whether a and b are byte-for-byte identical.
+	SameBytes bool
+
+	// Metadata describes differences in the viewBox and suggested palette.
+	Metadata []string
+
+	// Shapes describes the shapes that differ, in order.
+	Shapes []ShapeDiff
+
+	// Pixels is the number of pixels of the renderings that differ by more
+	// than the Tolerance, out of TotalPixels.
+	Pixels      int
+	TotalPixels int
+}
+
+// Equal returns whether a and b render the same, within the Tolerance, and
+// have the same metadata.
+func (r *Result) Equal() bool {
+	return (len(r.Metadata) == 0) && (r.Pixels == 0)
+}
+
+// ShapeDiff describes a difference between a shape of a and a shape of b.
+type ShapeDiff struct {
+	// A and B are the shape indexes in a and b. One of them is -1 if the
+	// shape was added or removed.
+	A, B int
+
+	// Paint, LOD and Path are whether the shapes' paints, levels of detail
+	// and paths differ. If Path, Segment is the index of the first differing
+	// path segment.
+	Paint   bool
+	LOD     bool
+	Path    bool
+	Segment int
+
+	// Pixels is the number of pixels that differ, by more than the
+	// Tolerance, between renderings of each shape on its own. A changed
+	// shape may render the same, for example if its path was re-encoded
+	// with equivalent segments.
+	Pixels int
+}
+
+func (d ShapeDiff) String() string {
+	buf := &bytes.Buffer{}
+	switch {
+	case d.B < 0:
+		fmt.Fprintf(buf, "shape a[%d]: removed", d.A)
+	case d.A < 0:
+		fmt.Fprintf(buf, "shape b[%d]: added", d.B)
+	default:
+		fmt.Fprintf(buf, "shape a[%d] b[%d]: changed", d.A, d.B)
+		if d.Paint {
+			buf.WriteString(" paint")
+		}
+		if d.LOD {
+			buf.WriteString(" lod")
+		}
+		if d.Path {
+			fmt.Fprintf(buf, " path (from segment %d)", d.Segment)
+		}
+	}
+	if d.Pixels == 0 {
+		buf.WriteString("; renders the same")
+	} else {
+		fmt.Fprintf(buf, "; %d pixels differ", d.Pixels)
+	}
+	return buf.String()
+}
+
+// Compare compares the IconVG graphics a and b.
+//
+// opts may be nil, which means to use the default options.
+func Compare(a, b []byte, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	c := &comparer{size: size, tolerance: opts.Tolerance}
+
+	ga, err := ivg.Decode(a, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ivgdiff: a: %v", err)
+	}
+	gb, err := ivg.Decode(b, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ivgdiff: b: %v", err)
+	}
+
+	r := &Result{
+		SameBytes:   bytes.Equal(a, b),
+		TotalPixels: size * size,
+	}
+	ma, mb := &ga.Metadata, &gb.Metadata
+	if ma.ViewBox != mb.ViewBox {
+		r.Metadata = append(r.Metadata, fmt.Sprintf("viewBox: %v vs %v", ma.ViewBox, mb.ViewBox))
+	}
+	if ma.Title != mb.Title {
+		r.Metadata = append(r.Metadata, fmt.Sprintf("title: %q vs %q", ma.Title, mb.Title))
+	}
+	if ma.Description != mb.Description {
+		r.Metadata = append(r.Metadata, fmt.Sprintf("description: %q vs %q", ma.Description, mb.Description))
+	}
+	for i := range ma.Palette {
+		if pa, pb := ma.Palette[i], mb.Palette[i]; pa != pb {
+			r.Metadata = append(r.Metadata, fmt.Sprintf("palette[%d]: %02x%02x%02x%02x vs %02x%02x%02x%02x",
+				i, pa.R, pa.G, pa.B, pa.A, pb.R, pb.G, pb.B, pb.A))
+		}
+	}
+
+	if r.Pixels, err = c.comparePixels(a, b); err != nil {
+		return nil, err
+	}
+	if r.Shapes, err = c.compareShapes(ga, gb); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type comparer struct {
+	size      int
+	tolerance uint8
+}
+
+// comparePixels renders a and b and returns the number of pixels that differ.
+func (c *comparer) comparePixels(a, b []byte) (int, error) {
+	ia, err := render.Image(a, c.size, nil)
+	if err != nil {
+		return 0, err
+	}
+	ib, err := render.Image(b, c.size, nil)
+	if err != nil {
+		return 0, err
+	}
+	return c.countPixels(ia, ib), nil
+}
+
+func (c *comparer) countPixels(ia, ib *image.RGBA) int {
+	n := 0
+	for i := 0; i < len(ia.Pix); i += 4 {
+		for j := i; j < i+4; j++ {
+			d := int(ia.Pix[j]) - int(ib.Pix[j])
+			if (d > int(c.tolerance)) || (-d > int(c.tolerance)) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// compareShapes aligns the shapes of ga and gb, using their longest common
+// subsequence, and describes the shapes that are not in that subsequence.
+func (c *comparer) compareShapes(ga, gb *ivg.Graphic) ([]ShapeDiff, error) {
+	sa, sb := ga.Shapes, gb.Shapes
+
+	// lcs[i][j] is the length of the longest common subsequence of sa[i:]
+	// and sb[j:].
+	lcs := make([][]int, len(sa)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(sb)+1)
+	}
+	for i := len(sa) - 1; i >= 0; i-- {
+		for j := len(sb) - 1; j >= 0; j-- {
+			if sameShape(&sa[i], &sb[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the subsequence. Between matching shapes, pair up the unmatched
+	// shapes of a and b as changes, and report any left over as removals or
+	// additions.
+	diffs := []ShapeDiff(nil)
+	gapA, gapB := []int(nil), []int(nil)
+	flush := func() error {
+		for k := 0; (k < len(gapA)) || (k < len(gapB)); k++ {
+			d := ShapeDiff{A: -1, B: -1}
+			if k < len(gapA) {
+				d.A = gapA[k]
+			}
+			if k < len(gapB) {
+				d.B = gapB[k]
+			}
+			if err := c.describe(&d, ga, gb); err != nil {
+				return err
+			}
+			diffs = append(diffs, d)
+		}
+		gapA, gapB = gapA[:0], gapB[:0]
+		return nil
+	}
+	for i, j := 0, 0; (i < len(sa)) || (j < len(sb)); {
+		switch {
+		case (i < len(sa)) && (j < len(sb)) && sameShape(&sa[i], &sb[j]):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			i, j = i+1, j+1
+		case (j == len(sb)) || ((i < len(sa)) && (lcs[i+1][j] >= lcs[i][j+1])):
+			gapA = append(gapA, i)
+			i++
+		default:
+			gapB = append(gapB, j)
+			j++
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// describe fills in what differs between the shapes d.A and d.B.
+func (c *comparer) describe(d *ShapeDiff, ga, gb *ivg.Graphic) error {
+	var sa, sb *ivg.Shape
+	if d.A >= 0 {
+		sa = &ga.Shapes[d.A]
+	}
+	if d.B >= 0 {
+		sb = &gb.Shapes[d.B]
+	}
+	if (sa != nil) && (sb != nil) {
+		d.Paint = !reflect.DeepEqual(sa.Paint, sb.Paint)
+		d.LOD = (sa.LOD0 != sb.LOD0) || (sa.LOD1 != sb.LOD1)
+		d.Segment = firstDifference(sa.Path, sb.Path)
+		d.Path = d.Segment >= 0
+	}
+
+	ia, err := c.renderShape(ga, sa)
+	if err != nil {
+		return err
+	}
+	ib, err := c.renderShape(gb, sb)
+	if err != nil {
+		return err
+	}
+	d.Pixels = c.countPixels(ia, ib)
+	return nil
+}
+
+// renderShape renders the shape s, which may be nil, on its own, with g's
+// metadata.
+func (c *comparer) renderShape(g *ivg.Graphic, s *ivg.Shape) (*image.RGBA, error) {
+	one := ivg.Graphic{Metadata: g.Metadata}
+	if s != nil {
+		one.Shapes = []ivg.Shape{*s}
+	}
+	src, err := ivg.Encode(&one)
+	if err != nil {
+		return nil, err
+	}
+	return render.Image(src, c.size, nil)
+}
+
+func sameShape(a, b *ivg.Shape) bool {
+	return (a.LOD0 == b.LOD0) && (a.LOD1 == b.LOD1) &&
+		reflect.DeepEqual(a.Paint, b.Paint) && (firstDifference(a.Path, b.Path) < 0)
+}
+
+// firstDifference returns the index of the first segment that differs between
+// two paths, or -1 if they are the same.
+func firstDifference(a, b ivg.Path) int {
+	for i := range a {
+		if (i >= len(b)) || (a[i] != b[i]) {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return len(a)
+	}
+	return -1
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgdiff_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/ivgdiff"
+)
+
+const (
+	redSquare    = "creg.3 [csel] #ff0000\npath [csel] -32 -32\nL 0 -32 0 0 -32 0\nz\n"
+	redSquareH   = "creg.3 [csel] #ff0000\npath [csel] -32 -32\nH 0\nV 0\nH -32\nz\n"
+	greenSquare  = "creg.3 [csel] #00ff00\npath [csel] -32 -32\nL 0 -32 0 0 -32 0\nz\n"
+	blueSquare   = "creg.3 [csel] #0000ff\npath [csel] 0 0\nL 32 0 32 32 0 32\nz\n"
+	offsetSquare = "creg.3 [csel] #0000ff\npath [csel] 0 0\nL 32 0 32 32 0 31\nz\n"
+)
+
+func assemble(t *testing.T, metadata string, shapes ...string) []byte {
+	t.Helper()
+	s := "magic\n" + metadata
+	for _, shape := range shapes {
+		s += shape
+	}
+	src, err := ivgasm.Assemble([]byte(s))
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	return src
+}
+
+func TestCompare(t *testing.T) {
+	const noMetadata = "metadata 0\n"
+	a := assemble(t, noMetadata, redSquare, blueSquare)
+	testCases := []struct {
+		desc          string
+		b             []byte
+		wantSameBytes bool
+		wantEqual     bool
+		wantShapes    string
+	}{{
+		desc:          "identical",
+		b:             assemble(t, noMetadata, redSquare, blueSquare),
+		wantSameBytes: true,
+		wantEqual:     true,
+		wantShapes:    "[]",
+	}, {
+		desc:       "equivalent opcodes",
+		b:          assemble(t, noMetadata, redSquareH, blueSquare),
+		wantEqual:  true,
+		wantShapes: "[]",
+	}, {
+		desc:       "changed paint",
+		b:          assemble(t, noMetadata, greenSquare, blueSquare),
+		wantShapes: "[shape a[0] b[0]: changed paint; 256 pixels differ]",
+	}, {
+		desc:       "changed path",
+		b:          assemble(t, noMetadata, redSquare, offsetSquare),
+		wantShapes: "[shape a[1] b[1]: changed path (from segment 3); 16 pixels differ]",
+	}, {
+		desc:       "removed shape",
+		b:          assemble(t, noMetadata, blueSquare),
+		wantShapes: "[shape a[0]: removed; 256 pixels differ]",
+	}, {
+		desc:       "added shape",
+		b:          assemble(t, noMetadata, redSquare, blueSquare, greenSquare),
+		wantShapes: "[shape b[2]: added; 256 pixels differ]",
+	}, {
+		desc:       "changed viewBox",
+		b:          assemble(t, "metadata 1\nviewBox -64 -64 64 64\n", redSquare, blueSquare),
+		wantShapes: "[]",
+	}}
+	for _, tc := range testCases {
+		r, err := ivgdiff.Compare(a, tc.b, &ivgdiff.Options{Size: 32})
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if r.SameBytes != tc.wantSameBytes {
+			t.Errorf("%s: SameBytes: got %t, want %t", tc.desc, r.SameBytes, tc.wantSameBytes)
+		}
+		if got := r.Equal(); got != tc.wantEqual {
+			t.Errorf("%s: Equal: got %t, want %t", tc.desc, got, tc.wantEqual)
+		}
+		if got := fmt.Sprint(r.Shapes); got != tc.wantShapes {
+			t.Errorf("%s: Shapes:\ngot  %s\nwant %s", tc.desc, got, tc.wantShapes)
+		}
+		if got, want := r.TotalPixels, 32*32; got != want {
+			t.Errorf("%s: TotalPixels: got %d, want %d", tc.desc, got, want)
+		}
+	}
+}
+
+func TestCompareMetadata(t *testing.T) {
+	a := assemble(t, "metadata 1\ntext \"Info\" \"\"\n", redSquare)
+	testCases := []struct {
+		desc     string
+		metadata string
+		want     string
+	}{
+		{"same", "metadata 1\ntext \"Info\" \"\"\n", "[]"},
+		{"changed title", "metadata 1\ntext \"Help\" \"\"\n", `[title: "Info" vs "Help"]`},
+		{"added description", "metadata 1\ntext \"Info\" \"More\"\n", `[description: "" vs "More"]`},
+		{"removed text", "metadata 0\n", `[title: "Info" vs ""]`},
+		{"changed viewBox", "metadata 2\nviewBox -64 -64 64 64\ntext \"Info\" \"\"\n",
+			"[viewBox: {[-32 -32] [32 32]} vs {[-64 -64] [64 64]}]"},
+	}
+	for _, tc := range testCases {
+		r, err := ivgdiff.Compare(a, assemble(t, tc.metadata, redSquare), &ivgdiff.Options{Size: 32})
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got := fmt.Sprint(r.Metadata); got != tc.want {
+			t.Errorf("%s: Metadata:\ngot  %s\nwant %s", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	valid := assemble(t, "metadata 0\n", redSquare)
+	invalid := []byte("not IconVG")
+	if _, err := ivgdiff.Compare(invalid, valid, nil); err == nil {
+		t.Errorf("invalid a: got nil error, want non-nil")
+	}
+	if _, err := ivgdiff.Compare(valid, invalid, nil); err == nil {
+		t.Errorf("invalid b: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgebiten draws IconVG graphics with Ebitengine
+// (https://ebitengine.org).
+//
+// An Icon is rasterized at the size, in device pixels, at which it is drawn,
+// so that it stays sharp when the window's scale changes, rather than being
+// scaled as a bitmap. The rasterization is cached, and redone only when that
+// size changes.
+//
+// This package depends on Ebitengine, which the IconVG module does not
+// require, so it is a module of its own,
+// github.com/google/iconvg/src/go/ivgebiten, with its own go.mod file.
+package ivgebiten
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// Icon is an IconVG graphic that can be drawn with Ebitengine.
+//
+// An Icon is not safe for concurrent use by multiple goroutines.
+type Icon struct {
+	src  []byte
+	opts *render.Options
+
+	// img is the cached rasterization, or nil if there is none.
+	img *ebiten.Image
+}
+
+// New returns an Icon for the IconVG graphic src.
+//
+// opts may be nil, which means to use the default options. The Icon retains
+// src and opts, which should not be modified afterwards.
+func New(src []byte, opts *render.Options) (*Icon, error) {
+	if _, err := lowlevel.DecodeMetadata(src); err != nil {
+		return nil, err
+	}
+	return &Icon{src: src, opts: opts}, nil
+}
+
+// SetOptions replaces the Icon's options, such as its palette, and discards
+// its cached rasterization.
+func (ic *Icon) SetOptions(opts *render.Options) {
+	ic.opts = opts
+	ic.img = nil
+}
+
+// Image returns the Icon rasterized to a size×size pixel image. The graphic's
+// viewBox is scaled to fit, preserving its aspect ratio, and is centered.
+//
+// The returned image remains valid until the next call to Image or Draw with
+// a different size, or to SetOptions.
+func (ic *Icon) Image(size int) (*ebiten.Image, error) {
+	if (ic.img != nil) && (ic.img.Bounds().Dx() == size) {
+		return ic.img, nil
+	}
+	m, err := render.Image(ic.src, size, ic.opts)
+	if err != nil {
+		return nil, err
+	}
+	ic.img = ebiten.NewImageFromImage(m)
+	return ic.img, nil
+}
+
+// Draw draws the Icon onto dst as a size×size pixel square, with its top-left
+// corner at (x, y), rasterizing it at that size if it is not already cached.
+//
+// Ebitengine scales the screen image up from the size that the game's Layout
+// method returns. For the Icon to be sharp on high density displays, Layout
+// should return its outside size multiplied by the monitor's device scale
+// factor, and size should be in those device pixels.
+func (ic *Icon) Draw(dst *ebiten.Image, x, y float64, size int) error {
+	img, err := ic.Image(size)
+	if err != nil {
+		return err
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(x, y)
+	dst.DrawImage(img, op)
+	return nil
+}
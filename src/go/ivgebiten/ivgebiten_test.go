@@ -0,0 +1,76 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgebiten_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgebiten"
+)
+
+func TestNew(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc    string
+		src     []byte
+		wantErr bool
+	}{
+		{"valid", src, false},
+		{"empty", nil, true},
+		{"not IconVG", []byte("not IconVG"), true},
+	}
+	for _, tc := range testCases {
+		_, err := ivgebiten.New(tc.src, nil)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic, err := ivgebiten.New(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m24, err := ic.Image(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m24.Bounds().Dx(), 24; got != want {
+		t.Errorf("width: got %d, want %d", got, want)
+	}
+	if m, err := ic.Image(24); err != nil || m != m24 {
+		t.Errorf("same size: got a different image or error %v, want the cached image", err)
+	}
+	m48, err := ic.Image(48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m48.Bounds().Dx(), 48; got != want {
+		t.Errorf("width: got %d, want %d", got, want)
+	}
+	ic.SetOptions(nil)
+	if m, err := ic.Image(48); err != nil || m == m48 {
+		t.Errorf("after SetOptions: got the cached image or error %v, want a new image", err)
+	}
+}
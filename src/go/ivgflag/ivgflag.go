@@ -0,0 +1,95 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivgflag parses the command line flag values, and the equivalent
+// query parameters, that the IconVG tools share, so that a color or a
+// palette override means the same thing to each of them.
+package ivgflag
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// ParseColor parses a CSS color, such as "#ff000080", "#f00" or "red", as
+// lowlevel.ParseColor does, returning it as alpha-premultiplied color.
+func ParseColor(s string) (color.RGBA, error) {
+	c, err := lowlevel.ParseColor(s)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	rgba, _ := c.RGBA()
+	return rgba, nil
+}
+
+// ParsePalette parses palette overrides, such as "0=#ff0000,3=#00ff0080",
+// which map custom palette indexes to colors. An empty string means no
+// overrides.
+//
+// Colors are as for ParseColor, except that the "#" of a "rrggbb" or
+// "rrggbbaa" color may be omitted, as it must be escaped in a URL.
+func ParsePalette(s string) (map[uint8]color.RGBA, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := map[uint8]color.RGBA{}
+	for _, field := range strings.Split(s, ",") {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid palette override %q", field)
+		}
+		index, err := strconv.ParseUint(strings.TrimSpace(field[:i]), 10, 8)
+		if (err != nil) || (index >= 64) {
+			return nil, fmt.Errorf("invalid palette index %q", field[:i])
+		}
+		v := strings.TrimSpace(field[i+1:])
+		c, err := ParseColor(v)
+		if (err != nil) && ((len(v) == 6) || (len(v) == 8)) {
+			if c2, err2 := ParseColor("#" + v); err2 == nil {
+				c, err = c2, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[uint8(index)] = c
+	}
+	return m, nil
+}
+
+// ParseAntialiasing parses an anti-aliasing mode name, such as "4x".
+func ParseAntialiasing(s string) (raster.Antialiasing, error) {
+	for a := raster.AntialiasingAnalytic; a <= raster.Antialiasing16x; a++ {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid anti-aliasing mode %q", s)
+}
+
+// ParseGradientQuantization parses a gradient quantization mode name, such
+// as "rgb565-dither".
+func ParseGradientQuantization(s string) (raster.GradientQuantization, error) {
+	for q := raster.GradientQuantizationNone; q <= raster.GradientQuantizationRGB565Dither; q++ {
+		if q.String() == s {
+			return q, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid gradient quantization mode %q", s)
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivgflag_test
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgflag"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+func TestParseColor(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{"#ff0000", color.RGBA{0xff, 0x00, 0x00, 0xff}, false},
+		{"#FFffFF", color.RGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#f00", color.RGBA{0xff, 0x00, 0x00, 0xff}, false},
+		{"red", color.RGBA{0xff, 0x00, 0x00, 0xff}, false},
+		// The colors are converted to alpha-premultiplied color.
+		{"#00ff0080", color.RGBA{0x00, 0x80, 0x00, 0x80}, false},
+		{"ff0000", color.RGBA{}, true},
+		{"#ff00zz", color.RGBA{}, true},
+		{"", color.RGBA{}, true},
+	}
+	for _, tc := range testCases {
+		got, err := ivgflag.ParseColor(tc.s)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    map[uint8]color.RGBA
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"0=#ff0000", map[uint8]color.RGBA{0: {0xff, 0x00, 0x00, 0xff}}, false},
+		{"0=#ff0000, 63=#00ff0080", map[uint8]color.RGBA{
+			0:  {0xff, 0x00, 0x00, 0xff},
+			63: {0x00, 0x80, 0x00, 0x80},
+		}, false},
+		// The "#" may be omitted, as in a URL.
+		{"0=ff0000,1=red", map[uint8]color.RGBA{
+			0: {0xff, 0x00, 0x00, 0xff},
+			1: {0xff, 0x00, 0x00, 0xff},
+		}, false},
+		{"64=#ff0000", nil, true},
+		{"-1=#ff0000", nil, true},
+		{"0", nil, true},
+		{"0=nonsense", nil, true},
+		{"0=f00", nil, true},
+	}
+	for _, tc := range testCases {
+		got, err := ivgflag.ParsePalette(tc.s)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseAntialiasing(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    raster.Antialiasing
+		wantErr bool
+	}{
+		{"analytic", raster.AntialiasingAnalytic, false},
+		{"none", raster.AntialiasingNone, false},
+		{"4x", raster.Antialiasing4x, false},
+		{"16x", raster.Antialiasing16x, false},
+		{"8x", 0, true},
+		{"invalid", 0, true},
+		{"", 0, true},
+	}
+	for _, tc := range testCases {
+		got, err := ivgflag.ParseAntialiasing(tc.s)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseGradientQuantization(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    raster.GradientQuantization
+		wantErr bool
+	}{
+		{"none", raster.GradientQuantizationNone, false},
+		{"rgb565", raster.GradientQuantizationRGB565, false},
+		{"rgb565-dither", raster.GradientQuantizationRGB565Dither, false},
+		{"rgb888", 0, true},
+	}
+	for _, tc := range testCases {
+		got, err := ivgflag.ParseGradientQuantization(tc.s)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tc.s, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/google/iconvg/src/go/ivggio
+
+go 1.23.8
+
+require github.com/google/iconvg v0.0.0
+
+require (
+	gioui.org v0.9.0
+	golang.org/x/image v0.26.0 // indirect
+)
+
+replace github.com/google/iconvg => ../../..
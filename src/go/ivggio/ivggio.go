@@ -0,0 +1,115 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivggio draws IconVG graphics with Gio (https://gioui.org).
+//
+// An Icon is rasterized at the size, in device pixels, at which it is laid
+// out, so that it stays sharp when the window's scale changes. The
+// rasterization is cached, and redone only when that size changes.
+//
+// This package depends on Gio, which the IconVG module does not require, so
+// it is a module of its own, github.com/google/iconvg/src/go/ivggio, with its
+// own go.mod file.
+package ivggio
+
+import (
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// Icon is an IconVG graphic that can be drawn with Gio.
+//
+// Like Gio's widgets, an Icon is not safe for concurrent use by multiple
+// goroutines.
+type Icon struct {
+	src  []byte
+	opts *render.Options
+
+	// size is the size, in pixels, of the cached rasterization, recorded as
+	// call. It is zero if there is none.
+	size int
+	ops  op.Ops
+	call op.CallOp
+}
+
+// New returns an Icon for the IconVG graphic src.
+//
+// opts may be nil, which means to use the default options. The Icon retains
+// src and opts, which should not be modified afterwards.
+func New(src []byte, opts *render.Options) (*Icon, error) {
+	if _, err := lowlevel.DecodeMetadata(src); err != nil {
+		return nil, err
+	}
+	return &Icon{src: src, opts: opts}, nil
+}
+
+// SetOptions replaces the Icon's options, such as its palette, and discards
+// its cached rasterization.
+func (ic *Icon) SetOptions(opts *render.Options) {
+	ic.opts = opts
+	ic.size = 0
+}
+
+// CallOp returns the operations that draw the Icon as a size×size pixel
+// square, with its top-left corner at the origin. The graphic's viewBox is
+// scaled to fit, preserving its aspect ratio, and is centered.
+//
+// The returned CallOp remains valid until the next call to CallOp or Layout
+// with a different size, or to SetOptions.
+func (ic *Icon) CallOp(size int) (op.CallOp, error) {
+	if size == ic.size {
+		return ic.call, nil
+	}
+	m, err := render.Image(ic.src, size, ic.opts)
+	if err != nil {
+		return op.CallOp{}, err
+	}
+	ic.ops.Reset()
+	macro := op.Record(&ic.ops)
+	cl := clip.Rect{Max: image.Point{size, size}}.Push(&ic.ops)
+	paint.NewImageOp(m).Add(&ic.ops)
+	paint.PaintOp{}.Add(&ic.ops)
+	cl.Pop()
+	ic.call = macro.Stop()
+	ic.size = size
+	return ic.call, nil
+}
+
+// Layout draws the Icon as a square whose width and height are size,
+// converted to device pixels by gtx's metric.
+func (ic *Icon) Layout(gtx layout.Context, size unit.Dp) layout.Dimensions {
+	px := gtx.Dp(size)
+	dims := layout.Dimensions{Size: gtx.Constraints.Constrain(image.Point{px, px})}
+	if px > dims.Size.X {
+		px = dims.Size.X
+	}
+	if px > dims.Size.Y {
+		px = dims.Size.Y
+	}
+	if px <= 0 {
+		return dims
+	}
+	if call, err := ic.CallOp(px); err == nil {
+		call.Add(gtx.Ops)
+	}
+	return dims
+}
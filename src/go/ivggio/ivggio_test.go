@@ -0,0 +1,98 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivggio_test
+
+import (
+	"image"
+	"os"
+	"testing"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+
+	"github.com/google/iconvg/src/go/ivggio"
+)
+
+func readIcon(t *testing.T) *ivggio.Icon {
+	t.Helper()
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic, err := ivggio.New(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ic
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"not IconVG", []byte("not IconVG")},
+	}
+	for _, tc := range testCases {
+		if _, err := ivggio.New(tc.src, nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestCallOp(t *testing.T) {
+	ic := readIcon(t)
+	c24, err := ic.CallOp(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := ic.CallOp(24); err != nil || c != c24 {
+		t.Errorf("same size: got a different CallOp or error %v, want the cached CallOp", err)
+	}
+	if _, err := ic.CallOp(48); err != nil {
+		t.Errorf("different size: %v", err)
+	}
+	if _, err := ic.CallOp(0); err == nil {
+		t.Errorf("zero size: got nil error, want non-nil")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		pxPerDp     float32
+		constraints layout.Constraints
+		want        image.Point
+	}{
+		{"1x", 1, layout.Constraints{Max: image.Pt(100, 100)}, image.Pt(24, 24)},
+		{"2x", 2, layout.Constraints{Max: image.Pt(100, 100)}, image.Pt(48, 48)},
+		{"too small", 2, layout.Constraints{Max: image.Pt(30, 20)}, image.Pt(30, 20)},
+		{"exact", 1, layout.Exact(image.Pt(40, 40)), image.Pt(40, 40)},
+		{"empty", 1, layout.Constraints{}, image.Pt(0, 0)},
+	}
+	for _, tc := range testCases {
+		ic := readIcon(t)
+		gtx := layout.Context{
+			Ops:         new(op.Ops),
+			Metric:      unit.Metric{PxPerDp: tc.pxPerDp},
+			Constraints: tc.constraints,
+		}
+		if got := ic.Layout(gtx, 24).Size; got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivghttp serves IconVG graphics over HTTP, transcoding them to PNG or
+// SVG for clients that cannot render IconVG.
+//
+// The response format is chosen by the "format" query parameter ("ivg", "png"
+// or "svg") or, if that is absent, by the request's Accept header. Other query
+// parameters are:
+//
+//   - size: the width and height, in pixels, of a PNG (default 64) or of an
+//     SVG's svg element.
+//   - palette: comma-separated palette overrides such as "0=ff0000,3=00ff0080".
+//     Each color is a non-alpha-premultiplied CSS color, as for package
+//     ivgflag, such as "rrggbb" or "rrggbbaa" with an optional (URL-escaped)
+//     leading '#'.
+//
+// Responses carry an ETag that depends on the graphic and on those
+// parameters, so that conditional requests are answered without transcoding.
+package ivghttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/iconvg/src/go/ivg2svg"
+	"github.com/google/iconvg/src/go/ivgflag"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+const (
+	// DefaultSize is the size, in pixels, of a PNG when the request has no
+	// size parameter.
+	DefaultSize = 64
+
+	// MaxSize is the largest size parameter that is accepted.
+	MaxSize = 4096
+)
+
+// format is a response format, as named by the "format" query parameter.
+type format string
+
+const (
+	formatIVG format = "ivg"
+	formatPNG format = "png"
+	formatSVG format = "svg"
+)
+
+func (f format) contentType() string {
+	switch f {
+	case formatPNG:
+		return "image/png"
+	case formatSVG:
+		return "image/svg+xml"
+	}
+	return "image/ivg"
+}
+
+var (
+	errInvalidFormat  = errors.New("ivghttp: invalid format")
+	errInvalidPalette = errors.New("ivghttp: invalid palette")
+	errInvalidSize    = errors.New("ivghttp: invalid size")
+)
+
+// Handler returns an http.Handler that serves the ".ivg" files in fsys. The
+// request URL's path, without its leading slash, names the file. Requests for
+// other files are answered with 404 Not Found.
+//
+// To serve a subdirectory of fsys under a URL prefix, combine fs.Sub with
+// http.StripPrefix.
+func Handler(fsys fs.FS) http.Handler {
+	return &handler{fsys: fsys}
+}
+
+type handler struct {
+	fsys fs.FS
+}
+
+// request is a parsed request: what to serve and how to transcode it.
+type request struct {
+	format    format
+	size      int
+	overrides map[uint8]color.RGBA
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != http.MethodGet) && (r.Method != http.MethodHead) {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !fs.ValidPath(name) || (path.Ext(name) != ".ivg") {
+		http.NotFound(w, r)
+		return
+	}
+	req, err := parseRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	src, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	modTime := time.Time{}
+	if fi, err := fs.Stat(h.fsys, name); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	w.Header().Set("Vary", "Accept")
+	etag := req.etag(src)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := req.transcode(r.Context(), src)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", req.format.contentType())
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(body))
+}
+
+func parseRequest(r *http.Request) (*request, error) {
+	q := r.URL.Query()
+	req := &request{
+		format: negotiate(r.Header.Get("Accept")),
+	}
+	if s := q.Get("format"); s != "" {
+		switch f := format(s); f {
+		case formatIVG, formatPNG, formatSVG:
+			req.format = f
+		default:
+			return nil, errInvalidFormat
+		}
+	}
+	if s := q.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if (err != nil) || (size <= 0) || (size > MaxSize) {
+			return nil, errInvalidSize
+		}
+		req.size = size
+	}
+	if s := q.Get("palette"); s != "" {
+		overrides, err := ivgflag.ParsePalette(s)
+		if err != nil {
+			return nil, errInvalidPalette
+		}
+		req.overrides = overrides
+	}
+	return req, nil
+}
+
+// negotiate picks the format for an Accept header. IconVG, then SVG, are
+// preferred, at equal quality values, but only if the client explicitly
+// accepts them. Otherwise, it picks PNG, which every browser can display.
+func negotiate(accept string) format {
+	best, bestQ := formatPNG, 0.0
+	for _, f := range [...]format{formatIVG, formatSVG, formatPNG} {
+		if q := acceptQuality(accept, f.contentType()); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality value that the Accept header gives to the
+// exact MIME type contentType, or 0 if the header does not mention it.
+// Wildcards such as "image/*" are ignored.
+func acceptQuality(accept string, contentType string) float64 {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), contentType) {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		return q
+	}
+	return 0
+}
+
+// etag returns a strong entity tag for the response to req, whose graphic is
+// src. It changes if the graphic or any of the transcoding parameters do.
+func (req *request) etag(src []byte) string {
+	h := sha256.New()
+	h.Write(src)
+	fmt.Fprintf(h, "\x00%s\x00%d", req.format, req.size)
+	indexes := make([]int, 0, len(req.overrides))
+	for i := range req.overrides {
+		indexes = append(indexes, int(i))
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		c := req.overrides[uint8(i)]
+		fmt.Fprintf(h, "\x00%d=%02x%02x%02x%02x", i, c.R, c.G, c.B, c.A)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches returns whether an If-None-Match header matches etag. Weak
+// comparison is used, as is required for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, s := range strings.Split(ifNoneMatch, ",") {
+		s = strings.TrimPrefix(strings.TrimSpace(s), "W/")
+		if (s == "*") || (s == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// transcode converts the graphic src to req's format. Rasterizing stops early
+// if ctx, the HTTP request's context, is done because the client went away.
+func (req *request) transcode(ctx context.Context, src []byte) ([]byte, error) {
+	if req.format == formatPNG {
+		size := req.size
+		if size == 0 {
+			size = DefaultSize
+		}
+		buf := &bytes.Buffer{}
+		err := render.PNGContext(ctx, buf, src, size, &render.Options{Palette: req.overrides})
+		return buf.Bytes(), err
+	}
+
+	if len(req.overrides) > 0 {
+		pal, err := lowlevel.LoadPartialPalette(src, req.overrides)
+		if err != nil {
+			return nil, err
+		}
+		if src, err = lowlevel.RewritePalette(src, pal); err != nil {
+			return nil, err
+		}
+	}
+	if req.format == formatIVG {
+		return src, nil
+	}
+	buf := &bytes.Buffer{}
+	err := ivg2svg.Convert(buf, src, &ivg2svg.Options{Width: req.size, Height: req.size})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivghttp_test
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/iconvg/src/go/ivghttp"
+)
+
+func newHandler(t *testing.T) (http.Handler, []byte) {
+	t.Helper()
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ivghttp.Handler(fstest.MapFS{
+		"icons/info.ivg": &fstest.MapFile{Data: src},
+		"icons/info.txt": &fstest.MapFile{Data: []byte("info")},
+	}), src
+}
+
+func TestHandler(t *testing.T) {
+	h, src := newHandler(t)
+	testCases := []struct {
+		method          string
+		url             string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"GET", "/icons/info.ivg", "", http.StatusOK, "image/png"},
+		{"GET", "/icons/info.ivg", "image/ivg", http.StatusOK, "image/ivg"},
+		{"GET", "/icons/info.ivg", "image/svg+xml, image/png;q=0.9", http.StatusOK, "image/svg+xml"},
+		{"GET", "/icons/info.ivg", "image/svg+xml;q=0.5, image/png", http.StatusOK, "image/png"},
+		{"GET", "/icons/info.ivg", "image/*", http.StatusOK, "image/png"},
+		{"GET", "/icons/info.ivg?format=svg", "image/ivg", http.StatusOK, "image/svg+xml"},
+		{"GET", "/icons/info.ivg?format=png&size=16&palette=0%3Dff0000", "", http.StatusOK, "image/png"},
+		{"HEAD", "/icons/info.ivg", "", http.StatusOK, "image/png"},
+		{"POST", "/icons/info.ivg", "", http.StatusMethodNotAllowed, ""},
+		{"GET", "/icons/missing.ivg", "", http.StatusNotFound, ""},
+		{"GET", "/icons/info.txt", "", http.StatusNotFound, ""},
+		{"GET", "/icons/../icons/info.ivg", "image/ivg", http.StatusOK, "image/ivg"},
+		{"GET", "/icons/info.ivg?format=gif", "", http.StatusBadRequest, ""},
+		{"GET", "/icons/info.ivg?size=0", "", http.StatusBadRequest, ""},
+		{"GET", "/icons/info.ivg?size=4097", "", http.StatusBadRequest, ""},
+		{"GET", "/icons/info.ivg?palette=64%3Dff0000", "", http.StatusBadRequest, ""},
+		{"GET", "/icons/info.ivg?palette=0%3Dff00", "", http.StatusBadRequest, ""},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		desc := tc.method + " " + tc.url + " (Accept: " + tc.accept + ")"
+		if rec.Code != tc.wantStatus {
+			t.Errorf("%s: status: got %d, want %d", desc, rec.Code, tc.wantStatus)
+			continue
+		}
+		if tc.wantStatus != http.StatusOK {
+			continue
+		}
+		if got := rec.Header().Get("Content-Type"); got != tc.wantContentType {
+			t.Errorf("%s: Content-Type: got %q, want %q", desc, got, tc.wantContentType)
+		}
+		if rec.Header().Get("ETag") == "" {
+			t.Errorf("%s: no ETag", desc)
+		}
+		if tc.method == "HEAD" {
+			continue
+		}
+		body := rec.Body.Bytes()
+		switch tc.wantContentType {
+		case "image/ivg":
+			if !bytes.Equal(body, src) {
+				t.Errorf("%s: body differs from the graphic", desc)
+			}
+		case "image/png":
+			if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+				t.Errorf("%s: %v", desc, err)
+			}
+		case "image/svg+xml":
+			if !bytes.HasPrefix(body, []byte("<svg ")) {
+				t.Errorf("%s: got %q, want an svg element", desc, body)
+			}
+		}
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	h, _ := newHandler(t)
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	etag := get("/icons/info.ivg?size=32", "").Header().Get("ETag")
+
+	testCases := []struct {
+		url         string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"/icons/info.ivg?size=32", etag, http.StatusNotModified},
+		{"/icons/info.ivg?size=32", `"other", ` + etag, http.StatusNotModified},
+		{"/icons/info.ivg?size=32", "*", http.StatusNotModified},
+		{"/icons/info.ivg?size=32", `"other"`, http.StatusOK},
+		{"/icons/info.ivg?size=48", etag, http.StatusOK},
+		{"/icons/info.ivg?size=32&palette=0%3Dff0000", etag, http.StatusOK},
+		{"/icons/info.ivg?size=32&format=svg", etag, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		if got := get(tc.url, tc.ifNoneMatch).Code; got != tc.wantStatus {
+			t.Errorf("%s (If-None-Match: %s): got %d, want %d", tc.url, tc.ifNoneMatch, got, tc.wantStatus)
+		}
+	}
+}
+
+func TestHandlerCancelled(t *testing.T) {
+	// Rendering a PNG stops when the client goes away. Serving the graphic
+	// itself does not render it.
+	h, _ := newHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		accept     string
+		wantStatus int
+	}{
+		{"image/png", http.StatusInternalServerError},
+		{"image/ivg", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/icons/info.ivg", nil).WithContext(ctx)
+		req.Header.Set("Accept", tc.accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Errorf("Accept: %s: status: got %d, want %d", tc.accept, rec.Code, tc.wantStatus)
+		}
+	}
+}
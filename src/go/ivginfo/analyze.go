@@ -0,0 +1,115 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivginfo
+
+import (
+	"math/bits"
+)
+
+// Stats are a graphic's complexity statistics, for asset pipelines to flag
+// icons that are too expensive for low-end devices.
+type Stats struct {
+	// Opcodes, Instructions, Paths, GradientPaths, Segments and Complexity
+	// are as for Info.
+	Opcodes       map[string]int
+	Instructions  int
+	Paths         int
+	GradientPaths int
+	Segments      map[string]int
+	Complexity    int
+
+	// GradientStops is the total number of stops of the gradient-filled
+	// paths' gradients.
+	GradientStops int
+
+	// CRegs and NRegs are the register pressure: the numbers of distinct CREG
+	// and NREG registers, of 64 each, that the graphic writes to.
+	CRegs int
+	NRegs int
+
+	// RasterCost estimates the graphic's rasterization cost. Each path costs
+	// its Complexity, for flattening and accumulating its segments, plus
+	// areaWeight times the fraction of the viewBox that its bounding box
+	// covers, for compositing its pixels. A gradient-filled path's cost
+	// counts twice. A graphic whose single path covers the whole viewBox,
+	// and is made of 64 lines, costs 64 + 64 = 128. Like Complexity, it is
+	// meant for comparing graphics, and for setting budgets, not as a
+	// precise measure.
+	RasterCost float64
+}
+
+// areaWeight is the Stats.RasterCost of compositing the whole viewBox,
+// relative to the cost of a line segment.
+const areaWeight = 64
+
+// Analyze decodes the IconVG graphic data and returns its complexity
+// statistics.
+func Analyze(data []byte) (Stats, error) {
+	x, err := inspect(data)
+	if err != nil {
+		return Stats{}, err
+	}
+	s := x.stats
+	s.Opcodes = x.info.Opcodes
+	s.Instructions = x.info.Instructions
+	s.Paths = x.info.Paths
+	s.GradientPaths = x.info.GradientPaths
+	s.Segments = x.info.Segments
+	s.Complexity = x.info.Complexity
+	s.CRegs = bits.OnesCount64(x.cRegs)
+	s.NRegs = bits.OnesCount64(x.nRegs)
+	return s, nil
+}
+
+// rasterCost returns the current path's contribution to Stats.RasterCost.
+// x.cost is its contribution to Info.Complexity, already doubled for a
+// gradient.
+func (x *inspector) rasterCost() float64 {
+	vb := &x.metadata.ViewBox
+	vw, vh := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	area := 0.0
+	if (vw > 0) && (vh > 0) {
+		w := float64(min32(x.max[0], vb.Max[0]) - max32(x.min[0], vb.Min[0]))
+		h := float64(min32(x.max[1], vb.Max[1]) - max32(x.min[1], vb.Min[1]))
+		if (w > 0) && (h > 0) {
+			area = (w * h) / (vw * vh)
+		}
+	}
+	if x.gradient {
+		area *= 2
+	}
+	return float64(x.cost) + areaWeight*area
+}
+
+func abs32(a float32) float32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
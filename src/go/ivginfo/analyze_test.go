@@ -0,0 +1,133 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivginfo_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/ivginfo"
+)
+
+func TestAnalyze(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		asm            string
+		wantComplexity int
+		wantRasterCost float64
+		wantCRegs      int
+		wantNRegs      int
+	}{{
+		desc:           "whole viewBox",
+		asm:            "creg.3 [csel] #000000\npath [csel] 0 0\nL 48 0 48 48 0 48\nz",
+		wantComplexity: 3,
+		wantRasterCost: 3 + 64,
+		wantCRegs:      1,
+	}, {
+		// Only the half of the path's bounding box inside the viewBox counts.
+		desc:           "half outside viewBox",
+		asm:            "path [csel] -24 0\nL 24 0 24 48 -24 48\nz",
+		wantComplexity: 3,
+		wantRasterCost: 3 + 32,
+	}, {
+		desc:           "zero area",
+		asm:            "path [csel] 0 0\nL 48 0\nz",
+		wantComplexity: 1,
+		wantRasterCost: 1,
+	}, {
+		desc:           "no segments",
+		asm:            "path [csel] 0 0\nz",
+		wantComplexity: 0,
+		wantRasterCost: 0,
+	}, {
+		// CREG[1] is written twice, and counts once.
+		desc:      "register pressure",
+		asm:       "creg.3 [csel++] #ff0000\ncreg.3 [csel++] #00ff00\ncreg.3 [csel-1] #0000ff\nnreg.real [nsel] 1\nnreg.real [nsel-1] 2",
+		wantCRegs: 2,
+		wantNRegs: 2,
+	}}
+	for _, tc := range testCases {
+		src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\nviewBox 0 0 48 48\n" + tc.asm))
+		if err != nil {
+			t.Fatalf("%s: Assemble: %v", tc.desc, err)
+		}
+		got, err := ivginfo.Analyze(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got.Complexity != tc.wantComplexity {
+			t.Errorf("%s: Complexity: got %d, want %d", tc.desc, got.Complexity, tc.wantComplexity)
+		}
+		if got.RasterCost != tc.wantRasterCost {
+			t.Errorf("%s: RasterCost: got %v, want %v", tc.desc, got.RasterCost, tc.wantRasterCost)
+		}
+		if got.CRegs != tc.wantCRegs {
+			t.Errorf("%s: CRegs: got %d, want %d", tc.desc, got.CRegs, tc.wantCRegs)
+		}
+		if got.NRegs != tc.wantNRegs {
+			t.Errorf("%s: NRegs: got %d, want %d", tc.desc, got.NRegs, tc.wantNRegs)
+		}
+	}
+}
+
+func TestAnalyzeTestData(t *testing.T) {
+	testCases := []struct {
+		filename      string
+		wantGradients bool
+	}{
+		{"action-info.lores.ivg", false},
+		{"blank.ivg", false},
+		{"cowbell.ivg", true},
+		{"gradient.ivg", true},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ivginfo.Analyze(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		info, err := ivginfo.Inspect(src)
+		if err != nil {
+			t.Fatalf("%s: Inspect: %v", tc.filename, err)
+		}
+		// The fields shared with Info must agree with Inspect.
+		if !reflect.DeepEqual(got.Opcodes, info.Opcodes) || !reflect.DeepEqual(got.Segments, info.Segments) ||
+			(got.Instructions != info.Instructions) || (got.Paths != info.Paths) ||
+			(got.GradientPaths != info.GradientPaths) || (got.Complexity != info.Complexity) {
+			t.Errorf("%s: got %+v, want the same as Inspect's %+v", tc.filename, got, info)
+		}
+		if gotGradients := got.GradientStops > 0; gotGradients != tc.wantGradients {
+			t.Errorf("%s: GradientStops: got %d, want non-zero %t", tc.filename, got.GradientStops, tc.wantGradients)
+		}
+		if (got.Paths > 0) && (got.RasterCost <= float64(got.Complexity)) {
+			t.Errorf("%s: RasterCost: got %v, want more than Complexity %d", tc.filename, got.RasterCost, got.Complexity)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	for _, src := range [][]byte{nil, []byte("not IconVG")} {
+		if _, err := ivginfo.Analyze(src); err == nil {
+			t.Errorf("%q: got nil error, want non-nil", src)
+		}
+	}
+}
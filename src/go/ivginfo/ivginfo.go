@@ -0,0 +1,395 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivginfo summarizes IconVG graphics, for inspecting files and for
+// asset pipeline telemetry.
+package ivginfo
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Format is the value of Info.Format. IconVG has no version number: its magic
+// identifier identifies the one file format.
+const Format = "IconVG"
+
+// Info summarizes an IconVG graphic.
+type Info struct {
+	// Format is always Format.
+	Format string `json:"format"`
+	// Size is the size of the graphic, in bytes.
+	Size int `json:"size"`
+
+	// ViewBox is the graphic's viewBox: minX, minY, maxX, maxY.
+	ViewBox [4]float32 `json:"viewBox"`
+	// Palette is the graphic's suggested palette, formatted as "#rrggbbaa"
+	// alpha-premultiplied colors. It omits the trailing entries that are
+	// opaque black, the default, and so is empty if the graphic has no
+	// suggested palette.
+	Palette []string `json:"palette"`
+	// Title and Description are the graphic's alternative text, if any.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// DetailLevels is the number of progressive detail levels, or zero if
+	// the graphic is not progressive.
+	DetailLevels int `json:"detailLevels,omitempty"`
+
+	// Opcodes counts the graphic's instructions, keyed by the name of the
+	// lowlevel.Destination method that they call, such as "SetCReg" or
+	// "AbsCubeTo". An instruction whose opcode repeats counts once.
+	Opcodes map[string]int `json:"opcodes"`
+	// Instructions is the total number of instructions.
+	Instructions int `json:"instructions"`
+
+	// Paths is the number of paths, of which GradientPaths are filled with
+	// gradients.
+	Paths         int `json:"paths"`
+	GradientPaths int `json:"gradientPaths"`
+	// Segments counts the paths' segments by kind: "lineTo" (including
+	// horizontal and vertical lines), "quadTo", "cubeTo" and "arcTo", each
+	// including their smooth variants. Every repetition of a repeated opcode
+	// counts.
+	Segments map[string]int `json:"segments"`
+
+	// Complexity estimates the graphic's rasterization cost, as the number of
+	// line segments that its paths flatten to, at a nominal 1 per line, 4 per
+	// quadratic Bézier curve, 8 per cubic Bézier curve and 16 per arc. A
+	// gradient-filled path's segments count twice. It is meant for comparing
+	// graphics, not as a precise measure.
+	Complexity int `json:"complexity"`
+}
+
+// Segment weights for Info.Complexity.
+const (
+	weightLine = 1
+	weightQuad = 4
+	weightCube = 8
+	weightArc  = 16
+)
+
+// Inspect decodes the IconVG graphic src and summarizes it.
+func Inspect(src []byte) (*Info, error) {
+	x, err := inspect(src)
+	if err != nil {
+		return nil, err
+	}
+	return &x.info, nil
+}
+
+// inspect decodes src with an inspector.
+func inspect(src []byte) (*inspector, error) {
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		return nil, err
+	}
+	x := &inspector{
+		info: Info{
+			Format:       Format,
+			Size:         len(src),
+			ViewBox:      [4]float32{m.ViewBox.Min[0], m.ViewBox.Min[1], m.ViewBox.Max[0], m.ViewBox.Max[1]},
+			Palette:      []string{},
+			Title:        m.Title,
+			Description:  m.Description,
+			DetailLevels: len(m.DetailLevels),
+			Opcodes:      map[string]int{},
+			Segments:     map[string]int{},
+		},
+	}
+	n := len(m.Palette)
+	for (n > 0) && (m.Palette[n-1] == lowlevel.DefaultPalette[n-1]) {
+		n--
+	}
+	for _, c := range m.Palette[:n] {
+		x.info.Palette = append(x.info.Palette, fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A))
+	}
+
+	opts := &lowlevel.DecodeOptions{
+		OnInstruction: x.onInstruction,
+	}
+	if err := lowlevel.Decode(x, src, opts); err != nil {
+		return nil, err
+	}
+	x.endPath()
+	return x, nil
+}
+
+// inspector is a lowlevel.Destination that tallies an Info. It tracks the
+// CREG registers, like a rasterizer would, to tell which paths are filled
+// with gradients.
+type inspector struct {
+	info Info
+
+	// newInstruction is whether the next method call is the first for the
+	// current instruction.
+	newInstruction bool
+
+	metadata lowlevel.Metadata
+	cSel     uint8
+	nSel     uint8
+	cReg     [64]color.RGBA
+
+	// gradient is whether the current path is filled with a gradient. cost
+	// is the current path's contribution to Info.Complexity.
+	gradient bool
+	cost     int
+
+	// The remaining fields are for Analyze's Stats. cRegs and nRegs are the
+	// sets of CREG and NREG registers written to. pen is the current point
+	// and min and max bound the current path's points.
+	stats    Stats
+	cRegs    uint64
+	nRegs    uint64
+	pen      [2]float32
+	min, max [2]float32
+}
+
+func (x *inspector) onInstruction(offset int) {
+	x.newInstruction = true
+	x.info.Instructions++
+}
+
+// op records a method call for the opcode histogram.
+func (x *inspector) op(name string) {
+	if x.newInstruction {
+		x.newInstruction = false
+		x.info.Opcodes[name]++
+	}
+}
+
+// segment records a path segment.
+func (x *inspector) segment(kind string, weight int) {
+	x.info.Segments[kind]++
+	x.cost += weight
+}
+
+func (x *inspector) endPath() {
+	if x.gradient {
+		x.cost *= 2
+	}
+	x.info.Complexity += x.cost
+	if x.cost > 0 {
+		x.stats.RasterCost += x.rasterCost()
+	}
+	x.gradient, x.cost = false, 0
+}
+
+// absPoint notes a point of the current path, in absolute coordinates.
+func (x *inspector) absPoint(ax, ay float32) {
+	x.min[0], x.min[1] = min32(x.min[0], ax), min32(x.min[1], ay)
+	x.max[0], x.max[1] = max32(x.max[0], ax), max32(x.max[1], ay)
+}
+
+// relPoint notes a point of the current path, relative to the pen.
+func (x *inspector) relPoint(ax, ay float32) {
+	x.absPoint(x.pen[0]+ax, x.pen[1]+ay)
+}
+
+// absTo and relTo note a segment's end point and move the pen there.
+func (x *inspector) absTo(ax, ay float32) {
+	x.absPoint(ax, ay)
+	x.pen = [2]float32{ax, ay}
+}
+
+func (x *inspector) relTo(ax, ay float32) {
+	x.absTo(x.pen[0]+ax, x.pen[1]+ay)
+}
+
+func (x *inspector) Reset(m lowlevel.Metadata) {
+	x.metadata = m
+	x.cSel = 0
+	x.cReg = m.Palette
+}
+
+func (x *inspector) SetCSel(cSel uint8) {
+	x.op("SetCSel")
+	x.cSel = cSel & 0x3f
+}
+
+func (x *inspector) SetNSel(nSel uint8) {
+	x.op("SetNSel")
+	x.nSel = nSel & 0x3f
+}
+
+func (x *inspector) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	x.op("SetCReg")
+	x.cReg[(x.cSel-adj)&0x3f] = c.Resolve(&x.metadata.Palette, &x.cReg)
+	x.cRegs |= 1 << ((x.cSel - adj) & 0x3f)
+	if incr {
+		x.cSel = (x.cSel + 1) & 0x3f
+	}
+}
+
+func (x *inspector) SetNReg(adj uint8, incr bool, f float32) {
+	x.op("SetNReg")
+	x.nRegs |= 1 << ((x.nSel - adj) & 0x3f)
+	if incr {
+		x.nSel = (x.nSel + 1) & 0x3f
+	}
+}
+
+func (x *inspector) SetLOD(lod0, lod1 float32) {
+	x.op("SetLOD")
+}
+
+func (x *inspector) StartPath(adj uint8, ax, ay float32) {
+	x.op("StartPath")
+	x.info.Paths++
+	c := x.cReg[(x.cSel-adj)&0x3f]
+	if (c.A == 0x00) && (c.B&0x80 != 0) {
+		x.info.GradientPaths++
+		x.gradient = true
+		x.stats.GradientStops += int(c.R & 0x3f)
+	}
+	x.pen = [2]float32{ax, ay}
+	x.min, x.max = x.pen, x.pen
+}
+
+func (x *inspector) ClosePathEndPath() {
+	x.op("ClosePathEndPath")
+	x.endPath()
+}
+
+func (x *inspector) ClosePathAbsMoveTo(ax, ay float32) {
+	x.op("ClosePathAbsMoveTo")
+	x.segment("lineTo", weightLine)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) ClosePathRelMoveTo(ax, ay float32) {
+	x.op("ClosePathRelMoveTo")
+	x.segment("lineTo", weightLine)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsHLineTo(ax float32) {
+	x.op("AbsHLineTo")
+	x.segment("lineTo", weightLine)
+	x.absTo(ax, x.pen[1])
+}
+
+func (x *inspector) RelHLineTo(ax float32) {
+	x.op("RelHLineTo")
+	x.segment("lineTo", weightLine)
+	x.relTo(ax, 0)
+}
+
+func (x *inspector) AbsVLineTo(ay float32) {
+	x.op("AbsVLineTo")
+	x.segment("lineTo", weightLine)
+	x.absTo(x.pen[0], ay)
+}
+
+func (x *inspector) RelVLineTo(ay float32) {
+	x.op("RelVLineTo")
+	x.segment("lineTo", weightLine)
+	x.relTo(0, ay)
+}
+
+func (x *inspector) AbsLineTo(ax, ay float32) {
+	x.op("AbsLineTo")
+	x.segment("lineTo", weightLine)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) RelLineTo(ax, ay float32) {
+	x.op("RelLineTo")
+	x.segment("lineTo", weightLine)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsSmoothQuadTo(ax, ay float32) {
+	x.op("AbsSmoothQuadTo")
+	x.segment("quadTo", weightQuad)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) RelSmoothQuadTo(ax, ay float32) {
+	x.op("RelSmoothQuadTo")
+	x.segment("quadTo", weightQuad)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsQuadTo(ax1, ay1, ax, ay float32) {
+	x.op("AbsQuadTo")
+	x.segment("quadTo", weightQuad)
+	x.absPoint(ax1, ay1)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) RelQuadTo(ax1, ay1, ax, ay float32) {
+	x.op("RelQuadTo")
+	x.segment("quadTo", weightQuad)
+	x.relPoint(ax1, ay1)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsSmoothCubeTo(ax2, ay2, ax, ay float32) {
+	x.op("AbsSmoothCubeTo")
+	x.segment("cubeTo", weightCube)
+	x.absPoint(ax2, ay2)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) RelSmoothCubeTo(ax2, ay2, ax, ay float32) {
+	x.op("RelSmoothCubeTo")
+	x.segment("cubeTo", weightCube)
+	x.relPoint(ax2, ay2)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsCubeTo(ax1, ay1, ax2, ay2, ax, ay float32) {
+	x.op("AbsCubeTo")
+	x.segment("cubeTo", weightCube)
+	x.absPoint(ax1, ay1)
+	x.absPoint(ax2, ay2)
+	x.absTo(ax, ay)
+}
+
+func (x *inspector) RelCubeTo(ax1, ay1, ax2, ay2, ax, ay float32) {
+	x.op("RelCubeTo")
+	x.segment("cubeTo", weightCube)
+	x.relPoint(ax1, ay1)
+	x.relPoint(ax2, ay2)
+	x.relTo(ax, ay)
+}
+
+func (x *inspector) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, ax, ay float32) {
+	x.op("AbsArcTo")
+	x.segment("arcTo", weightArc)
+	x.arcTo(rx, ry, ax, ay)
+}
+
+func (x *inspector) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, ax, ay float32) {
+	x.op("RelArcTo")
+	x.segment("arcTo", weightArc)
+	x.arcTo(rx, ry, x.pen[0]+ax, x.pen[1]+ay)
+}
+
+// arcTo notes an arc to (ax, ay). The arc's points are within its larger
+// radius of its center, which is within that radius of its end points, so
+// that the arc is within its end points' bounds grown by twice that radius.
+// Radii too small to span the end points are scaled up, but then the arc is
+// a semi-ellipse whose span is the distance between them.
+func (x *inspector) arcTo(rx, ry, ax, ay float32) {
+	r := 2 * max32(abs32(rx), abs32(ry))
+	if d := max32(abs32(ax-x.pen[0]), abs32(ay-x.pen[1])); r < d {
+		r = d
+	}
+	x.absPoint(min32(x.pen[0], ax)-r, min32(x.pen[1], ay)-r)
+	x.absPoint(max32(x.pen[0], ax)+r, max32(x.pen[1], ay)+r)
+	x.absTo(ax, ay)
+}
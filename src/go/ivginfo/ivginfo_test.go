@@ -0,0 +1,206 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivginfo_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/ivginfo"
+)
+
+func TestInspect(t *testing.T) {
+	src, err := ivgasm.Assemble([]byte(`magic
+metadata 4
+viewBox 0 0 48 48
+palette.3 #ff0000
+text "Info" "D\xc3\xa9tails"
+levels 0 0
+creg.3 [csel] #00ff00
+path [csel] 0 0
+L 10 0 10 10
+H 0
+Q 1 1 2 2
+c 1 1 2 2 3 3
+A 1 1 0 0 1 5 5
+z
+path [csel-1] 0 0
+L 1 1
+z
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ivginfo.Inspect(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ivginfo.Info{
+		Format:       ivginfo.Format,
+		Size:         len(src),
+		ViewBox:      [4]float32{0, 0, 48, 48},
+		Palette:      []string{"#ff0000ff"},
+		Title:        "Info",
+		Description:  "Détails",
+		DetailLevels: 2,
+		Opcodes: map[string]int{
+			"AbsArcTo":         1,
+			"AbsHLineTo":       1,
+			"AbsLineTo":        2,
+			"AbsQuadTo":        1,
+			"ClosePathEndPath": 2,
+			"RelCubeTo":        1,
+			"SetCReg":          1,
+			"StartPath":        2,
+		},
+		Instructions: 11,
+		Paths:        2,
+		Segments: map[string]int{
+			"arcTo":  1,
+			"cubeTo": 1,
+			"lineTo": 4,
+			"quadTo": 1,
+		},
+		// The weights are 1 per line, 4 per quad, 8 per cube and 16 per arc.
+		Complexity: 4*1 + 1*4 + 1*8 + 1*16,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestInspectTestData(t *testing.T) {
+	testCases := []struct {
+		filename          string
+		wantPaths         int
+		wantGradientPaths int
+		wantPalette       int
+	}{
+		{"action-info.lores.ivg", 1, 0, 0},
+		{"blank.ivg", 0, 0, 0},
+		{"gradient.ivg", 4, 4, 0},
+		{"lod-polygon.ivg", 4, 0, 0},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := ivginfo.Inspect(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		if info.Size != len(src) {
+			t.Errorf("%s: Size: got %d, want %d", tc.filename, info.Size, len(src))
+		}
+		if info.Paths != tc.wantPaths {
+			t.Errorf("%s: Paths: got %d, want %d", tc.filename, info.Paths, tc.wantPaths)
+		}
+		if info.GradientPaths != tc.wantGradientPaths {
+			t.Errorf("%s: GradientPaths: got %d, want %d", tc.filename, info.GradientPaths, tc.wantGradientPaths)
+		}
+		if len(info.Palette) != tc.wantPalette {
+			t.Errorf("%s: Palette: got %q, want %d entries", tc.filename, info.Palette, tc.wantPalette)
+		}
+	}
+}
+
+func TestInspectErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"not IconVG", []byte("not IconVG")},
+		{"truncated", []byte("\x89IVG\x00\xc0\x80")},
+	}
+	for _, tc := range testCases {
+		if _, err := ivginfo.Inspect(tc.src); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	testCases := []struct {
+		desc string
+		info ivginfo.Info
+		want string
+	}{{
+		desc: "minimal",
+		info: ivginfo.Info{
+			Format:  ivginfo.Format,
+			Size:    12,
+			ViewBox: [4]float32{-32, -32, 32, 32},
+		},
+		want: "format:        " + ivginfo.Format + "\n" +
+			"size:          12 bytes\n" +
+			"viewBox:       -32 -32 32 32\n" +
+			"palette:       none\n" +
+			"paths:         0 (0 with gradients)\n" +
+			"segments:\n" +
+			"instructions:  0\n" +
+			"complexity:    0\n",
+	}, {
+		desc: "full",
+		info: ivginfo.Info{
+			Format:        ivginfo.Format,
+			Size:          100,
+			ViewBox:       [4]float32{0, 0, 24, 24},
+			Palette:       []string{"#ff0000ff", "#00ff00ff"},
+			Title:         "Bell",
+			Description:   "A cow bell",
+			DetailLevels:  2,
+			Paths:         3,
+			GradientPaths: 1,
+			Segments:      map[string]int{"lineTo": 4, "arcTo": 1, "cubeTo": 1},
+			Instructions:  9,
+			Opcodes:       map[string]int{"StartPath": 3, "AbsLineTo": 4},
+			Complexity:    28,
+		},
+		want: "format:        " + ivginfo.Format + "\n" +
+			"size:          100 bytes\n" +
+			"viewBox:       0 0 24 24\n" +
+			"palette:       2 colors\n" +
+			"     0: #ff0000ff\n" +
+			"     1: #00ff00ff\n" +
+			"title:         \"Bell\"\n" +
+			"description:   \"A cow bell\"\n" +
+			"detail levels: 2\n" +
+			"paths:         3 (1 with gradients)\n" +
+			"segments:\n" +
+			"    lineTo               4\n" +
+			"    arcTo                1\n" +
+			"    cubeTo               1\n" +
+			"instructions:  9\n" +
+			"    AbsLineTo            4\n" +
+			"    StartPath            3\n" +
+			"complexity:    28\n",
+	}}
+	for _, tc := range testCases {
+		b := &strings.Builder{}
+		if err := tc.info.WriteText(b); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.desc, got, tc.want)
+		}
+	}
+}
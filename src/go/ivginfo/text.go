@@ -0,0 +1,73 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivginfo
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteText writes info to w as human-readable plain text, as printed by the
+// ivginfo command.
+func (info *Info) WriteText(w io.Writer) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "format:        %s\n", info.Format)
+	fmt.Fprintf(b, "size:          %d bytes\n", info.Size)
+	fmt.Fprintf(b, "viewBox:       %g %g %g %g\n", info.ViewBox[0], info.ViewBox[1], info.ViewBox[2], info.ViewBox[3])
+	if len(info.Palette) == 0 {
+		fmt.Fprintf(b, "palette:       none\n")
+	} else {
+		fmt.Fprintf(b, "palette:       %d colors\n", len(info.Palette))
+		for i, c := range info.Palette {
+			fmt.Fprintf(b, "    %2d: %s\n", i, c)
+		}
+	}
+	if info.Title != "" {
+		fmt.Fprintf(b, "title:         %q\n", info.Title)
+	}
+	if info.Description != "" {
+		fmt.Fprintf(b, "description:   %q\n", info.Description)
+	}
+	if info.DetailLevels > 0 {
+		fmt.Fprintf(b, "detail levels: %d\n", info.DetailLevels)
+	}
+	fmt.Fprintf(b, "paths:         %d (%d with gradients)\n", info.Paths, info.GradientPaths)
+	fmt.Fprintf(b, "segments:\n")
+	writeCounts(b, info.Segments)
+	fmt.Fprintf(b, "instructions:  %d\n", info.Instructions)
+	writeCounts(b, info.Opcodes)
+	fmt.Fprintf(b, "complexity:    %d\n", info.Complexity)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCounts writes m's entries, from most to least frequent.
+func writeCounts(b *strings.Builder, m map[string]int) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "    %-20s %d\n", k, m[k])
+	}
+}
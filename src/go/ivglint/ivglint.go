@@ -0,0 +1,322 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ivglint checks IconVG graphics for problems.
+//
+// Errors are violations of the specification, such as byte code that does not
+// decode or a color that is not alpha-premultiplied. Warnings are valid byte
+// code that is probably a mistake, such as a gradient whose register indexes
+// wrap around, a coordinate outside of the viewBox or an instruction that can
+// never have any effect.
+package ivglint
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity uint8
+
+const (
+	// Error means that the graphic violates the IconVG specification.
+	Error Severity = iota
+	// Warning means that the graphic is valid but probably not as intended.
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a problem found in an IconVG graphic.
+type Diagnostic struct {
+	// Offset is the byte offset, within the graphic, of the instruction that
+	// has the problem. It is zero for problems with the magic identifier or
+	// the metadata.
+	Offset   int
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%#x: %v: %s", d.Offset, d.Severity, d.Message)
+}
+
+// Lint checks the IconVG graphic src, returning its problems in byte offset
+// order. It returns no Diagnostics if it finds no problems.
+func Lint(src []byte) []Diagnostic {
+	l := &linter{}
+	opts := &lowlevel.DecodeOptions{
+		OnInstruction: l.onInstruction,
+		OnWarning:     l.onWarning,
+	}
+	if err := lowlevel.Decode(l, src, opts); err != nil {
+		if de := (*lowlevel.DecodeError)(nil); errors.As(err, &de) {
+			l.offset = de.Offset
+			l.errorf("%s", string(de.Reason))
+		} else {
+			l.errorf("%v", err)
+		}
+		return l.diags
+	}
+	if l.drawing {
+		l.offset = l.pathOffset
+		l.errorf("path is unfinished")
+	} else if l.trailingOffset >= 0 {
+		l.offset = l.trailingOffset
+		l.warnf("instructions after the last path have no effect")
+	}
+	return l.diags
+}
+
+// linter is a lowlevel.Destination that tracks the decoder's virtual machine
+// state, like a rasterizer would, and records Diagnostics.
+type linter struct {
+	diags []Diagnostic
+
+	// offset is the byte offset of the current instruction. pathOffset is
+	// that of the current path's first instruction. trailingOffset is that of
+	// the first instruction after the last path, or -1 if there is no such
+	// instruction.
+	offset         int
+	pathOffset     int
+	trailingOffset int
+	drawing        bool
+
+	// lastOffset de-duplicates warnings about an instruction's coordinates
+	// being outside of the viewBox. It is -1 if there is no such instruction.
+	lastOffset int
+
+	metadata lowlevel.Metadata
+	lod0     float32
+	lod1     float32
+	cSel     uint8
+	nSel     uint8
+	cReg     [64]color.RGBA
+	nReg     [64]float32
+
+	pen   f32.Vec2
+	start f32.Vec2
+}
+
+func (l *linter) onInstruction(offset int) {
+	l.offset = offset
+	if !l.drawing && (l.trailingOffset < 0) {
+		l.trailingOffset = offset
+	}
+}
+
+// onWarning records the violations that lenient decoding repairs, such as a
+// non-premultiplied color, before the linter would otherwise see them.
+func (l *linter) onWarning(w *lowlevel.DecodeError) {
+	if w.Reason == lowlevel.ErrUnfinishedPath {
+		// Lint reports this itself, once decoding finishes.
+		return
+	}
+	l.diags = append(l.diags, Diagnostic{w.Offset, Error, string(w.Reason)})
+}
+
+func (l *linter) errorf(format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{l.offset, Error, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(format string, args ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{l.offset, Warning, fmt.Sprintf(format, args...)})
+}
+
+func validAlphaPremulColor(c color.RGBA) bool {
+	return c.R <= c.A && c.G <= c.A && c.B <= c.A
+}
+
+func isGradient(c color.RGBA) bool {
+	return (c.A == 0x00) && (c.B&0x80 != 0)
+}
+
+func (l *linter) Reset(m lowlevel.Metadata) {
+	l.metadata = m
+	l.lod0 = 0
+	l.lod1 = float32(math.Inf(+1))
+	l.cReg = m.Palette
+	l.trailingOffset = -1
+	l.lastOffset = -1
+}
+
+func (l *linter) SetCSel(cSel uint8) { l.cSel = cSel & 0x3f }
+func (l *linter) SetNSel(nSel uint8) { l.nSel = nSel & 0x3f }
+
+func (l *linter) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	l.cReg[(l.cSel-adj)&0x3f] = c.Resolve(&l.metadata.Palette, &l.cReg)
+	if incr {
+		if l.cSel == 63 {
+			l.warnf("CSEL++ wraps around")
+		}
+		l.cSel = (l.cSel + 1) & 0x3f
+	}
+}
+
+func (l *linter) SetNReg(adj uint8, incr bool, f float32) {
+	l.nReg[(l.nSel-adj)&0x3f] = f
+	if incr {
+		if l.nSel == 63 {
+			l.warnf("NSEL++ wraps around")
+		}
+		l.nSel = (l.nSel + 1) & 0x3f
+	}
+}
+
+func (l *linter) SetLOD(lod0, lod1 float32) {
+	if !(lod0 < lod1) {
+		l.warnf("level of detail range [%g, %g) is empty", lod0, lod1)
+	}
+	l.lod0, l.lod1 = lod0, lod1
+}
+
+func (l *linter) StartPath(adj uint8, x, y float32) {
+	l.drawing = true
+	l.pathOffset = l.offset
+	l.trailingOffset = -1
+
+	if !(l.lod0 < l.lod1) {
+		l.warnf("path is never drawn: its level of detail range [%g, %g) is empty", l.lod0, l.lod1)
+	}
+	if c := l.cReg[(l.cSel-adj)&0x3f]; isGradient(c) {
+		l.checkGradient(c)
+	} else if !validAlphaPremulColor(c) {
+		l.warnf("path is never drawn: its paint is neither a color nor a gradient")
+	}
+	l.start = f32.Vec2{}
+	l.moveTo(f32.Vec2{x, y})
+}
+
+func (l *linter) checkGradient(c color.RGBA) {
+	nStops := c.R & 0x3f
+	cBase := c.G & 0x3f
+	nBase := c.B & 0x3f
+	if nStops == 0 {
+		l.warnf("gradient has no stops")
+	}
+	if int(cBase)+int(nStops) > 64 {
+		l.warnf("gradient's CREG indexes wrap around (CBASE=%d, NSTOPS=%d)", cBase, nStops)
+	}
+	if (nBase < 6) || (int(nBase)+int(nStops) > 64) {
+		l.warnf("gradient's NREG indexes wrap around (NBASE=%d, NSTOPS=%d)", nBase, nStops)
+	}
+	for i := uint8(0); i < nStops; i++ {
+		if s := l.cReg[(cBase+i)&0x3f]; !validAlphaPremulColor(s) {
+			l.warnf("gradient stop %d's color %02x%02x%02x%02x is not alpha-premultiplied",
+				i, s.R, s.G, s.B, s.A)
+		}
+	}
+}
+
+func (l *linter) ClosePathEndPath() {
+	l.drawing = false
+	l.pen = l.start
+}
+
+func (l *linter) ClosePathAbsMoveTo(x, y float32) {
+	l.pen = l.start
+	l.moveTo(f32.Vec2{x, y})
+}
+
+func (l *linter) ClosePathRelMoveTo(x, y float32) {
+	l.pen = l.start
+	l.moveTo(l.rel(x, y))
+}
+
+func (l *linter) AbsHLineTo(x float32) { l.lineTo(f32.Vec2{x, l.pen[1]}) }
+func (l *linter) RelHLineTo(x float32) { l.lineTo(f32.Vec2{l.pen[0] + x, l.pen[1]}) }
+func (l *linter) AbsVLineTo(y float32) { l.lineTo(f32.Vec2{l.pen[0], y}) }
+func (l *linter) RelVLineTo(y float32) { l.lineTo(f32.Vec2{l.pen[0], l.pen[1] + y}) }
+
+func (l *linter) AbsLineTo(x, y float32) { l.lineTo(f32.Vec2{x, y}) }
+func (l *linter) RelLineTo(x, y float32) { l.lineTo(l.rel(x, y)) }
+
+func (l *linter) AbsSmoothQuadTo(x, y float32) { l.lineTo(f32.Vec2{x, y}) }
+func (l *linter) RelSmoothQuadTo(x, y float32) { l.lineTo(l.rel(x, y)) }
+
+func (l *linter) AbsQuadTo(x1, y1, x, y float32) {
+	l.check(f32.Vec2{x1, y1})
+	l.lineTo(f32.Vec2{x, y})
+}
+
+func (l *linter) RelQuadTo(x1, y1, x, y float32) {
+	l.check(l.rel(x1, y1))
+	l.lineTo(l.rel(x, y))
+}
+
+func (l *linter) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	l.check(f32.Vec2{x2, y2})
+	l.lineTo(f32.Vec2{x, y})
+}
+
+func (l *linter) RelSmoothCubeTo(x2, y2, x, y float32) {
+	l.check(l.rel(x2, y2))
+	l.lineTo(l.rel(x, y))
+}
+
+func (l *linter) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	l.check(f32.Vec2{x1, y1})
+	l.check(f32.Vec2{x2, y2})
+	l.lineTo(f32.Vec2{x, y})
+}
+
+func (l *linter) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	l.check(l.rel(x1, y1))
+	l.check(l.rel(x2, y2))
+	l.lineTo(l.rel(x, y))
+}
+
+func (l *linter) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	l.lineTo(f32.Vec2{x, y})
+}
+
+func (l *linter) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	l.lineTo(l.rel(x, y))
+}
+
+func (l *linter) rel(x, y float32) f32.Vec2 {
+	return f32.Vec2{l.pen[0] + x, l.pen[1] + y}
+}
+
+func (l *linter) moveTo(p f32.Vec2) {
+	l.check(p)
+	l.pen, l.start = p, p
+}
+
+func (l *linter) lineTo(p f32.Vec2) {
+	l.check(p)
+	l.pen = p
+}
+
+// check warns if p is outside of the viewBox, at most once per instruction.
+func (l *linter) check(p f32.Vec2) {
+	vb := &l.metadata.ViewBox
+	if (vb.Min[0] <= p[0]) && (p[0] <= vb.Max[0]) && (vb.Min[1] <= p[1]) && (p[1] <= vb.Max[1]) {
+		return
+	} else if l.lastOffset == l.offset {
+		return
+	}
+	l.lastOffset = l.offset
+	l.warnf("point (%g, %g) is outside of the viewBox", p[0], p[1])
+}
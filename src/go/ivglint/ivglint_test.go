@@ -0,0 +1,119 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ivglint_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/ivglint"
+)
+
+func TestLint(t *testing.T) {
+	const header = "magic\nmetadata 0\n"
+	const triangle = "path [csel] 0 0\nL 1 1 2 0\nz\n"
+	testCases := []struct {
+		desc string
+		asm  string
+		want []ivglint.Diagnostic
+	}{{
+		desc: "valid",
+		asm:  header + triangle,
+		want: nil,
+	}, {
+		desc: "invalid magic identifier",
+		asm:  "bytes 00",
+		want: []ivglint.Diagnostic{{0x0, ivglint.Error, "invalid magic identifier"}},
+	}, {
+		desc: "unsupported opcode",
+		asm:  header + "bytes ff",
+		want: []ivglint.Diagnostic{{0x5, ivglint.Error, "unsupported styling opcode"}},
+	}, {
+		desc: "non-premultiplied color",
+		asm:  header + "creg.4 [csel] #ff000080\n" + triangle,
+		want: []ivglint.Diagnostic{{0x5, ivglint.Error, "non-premultiplied color"}},
+	}, {
+		desc: "non-premultiplied palette color",
+		asm:  "magic\nmetadata 1\npalette.4 #ff000080\n" + triangle,
+		want: []ivglint.Diagnostic{{0x5, ivglint.Error, "non-premultiplied color"}},
+	}, {
+		desc: "unfinished path",
+		asm:  header + "path [csel] 0 0\nL 1 1",
+		want: []ivglint.Diagnostic{{0x5, ivglint.Error, "path is unfinished"}},
+	}, {
+		desc: "trailing instruction",
+		asm:  header + triangle + "csel 1",
+		want: []ivglint.Diagnostic{{0xe, ivglint.Warning, "instructions after the last path have no effect"}},
+	}, {
+		desc: "outside of the viewBox",
+		asm:  header + "path [csel] 0 0\nL 40 1 2 0\nz",
+		want: []ivglint.Diagnostic{{0x8, ivglint.Warning, "point (40, 1) is outside of the viewBox"}},
+	}, {
+		desc: "CSEL++ wraps around",
+		asm:  header + "csel 63\ncreg.1 [csel++] #000000\n" + triangle,
+		want: []ivglint.Diagnostic{{0x6, ivglint.Warning, "CSEL++ wraps around"}},
+	}, {
+		desc: "empty level of detail",
+		asm:  header + "lod 10 5\n" + triangle,
+		want: []ivglint.Diagnostic{
+			{0x5, ivglint.Warning, "level of detail range [10, 5) is empty"},
+			{0x8, ivglint.Warning, "path is never drawn: its level of detail range [10, 5) is empty"},
+		},
+	}}
+	for _, tc := range testCases {
+		src, err := ivgasm.Assemble([]byte(tc.asm))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := ivglint.Lint(src); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestLintTestData(t *testing.T) {
+	testCases := []struct {
+		filename string
+		want     int
+	}{
+		{"action-info.hires.ivg", 0},
+		{"arcs.ivg", 0},
+		{"blank.ivg", 0},
+		{"cowbell.ivg", 0},
+		{"elliptical.ivg", 0},
+		// favicon's feet poke out of the bottom of its viewBox.
+		{"favicon.ivg", 2},
+		{"gradient.ivg", 0},
+		{"lod-polygon.ivg", 0},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ivglint.Lint(src)
+		if len(got) != tc.want {
+			t.Errorf("%s: got %d diagnostics, want %d: %v", tc.filename, len(got), tc.want, got)
+			continue
+		}
+		for _, d := range got {
+			if d.Severity != ivglint.Warning {
+				t.Errorf("%s: got %v, want a warning", tc.filename, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+var (
+	errInvalidAnimationFrame = errors.New("iconvg: invalid animation frame")
+	errInvalidFrameDuration  = errors.New("iconvg: invalid animation frame duration")
+	errInvalidLoopCount      = errors.New("iconvg: invalid animation loop count")
+)
+
+// Animation is a sequence of IconVG graphics that are shown one after the
+// other, for simple icon animations such as spinners and progress states.
+//
+// Animations are an experimental extension. They are not part of the IconVG
+// specification, and their encoding may change. An encoded Animation is a
+// container for complete IconVG graphics, so that each frame can be decoded,
+// rendered or converted like any other graphic:
+//
+//   - the magic identifier "\x89IVA",
+//   - a natural number, the loop count,
+//   - a natural number, the number of frames N,
+//   - N frame table entries, each a natural number duration in milliseconds
+//     and a natural number frame length in bytes,
+//   - N frames, concatenated, each an IconVG graphic of that length.
+//
+// The frame table lets a decoder find any frame without decoding the others.
+// Natural numbers are encoded as in IconVG metadata.
+type Animation struct {
+	// LoopCount is the number of times to play the frames. Zero means to loop
+	// forever.
+	LoopCount int
+
+	Frames []Frame
+}
+
+// Frame is one of an Animation's graphics.
+type Frame struct {
+	// Duration is how long the frame is shown for. It is encoded in whole
+	// milliseconds.
+	Duration time.Duration
+
+	// Graphic is the frame's IconVG graphic.
+	Graphic []byte
+}
+
+const animationMagic = "\x89IVA"
+
+// maxNatural is one more than the largest natural number.
+const maxNatural = 1 << 30
+
+// IsAnimation returns whether src starts with an Animation's magic identifier,
+// instead of an IconVG graphic's.
+func IsAnimation(src []byte) bool {
+	return bytes.HasPrefix(src, []byte(animationMagic))
+}
+
+// DecodeAnimation decodes an encoded Animation. The frames' Graphic slices
+// alias src.
+//
+// It checks that each frame starts with the IconVG magic identifier, but it
+// does not otherwise validate the frames' graphics. Decoding or rendering a
+// frame does that.
+func DecodeAnimation(src []byte) (*Animation, error) {
+	if !IsAnimation(src) {
+		return nil, ErrInvalidMagicIdentifier
+	}
+	b := buffer(src[len(animationMagic):])
+
+	loopCount, n := b.decodeNatural()
+	if n == 0 {
+		return nil, ErrInvalidAnimation
+	}
+	b = b[n:]
+	nFrames, n := b.decodeNatural()
+	if n == 0 {
+		return nil, ErrInvalidAnimation
+	}
+	b = b[n:]
+	// Each frame table entry is at least 2 bytes long, so an impossibly
+	// large frame count is rejected before allocating for it.
+	if int64(nFrames) > int64(len(b))/2 {
+		return nil, ErrInvalidAnimation
+	}
+
+	a := &Animation{
+		LoopCount: int(loopCount),
+		Frames:    make([]Frame, nFrames),
+	}
+	lengths := make([]uint32, nFrames)
+	for i := range a.Frames {
+		ms, n := b.decodeNatural()
+		if n == 0 {
+			return nil, ErrInvalidAnimation
+		}
+		b = b[n:]
+		a.Frames[i].Duration = time.Duration(ms) * time.Millisecond
+		if lengths[i], n = b.decodeNatural(); n == 0 {
+			return nil, ErrInvalidAnimation
+		}
+		b = b[n:]
+	}
+	for i, length := range lengths {
+		if uint64(length) > uint64(len(b)) {
+			return nil, ErrInvalidAnimation
+		}
+		a.Frames[i].Graphic = b[:length:length]
+		b = b[length:]
+		if !bytes.HasPrefix(a.Frames[i].Graphic, magicBytes) {
+			return nil, ErrInvalidAnimation
+		}
+	}
+	if len(b) != 0 {
+		return nil, ErrInvalidAnimation
+	}
+	return a, nil
+}
+
+// EncodeAnimation encodes an Animation. Frame durations are rounded to the
+// nearest millisecond.
+func EncodeAnimation(a *Animation) ([]byte, error) {
+	if (a.LoopCount < 0) || (a.LoopCount >= maxNatural) {
+		return nil, errInvalidLoopCount
+	} else if len(a.Frames) >= maxNatural {
+		return nil, errInvalidAnimationFrame
+	}
+
+	b := buffer(animationMagic)
+	b.encodeNatural(uint32(a.LoopCount))
+	b.encodeNatural(uint32(len(a.Frames)))
+	for _, f := range a.Frames {
+		ms := f.Duration.Round(time.Millisecond).Milliseconds()
+		if (ms < 0) || (ms >= maxNatural) {
+			return nil, errInvalidFrameDuration
+		} else if (len(f.Graphic) >= maxNatural) || !bytes.HasPrefix(f.Graphic, magicBytes) {
+			return nil, errInvalidAnimationFrame
+		}
+		b.encodeNatural(uint32(ms))
+		b.encodeNatural(uint32(len(f.Graphic)))
+	}
+	for _, f := range a.Frames {
+		b = append(b, f.Graphic...)
+	}
+	return []byte(b), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func readAnimationFrames(t *testing.T) (a, b []byte) {
+	t.Helper()
+	a, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestAnimationRoundTrip(t *testing.T) {
+	a, b := readAnimationFrames(t)
+	testCases := []lowlevel.Animation{
+		{},
+		{LoopCount: 0, Frames: []lowlevel.Frame{{Duration: 100 * time.Millisecond, Graphic: a}}},
+		{LoopCount: 3, Frames: []lowlevel.Frame{
+			{Duration: 100 * time.Millisecond, Graphic: a},
+			{Duration: 0, Graphic: b},
+			{Duration: 2 * time.Second, Graphic: a},
+		}},
+	}
+	for i, tc := range testCases {
+		enc, err := lowlevel.EncodeAnimation(&tc)
+		if err != nil {
+			t.Errorf("#%d: EncodeAnimation: %v", i, err)
+			continue
+		}
+		if !lowlevel.IsAnimation(enc) {
+			t.Errorf("#%d: IsAnimation: got false, want true", i)
+		}
+		got, err := lowlevel.DecodeAnimation(enc)
+		if err != nil {
+			t.Errorf("#%d: DecodeAnimation: %v", i, err)
+			continue
+		}
+		if got.LoopCount != tc.LoopCount || len(got.Frames) != len(tc.Frames) {
+			t.Errorf("#%d: got %d frames, loop count %d, want %d, %d",
+				i, len(got.Frames), got.LoopCount, len(tc.Frames), tc.LoopCount)
+			continue
+		}
+		for j := range got.Frames {
+			if !reflect.DeepEqual(got.Frames[j], tc.Frames[j]) {
+				t.Errorf("#%d: frame #%d: got duration %v, %d bytes, want %v, %d bytes", i, j,
+					got.Frames[j].Duration, len(got.Frames[j].Graphic),
+					tc.Frames[j].Duration, len(tc.Frames[j].Graphic))
+			}
+		}
+	}
+}
+
+func TestEncodeAnimationRounding(t *testing.T) {
+	a, _ := readAnimationFrames(t)
+	enc, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		Frames: []lowlevel.Frame{{Duration: 1500 * time.Microsecond, Graphic: a}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := lowlevel.DecodeAnimation(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, want := got.Frames[0].Duration, 2*time.Millisecond; d != want {
+		t.Errorf("got %v, want %v", d, want)
+	}
+}
+
+func TestEncodeAnimationErrors(t *testing.T) {
+	a, _ := readAnimationFrames(t)
+	testCases := []struct {
+		desc string
+		a    lowlevel.Animation
+	}{
+		{"negative loop count", lowlevel.Animation{LoopCount: -1}},
+		{"huge loop count", lowlevel.Animation{LoopCount: 1 << 30}},
+		{"negative duration", lowlevel.Animation{Frames: []lowlevel.Frame{{Duration: -time.Second, Graphic: a}}}},
+		{"huge duration", lowlevel.Animation{Frames: []lowlevel.Frame{{Duration: 1e6 * time.Hour, Graphic: a}}}},
+		{"empty graphic", lowlevel.Animation{Frames: []lowlevel.Frame{{}}}},
+		{"not a graphic", lowlevel.Animation{Frames: []lowlevel.Frame{{Graphic: []byte("GIF89a")}}}},
+	}
+	for _, tc := range testCases {
+		if _, err := lowlevel.EncodeAnimation(&tc.a); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestDecodeAnimationErrors(t *testing.T) {
+	a, _ := readAnimationFrames(t)
+	valid, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		Frames: []lowlevel.Frame{{Duration: time.Second, Graphic: a}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The only frame's graphic is at the end of the encoding.
+	notGraphic := append([]byte(nil), valid...)
+	notGraphic[len(valid)-len(a)] = 'X'
+
+	testCases := []struct {
+		desc string
+		src  []byte
+		want error
+	}{
+		{"empty", nil, lowlevel.ErrInvalidMagicIdentifier},
+		{"graphic", a, lowlevel.ErrInvalidMagicIdentifier},
+		{"magic only", []byte("\x89IVA"), lowlevel.ErrInvalidAnimation},
+		{"too many frames", []byte("\x89IVA\x00\xfe"), lowlevel.ErrInvalidAnimation},
+		{"truncated", valid[:len(valid)-1], lowlevel.ErrInvalidAnimation},
+		{"trailing bytes", append(append([]byte(nil), valid...), 0), lowlevel.ErrInvalidAnimation},
+		{"frame is not a graphic", notGraphic, lowlevel.ErrInvalidAnimation},
+	}
+	for _, tc := range testCases {
+		_, err := lowlevel.DecodeAnimation(tc.src)
+		if err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, err, tc.want)
+		}
+	}
+	if got, err := lowlevel.DecodeAnimation(valid); err != nil || !bytes.Equal(got.Frames[0].Graphic, a) {
+		t.Errorf("valid: got %v, want the frame", err)
+	}
+}
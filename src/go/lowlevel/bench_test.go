@@ -0,0 +1,69 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// benchmarkCorpus is the test/data graphics that the benchmarks run on, from
+// simple to complex: a one-path icon, an illustration with gradients and an
+// illustration with many paths and blends.
+var benchmarkCorpus = []string{
+	"action-info.lores",
+	"cowbell",
+	"favicon",
+}
+
+// runBenchmarkCorpus runs f as a sub-benchmark for each graphic in the
+// benchmark corpus, reporting allocations.
+func runBenchmarkCorpus(b *testing.B, f func(b *testing.B, src []byte)) {
+	for _, name := range benchmarkCorpus {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			f(b, src)
+		})
+	}
+}
+
+// BenchmarkDecode measures the decoder virtual machine on its own, with no
+// Destination.
+func BenchmarkDecode(b *testing.B) {
+	runBenchmarkCorpus(b, func(b *testing.B, src []byte) {
+		for i := 0; i < b.N; i++ {
+			if err := lowlevel.Decode(nil, src, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDecodeMetadata(b *testing.B) {
+	runBenchmarkCorpus(b, func(b *testing.B, src []byte) {
+		for i := 0; i < b.N; i++ {
+			if _, err := lowlevel.DecodeMetadata(src); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
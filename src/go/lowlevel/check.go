@@ -0,0 +1,123 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+// checker handles the violations of the specification that lenient decoding
+// repairs or ignores, as listed for DecodeOptions.Strict. In strict mode, each
+// violation is an error. Otherwise, each is passed to onWarning.
+//
+// Decoding still repairs violations when its checker is nil, which it is if
+// the DecodeOptions neither set Strict nor OnWarning, but it does not detect
+// those violations that need no repair, such as metadata chunks that are out
+// of order.
+type checker struct {
+	strict    bool
+	onWarning func(w *DecodeError)
+
+	// offset, opcode and inMetadata locate the current instruction or
+	// metadata chunk, as for a DecodeError.
+	offset     int
+	opcode     byte
+	inMetadata bool
+
+	// lastMID is the most recent metadata chunk's MID, or -1 if there was
+	// no such chunk.
+	lastMID int64
+}
+
+// newChecker returns a checker for opts, re-using storage if it is non-nil.
+// It returns nil if opts do not ask to detect violations.
+func newChecker(storage *checker, opts *DecodeOptions) *checker {
+	if (opts == nil) || (!opts.Strict && (opts.OnWarning == nil)) {
+		return nil
+	} else if storage == nil {
+		storage = &checker{}
+	}
+	*storage = checker{
+		strict:    opts.Strict,
+		onWarning: opts.OnWarning,
+		lastMID:   -1,
+	}
+	return storage
+}
+
+// locate sets the location of subsequent violations.
+func (c *checker) locate(offset int, opcode byte, inMetadata bool) {
+	if c != nil {
+		c.offset, c.opcode, c.inMetadata = offset, opcode, inMetadata
+	}
+}
+
+// violation reports a violation of the specification. It returns the error to
+// stop decoding with, which is non-nil only in strict mode.
+func (c *checker) violation(reason FormatError) error {
+	if c == nil {
+		return nil
+	} else if c.strict {
+		return reason
+	} else if c.onWarning != nil {
+		c.onWarning(&DecodeError{
+			Offset:     c.offset,
+			Opcode:     c.opcode,
+			InMetadata: c.inMetadata,
+			Reason:     reason,
+		})
+	}
+	return nil
+}
+
+// checkMID checks that metadata chunks' MIDs are in increasing order.
+func (c *checker) checkMID(mid uint32) error {
+	if c == nil {
+		return nil
+	}
+	lastMID := c.lastMID
+	c.lastMID = int64(mid)
+	if int64(mid) <= lastMID {
+		return c.violation(ErrInvalidMetadataIdentifierOrder)
+	}
+	return nil
+}
+
+// checkColor checks, and repairs, a color that is set in a CREG register. A
+// direct color must be alpha-premultiplied or, if not, a gradient whose
+// reserved bits are zero. Indirect colors are only resolved by a Destination,
+// and blending valid colors gives a valid color.
+func checkColor(c Color, chk *checker) (Color, error) {
+	if c.typ != ColorTypeRGBA {
+		return c, nil
+	}
+	rgba := &c.data
+	if (rgba.A == 0x00) && (rgba.B&0x80 != 0) {
+		if rgba.R&0xc0 == 0 {
+			return c, nil
+		}
+		rgba.R &= 0x3f
+		return c, chk.violation(ErrReservedGradientBits)
+	} else if validAlphaPremulColor(*rgba) {
+		return c, nil
+	}
+	rgba.R = minUint8(rgba.R, rgba.A)
+	rgba.G = minUint8(rgba.G, rgba.A)
+	rgba.B = minUint8(rgba.B, rgba.A)
+	return c, chk.violation(ErrNonPremultipliedColor)
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
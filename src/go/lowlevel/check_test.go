@@ -0,0 +1,110 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	testCases := []struct {
+		desc string
+		asm  string
+		want lowlevel.FormatError
+		// wantCReg0 is CREG[0] after lenient decoding.
+		wantCReg0 color.RGBA
+	}{{
+		desc:      "valid",
+		asm:       "metadata 0\ncreg.4 [csel] #80000080\npath [csel] 0 0\nL 1 0\nz",
+		wantCReg0: color.RGBA{0x80, 0x00, 0x00, 0x80},
+	}, {
+		desc:      "non-premultiplied CREG color",
+		asm:       "metadata 0\ncreg.4 [csel] #ff000080\npath [csel] 0 0\nL 1 0\nz",
+		want:      lowlevel.ErrNonPremultipliedColor,
+		wantCReg0: color.RGBA{0x80, 0x00, 0x00, 0x80},
+	}, {
+		desc:      "reserved gradient bits",
+		asm:       "metadata 0\ncreg.4 [csel] #c1008000\npath [csel] 0 0\nL 1 0\nz",
+		want:      lowlevel.ErrReservedGradientBits,
+		wantCReg0: color.RGBA{0x01, 0x00, 0x80, 0x00},
+	}, {
+		desc:      "non-premultiplied palette color",
+		asm:       "metadata 1\npalette.4 #ff000080",
+		want:      lowlevel.ErrNonPremultipliedColor,
+		wantCReg0: color.RGBA{0x00, 0x00, 0x00, 0xff},
+	}, {
+		desc:      "metadata out of order",
+		asm:       "metadata 2\npalette.3 #ff0000\nviewBox -32 -32 32 32",
+		want:      lowlevel.ErrInvalidMetadataIdentifierOrder,
+		wantCReg0: color.RGBA{0xff, 0x00, 0x00, 0xff},
+	}, {
+		desc:      "unfinished path",
+		asm:       "metadata 0\npath [csel] 0 0\nL 1 0",
+		want:      lowlevel.ErrUnfinishedPath,
+		wantCReg0: color.RGBA{0x00, 0x00, 0x00, 0xff},
+	}}
+	for _, tc := range testCases {
+		src, err := ivgasm.Assemble([]byte("magic\n" + tc.asm))
+		if err != nil {
+			t.Fatalf("%s: Assemble: %v", tc.desc, err)
+		}
+
+		strict := &lowlevel.DecodeOptions{Strict: true}
+		errs := map[string]error{
+			"Decode":        lowlevel.Decode(nil, src, strict),
+			"DecodeFixed":   lowlevel.DecodeFixed(&fixedRecorder{scale: 1 << 6}, src, strict),
+			"StreamDecoder": lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(nil, strict),
+		}
+		for name, err := range errs {
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("%s: %s: strict: got %v, want nil", tc.desc, name, err)
+				}
+			} else if !errors.Is(err, tc.want) {
+				t.Errorf("%s: %s: strict: got %v, want %q", tc.desc, name, err, tc.want)
+			}
+		}
+
+		var warnings []error
+		vm := lowlevel.NewVM(nil)
+		err = lowlevel.Decode(vm, src, &lowlevel.DecodeOptions{
+			OnWarning: func(w *lowlevel.DecodeError) { warnings = append(warnings, w.Reason) },
+		})
+		if err != nil {
+			t.Errorf("%s: lenient: %v", tc.desc, err)
+			continue
+		}
+		if tc.want == "" {
+			if len(warnings) != 0 {
+				t.Errorf("%s: lenient: got warnings %v, want none", tc.desc, warnings)
+			}
+		} else if len(warnings) != 1 || warnings[0] != tc.want {
+			t.Errorf("%s: lenient: got warnings %v, want [%q]", tc.desc, warnings, tc.want)
+		}
+		if got := vm.State().CReg[0]; got != tc.wantCReg0 {
+			t.Errorf("%s: lenient: CREG[0]: got %v, want %v", tc.desc, got, tc.wantCReg0)
+		}
+
+		if err := lowlevel.Decode(nil, src, nil); err != nil {
+			t.Errorf("%s: default: %v", tc.desc, err)
+		}
+	}
+}
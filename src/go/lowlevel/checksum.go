@@ -0,0 +1,50 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// AddChecksum returns a copy of the IconVG graphic src with a checksum (see
+// Metadata.Checksum), replacing any existing one. Like RewritePalette, only
+// the metadata is decoded and re-encoded.
+func AddChecksum(src []byte) ([]byte, error) {
+	return rewriteMetadata(src, func(m *Metadata) { m.Checksum = true })
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the CRC-32C of the IconVG graphic src, computed as if the
+// 4 byte checksum at src[at:at+4] were zero.
+func checksum(src []byte, at int) uint32 {
+	c := crc32.Update(0, castagnoli, src[:at])
+	c = crc32.Update(c, castagnoli, make([]byte, 4))
+	return crc32.Update(c, castagnoli, src[at+4:])
+}
+
+// verifyChecksum checks the 4 byte checksum at src[at:at+4].
+func verifyChecksum(src []byte, at int) error {
+	if checksum(src, at) != binary.LittleEndian.Uint32(src[at:]) {
+		return annotate(ErrInvalidChecksum, at, 0, true)
+	}
+	return nil
+}
+
+// sealChecksum sets the 4 byte checksum at dst[at:at+4].
+func sealChecksum(dst []byte, at int) {
+	binary.LittleEndian.PutUint32(dst[at:], checksum(dst, at))
+}
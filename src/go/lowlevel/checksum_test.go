@@ -0,0 +1,169 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// decodeErrors returns the errors from decoding src in every way that verifies
+// a checksum.
+func decodeErrors(src []byte) map[string]error {
+	_, err := lowlevel.DecodeMetadata(src)
+	return map[string]error{
+		"Decode":         lowlevel.Decode(lowlevel.NopDestination{}, src, nil),
+		"DecodeMetadata": err,
+		"StreamDecoder":  lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(lowlevel.NopDestination{}, nil),
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	m := lowlevel.Metadata{
+		ViewBox:  lowlevel.DefaultViewBox,
+		Palette:  lowlevel.DefaultPalette,
+		Title:    "Triangle",
+		Checksum: true,
+	}
+	draw := func(e *lowlevel.Encoder) {
+		e.StartPath(0, -16, -16)
+		e.AbsLineTo(16, -16)
+		e.RelLineTo(-16, 32)
+		e.ClosePathEndPath()
+	}
+	e := &lowlevel.Encoder{}
+	e.Reset(m)
+	draw(e)
+	src, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = append([]byte(nil), src...)
+	for name, err := range decodeErrors(src) {
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if got, err := lowlevel.DecodeMetadata(src); err != nil {
+		t.Fatal(err)
+	} else if !got.Checksum {
+		t.Errorf("DecodeMetadata: Checksum: got false, want true")
+	}
+
+	// An Encoder with a Writer writes the same bytes.
+	buf := &bytes.Buffer{}
+	e.SetWriter(buf)
+	e.Reset(m)
+	draw(e)
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), src) {
+		t.Errorf("Flush: got % x, want % x", buf.Bytes(), src)
+	}
+
+	// Changing the checksum, or any of the opcodes that follow it, is
+	// detected, unless it makes for a different format error.
+	at := bytes.Index(src, []byte("\x0a\x06")) + 2
+	for i := at; i < len(src); i++ {
+		corrupt := append([]byte(nil), src...)
+		corrupt[i] ^= 0x40
+		for name, err := range decodeErrors(corrupt) {
+			if err == nil {
+				t.Errorf("byte %d: %s: got nil error, want non-nil", i, name)
+			}
+		}
+	}
+	corrupt := append([]byte(nil), src...)
+	corrupt[len(corrupt)-2] ^= 0x40
+	for name, err := range decodeErrors(corrupt) {
+		if !errors.Is(err, lowlevel.ErrInvalidChecksum) {
+			t.Errorf("last path: %s: got %v, want %v", name, err, lowlevel.ErrInvalidChecksum)
+		}
+	}
+}
+
+func TestChecksumChunkLength(t *testing.T) {
+	// The checksum chunk's data must be exactly 4 bytes.
+	for _, src := range []string{
+		"\x89IVG\x02\x08\x06\x00\x00\x00",
+		"\x89IVG\x02\x0c\x06\x00\x00\x00\x00\x00",
+	} {
+		for name, err := range decodeErrors([]byte(src)) {
+			if !errors.Is(err, lowlevel.ErrInvalidChecksum) {
+				t.Errorf("% x: %s: got %v, want %v", src, name, err, lowlevel.ErrInvalidChecksum)
+			}
+		}
+	}
+}
+
+func TestAddChecksum(t *testing.T) {
+	for _, filename := range []string{"action-info.lores.ivg", "blank.ivg", "cowbell.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := lowlevel.AddChecksum(src)
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		for name, err := range decodeErrors(got) {
+			if err != nil {
+				t.Errorf("%s: %s: %v", filename, name, err)
+			}
+		}
+		m0, err := lowlevel.DecodeMetadata(src)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		m1, err := lowlevel.DecodeMetadata(got)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if !m1.Checksum {
+			t.Errorf("%s: Checksum: got false, want true", filename)
+		}
+		m1.Checksum = false
+		if !m1.Equal(&m0) {
+			t.Errorf("%s: metadata: got %+v, want %+v", filename, m1, m0)
+		}
+		// Adding a checksum again replaces the first.
+		again, err := lowlevel.AddChecksum(got)
+		if err != nil {
+			t.Errorf("%s: again: %v", filename, err)
+		} else if !bytes.Equal(again, got) {
+			t.Errorf("%s: again: got % x, want % x", filename, again, got)
+		}
+
+		// Rewriting the palette recomputes the checksum.
+		pal := lowlevel.DefaultPalette
+		pal[0].R = 0xff
+		rewritten, err := lowlevel.RewritePalette(got, pal)
+		if err != nil {
+			t.Errorf("%s: RewritePalette: %v", filename, err)
+			continue
+		}
+		for name, err := range decodeErrors(rewritten) {
+			if err != nil {
+				t.Errorf("%s: RewritePalette: %s: %v", filename, name, err)
+			}
+		}
+	}
+}
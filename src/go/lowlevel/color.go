@@ -16,29 +16,45 @@ package lowlevel
 
 import (
 	"image/color"
+	"math"
+	"strconv"
 )
 
 func validAlphaPremulColor(c color.RGBA) bool {
 	return c.R <= c.A && c.G <= c.A && c.B <= c.A
 }
 
-// colorType distinguishes types of Colors.
-type colorType uint8
+// ColorType distinguishes types of Colors.
+type ColorType uint8
 
 const (
-	// colorTypeRGBA is a direct RGBA color.
-	colorTypeRGBA colorType = iota
+	// ColorTypeRGBA is a direct RGBA color.
+	ColorTypeRGBA ColorType = iota
 
-	// colorTypePaletteIndex is an indirect color, indexing the custom palette.
-	colorTypePaletteIndex
+	// ColorTypePaletteIndex is an indirect color, indexing the custom palette.
+	ColorTypePaletteIndex
 
-	// colorTypeCReg is an indirect color, indexing the CREG color registers.
-	colorTypeCReg
+	// ColorTypeCReg is an indirect color, indexing the CREG color registers.
+	ColorTypeCReg
 
-	// colorTypeBlend is an indirect color, blending two other colors.
-	colorTypeBlend
+	// ColorTypeBlend is an indirect color, blending two other colors.
+	ColorTypeBlend
 )
 
+func (t ColorType) String() string {
+	switch t {
+	case ColorTypeRGBA:
+		return "RGBA"
+	case ColorTypePaletteIndex:
+		return "PaletteIndex"
+	case ColorTypeCReg:
+		return "CReg"
+	case ColorTypeBlend:
+		return "Blend"
+	}
+	return "ColorType(" + strconv.Itoa(int(t)) + ")"
+}
+
 // Color is an IconVG color, whose RGBA values can depend on context. Some
 // Colors are direct RGBA values. Other Colors are indirect, referring to an
 // index of the custom palette, a color register of the decoder virtual
@@ -46,7 +62,7 @@ const (
 //
 // See the "Colors" section in the specification for details.
 type Color struct {
-	typ  colorType
+	typ  ColorType
 	data color.RGBA
 }
 
@@ -55,21 +71,96 @@ func (c Color) paletteIndex() uint8      { return c.data.R }
 func (c Color) cReg() uint8              { return c.data.R }
 func (c Color) blend() (t, c0, c1 uint8) { return c.data.R, c.data.G, c.data.B }
 
+// Type returns whether the Color is direct, a palette index, a CREG reference
+// or a blend.
+func (c Color) Type() ColorType { return c.typ }
+
+// RGBA returns the Color's RGBA value, and whether it is a direct Color. An
+// indirect Color's RGBA value depends on context: see Resolve.
+func (c Color) RGBA() (rgba color.RGBA, ok bool) {
+	if c.typ != ColorTypeRGBA {
+		return color.RGBA{}, false
+	}
+	return c.rgba(), true
+}
+
+// PaletteIndex returns the index of the custom palette that the Color refers
+// to, and whether it is a palette index Color.
+func (c Color) PaletteIndex() (i uint8, ok bool) {
+	if c.typ != ColorTypePaletteIndex {
+		return 0, false
+	}
+	return c.paletteIndex(), true
+}
+
+// CReg returns the CREG color register that the Color refers to, and whether
+// it is a CREG Color.
+func (c Color) CReg() (i uint8, ok bool) {
+	if c.typ != ColorTypeCReg {
+		return 0, false
+	}
+	return c.cReg(), true
+}
+
+// Blend returns the arguments that the Color was created with by BlendColor,
+// and whether it is a blend Color. The blend is t/255 of the way from c0 to
+// c1, which are 1 byte color encodings: see BlendedColors.
+func (c Color) Blend() (t, c0, c1 uint8, ok bool) {
+	if c.typ != ColorTypeBlend {
+		return 0, 0, 0, false
+	}
+	t, c0, c1 = c.blend()
+	return t, c0, c1, true
+}
+
+// BlendedColors is like Blend but returns the two blended Colors, decoded from
+// their 1 byte color encodings.
+func (c Color) BlendedColors() (t uint8, c0, c1 Color, ok bool) {
+	if c.typ != ColorTypeBlend {
+		return 0, Color{}, Color{}, false
+	}
+	t, x0, x1 := c.blend()
+	return t, decodeColor1(x0), decodeColor1(x1), true
+}
+
+// PaletteRef returns the custom palette index that the Color refers to, and
+// the opacity that it is applied with, if the Color is a palette index Color
+// or a blend of one with transparent black. Such Colors follow a theme's
+// palette, so that converters can map them to named, themable colors.
+func (c Color) PaletteRef() (i uint8, opacity float32, ok bool) {
+	if i, ok := c.PaletteIndex(); ok {
+		return i, 1, true
+	}
+	t, c0, c1, ok := c.BlendedColors()
+	if !ok || t == 0xff {
+		return 0, 0, false
+	}
+	if rgba, ok := c1.RGBA(); !ok || rgba != (color.RGBA{}) {
+		return 0, 0, false
+	}
+	if i, ok := c0.PaletteIndex(); ok {
+		return i, float32(0xff-t) / 0xff, true
+	}
+	return 0, 0, false
+}
+
 // Resolve resolves the Color's RGBA value, given its context: the custom
 // palette and the color registers of the decoder virtual machine.
 func (c Color) Resolve(pal *Palette, cReg *[64]color.RGBA) color.RGBA {
-	switch c.typ {
-	case colorTypeRGBA:
-		return c.rgba()
-	case colorTypePaletteIndex:
-		return pal[c.paletteIndex()&0x3f]
-	case colorTypeCReg:
-		return cReg[c.cReg()&0x3f]
+	if c.typ != ColorTypeBlend {
+		return c.resolve1(pal, cReg)
 	}
+	// A blend's two components are 1 byte colors, which are never blends
+	// themselves, so blends nest at most one level deep.
 	t, c0, c1 := c.blend()
+	rgba0 := decodeColor1(c0).resolve1(pal, cReg)
+	rgba1 := decodeColor1(c1).resolve1(pal, cReg)
+	return blendRGBA(t, rgba0, rgba1)
+}
+
+// blendRGBA returns the color t/255 of the way from rgba0 to rgba1.
+func blendRGBA(t uint8, rgba0, rgba1 color.RGBA) color.RGBA {
 	p, q := uint32(255-t), uint32(t)
-	rgba0 := decodeColor1(c0).Resolve(pal, cReg)
-	rgba1 := decodeColor1(c1).Resolve(pal, cReg)
 	return color.RGBA{
 		uint8(((p * uint32(rgba0.R)) + q*uint32(rgba1.R) + 128) / 255),
 		uint8(((p * uint32(rgba0.G)) + q*uint32(rgba1.G) + 128) / 255),
@@ -78,16 +169,127 @@ func (c Color) Resolve(pal *Palette, cReg *[64]color.RGBA) color.RGBA {
 	}
 }
 
+// Resolve64 is like Resolve but returns 16 bits per channel. Blends are
+// computed at that precision, instead of being rounded to 8 bits per channel,
+// so compositing the result into a 16 bit destination loses nothing further.
+func (c Color) Resolve64(pal *Palette, cReg *[64]color.RGBA) color.RGBA64 {
+	if c.typ != ColorTypeBlend {
+		r, g, b, a := c.resolve1(pal, cReg).RGBA()
+		return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}
+	}
+	t, c0, c1 := c.blend()
+	p, q := uint32(255-t), uint32(t)
+	r0, g0, b0, a0 := decodeColor1(c0).resolve1(pal, cReg).RGBA()
+	r1, g1, b1, a1 := decodeColor1(c1).resolve1(pal, cReg).RGBA()
+	return color.RGBA64{
+		uint16(((p * r0) + q*r1 + 127) / 255),
+		uint16(((p * g0) + q*g1 + 127) / 255),
+		uint16(((p * b0) + q*b1 + 127) / 255),
+		uint16(((p * a0) + q*a1 + 127) / 255),
+	}
+}
+
+// ResolveNRGBA is like Resolve but returns non-alpha-premultiplied color. The
+// straight alpha values are derived from the 16 bit result of Resolve64, which
+// keeps more precision in translucent colors than converting Resolve's 8 bit
+// result would.
+func (c Color) ResolveNRGBA(pal *Palette, cReg *[64]color.RGBA) color.NRGBA {
+	rgba := c.Resolve64(pal, cReg)
+	a := uint32(rgba.A)
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	ch := func(v uint16) uint8 {
+		// An invalid premultiplied color can have a channel exceeding alpha.
+		x := (uint32(v)*0xff + a/2) / a
+		if x > 0xff {
+			x = 0xff
+		}
+		return uint8(x)
+	}
+	return color.NRGBA{ch(rgba.R), ch(rgba.G), ch(rgba.B), uint8((a*0xff + 0x7fff) / 0xffff)}
+}
+
+// ResolveLinear is like Resolve but blends in linear light instead of in the
+// sRGB color space. Blending in sRGB, as the specification does, gives darker
+// midpoints than most design tools, which blend in linear light, do.
+func (c Color) ResolveLinear(pal *Palette, cReg *[64]color.RGBA) color.RGBA {
+	if c.typ != ColorTypeBlend {
+		return c.resolve1(pal, cReg)
+	}
+	t, c0, c1 := c.blend()
+	rgba0 := decodeColor1(c0).resolve1(pal, cReg)
+	rgba1 := decodeColor1(c1).resolve1(pal, cReg)
+	return lerpLinear(float64(t)/255, rgba0, rgba1)
+}
+
+// lerpLinear interpolates, in linear light, between two alpha-premultiplied
+// sRGB colors. f=0 gives c0 and f=1 gives c1.
+func lerpLinear(f float64, c0, c1 color.RGBA) color.RGBA {
+	a0, a1 := float64(c0.A)/0xff, float64(c1.A)/0xff
+	a := a0*(1-f) + a1*f
+	if !(a > 0) {
+		return color.RGBA{}
+	}
+	ch := func(u0, u1 uint8) uint8 {
+		v0, v1 := 0.0, 0.0
+		if c0.A != 0 {
+			v0 = srgbToLinear(float64(u0)/float64(c0.A)) * a0
+		}
+		if c1.A != 0 {
+			v1 = srgbToLinear(float64(u1)/float64(c1.A)) * a1
+		}
+		v := linearToSRGB((v0*(1-f) + v1*f) / a)
+		return uint8(math.Round(math.Min(v, 1) * a * 0xff))
+	}
+	return color.RGBA{
+		R: ch(c0.R, c1.R),
+		G: ch(c0.G, c1.G),
+		B: ch(c0.B, c1.B),
+		A: uint8(math.Round(a * 0xff)),
+	}
+}
+
+// srgbToLinear and linearToSRGB are the sRGB transfer function and its
+// inverse, on values in the range [0, 1].
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// resolve1 is like Resolve but does not resolve blends, which it treats as
+// transparent black. It does not recurse.
+func (c Color) resolve1(pal *Palette, cReg *[64]color.RGBA) color.RGBA {
+	switch c.typ {
+	case ColorTypeRGBA:
+		return c.rgba()
+	case ColorTypePaletteIndex:
+		return pal[c.paletteIndex()&0x3f]
+	case ColorTypeCReg:
+		return cReg[c.cReg()&0x3f]
+	}
+	return color.RGBA{}
+}
+
 // RGBAColor returns a direct Color.
-func RGBAColor(c color.RGBA) Color { return Color{colorTypeRGBA, c} }
+func RGBAColor(c color.RGBA) Color { return Color{ColorTypeRGBA, c} }
 
 // PaletteIndexColor returns an indirect Color referring to an index of the
 // custom palette.
-func PaletteIndexColor(i uint8) Color { return Color{colorTypePaletteIndex, color.RGBA{R: i & 0x3f}} }
+func PaletteIndexColor(i uint8) Color { return Color{ColorTypePaletteIndex, color.RGBA{R: i & 0x3f}} }
 
 // CRegColor returns an indirect Color referring to a color register of the
 // decoder virtual machine.
-func CRegColor(i uint8) Color { return Color{colorTypeCReg, color.RGBA{R: i & 0x3f}} }
+func CRegColor(i uint8) Color { return Color{ColorTypeCReg, color.RGBA{R: i & 0x3f}} }
 
 // BlendColor returns an indirect Color that blends two other Colors. Those two
 // other Colors must both be encodable as a 1 byte color.
@@ -98,9 +300,21 @@ func CRegColor(i uint8) Color { return Color{colorTypeCReg, color.RGBA{R: i & 0x
 // example.
 //
 // See the "Colors" section in the specification for details.
-func BlendColor(t, c0, c1 uint8) Color { return Color{colorTypeBlend, color.RGBA{R: t, G: c0, B: c1}} }
+func BlendColor(t, c0, c1 uint8) Color { return Color{ColorTypeBlend, color.RGBA{R: t, G: c0, B: c1}} }
+
+// color1Table holds the decodings of every 1 byte color. Blends decode their
+// two components each time that they are resolved, so looking them up is
+// cheaper than recomputing them.
+var color1Table = func() (t [256]Color) {
+	for i := range t {
+		t[i] = computeColor1(byte(i))
+	}
+	return t
+}()
+
+func decodeColor1(x byte) Color { return color1Table[x] }
 
-func decodeColor1(x byte) Color {
+func computeColor1(x byte) Color {
 	if x >= 0x80 {
 		if x >= 0xc0 {
 			return CRegColor(x)
@@ -132,7 +346,7 @@ func is1(u uint8) bool { return u&0x3f == 0 || u == 0xff }
 
 func encodeColor1(c Color) (x byte, ok bool) {
 	switch c.typ {
-	case colorTypeRGBA:
+	case ColorTypeRGBA:
 		if c.data.A != 0xff {
 			switch c.data {
 			case color.RGBA{0x00, 0x00, 0x00, 0x00}:
@@ -148,9 +362,9 @@ func encodeColor1(c Color) (x byte, ok bool) {
 			b := c.data.B / 0x3f
 			return 25*r + 5*g + b, true
 		}
-	case colorTypePaletteIndex:
+	case ColorTypePaletteIndex:
 		return c.data.R | 0x80, true
-	case colorTypeCReg:
+	case ColorTypeCReg:
 		return c.data.R | 0xc0, true
 	}
 	return 0, false
@@ -159,7 +373,7 @@ func encodeColor1(c Color) (x byte, ok bool) {
 func is2(u uint8) bool { return u%0x11 == 0 }
 
 func encodeColor2(c Color) (x [2]byte, ok bool) {
-	if c.typ == colorTypeRGBA && is2(c.data.R) && is2(c.data.G) && is2(c.data.B) && is2(c.data.A) {
+	if c.typ == ColorTypeRGBA && is2(c.data.R) && is2(c.data.G) && is2(c.data.B) && is2(c.data.A) {
 		return [2]byte{
 			(c.data.R/0x11)<<4 | (c.data.G / 0x11),
 			(c.data.B/0x11)<<4 | (c.data.A / 0x11),
@@ -169,21 +383,21 @@ func encodeColor2(c Color) (x [2]byte, ok bool) {
 }
 
 func encodeColor3Direct(c Color) (x [3]byte, ok bool) {
-	if c.typ == colorTypeRGBA && c.data.A == 0xff {
+	if c.typ == ColorTypeRGBA && c.data.A == 0xff {
 		return [3]byte{c.data.R, c.data.G, c.data.B}, true
 	}
 	return [3]byte{}, false
 }
 
 func encodeColor4(c Color) (x [4]byte, ok bool) {
-	if c.typ == colorTypeRGBA {
+	if c.typ == ColorTypeRGBA {
 		return [4]byte{c.data.R, c.data.G, c.data.B, c.data.A}, true
 	}
 	return [4]byte{}, false
 }
 
 func encodeColor3Indirect(c Color) (x [3]byte, ok bool) {
-	if c.typ == colorTypeBlend {
+	if c.typ == ColorTypeBlend {
 		return [3]byte{c.data.R, c.data.G, c.data.B}, true
 	}
 	return [3]byte{}, false
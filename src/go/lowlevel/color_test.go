@@ -0,0 +1,224 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestColorAccessors(t *testing.T) {
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	testCases := []struct {
+		c             lowlevel.Color
+		wantType      lowlevel.ColorType
+		wantString    string
+		wantRGBA      bool
+		wantPalette   bool
+		wantCReg      bool
+		wantBlend     bool
+		wantIndex     uint8
+		wantBlendArgs [3]uint8
+	}{
+		{c: lowlevel.RGBAColor(red), wantType: lowlevel.ColorTypeRGBA, wantString: "RGBA", wantRGBA: true},
+		{c: lowlevel.PaletteIndexColor(5), wantType: lowlevel.ColorTypePaletteIndex, wantString: "PaletteIndex",
+			wantPalette: true, wantIndex: 5},
+		{c: lowlevel.PaletteIndexColor(0x45), wantType: lowlevel.ColorTypePaletteIndex, wantString: "PaletteIndex",
+			wantPalette: true, wantIndex: 5},
+		{c: lowlevel.CRegColor(63), wantType: lowlevel.ColorTypeCReg, wantString: "CReg",
+			wantCReg: true, wantIndex: 63},
+		{c: lowlevel.BlendColor(0x40, 0x80, 0xc1), wantType: lowlevel.ColorTypeBlend, wantString: "Blend",
+			wantBlend: true, wantBlendArgs: [3]uint8{0x40, 0x80, 0xc1}},
+	}
+	for _, tc := range testCases {
+		if got := tc.c.Type(); got != tc.wantType {
+			t.Errorf("%v: Type: got %v, want %v", tc.c, got, tc.wantType)
+		}
+		if got := tc.c.Type().String(); got != tc.wantString {
+			t.Errorf("%v: String: got %q, want %q", tc.c, got, tc.wantString)
+		}
+		if rgba, ok := tc.c.RGBA(); ok != tc.wantRGBA {
+			t.Errorf("%v: RGBA: got ok %t, want %t", tc.c, ok, tc.wantRGBA)
+		} else if ok && rgba != red {
+			t.Errorf("%v: RGBA: got %v, want %v", tc.c, rgba, red)
+		}
+		if i, ok := tc.c.PaletteIndex(); ok != tc.wantPalette {
+			t.Errorf("%v: PaletteIndex: got ok %t, want %t", tc.c, ok, tc.wantPalette)
+		} else if ok && i != tc.wantIndex {
+			t.Errorf("%v: PaletteIndex: got %d, want %d", tc.c, i, tc.wantIndex)
+		}
+		if i, ok := tc.c.CReg(); ok != tc.wantCReg {
+			t.Errorf("%v: CReg: got ok %t, want %t", tc.c, ok, tc.wantCReg)
+		} else if ok && i != tc.wantIndex {
+			t.Errorf("%v: CReg: got %d, want %d", tc.c, i, tc.wantIndex)
+		}
+		if bt, c0, c1, ok := tc.c.Blend(); ok != tc.wantBlend {
+			t.Errorf("%v: Blend: got ok %t, want %t", tc.c, ok, tc.wantBlend)
+		} else if got := [3]uint8{bt, c0, c1}; ok && got != tc.wantBlendArgs {
+			t.Errorf("%v: Blend: got %v, want %v", tc.c, got, tc.wantBlendArgs)
+		}
+	}
+	if got, want := lowlevel.ColorType(9).String(), "ColorType(9)"; got != want {
+		t.Errorf("invalid ColorType: got %q, want %q", got, want)
+	}
+}
+
+func TestBlendedColors(t *testing.T) {
+	// The blend arguments are 1 byte color encodings: 0x00 to 0x7c are
+	// direct colors, 0x80 to 0xbf are palette indexes and 0xc0 to 0xff are
+	// CREG indexes.
+	testCases := []struct {
+		arg  uint8
+		want lowlevel.Color
+	}{
+		{0x00, lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+		{0x64, lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})},
+		{0x7f, lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0x00})},
+		{0x83, lowlevel.PaletteIndexColor(3)},
+		{0xff, lowlevel.CRegColor(63)},
+	}
+	for _, tc := range testCases {
+		bt, c0, c1, ok := lowlevel.BlendColor(0x80, tc.arg, 0x80).BlendedColors()
+		if !ok {
+			t.Errorf("0x%02x: got ok false, want true", tc.arg)
+			continue
+		}
+		if bt != 0x80 {
+			t.Errorf("0x%02x: t: got 0x%02x, want 0x%02x", tc.arg, bt, 0x80)
+		}
+		if c0 != tc.want {
+			t.Errorf("0x%02x: c0: got %v, want %v", tc.arg, c0, tc.want)
+		}
+		if want := lowlevel.PaletteIndexColor(0); c1 != want {
+			t.Errorf("0x%02x: c1: got %v, want %v", tc.arg, c1, want)
+		}
+	}
+	if _, _, _, ok := lowlevel.PaletteIndexColor(0).BlendedColors(); ok {
+		t.Errorf("non-blend: got ok true, want false")
+	}
+}
+
+func TestResolveLinear(t *testing.T) {
+	pal := lowlevel.DefaultPalette
+	var cReg [64]color.RGBA
+	// 0x00 and 0x7c are the 1 byte encodings of opaque black and white.
+	// Blending them half way in sRGB gives 0x80, but in linear light gives
+	// the lighter 0xbc.
+	testCases := []struct {
+		c    lowlevel.Color
+		want color.RGBA
+	}{
+		{lowlevel.BlendColor(0x00, 0x00, 0x7c), color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{lowlevel.BlendColor(0x80, 0x00, 0x7c), color.RGBA{0xbc, 0xbc, 0xbc, 0xff}},
+		{lowlevel.BlendColor(0xff, 0x00, 0x7c), color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{lowlevel.BlendColor(0x80, 0x7f, 0x7c), color.RGBA{0x80, 0x80, 0x80, 0x80}},
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), color.RGBA{0x12, 0x34, 0x56, 0xff}},
+	}
+	for _, tc := range testCases {
+		if got := tc.c.ResolveLinear(&pal, &cReg); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.c, got, tc.want)
+		}
+		if _, _, _, ok := tc.c.Blend(); !ok {
+			if got, want := tc.c.ResolveLinear(&pal, &cReg), tc.c.Resolve(&pal, &cReg); got != want {
+				t.Errorf("%v: got %v, want Resolve's %v", tc.c, got, want)
+			}
+		}
+	}
+}
+
+func TestResolve64(t *testing.T) {
+	pal := lowlevel.DefaultPalette
+	pal[1] = color.RGBA{0x00, 0x80, 0x00, 0x80}
+	var cReg [64]color.RGBA
+	cReg[2] = color.RGBA{0x00, 0x00, 0xff, 0xff}
+	testCases := []struct {
+		c    lowlevel.Color
+		want color.RGBA64
+	}{
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), color.RGBA64{0x1212, 0x3434, 0x5656, 0xffff}},
+		{lowlevel.PaletteIndexColor(1), color.RGBA64{0x0000, 0x8080, 0x0000, 0x8080}},
+		{lowlevel.CRegColor(2), color.RGBA64{0x0000, 0x0000, 0xffff, 0xffff}},
+		// 0x00, 0x7c and 0x7f are the 1 byte encodings of opaque black,
+		// opaque white and transparent black.
+		{lowlevel.BlendColor(0x00, 0x00, 0x7c), color.RGBA64{0x0000, 0x0000, 0x0000, 0xffff}},
+		{lowlevel.BlendColor(0x80, 0x00, 0x7c), color.RGBA64{0x8080, 0x8080, 0x8080, 0xffff}},
+		{lowlevel.BlendColor(0x01, 0x00, 0x7c), color.RGBA64{0x0101, 0x0101, 0x0101, 0xffff}},
+		{lowlevel.BlendColor(0xff, 0x00, 0x7c), color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}},
+		{lowlevel.BlendColor(0x80, 0x7f, 0x7c), color.RGBA64{0x8080, 0x8080, 0x8080, 0x8080}},
+	}
+	for _, tc := range testCases {
+		if got := tc.c.Resolve64(&pal, &cReg); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.c, got, tc.want)
+		}
+		// Converting Resolve64's result to 8 bits gives Resolve's.
+		got := color.RGBAModel.Convert(tc.c.Resolve64(&pal, &cReg)).(color.RGBA)
+		if want := tc.c.Resolve(&pal, &cReg); got != want {
+			t.Errorf("%v: got %v, want Resolve's %v", tc.c, got, want)
+		}
+	}
+}
+
+func TestResolveNRGBA(t *testing.T) {
+	pal := lowlevel.DefaultPalette
+	var cReg [64]color.RGBA
+	testCases := []struct {
+		c    lowlevel.Color
+		want color.NRGBA
+	}{
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), color.NRGBA{0x12, 0x34, 0x56, 0xff}},
+		{lowlevel.RGBAColor(color.RGBA{0x40, 0x00, 0x00, 0x80}), color.NRGBA{0x80, 0x00, 0x00, 0x80}},
+		{lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0x00}), color.NRGBA{}},
+		// An invalid premultiplied color's channels are clamped.
+		{lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0x80}), color.NRGBA{0xff, 0x00, 0x00, 0x80}},
+		// Half way from transparent black to opaque white is translucent
+		// white.
+		{lowlevel.BlendColor(0x80, 0x7f, 0x7c), color.NRGBA{0xff, 0xff, 0xff, 0x80}},
+	}
+	for _, tc := range testCases {
+		if got := tc.c.ResolveNRGBA(&pal, &cReg); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestPaletteRef(t *testing.T) {
+	// 0x85 is palette index 5 and 0x7f is transparent black, as 1 byte color
+	// encodings.
+	testCases := []struct {
+		c           lowlevel.Color
+		wantIndex   uint8
+		wantOpacity float32
+		wantOK      bool
+	}{
+		{lowlevel.PaletteIndexColor(5), 5, 1, true},
+		{lowlevel.BlendColor(0x00, 0x85, 0x7f), 5, 1, true},
+		{lowlevel.BlendColor(0x40, 0x85, 0x7f), 5, float32(0xbf) / 0xff, true},
+		{lowlevel.BlendColor(0xff, 0x85, 0x7f), 0, 0, false},
+		{lowlevel.BlendColor(0x40, 0x85, 0x00), 0, 0, false},
+		{lowlevel.BlendColor(0x40, 0x7f, 0x85), 0, 0, false},
+		{lowlevel.BlendColor(0x40, 0xc5, 0x7f), 0, 0, false},
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), 0, 0, false},
+		{lowlevel.CRegColor(5), 0, 0, false},
+	}
+	for _, tc := range testCases {
+		i, opacity, ok := tc.c.PaletteRef()
+		if (i != tc.wantIndex) || (opacity != tc.wantOpacity) || (ok != tc.wantOK) {
+			t.Errorf("%v: got (%d, %v, %t), want (%d, %v, %t)",
+				tc.c, i, opacity, ok, tc.wantIndex, tc.wantOpacity, tc.wantOK)
+		}
+	}
+}
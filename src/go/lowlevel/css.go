@@ -0,0 +1,174 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses a CSS color string, such as "rebeccapurple", "#abc",
+// "#11223380", "rgba(12, 34, 56, 0.5)", "rgb(12 34 56 / 50%)" or
+// "hsl(270, 50%, 40%)", and returns it as a direct Color. Unlike CSS colors,
+// IconVG colors are alpha-premultiplied, and ParseColor does that
+// premultiplication.
+//
+// The returned Color is encoded in as few bytes as its RGBA value allows:
+// opaque colors whose channels are all 0x00, 0x40, 0x80, 0xc0 or 0xff, and a
+// few translucent grays, take 1 byte. Other colors take 2, 3 or 4 bytes.
+func ParseColor(s string) (Color, error) {
+	r, g, b, a, ok := parseCSSColor(strings.ToLower(strings.TrimSpace(s)))
+	if !ok {
+		return Color{}, fmt.Errorf("iconvg: invalid color %q", s)
+	}
+	a = clamp01(a)
+	return RGBAColor(color.RGBA{
+		R: uint8(math.Round(clamp01(r) * a * 0xff)),
+		G: uint8(math.Round(clamp01(g) * a * 0xff)),
+		B: uint8(math.Round(clamp01(b) * a * 0xff)),
+		A: uint8(math.Round(a * 0xff)),
+	}), nil
+}
+
+// parseCSSColor parses a lower case CSS color, returning its non-premultiplied
+// channels, nominally in the range [0, 1].
+func parseCSSColor(s string) (r, g, b, a float64, ok bool) {
+	if strings.HasPrefix(s, "#") {
+		return parseHexColor(s[1:])
+	} else if s == "transparent" {
+		return 0, 0, 0, 0, true
+	} else if s == "rebeccapurple" {
+		// CSS Color Level 4 added this to the SVG 1.1 color keywords.
+		return 0x66 / 255.0, 0x33 / 255.0, 0x99 / 255.0, 1, true
+	} else if c, ok := colornames.Map[s]; ok {
+		return float64(c.R) / 0xff, float64(c.G) / 0xff, float64(c.B) / 0xff, 1, true
+	}
+
+	i := strings.IndexByte(s, '(')
+	if (i < 0) || !strings.HasSuffix(s, ")") {
+		return 0, 0, 0, 0, false
+	}
+	name, args := strings.TrimSpace(s[:i]), s[i+1:len(s)-1]
+
+	// The alpha value is either the fourth comma separated argument or,
+	// in the space separated syntax, follows a slash.
+	alpha := ""
+	if j := strings.IndexByte(args, '/'); j >= 0 {
+		args, alpha = args[:j], args[j+1:]
+	}
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return (r == ',') || (r == ' ') || (r == '\t') || (r == '\n')
+	})
+	if (len(fields) == 4) && (alpha == "") {
+		fields, alpha = fields[:3], fields[3]
+	}
+	if len(fields) != 3 {
+		return 0, 0, 0, 0, false
+	}
+	a = 1
+	if alpha != "" {
+		if a, ok = parseCSSNumber(strings.TrimSpace(alpha), 1); !ok {
+			return 0, 0, 0, 0, false
+		}
+	}
+
+	var v [3]float64
+	switch name {
+	case "rgb", "rgba":
+		for k, f := range fields {
+			if v[k], ok = parseCSSNumber(f, 0xff); !ok {
+				return 0, 0, 0, 0, false
+			}
+			v[k] /= 0xff
+		}
+		return v[0], v[1], v[2], a, true
+
+	case "hsl", "hsla":
+		h := strings.TrimSuffix(fields[0], "deg")
+		if v[0], ok = parseCSSNumber(h, 360); !ok || strings.HasSuffix(h, "%") {
+			return 0, 0, 0, 0, false
+		}
+		for k, f := range fields[1:] {
+			if !strings.HasSuffix(f, "%") {
+				return 0, 0, 0, 0, false
+			} else if v[k+1], ok = parseCSSNumber(f, 1); !ok {
+				return 0, 0, 0, 0, false
+			}
+		}
+		r, g, b = hslToRGB(v[0]/360, clamp01(v[1]), clamp01(v[2]))
+		return r, g, b, a, true
+	}
+	return 0, 0, 0, 0, false
+}
+
+// parseHexColor parses the hexadecimal digits of a "#rgb", "#rgba", "#rrggbb"
+// or "#rrggbbaa" color.
+func parseHexColor(h string) (r, g, b, a float64, ok bool) {
+	if (len(h) == 3) || (len(h) == 4) {
+		h2 := make([]byte, 0, 2*len(h))
+		for i := 0; i < len(h); i++ {
+			h2 = append(h2, h[i], h[i])
+		}
+		h = string(h2)
+	}
+	if (len(h) != 6) && (len(h) != 8) {
+		return 0, 0, 0, 0, false
+	}
+	u, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+	if len(h) == 6 {
+		u = u<<8 | 0xff
+	}
+	return float64(u>>24) / 0xff, float64(u>>16&0xff) / 0xff, float64(u>>8&0xff) / 0xff, float64(u&0xff) / 0xff, true
+}
+
+// parseCSSNumber parses a number or a percentage, where 100% is full.
+func parseCSSNumber(s string, full float64) (float64, bool) {
+	percent := strings.HasSuffix(s, "%")
+	if percent {
+		s = s[:len(s)-1]
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if (err != nil) || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	if percent {
+		// Multiply before dividing, so that 50% of 255 is exactly 127.5.
+		f = f * full / 100
+	}
+	return f, true
+}
+
+// hslToRGB converts from the HSL color model, with the hue h in turns, as per
+// the CSS Color specification.
+func hslToRGB(h, s, l float64) (r, g, b float64) {
+	h -= math.Floor(h)
+	f := func(n float64) float64 {
+		k := math.Mod(n+h*12, 12)
+		return l - s*math.Min(l, 1-l)*math.Max(-1, math.Min(math.Min(k-3, 9-k), 1))
+	}
+	return f(0), f(8), f(4)
+}
+
+func clamp01(f float64) float64 {
+	return math.Max(0, math.Min(1, f))
+}
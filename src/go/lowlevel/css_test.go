@@ -0,0 +1,81 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestParseColor(t *testing.T) {
+	testCases := []struct {
+		s    string
+		want color.RGBA
+	}{
+		{"black", color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{"  Red ", color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{"rebeccapurple", color.RGBA{0x66, 0x33, 0x99, 0xff}},
+		{"transparent", color.RGBA{0x00, 0x00, 0x00, 0x00}},
+		{"#abc", color.RGBA{0xaa, 0xbb, 0xcc, 0xff}},
+		{"#abcd", color.RGBA{0x93, 0xa2, 0xb1, 0xdd}},
+		{"#112233", color.RGBA{0x11, 0x22, 0x33, 0xff}},
+		{"#ff000080", color.RGBA{0x80, 0x00, 0x00, 0x80}},
+		{"rgb(12, 34, 56)", color.RGBA{12, 34, 56, 0xff}},
+		{"rgba(255, 0, 0, 0.5)", color.RGBA{0x80, 0x00, 0x00, 0x80}},
+		{"rgb(255 0 0 / 50%)", color.RGBA{0x80, 0x00, 0x00, 0x80}},
+		{"rgb(100%, 0%, 50%)", color.RGBA{0xff, 0x00, 0x80, 0xff}},
+		{"rgb(300, -5, 0)", color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{"hsl(0, 100%, 50%)", color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{"hsl(120deg 100% 25%)", color.RGBA{0x00, 0x80, 0x00, 0xff}},
+		{"hsla(240, 100%, 50%, 0.5)", color.RGBA{0x00, 0x00, 0x80, 0x80}},
+		{"hsl(-120, 100%, 50%)", color.RGBA{0x00, 0x00, 0xff, 0xff}},
+	}
+	for _, tc := range testCases {
+		c, err := lowlevel.ParseColor(tc.s)
+		if err != nil {
+			t.Errorf("%q: %v", tc.s, err)
+			continue
+		}
+		if got, ok := c.RGBA(); !ok || got != tc.want {
+			t.Errorf("%q: got %v, %t, want %v, true", tc.s, got, ok, tc.want)
+		}
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	testCases := []string{
+		"",
+		"notacolor",
+		"#12",
+		"#12345",
+		"#gggggg",
+		"rgb(1, 2)",
+		"rgb(1, 2, 3, 4, 5)",
+		"rgb(1, 2, 3",
+		"rgb(a, b, c)",
+		"rgb(1, 2, 3 / x)",
+		"hsl(120, 100, 50%)",
+		"hsl(50%, 100%, 50%)",
+		"cmyk(1, 2, 3)",
+		"rgb(NaN, 0, 0)",
+	}
+	for _, tc := range testCases {
+		if _, err := lowlevel.ParseColor(tc); err == nil {
+			t.Errorf("%q: got nil error, want non-nil", tc)
+		}
+	}
+}
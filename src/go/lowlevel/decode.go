@@ -16,19 +16,51 @@ package lowlevel
 
 import (
 	"bytes"
+	"context"
 	"image/color"
+	"sync"
+	"unicode/utf8"
 )
 
 var midDescriptions = [...]string{
-	midViewBox:          "viewBox",
-	midSuggestedPalette: "suggested palette",
+	midViewBox:             "viewBox",
+	midSuggestedPalette:    "suggested palette",
+	midTitleAndDescription: "title and description",
+	midChecksum:            "checksum",
+	midDetailLevels:        "detail levels",
 }
 
 // Destination handles the actions decoded from an IconVG graphic's byte code.
+// It is a visitor: custom backends, such as GPU tessellators or document
+// writers, implement it to consume IconVG graphics without an intermediate
+// raster or a parser of their own. The raster package's Rasterizer and the
+// ivg package's decoder are examples.
 //
 // When passed to Decode, the first method called (if any) will be Reset. No
 // methods will be called at all if an error is encountered in the encoded form
 // before the metadata is fully decoded.
+//
+// The methods mirror the byte code's virtual machine, which the Destination
+// is responsible for executing if it needs to:
+//
+//   - SetCSel, SetNSel, SetCReg and SetNReg modify the CSEL and NSEL selector
+//     registers and the CREG and NREG color and number registers. CREG starts
+//     as Reset's Metadata.Palette and NREG starts as all zeroes. For SetCReg
+//     and SetNReg, the register is CREG[CSEL-adj] or NREG[NSEL-adj] (modulo
+//     64) and, if incr, the selector is incremented afterwards. The Color
+//     should be resolved (see Color.Resolve) when it is set, not when used.
+//   - SetLOD sets the level of detail bounds for subsequent paths.
+//   - StartPath starts a path, filled with CREG[CSEL-adj], with an absolute
+//     moveTo. The path's segments follow, ending with ClosePathEndPath.
+//     ClosePathAbsMoveTo and ClosePathRelMoveTo close the current sub-path
+//     and start another.
+//   - The remaining methods are path segments, as for SVG path data. "Abs"
+//     and "Rel" methods take absolute and relative (to the current point)
+//     coordinates. "Smooth" methods' first control point is the reflection
+//     of the previous segment's last control point. Arc rotations are
+//     measured in revolutions, not degrees.
+//
+// NopDestination can be embedded to implement only some of the methods.
 type Destination interface {
 	Reset(m Metadata)
 
@@ -66,33 +98,320 @@ type Destination interface {
 // human-readable commentary in fmt.Printf style.
 type printer func(b []byte, format string, args ...interface{})
 
+// quoted prints b, such as a metadata chunk's text, as quoted strings of up to
+// 4 bytes each, as the hexadecimal column only fits 4 bytes per line.
+func (p printer) quoted(b []byte) {
+	for len(b) > 0 {
+		n := len(b)
+		if n > 4 {
+			n = 4
+		}
+		p(b[:n], "    %q\n", b[:n])
+		b = b[n:]
+	}
+}
+
 // DecodeOptions are the optional parameters to the Decode function.
 type DecodeOptions struct {
 	// Palette is an optional 64 color palette. If one isn't provided, the
 	// IconVG graphic's suggested palette will be used.
 	Palette *Palette
+
+	// OnInstruction, if non-nil, is called before decoding each instruction
+	// (an opcode and its operands) that follows the metadata, with the byte
+	// offset of that instruction within the IconVG graphic. It lets tools
+	// such as linters relate Destination method calls to the byte code.
+	OnInstruction func(offset int)
+
+	// Trace, if non-nil, is called after decoding each instruction that
+	// follows the metadata, and after the Destination method calls that it
+	// made, with the instruction's byte offset, its opcode and the bytes of
+	// its operands. It is not called for an instruction that fails to decode.
+	// The args slice is only valid for the duration of the call.
+	//
+	// It lets tools show exactly how a graphic executes, which helps diagnose
+	// encoder bugs.
+	Trace func(offset int, op Opcode, args []byte)
+
+	// Strict is whether to reject every violation of the specification that
+	// decoding detects. By default, decoding is lenient: it rejects only the
+	// violations that it cannot recover from, such as a reserved opcode or an
+	// invalid viewBox, and repairs or ignores these others:
+	//
+	//   - a suggested palette color that is not alpha-premultiplied is
+	//     replaced by opaque black (ErrNonPremultipliedColor),
+	//   - a direct color, set in a CREG register, that is neither
+	//     alpha-premultiplied nor a gradient has its red, green and blue
+	//     clamped to its alpha (ErrNonPremultipliedColor),
+	//   - a gradient's reserved bits are cleared (ErrReservedGradientBits),
+	//   - metadata chunks whose MIDs are not in increasing order are decoded
+	//     anyway (ErrInvalidMetadataIdentifierOrder),
+	//   - a path that is unfinished at the end of the graphic is ignored,
+	//     without a ClosePathEndPath call (ErrUnfinishedPath).
+	//
+	// Asset validation wants strict decoding, and rendering for end users
+	// wants lenient decoding.
+	Strict bool
+
+	// OnWarning, if non-nil, is called, during lenient decoding, with each
+	// violation that was repaired or ignored.
+	OnWarning func(w *DecodeError)
+
+	// MaxLOD, if positive, is how many of a progressive graphic's detail
+	// levels (see Metadata.DetailLevels) to decode. Decoding stops, without
+	// error, at the end of that level. Zero means to decode every level, as
+	// does a graphic that is not progressive. A StreamDecoder that stops
+	// early does not verify the graphic's checksum.
+	MaxLOD int
+
+	// Context, if non-nil, is checked before each opcode. Once it is done,
+	// decoding stops with its error, and the Destination is not called after
+	// that, so that a server can abandon decoding, and rendering, when its
+	// client disconnects.
+	Context context.Context
+
+	// The remaining fields bound the work done, and the memory needed, when
+	// decoding untrusted IconVG graphics. Zero or negative values mean no
+	// limit. Decoding stops with an error as soon as a limit is exceeded, and
+	// the Destination is not called after that.
+
+	// MaxOpcodes is the maximum number of opcodes to decode. A drawing opcode
+	// with an implicit repetition count, such as "5 lineTos", counts once.
+	MaxOpcodes int
+
+	// MaxPathSegments is the maximum total number of path segments, over all
+	// paths. Each lineTo, quadTo, cubeTo, arcTo and moveTo (including the
+	// implicit moveTo when starting a path) counts once, even when it is one
+	// of an opcode's implicit repetitions.
+	MaxPathSegments int
+
+	// MaxNestingDepth is the maximum depth of nested structures. IconVG byte
+	// code is currently a flat sequence of paths, which never nests, so this
+	// limit is always met. It is provided so that programs that set it keep
+	// working, and stay protected, if future versions add nesting.
+	MaxNestingDepth int
+
+	// MaxOutputBytes is the maximum total size of the numbers and colors
+	// passed to the Destination, with each number or color counting as 4
+	// bytes. It bounds the memory needed by a Destination, such as the one
+	// behind the ivg package's Decode function, that records everything it
+	// is given.
+	MaxOutputBytes int
+
+	// FixedFormat is the fixed point format of the numbers that DecodeFixed
+	// passes to its FixedDestination. Decode ignores it.
+	FixedFormat FixedFormat
+
+	// FillRule is the fill rule to give to a Destination (or a
+	// FixedDestination) that implements FillRuleDestination, for graphics
+	// that were encoded, by a program that did not rewrite them, with
+	// even-odd paths. Such graphics do not conform to the specification, and
+	// other renderers will fill them with the non-zero rule.
+	FillRule FillRule
+}
+
+// FillRuleDestination is implemented by Destinations, such as the raster
+// package's Rasterizer, that can fill paths with either FillRule. Decode calls
+// SetFillRule, before Reset, with the DecodeOptions' FillRule, which is
+// FillRuleNonZero by default.
+type FillRuleDestination interface {
+	SetFillRule(r FillRule)
+}
+
+// setFillRule calls dst's SetFillRule method, if it has one.
+func setFillRule(dst interface{}, opts *DecodeOptions) {
+	if d, ok := dst.(FillRuleDestination); ok {
+		r := FillRuleNonZero
+		if opts != nil {
+			r = opts.FillRule
+		}
+		d.SetFillRule(r)
+	}
 }
 
 // Decode decodes an IconVG graphic.
 //
 // opts may be nil, which means to use the default options.
+//
+// Decode makes no heap allocations of its own, although dst may, unless opts
+// sets resource limits, a Context, Strict or OnWarning. A Decoder avoids
+// those allocations too.
 func Decode(dst Destination, src []byte, opts *DecodeOptions) error {
-	return decode(dst, nil, nil, false, src, opts)
+	return decode(dst, nil, nil, nil, nil, false, src, opts)
+}
+
+// Decoder decodes IconVG graphics. Unlike the Decode function, it can be
+// reused, so that decoding many graphics makes no heap allocations of its own
+// after the first, even when enforcing resource limits.
+//
+// The zero value is ready to use. A Decoder is not safe for concurrent use by
+// multiple goroutines. A DecoderPool shares Decoders between goroutines.
+type Decoder struct {
+	lim limiter
+	chk checker
 }
 
-// DecodeMetadata decodes only the metadata in an IconVG graphic.
+// Decode decodes an IconVG graphic, like the Decode function.
+//
+// opts may be nil, which means to use the default options.
+func (d *Decoder) Decode(dst Destination, src []byte, opts *DecodeOptions) error {
+	err := decode(dst, &d.lim, &d.chk, nil, nil, false, src, opts)
+	d.lim.release()
+	d.chk.onWarning = nil
+	return err
+}
+
+// DecoderPool is a pool of Decoders, for decoding many graphics concurrently,
+// such as in an HTTP server, without each goroutine or each call allocating
+// its own Decoder.
+//
+// The zero value is ready to use. Unlike a Decoder, a DecoderPool is safe for
+// concurrent use by multiple goroutines. Each Decoder that it hands out is
+// used by one goroutine at a time. A DecoderPool must not be copied after
+// first use.
+type DecoderPool struct {
+	p sync.Pool
+}
+
+// Get returns a Decoder from the pool, or a new one if the pool is empty. The
+// caller has exclusive use of it until passing it to Put.
+func (p *DecoderPool) Get() *Decoder {
+	if d, ok := p.p.Get().(*Decoder); ok {
+		return d
+	}
+	return &Decoder{}
+}
+
+// Put returns d, which must not be used afterwards, to the pool. A Decoder
+// keeps no reference to the Destination or DecodeOptions of its previous
+// Decode call, so pooled Decoders do not keep those alive.
+func (p *DecoderPool) Put(d *Decoder) {
+	if d != nil {
+		p.p.Put(d)
+	}
+}
+
+// Decode decodes an IconVG graphic with a pooled Decoder, like the Decode
+// function. It may be called by multiple goroutines concurrently, with
+// different Destinations.
+//
+// opts may be nil, which means to use the default options.
+func (p *DecoderPool) Decode(dst Destination, src []byte, opts *DecodeOptions) error {
+	d := p.Get()
+	err := d.Decode(dst, src, opts)
+	p.Put(d)
+	return err
+}
+
+// DecodeMetadata decodes only the metadata in an IconVG graphic: its viewBox,
+// suggested palette, title and description.
+//
+// It checks the magic identifier and decodes the metadata chunks, but it does
+// not walk, or validate, the styling and drawing opcodes that follow them. Its
+// cost depends on the size of the metadata, not the size of the graphic,
+// except that it verifies the graphic's checksum, if it has one.
 func DecodeMetadata(src []byte) (m Metadata, retErr error) {
 	m.ViewBox = DefaultViewBox
 	m.Palette = DefaultPalette
-	if err := decode(nil, nil, &m, true, src, nil); err != nil {
+	if err := decode(nil, nil, nil, nil, &m, true, src, nil); err != nil {
 		return Metadata{}, err
 	}
 	return m, nil
 }
 
-func decode(dst Destination, p printer, m *Metadata, metadataOnly bool, src buffer, opts *DecodeOptions) error {
+// decode decodes src. lim and chk, if non-nil, are storage to reuse for
+// enforcing opts' resource limits and for detecting violations of the
+// specification.
+func decode(dst Destination, lim *limiter, chk *checker, p printer, m *Metadata, metadataOnly bool, src buffer, opts *DecodeOptions) error {
+	srcLen := len(src)
+	if m == nil {
+		// This does not escape, so that it lives on the stack.
+		local := Metadata{
+			ViewBox: DefaultViewBox,
+			Palette: DefaultPalette,
+		}
+		m = &local
+	}
+	chk = newChecker(chk, opts)
+	src, err := decodeHeader(p, chk, m, src, opts)
+	if err != nil {
+		return err
+	}
+	if metadataOnly {
+		return nil
+	}
+	levels := newDetailLevels(dst, m, srcLen-len(src), opts)
+	setFillRule(dst, opts)
+	if hasLimits(opts) {
+		if lim == nil {
+			lim = &limiter{}
+		}
+		lim.reset(dst, opts)
+		dst = lim
+	} else {
+		lim = nil
+	}
+	if dst != nil {
+		dst.Reset(*m)
+	}
+
+	mf, onInstruction, trace := modeFunc(decodeStyling), (func(int))(nil), (func(int, Opcode, []byte))(nil)
+	if opts != nil {
+		onInstruction, trace = opts.OnInstruction, opts.Trace
+	}
+	drawing, pathOffset, pathOpcode := false, 0, byte(0)
+	for len(src) > 0 {
+		if stop, err := levels.reach(srcLen-len(src), drawing); err != nil {
+			return err
+		} else if stop {
+			return nil
+		}
+		if onInstruction != nil {
+			onInstruction(srcLen - len(src))
+		}
+		if lim != nil {
+			if err := lim.opcode(); err != nil {
+				return err
+			}
+		}
+		offset, op, instruction, err := srcLen-len(src), Opcode{src[0], drawing}, src, error(nil)
+		chk.locate(offset, op.Byte, false)
+		mf, src, err = mf(dst, p, chk, src)
+		if err != nil {
+			return annotate(err, offset, op.Byte, false)
+		} else if (lim != nil) && (lim.err != nil) {
+			return lim.err
+		}
+		if trace != nil {
+			trace(offset, op, instruction[1:len(instruction)-len(src)])
+		}
+		if !drawing {
+			pathOffset, pathOpcode = offset, op.Byte
+		}
+		drawing = op.nextDrawing()
+	}
+	if err := levels.finish(srcLen, drawing); err != nil {
+		return err
+	}
+	return checkFinished(chk, drawing, pathOffset, pathOpcode)
+}
+
+// checkFinished checks that the graphic did not end in the drawing mode, in
+// a path that started at pathOffset.
+func checkFinished(chk *checker, drawing bool, pathOffset int, pathOpcode byte) error {
+	if !drawing {
+		return nil
+	}
+	chk.locate(pathOffset, pathOpcode, false)
+	return annotate(chk.violation(ErrUnfinishedPath), pathOffset, pathOpcode, false)
+}
+
+// decodeHeader decodes the magic identifier and the metadata chunks at the
+// start of src into m, returning the bytes that follow them.
+func decodeHeader(p printer, chk *checker, m *Metadata, src buffer, opts *DecodeOptions) (src1 buffer, retErr error) {
+	graphic := src
 	if !bytes.HasPrefix(src, magicBytes) {
-		return errInvalidMagicIdentifier
+		return nil, annotate(ErrInvalidMagicIdentifier, 0, 0, true)
 	}
 	if p != nil {
 		p(src[:len(magic)], "IconVG Magic identifier\n")
@@ -101,45 +420,43 @@ func decode(dst Destination, p printer, m *Metadata, metadataOnly bool, src buff
 
 	nMetadataChunks, n := src.decodeNatural()
 	if n == 0 {
-		return errInvalidNumberOfMetadataChunks
+		return nil, annotate(ErrInvalidNumberOfMetadataChunks, len(magic), 0, true)
 	}
 	if p != nil {
 		p(src[:n], "Number of metadata chunks: %d\n", nMetadataChunks)
 	}
 	src = src[n:]
 
-	if m == nil {
-		m = &Metadata{}
+	if opts != nil && opts.Palette != nil {
+		m.Palette = *opts.Palette
 	}
+	offset, checksumAt := len(magic)+n, 0
 	for ; nMetadataChunks > 0; nMetadataChunks-- {
-		err := error(nil)
-		src, err = decodeMetadataChunk(p, m, src, opts)
+		chk.locate(offset, 0, true)
+		hadChecksum := m.Checksum
+		rest, err := decodeMetadataChunk(p, chk, m, src, opts)
 		if err != nil {
-			return err
+			return nil, annotate(err, offset, 0, true)
+		}
+		offset += len(src) - len(rest)
+		src = rest
+		if m.Checksum && !hadChecksum {
+			// The checksum ends its chunk.
+			checksumAt = offset - 4
 		}
 	}
-	if metadataOnly {
-		return nil
-	}
-	if dst != nil {
-		dst.Reset(*m)
-	}
-
-	mf := modeFunc(decodeStyling)
-	for len(src) > 0 {
-		err := error(nil)
-		mf, src, err = mf(dst, p, src)
-		if err != nil {
-			return err
+	if checksumAt > 0 {
+		if err := verifyChecksum(graphic, checksumAt); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return src, nil
 }
 
-func decodeMetadataChunk(p printer, m *Metadata, src buffer, opts *DecodeOptions) (src1 buffer, retErr error) {
+func decodeMetadataChunk(p printer, chk *checker, m *Metadata, src buffer, opts *DecodeOptions) (src1 buffer, retErr error) {
 	length, n := src.decodeNatural()
 	if n == 0 {
-		return nil, errInvalidMetadataChunkLength
+		return nil, ErrInvalidMetadataChunkLength
 	}
 	if p != nil {
 		p(src[:n], "Metadata chunk length: %d\n", length)
@@ -149,40 +466,40 @@ func decodeMetadataChunk(p printer, m *Metadata, src buffer, opts *DecodeOptions
 
 	mid, n := src.decodeNatural()
 	if n == 0 {
-		return nil, errInvalidMetadataIdentifier
-	}
-	if mid >= uint32(len(midDescriptions)) {
-		return nil, errUnsupportedMetadataIdentifier
+		return nil, ErrInvalidMetadataIdentifier
 	}
 	if p != nil {
-		p(src[:n], "Metadata Identifier: %d (%s)\n", mid, midDescriptions[mid])
+		p(src[:n], "Metadata Identifier: %d (%s)\n", mid, midDescription(mid))
 	}
 	src = src[n:]
+	if err := chk.checkMID(mid); err != nil {
+		return nil, err
+	}
 
 	switch mid {
 	case midViewBox:
 		err := error(nil)
 		if m.ViewBox.Min[0], src, err = decodeNumber(p, src, buffer.decodeCoordinate); err != nil {
-			return nil, errInvalidViewBox
+			return nil, ErrInvalidViewBox
 		}
 		if m.ViewBox.Min[1], src, err = decodeNumber(p, src, buffer.decodeCoordinate); err != nil {
-			return nil, errInvalidViewBox
+			return nil, ErrInvalidViewBox
 		}
 		if m.ViewBox.Max[0], src, err = decodeNumber(p, src, buffer.decodeCoordinate); err != nil {
-			return nil, errInvalidViewBox
+			return nil, ErrInvalidViewBox
 		}
 		if m.ViewBox.Max[1], src, err = decodeNumber(p, src, buffer.decodeCoordinate); err != nil {
-			return nil, errInvalidViewBox
+			return nil, ErrInvalidViewBox
 		}
 		if m.ViewBox.Min[0] > m.ViewBox.Max[0] || m.ViewBox.Min[1] > m.ViewBox.Max[1] ||
 			isNaNOrInfinity(m.ViewBox.Min[0]) || isNaNOrInfinity(m.ViewBox.Min[1]) ||
 			isNaNOrInfinity(m.ViewBox.Max[0]) || isNaNOrInfinity(m.ViewBox.Max[1]) {
-			return nil, errInvalidViewBox
+			return nil, ErrInvalidViewBox
 		}
 
 	case midSuggestedPalette:
 		if len(src) == 0 {
-			return nil, errInvalidSuggestedPalette
+			return nil, ErrInvalidSuggestedPalette
 		}
 		length, format := 1+int(src[0]&0x3f), src[0]>>6
 		decode := buffer.decodeColor4
@@ -202,10 +519,17 @@ func decodeMetadataChunk(p printer, m *Metadata, src buffer, opts *DecodeOptions
 		for i := 0; i < length; i++ {
 			c, n := decode(src)
 			if n == 0 {
-				return nil, errInvalidSuggestedPalette
+				return nil, ErrInvalidSuggestedPalette
 			}
+			// A 1 byte color that refers to the custom palette or a CREG
+			// register resolves to opaque black.
 			rgba := c.rgba()
-			if c.typ != colorTypeRGBA || !validAlphaPremulColor(rgba) {
+			if c.typ != ColorTypeRGBA {
+				rgba = color.RGBA{0x00, 0x00, 0x00, 0xff}
+			} else if !validAlphaPremulColor(rgba) {
+				if err := chk.violation(ErrNonPremultipliedColor); err != nil {
+					return nil, err
+				}
 				rgba = color.RGBA{0x00, 0x00, 0x00, 0xff}
 			}
 			if p != nil {
@@ -217,12 +541,81 @@ func decodeMetadataChunk(p printer, m *Metadata, src buffer, opts *DecodeOptions
 			}
 		}
 
+	case midTitleAndDescription:
+		// Taking the address of m's fields here would make every m escape to
+		// the heap, so that Decode would allocate.
+		for i, name := range [2]string{"Title", "Description"} {
+			length, n := src.decodeNatural()
+			if (n == 0) || (uint64(len(src)-n) < uint64(length)) {
+				return nil, ErrInvalidTitleAndDescription
+			}
+			s := src[n : n+int(length)]
+			if !utf8.Valid(s) {
+				return nil, ErrInvalidTitleAndDescription
+			}
+			if p != nil {
+				p(src[:n], "    %s: %d bytes\n", name, length)
+				p.quoted(s)
+			}
+			if i == 0 {
+				m.Title = string(s)
+			} else {
+				m.Description = string(s)
+			}
+			src = src[n+int(length):]
+		}
+
+	case midChecksum:
+		if int64(len(src))-lenSrcWant != 4 {
+			return nil, ErrInvalidChecksum
+		}
+		if p != nil {
+			p(src[:4], "    CRC-32C: 0x%02x%02x%02x%02x\n", src[3], src[2], src[1], src[0])
+		}
+		m.Checksum = true
+		src = src[4:]
+
+	case midDetailLevels:
+		count, n := src.decodeNatural()
+		if (n == 0) || (uint64(count) > uint64(len(src))) {
+			return nil, ErrInvalidDetailLevels
+		}
+		if p != nil {
+			p(src[:n], "    %d levels\n", count)
+		}
+		src = src[n:]
+		m.DetailLevels = make([]uint32, count)
+		for i := range m.DetailLevels {
+			end, n := src.decodeNatural()
+			if (n == 0) || ((i > 0) && (end < m.DetailLevels[i-1])) {
+				return nil, ErrInvalidDetailLevels
+			}
+			if p != nil {
+				p(src[:n], "    Level %d ends at opcode offset %d\n", i+1, end)
+			}
+			m.DetailLevels[i] = end
+			src = src[n:]
+		}
+
 	default:
-		return nil, errUnsupportedMetadataIdentifier
+		// Keep a copy of the chunk's data, as src's bytes may be re-used.
+		length := int64(len(src)) - lenSrcWant
+		if (length < 0) || (length > int64(len(src))) {
+			return nil, ErrInconsistentMetadataChunkLength
+		}
+		data := append([]byte{}, src[:length]...)
+		if p != nil {
+			p.quoted(data)
+		}
+		if m.Extra == nil {
+			m.Extra = map[MID][]byte{}
+		}
+		m.Extra[MID(mid)] = data
+		src = src[length:]
 	}
 
 	if int64(len(src)) != lenSrcWant {
-		return nil, errInconsistentMetadataChunkLength
+		return nil, ErrInconsistentMetadataChunkLength
 	}
 	return src, nil
 }
@@ -233,9 +626,9 @@ func decodeMetadataChunk(p printer, m *Metadata, src buffer, opts *DecodeOptions
 // It is a function type. The decoding loop calls this function to decode and
 // execute the next opcode from the src buffer, returning the subsequent mode
 // and the remaining source bytes.
-type modeFunc func(dst Destination, p printer, src buffer) (modeFunc, buffer, error)
+type modeFunc func(dst Destination, p printer, chk *checker, src buffer) (modeFunc, buffer, error)
 
-func decodeStyling(dst Destination, p printer, src buffer) (modeFunc, buffer, error) {
+func decodeStyling(dst Destination, p printer, chk *checker, src buffer) (modeFunc, buffer, error) {
 	switch opcode := src[0]; {
 	case opcode < 0x80:
 		if opcode < 0x40 {
@@ -259,18 +652,18 @@ func decodeStyling(dst Destination, p printer, src buffer) (modeFunc, buffer, er
 		}
 		return decodeStyling, src, nil
 	case opcode < 0xa8:
-		return decodeSetCReg(dst, p, src, opcode)
+		return decodeSetCReg(dst, p, chk, src, opcode)
 	case opcode < 0xc0:
-		return decodeSetNReg(dst, p, src, opcode)
+		return decodeSetNReg(dst, p, chk, src, opcode)
 	case opcode < 0xc7:
-		return decodeStartPath(dst, p, src, opcode)
+		return decodeStartPath(dst, p, chk, src, opcode)
 	case opcode == 0xc7:
-		return decodeSetLOD(dst, p, src)
+		return decodeSetLOD(dst, p, chk, src)
 	}
-	return nil, nil, errUnsupportedStylingOpcode
+	return nil, nil, ErrUnsupportedStylingOpcode
 }
 
-func decodeSetCReg(dst Destination, p printer, src buffer, opcode byte) (modeFunc, buffer, error) {
+func decodeSetCReg(dst Destination, p printer, chk *checker, src buffer, opcode byte) (modeFunc, buffer, error) {
 	nBytes, directness, adj := 0, "", opcode&0x07
 	var decode func(buffer) (Color, int)
 	incr := adj == 7
@@ -301,7 +694,7 @@ func decodeSetCReg(dst Destination, p printer, src buffer, opcode byte) (modeFun
 
 	c, n := decode(src)
 	if n == 0 {
-		return nil, nil, errInvalidColor
+		return nil, nil, ErrInvalidColor
 	}
 
 	if p != nil {
@@ -309,6 +702,10 @@ func decodeSetCReg(dst Destination, p printer, src buffer, opcode byte) (modeFun
 	}
 	src = src[n:]
 
+	c, err := checkColor(c, chk)
+	if err != nil {
+		return nil, nil, err
+	}
 	if dst != nil {
 		dst.SetCReg(adj, incr, c)
 	}
@@ -318,7 +715,7 @@ func decodeSetCReg(dst Destination, p printer, src buffer, opcode byte) (modeFun
 
 func printColor(src []byte, p printer, c Color, prefix string) {
 	switch c.typ {
-	case colorTypeRGBA:
+	case ColorTypeRGBA:
 		if rgba := c.rgba(); validAlphaPremulColor(rgba) {
 			p(src, "    %sRGBA %02x%02x%02x%02x\n", prefix, rgba.R, rgba.G, rgba.B, rgba.A)
 		} else if rgba.A == 0 && rgba.B&0x80 != 0 {
@@ -333,11 +730,11 @@ func printColor(src []byte, p printer, c Color, prefix string) {
 		} else {
 			p(src, "    %snonsensical color\n", prefix)
 		}
-	case colorTypePaletteIndex:
+	case ColorTypePaletteIndex:
 		p(src, "    %scustomPalette[%d]\n", prefix, c.paletteIndex())
-	case colorTypeCReg:
+	case ColorTypeCReg:
 		p(src, "    %sCREG[%d]\n", prefix, c.cReg())
-	case colorTypeBlend:
+	case ColorTypeBlend:
 		t, c0, c1 := c.blend()
 		p(src[:1], "    blend %d:%d c0:c1\n", 0xff-t, t)
 		printColor(src[1:2], p, decodeColor1(c0), "    c0: ")
@@ -345,7 +742,7 @@ func printColor(src []byte, p printer, c Color, prefix string) {
 	}
 }
 
-func decodeSetNReg(dst Destination, p printer, src buffer, opcode byte) (modeFunc, buffer, error) {
+func decodeSetNReg(dst Destination, p printer, chk *checker, src buffer, opcode byte) (modeFunc, buffer, error) {
 	decode, typ, adj := buffer.decodeZeroToOne, "zero-to-one", opcode&0x07
 	incr := adj == 7
 	if incr {
@@ -369,7 +766,7 @@ func decodeSetNReg(dst Destination, p printer, src buffer, opcode byte) (modeFun
 
 	f, n := decode(src)
 	if n == 0 {
-		return nil, nil, errInvalidNumber
+		return nil, nil, ErrInvalidNumber
 	}
 	if p != nil {
 		p(src[:n], "    %g\n", f)
@@ -383,7 +780,7 @@ func decodeSetNReg(dst Destination, p printer, src buffer, opcode byte) (modeFun
 	return decodeStyling, src, nil
 }
 
-func decodeStartPath(dst Destination, p printer, src buffer, opcode byte) (modeFunc, buffer, error) {
+func decodeStartPath(dst Destination, p printer, chk *checker, src buffer, opcode byte) (modeFunc, buffer, error) {
 	adj := opcode & 0x07
 	if p != nil {
 		p(src[:1], "Start path, filled with CREG[CSEL-%d]; M (absolute moveTo)\n", adj)
@@ -406,7 +803,7 @@ func decodeStartPath(dst Destination, p printer, src buffer, opcode byte) (modeF
 	return decodeDrawing, src, nil
 }
 
-func decodeSetLOD(dst Destination, p printer, src buffer) (modeFunc, buffer, error) {
+func decodeSetLOD(dst Destination, p printer, chk *checker, src buffer) (modeFunc, buffer, error) {
 	if p != nil {
 		p(src[:1], "Set LOD\n")
 	}
@@ -427,7 +824,7 @@ func decodeSetLOD(dst Destination, p printer, src buffer) (modeFunc, buffer, err
 	return decodeStyling, src, nil
 }
 
-func decodeDrawing(dst Destination, p printer, src buffer) (mf modeFunc, src1 buffer, retErr error) {
+func decodeDrawing(dst Destination, p printer, chk *checker, src buffer) (mf modeFunc, src1 buffer, retErr error) {
 	var coords [6]float32
 
 	switch opcode := src[0]; {
@@ -637,7 +1034,7 @@ func decodeDrawing(dst Destination, p printer, src buffer) (mf modeFunc, src1 bu
 		}
 
 	default:
-		return nil, nil, errUnsupportedDrawingOpcode
+		return nil, nil, ErrUnsupportedDrawingOpcode
 	}
 	return decodeDrawing, src, nil
 }
@@ -647,7 +1044,7 @@ type decodeNumberFunc func(buffer) (float32, int)
 func decodeNumber(p printer, src buffer, dnf decodeNumberFunc) (float32, buffer, error) {
 	x, n := dnf(src)
 	if n == 0 {
-		return 0, nil, errInvalidNumber
+		return 0, nil, ErrInvalidNumber
 	}
 	if p != nil {
 		p(src[:n], "    %+g\n", x)
@@ -669,7 +1066,7 @@ func decodeCoordinates(coords []float32, p printer, src buffer) (src1 buffer, re
 func decodeAngle(p printer, src buffer) (float32, buffer, error) {
 	x, n := src.decodeZeroToOne()
 	if n == 0 {
-		return 0, nil, errInvalidNumber
+		return 0, nil, ErrInvalidNumber
 	}
 	if p != nil {
 		p(src[:n], "    %v × 360 degrees (%v degrees)\n", x, x*360)
@@ -680,7 +1077,7 @@ func decodeAngle(p printer, src buffer) (float32, buffer, error) {
 func decodeArcToFlags(p printer, src buffer) (bool, bool, buffer, error) {
 	x, n := src.decodeNatural()
 	if n == 0 {
-		return false, false, nil, errInvalidNumber
+		return false, false, nil, ErrInvalidNumber
 	}
 	if p != nil {
 		p(src[:n], "    %#x (largeArc=%d, sweep=%d)\n", x, (x>>0)&0x01, (x>>1)&0x01)
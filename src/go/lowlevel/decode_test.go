@@ -0,0 +1,168 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDecodeAllocs(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := &lowlevel.DecodeOptions{
+		MaxOpcodes:      1000,
+		MaxPathSegments: 1000,
+		MaxOutputBytes:  1 << 20,
+	}
+	d := &lowlevel.Decoder{}
+	pool := &lowlevel.DecoderPool{}
+
+	testCases := []struct {
+		desc string
+		f    func() error
+	}{
+		{"Decode, nil dst", func() error { return lowlevel.Decode(nil, src, nil) }},
+		{"Decode, NopDestination", func() error { return lowlevel.Decode(lowlevel.NopDestination{}, src, nil) }},
+		{"Decoder, nil opts", func() error { return d.Decode(lowlevel.NopDestination{}, src, nil) }},
+		{"Decoder, limits", func() error { return d.Decode(lowlevel.NopDestination{}, src, limits) }},
+		{"Decode, background Context", func() error {
+			return lowlevel.Decode(lowlevel.NopDestination{}, src, &lowlevel.DecodeOptions{Context: context.Background()})
+		}},
+		{"DecoderPool, limits", func() error { return pool.Decode(lowlevel.NopDestination{}, src, limits) }},
+		{"DecodeMetadata", func() error { _, err := lowlevel.DecodeMetadata(src); return err }},
+	}
+	for _, tc := range testCases {
+		// Warm up the Decoder, whose first use may allocate.
+		if err := tc.f(); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got := testing.AllocsPerRun(100, func() { tc.f() }); got != 0 {
+			t.Errorf("%s: got %v allocations, want 0", tc.desc, got)
+		}
+	}
+}
+
+func TestDecoderPool(t *testing.T) {
+	filenames := []string{"action-info.lores.ivg", "arcs.ivg", "cowbell.ivg", "gradient.ivg"}
+	srcs := make([][]byte, len(filenames))
+	wants := make([]*floatRecorder, len(filenames))
+	for i, filename := range filenames {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs[i] = src
+		wants[i] = &floatRecorder{}
+		if err := lowlevel.Decode(wants[i], src, nil); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+	}
+
+	pool := &lowlevel.DecoderPool{}
+	limits := &lowlevel.DecodeOptions{MaxOpcodes: 1000}
+	errs := make(chan error, 8*len(filenames))
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		for i := range filenames {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				got := &floatRecorder{}
+				if err := pool.Decode(got, srcs[i], limits); err != nil {
+					errs <- fmt.Errorf("%s: %v", filenames[i], err)
+				} else if !reflect.DeepEqual(got.calls, wants[i].calls) {
+					errs <- fmt.Errorf("%s: pooled and unpooled decodings differ", filenames[i])
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// A pooled Decoder's resource limits do not carry over to its next use.
+	d := pool.Get()
+	if err := d.Decode(lowlevel.NopDestination{}, srcs[2], &lowlevel.DecodeOptions{MaxOpcodes: 1}); err == nil {
+		t.Errorf("MaxOpcodes=1: got nil error, want non-nil")
+	}
+	pool.Put(d)
+	pool.Put(nil)
+	if err := pool.Decode(lowlevel.NopDestination{}, srcs[2], nil); err != nil {
+		t.Errorf("no limits: %v", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  string
+		want lowlevel.DecodeError
+	}{{
+		desc: "invalid magic identifier",
+		src:  "\x89IVX\x00",
+		want: lowlevel.DecodeError{Offset: 0, InMetadata: true, Reason: lowlevel.ErrInvalidMagicIdentifier},
+	}, {
+		desc: "missing number of metadata chunks",
+		src:  "\x89IVG",
+		want: lowlevel.DecodeError{Offset: 4, InMetadata: true, Reason: lowlevel.ErrInvalidNumberOfMetadataChunks},
+	}, {
+		desc: "truncated first path",
+		src:  "\x89IVG\x00\xc0\x80",
+		want: lowlevel.DecodeError{Offset: 5, Opcode: 0xc0, Reason: lowlevel.ErrInvalidNumber},
+	}, {
+		desc: "truncated second path",
+		src:  "\x89IVG\x00\xc0\x80\x80\xe1\xc0\x80",
+		want: lowlevel.DecodeError{Offset: 9, Opcode: 0xc0, Reason: lowlevel.ErrInvalidNumber},
+	}}
+	for _, tc := range testCases {
+		errs := map[string]error{
+			"Decode":        lowlevel.Decode(nil, []byte(tc.src), nil),
+			"DecodeFixed":   lowlevel.DecodeFixed(&fixedRecorder{scale: 1 << 6}, []byte(tc.src), nil),
+			"StreamDecoder": lowlevel.NewStreamDecoder(strings.NewReader(tc.src)).Decode(nil, nil),
+		}
+		for name, err := range errs {
+			de := (*lowlevel.DecodeError)(nil)
+			if !errors.As(err, &de) {
+				t.Errorf("%s: %s: got %v, want a *DecodeError", tc.desc, name, err)
+				continue
+			}
+			if *de != tc.want {
+				t.Errorf("%s: %s: got %+v, want %+v", tc.desc, name, *de, tc.want)
+			}
+			if !errors.Is(err, tc.want.Reason) {
+				t.Errorf("%s: %s: errors.Is(err, %q): got false, want true", tc.desc, name, tc.want.Reason)
+			}
+		}
+	}
+
+	const want = "iconvg: invalid number at byte 0x9 in opcode 0xc0"
+	if got := lowlevel.Decode(nil, []byte("\x89IVG\x00\xc0\x80\x80\xe1\xc0\x80"), nil).Error(); got != want {
+		t.Errorf("Error: got %q, want %q", got, want)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 )
 
 // Disassemble writes src's disassembly to w.
@@ -26,14 +27,26 @@ import (
 // (look for the text "annotated disassembly") or test/data/*.ivg.disassembly
 // for example output.
 func Disassemble(w io.Writer, src []byte) error {
+	return disassembleBuffered(w, src, false)
+}
+
+// DisassembleWithState is like Disassemble, but after each instruction, it
+// also writes how that instruction changed the virtual machine's state (see
+// VMState): the selector and data registers, the level of detail bounds, the
+// path being drawn and the pen. Such lines start with "=>".
+func DisassembleWithState(w io.Writer, src []byte) error {
+	return disassembleBuffered(w, src, true)
+}
+
+func disassembleBuffered(w io.Writer, src []byte, withState bool) error {
 	// Calling disassemble will make lots of small writes. If w is an
 	// io.ByteWriter then assume that it is already buffered. Otherwise, wrap w
 	// with our own buffering.
 	if _, ok := w.(io.ByteWriter); ok {
-		return disassemble(w, src)
+		return disassemble(w, src, withState)
 	}
 	bw := bufio.NewWriter(w)
-	err0 := disassemble(bw, src)
+	err0 := disassemble(bw, src, withState)
 	err1 := bw.Flush()
 	if err0 != nil {
 		return err0
@@ -41,7 +54,7 @@ func Disassemble(w io.Writer, src []byte) error {
 	return err1
 }
 
-func disassemble(w io.Writer, src []byte) error {
+func disassemble(w io.Writer, src []byte, withState bool) error {
 	p := func(b []byte, format string, args ...interface{}) {
 		const hex = "0123456789abcdef"
 		var buf [14]byte
@@ -55,5 +68,56 @@ func disassemble(w io.Writer, src []byte) error {
 		w.Write(buf[:])
 		fmt.Fprintf(w, format, args...)
 	}
-	return decode(nil, p, nil, false, src, nil)
+	if !withState {
+		return decode(nil, nil, nil, p, nil, false, src, nil)
+	}
+
+	// OnInstruction is called before each instruction, which is after the
+	// previous instruction's disassembly and execution.
+	vm, prev, started := NewVM(nil), VMState{}, false
+	flush := func(int) {
+		s := vm.State()
+		if started {
+			printStateChanges(p, &prev, &s)
+		}
+		prev, started = s, true
+	}
+	if err := decode(vm, nil, nil, p, nil, false, src, &DecodeOptions{OnInstruction: flush}); err != nil {
+		return err
+	}
+	if started {
+		flush(0)
+	}
+	return nil
+}
+
+// printStateChanges prints the differences between two VMStates.
+func printStateChanges(p printer, s0 *VMState, s1 *VMState) {
+	if s0.CSel != s1.CSel {
+		p(nil, "=> CSEL = %d\n", s1.CSel)
+	}
+	if s0.NSel != s1.NSel {
+		p(nil, "=> NSEL = %d\n", s1.NSel)
+	}
+	for i := range s1.CReg {
+		if c := s1.CReg[i]; s0.CReg[i] != c {
+			p(nil, "=> CREG[%d] = RGBA %02x%02x%02x%02x\n", i, c.R, c.G, c.B, c.A)
+		}
+	}
+	for i := range s1.NReg {
+		// Comparing the bits, not the values, finds changes to or from NaN.
+		if f := s1.NReg[i]; math.Float32bits(s0.NReg[i]) != math.Float32bits(f) {
+			p(nil, "=> NREG[%d] = %g\n", i, f)
+		}
+	}
+	if (s0.LOD0 != s1.LOD0) || (s0.LOD1 != s1.LOD1) {
+		p(nil, "=> LOD = [%g, %g)\n", s1.LOD0, s1.LOD1)
+	}
+	if s0.NumPaths != s1.NumPaths {
+		c := s1.PathFill
+		p(nil, "=> path %d, filled with RGBA %02x%02x%02x%02x\n", s1.NumPaths-1, c.R, c.G, c.B, c.A)
+	}
+	if s1.InPath && (s0.Pen != s1.Pen) {
+		p(nil, "=> pen = (%g, %g)\n", s1.Pen[0], s1.Pen[1])
+	}
 }
@@ -0,0 +1,602 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+var (
+	errDetailLevels           = errors.New("iconvg: too many detail levels")
+	errDrawingOpInStylingMode = errors.New("iconvg: drawing op in styling mode")
+	errStylingOpInDrawingMode = errors.New("iconvg: styling op in drawing mode")
+	errInvalidAdjustment      = errors.New("iconvg: invalid adjustment")
+	errInvalidExtraMetadata   = errors.New("iconvg: invalid extra metadata")
+	errInvalidText            = errors.New("iconvg: invalid title or description")
+	errMissingReset           = errors.New("iconvg: missing Reset")
+	errUnfinishedPath         = errors.New("iconvg: unfinished path")
+	errWriterSet              = errors.New("iconvg: Bytes called on an Encoder with a Writer")
+)
+
+// encoderChunkSize is how many bytes an Encoder with a Writer buffers before
+// writing them.
+const encoderChunkSize = 4096
+
+// Encoder is a Destination that encodes the actions it receives as IconVG
+// byte code.
+//
+// Each method call is encoded as a single operation, using the smallest
+// encoding of its color or numbers. Consecutive calls to the same drawing
+// method are combined into a single opcode with a repeat count. Encoder does
+// not otherwise change the operations, such as converting between absolute
+// and relative coordinates: that is the caller's responsibility.
+//
+// Decoding an IconVG graphic with an Encoder as the Destination re-encodes it.
+//
+// By default, the Encoder accumulates the whole graphic in memory, for Bytes
+// to return. SetWriter switches it to writing the byte code to an io.Writer
+// as it goes, which suits streaming many graphics into one archive.
+//
+// The zero value is ready to use, but the first method called must be Reset.
+type Encoder struct {
+	buf     buffer
+	err     error
+	started bool
+	drawing bool
+
+	// lastOp and lastOpIndex are the most recent drawing opcode (with the
+	// repeat count bits cleared) and its position in the graphic, so that
+	// repeated drawing operations can share an opcode. lastOpIndex is zero if
+	// the most recent operation cannot be repeated, as the graphic's first
+	// byte is part of the magic identifier, not an opcode.
+	lastOp      byte
+	lastOpIndex int
+
+	// w is the SetWriter sink. written is the number of bytes of the graphic
+	// already written to w, which precede buf's bytes.
+	w       io.Writer
+	written int
+
+	// checksumAt is the position of the checksum in the graphic, or zero if
+	// it has none. levelsAt is the position of the detail levels' ends, each
+	// a 4 byte natural number, and levelEnds are those ends, so far, and
+	// opcodesAt is the position of the first opcode. The Encoder keeps the
+	// whole graphic buffered until it fills these in, as they precede the
+	// bytes that they describe.
+	checksumAt int
+	levelsAt   int
+	levelEnds  []uint32
+	nLevels    int
+	opcodesAt  int
+}
+
+var _ DetailLevelDestination = (*Encoder)(nil)
+
+// EndDetailLevel ends the current progressive detail level (see
+// Metadata.DetailLevels). It must be called in the styling mode, between
+// paths, and no more times than the number of levels given to Reset. Any
+// levels that are not ended explicitly end at the end of the graphic.
+func (e *Encoder) EndDetailLevel() {
+	if e.err != nil {
+		return
+	} else if !e.started {
+		e.err = errMissingReset
+		return
+	} else if e.drawing {
+		e.err = errUnfinishedPath
+		return
+	} else if len(e.levelEnds) >= e.nLevels {
+		e.err = errDetailLevels
+		return
+	}
+	e.levelEnds = append(e.levelEnds, uint32(e.written+len(e.buf)-e.opcodesAt))
+	e.lastOpIndex = 0
+}
+
+// seal fills in the parts of the metadata that depend on the rest of the
+// graphic.
+func (e *Encoder) seal() {
+	if e.nLevels > 0 {
+		end := uint32(e.written + len(e.buf) - e.opcodesAt)
+		for i := 0; i < e.nLevels; i++ {
+			u := end
+			if i < len(e.levelEnds) {
+				u = e.levelEnds[i]
+			}
+			u = (u << 2) | 3
+			b := e.buf[e.levelsAt+4*i:]
+			b[0], b[1], b[2], b[3] = uint8(u), uint8(u>>8), uint8(u>>16), uint8(u>>24)
+		}
+	}
+	if e.checksumAt > 0 {
+		sealChecksum(e.buf, e.checksumAt)
+	}
+}
+
+// SetWriter sets the Encoder's sink. If w is non-nil then the Encoder writes
+// the graphic to w, in chunks, instead of Bytes returning it, and Flush must
+// be called after the final method, to write what remains buffered.
+//
+// SetWriter is typically called before Reset, which keeps the sink. An
+// Encoder can then encode successive graphics to the same w, calling Reset,
+// the drawing methods and Flush for each.
+func (e *Encoder) SetWriter(w io.Writer) {
+	e.w = w
+}
+
+// Bytes returns the encoded IconVG graphic. It returns an error if the
+// Encoder's methods were called in an invalid order, such as calling a
+// drawing method while not in the drawing mode, or if the Encoder has a
+// Writer.
+func (e *Encoder) Bytes() ([]byte, error) {
+	if err := e.checkFinished(); err != nil {
+		return nil, err
+	} else if e.w != nil {
+		return nil, errWriterSet
+	}
+	e.seal()
+	return []byte(e.buf), nil
+}
+
+// Flush writes the rest of the encoded IconVG graphic to the Encoder's
+// Writer. Like Bytes, it returns an error if the Encoder's methods were
+// called in an invalid order, in which case the graphic written so far is
+// incomplete. It is a no-op if the Encoder has no Writer.
+func (e *Encoder) Flush() error {
+	if err := e.checkFinished(); err != nil {
+		return err
+	}
+	e.seal()
+	e.flush(len(e.buf))
+	return e.err
+}
+
+// PredictedSize returns the size, in bytes, of the encoded IconVG graphic,
+// assuming that no further methods are called: it is exact once the final
+// method has been called, and otherwise a lower bound. It counts bytes
+// already written to the Writer as well as those still buffered.
+//
+// To learn a graphic's size before writing it, such as for an archive
+// format whose headers precede the data, a caller can first encode the
+// graphic to io.Discard, which costs little memory.
+func (e *Encoder) PredictedSize() int {
+	return e.written + len(e.buf)
+}
+
+func (e *Encoder) checkFinished() error {
+	if e.err != nil {
+		return e.err
+	} else if !e.started {
+		return errMissingReset
+	} else if e.drawing {
+		return errUnfinishedPath
+	}
+	return nil
+}
+
+// maybeFlush writes buffered bytes to the Writer, once there are enough of
+// them, other than those from the most recent opcode on, whose repeat count
+// may yet change.
+func (e *Encoder) maybeFlush() {
+	if (e.w == nil) || (len(e.buf) < encoderChunkSize) ||
+		(e.checksumAt > 0) || (e.nLevels > 0) {
+		return
+	}
+	n := len(e.buf)
+	if e.lastOpIndex > 0 {
+		n = e.lastOpIndex - e.written
+	}
+	e.flush(n)
+}
+
+// flush writes the first n buffered bytes to the Writer, if there is one.
+func (e *Encoder) flush(n int) {
+	if (e.w == nil) || (e.err != nil) || (n == 0) {
+		return
+	}
+	if _, err := e.w.Write(e.buf[:n]); err != nil {
+		e.err = err
+		return
+	}
+	e.written += n
+	e.buf = e.buf[:copy(e.buf, e.buf[n:])]
+}
+
+// Reset discards any previously encoded graphic and starts a new one with the
+// given metadata. Metadata that equals the default ViewBox or default Palette
+// is omitted, as are an empty Title and Description. A Title or Description
+// that is not valid UTF-8 is an error, reported when the graphic is finished,
+// as is an Extra chunk whose MID is one that this package encodes itself. If
+// m.Checksum is set, Bytes and Flush compute the graphic's checksum. Likewise,
+// they fill in the ends of the len(m.DetailLevels) detail levels, ignoring
+// m.DetailLevels' values, from the calls to EndDetailLevel.
+func (e *Encoder) Reset(m Metadata) {
+	*e = Encoder{
+		buf:     append(e.buf[:0], magic...),
+		started: true,
+		w:       e.w,
+	}
+
+	nMetadataChunks := uint32(0)
+	if m.ViewBox != DefaultViewBox {
+		nMetadataChunks++
+	}
+	if m.Palette != DefaultPalette {
+		nMetadataChunks++
+	}
+	if (m.Title != "") || (m.Description != "") {
+		nMetadataChunks++
+	}
+	if m.Checksum {
+		nMetadataChunks++
+	}
+	if len(m.DetailLevels) > 0 {
+		nMetadataChunks++
+	}
+	nMetadataChunks += uint32(len(m.Extra))
+	e.buf.encodeNatural(nMetadataChunks)
+
+	if m.ViewBox != DefaultViewBox {
+		chunk := buffer(nil)
+		chunk.encodeNatural(midViewBox)
+		chunk.encodeCoordinate(m.ViewBox.Min[0])
+		chunk.encodeCoordinate(m.ViewBox.Min[1])
+		chunk.encodeCoordinate(m.ViewBox.Max[0])
+		chunk.encodeCoordinate(m.ViewBox.Max[1])
+		e.buf.encodeNatural(uint32(len(chunk)))
+		e.buf = append(e.buf, chunk...)
+	}
+
+	if m.Palette != DefaultPalette {
+		chunk := buffer(nil)
+		chunk.encodeNatural(midSuggestedPalette)
+		encodeSuggestedPalette(&chunk, &m.Palette)
+		e.buf.encodeNatural(uint32(len(chunk)))
+		e.buf = append(e.buf, chunk...)
+	}
+
+	if (m.Title != "") || (m.Description != "") {
+		chunk := buffer(nil)
+		chunk.encodeNatural(midTitleAndDescription)
+		for _, s := range [2]string{m.Title, m.Description} {
+			if !utf8.ValidString(s) || (len(s) >= maxNatural/2) {
+				e.err = errInvalidText
+			}
+			chunk.encodeNatural(uint32(len(s)))
+			chunk = append(chunk, s...)
+		}
+		e.buf.encodeNatural(uint32(len(chunk)))
+		e.buf = append(e.buf, chunk...)
+	}
+
+	if m.Checksum {
+		e.buf = append(e.buf, 0x0a, midChecksum<<1, 0, 0, 0, 0)
+		e.checksumAt = len(e.buf) - 4
+	}
+
+	if n := len(m.DetailLevels); n > 0 {
+		if n >= maxNatural/8 {
+			e.err = errDetailLevels
+			n = 0
+		}
+		chunk := buffer(nil)
+		chunk.encodeNatural(midDetailLevels)
+		chunk.encodeNatural(uint32(n))
+		e.buf.encodeNatural(uint32(len(chunk) + 4*n))
+		e.buf = append(e.buf, chunk...)
+		e.levelsAt, e.nLevels = len(e.buf), n
+		e.buf = append(e.buf, make([]byte, 4*n)...)
+	}
+
+	// Chunks must be in increasing MID order.
+	mids := make([]MID, 0, len(m.Extra))
+	for mid := range m.Extra {
+		mids = append(mids, mid)
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+	for _, mid := range mids {
+		if (mid <= midDetailLevels) || (mid >= maxNatural) ||
+			(len(m.Extra[mid]) >= maxNatural-4) {
+			e.err = errInvalidExtraMetadata
+		}
+		chunk := buffer(nil)
+		chunk.encodeNatural(uint32(mid))
+		chunk = append(chunk, m.Extra[mid]...)
+		e.buf.encodeNatural(uint32(len(chunk)))
+		e.buf = append(e.buf, chunk...)
+	}
+	e.opcodesAt = len(e.buf)
+}
+
+// encodeSuggestedPalette encodes the shortest prefix of p that is followed
+// only by opaque black, using the narrowest color format that can represent
+// every color in that prefix.
+func encodeSuggestedPalette(b *buffer, p *Palette) {
+	n := len(p)
+	for ; n > 1; n-- {
+		if p[n-1] != (color.RGBA{0x00, 0x00, 0x00, 0xff}) {
+			break
+		}
+	}
+
+	format := byte(0)
+	for _, rgba := range p[:n] {
+		c := RGBAColor(rgba)
+		if _, ok := encodeColor1(c); ok && (format <= 0) {
+			continue
+		} else if _, ok := encodeColor2(c); ok && (format <= 1) {
+			format = 1
+		} else if _, ok := encodeColor3Direct(c); ok && (format <= 2) {
+			format = 2
+		} else {
+			format = 3
+			break
+		}
+	}
+
+	*b = append(*b, (format<<6)|byte(n-1))
+	for _, rgba := range p[:n] {
+		switch c := RGBAColor(rgba); format {
+		case 0:
+			b.encodeColor1(c)
+		case 1:
+			b.encodeColor2(c)
+		case 2:
+			b.encodeColor3Direct(c)
+		default:
+			b.encodeColor4(c)
+		}
+	}
+}
+
+func (e *Encoder) checkStyling() bool {
+	if e.err != nil {
+		return false
+	} else if !e.started {
+		e.err = errMissingReset
+		return false
+	} else if e.drawing {
+		e.err = errStylingOpInDrawingMode
+		return false
+	}
+	e.lastOpIndex = 0
+	e.maybeFlush()
+	return true
+}
+
+func (e *Encoder) checkDrawing() bool {
+	if e.err != nil {
+		return false
+	} else if !e.started {
+		e.err = errMissingReset
+		return false
+	} else if !e.drawing {
+		e.err = errDrawingOpInStylingMode
+		return false
+	}
+	e.maybeFlush()
+	return true
+}
+
+func (e *Encoder) SetCSel(cSel uint8) {
+	if e.checkStyling() {
+		e.buf = append(e.buf, 0x00|(cSel&0x3f))
+	}
+}
+
+func (e *Encoder) SetNSel(nSel uint8) {
+	if e.checkStyling() {
+		e.buf = append(e.buf, 0x40|(nSel&0x3f))
+	}
+}
+
+// adjBits returns the low three bits of an ADJ-using opcode.
+func (e *Encoder) adjBits(adj uint8, incr bool) byte {
+	if incr {
+		if adj != 0 {
+			e.err = errInvalidAdjustment
+		}
+		return 7
+	} else if adj >= 7 {
+		e.err = errInvalidAdjustment
+	}
+	return adj & 0x07
+}
+
+func (e *Encoder) SetCReg(adj uint8, incr bool, c Color) {
+	if !e.checkStyling() {
+		return
+	}
+	adjBits := e.adjBits(adj, incr)
+	if x, ok := encodeColor1(c); ok {
+		e.buf = append(e.buf, 0x80|adjBits, x)
+	} else if x, ok := encodeColor2(c); ok {
+		e.buf = append(e.buf, 0x88|adjBits, x[0], x[1])
+	} else if x, ok := encodeColor3Direct(c); ok {
+		e.buf = append(e.buf, 0x90|adjBits, x[0], x[1], x[2])
+	} else if x, ok := encodeColor4(c); ok {
+		e.buf = append(e.buf, 0x98|adjBits, x[0], x[1], x[2], x[3])
+	} else if x, ok := encodeColor3Indirect(c); ok {
+		e.buf = append(e.buf, 0xa0|adjBits, x[0], x[1], x[2])
+	} else {
+		e.err = ErrInvalidColor
+	}
+}
+
+// SetNReg encodes f as whichever of a real, coordinate or zero-to-one number
+// is shortest.
+func (e *Encoder) SetNReg(adj uint8, incr bool, f float32) {
+	if !e.checkStyling() {
+		return
+	}
+	adjBits := e.adjBits(adj, incr)
+
+	bestOpcode, best := byte(0), buffer(nil)
+	for _, candidate := range [...]struct {
+		opcode byte
+		encode func(*buffer, float32) int
+	}{
+		{0xa8, (*buffer).encodeReal},
+		{0xb0, (*buffer).encodeCoordinate},
+		{0xb8, (*buffer).encodeZeroToOne},
+	} {
+		x := buffer(nil)
+		if n := candidate.encode(&x, f); (best == nil) || (n < len(best)) {
+			bestOpcode, best = candidate.opcode, x
+		}
+	}
+	e.buf = append(e.buf, bestOpcode|adjBits)
+	e.buf = append(e.buf, best...)
+}
+
+func (e *Encoder) SetLOD(lod0, lod1 float32) {
+	if e.checkStyling() {
+		e.buf = append(e.buf, 0xc7)
+		e.buf.encodeReal(lod0)
+		e.buf.encodeReal(lod1)
+	}
+}
+
+func (e *Encoder) StartPath(adj uint8, x, y float32) {
+	if !e.checkStyling() {
+		return
+	} else if adj >= 7 {
+		e.err = errInvalidAdjustment
+		return
+	}
+	e.buf = append(e.buf, 0xc0|adj)
+	e.buf.encodeCoordinate(x)
+	e.buf.encodeCoordinate(y)
+	e.drawing = true
+}
+
+func (e *Encoder) ClosePathEndPath() {
+	if e.checkDrawing() {
+		e.buf = append(e.buf, 0xe1)
+		e.drawing = false
+		e.lastOpIndex = 0
+	}
+}
+
+func (e *Encoder) ClosePathAbsMoveTo(x, y float32) { e.drawOneOf(0xe2, x, y) }
+func (e *Encoder) ClosePathRelMoveTo(x, y float32) { e.drawOneOf(0xe3, x, y) }
+
+func (e *Encoder) AbsHLineTo(x float32) { e.drawOneOf(0xe6, x) }
+func (e *Encoder) RelHLineTo(x float32) { e.drawOneOf(0xe7, x) }
+func (e *Encoder) AbsVLineTo(y float32) { e.drawOneOf(0xe8, y) }
+func (e *Encoder) RelVLineTo(y float32) { e.drawOneOf(0xe9, y) }
+
+func (e *Encoder) AbsLineTo(x, y float32)       { e.drawRepeatable(0x00, 32, x, y) }
+func (e *Encoder) RelLineTo(x, y float32)       { e.drawRepeatable(0x20, 32, x, y) }
+func (e *Encoder) AbsSmoothQuadTo(x, y float32) { e.drawRepeatable(0x40, 16, x, y) }
+func (e *Encoder) RelSmoothQuadTo(x, y float32) { e.drawRepeatable(0x50, 16, x, y) }
+
+func (e *Encoder) AbsQuadTo(x1, y1, x, y float32) { e.drawRepeatable(0x60, 16, x1, y1, x, y) }
+func (e *Encoder) RelQuadTo(x1, y1, x, y float32) { e.drawRepeatable(0x70, 16, x1, y1, x, y) }
+
+func (e *Encoder) AbsSmoothCubeTo(x2, y2, x, y float32) { e.drawRepeatable(0x80, 16, x2, y2, x, y) }
+func (e *Encoder) RelSmoothCubeTo(x2, y2, x, y float32) { e.drawRepeatable(0x90, 16, x2, y2, x, y) }
+
+func (e *Encoder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	e.drawRepeatable(0xa0, 16, x1, y1, x2, y2, x, y)
+}
+
+func (e *Encoder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	e.drawRepeatable(0xb0, 16, x1, y1, x2, y2, x, y)
+}
+
+func (e *Encoder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	e.arcTo(0xc0, rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+func (e *Encoder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	e.arcTo(0xd0, rx, ry, xAxisRotation, largeArc, sweep, x, y)
+}
+
+func (e *Encoder) arcTo(opcode byte, rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	if !e.checkDrawing() {
+		return
+	}
+	e.startRepeatable(opcode, 16)
+	e.buf.encodeCoordinate(rx)
+	e.buf.encodeCoordinate(ry)
+	e.buf.encodeAngle(xAxisRotation)
+	flags := uint32(0)
+	if largeArc {
+		flags |= 0x01
+	}
+	if sweep {
+		flags |= 0x02
+	}
+	e.buf.encodeNatural(flags)
+	e.buf.encodeCoordinate(x)
+	e.buf.encodeCoordinate(y)
+}
+
+// drawOneOf encodes a drawing operation that has no repeat count.
+func (e *Encoder) drawOneOf(opcode byte, coords ...float32) {
+	if !e.checkDrawing() {
+		return
+	}
+	e.buf = append(e.buf, opcode)
+	for _, x := range coords {
+		e.buf.encodeCoordinate(x)
+	}
+	e.lastOpIndex = 0
+}
+
+// drawRepeatable encodes a drawing operation that has a repeat count, of up
+// to maxReps.
+func (e *Encoder) drawRepeatable(opcode byte, maxReps byte, coords ...float32) {
+	if !e.checkDrawing() {
+		return
+	}
+	e.startRepeatable(opcode, maxReps)
+	for _, x := range coords {
+		e.buf.encodeCoordinate(x)
+	}
+}
+
+// startRepeatable either increments the repeat count of the previous opcode,
+// if it is the same drawing operation and its repeat count is not saturated,
+// or appends a new opcode.
+func (e *Encoder) startRepeatable(opcode byte, maxReps byte) {
+	if (e.lastOpIndex > 0) && (e.lastOp == opcode) {
+		i := e.lastOpIndex - e.written
+		if reps := e.buf[i] - opcode; reps+1 < maxReps {
+			e.buf[i]++
+			return
+		}
+	}
+	e.lastOp = opcode
+	e.lastOpIndex = e.written + len(e.buf)
+	e.buf = append(e.buf, opcode)
+}
+
+// QuantizeCoordinate returns what f decodes as, after being encoded as a
+// coordinate number, and the number of bytes in that encoding: 1, 2 or 4.
+//
+// Coordinates that are integers in the range [-64, +64) take 1 byte.
+// Multiples of 1/64 in the range [-128, +128) take 2 bytes. All other
+// coordinates take 4 bytes and may lose precision.
+func QuantizeCoordinate(f float32) (q float32, n int) {
+	b := buffer(nil)
+	b.encodeCoordinate(f)
+	return b.decodeCoordinate()
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestQuantizeCoordinate(t *testing.T) {
+	testCases := []struct {
+		f     float32
+		wantQ float32
+		wantN int
+	}{
+		{0, 0, 1},
+		{-64, -64, 1},
+		{63, 63, 1},
+		{64, 64, 2},
+		{-128, -128, 2},
+		{0.5, 0.5, 2},
+		{127.984375, 127.984375, 2},
+		{128, 128, 4},
+		{0.1, 0.099999994, 4},
+		{1000, 1000, 4},
+	}
+	for _, tc := range testCases {
+		gotQ, gotN := lowlevel.QuantizeCoordinate(tc.f)
+		if gotQ != tc.wantQ || gotN != tc.wantN {
+			t.Errorf("f=%v: got (%v, %d), want (%v, %d)", tc.f, gotQ, gotN, tc.wantQ, tc.wantN)
+		}
+	}
+}
+
+func TestEncoderBytes(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		draw  func(e *lowlevel.Encoder)
+		valid bool
+	}{{
+		desc:  "nothing",
+		draw:  func(e *lowlevel.Encoder) {},
+		valid: true,
+	}, {
+		desc: "triangle",
+		draw: func(e *lowlevel.Encoder) {
+			e.StartPath(0, -16, -16)
+			e.AbsLineTo(16, -16)
+			e.RelLineTo(-16, 32)
+			e.ClosePathEndPath()
+		},
+		valid: true,
+	}, {
+		desc: "every drawing op",
+		draw: func(e *lowlevel.Encoder) {
+			e.StartPath(0, 0, 0)
+			e.AbsHLineTo(8)
+			e.RelVLineTo(8)
+			e.AbsQuadTo(4, 12, 0, 8)
+			e.RelSmoothQuadTo(-4, -4)
+			e.AbsCubeTo(-8, 0, -8, -8, 0, -8)
+			e.RelSmoothCubeTo(8, 0, 8, 8)
+			e.AbsArcTo(4, 4, 0, false, true, 0, 0)
+			e.ClosePathEndPath()
+		},
+		valid: true,
+	}, {
+		desc: "styling op while drawing",
+		draw: func(e *lowlevel.Encoder) {
+			e.StartPath(0, 0, 0)
+			e.SetCSel(1)
+		},
+		valid: false,
+	}, {
+		desc: "drawing op while styling",
+		draw: func(e *lowlevel.Encoder) {
+			e.AbsLineTo(1, 1)
+		},
+		valid: false,
+	}}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		})
+		tc.draw(e)
+		src, err := e.Bytes()
+		if got := err == nil; got != tc.valid {
+			t.Errorf("%s: valid: got %t, want %t (err=%v)", tc.desc, got, tc.valid, err)
+			continue
+		}
+		if !tc.valid {
+			continue
+		}
+		if err := lowlevel.Decode(lowlevel.NopDestination{}, src, nil); err != nil {
+			t.Errorf("%s: Decode: %v", tc.desc, err)
+		}
+	}
+}
+
+// errWriter is an io.Writer that always fails.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestEncoderWriter(t *testing.T) {
+	m := lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: lowlevel.DefaultPalette,
+	}
+	testCases := []struct {
+		desc string
+		draw func(e *lowlevel.Encoder)
+	}{{
+		desc: "nothing",
+		draw: func(e *lowlevel.Encoder) {},
+	}, {
+		desc: "triangle",
+		draw: func(e *lowlevel.Encoder) {
+			e.StartPath(0, -16, -16)
+			e.AbsLineTo(16, -16)
+			e.RelLineTo(-16, 32)
+			e.ClosePathEndPath()
+		},
+	}, {
+		// The repeated lineTos share opcodes, including across the
+		// Encoder's chunk boundaries.
+		desc: "many lines",
+		draw: func(e *lowlevel.Encoder) {
+			e.StartPath(0, 0, 0)
+			for i := 0; i < 5000; i++ {
+				e.AbsLineTo(float32(i%32), float32(i%7))
+			}
+			e.ClosePathEndPath()
+		},
+	}, {
+		desc: "many paths",
+		draw: func(e *lowlevel.Encoder) {
+			for i := 0; i < 1000; i++ {
+				e.SetCSel(uint8(i))
+				e.StartPath(0, float32(i%64)-32, 0.5)
+				e.RelHLineTo(1.25)
+				e.ClosePathEndPath()
+			}
+		},
+	}}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(m)
+		tc.draw(e)
+		want, err := e.Bytes()
+		if err != nil {
+			t.Errorf("%s: Bytes: %v", tc.desc, err)
+			continue
+		}
+		// Reset re-uses the buffer that Bytes returned.
+		want = append([]byte(nil), want...)
+		if got := e.PredictedSize(); got != len(want) {
+			t.Errorf("%s: PredictedSize without a Writer: got %d, want %d", tc.desc, got, len(want))
+		}
+
+		// Encode twice, to check that Reset keeps the Writer.
+		buf := &bytes.Buffer{}
+		e.SetWriter(buf)
+		for i := 0; i < 2; i++ {
+			e.Reset(m)
+			tc.draw(e)
+			if err := e.Flush(); err != nil {
+				t.Errorf("%s #%d: Flush: %v", tc.desc, i, err)
+			}
+			if got := e.PredictedSize(); got != len(want) {
+				t.Errorf("%s #%d: PredictedSize: got %d, want %d", tc.desc, i, got, len(want))
+			}
+		}
+		if got := buf.Bytes(); !bytes.Equal(got, append(append([]byte(nil), want...), want...)) {
+			t.Errorf("%s: written bytes differ from Bytes (got %d bytes, want 2×%d)", tc.desc, len(got), len(want))
+		}
+		if _, err := e.Bytes(); err == nil {
+			t.Errorf("%s: Bytes with a Writer: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestEncoderWriterErrors(t *testing.T) {
+	e := &lowlevel.Encoder{}
+	e.SetWriter(errWriter{})
+	e.Reset(lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: lowlevel.DefaultPalette,
+	})
+	e.StartPath(0, 0, 0)
+	e.AbsLineTo(1, 1)
+	e.ClosePathEndPath()
+	if err := e.Flush(); err == nil {
+		t.Errorf("failing Writer: got nil error, want non-nil")
+	}
+
+	e.SetWriter(&bytes.Buffer{})
+	e.Reset(lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: lowlevel.DefaultPalette,
+	})
+	e.StartPath(0, 0, 0)
+	if err := e.Flush(); err == nil {
+		t.Errorf("unfinished path: got nil error, want non-nil")
+	}
+}
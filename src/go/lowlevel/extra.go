@@ -0,0 +1,138 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MID is a Metadata Identifier, which identifies a metadata chunk.
+type MID uint32
+
+// FirstVendorMID is the smallest vendor MID. The specification never assigns
+// MIDs at or above it, leaving them to tools that attach their own metadata,
+// such as build provenance, to a graphic.
+const FirstVendorMID MID = 1024
+
+var errNoMetadataCodec = errors.New("iconvg: no metadata codec registered")
+
+// MetadataCodec converts between a vendor metadata chunk's MID-specific data
+// and a typed value.
+type MetadataCodec interface {
+	// DecodeChunk decodes a chunk's MID-specific data.
+	DecodeChunk(data []byte) (interface{}, error)
+	// EncodeChunk encodes v as a chunk's MID-specific data.
+	EncodeChunk(v interface{}) ([]byte, error)
+}
+
+type registeredCodec struct {
+	name  string
+	codec MetadataCodec
+}
+
+var (
+	metadataCodecsMu sync.RWMutex
+	metadataCodecs   = map[MID]registeredCodec{}
+)
+
+// RegisterMetadataCodec registers a MetadataCodec for a vendor MID, replacing
+// any previously registered one. The name describes the chunk in
+// disassemblies. It panics if mid is less than FirstVendorMID.
+//
+// Registering a codec is only needed for typed access, via ExtraValue and
+// SetExtraValue. Decoding and encoding preserve a chunk's bytes in
+// Metadata.Extra regardless.
+func RegisterMetadataCodec(mid MID, name string, c MetadataCodec) {
+	if mid < FirstVendorMID {
+		panic(fmt.Sprintf("iconvg: RegisterMetadataCodec: MID %d is not a vendor MID", mid))
+	}
+	metadataCodecsMu.Lock()
+	metadataCodecs[mid] = registeredCodec{name, c}
+	metadataCodecsMu.Unlock()
+}
+
+func metadataCodec(mid MID) registeredCodec {
+	metadataCodecsMu.RLock()
+	defer metadataCodecsMu.RUnlock()
+	return metadataCodecs[mid]
+}
+
+// midDescription returns how disassemblies describe a chunk's MID.
+func midDescription(mid uint32) string {
+	if mid < uint32(len(midDescriptions)) {
+		return midDescriptions[mid]
+	} else if rc := metadataCodec(MID(mid)); rc.name != "" {
+		return rc.name
+	}
+	return "extra"
+}
+
+// ExtraValue decodes the m.Extra chunk for mid with its registered
+// MetadataCodec. It returns ok == false if m has no such chunk.
+func (m *Metadata) ExtraValue(mid MID) (v interface{}, ok bool, err error) {
+	data, ok := m.Extra[mid]
+	if !ok {
+		return nil, false, nil
+	}
+	rc := metadataCodec(mid)
+	if rc.codec == nil {
+		return nil, true, errNoMetadataCodec
+	}
+	v, err = rc.codec.DecodeChunk(data)
+	return v, true, err
+}
+
+// SetExtraValue encodes v with mid's registered MetadataCodec and sets it as
+// the m.Extra chunk for mid.
+func (m *Metadata) SetExtraValue(mid MID, v interface{}) error {
+	rc := metadataCodec(mid)
+	if rc.codec == nil {
+		return errNoMetadataCodec
+	}
+	data, err := rc.codec.EncodeChunk(v)
+	if err != nil {
+		return err
+	}
+	if m.Extra == nil {
+		m.Extra = map[MID][]byte{}
+	}
+	m.Extra[mid] = data
+	return nil
+}
+
+// Equal returns whether m and n are the same metadata. A nil Extra equals an
+// empty one.
+func (m *Metadata) Equal(n *Metadata) bool {
+	if (m.ViewBox != n.ViewBox) || (m.Palette != n.Palette) ||
+		(m.Title != n.Title) || (m.Description != n.Description) ||
+		(m.Checksum != n.Checksum) || (len(m.DetailLevels) != len(n.DetailLevels)) ||
+		(len(m.Extra) != len(n.Extra)) {
+		return false
+	}
+	for i, end := range m.DetailLevels {
+		if end != n.DetailLevels[i] {
+			return false
+		}
+	}
+	for mid, data := range m.Extra {
+		if other, ok := n.Extra[mid]; !ok || !bytes.Equal(data, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,515 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"math"
+)
+
+// FixedFormat is a fixed point number format: a signed 32-bit integer with an
+// implicit number of fractional bits.
+type FixedFormat uint8
+
+const (
+	// FixedInt26_6 has 6 fractional bits, like the Int26_6 type in the
+	// golang.org/x/image/math/fixed package.
+	FixedInt26_6 FixedFormat = 0
+	// FixedInt16_16 has 16 fractional bits.
+	FixedInt16_16 FixedFormat = 1
+)
+
+// fracBits returns the number of fractional bits in the format.
+func (f FixedFormat) fracBits() uint {
+	if f == FixedInt16_16 {
+		return 16
+	}
+	return 6
+}
+
+// FixedRectangle is like Rectangle but its coordinates are fixed point.
+type FixedRectangle struct {
+	Min, Max [2]int32
+}
+
+// FixedDestination is like Destination but its numbers, including NREG
+// values, levels of detail, arc radii and arc rotations, are fixed point, in
+// the DecodeOptions' FixedFormat.
+//
+// Reset is passed the metadata twice: m's ViewBox is float32, as for a
+// Destination, and viewBox is its fixed point equivalent.
+type FixedDestination interface {
+	Reset(m Metadata, viewBox FixedRectangle)
+
+	SetCSel(cSel uint8)
+	SetNSel(nSel uint8)
+	SetCReg(adj uint8, incr bool, c Color)
+	SetNReg(adj uint8, incr bool, f int32)
+	SetLOD(lod0, lod1 int32)
+
+	StartPath(adj uint8, x, y int32)
+	ClosePathEndPath()
+	ClosePathAbsMoveTo(x, y int32)
+	ClosePathRelMoveTo(x, y int32)
+
+	AbsHLineTo(x int32)
+	RelHLineTo(x int32)
+	AbsVLineTo(y int32)
+	RelVLineTo(y int32)
+	AbsLineTo(x, y int32)
+	RelLineTo(x, y int32)
+	AbsSmoothQuadTo(x, y int32)
+	RelSmoothQuadTo(x, y int32)
+	AbsQuadTo(x1, y1, x, y int32)
+	RelQuadTo(x1, y1, x, y int32)
+	AbsSmoothCubeTo(x2, y2, x, y int32)
+	RelSmoothCubeTo(x2, y2, x, y int32)
+	AbsCubeTo(x1, y1, x2, y2, x, y int32)
+	RelCubeTo(x1, y1, x2, y2, x, y int32)
+	AbsArcTo(rx, ry, xAxisRotation int32, largeArc, sweep bool, x, y int32)
+	RelArcTo(rx, ry, xAxisRotation int32, largeArc, sweep bool, x, y int32)
+}
+
+// DecodeFixed decodes an IconVG graphic, like Decode, but passes fixed point
+// numbers to dst. It is for targets without floating point hardware, such as
+// microcontrollers: decoding the styling and drawing opcodes uses only
+// integer arithmetic.
+//
+// Numbers are rounded to the nearest representable value. Numbers outside of
+// the representable range, including infinities, saturate. NaNs become zero.
+// A level of detail's upper bound is often +Inf, which saturates to
+// math.MaxInt32.
+//
+// opts may be nil, which means to use the default options: in particular, a
+// FixedFormat of FixedInt26_6.
+func DecodeFixed(dst FixedDestination, src []byte, opts *DecodeOptions) error {
+	srcLen := len(src)
+	m := Metadata{
+		ViewBox: DefaultViewBox,
+		Palette: DefaultPalette,
+	}
+	chk := newChecker(nil, opts)
+	b, err := decodeHeader(nil, chk, &m, src, opts)
+	if err != nil {
+		return err
+	}
+
+	d := fixedDecoder{
+		dst:      dst,
+		chk:      chk,
+		fracBits: FixedInt26_6.fracBits(),
+	}
+	onInstruction, trace := (func(int))(nil), (func(int, Opcode, []byte))(nil)
+	if opts != nil {
+		d.fracBits = opts.FixedFormat.fracBits()
+		onInstruction, trace = opts.OnInstruction, opts.Trace
+	}
+	setFillRule(dst, opts)
+	if hasLimits(opts) {
+		d.lim = &limiter{}
+		d.lim.reset(nil, opts)
+	}
+	if dst != nil {
+		dst.Reset(m, FixedRectangle{
+			Min: [2]int32{
+				fixedFromFloat32Bits(math.Float32bits(m.ViewBox.Min[0]), d.fracBits),
+				fixedFromFloat32Bits(math.Float32bits(m.ViewBox.Min[1]), d.fracBits),
+			},
+			Max: [2]int32{
+				fixedFromFloat32Bits(math.Float32bits(m.ViewBox.Max[0]), d.fracBits),
+				fixedFromFloat32Bits(math.Float32bits(m.ViewBox.Max[1]), d.fracBits),
+			},
+		})
+	}
+
+	levels := newDetailLevels(dst, &m, srcLen-len(b), opts)
+	drawing, pathOffset, pathOpcode := false, 0, byte(0)
+	for len(b) > 0 {
+		if stop, err := levels.reach(srcLen-len(b), drawing); err != nil {
+			return err
+		} else if stop {
+			return nil
+		}
+		if onInstruction != nil {
+			onInstruction(srcLen - len(b))
+		}
+		if d.lim != nil {
+			if err := d.lim.opcode(); err != nil {
+				return err
+			}
+		}
+		offset, op, instruction := srcLen-len(b), Opcode{b[0], drawing}, b
+		chk.locate(offset, op.Byte, false)
+		if drawing {
+			drawing, b, err = d.decodeDrawing(b)
+		} else {
+			drawing, b, err = d.decodeStyling(b)
+		}
+		if err != nil {
+			return annotate(err, offset, op.Byte, false)
+		} else if (d.lim != nil) && (d.lim.err != nil) {
+			return d.lim.err
+		}
+		if trace != nil {
+			trace(offset, op, instruction[1:len(instruction)-len(b)])
+		}
+		if !op.Drawing {
+			pathOffset, pathOpcode = offset, op.Byte
+		}
+	}
+	if err := levels.finish(srcLen, drawing); err != nil {
+		return err
+	}
+	return checkFinished(chk, drawing, pathOffset, pathOpcode)
+}
+
+// fixedDecoder holds the state for DecodeFixed. Its decodeStyling and
+// decodeDrawing methods mirror the decodeStyling and decodeDrawing functions,
+// returning whether the next opcode is a drawing opcode.
+type fixedDecoder struct {
+	dst      FixedDestination
+	lim      *limiter
+	chk      *checker
+	fracBits uint
+}
+
+// count counts nSegments path segments and nNumbers numbers or colors against
+// the resource limits, if any. It returns whether to call d.dst's methods.
+func (d *fixedDecoder) count(nSegments int, nNumbers int) bool {
+	if d.lim != nil {
+		d.lim.count(nSegments, nNumbers)
+		if d.lim.err != nil {
+			return false
+		}
+	}
+	return d.dst != nil
+}
+
+func (d *fixedDecoder) decodeStyling(src buffer) (drawing bool, src1 buffer, retErr error) {
+	switch opcode := src[0]; {
+	case opcode < 0x40:
+		if d.count(0, 0) {
+			d.dst.SetCSel(opcode & 0x3f)
+		}
+		return false, src[1:], nil
+
+	case opcode < 0x80:
+		if d.count(0, 0) {
+			d.dst.SetNSel(opcode & 0x3f)
+		}
+		return false, src[1:], nil
+
+	case opcode < 0xa8:
+		adj := opcode & 0x07
+		incr := adj == 7
+		if incr {
+			adj = 0
+		}
+		decode := [5]func(buffer) (Color, int){
+			buffer.decodeColor1,
+			buffer.decodeColor2,
+			buffer.decodeColor3Direct,
+			buffer.decodeColor4,
+			buffer.decodeColor3Indirect,
+		}[(opcode-0x80)>>3]
+		c, n := decode(src[1:])
+		if n == 0 {
+			return false, nil, ErrInvalidColor
+		}
+		c, err := checkColor(c, d.chk)
+		if err != nil {
+			return false, nil, err
+		}
+		if d.count(0, 1) {
+			d.dst.SetCReg(adj, incr, c)
+		}
+		return false, src[1+n:], nil
+
+	case opcode < 0xc0:
+		adj := opcode & 0x07
+		incr := adj == 7
+		if incr {
+			adj = 0
+		}
+		decode := [3]decodeFixedFunc{
+			buffer.decodeRealFixed,
+			buffer.decodeCoordinateFixed,
+			buffer.decodeZeroToOneFixed,
+		}[(opcode-0xa8)>>3]
+		f, n := decode(src[1:], d.fracBits)
+		if n == 0 {
+			return false, nil, ErrInvalidNumber
+		}
+		if d.count(0, 1) {
+			d.dst.SetNReg(adj, incr, f)
+		}
+		return false, src[1+n:], nil
+
+	case opcode < 0xc7:
+		var coords [2]int32
+		src, err := d.decodeNumbers(coords[:], src[1:], buffer.decodeCoordinateFixed)
+		if err != nil {
+			return false, nil, err
+		}
+		if d.count(1, 2) {
+			d.dst.StartPath(opcode&0x07, coords[0], coords[1])
+		}
+		return true, src, nil
+
+	case opcode == 0xc7:
+		var lods [2]int32
+		src, err := d.decodeNumbers(lods[:], src[1:], buffer.decodeRealFixed)
+		if err != nil {
+			return false, nil, err
+		}
+		if d.count(0, 2) {
+			d.dst.SetLOD(lods[0], lods[1])
+		}
+		return false, src, nil
+	}
+	return false, nil, ErrUnsupportedStylingOpcode
+}
+
+func (d *fixedDecoder) decodeDrawing(src buffer) (drawing bool, src1 buffer, retErr error) {
+	var coords [6]int32
+
+	switch opcode := src[0]; {
+	case opcode < 0xe0:
+		nCoords, nReps := 0, 1+int(opcode&0x0f)
+		switch opcode >> 4 {
+		case 0x00, 0x01, 0x02, 0x03:
+			nCoords = 2
+			nReps = 1 + int(opcode&0x1f)
+		case 0x04, 0x05:
+			nCoords = 2
+		case 0x06, 0x07, 0x08, 0x09:
+			nCoords = 4
+		default:
+			nCoords = 6
+		}
+		src = src[1:]
+
+		for i := 0; i < nReps; i++ {
+			var largeArc, sweep bool
+			err := error(nil)
+			if opcode < 0xc0 {
+				src, err = d.decodeNumbers(coords[:nCoords], src, buffer.decodeCoordinateFixed)
+			} else {
+				src, err = d.decodeArcTo(&coords, &largeArc, &sweep, src)
+			}
+			if err != nil {
+				return false, nil, err
+			}
+
+			if !d.count(1, nCoords) {
+				continue
+			}
+			switch opcode >> 4 {
+			case 0x00, 0x01:
+				d.dst.AbsLineTo(coords[0], coords[1])
+			case 0x02, 0x03:
+				d.dst.RelLineTo(coords[0], coords[1])
+			case 0x04:
+				d.dst.AbsSmoothQuadTo(coords[0], coords[1])
+			case 0x05:
+				d.dst.RelSmoothQuadTo(coords[0], coords[1])
+			case 0x06:
+				d.dst.AbsQuadTo(coords[0], coords[1], coords[2], coords[3])
+			case 0x07:
+				d.dst.RelQuadTo(coords[0], coords[1], coords[2], coords[3])
+			case 0x08:
+				d.dst.AbsSmoothCubeTo(coords[0], coords[1], coords[2], coords[3])
+			case 0x09:
+				d.dst.RelSmoothCubeTo(coords[0], coords[1], coords[2], coords[3])
+			case 0x0a:
+				d.dst.AbsCubeTo(coords[0], coords[1], coords[2], coords[3], coords[4], coords[5])
+			case 0x0b:
+				d.dst.RelCubeTo(coords[0], coords[1], coords[2], coords[3], coords[4], coords[5])
+			case 0x0c:
+				d.dst.AbsArcTo(coords[0], coords[1], coords[2], largeArc, sweep, coords[4], coords[5])
+			case 0x0d:
+				d.dst.RelArcTo(coords[0], coords[1], coords[2], largeArc, sweep, coords[4], coords[5])
+			}
+		}
+		return true, src, nil
+
+	case opcode == 0xe1:
+		if d.count(0, 0) {
+			d.dst.ClosePathEndPath()
+		}
+		return false, src[1:], nil
+
+	case (opcode == 0xe2) || (opcode == 0xe3):
+		src, err := d.decodeNumbers(coords[:2], src[1:], buffer.decodeCoordinateFixed)
+		if err != nil {
+			return false, nil, err
+		}
+		if d.count(1, 2) {
+			if opcode == 0xe2 {
+				d.dst.ClosePathAbsMoveTo(coords[0], coords[1])
+			} else {
+				d.dst.ClosePathRelMoveTo(coords[0], coords[1])
+			}
+		}
+		return true, src, nil
+
+	case (0xe6 <= opcode) && (opcode < 0xea):
+		src, err := d.decodeNumbers(coords[:1], src[1:], buffer.decodeCoordinateFixed)
+		if err != nil {
+			return false, nil, err
+		}
+		if d.count(1, 1) {
+			switch opcode {
+			case 0xe6:
+				d.dst.AbsHLineTo(coords[0])
+			case 0xe7:
+				d.dst.RelHLineTo(coords[0])
+			case 0xe8:
+				d.dst.AbsVLineTo(coords[0])
+			case 0xe9:
+				d.dst.RelVLineTo(coords[0])
+			}
+		}
+		return true, src, nil
+	}
+	return false, nil, ErrUnsupportedDrawingOpcode
+}
+
+// decodeArcTo decodes one repetition of an arcTo: the radii, the rotation,
+// the flags and the end point. The rotation is held in coords[2], leaving
+// coords[3] unused, so that coords has the same layout as for a cubeTo.
+func (d *fixedDecoder) decodeArcTo(coords *[6]int32, largeArc *bool, sweep *bool, src buffer) (src1 buffer, retErr error) {
+	src, err := d.decodeNumbers(coords[0:2], src, buffer.decodeCoordinateFixed)
+	if err != nil {
+		return nil, err
+	}
+	src, err = d.decodeNumbers(coords[2:3], src, buffer.decodeZeroToOneFixed)
+	if err != nil {
+		return nil, err
+	}
+	x, n := src.decodeNatural()
+	if n == 0 {
+		return nil, ErrInvalidNumber
+	}
+	*largeArc, *sweep = (x>>0)&0x01 != 0, (x>>1)&0x01 != 0
+	return d.decodeNumbers(coords[4:6], src[n:], buffer.decodeCoordinateFixed)
+}
+
+func (d *fixedDecoder) decodeNumbers(dst []int32, src buffer, dff decodeFixedFunc) (src1 buffer, retErr error) {
+	for i := range dst {
+		x, n := dff(src, d.fracBits)
+		if n == 0 {
+			return nil, ErrInvalidNumber
+		}
+		dst[i], src = x, src[n:]
+	}
+	return src, nil
+}
+
+type decodeFixedFunc func(b buffer, fracBits uint) (int32, int)
+
+// decodeRealFixed is like decodeReal but returns a fixed point number with
+// fracBits fractional bits.
+func (b buffer) decodeRealFixed(fracBits uint) (x int32, n int) {
+	switch u, n := b.decodeNatural(); n {
+	case 0:
+		return 0, n
+	case 1, 2:
+		return saturateInt32(int64(u) << fracBits), n
+	default:
+		return fixedFromFloat32Bits(u<<2, fracBits), n
+	}
+}
+
+// decodeCoordinateFixed is like decodeCoordinate but returns a fixed point
+// number with fracBits fractional bits.
+func (b buffer) decodeCoordinateFixed(fracBits uint) (x int32, n int) {
+	switch u, n := b.decodeNatural(); n {
+	case 0:
+		return 0, n
+	case 1:
+		return saturateInt32((int64(u) - 64) << fracBits), n
+	case 2:
+		// The 2 byte form has 6 fractional bits.
+		return saturateInt32(((int64(u) - 64*128) << fracBits) >> 6), n
+	default:
+		return fixedFromFloat32Bits(u<<2, fracBits), n
+	}
+}
+
+// decodeZeroToOneFixed is like decodeZeroToOne but returns a fixed point
+// number with fracBits fractional bits.
+func (b buffer) decodeZeroToOneFixed(fracBits uint) (x int32, n int) {
+	switch u, n := b.decodeNatural(); n {
+	case 0:
+		return 0, n
+	case 1:
+		return int32(((int64(u) << fracBits) + 60) / 120), n
+	case 2:
+		return int32(((int64(u) << fracBits) + 7560) / 15120), n
+	default:
+		return fixedFromFloat32Bits(u<<2, fracBits), n
+	}
+}
+
+// fixedFromFloat32Bits converts the float32 whose IEEE 754 bits are u to a
+// fixed point number with fracBits fractional bits, using only integer
+// arithmetic. It rounds to nearest (with ties away from zero), saturates and
+// maps NaN to zero.
+func fixedFromFloat32Bits(u uint32, fracBits uint) int32 {
+	exp := int((u >> 23) & 0xff)
+	mant := int64(u & 0x7fffff)
+	neg := (u >> 31) != 0
+	if exp == 0xff {
+		if mant != 0 {
+			return 0
+		} else if neg {
+			return math.MinInt32
+		}
+		return math.MaxInt32
+	}
+	if exp == 0 {
+		// Subnormal numbers have no implicit leading bit.
+		exp = 1
+	} else {
+		mant |= 1 << 23
+	}
+
+	// The float32 value is (mant × 2**(exp - 150)), so the fixed point value
+	// is (mant × 2**shift). mant has at most 24 bits, so a shift of 40 or more
+	// overflows an int32 and a shift of -25 or less rounds to zero.
+	x := int64(0)
+	if shift := exp - 150 + int(fracBits); shift >= 0 {
+		if shift >= 40 {
+			x = math.MaxInt64
+		} else {
+			x = mant << uint(shift)
+		}
+	} else if shift > -25 {
+		x = (mant + (1 << uint(-shift-1))) >> uint(-shift)
+	}
+	if neg {
+		x = -x
+	}
+	return saturateInt32(x)
+}
+
+func saturateInt32(x int64) int32 {
+	if x < math.MinInt32 {
+		return math.MinInt32
+	} else if x > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(x)
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// call is a recorded Destination or FixedDestination method call, with its
+// numeric arguments converted to float64.
+type call struct {
+	op   string
+	args []float64
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// floatRecorder is a Destination that records its method calls.
+type floatRecorder struct {
+	calls []call
+}
+
+func (r *floatRecorder) rec(op string, args ...float32) {
+	c := call{op: op}
+	for _, a := range args {
+		c.args = append(c.args, float64(a))
+	}
+	r.calls = append(r.calls, c)
+}
+
+func (r *floatRecorder) Reset(m lowlevel.Metadata) {
+	r.rec("Reset", m.ViewBox.Min[0], m.ViewBox.Min[1], m.ViewBox.Max[0], m.ViewBox.Max[1])
+}
+func (r *floatRecorder) SetCSel(cSel uint8) { r.rec("SetCSel", float32(cSel)) }
+func (r *floatRecorder) SetNSel(nSel uint8) { r.rec("SetNSel", float32(nSel)) }
+func (r *floatRecorder) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	r.rec("SetCReg", float32(adj), float32(b2f(incr)))
+}
+func (r *floatRecorder) SetNReg(adj uint8, incr bool, f float32) {
+	r.rec("SetNReg", float32(adj), float32(b2f(incr)), f)
+}
+func (r *floatRecorder) SetLOD(lod0, lod1 float32)         { r.rec("SetLOD", lod0, lod1) }
+func (r *floatRecorder) StartPath(adj uint8, x, y float32) { r.rec("StartPath", float32(adj), x, y) }
+func (r *floatRecorder) ClosePathEndPath()                 { r.rec("ClosePathEndPath") }
+func (r *floatRecorder) ClosePathAbsMoveTo(x, y float32)   { r.rec("ClosePathAbsMoveTo", x, y) }
+func (r *floatRecorder) ClosePathRelMoveTo(x, y float32)   { r.rec("ClosePathRelMoveTo", x, y) }
+func (r *floatRecorder) AbsHLineTo(x float32)              { r.rec("AbsHLineTo", x) }
+func (r *floatRecorder) RelHLineTo(x float32)              { r.rec("RelHLineTo", x) }
+func (r *floatRecorder) AbsVLineTo(y float32)              { r.rec("AbsVLineTo", y) }
+func (r *floatRecorder) RelVLineTo(y float32)              { r.rec("RelVLineTo", y) }
+func (r *floatRecorder) AbsLineTo(x, y float32)            { r.rec("AbsLineTo", x, y) }
+func (r *floatRecorder) RelLineTo(x, y float32)            { r.rec("RelLineTo", x, y) }
+func (r *floatRecorder) AbsSmoothQuadTo(x, y float32)      { r.rec("AbsSmoothQuadTo", x, y) }
+func (r *floatRecorder) RelSmoothQuadTo(x, y float32)      { r.rec("RelSmoothQuadTo", x, y) }
+func (r *floatRecorder) AbsQuadTo(x1, y1, x, y float32)    { r.rec("AbsQuadTo", x1, y1, x, y) }
+func (r *floatRecorder) RelQuadTo(x1, y1, x, y float32)    { r.rec("RelQuadTo", x1, y1, x, y) }
+func (r *floatRecorder) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	r.rec("AbsSmoothCubeTo", x2, y2, x, y)
+}
+func (r *floatRecorder) RelSmoothCubeTo(x2, y2, x, y float32) {
+	r.rec("RelSmoothCubeTo", x2, y2, x, y)
+}
+func (r *floatRecorder) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.rec("AbsCubeTo", x1, y1, x2, y2, x, y)
+}
+func (r *floatRecorder) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	r.rec("RelCubeTo", x1, y1, x2, y2, x, y)
+}
+func (r *floatRecorder) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.rec("AbsArcTo", rx, ry, xAxisRotation, float32(b2f(largeArc)), float32(b2f(sweep)), x, y)
+}
+func (r *floatRecorder) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	r.rec("RelArcTo", rx, ry, xAxisRotation, float32(b2f(largeArc)), float32(b2f(sweep)), x, y)
+}
+
+// fixedRecorder is a FixedDestination that records its method calls, with
+// its fixed point arguments converted to float64.
+type fixedRecorder struct {
+	calls []call
+	scale float64
+}
+
+func (r *fixedRecorder) rec(op string, args ...int32) {
+	c := call{op: op}
+	for _, a := range args {
+		c.args = append(c.args, float64(a)/r.scale)
+	}
+	r.calls = append(r.calls, c)
+}
+
+// recInt is like rec, but for arguments that are integers, not fixed point.
+func (r *fixedRecorder) recInt(op string, args ...float64) {
+	r.calls = append(r.calls, call{op, args})
+}
+
+func (r *fixedRecorder) Reset(m lowlevel.Metadata, viewBox lowlevel.FixedRectangle) {
+	r.rec("Reset", viewBox.Min[0], viewBox.Min[1], viewBox.Max[0], viewBox.Max[1])
+}
+func (r *fixedRecorder) SetCSel(cSel uint8) { r.recInt("SetCSel", float64(cSel)) }
+func (r *fixedRecorder) SetNSel(nSel uint8) { r.recInt("SetNSel", float64(nSel)) }
+func (r *fixedRecorder) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	r.recInt("SetCReg", float64(adj), b2f(incr))
+}
+func (r *fixedRecorder) SetNReg(adj uint8, incr bool, f int32) {
+	r.recInt("SetNReg", float64(adj), b2f(incr), float64(f)/r.scale)
+}
+func (r *fixedRecorder) SetLOD(lod0, lod1 int32) { r.rec("SetLOD", lod0, lod1) }
+func (r *fixedRecorder) StartPath(adj uint8, x, y int32) {
+	r.recInt("StartPath", float64(adj), float64(x)/r.scale, float64(y)/r.scale)
+}
+func (r *fixedRecorder) ClosePathEndPath()             { r.rec("ClosePathEndPath") }
+func (r *fixedRecorder) ClosePathAbsMoveTo(x, y int32) { r.rec("ClosePathAbsMoveTo", x, y) }
+func (r *fixedRecorder) ClosePathRelMoveTo(x, y int32) { r.rec("ClosePathRelMoveTo", x, y) }
+func (r *fixedRecorder) AbsHLineTo(x int32)            { r.rec("AbsHLineTo", x) }
+func (r *fixedRecorder) RelHLineTo(x int32)            { r.rec("RelHLineTo", x) }
+func (r *fixedRecorder) AbsVLineTo(y int32)            { r.rec("AbsVLineTo", y) }
+func (r *fixedRecorder) RelVLineTo(y int32)            { r.rec("RelVLineTo", y) }
+func (r *fixedRecorder) AbsLineTo(x, y int32)          { r.rec("AbsLineTo", x, y) }
+func (r *fixedRecorder) RelLineTo(x, y int32)          { r.rec("RelLineTo", x, y) }
+func (r *fixedRecorder) AbsSmoothQuadTo(x, y int32)    { r.rec("AbsSmoothQuadTo", x, y) }
+func (r *fixedRecorder) RelSmoothQuadTo(x, y int32)    { r.rec("RelSmoothQuadTo", x, y) }
+func (r *fixedRecorder) AbsQuadTo(x1, y1, x, y int32)  { r.rec("AbsQuadTo", x1, y1, x, y) }
+func (r *fixedRecorder) RelQuadTo(x1, y1, x, y int32)  { r.rec("RelQuadTo", x1, y1, x, y) }
+func (r *fixedRecorder) AbsSmoothCubeTo(x2, y2, x, y int32) {
+	r.rec("AbsSmoothCubeTo", x2, y2, x, y)
+}
+func (r *fixedRecorder) RelSmoothCubeTo(x2, y2, x, y int32) {
+	r.rec("RelSmoothCubeTo", x2, y2, x, y)
+}
+func (r *fixedRecorder) AbsCubeTo(x1, y1, x2, y2, x, y int32) {
+	r.rec("AbsCubeTo", x1, y1, x2, y2, x, y)
+}
+func (r *fixedRecorder) RelCubeTo(x1, y1, x2, y2, x, y int32) {
+	r.rec("RelCubeTo", x1, y1, x2, y2, x, y)
+}
+func (r *fixedRecorder) AbsArcTo(rx, ry, xAxisRotation int32, largeArc, sweep bool, x, y int32) {
+	r.recInt("AbsArcTo", float64(rx)/r.scale, float64(ry)/r.scale, float64(xAxisRotation)/r.scale,
+		b2f(largeArc), b2f(sweep), float64(x)/r.scale, float64(y)/r.scale)
+}
+func (r *fixedRecorder) RelArcTo(rx, ry, xAxisRotation int32, largeArc, sweep bool, x, y int32) {
+	r.recInt("RelArcTo", float64(rx)/r.scale, float64(ry)/r.scale, float64(xAxisRotation)/r.scale,
+		b2f(largeArc), b2f(sweep), float64(x)/r.scale, float64(y)/r.scale)
+}
+
+// TestDecodeFixed checks that DecodeFixed calls the same methods, with the
+// same numbers (give or take rounding and saturation), as Decode.
+func TestDecodeFixed(t *testing.T) {
+	testCases := []struct {
+		format lowlevel.FixedFormat
+		scale  float64
+	}{
+		{lowlevel.FixedInt26_6, 1 << 6},
+		{lowlevel.FixedInt16_16, 1 << 16},
+	}
+	for _, filename := range []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"cowbell.ivg",
+		"elliptical.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+		"video-005.primitive.ivg",
+	} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &floatRecorder{}
+		if err := lowlevel.Decode(want, src, nil); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		for _, tc := range testCases {
+			got := &fixedRecorder{scale: tc.scale}
+			opts := &lowlevel.DecodeOptions{FixedFormat: tc.format}
+			if err := lowlevel.DecodeFixed(got, src, opts); err != nil {
+				t.Errorf("%s, format %d: %v", filename, tc.format, err)
+				continue
+			}
+			if len(got.calls) != len(want.calls) {
+				t.Errorf("%s, format %d: got %d calls, want %d", filename, tc.format, len(got.calls), len(want.calls))
+				continue
+			}
+			maxFixed := math.MaxInt32 / tc.scale
+			for i, g := range got.calls {
+				w := want.calls[i]
+				if g.op != w.op || len(g.args) != len(w.args) {
+					t.Errorf("%s, format %d: call #%d: got %v, want %v", filename, tc.format, i, g, w)
+					break
+				}
+				for j := range g.args {
+					wj := math.Max(-maxFixed, math.Min(w.args[j], maxFixed))
+					if math.Abs(g.args[j]-wj) > 0.5/tc.scale {
+						t.Errorf("%s, format %d: call #%d: got %v, want %v", filename, tc.format, i, g, w)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeFixedNumbers(t *testing.T) {
+	testCases := []struct {
+		f    float32
+		want int32
+	}{
+		{0, 0},
+		{1, 64},
+		{-2.5, -160},
+		{0.3, 19},
+		{1e9, math.MaxInt32},
+		{-1e9, math.MinInt32},
+		{float32(math.Inf(+1)), math.MaxInt32},
+		{float32(math.Inf(-1)), math.MinInt32},
+		{float32(math.NaN()), 0},
+	}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		})
+		e.SetNReg(0, false, tc.f)
+		src, err := e.Bytes()
+		if err != nil {
+			t.Fatalf("f=%v: %v", tc.f, err)
+		}
+		r := &fixedRecorder{scale: 1}
+		if err := lowlevel.DecodeFixed(r, src, nil); err != nil {
+			t.Errorf("f=%v: %v", tc.f, err)
+			continue
+		}
+		if len(r.calls) != 2 || r.calls[1].op != "SetNReg" {
+			t.Errorf("f=%v: got calls %v, want Reset and SetNReg", tc.f, r.calls)
+			continue
+		}
+		if got := int32(r.calls[1].args[2]); got != tc.want {
+			t.Errorf("f=%v: got %d, want %d", tc.f, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// addSeedCorpus adds the test/data IconVG graphics to f's seed corpus.
+func addSeedCorpus(f *testing.F) {
+	filenames, err := filepath.Glob("../../../test/data/*.ivg")
+	if err != nil {
+		f.Fatal(err)
+	} else if len(filenames) == 0 {
+		f.Fatal("no seed corpus files found")
+	}
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+}
+
+// checkFormatError fails t if err is neither nil nor a FormatError.
+func checkFormatError(t *testing.T, funcName string, err error) {
+	var formatError lowlevel.FormatError
+	if (err != nil) && !errors.As(err, &formatError) {
+		t.Fatalf("%s: got %T error %v, want a FormatError", funcName, err, err)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addSeedCorpus(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		err := lowlevel.Decode(nil, src, nil)
+		checkFormatError(t, "Decode", err)
+
+		streamErr := lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(nil, nil)
+		checkFormatError(t, "StreamDecoder.Decode", streamErr)
+		if (err == nil) != (streamErr == nil) {
+			t.Fatalf("Decode and StreamDecoder.Decode disagree: %v versus %v", err, streamErr)
+		}
+
+		_, metadataErr := lowlevel.DecodeMetadata(src)
+		checkFormatError(t, "DecodeMetadata", metadataErr)
+		if (err == nil) && (metadataErr != nil) {
+			t.Fatalf("DecodeMetadata: %v", metadataErr)
+		}
+
+		// Rendering passes arbitrary numbers to the Rasterizer's arc and
+		// gradient code.
+		if err == nil {
+			dst := image.NewRGBA(image.Rect(0, 0, 16, 16))
+			if err := raster.Render(dst, dst.Bounds(), src, nil); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+		}
+	})
+}
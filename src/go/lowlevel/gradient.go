@@ -0,0 +1,59 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"math"
+
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// InvertGradientTransform returns the inverse of a gradient's transformation
+// m, and whether m is invertible. m maps from the graphic's coordinate space
+// to gradient coordinate space, where a linear gradient ranges from x=0 to x=1
+// and a radial gradient is the unit circle centered on the origin: see the
+// "Colors and Gradients" section in the specification.
+//
+// A linear gradient's transformation ignores the y coordinate, so it is
+// singular. If linear is true, it is made invertible by setting its second
+// row to be perpendicular to its first.
+func InvertGradientTransform(m f32.Aff3, linear bool) (inv f64.Aff3, ok bool) {
+	n := f64.Aff3{}
+	for i := range m {
+		n[i] = float64(m[i])
+	}
+	if linear {
+		n[3], n[4], n[5] = -n[1], n[0], 0
+	}
+	return InvertAff3(n)
+}
+
+// InvertAff3 returns the inverse of the affine transformation m, and whether m
+// is invertible.
+func InvertAff3(m f64.Aff3) (inv f64.Aff3, ok bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if (det == 0) || math.IsNaN(det) || math.IsInf(det, 0) {
+		return f64.Aff3{}, false
+	}
+	return f64.Aff3{
+		+m[4] / det,
+		-m[1] / det,
+		(m[1]*m[5] - m[2]*m[4]) / det,
+		-m[3] / det,
+		+m[0] / det,
+		(m[2]*m[3] - m[0]*m[5]) / det,
+	}, true
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+func TestInvertGradientTransform(t *testing.T) {
+	nan := float32(math.NaN())
+	testCases := []struct {
+		m      f32.Aff3
+		linear bool
+		want   f64.Aff3
+		wantOK bool
+	}{
+		{f32.Aff3{1, 0, 0, 0, 1, 0}, false, f64.Aff3{1, 0, 0, 0, 1, 0}, true},
+		{f32.Aff3{2, 0, 4, 0, 4, 8}, false, f64.Aff3{0.5, 0, -2, 0, 0.25, -2}, true},
+		{f32.Aff3{0, 1, 0, -1, 0, 0}, false, f64.Aff3{0, -1, 0, 1, 0, 0}, true},
+		// A linear gradient's second row is ignored, and replaced by one
+		// perpendicular to the first.
+		{f32.Aff3{0.5, 0, -1, 0, 0, 0}, false, f64.Aff3{}, false},
+		{f32.Aff3{0.5, 0, -1, 0, 0, 0}, true, f64.Aff3{2, 0, 2, 0, 2, 0}, true},
+		{f32.Aff3{0.5, 0, -1, 7, 8, 9}, true, f64.Aff3{2, 0, 2, 0, 2, 0}, true},
+		{f32.Aff3{0, 0, 1, 0, 0, 0}, true, f64.Aff3{}, false},
+		{f32.Aff3{nan, 0, 0, 0, 1, 0}, false, f64.Aff3{}, false},
+	}
+	for _, tc := range testCases {
+		got, ok := lowlevel.InvertGradientTransform(tc.m, tc.linear)
+		if (got != tc.want) || (ok != tc.wantOK) {
+			t.Errorf("%v, linear=%t: got %v, %t, want %v, %t", tc.m, tc.linear, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestInvertAff3(t *testing.T) {
+	m := f64.Aff3{1.5, -0.5, 3, 0.25, 2, -7}
+	inv, ok := lowlevel.InvertAff3(m)
+	if !ok {
+		t.Fatalf("got ok false, want true")
+	}
+	// Mapping a point by m and then by inv should give back the point.
+	for _, p := range [][2]float64{{0, 0}, {1, 0}, {0, 1}, {-3, 5}} {
+		x := m[0]*p[0] + m[1]*p[1] + m[2]
+		y := m[3]*p[0] + m[4]*p[1] + m[5]
+		gx := inv[0]*x + inv[1]*y + inv[2]
+		gy := inv[3]*x + inv[4]*y + inv[5]
+		if (math.Abs(gx-p[0]) > 1e-12) || (math.Abs(gy-p[1]) > 1e-12) {
+			t.Errorf("%v: got (%v, %v)", p, gx, gy)
+		}
+	}
+	if _, ok := lowlevel.InvertAff3(f64.Aff3{1, 2, 0, 2, 4, 0}); ok {
+		t.Errorf("singular: got ok true, want false")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+)
+
+var errInvalidJSONColor = errors.New("iconvg: invalid JSON color")
+
+// jsonColor is the JSON form of an indirect Color. Exactly one of its fields
+// is non-nil.
+type jsonColor struct {
+	Palette *uint8 `json:"palette,omitempty"`
+	CReg    *uint8 `json:"creg,omitempty"`
+	Blend   []int  `json:"blend,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. A direct Color is a
+// "#rrggbbaa" string, whose values are alpha-premultiplied. Indirect Colors
+// are objects: {"palette": i}, {"creg": i} or {"blend": [t, c0, c1]}, whose
+// values are the arguments to PaletteIndexColor, CRegColor or BlendColor.
+func (c Color) MarshalJSON() ([]byte, error) {
+	switch c.typ {
+	case ColorTypeRGBA:
+		return []byte(fmt.Sprintf(`"#%02x%02x%02x%02x"`, c.data.R, c.data.G, c.data.B, c.data.A)), nil
+	case ColorTypePaletteIndex:
+		i := c.paletteIndex()
+		return json.Marshal(jsonColor{Palette: &i})
+	case ColorTypeCReg:
+		i := c.cReg()
+		return json.Marshal(jsonColor{CReg: &i})
+	}
+	t, c0, c1 := c.blend()
+	return json.Marshal(jsonColor{Blend: []int{int(t), int(c0), int(c1)}})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting what
+// MarshalJSON produces.
+func (c *Color) UnmarshalJSON(b []byte) error {
+	if (len(b) > 0) && (b[0] == '"') {
+		s := ""
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if (len(s) != 9) || (s[0] != '#') {
+			return errInvalidJSONColor
+		}
+		u, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return errInvalidJSONColor
+		}
+		*c = RGBAColor(color.RGBA{uint8(u >> 24), uint8(u >> 16), uint8(u >> 8), uint8(u)})
+		return nil
+	}
+
+	j := jsonColor{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	switch {
+	case (j.Palette != nil) && (j.CReg == nil) && (j.Blend == nil):
+		*c = PaletteIndexColor(*j.Palette)
+	case (j.Palette == nil) && (j.CReg != nil) && (j.Blend == nil):
+		*c = CRegColor(*j.CReg)
+	case (j.Palette == nil) && (j.CReg == nil) && (j.Blend != nil):
+		// Unlike a [3]uint8, which would zero-fill a short array, this
+		// rejects blends that do not have exactly three arguments.
+		if len(j.Blend) != 3 {
+			return errInvalidJSONColor
+		}
+		for _, x := range j.Blend {
+			if (x < 0) || (0xff < x) {
+				return errInvalidJSONColor
+			}
+		}
+		*c = BlendColor(uint8(j.Blend[0]), uint8(j.Blend[1]), uint8(j.Blend[2]))
+	default:
+		return errInvalidJSONColor
+	}
+	return nil
+}
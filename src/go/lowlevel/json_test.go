@@ -0,0 +1,75 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestColorJSON(t *testing.T) {
+	testCases := []struct {
+		c    lowlevel.Color
+		want string
+	}{
+		{lowlevel.RGBAColor(color.RGBA{0x12, 0x34, 0x56, 0xff}), `"#123456ff"`},
+		{lowlevel.RGBAColor(color.RGBA{}), `"#00000000"`},
+		{lowlevel.PaletteIndexColor(7), `{"palette":7}`},
+		{lowlevel.CRegColor(63), `{"creg":63}`},
+		{lowlevel.BlendColor(0x40, 0x80, 0xc1), `{"blend":[64,128,193]}`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.c)
+		if err != nil {
+			t.Errorf("%v: Marshal: %v", tc.c, err)
+			continue
+		}
+		if got := string(b); got != tc.want {
+			t.Errorf("%v: Marshal: got %s, want %s", tc.c, got, tc.want)
+		}
+		got := lowlevel.Color{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%v: Unmarshal: %v", tc.c, err)
+			continue
+		}
+		if got != tc.c {
+			t.Errorf("%v: Unmarshal: got %v, want %v", tc.c, got, tc.c)
+		}
+	}
+}
+
+func TestColorJSONErrors(t *testing.T) {
+	testCases := []string{
+		`"#123456"`,
+		`"123456ff0"`,
+		`"#12345g78"`,
+		`{}`,
+		`{"palette":1,"creg":2}`,
+		`{"blend":[1,2]}`,
+		`{"blend":[1,2,3,4]}`,
+		`{"blend":[1,2,256]}`,
+		`{"palette":300}`,
+		`12`,
+	}
+	for _, tc := range testCases {
+		c := lowlevel.Color{}
+		if err := json.Unmarshal([]byte(tc), &c); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+// DetailLevelDestination is implemented by Destinations, such as the Encoder,
+// that track a graphic's progressive detail levels (see
+// Metadata.DetailLevels). Decode calls EndDetailLevel at the end of each
+// level, between the Destination method calls for the level's paths and those
+// for the next level's.
+type DetailLevelDestination interface {
+	EndDetailLevel()
+}
+
+// detailLevels tracks the progressive detail levels of a graphic being
+// decoded.
+type detailLevels struct {
+	// ends are the levels' end offsets, relative to start, the offset of the
+	// first opcode. n is how many ends have been reached.
+	ends  []uint32
+	start int
+	n     int
+
+	// max is the DecodeOptions' MaxLOD. dst, if non-nil, is told about each
+	// level's end.
+	max int
+	dst DetailLevelDestination
+}
+
+func newDetailLevels(dst interface{}, m *Metadata, start int, opts *DecodeOptions) detailLevels {
+	l := detailLevels{
+		ends:  m.DetailLevels,
+		start: start,
+	}
+	if opts != nil {
+		l.max = opts.MaxLOD
+	}
+	l.dst, _ = dst.(DetailLevelDestination)
+	return l
+}
+
+// reach is called before decoding the instruction at offset, and at the end
+// of the graphic with offset being its length. It returns whether decoding
+// should stop, as MaxLOD levels have been decoded.
+func (l *detailLevels) reach(offset int, drawing bool) (stop bool, err error) {
+	for (l.n < len(l.ends)) && (l.start+int(l.ends[l.n]) <= offset) {
+		// A level must end between instructions, in the styling mode.
+		if (l.start+int(l.ends[l.n]) != offset) || drawing {
+			return false, annotate(ErrInvalidDetailLevels, offset, 0, false)
+		}
+		l.n++
+		if l.dst != nil {
+			l.dst.EndDetailLevel()
+		}
+		if (l.max > 0) && (l.n >= l.max) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// finish is called at the end of the graphic, whose length is offset.
+func (l *detailLevels) finish(offset int, drawing bool) error {
+	l.max = 0
+	if _, err := l.reach(offset, drawing); err != nil {
+		return err
+	} else if l.n < len(l.ends) {
+		return annotate(ErrInvalidDetailLevels, offset, 0, false)
+	}
+	return nil
+}
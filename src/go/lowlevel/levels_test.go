@@ -0,0 +1,224 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// levelRecorder records a Destination's paths, as "P", and the ends of its
+// detail levels, as "|".
+type levelRecorder struct {
+	lowlevel.NopDestination
+	ops string
+}
+
+func (r *levelRecorder) StartPath(adj uint8, x float32, y float32) { r.ops += "P" }
+func (r *levelRecorder) EndDetailLevel()                           { r.ops += "|" }
+
+func encodeDetailLevels(t *testing.T, m lowlevel.Metadata) []byte {
+	t.Helper()
+	e := &lowlevel.Encoder{}
+	e.Reset(m)
+	e.StartPath(0, -30, -30)
+	e.AbsLineTo(30, 30)
+	e.AbsLineTo(-30, 30)
+	e.ClosePathEndPath()
+	e.EndDetailLevel()
+	e.StartPath(0, -10, -10)
+	e.AbsLineTo(10, 10)
+	e.AbsLineTo(-10, 10)
+	e.ClosePathEndPath()
+	e.EndDetailLevel()
+	e.StartPath(0, 1, 1)
+	e.AbsLineTo(2, 2)
+	e.AbsLineTo(1, 2)
+	e.ClosePathEndPath()
+	src, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), src...)
+}
+
+func TestDetailLevels(t *testing.T) {
+	src := encodeDetailLevels(t, lowlevel.Metadata{
+		ViewBox:      lowlevel.DefaultViewBox,
+		Palette:      lowlevel.DefaultPalette,
+		DetailLevels: make([]uint32, 3),
+	})
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.DetailLevels; (len(got) != 3) || (got[0] == 0) ||
+		(got[0] >= got[1]) || (got[1] >= got[2]) {
+		t.Fatalf("DetailLevels: got %v, want 3 increasing ends", got)
+	}
+
+	testCases := []struct {
+		maxLOD int
+		want   string
+	}{
+		{0, "P|P|P|"},
+		{1, "P|"},
+		{2, "P|P|"},
+		{3, "P|P|P|"},
+		{4, "P|P|P|"},
+		{-1, "P|P|P|"},
+	}
+	for _, tc := range testCases {
+		opts := &lowlevel.DecodeOptions{MaxLOD: tc.maxLOD}
+		r := &levelRecorder{}
+		if err := lowlevel.Decode(r, src, opts); err != nil {
+			t.Errorf("MaxLOD=%d: Decode: %v", tc.maxLOD, err)
+		} else if r.ops != tc.want {
+			t.Errorf("MaxLOD=%d: Decode: got %q, want %q", tc.maxLOD, r.ops, tc.want)
+		}
+		r = &levelRecorder{}
+		if err := lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(r, opts); err != nil {
+			t.Errorf("MaxLOD=%d: StreamDecoder: %v", tc.maxLOD, err)
+		} else if r.ops != tc.want {
+			t.Errorf("MaxLOD=%d: StreamDecoder: got %q, want %q", tc.maxLOD, r.ops, tc.want)
+		}
+	}
+
+	// A graphic that is not progressive ignores MaxLOD.
+	e := &lowlevel.Encoder{}
+	e.Reset(lowlevel.Metadata{ViewBox: lowlevel.DefaultViewBox, Palette: lowlevel.DefaultPalette})
+	e.StartPath(0, 1, 1)
+	e.AbsLineTo(2, 2)
+	e.ClosePathEndPath()
+	e.StartPath(0, 3, 3)
+	e.AbsLineTo(4, 4)
+	e.ClosePathEndPath()
+	plain, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &levelRecorder{}
+	if err := lowlevel.Decode(r, plain, &lowlevel.DecodeOptions{MaxLOD: 1}); err != nil {
+		t.Errorf("not progressive: %v", err)
+	} else if r.ops != "PP" {
+		t.Errorf("not progressive: got %q, want %q", r.ops, "PP")
+	}
+}
+
+func TestDetailLevelsChecksum(t *testing.T) {
+	// The Encoder fills in the detail levels before computing the checksum.
+	src := encodeDetailLevels(t, lowlevel.Metadata{
+		ViewBox:      lowlevel.DefaultViewBox,
+		Palette:      lowlevel.DefaultPalette,
+		Checksum:     true,
+		DetailLevels: make([]uint32, 3),
+	})
+	for name, err := range decodeErrors(src) {
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// Decoding only the first level stops before the end of the graphic.
+	r := &levelRecorder{}
+	if err := lowlevel.Decode(r, src, &lowlevel.DecodeOptions{MaxLOD: 1}); err != nil {
+		t.Errorf("MaxLOD=1: %v", err)
+	} else if r.ops != "P|" {
+		t.Errorf("MaxLOD=1: got %q, want %q", r.ops, "P|")
+	}
+}
+
+func TestEndDetailLevelErrors(t *testing.T) {
+	m := lowlevel.Metadata{
+		ViewBox:      lowlevel.DefaultViewBox,
+		Palette:      lowlevel.DefaultPalette,
+		DetailLevels: make([]uint32, 1),
+	}
+	testCases := []struct {
+		desc    string
+		nLevels int
+		draw    func(e *lowlevel.Encoder)
+		wantErr bool
+	}{
+		{"implicit end", 1, func(e *lowlevel.Encoder) {}, false},
+		{"empty level", 2, func(e *lowlevel.Encoder) { e.EndDetailLevel() }, false},
+		{"not progressive", 0, func(e *lowlevel.Encoder) { e.EndDetailLevel() }, true},
+		{"too many", 1, func(e *lowlevel.Encoder) {
+			e.EndDetailLevel()
+			e.EndDetailLevel()
+		}, true},
+		{"unfinished path", 1, func(e *lowlevel.Encoder) {
+			e.StartPath(0, 1, 2)
+			e.EndDetailLevel()
+		}, true},
+	}
+	for _, tc := range testCases {
+		m.DetailLevels = make([]uint32, tc.nLevels)
+		e := &lowlevel.Encoder{}
+		e.Reset(m)
+		tc.draw(e)
+		e.StartPath(0, 3, 4)
+		e.AbsLineTo(5, 6)
+		e.ClosePathEndPath()
+		src, err := e.Bytes()
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.desc, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if err := lowlevel.Decode(lowlevel.NopDestination{}, src, nil); err != nil {
+			t.Errorf("%s: Decode: %v", tc.desc, err)
+		}
+	}
+}
+
+func TestInvalidDetailLevels(t *testing.T) {
+	// Each src has a detail levels chunk, with a count and ends, and a path
+	// whose 3 byte start instruction is followed by a 1 byte z instruction.
+	testCases := []struct {
+		desc    string
+		levels  string
+		wantErr bool
+	}{
+		{"one level", "\x02\x08", false},
+		{"empty first level", "\x04\x00\x08", false},
+		{"no levels", "\x00", false},
+		{"mid-instruction", "\x02\x04", true},
+		{"drawing mode", "\x02\x06", true},
+		{"past the end", "\x02\x0a", true},
+		{"decreasing", "\x04\x08\x00", true},
+		{"count too large", "\x7e\x08", true},
+	}
+	for _, tc := range testCases {
+		chunk := "\x08" + tc.levels
+		src := []byte("\x89IVG\x02" + string(rune(len(chunk)<<1)) + chunk + "\xc0\x82\x84\xe1")
+		errs := map[string]error{
+			"Decode":        lowlevel.Decode(lowlevel.NopDestination{}, src, nil),
+			"StreamDecoder": lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(lowlevel.NopDestination{}, nil),
+		}
+		for name, err := range errs {
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("%s: %s: got error %v, want error %t", tc.desc, name, err, tc.wantErr)
+			} else if tc.wantErr && !errors.Is(err, lowlevel.ErrInvalidDetailLevels) {
+				t.Errorf("%s: %s: got %v, want %v", tc.desc, name, err, lowlevel.ErrInvalidDetailLevels)
+			}
+		}
+	}
+}
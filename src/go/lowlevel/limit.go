@@ -0,0 +1,262 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errTooManyOpcodes      = errors.New("iconvg: too many opcodes")
+	errTooManyPathSegments = errors.New("iconvg: too many path segments")
+	errTooMuchOutput       = errors.New("iconvg: too much output")
+)
+
+// limiter is a Destination that enforces the DecodeOptions' resource limits
+// before forwarding to another (possibly nil) Destination. Once a limit is
+// exceeded, it sets err and stops forwarding.
+type limiter struct {
+	dst Destination
+
+	maxOpcodes      int
+	maxPathSegments int
+	maxOutputBytes  int
+
+	nOpcodes      int
+	nPathSegments int
+	nOutputBytes  int
+
+	// ctx is the DecodeOptions' Context, if it can be done, and done is its
+	// Done channel.
+	ctx  context.Context
+	done <-chan struct{}
+
+	err error
+}
+
+// hasLimits returns whether opts has any resource limits, or a Context that
+// can be done.
+func hasLimits(opts *DecodeOptions) bool {
+	return (opts != nil) && ((opts.MaxOpcodes > 0) || (opts.MaxPathSegments > 0) || (opts.MaxOutputBytes > 0) ||
+		((opts.Context != nil) && (opts.Context.Done() != nil)))
+}
+
+// reset sets l to wrap dst and enforce opts' limits, clearing any counts from
+// a previous use of l.
+func (l *limiter) reset(dst Destination, opts *DecodeOptions) {
+	*l = limiter{
+		dst:             dst,
+		maxOpcodes:      opts.MaxOpcodes,
+		maxPathSegments: opts.MaxPathSegments,
+		maxOutputBytes:  opts.MaxOutputBytes,
+	}
+	if opts.Context != nil {
+		l.ctx, l.done = opts.Context, opts.Context.Done()
+	}
+}
+
+// release drops l's references to the Destination and the Context, so that
+// a reused limiter does not keep them alive.
+func (l *limiter) release() {
+	l.dst, l.ctx, l.done = nil, nil, nil
+}
+
+// opcode counts the decoding of one opcode.
+func (l *limiter) opcode() error {
+	l.nOpcodes++
+	if (l.maxOpcodes > 0) && (l.nOpcodes > l.maxOpcodes) {
+		l.err = errTooManyOpcodes
+	} else if l.done != nil {
+		select {
+		case <-l.done:
+			l.err = l.ctx.Err()
+		default:
+		}
+	}
+	return l.err
+}
+
+// count counts nSegments path segments and nNumbers numbers or colors, each
+// of which is 4 bytes of output. It returns whether to forward to l.dst.
+func (l *limiter) count(nSegments int, nNumbers int) bool {
+	if l.err != nil {
+		return false
+	}
+	l.nPathSegments += nSegments
+	l.nOutputBytes += 4 * nNumbers
+	if (l.maxPathSegments > 0) && (l.nPathSegments > l.maxPathSegments) {
+		l.err = errTooManyPathSegments
+	} else if (l.maxOutputBytes > 0) && (l.nOutputBytes > l.maxOutputBytes) {
+		l.err = errTooMuchOutput
+	}
+	return (l.err == nil) && (l.dst != nil)
+}
+
+func (l *limiter) Reset(m Metadata) {
+	if l.dst != nil {
+		l.dst.Reset(m)
+	}
+}
+
+func (l *limiter) SetCSel(cSel uint8) {
+	if l.count(0, 0) {
+		l.dst.SetCSel(cSel)
+	}
+}
+
+func (l *limiter) SetNSel(nSel uint8) {
+	if l.count(0, 0) {
+		l.dst.SetNSel(nSel)
+	}
+}
+
+func (l *limiter) SetCReg(adj uint8, incr bool, c Color) {
+	if l.count(0, 1) {
+		l.dst.SetCReg(adj, incr, c)
+	}
+}
+
+func (l *limiter) SetNReg(adj uint8, incr bool, f float32) {
+	if l.count(0, 1) {
+		l.dst.SetNReg(adj, incr, f)
+	}
+}
+
+func (l *limiter) SetLOD(lod0, lod1 float32) {
+	if l.count(0, 2) {
+		l.dst.SetLOD(lod0, lod1)
+	}
+}
+
+func (l *limiter) StartPath(adj uint8, x, y float32) {
+	if l.count(1, 2) {
+		l.dst.StartPath(adj, x, y)
+	}
+}
+
+func (l *limiter) ClosePathEndPath() {
+	if l.count(0, 0) {
+		l.dst.ClosePathEndPath()
+	}
+}
+
+func (l *limiter) ClosePathAbsMoveTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.ClosePathAbsMoveTo(x, y)
+	}
+}
+
+func (l *limiter) ClosePathRelMoveTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.ClosePathRelMoveTo(x, y)
+	}
+}
+
+func (l *limiter) AbsHLineTo(x float32) {
+	if l.count(1, 1) {
+		l.dst.AbsHLineTo(x)
+	}
+}
+
+func (l *limiter) RelHLineTo(x float32) {
+	if l.count(1, 1) {
+		l.dst.RelHLineTo(x)
+	}
+}
+
+func (l *limiter) AbsVLineTo(y float32) {
+	if l.count(1, 1) {
+		l.dst.AbsVLineTo(y)
+	}
+}
+
+func (l *limiter) RelVLineTo(y float32) {
+	if l.count(1, 1) {
+		l.dst.RelVLineTo(y)
+	}
+}
+
+func (l *limiter) AbsLineTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.AbsLineTo(x, y)
+	}
+}
+
+func (l *limiter) RelLineTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.RelLineTo(x, y)
+	}
+}
+
+func (l *limiter) AbsSmoothQuadTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.AbsSmoothQuadTo(x, y)
+	}
+}
+
+func (l *limiter) RelSmoothQuadTo(x, y float32) {
+	if l.count(1, 2) {
+		l.dst.RelSmoothQuadTo(x, y)
+	}
+}
+
+func (l *limiter) AbsQuadTo(x1, y1, x, y float32) {
+	if l.count(1, 4) {
+		l.dst.AbsQuadTo(x1, y1, x, y)
+	}
+}
+
+func (l *limiter) RelQuadTo(x1, y1, x, y float32) {
+	if l.count(1, 4) {
+		l.dst.RelQuadTo(x1, y1, x, y)
+	}
+}
+
+func (l *limiter) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	if l.count(1, 4) {
+		l.dst.AbsSmoothCubeTo(x2, y2, x, y)
+	}
+}
+
+func (l *limiter) RelSmoothCubeTo(x2, y2, x, y float32) {
+	if l.count(1, 4) {
+		l.dst.RelSmoothCubeTo(x2, y2, x, y)
+	}
+}
+
+func (l *limiter) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	if l.count(1, 6) {
+		l.dst.AbsCubeTo(x1, y1, x2, y2, x, y)
+	}
+}
+
+func (l *limiter) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	if l.count(1, 6) {
+		l.dst.RelCubeTo(x1, y1, x2, y2, x, y)
+	}
+}
+
+func (l *limiter) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	if l.count(1, 6) {
+		l.dst.AbsArcTo(rx, ry, xAxisRotation, largeArc, sweep, x, y)
+	}
+}
+
+func (l *limiter) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	if l.count(1, 6) {
+		l.dst.RelArcTo(rx, ry, xAxisRotation, largeArc, sweep, x, y)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cowbell.ivg has 84 opcodes and 101 path segments, and passes 448
+	// numbers and colors, or 1792 bytes, to its Destination.
+	testCases := []struct {
+		name    string
+		set     func(o *lowlevel.DecodeOptions, n int)
+		n       int
+		wantErr string
+	}{
+		{"MaxOpcodes", func(o *lowlevel.DecodeOptions, n int) { o.MaxOpcodes = n }, 84, "iconvg: too many opcodes"},
+		{"MaxPathSegments", func(o *lowlevel.DecodeOptions, n int) { o.MaxPathSegments = n }, 101, "iconvg: too many path segments"},
+		{"MaxOutputBytes", func(o *lowlevel.DecodeOptions, n int) { o.MaxOutputBytes = n }, 1792, "iconvg: too much output"},
+	}
+	for _, tc := range testCases {
+		for _, dst := range []lowlevel.Destination{nil, lowlevel.NopDestination{}} {
+			opts := &lowlevel.DecodeOptions{}
+			tc.set(opts, tc.n)
+			if err := lowlevel.Decode(dst, src, opts); err != nil {
+				t.Errorf("%s=%d, dst=%T: got %v, want nil", tc.name, tc.n, dst, err)
+			}
+			tc.set(opts, tc.n-1)
+			if err := lowlevel.Decode(dst, src, opts); (err == nil) || (err.Error() != tc.wantErr) {
+				t.Errorf("%s=%d, dst=%T: got %v, want %q", tc.name, tc.n-1, dst, err, tc.wantErr)
+			}
+		}
+	}
+}
+
+func TestDecodeMaxNestingDepth(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IconVG byte code never nests, so any depth limit is met.
+	for _, n := range []int{-1, 0, 1} {
+		if err := lowlevel.Decode(nil, src, &lowlevel.DecodeOptions{MaxNestingDepth: n}); err != nil {
+			t.Errorf("MaxNestingDepth=%d: got %v, want nil", n, err)
+		}
+	}
+}
+
+func TestDecodeLimitsStopForwarding(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &countingDestination{}
+	opts := &lowlevel.DecodeOptions{MaxPathSegments: 10}
+	if err := lowlevel.Decode(c, src, opts); err == nil {
+		t.Fatal("Decode: got nil error, want non-nil")
+	}
+	if c.nSegments != 10 {
+		t.Errorf("segments forwarded: got %d, want 10", c.nSegments)
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoders := []struct {
+		name   string
+		decode func(dst lowlevel.Destination, opts *lowlevel.DecodeOptions) error
+	}{
+		{"Decode", func(dst lowlevel.Destination, opts *lowlevel.DecodeOptions) error {
+			return lowlevel.Decode(dst, src, opts)
+		}},
+		{"Decoder", func(dst lowlevel.Destination, opts *lowlevel.DecodeOptions) error {
+			return (&lowlevel.Decoder{}).Decode(dst, src, opts)
+		}},
+		{"StreamDecoder", func(dst lowlevel.Destination, opts *lowlevel.DecodeOptions) error {
+			return lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(dst, opts)
+		}},
+	}
+	for _, d := range decoders {
+		// A Context that is never done does not stop decoding.
+		c := &countingDestination{}
+		if err := d.decode(c, &lowlevel.DecodeOptions{Context: context.Background()}); err != nil {
+			t.Errorf("%s: background: %v", d.name, err)
+		} else if c.nSegments != 101 {
+			t.Errorf("%s: background: segments forwarded: got %d, want 101", d.name, c.nSegments)
+		}
+
+		// A Context that is already done stops decoding before the first
+		// opcode.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c = &countingDestination{}
+		if err := d.decode(c, &lowlevel.DecodeOptions{Context: ctx}); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: cancelled: got %v, want %v", d.name, err, context.Canceled)
+		} else if c.nSegments != 0 {
+			t.Errorf("%s: cancelled: segments forwarded: got %d, want 0", d.name, c.nSegments)
+		}
+
+		// A Context that is done part way through stops decoding at the
+		// next opcode.
+		ctx, cancel = context.WithCancel(context.Background())
+		cc := &cancellingDestination{cancel: cancel, after: 10}
+		if err := d.decode(cc, &lowlevel.DecodeOptions{Context: ctx}); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: cancelled part way: got %v, want %v", d.name, err, context.Canceled)
+		} else if (cc.nSegments < 10) || (cc.nSegments >= 101) {
+			t.Errorf("%s: cancelled part way: segments forwarded: got %d, want from 10 to 100", d.name, cc.nSegments)
+		}
+		cancel()
+	}
+}
+
+// cancellingDestination is a countingDestination that calls cancel when it
+// has been given after path segments.
+type cancellingDestination struct {
+	countingDestination
+	cancel func()
+	after  int
+}
+
+func (c *cancellingDestination) AbsLineTo(x, y float32) { c.count() }
+func (c *cancellingDestination) RelLineTo(x, y float32) { c.count() }
+
+func (c *cancellingDestination) count() {
+	if c.nSegments++; c.nSegments == c.after {
+		c.cancel()
+	}
+}
+
+// countingDestination is a Destination that counts the path segments, including
+// the implicit moveTo that starts each path, that it is given.
+type countingDestination struct {
+	lowlevel.NopDestination
+	nSegments int
+}
+
+func (c *countingDestination) StartPath(adj uint8, x, y float32)    { c.nSegments++ }
+func (c *countingDestination) ClosePathAbsMoveTo(x, y float32)      { c.nSegments++ }
+func (c *countingDestination) ClosePathRelMoveTo(x, y float32)      { c.nSegments++ }
+func (c *countingDestination) AbsHLineTo(x float32)                 { c.nSegments++ }
+func (c *countingDestination) RelHLineTo(x float32)                 { c.nSegments++ }
+func (c *countingDestination) AbsVLineTo(y float32)                 { c.nSegments++ }
+func (c *countingDestination) RelVLineTo(y float32)                 { c.nSegments++ }
+func (c *countingDestination) AbsLineTo(x, y float32)               { c.nSegments++ }
+func (c *countingDestination) RelLineTo(x, y float32)               { c.nSegments++ }
+func (c *countingDestination) AbsSmoothQuadTo(x, y float32)         { c.nSegments++ }
+func (c *countingDestination) RelSmoothQuadTo(x, y float32)         { c.nSegments++ }
+func (c *countingDestination) AbsQuadTo(x1, y1, x, y float32)       { c.nSegments++ }
+func (c *countingDestination) RelQuadTo(x1, y1, x, y float32)       { c.nSegments++ }
+func (c *countingDestination) AbsSmoothCubeTo(x2, y2, x, y float32) { c.nSegments++ }
+func (c *countingDestination) RelSmoothCubeTo(x2, y2, x, y float32) { c.nSegments++ }
+
+func (c *countingDestination) AbsCubeTo(x1, y1, x2, y2, x, y float32) { c.nSegments++ }
+func (c *countingDestination) RelCubeTo(x1, y1, x2, y2, x, y float32) { c.nSegments++ }
+
+func (c *countingDestination) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	c.nSegments++
+}
+
+func (c *countingDestination) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	c.nSegments++
+}
@@ -19,28 +19,93 @@
 package lowlevel
 
 import (
-	"errors"
+	"fmt"
 	"image/color"
 	"math"
 
 	"golang.org/x/image/math/f32"
 )
 
-var (
-	errInconsistentMetadataChunkLength = errors.New("iconvg: inconsistent metadata chunk length")
-	errInvalidColor                    = errors.New("iconvg: invalid color")
-	errInvalidMagicIdentifier          = errors.New("iconvg: invalid magic identifier")
-	errInvalidMetadataChunkLength      = errors.New("iconvg: invalid metadata chunk length")
-	errInvalidMetadataIdentifier       = errors.New("iconvg: invalid metadata identifier")
-	errInvalidNumber                   = errors.New("iconvg: invalid number")
-	errInvalidNumberOfMetadataChunks   = errors.New("iconvg: invalid number of metadata chunks")
-	errInvalidSuggestedPalette         = errors.New("iconvg: invalid suggested palette")
-	errInvalidViewBox                  = errors.New("iconvg: invalid view box")
-	errUnsupportedDrawingOpcode        = errors.New("iconvg: unsupported drawing opcode")
-	errUnsupportedMetadataIdentifier   = errors.New("iconvg: unsupported metadata identifier")
-	errUnsupportedStylingOpcode        = errors.New("iconvg: unsupported styling opcode")
+// FormatError reports that the input is not a valid IconVG graphic.
+//
+// Decoding malformed input never panics. It returns a *DecodeError, which
+// wraps a FormatError, unless reading the input fails or a DecodeOptions
+// resource limit is exceeded. Use errors.Is to check for a particular
+// FormatError, such as ErrInvalidNumber, or errors.As to check for any.
+type FormatError string
+
+func (e FormatError) Error() string { return "iconvg: " + string(e) }
+
+// These are the FormatErrors that decoding can return.
+const (
+	ErrInconsistentMetadataChunkLength = FormatError("inconsistent metadata chunk length")
+	ErrInvalidAnimation                = FormatError("invalid animation")
+	ErrInvalidChecksum                 = FormatError("invalid checksum")
+	ErrInvalidColor                    = FormatError("invalid color")
+	ErrInvalidDetailLevels             = FormatError("invalid detail levels")
+	ErrInvalidMagicIdentifier          = FormatError("invalid magic identifier")
+	ErrInvalidMetadataChunkLength      = FormatError("invalid metadata chunk length")
+	ErrInvalidMetadataIdentifier       = FormatError("invalid metadata identifier")
+	ErrInvalidMetadataIdentifierOrder  = FormatError("invalid metadata identifier order")
+	ErrInvalidNumber                   = FormatError("invalid number")
+	ErrInvalidNumberOfMetadataChunks   = FormatError("invalid number of metadata chunks")
+	ErrInvalidSuggestedPalette         = FormatError("invalid suggested palette")
+	ErrInvalidTitleAndDescription      = FormatError("invalid title and description")
+	ErrInvalidViewBox                  = FormatError("invalid view box")
+	ErrNonPremultipliedColor           = FormatError("non-premultiplied color")
+	ErrReservedGradientBits            = FormatError("reserved gradient bits")
+	ErrUnfinishedPath                  = FormatError("unfinished path")
+	ErrUnsupportedDrawingOpcode        = FormatError("unsupported drawing opcode")
+	// Deprecated: decoders keep unrecognized chunks in Metadata.Extra.
+	ErrUnsupportedMetadataIdentifier = FormatError("unsupported metadata identifier")
+	ErrUnsupportedStylingOpcode      = FormatError("unsupported styling opcode")
 )
 
+// DecodeError is a FormatError annotated with where, in the input, decoding
+// failed, so that tools can report, for example, "invalid number at byte
+// 0x1a3 in opcode 0xc7".
+type DecodeError struct {
+	// Offset is the byte offset, in the input, of the opcode or metadata
+	// chunk that could not be decoded. It is 0 for an invalid magic
+	// identifier and 4 for an invalid number of metadata chunks.
+	Offset int
+
+	// Opcode is the opcode that could not be decoded. It is meaningless if
+	// InMetadata is true.
+	Opcode byte
+
+	// InMetadata is whether decoding failed in the magic identifier or the
+	// metadata, instead of in the styling and drawing opcodes.
+	InMetadata bool
+
+	// Reason is what was invalid.
+	Reason FormatError
+}
+
+func (e *DecodeError) Error() string {
+	if e.InMetadata {
+		return fmt.Sprintf("iconvg: %s at byte 0x%x in metadata", string(e.Reason), e.Offset)
+	}
+	return fmt.Sprintf("iconvg: %s at byte 0x%x in opcode 0x%02x", string(e.Reason), e.Offset, e.Opcode)
+}
+
+// Unwrap returns e.Reason.
+func (e *DecodeError) Unwrap() error { return e.Reason }
+
+// annotate returns err wrapped in a *DecodeError if it is a FormatError, or
+// err itself otherwise, such as when reading the input failed.
+func annotate(err error, offset int, opcode byte, inMetadata bool) error {
+	if f, ok := err.(FormatError); ok {
+		return &DecodeError{
+			Offset:     offset,
+			Opcode:     opcode,
+			InMetadata: inMetadata,
+			Reason:     f,
+		}
+	}
+	return err
+}
+
 var gradientShapeNames = [2]string{
 	"linear",
 	"radial",
@@ -72,6 +137,33 @@ func (r *Rectangle) AspectRatio() (dx, dy float32) {
 	return r.Max[0] - r.Min[0], r.Max[1] - r.Min[1]
 }
 
+// FillRule is the rule for deciding whether a point is inside a path.
+//
+// IconVG byte code has no fill rule of its own: the specification fills every
+// path with the non-zero rule. The even-odd rule, used by some of the formats
+// that IconVG graphics are converted from, is only an option for encoders,
+// which rewrite even-odd paths as equivalent non-zero paths, and for
+// Destinations, which can be asked to fill decoded paths as even-odd. See
+// DecodeOptions.FillRule.
+type FillRule uint8
+
+const (
+	// FillRuleNonZero fills the points around which the path winds a non-zero
+	// number of times. It is the specification's rule.
+	FillRuleNonZero FillRule = 0
+	// FillRuleEvenOdd fills the points around which the path winds an odd
+	// number of times.
+	FillRuleEvenOdd FillRule = 1
+)
+
+// String returns "nonzero" or "evenodd", the SVG fill-rule names.
+func (r FillRule) String() string {
+	if r == FillRuleEvenOdd {
+		return "evenodd"
+	}
+	return "nonzero"
+}
+
 // Palette is an IconVG palette.
 type Palette [64]color.RGBA
 
@@ -83,12 +175,47 @@ type Metadata struct {
 	// palette to place within the IconVG graphic. When decoding, it is either
 	// the optional palette passed to Decode, or if no optional palette was
 	// given, the suggested palette within the IconVG graphic.
+	//
+	// DecodeMetadata reads, and RewritePalette replaces, an IconVG graphic's
+	// suggested palette without walking the rest of the graphic.
 	Palette Palette
+
+	// Title and Description are the graphic's alternative text, for screen
+	// readers and other assistive technology: a short title, such as
+	// "Delete", and an optional longer description. They are UTF-8 encoded.
+	// SVG converters map them to the title and desc elements.
+	Title       string
+	Description string
+
+	// Checksum is whether the graphic has a CRC-32C checksum, which decoders
+	// verify, so that a corrupted graphic is an error instead of a garbled
+	// rendering. When encoding, the Encoder computes the checksum.
+	Checksum bool
+
+	// DetailLevels are where the graphic's progressive detail levels end, as
+	// byte offsets relative to its first opcode. A progressive graphic draws
+	// coarse shapes first, so that decoding only its first few levels, with
+	// DecodeOptions.MaxLOD, gives a cheap thumbnail or preview. These levels
+	// are unrelated to SetLOD's height-based level of detail.
+	//
+	// When encoding, only the number of levels matters: the Encoder records
+	// each level's end when EndDetailLevel is called.
+	DetailLevels []uint32
+
+	// Extra holds the metadata chunks that this package does not otherwise
+	// decode, keyed by MID, as their MID-specific data. Decoding preserves
+	// them and encoding writes them back, so that tools can attach their own
+	// metadata, at or above FirstVendorMID, without breaking other readers.
+	// It is nil if there are no such chunks. See also RegisterMetadataCodec.
+	Extra map[MID][]byte
 }
 
 const (
-	midViewBox          = 0
-	midSuggestedPalette = 1
+	midViewBox             = 0
+	midSuggestedPalette    = 1
+	midTitleAndDescription = 2
+	midChecksum            = 3
+	midDetailLevels        = 4
 )
 
 // DefaultViewBox is the default ViewBox. Its values should not be modified.
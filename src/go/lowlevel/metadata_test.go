@@ -0,0 +1,248 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestTitleAndDescription(t *testing.T) {
+	testCases := []struct {
+		title, description string
+	}{
+		{"", ""},
+		{"Info", ""},
+		{"", "Détails"},
+		{"Info", "Détails"},
+		// A 100 byte string's length is a 2 byte natural number.
+		{strings.Repeat("t", 100), strings.Repeat("d", 100)},
+	}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox:     lowlevel.DefaultViewBox,
+			Palette:     lowlevel.DefaultPalette,
+			Title:       tc.title,
+			Description: tc.description,
+		})
+		src, err := e.Bytes()
+		if err != nil {
+			t.Errorf("%q, %q: Bytes: %v", tc.title, tc.description, err)
+			continue
+		}
+		// Empty text is omitted, leaving no metadata chunks.
+		if (tc.title == "") && (tc.description == "") && (string(src) != "\x89IVG\x00") {
+			t.Errorf("%q, %q: got % x, want no metadata chunks", tc.title, tc.description, src)
+		}
+		m, err := lowlevel.DecodeMetadata(src)
+		if err != nil {
+			t.Errorf("%q, %q: DecodeMetadata: %v", tc.title, tc.description, err)
+			continue
+		}
+		if (m.Title != tc.title) || (m.Description != tc.description) {
+			t.Errorf("%q, %q: got %q, %q", tc.title, tc.description, m.Title, m.Description)
+		}
+	}
+}
+
+func TestTitleAndDescriptionErrors(t *testing.T) {
+	for _, m := range []lowlevel.Metadata{{Title: "\xff"}, {Description: "ok\xc3"}} {
+		m.ViewBox, m.Palette = lowlevel.DefaultViewBox, lowlevel.DefaultPalette
+		e := &lowlevel.Encoder{}
+		e.Reset(m)
+		if _, err := e.Bytes(); err == nil {
+			t.Errorf("%q, %q: Bytes: got nil error, want non-nil", m.Title, m.Description)
+		}
+	}
+
+	testCases := []struct {
+		desc string
+		src  string
+	}{
+		{"invalid UTF-8", "\x89IVG\x02\x08\x04\x02\xff\x00"},
+		{"title too long", "\x89IVG\x02\x06\x04\x08a"},
+		{"missing description", "\x89IVG\x02\x06\x04\x02a"},
+	}
+	for _, tc := range testCases {
+		_, err := lowlevel.DecodeMetadata([]byte(tc.src))
+		if !errors.Is(err, lowlevel.ErrInvalidTitleAndDescription) {
+			t.Errorf("%s: got %v, want %v", tc.desc, err, lowlevel.ErrInvalidTitleAndDescription)
+		}
+	}
+}
+
+// metadataRecorder is a Destination that records the metadata passed to
+// Reset.
+type metadataRecorder struct {
+	lowlevel.NopDestination
+	m lowlevel.Metadata
+}
+
+func (r *metadataRecorder) Reset(m lowlevel.Metadata) { r.m = m }
+
+func TestExtraMetadata(t *testing.T) {
+	want := lowlevel.Metadata{
+		ViewBox: lowlevel.DefaultViewBox,
+		Palette: lowlevel.DefaultPalette,
+		Title:   "Info",
+		Extra: map[lowlevel.MID][]byte{
+			2000: {},
+			5:    []byte("x"),
+			1024: []byte("abc"),
+		},
+	}
+	e := &lowlevel.Encoder{}
+	e.Reset(want)
+	src, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The chunks are in increasing MID order: 2 (title and description), 5,
+	// 1024 and then 2000.
+	const wantPrefix = "\x89IVG\x08" +
+		"\x0e\x04\x08Info\x00" +
+		"\x04\x0ax" +
+		"\x0a\x01\x10abc" +
+		"\x04\x41\x1f"
+	if !strings.HasPrefix(string(src), wantPrefix) {
+		t.Errorf("got % x, want prefix % x", src, wantPrefix)
+	}
+
+	got, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		t.Fatalf("DecodeMetadata: %v", err)
+	}
+	if !got.Equal(&want) {
+		t.Errorf("DecodeMetadata: got %+v, want %+v", got.Extra, want.Extra)
+	}
+	r := &metadataRecorder{}
+	if err := lowlevel.NewStreamDecoder(strings.NewReader(string(src))).Decode(r, nil); err != nil {
+		t.Fatalf("StreamDecoder.Decode: %v", err)
+	}
+	if !r.m.Equal(&want) {
+		t.Errorf("StreamDecoder.Decode: got %+v, want %+v", r.m.Extra, want.Extra)
+	}
+
+	// Extra chunks must not use the MIDs that Reset encodes itself.
+	for _, mid := range []lowlevel.MID{0, 1, 2} {
+		m := want
+		m.Extra = map[lowlevel.MID][]byte{mid: {}}
+		e.Reset(m)
+		if _, err := e.Bytes(); err == nil {
+			t.Errorf("MID %d: Bytes: got nil error, want non-nil", mid)
+		}
+	}
+}
+
+// stringCodec is a MetadataCodec for a string value.
+type stringCodec struct{}
+
+func (stringCodec) DecodeChunk(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func (stringCodec) EncodeChunk(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("not a string")
+	}
+	return []byte(s), nil
+}
+
+func TestMetadataCodec(t *testing.T) {
+	const mid, unregistered = lowlevel.FirstVendorMID + 1, lowlevel.FirstVendorMID + 2
+	lowlevel.RegisterMetadataCodec(mid, "test string", stringCodec{})
+
+	m := &lowlevel.Metadata{}
+	if _, ok, err := m.ExtraValue(mid); ok || (err != nil) {
+		t.Errorf("ExtraValue before SetExtraValue: got %t, %v, want false, nil", ok, err)
+	}
+	if err := m.SetExtraValue(mid, "provenance"); err != nil {
+		t.Fatalf("SetExtraValue: %v", err)
+	}
+	if got := string(m.Extra[mid]); got != "provenance" {
+		t.Errorf("Extra: got %q, want %q", got, "provenance")
+	}
+	if v, ok, err := m.ExtraValue(mid); !ok || (err != nil) || (v != "provenance") {
+		t.Errorf("ExtraValue: got %v, %t, %v, want %q, true, nil", v, ok, err, "provenance")
+	}
+	if err := m.SetExtraValue(mid, 123); err == nil {
+		t.Errorf("SetExtraValue(123): got nil error, want non-nil")
+	}
+	if err := m.SetExtraValue(unregistered, "x"); err == nil {
+		t.Errorf("SetExtraValue(unregistered): got nil error, want non-nil")
+	}
+	m.Extra[unregistered] = []byte("x")
+	if _, ok, err := m.ExtraValue(unregistered); !ok || (err == nil) {
+		t.Errorf("ExtraValue(unregistered): got %t, %v, want true, non-nil", ok, err)
+	}
+
+	// Disassemblies describe the chunk by its registered name.
+	full := *m
+	full.ViewBox, full.Palette = lowlevel.DefaultViewBox, lowlevel.DefaultPalette
+	e := &lowlevel.Encoder{}
+	e.Reset(full)
+	src, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &strings.Builder{}
+	if err := lowlevel.Disassemble(buf, src); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"(test string)", "(extra)", `"prov"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Disassemble: got\n%s\nwant it to contain %q", buf, want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterMetadataCodec(%d): got no panic, want one", lowlevel.FirstVendorMID-1)
+		}
+	}()
+	lowlevel.RegisterMetadataCodec(lowlevel.FirstVendorMID-1, "not vendor", stringCodec{})
+}
+
+func TestMetadataEqual(t *testing.T) {
+	base := lowlevel.Metadata{ViewBox: lowlevel.DefaultViewBox, Palette: lowlevel.DefaultPalette}
+	testCases := []struct {
+		desc   string
+		modify func(m *lowlevel.Metadata)
+		want   bool
+	}{
+		{"same", func(m *lowlevel.Metadata) {}, true},
+		{"empty Extra", func(m *lowlevel.Metadata) { m.Extra = map[lowlevel.MID][]byte{} }, true},
+		{"viewBox", func(m *lowlevel.Metadata) { m.ViewBox.Max[0] = 64 }, false},
+		{"palette", func(m *lowlevel.Metadata) { m.Palette[3].R = 0xff }, false},
+		{"title", func(m *lowlevel.Metadata) { m.Title = "x" }, false},
+		{"description", func(m *lowlevel.Metadata) { m.Description = "x" }, false},
+		{"extra", func(m *lowlevel.Metadata) { m.Extra = map[lowlevel.MID][]byte{1024: nil} }, false},
+	}
+	for _, tc := range testCases {
+		m := base
+		tc.modify(&m)
+		if got := base.Equal(&m); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.desc, got, tc.want)
+		}
+		if got := m.Equal(&base); got != tc.want {
+			t.Errorf("%s (reversed): got %t, want %t", tc.desc, got, tc.want)
+		}
+	}
+}
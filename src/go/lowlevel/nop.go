@@ -0,0 +1,56 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+// NopDestination is a Destination whose methods do nothing. Embedding it in a
+// struct lets that struct implement Destination by overriding only the
+// methods that it needs.
+type NopDestination struct{}
+
+var _ Destination = NopDestination{}
+
+func (NopDestination) Reset(m Metadata) {}
+
+func (NopDestination) SetCSel(cSel uint8)                      {}
+func (NopDestination) SetNSel(nSel uint8)                      {}
+func (NopDestination) SetCReg(adj uint8, incr bool, c Color)   {}
+func (NopDestination) SetNReg(adj uint8, incr bool, f float32) {}
+func (NopDestination) SetLOD(lod0, lod1 float32)               {}
+
+func (NopDestination) StartPath(adj uint8, x, y float32) {}
+func (NopDestination) ClosePathEndPath()                 {}
+func (NopDestination) ClosePathAbsMoveTo(x, y float32)   {}
+func (NopDestination) ClosePathRelMoveTo(x, y float32)   {}
+
+func (NopDestination) AbsHLineTo(x float32)                   {}
+func (NopDestination) RelHLineTo(x float32)                   {}
+func (NopDestination) AbsVLineTo(y float32)                   {}
+func (NopDestination) RelVLineTo(y float32)                   {}
+func (NopDestination) AbsLineTo(x, y float32)                 {}
+func (NopDestination) RelLineTo(x, y float32)                 {}
+func (NopDestination) AbsSmoothQuadTo(x, y float32)           {}
+func (NopDestination) RelSmoothQuadTo(x, y float32)           {}
+func (NopDestination) AbsQuadTo(x1, y1, x, y float32)         {}
+func (NopDestination) RelQuadTo(x1, y1, x, y float32)         {}
+func (NopDestination) AbsSmoothCubeTo(x2, y2, x, y float32)   {}
+func (NopDestination) RelSmoothCubeTo(x2, y2, x, y float32)   {}
+func (NopDestination) AbsCubeTo(x1, y1, x2, y2, x, y float32) {}
+func (NopDestination) RelCubeTo(x1, y1, x2, y2, x, y float32) {}
+
+func (NopDestination) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+}
+
+func (NopDestination) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+}
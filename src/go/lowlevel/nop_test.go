@@ -0,0 +1,64 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// pathCounter counts the paths in a graphic, implementing only the
+// Destination methods that it needs.
+type pathCounter struct {
+	lowlevel.NopDestination
+	resets int
+	paths  int
+}
+
+func (c *pathCounter) Reset(m lowlevel.Metadata)         { c.resets++ }
+func (c *pathCounter) StartPath(adj uint8, x, y float32) { c.paths++ }
+
+func TestNopDestination(t *testing.T) {
+	testCases := []struct {
+		filename  string
+		wantPaths int
+	}{
+		{"action-info.lores.ivg", 1},
+		{"blank.ivg", 0},
+		{"lod-polygon.ivg", 4},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lowlevel.Decode(lowlevel.NopDestination{}, src, nil); err != nil {
+			t.Errorf("%s: NopDestination: %v", tc.filename, err)
+		}
+		c := &pathCounter{}
+		if err := lowlevel.Decode(c, src, nil); err != nil {
+			t.Errorf("%s: pathCounter: %v", tc.filename, err)
+			continue
+		}
+		if c.resets != 1 {
+			t.Errorf("%s: resets: got %d, want %d", tc.filename, c.resets, 1)
+		}
+		if c.paths != tc.wantPaths {
+			t.Errorf("%s: paths: got %d, want %d", tc.filename, c.paths, tc.wantPaths)
+		}
+	}
+}
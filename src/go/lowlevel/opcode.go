@@ -0,0 +1,99 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"fmt"
+)
+
+// Opcode is an instruction's opcode. The same byte means different things in
+// the styling and drawing modes, so an Opcode records both.
+type Opcode struct {
+	Byte    byte
+	Drawing bool
+}
+
+// drawingOpcodeNames are the names of the drawing opcodes below 0xe0, indexed
+// by the opcode's high nibble. Each opcode's low bits, and the 0x10 bit for
+// lineTos, are a repetition count.
+var drawingOpcodeNames = [14]string{
+	"L", "L", "l", "l",
+	"T", "t", "Q", "q",
+	"S", "s", "C", "c",
+	"A", "a",
+}
+
+// String returns the opcode's name, as written in the ivgasm assembly
+// language, such as "creg.4" or "L".
+func (o Opcode) String() string {
+	b := o.Byte
+	if o.Drawing {
+		switch {
+		case b < 0xe0:
+			return drawingOpcodeNames[b>>4]
+		case b == 0xe1:
+			return "z"
+		case b == 0xe2:
+			return "zM"
+		case b == 0xe3:
+			return "zm"
+		case b == 0xe6:
+			return "H"
+		case b == 0xe7:
+			return "h"
+		case b == 0xe8:
+			return "V"
+		case b == 0xe9:
+			return "v"
+		}
+	} else {
+		switch {
+		case b < 0x40:
+			return "csel"
+		case b < 0x80:
+			return "nsel"
+		case b < 0x88:
+			return "creg.1"
+		case b < 0x90:
+			return "creg.2"
+		case b < 0x98:
+			return "creg.3"
+		case b < 0xa0:
+			return "creg.4"
+		case b < 0xa8:
+			return "creg.blend"
+		case b < 0xb0:
+			return "nreg.real"
+		case b < 0xb8:
+			return "nreg.coord"
+		case b < 0xc0:
+			return "nreg.zero-to-one"
+		case b < 0xc7:
+			return "path"
+		case b == 0xc7:
+			return "lod"
+		}
+	}
+	return fmt.Sprintf("unsupported(0x%02x)", b)
+}
+
+// nextDrawing returns whether the instruction after one with opcode o is in the
+// drawing mode: a path starts in the styling mode and ends in the drawing mode.
+func (o Opcode) nextDrawing() bool {
+	if o.Drawing {
+		return o.Byte != 0xe1
+	}
+	return (0xc0 <= o.Byte) && (o.Byte < 0xc7)
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestOpcodeString(t *testing.T) {
+	testCases := []struct {
+		op   lowlevel.Opcode
+		want string
+	}{
+		{lowlevel.Opcode{Byte: 0x00}, "csel"},
+		{lowlevel.Opcode{Byte: 0x7f}, "nsel"},
+		{lowlevel.Opcode{Byte: 0x80}, "creg.1"},
+		{lowlevel.Opcode{Byte: 0x9f}, "creg.4"},
+		{lowlevel.Opcode{Byte: 0xa0}, "creg.blend"},
+		{lowlevel.Opcode{Byte: 0xa8}, "nreg.real"},
+		{lowlevel.Opcode{Byte: 0xb0}, "nreg.coord"},
+		{lowlevel.Opcode{Byte: 0xb8}, "nreg.zero-to-one"},
+		{lowlevel.Opcode{Byte: 0xc0}, "path"},
+		{lowlevel.Opcode{Byte: 0xc7}, "lod"},
+		{lowlevel.Opcode{Byte: 0xc8}, "unsupported(0xc8)"},
+		{lowlevel.Opcode{Byte: 0x00, Drawing: true}, "L"},
+		{lowlevel.Opcode{Byte: 0x3f, Drawing: true}, "l"},
+		{lowlevel.Opcode{Byte: 0x40, Drawing: true}, "T"},
+		{lowlevel.Opcode{Byte: 0x7f, Drawing: true}, "q"},
+		{lowlevel.Opcode{Byte: 0xb0, Drawing: true}, "c"},
+		{lowlevel.Opcode{Byte: 0xc0, Drawing: true}, "A"},
+		{lowlevel.Opcode{Byte: 0xdf, Drawing: true}, "a"},
+		{lowlevel.Opcode{Byte: 0xe0, Drawing: true}, "unsupported(0xe0)"},
+		{lowlevel.Opcode{Byte: 0xe1, Drawing: true}, "z"},
+		{lowlevel.Opcode{Byte: 0xe2, Drawing: true}, "zM"},
+		{lowlevel.Opcode{Byte: 0xe3, Drawing: true}, "zm"},
+		{lowlevel.Opcode{Byte: 0xe6, Drawing: true}, "H"},
+		{lowlevel.Opcode{Byte: 0xe9, Drawing: true}, "v"},
+		{lowlevel.Opcode{Byte: 0xff, Drawing: true}, "unsupported(0xff)"},
+	}
+	for _, tc := range testCases {
+		if got := tc.op.String(); got != tc.want {
+			t.Errorf("%#02x (drawing: %t): got %q, want %q", tc.op.Byte, tc.op.Drawing, got, tc.want)
+		}
+	}
+}
+
+// traceRecorder returns DecodeOptions whose Trace hook records each
+// instruction, as its offset, opcode and operand bytes.
+func traceRecorder(trace *[]string) *lowlevel.DecodeOptions {
+	return &lowlevel.DecodeOptions{
+		Trace: func(offset int, op lowlevel.Opcode, args []byte) {
+			*trace = append(*trace, fmt.Sprintf("%d %v % x", offset, op, args))
+		},
+	}
+}
+
+func TestDecodeTrace(t *testing.T) {
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 0\n" +
+		"creg.3 [csel] #ff0000\npath [csel] 0 0\nL 10 0\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err := lowlevel.Decode(nil, src, traceRecorder(&got)); err != nil {
+		t.Fatal(err)
+	}
+	// The magic identifier and the metadata take 5 bytes. Each coordinate
+	// takes 1 byte.
+	want := []string{
+		"5 creg.3 ff 00 00",
+		"9 path 80 80",
+		"12 L 94 80",
+		"15 z ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// An instruction that fails to decode is not traced.
+	got = nil
+	if err := lowlevel.Decode(nil, src[:14], traceRecorder(&got)); err == nil {
+		t.Errorf("truncated: got nil error, want non-nil")
+	}
+	if !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("truncated: got  %q\nwant %q", got, want[:2])
+	}
+}
+
+func TestDecodeTraceDecoders(t *testing.T) {
+	for _, filename := range []string{
+		"arcs.ivg",
+		"cowbell.ivg",
+		"gradient.ivg",
+		"lod-polygon.ivg",
+	} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		if err := lowlevel.Decode(nil, src, traceRecorder(&want)); err != nil {
+			t.Fatalf("%s: Decode: %v", filename, err)
+		}
+		if len(want) == 0 {
+			t.Fatalf("%s: Decode: got no trace", filename)
+		}
+
+		var fixed []string
+		if err := lowlevel.DecodeFixed(&fixedRecorder{scale: 1 << 6}, src, traceRecorder(&fixed)); err != nil {
+			t.Errorf("%s: DecodeFixed: %v", filename, err)
+		} else if !reflect.DeepEqual(fixed, want) {
+			t.Errorf("%s: DecodeFixed: traces differ", filename)
+		}
+
+		var stream []string
+		if err := lowlevel.NewStreamDecoder(bytes.NewReader(src)).Decode(nil, traceRecorder(&stream)); err != nil {
+			t.Errorf("%s: StreamDecoder: %v", filename, err)
+		} else if !reflect.DeepEqual(stream, want) {
+			t.Errorf("%s: StreamDecoder: traces differ", filename)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+)
+
+// NewPalette returns a Palette whose first entries are colors, converted to
+// alpha-premultiplied color.RGBA values (so that, for example, a color.NRGBA
+// is premultiplied), and whose remaining entries are those of the
+// DefaultPalette. Colors beyond the 64th are ignored.
+func NewPalette(colors ...color.Color) Palette {
+	p := DefaultPalette
+	for i, c := range colors {
+		if i >= len(p) {
+			break
+		}
+		p[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	return p
+}
+
+// Validate returns an error, wrapping ErrNonPremultipliedColor, if any of p's
+// entries is not a valid alpha-premultiplied color: one whose red, green and
+// blue values are each no greater than its alpha value. Decoding replaces such
+// suggested palette entries by opaque black, and rendering with such a custom
+// palette gives unspecified results.
+func (p *Palette) Validate() error {
+	for i, c := range p {
+		if !validAlphaPremulColor(c) {
+			return fmt.Errorf("%w (palette entry %d)", ErrNonPremultipliedColor, i)
+		}
+	}
+	return nil
+}
+
+// BlendOver returns a copy of p whose entries are each composited, using the
+// Porter-Duff "over" operator, over bg. If bg is opaque, every resulting entry
+// is opaque, which suits rendering to a destination that has no alpha channel.
+func (p *Palette) BlendOver(bg color.Color) Palette {
+	bgR, bgG, bgB, bgA := bg.RGBA()
+	// Compute in 16 bits per channel, as bg's values are, and round to 8 bits
+	// at the end.
+	over := func(x uint8, inv uint32, y uint32) uint8 {
+		v := uint32(x)*0x101 + (y*inv+0x7f)/0xff
+		return uint8((v*0xff + 0x7fff) / 0xffff)
+	}
+	q := *p
+	for i, c := range q {
+		inv := 0xff - uint32(c.A)
+		q[i] = color.RGBA{over(c.R, inv, bgR), over(c.G, inv, bgG), over(c.B, inv, bgB), over(c.A, inv, bgA)}
+	}
+	return q
+}
+
+// PaletteIndices returns the custom palette indices that the IconVG graphic
+// src's paths use, as a bit set: bit i is set if a path is filled with a
+// color that depends on customPalette[i]. The CREG color registers start out
+// holding the custom palette, so that is the case for a path filled with
+// CREG[i] before anything else is set there, as well as for one filled with
+// a color that refers to the palette entry, directly, via another CREG
+// register, as one of a blended color's two colors or as a gradient stop.
+//
+// Themed rendering (such as a dark mode or brand colors) only needs to supply
+// those palette entries.
+func PaletteIndices(src []byte) (uint64, error) {
+	p := &paletteIndexer{}
+	if err := Decode(p, src, nil); err != nil {
+		return 0, err
+	}
+	return p.indices, nil
+}
+
+// ColorCounts returns every distinct fill color of the IconVG graphic src,
+// resolved against its suggested palette, and how many paths use each color.
+// A gradient's stop colors each count as used by every path that the gradient
+// fills. Paths are counted regardless of their level of detail.
+func ColorCounts(src []byte) (map[color.RGBA]int, error) {
+	c := &colorCounter{counts: map[color.RGBA]int{}}
+	if err := Decode(c, src, nil); err != nil {
+		return nil, err
+	}
+	return c.counts, nil
+}
+
+// ExtractPalette returns the distinct fill colors of the IconVG graphic src,
+// as per ColorCounts, from most to least used. Equally used colors are in
+// order of first use.
+func ExtractPalette(src []byte) ([]color.RGBA, error) {
+	c := &colorCounter{counts: map[color.RGBA]int{}}
+	if err := Decode(c, src, nil); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(c.order, func(i, j int) bool {
+		return c.counts[c.order[i]] > c.counts[c.order[j]]
+	})
+	return c.order, nil
+}
+
+// LoadPartialPalette returns the palette to pass, via DecodeOptions.Palette,
+// when decoding src. It is src's suggested palette, with the entries that
+// src's paths use (see PaletteIndices) replaced by the corresponding entries
+// of overrides, if present. Entries of overrides that src does not use are
+// ignored.
+func LoadPartialPalette(src []byte, overrides map[uint8]color.RGBA) (Palette, error) {
+	m, err := DecodeMetadata(src)
+	if err != nil {
+		return Palette{}, err
+	}
+	indices, err := PaletteIndices(src)
+	if err != nil {
+		return Palette{}, err
+	}
+	for i, c := range overrides {
+		if (i < 64) && (indices&(1<<i) != 0) {
+			m.Palette[i] = c
+		}
+	}
+	return m.Palette, nil
+}
+
+// RewritePalette returns a copy of the IconVG graphic src whose suggested
+// palette is pal. Only the metadata is decoded and re-encoded. The styling and
+// drawing opcodes that follow it are copied verbatim, without being walked or
+// validated, so that the cost depends on the size of the metadata, not the
+// size of the graphic, apart from verifying and recomputing the graphic's
+// checksum, if it has one.
+//
+// Like Encoder.Reset, it omits the suggested palette if pal equals the
+// DefaultPalette.
+func RewritePalette(src []byte, pal Palette) ([]byte, error) {
+	return rewriteMetadata(src, func(m *Metadata) { m.Palette = pal })
+}
+
+// rewriteMetadata returns a copy of the IconVG graphic src whose metadata is
+// modified by f, copying the opcodes verbatim.
+func rewriteMetadata(src []byte, f func(m *Metadata)) ([]byte, error) {
+	m, err := DecodeMetadata(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the magic identifier and the metadata chunks, which DecodeMetadata
+	// has already validated.
+	rest := buffer(src[len(magic):])
+	nMetadataChunks, n := rest.decodeNatural()
+	rest = rest[n:]
+	for ; nMetadataChunks > 0; nMetadataChunks-- {
+		length, n := rest.decodeNatural()
+		rest = rest[n+int(length):]
+	}
+
+	f(&m)
+	e := Encoder{}
+	e.Reset(m)
+	e.buf = append(e.buf, rest...)
+	// The detail levels' ends are relative to the first opcode, so that they
+	// carry over unchanged.
+	e.levelEnds = m.DetailLevels
+	e.seal()
+	return e.buf, nil
+}
+
+// paletteIndexer is a Destination that records which custom palette indices
+// the paths use. For each CREG register, uses is the bit set of the palette
+// indices that its value depends on, which, initially, for CREG[i], is i. The
+// registers' values are tracked, like a rasterizer does, to find gradients.
+type paletteIndexer struct {
+	NopDestination
+
+	metadata Metadata
+	cSel     uint8
+	cReg     [64]color.RGBA
+	uses     [64]uint64
+
+	indices uint64
+}
+
+func (p *paletteIndexer) Reset(m Metadata) {
+	p.metadata = m
+	p.cSel = 0
+	p.cReg = m.Palette
+	for i := range p.uses {
+		p.uses[i] = 1 << uint(i)
+	}
+}
+
+func (p *paletteIndexer) SetCSel(cSel uint8) { p.cSel = cSel & 0x3f }
+
+func (p *paletteIndexer) SetCReg(adj uint8, incr bool, c Color) {
+	i := (p.cSel - adj) & 0x3f
+	// Both of these read the registers' old values, which c may refer to.
+	uses, rgba := p.colorUses(c), c.Resolve(&p.metadata.Palette, &p.cReg)
+	p.uses[i], p.cReg[i] = uses, rgba
+	if incr {
+		p.cSel = (p.cSel + 1) & 0x3f
+	}
+}
+
+func (p *paletteIndexer) StartPath(adj uint8, x, y float32) {
+	i := (p.cSel - adj) & 0x3f
+	p.indices |= p.uses[i]
+	if rgba := p.cReg[i]; (rgba.A == 0x00) && (rgba.B&0x80 != 0) {
+		// A gradient's CREG value holds its number of stops and the CREG
+		// register of its first stop's color.
+		nStops, cBase := rgba.R&0x3f, rgba.G&0x3f
+		for j := uint8(0); j < nStops; j++ {
+			p.indices |= p.uses[(cBase+j)&0x3f]
+		}
+	}
+}
+
+// colorUses returns the bit set of the palette indices that c depends on.
+func (p *paletteIndexer) colorUses(c Color) uint64 {
+	switch c.typ {
+	case ColorTypePaletteIndex:
+		return 1 << (c.paletteIndex() & 0x3f)
+	case ColorTypeCReg:
+		return p.uses[c.cReg()&0x3f]
+	case ColorTypeBlend:
+		_, c0, c1 := c.blend()
+		// A 1 byte color is never itself a blend, so this does not recurse
+		// further.
+		return p.colorUses(decodeColor1(c0)) | p.colorUses(decodeColor1(c1))
+	}
+	return 0
+}
+
+// colorCounter is a Destination that counts the resolved fill colors of each
+// path, tracking the CREG registers and CSEL like a rasterizer does.
+type colorCounter struct {
+	NopDestination
+
+	metadata Metadata
+	cSel     uint8
+	cReg     [64]color.RGBA
+
+	counts map[color.RGBA]int
+	order  []color.RGBA
+}
+
+func (c *colorCounter) Reset(m Metadata) {
+	c.metadata = m
+	c.cSel = 0
+	c.cReg = m.Palette
+}
+
+func (c *colorCounter) SetCSel(cSel uint8) { c.cSel = cSel & 0x3f }
+
+func (c *colorCounter) SetCReg(adj uint8, incr bool, x Color) {
+	c.cReg[(c.cSel-adj)&0x3f] = x.Resolve(&c.metadata.Palette, &c.cReg)
+	if incr {
+		c.cSel = (c.cSel + 1) & 0x3f
+	}
+}
+
+func (c *colorCounter) StartPath(adj uint8, x, y float32) {
+	rgba := c.cReg[(c.cSel-adj)&0x3f]
+	if validAlphaPremulColor(rgba) {
+		c.count(rgba)
+	} else if (rgba.A == 0x00) && (rgba.B&0x80 != 0) {
+		// A gradient's CREG value holds its number of stops and the CREG
+		// register of its first stop's color.
+		nStops, cBase := rgba.R&0x3f, rgba.G&0x3f
+		for i := uint8(0); i < nStops; i++ {
+			c.count(c.cReg[(cBase+i)&0x3f])
+		}
+	}
+}
+
+func (c *colorCounter) count(rgba color.RGBA) {
+	if c.counts[rgba] == 0 {
+		c.order = append(c.order, rgba)
+	}
+	c.counts[rgba]++
+}
@@ -0,0 +1,417 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestPaletteIndices(t *testing.T) {
+	testCases := []struct {
+		filename string
+		want     uint64
+	}{
+		// action-info fills its circle with CREG[0], which starts out
+		// holding customPalette[0].
+		{"action-info.hires.ivg", 0x1},
+		{"action-info.lores.ivg", 0x1},
+		{"arcs.ivg", 0x0},
+		{"blank.ivg", 0x0},
+		{"cowbell.ivg", 0x0},
+		{"favicon.ivg", 0x1},
+		{"gradient.ivg", 0x0},
+		{"lod-polygon.ivg", 0x1},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := lowlevel.PaletteIndices(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.filename, got, tc.want)
+		}
+	}
+}
+
+func TestPaletteIndicesCReg(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	testCases := []struct {
+		desc  string
+		style func(e *lowlevel.Encoder)
+		want  uint64
+	}{{
+		desc:  "initial CREG[0]",
+		style: func(e *lowlevel.Encoder) {},
+		want:  1 << 0,
+	}, {
+		desc:  "initial CREG[5]",
+		style: func(e *lowlevel.Encoder) { e.SetCSel(5) },
+		want:  1 << 5,
+	}, {
+		desc:  "overwritten CREG[0]",
+		style: func(e *lowlevel.Encoder) { e.SetCReg(0, false, red) },
+		want:  0,
+	}, {
+		desc:  "palette index",
+		style: func(e *lowlevel.Encoder) { e.SetCReg(0, false, lowlevel.PaletteIndexColor(7)) },
+		want:  1 << 7,
+	}, {
+		desc:  "initial CREG[9], via CREG[0]",
+		style: func(e *lowlevel.Encoder) { e.SetCReg(0, false, lowlevel.CRegColor(9)) },
+		want:  1 << 9,
+	}, {
+		desc: "overwritten CREG[9], via CREG[0]",
+		style: func(e *lowlevel.Encoder) {
+			e.SetCSel(9)
+			e.SetCReg(0, false, red)
+			e.SetCSel(0)
+			e.SetCReg(0, false, lowlevel.CRegColor(9))
+		},
+		want: 0,
+	}, {
+		desc:  "blend of palette index 2 and initial CREG[4]",
+		style: func(e *lowlevel.Encoder) { e.SetCReg(0, false, lowlevel.BlendColor(0x40, 0x82, 0xc4)) },
+		want:  1<<2 | 1<<4,
+	}, {
+		desc: "palette index set in an unused register",
+		style: func(e *lowlevel.Encoder) {
+			e.SetCReg(0, false, red)
+			e.SetCReg(1, false, lowlevel.PaletteIndexColor(3))
+		},
+		want: 0,
+	}}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		})
+		tc.style(e)
+		e.StartPath(0, 0, 0)
+		e.AbsLineTo(10, 0)
+		e.AbsLineTo(10, 10)
+		e.ClosePathEndPath()
+		src, err := e.Bytes()
+		if err != nil {
+			t.Fatalf("%s: Bytes: %v", tc.desc, err)
+		}
+		got, err := lowlevel.PaletteIndices(src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestLoadPartialPalette(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.hires.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pink := color.RGBA{0xfe, 0x76, 0xea, 0xff}
+	green := color.RGBA{0x00, 0x80, 0x00, 0xff}
+	got, err := lowlevel.LoadPartialPalette(src, map[uint8]color.RGBA{0: pink, 1: green})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := m.Palette
+	// Only entry 0 is used, so the override for entry 1 is ignored.
+	want[0] = pink
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRewritePalette(t *testing.T) {
+	red := lowlevel.DefaultPalette
+	red[0] = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	testCases := []struct {
+		filename string
+		pal      lowlevel.Palette
+	}{
+		{"action-info.lores.ivg", red},
+		{"action-info.lores.ivg", lowlevel.DefaultPalette},
+		{"cowbell.ivg", red},
+		{"favicon.ivg", red},
+		{"gradient.ivg", red},
+		{"video-005.primitive.ivg", lowlevel.DefaultPalette},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := lowlevel.DecodeMetadata(src)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filename, err)
+		}
+		want.Palette = tc.pal
+
+		dst, err := lowlevel.RewritePalette(src, tc.pal)
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		got, err := lowlevel.DecodeMetadata(dst)
+		if err != nil {
+			t.Errorf("%s: DecodeMetadata: %v", tc.filename, err)
+			continue
+		}
+		if !got.Equal(&want) {
+			t.Errorf("%s: got %v, want %v", tc.filename, got, want)
+		}
+		// The opcodes are copied verbatim.
+		if got, want := dst[firstOpcode(t, dst):], src[firstOpcode(t, src):]; !bytes.Equal(got, want) {
+			t.Errorf("%s: opcodes: got % x, want % x", tc.filename, got, want)
+		}
+	}
+}
+
+// firstOpcode returns the byte offset of the first opcode in src, after its
+// metadata, or len(src) if it has no opcodes.
+func firstOpcode(t *testing.T, src []byte) int {
+	t.Helper()
+	offset := -1
+	opts := &lowlevel.DecodeOptions{
+		OnInstruction: func(o int) {
+			if offset < 0 {
+				offset = o
+			}
+		},
+	}
+	if err := lowlevel.Decode(nil, src, opts); err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 {
+		return len(src)
+	}
+	return offset
+}
+
+func TestExtractPalette(t *testing.T) {
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	green := color.RGBA{0x00, 0xff, 0x00, 0xff}
+	blue := color.RGBA{0x00, 0x00, 0xff, 0xff}
+	testCases := []struct {
+		desc       string
+		fills      []lowlevel.Color
+		wantCounts map[color.RGBA]int
+		wantOrder  []color.RGBA
+	}{{
+		desc:       "no paths",
+		wantCounts: map[color.RGBA]int{},
+	}, {
+		desc:       "initial CREG[0]",
+		fills:      []lowlevel.Color{lowlevel.CRegColor(0)},
+		wantCounts: map[color.RGBA]int{black: 1},
+		wantOrder:  []color.RGBA{black},
+	}, {
+		desc: "most used first",
+		fills: []lowlevel.Color{
+			lowlevel.RGBAColor(green),
+			lowlevel.RGBAColor(blue),
+			lowlevel.RGBAColor(red),
+			lowlevel.RGBAColor(blue),
+		},
+		wantCounts: map[color.RGBA]int{green: 1, blue: 2, red: 1},
+		wantOrder:  []color.RGBA{blue, green, red},
+	}, {
+		desc: "resolved palette index",
+		fills: []lowlevel.Color{
+			lowlevel.PaletteIndexColor(0),
+			lowlevel.RGBAColor(black),
+		},
+		wantCounts: map[color.RGBA]int{black: 2},
+		wantOrder:  []color.RGBA{black},
+	}, {
+		desc: "transparent",
+		fills: []lowlevel.Color{
+			lowlevel.RGBAColor(color.RGBA{}),
+		},
+		wantCounts: map[color.RGBA]int{{}: 1},
+		wantOrder:  []color.RGBA{{}},
+	}}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		})
+		for _, fill := range tc.fills {
+			e.SetCReg(0, false, fill)
+			e.StartPath(0, 0, 0)
+			e.AbsLineTo(10, 0)
+			e.AbsLineTo(10, 10)
+			e.ClosePathEndPath()
+		}
+		src, err := e.Bytes()
+		if err != nil {
+			t.Fatalf("%s: Bytes: %v", tc.desc, err)
+		}
+		counts, err := lowlevel.ColorCounts(src)
+		if err != nil {
+			t.Errorf("%s: ColorCounts: %v", tc.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(counts, tc.wantCounts) {
+			t.Errorf("%s: ColorCounts: got %v, want %v", tc.desc, counts, tc.wantCounts)
+		}
+		order, err := lowlevel.ExtractPalette(src)
+		if err != nil {
+			t.Errorf("%s: ExtractPalette: %v", tc.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(order, tc.wantOrder) {
+			t.Errorf("%s: ExtractPalette: got %v, want %v", tc.desc, order, tc.wantOrder)
+		}
+	}
+}
+
+func TestExtractPaletteGradient(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/gradient.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := lowlevel.ColorCounts(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every color is a gradient stop. None of them is the gradient's own
+	// CREG value, which is not a valid alpha-premultiplied color.
+	if len(counts) < 2 {
+		t.Errorf("got %d colors, want at least 2", len(counts))
+	}
+	for c := range counts {
+		if c.R > c.A || c.G > c.A || c.B > c.A {
+			t.Errorf("got non-premultiplied color %v", c)
+		}
+	}
+}
+
+func TestNewPalette(t *testing.T) {
+	colors := []color.Color{
+		color.RGBA{0x12, 0x34, 0x56, 0xff},
+		color.NRGBA{0xff, 0x00, 0x00, 0x80},
+		color.Gray{0x80},
+	}
+	p := lowlevel.NewPalette(colors...)
+	want := lowlevel.DefaultPalette
+	want[0] = color.RGBA{0x12, 0x34, 0x56, 0xff}
+	want[1] = color.RGBA{0x80, 0x00, 0x00, 0x80}
+	want[2] = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	if p != want {
+		t.Errorf("got %v, want %v", p, want)
+	}
+
+	// Colors beyond the 64th are ignored.
+	many := make([]color.Color, 65)
+	for i := range many {
+		many[i] = color.White
+	}
+	p = lowlevel.NewPalette(many...)
+	for i, c := range p {
+		if c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+			t.Errorf("many: entry %d: got %v, want white", i, c)
+		}
+	}
+	if p := lowlevel.NewPalette(); p != lowlevel.DefaultPalette {
+		t.Errorf("none: got %v, want the DefaultPalette", p)
+	}
+}
+
+func TestPaletteValidate(t *testing.T) {
+	p := lowlevel.DefaultPalette
+	if err := p.Validate(); err != nil {
+		t.Errorf("DefaultPalette: %v", err)
+	}
+	p[5] = color.RGBA{0x00, 0x00, 0x00, 0x00}
+	if err := p.Validate(); err != nil {
+		t.Errorf("transparent: %v", err)
+	}
+	p[5] = color.RGBA{0x00, 0x81, 0x00, 0x80}
+	err := p.Validate()
+	if !errors.Is(err, lowlevel.ErrNonPremultipliedColor) {
+		t.Errorf("non-premultiplied: got %v, want %v", err, lowlevel.ErrNonPremultipliedColor)
+	} else if !strings.Contains(err.Error(), "palette entry 5") {
+		t.Errorf("non-premultiplied: got %q, want it to name palette entry 5", err)
+	}
+}
+
+func TestPaletteBlendOver(t *testing.T) {
+	p := lowlevel.DefaultPalette
+	p[0] = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	p[1] = color.RGBA{0x00, 0x00, 0x00, 0x80}
+	p[2] = color.RGBA{0x00, 0x00, 0x00, 0x00}
+	p[3] = color.RGBA{0x00, 0x40, 0x00, 0x40}
+	testCases := []struct {
+		desc string
+		bg   color.Color
+		want [4]color.RGBA
+	}{{
+		desc: "white",
+		bg:   color.White,
+		want: [4]color.RGBA{
+			{0xff, 0x00, 0x00, 0xff},
+			{0x7f, 0x7f, 0x7f, 0xff},
+			{0xff, 0xff, 0xff, 0xff},
+			{0xbf, 0xff, 0xbf, 0xff},
+		},
+	}, {
+		desc: "transparent",
+		bg:   color.Transparent,
+		want: [4]color.RGBA{p[0], p[1], p[2], p[3]},
+	}, {
+		desc: "translucent blue",
+		bg:   color.NRGBA{0x00, 0x00, 0xff, 0x80},
+		want: [4]color.RGBA{
+			{0xff, 0x00, 0x00, 0xff},
+			{0x00, 0x00, 0x40, 0xc0},
+			{0x00, 0x00, 0x80, 0x80},
+			{0x00, 0x40, 0x60, 0xa0},
+		},
+	}}
+	for _, tc := range testCases {
+		got := p.BlendOver(tc.bg)
+		for i, want := range tc.want {
+			if got[i] != want {
+				t.Errorf("%s: entry %d: got %v, want %v", tc.desc, i, got[i], want)
+			}
+		}
+	}
+	if p[1] != (color.RGBA{0x00, 0x00, 0x00, 0x80}) {
+		t.Errorf("BlendOver modified its receiver")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"image/color"
+)
+
+// Recolor returns a copy of the IconVG graphic src with its colors replaced
+// according to mapping, whose keys and values are alpha-premultiplied colors.
+// Colors that are not keys of mapping are unchanged.
+//
+// It replaces direct colors, including gradient stops, and suggested palette
+// entries, which also initialize the CREG registers. Replacing an entry of the
+// default palette (opaque black) adds a suggested palette to the result. A
+// blend's two colors are replaced only if their replacements can also be
+// encoded as a 1 byte color, as a blend requires. The color that a blend
+// resolves to is not itself looked up in mapping.
+//
+// Every color is re-encoded in the fewest bytes that can represent it, as are
+// the graphic's numbers. The operations are otherwise unchanged.
+func Recolor(src []byte, mapping map[color.RGBA]color.RGBA) ([]byte, error) {
+	r := &recolorer{mapping: mapping}
+	if err := Decode(r, src, nil); err != nil {
+		return nil, err
+	}
+	return r.Bytes()
+}
+
+// recolorer is a Destination that re-encodes what it receives, replacing
+// colors.
+type recolorer struct {
+	Encoder
+
+	mapping map[color.RGBA]color.RGBA
+}
+
+func (r *recolorer) Reset(m Metadata) {
+	for i, c := range m.Palette {
+		m.Palette[i] = r.recolor(c)
+	}
+	r.Encoder.Reset(m)
+}
+
+func (r *recolorer) SetCReg(adj uint8, incr bool, c Color) {
+	switch c.typ {
+	case ColorTypeRGBA:
+		// Gradient descriptors, in CREG, are not valid colors and are left
+		// alone.
+		if rgba := c.rgba(); validAlphaPremulColor(rgba) {
+			c = RGBAColor(r.recolor(rgba))
+		}
+	case ColorTypeBlend:
+		t, x0, x1 := c.blend()
+		c = BlendColor(t, r.recolor1(x0), r.recolor1(x1))
+	}
+	r.Encoder.SetCReg(adj, incr, c)
+}
+
+func (r *recolorer) recolor(c color.RGBA) color.RGBA {
+	if d, ok := r.mapping[c]; ok {
+		return d
+	}
+	return c
+}
+
+// recolor1 replaces a 1 byte color, if it is direct and if its replacement is
+// also encodable as a 1 byte color.
+func (r *recolorer) recolor1(x byte) byte {
+	c := decodeColor1(x)
+	if c.typ != ColorTypeRGBA {
+		return x
+	}
+	if y, ok := encodeColor1(RGBAColor(r.recolor(c.rgba()))); ok {
+		return y
+	}
+	return x
+}
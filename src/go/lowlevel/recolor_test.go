@@ -0,0 +1,140 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestRecolor(t *testing.T) {
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	blue := color.RGBA{0x00, 0x00, 0xff, 0xff}
+	odd := color.RGBA{0x12, 0x34, 0x56, 0xff}
+	testCases := []struct {
+		desc    string
+		fill    lowlevel.Color
+		mapping map[color.RGBA]color.RGBA
+		want    color.RGBA
+	}{{
+		desc:    "direct",
+		fill:    lowlevel.RGBAColor(red),
+		mapping: map[color.RGBA]color.RGBA{red: blue},
+		want:    blue,
+	}, {
+		desc:    "unmapped",
+		fill:    lowlevel.RGBAColor(red),
+		mapping: map[color.RGBA]color.RGBA{blue: red},
+		want:    red,
+	}, {
+		desc:    "palette",
+		fill:    lowlevel.PaletteIndexColor(0),
+		mapping: map[color.RGBA]color.RGBA{black: red},
+		want:    red,
+	}, {
+		desc:    "initial CREG",
+		fill:    lowlevel.CRegColor(0),
+		mapping: map[color.RGBA]color.RGBA{black: red},
+		want:    red,
+	}, {
+		// 0x00 and 0x7c are the 1 byte encodings of opaque black and white.
+		// Red has a 1 byte encoding but odd does not.
+		desc:    "blend",
+		fill:    lowlevel.BlendColor(0xff, 0x00, 0x7c),
+		mapping: map[color.RGBA]color.RGBA{white: red},
+		want:    red,
+	}, {
+		desc:    "blend with an unencodable replacement",
+		fill:    lowlevel.BlendColor(0xff, 0x00, 0x7c),
+		mapping: map[color.RGBA]color.RGBA{white: odd},
+		want:    white,
+	}}
+	for _, tc := range testCases {
+		e := &lowlevel.Encoder{}
+		e.Reset(lowlevel.Metadata{
+			ViewBox: lowlevel.DefaultViewBox,
+			Palette: lowlevel.DefaultPalette,
+		})
+		e.SetCReg(0, false, tc.fill)
+		e.StartPath(0, 0, 0)
+		e.AbsLineTo(10, 0)
+		e.AbsLineTo(10, 10)
+		e.ClosePathEndPath()
+		src, err := e.Bytes()
+		if err != nil {
+			t.Fatalf("%s: Bytes: %v", tc.desc, err)
+		}
+		dst, err := lowlevel.Recolor(src, tc.mapping)
+		if err != nil {
+			t.Errorf("%s: Recolor: %v", tc.desc, err)
+			continue
+		}
+		got, err := lowlevel.ColorCounts(dst)
+		if err != nil {
+			t.Errorf("%s: ColorCounts: %v", tc.desc, err)
+			continue
+		}
+		if want := map[color.RGBA]int{tc.want: 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, want)
+		}
+	}
+}
+
+func TestRecolorTestData(t *testing.T) {
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, err := lowlevel.ColorCounts(src)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		// An empty mapping changes no colors.
+		dst, err := lowlevel.Recolor(src, nil)
+		if err != nil {
+			t.Errorf("%s: Recolor(nil): %v", filename, err)
+			continue
+		}
+		if got, err := lowlevel.ColorCounts(dst); err != nil || !reflect.DeepEqual(got, before) {
+			t.Errorf("%s: Recolor(nil): got %v, %v, want %v, nil", filename, got, err, before)
+		}
+
+		// Mapping every color to red leaves only red.
+		mapping := map[color.RGBA]color.RGBA{}
+		total := 0
+		for c, n := range before {
+			mapping[c] = red
+			total += n
+		}
+		dst, err = lowlevel.Recolor(src, mapping)
+		if err != nil {
+			t.Errorf("%s: Recolor: %v", filename, err)
+			continue
+		}
+		want := map[color.RGBA]int{red: total}
+		if got, err := lowlevel.ColorCounts(dst); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Recolor: got %v, %v, want %v, nil", filename, got, err, want)
+		}
+	}
+}
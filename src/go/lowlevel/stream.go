@@ -0,0 +1,322 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// maxInstructionLength is the maximum number of bytes in a single IconVG
+// instruction: an opcode followed by up to 16 repetitions of an arcTo, each
+// having 6 numbers of up to 4 bytes each.
+const maxInstructionLength = 1 + 16*6*4
+
+// StreamDecoder decodes an IconVG graphic from an io.Reader.
+//
+// Unlike Decode, it does not need the complete IconVG graphic up front. It
+// reads one instruction at a time, calling the Destination's methods as soon
+// as that instruction's bytes are available, so that a graphic being fetched
+// over a slow network can be rendered incrementally. As a consequence, it
+// only verifies a graphic's checksum once it reaches the end of the graphic,
+// after calling the Destination's methods.
+type StreamDecoder struct {
+	r   *bufio.Reader
+	c   countingReader
+	lim limiter
+	chk checker
+
+	// crc is the running CRC-32C of the bytes decoded so far, with any
+	// checksum taken as zero. checksumAt and checksum are the position and
+	// value of that checksum, if the graphic has one.
+	crc        uint32
+	checksumAt int
+	checksum   uint32
+}
+
+// countingReader counts the bytes read from an io.Reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// NewStreamDecoder returns a StreamDecoder that reads from r.
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	// The bufio.Reader's buffer must be able to hold any single instruction.
+	d := &StreamDecoder{
+		c: countingReader{r: r},
+	}
+	d.r = bufio.NewReaderSize(&d.c, 8*maxInstructionLength)
+	return d
+}
+
+// Decode decodes an IconVG graphic, reading until io.EOF.
+//
+// opts may be nil, which means to use the default options.
+func (d *StreamDecoder) Decode(dst Destination, opts *DecodeOptions) error {
+	m := Metadata{
+		ViewBox: DefaultViewBox,
+		Palette: DefaultPalette,
+	}
+	if opts != nil && opts.Palette != nil {
+		m.Palette = *opts.Palette
+	}
+	chk := newChecker(&d.chk, opts)
+	if chk != nil {
+		defer func() { chk.onWarning = nil }()
+	}
+	if err := d.decodeMetadata(&m, chk, opts); err != nil {
+		return err
+	}
+	levels := newDetailLevels(dst, &m, d.c.n-d.r.Buffered(), opts)
+	setFillRule(dst, opts)
+	lim := (*limiter)(nil)
+	if hasLimits(opts) {
+		lim = &d.lim
+		lim.reset(dst, opts)
+		dst = lim
+		defer lim.release()
+	}
+	if dst != nil {
+		dst.Reset(m)
+	}
+
+	mf, drawing, onInstruction, trace := modeFunc(decodeStyling), false, (func(int))(nil), (func(int, Opcode, []byte))(nil)
+	if opts != nil {
+		onInstruction, trace = opts.OnInstruction, opts.Trace
+	}
+	pathOffset, pathOpcode := 0, byte(0)
+	for {
+		offset := d.c.n - d.r.Buffered()
+		if _, err := d.r.Peek(1); err == io.EOF {
+			if (d.checksumAt > 0) && (d.crc != d.checksum) {
+				return annotate(ErrInvalidChecksum, d.checksumAt, 0, true)
+			}
+			if err := levels.finish(offset, drawing); err != nil {
+				return err
+			}
+			return checkFinished(chk, drawing, pathOffset, pathOpcode)
+		} else if err != nil {
+			return err
+		}
+		if stop, err := levels.reach(offset, drawing); err != nil {
+			return err
+		} else if stop {
+			return nil
+		}
+		if onInstruction != nil {
+			onInstruction(offset)
+		}
+		if lim != nil {
+			if err := lim.opcode(); err != nil {
+				return err
+			}
+		}
+		n, err := d.instructionLength(drawing)
+		if err != nil {
+			return err
+		}
+		b, err := d.r.Peek(n)
+		if (err != nil) && (err != io.EOF) {
+			return err
+		}
+
+		// If b is short then mf will return the appropriate decoding error.
+		op := Opcode{b[0], drawing}
+		chk.locate(offset, op.Byte, false)
+		if mf, _, err = mf(dst, nil, chk, buffer(b)); err != nil {
+			return annotate(err, offset, op.Byte, false)
+		} else if (lim != nil) && (lim.err != nil) {
+			return lim.err
+		}
+		if trace != nil {
+			trace(offset, op, b[1:])
+		}
+		d.crc = crc32.Update(d.crc, castagnoli, b)
+		d.r.Discard(len(b))
+		if !drawing {
+			pathOffset, pathOpcode = offset, op.Byte
+		}
+		drawing = op.nextDrawing()
+	}
+}
+
+// DecodeMetadataReader is like DecodeMetadata but reads the IconVG graphic
+// from r. It reads little more than the magic identifier and the metadata
+// chunks, although it may buffer some of the bytes that follow them. Unlike
+// DecodeMetadata, it therefore does not verify the graphic's checksum.
+func DecodeMetadataReader(r io.Reader) (Metadata, error) {
+	d := &StreamDecoder{
+		c: countingReader{r: r},
+	}
+	d.r = bufio.NewReaderSize(&d.c, 64)
+	m := Metadata{
+		ViewBox: DefaultViewBox,
+		Palette: DefaultPalette,
+	}
+	if err := d.decodeMetadata(&m, nil, nil); err != nil {
+		return Metadata{}, err
+	}
+	return m, nil
+}
+
+func (d *StreamDecoder) decodeMetadata(m *Metadata, chk *checker, opts *DecodeOptions) error {
+	if b, _ := d.r.Peek(len(magic)); !bytes.Equal(b, magicBytes) {
+		return annotate(ErrInvalidMagicIdentifier, 0, 0, true)
+	}
+	d.r.Discard(len(magic))
+	d.crc, d.checksumAt = crc32.Update(0, castagnoli, magicBytes), 0
+
+	nMetadataChunks, n, err := d.peekNatural()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return annotate(ErrInvalidNumberOfMetadataChunks, len(magic), 0, true)
+	}
+	b, _ := d.r.Peek(n)
+	d.crc = crc32.Update(d.crc, castagnoli, b)
+	d.r.Discard(n)
+
+	for ; nMetadataChunks > 0; nMetadataChunks-- {
+		offset := d.c.n - d.r.Buffered()
+		length, n, err := d.peekNatural()
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return annotate(ErrInvalidMetadataChunkLength, offset, 0, true)
+		}
+
+		// Read the chunk, including its length prefix. The io.LimitReader
+		// means that we only allocate as many bytes as are actually present,
+		// even if the chunk length is (maliciously) enormous.
+		chunk, err := io.ReadAll(io.LimitReader(d.r, int64(n)+int64(length)))
+		if err != nil {
+			return err
+		}
+		chk.locate(offset, 0, true)
+		hadChecksum := m.Checksum
+		if _, err := decodeMetadataChunk(nil, chk, m, buffer(chunk), opts); err != nil {
+			return annotate(err, offset, 0, true)
+		}
+		if m.Checksum && !hadChecksum {
+			// The checksum ends its chunk.
+			d.checksumAt = offset + len(chunk) - 4
+			d.checksum = binary.LittleEndian.Uint32(chunk[len(chunk)-4:])
+			copy(chunk[len(chunk)-4:], "\x00\x00\x00\x00")
+		}
+		d.crc = crc32.Update(d.crc, castagnoli, chunk)
+	}
+	return nil
+}
+
+// peekNatural peeks at the natural number at the start of the unread bytes,
+// without consuming it. It returns n == 0 if there are insufficient bytes.
+func (d *StreamDecoder) peekNatural() (u uint32, n int, retErr error) {
+	b, err := d.r.Peek(numberLength(d.r))
+	if (err != nil) && (err != io.EOF) {
+		return 0, 0, err
+	}
+	u, n = buffer(b).decodeNatural()
+	return u, n, nil
+}
+
+// instructionLength returns the number of bytes in the next instruction,
+// based on its opcode and the (self-describing) lengths of its numbers. It
+// returns the number of bytes available if that is fewer.
+func (d *StreamDecoder) instructionLength(drawing bool) (int, error) {
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	opcode := b[0]
+
+	nColorBytes, nNumbers := 0, 0
+	if !drawing {
+		switch {
+		case opcode < 0x80:
+		case opcode < 0xa8:
+			nColorBytes = [5]int{1, 2, 3, 4, 3}[(opcode-0x80)>>3]
+		case opcode < 0xc0:
+			nNumbers = 1
+		case opcode < 0xc8:
+			nNumbers = 2
+		}
+	} else {
+		switch {
+		case opcode < 0xe0:
+			nReps := 1 + int(opcode&0x0f)
+			nCoords := 0
+			switch opcode >> 4 {
+			case 0x00, 0x01, 0x02, 0x03:
+				nReps = 1 + int(opcode&0x1f)
+				nCoords = 2
+			case 0x04, 0x05:
+				nCoords = 2
+			case 0x06, 0x07, 0x08, 0x09:
+				nCoords = 4
+			default:
+				// cubeTo and arcTo both take 6 numbers per repetition.
+				nCoords = 6
+			}
+			nNumbers = nReps * nCoords
+		case (opcode == 0xe2) || (opcode == 0xe3):
+			nNumbers = 2
+		case (0xe6 <= opcode) && (opcode < 0xea):
+			nNumbers = 1
+		}
+	}
+
+	n := 1 + nColorBytes
+	for ; nNumbers > 0; nNumbers-- {
+		b, err := d.r.Peek(n + 1)
+		if (err != nil) && (err != io.EOF) {
+			return 0, err
+		} else if len(b) <= n {
+			return len(b), nil
+		}
+		n += numberLengthFromFirstByte(b[n])
+	}
+	return n, nil
+}
+
+// numberLength returns the length of the number at the start of r's unread
+// bytes, or 1 if there are no unread bytes.
+func numberLength(r *bufio.Reader) int {
+	b, _ := r.Peek(1)
+	if len(b) == 0 {
+		return 1
+	}
+	return numberLengthFromFirstByte(b[0])
+}
+
+// numberLengthFromFirstByte returns the length of an encoded number, which
+// is determined by the low two bits of its first byte.
+func numberLengthFromFirstByte(x byte) int {
+	if x&0x01 == 0 {
+		return 1
+	} else if x&0x02 == 0 {
+		return 2
+	}
+	return 4
+}
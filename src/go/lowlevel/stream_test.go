@@ -0,0 +1,186 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func TestDecodeMetadataReader(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The magic identifier, the number of metadata chunks and the 6 byte
+	// viewBox chunk take 11 bytes.
+	const metadataLen = 11
+
+	testCases := []struct {
+		desc    string
+		src     []byte
+		wantErr bool
+	}{
+		{"complete", src, false},
+		{"metadata only", src[:metadataLen], false},
+		{"metadata and garbage", append(src[:metadataLen:metadataLen], 0xff, 0xff, 0xff), false},
+		{"empty", nil, true},
+		{"bad magic identifier", append([]byte("\x89IVH"), src[4:]...), true},
+		{"truncated metadata", src[:metadataLen-1], true},
+	}
+	for _, tc := range testCases {
+		got, err := lowlevel.DecodeMetadataReader(bytes.NewReader(tc.src))
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.desc, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if !got.Equal(&want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, want)
+		}
+	}
+}
+
+func TestDecodeMetadataReaderTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.hires.ivg",
+		"arcs.ivg",
+		"blank.ivg",
+		"cowbell.ivg",
+		"favicon.ivg",
+		"gradient.ivg",
+		"video-005.primitive.ivg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := lowlevel.DecodeMetadata(src)
+		if err != nil {
+			t.Errorf("%s: DecodeMetadata: %v", tc, err)
+			continue
+		}
+		got, err := lowlevel.DecodeMetadataReader(bytes.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: DecodeMetadataReader: %v", tc, err)
+			continue
+		}
+		if !got.Equal(&want) {
+			t.Errorf("%s: got %v, want %v", tc, got, want)
+		}
+	}
+}
+
+var streamTestData = []string{
+	"action-info.hires.ivg",
+	"action-info.lores.ivg",
+	"arcs.ivg",
+	"blank.ivg",
+	"cowbell.ivg",
+	"elliptical.ivg",
+	"favicon.ivg",
+	"gradient-spreads.ivg",
+	"gradient.ivg",
+	"lod-polygon.ivg",
+	"video-005.primitive.ivg",
+}
+
+// reencode decodes an IconVG graphic into an Encoder, with decode, and
+// returns the Encoder's bytes. Two decoders that call the Destination the
+// same way give the same bytes.
+func reencode(decode func(dst lowlevel.Destination) error) ([]byte, error) {
+	e := &lowlevel.Encoder{}
+	if err := decode(e); err != nil {
+		return nil, err
+	}
+	return e.Bytes()
+}
+
+func TestStreamDecoder(t *testing.T) {
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"whole", func(r io.Reader) io.Reader { return r }},
+		{"one byte", iotest.OneByteReader},
+		{"half", iotest.HalfReader},
+		{"data and EOF", iotest.DataErrReader},
+	}
+	for _, tc := range streamTestData {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := reencode(func(dst lowlevel.Destination) error {
+			return lowlevel.Decode(dst, src, nil)
+		})
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc, err)
+			continue
+		}
+		for _, r := range readers {
+			got, err := reencode(func(dst lowlevel.Destination) error {
+				return lowlevel.NewStreamDecoder(r.wrap(bytes.NewReader(src))).Decode(dst, nil)
+			})
+			if err != nil {
+				t.Errorf("%s: %s reader: %v", tc, r.name, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s reader: the StreamDecoder and Decode disagree", tc, r.name)
+			}
+		}
+	}
+}
+
+func TestStreamDecoderErrors(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errRead := errors.New("read error")
+	testCases := []struct {
+		desc    string
+		r       io.Reader
+		wantErr error
+	}{
+		{"empty", bytes.NewReader(nil), nil},
+		{"bad magic identifier", bytes.NewReader(append([]byte("\x89IVH"), src[4:]...)), nil},
+		{"truncated metadata", bytes.NewReader(src[:8]), nil},
+		{"truncated drawing", iotest.OneByteReader(bytes.NewReader(src[:len(src)-3])), nil},
+		{"read error", io.MultiReader(bytes.NewReader(src[:len(src)/2]), iotest.ErrReader(errRead)), errRead},
+	}
+	for _, tc := range testCases {
+		err := lowlevel.NewStreamDecoder(tc.r).Decode(lowlevel.NopDestination{}, nil)
+		if err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		} else if (tc.wantErr != nil) && !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: got %v, want %v", tc.desc, err, tc.wantErr)
+		}
+	}
+}
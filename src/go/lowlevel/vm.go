@@ -0,0 +1,358 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel
+
+import (
+	"image/color"
+	"math"
+
+	"golang.org/x/image/math/f32"
+)
+
+// VMState is a snapshot of the state of the byte code's virtual machine: its
+// registers, its metadata and the current path.
+//
+// Coordinates are in the graphic's coordinate space, as defined by the
+// Metadata's ViewBox.
+type VMState struct {
+	Metadata Metadata
+
+	// CSel and NSel are the selector registers. CReg and NReg are the color
+	// and number registers. CReg's colors are resolved, as by Color.Resolve:
+	// each is either an alpha-premultiplied color or a gradient.
+	CSel uint8
+	NSel uint8
+	CReg [64]color.RGBA
+	NReg [64]float32
+
+	// LOD0 and LOD1 are the level of detail bounds for subsequent paths.
+	LOD0, LOD1 float32
+
+	// InPath is whether the virtual machine is in the drawing mode, between
+	// a path's StartPath and its ClosePathEndPath. NumPaths is the number of
+	// paths started so far, and PathFill is the CREG value that the most
+	// recent path is filled with.
+	InPath   bool
+	NumPaths int
+	PathFill color.RGBA
+
+	// Pen is the current point. Smooth is the implicit first control point
+	// for a subsequent smooth quadTo or cubeTo: the reflection, through Pen,
+	// of the previous segment's last control point, or Pen itself.
+	Pen    f32.Vec2
+	Smooth f32.Vec2
+}
+
+// SelectedCReg returns CREG[CSEL-adj], modulo 64.
+func (s *VMState) SelectedCReg(adj uint8) color.RGBA {
+	return s.CReg[(s.CSel-adj)&0x3f]
+}
+
+// SelectedNReg returns NREG[NSEL-adj], modulo 64.
+func (s *VMState) SelectedNReg(adj uint8) float32 {
+	return s.NReg[(s.NSel-adj)&0x3f]
+}
+
+// VM is a Destination that executes the byte code's virtual machine, keeping
+// its VMState, and forwards each method call to another Destination.
+//
+// Debuggers and other tools can query the state mid-decode, such as from
+// DecodeOptions.OnInstruction, which is called between instructions:
+//
+//	vm := lowlevel.NewVM(dst)
+//	err := lowlevel.Decode(vm, src, &lowlevel.DecodeOptions{
+//		OnInstruction: func(offset int) {
+//			s := vm.State()
+//			fmt.Printf("0x%04x: CSEL=%d NSEL=%d pen=%v\n", offset, s.CSel, s.NSel, s.Pen)
+//		},
+//	})
+type VM struct {
+	dst Destination
+	s   VMState
+
+	// blends caches the resolved values of blend colors. It is keyed by the
+	// blends' resolved components, not by their CREG or palette indices, so
+	// that it stays valid when those registers change, including across
+	// Reset calls.
+	blends [blendCacheSize]blendCacheEntry
+}
+
+// blendCacheSize is the number of entries in a VM's blend cache. It is a
+// power of 2.
+const blendCacheSize = 64
+
+// blendCacheEntry is a resolved blend color: t/255 of the way from rgba0 to
+// rgba1 is rgba. An entry whose t, rgba0 and rgba1 are all zero is unused,
+// which is harmless, as that blend resolves to zero.
+type blendCacheEntry struct {
+	t            uint8
+	rgba0, rgba1 color.RGBA
+	rgba         color.RGBA
+}
+
+// NewVM returns a VM that forwards to dst, which may be nil.
+func NewVM(dst Destination) *VM {
+	return &VM{dst: dst}
+}
+
+// State returns a snapshot of the virtual machine's state.
+func (v *VM) State() VMState {
+	return v.s
+}
+
+// SetFillRule forwards to the VM's Destination, if it is a
+// FillRuleDestination.
+func (v *VM) SetFillRule(r FillRule) {
+	if d, ok := v.dst.(FillRuleDestination); ok {
+		d.SetFillRule(r)
+	}
+}
+
+func (v *VM) Reset(m Metadata) {
+	v.s = VMState{
+		Metadata: m,
+		CReg:     m.Palette,
+		LOD0:     0,
+		LOD1:     float32(math.Inf(+1)),
+	}
+	if v.dst != nil {
+		v.dst.Reset(m)
+	}
+}
+
+func (v *VM) SetCSel(cSel uint8) {
+	v.s.CSel = cSel & 0x3f
+	if v.dst != nil {
+		v.dst.SetCSel(cSel)
+	}
+}
+
+func (v *VM) SetNSel(nSel uint8) {
+	v.s.NSel = nSel & 0x3f
+	if v.dst != nil {
+		v.dst.SetNSel(nSel)
+	}
+}
+
+func (v *VM) SetCReg(adj uint8, incr bool, c Color) {
+	v.s.CReg[(v.s.CSel-adj)&0x3f] = v.resolve(c)
+	if incr {
+		v.s.CSel = (v.s.CSel + 1) & 0x3f
+	}
+	if v.dst != nil {
+		v.dst.SetCReg(adj, incr, c)
+	}
+}
+
+func (v *VM) SetNReg(adj uint8, incr bool, f float32) {
+	v.s.NReg[(v.s.NSel-adj)&0x3f] = f
+	if incr {
+		v.s.NSel = (v.s.NSel + 1) & 0x3f
+	}
+	if v.dst != nil {
+		v.dst.SetNReg(adj, incr, f)
+	}
+}
+
+func (v *VM) SetLOD(lod0, lod1 float32) {
+	v.s.LOD0, v.s.LOD1 = lod0, lod1
+	if v.dst != nil {
+		v.dst.SetLOD(lod0, lod1)
+	}
+}
+
+func (v *VM) StartPath(adj uint8, x, y float32) {
+	v.s.InPath = true
+	v.s.NumPaths++
+	v.s.PathFill = v.s.SelectedCReg(adj)
+	v.moveTo(f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.StartPath(adj, x, y)
+	}
+}
+
+func (v *VM) ClosePathEndPath() {
+	v.s.InPath = false
+	if v.dst != nil {
+		v.dst.ClosePathEndPath()
+	}
+}
+
+// Like the C implementation, closing a sub-path does not move the pen back to
+// the start of the sub-path, so relative moveTos are relative to the pen.
+
+func (v *VM) ClosePathAbsMoveTo(x, y float32) {
+	v.moveTo(f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.ClosePathAbsMoveTo(x, y)
+	}
+}
+
+func (v *VM) ClosePathRelMoveTo(x, y float32) {
+	v.moveTo(v.rel(x, y))
+	if v.dst != nil {
+		v.dst.ClosePathRelMoveTo(x, y)
+	}
+}
+
+func (v *VM) AbsHLineTo(x float32) {
+	v.moveTo(f32.Vec2{x, v.s.Pen[1]})
+	if v.dst != nil {
+		v.dst.AbsHLineTo(x)
+	}
+}
+
+func (v *VM) RelHLineTo(x float32) {
+	v.moveTo(v.rel(x, 0))
+	if v.dst != nil {
+		v.dst.RelHLineTo(x)
+	}
+}
+
+func (v *VM) AbsVLineTo(y float32) {
+	v.moveTo(f32.Vec2{v.s.Pen[0], y})
+	if v.dst != nil {
+		v.dst.AbsVLineTo(y)
+	}
+}
+
+func (v *VM) RelVLineTo(y float32) {
+	v.moveTo(v.rel(0, y))
+	if v.dst != nil {
+		v.dst.RelVLineTo(y)
+	}
+}
+
+func (v *VM) AbsLineTo(x, y float32) {
+	v.moveTo(f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsLineTo(x, y)
+	}
+}
+
+func (v *VM) RelLineTo(x, y float32) {
+	v.moveTo(v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelLineTo(x, y)
+	}
+}
+
+func (v *VM) AbsSmoothQuadTo(x, y float32) {
+	v.curveTo(v.s.Smooth, f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsSmoothQuadTo(x, y)
+	}
+}
+
+func (v *VM) RelSmoothQuadTo(x, y float32) {
+	v.curveTo(v.s.Smooth, v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelSmoothQuadTo(x, y)
+	}
+}
+
+func (v *VM) AbsQuadTo(x1, y1, x, y float32) {
+	v.curveTo(f32.Vec2{x1, y1}, f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsQuadTo(x1, y1, x, y)
+	}
+}
+
+func (v *VM) RelQuadTo(x1, y1, x, y float32) {
+	v.curveTo(v.rel(x1, y1), v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelQuadTo(x1, y1, x, y)
+	}
+}
+
+func (v *VM) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	v.curveTo(f32.Vec2{x2, y2}, f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsSmoothCubeTo(x2, y2, x, y)
+	}
+}
+
+func (v *VM) RelSmoothCubeTo(x2, y2, x, y float32) {
+	v.curveTo(v.rel(x2, y2), v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelSmoothCubeTo(x2, y2, x, y)
+	}
+}
+
+func (v *VM) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	v.curveTo(f32.Vec2{x2, y2}, f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsCubeTo(x1, y1, x2, y2, x, y)
+	}
+}
+
+func (v *VM) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	v.curveTo(v.rel(x2, y2), v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelCubeTo(x1, y1, x2, y2, x, y)
+	}
+}
+
+func (v *VM) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	v.moveTo(f32.Vec2{x, y})
+	if v.dst != nil {
+		v.dst.AbsArcTo(rx, ry, xAxisRotation, largeArc, sweep, x, y)
+	}
+}
+
+func (v *VM) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	v.moveTo(v.rel(x, y))
+	if v.dst != nil {
+		v.dst.RelArcTo(rx, ry, xAxisRotation, largeArc, sweep, x, y)
+	}
+}
+
+// resolve is like c.Resolve, in the VM's context, but looks blends up in, or
+// adds them to, the blend cache.
+func (v *VM) resolve(c Color) color.RGBA {
+	if c.typ != ColorTypeBlend {
+		return c.resolve1(&v.s.Metadata.Palette, &v.s.CReg)
+	}
+	t, c0, c1 := c.blend()
+	rgba0 := decodeColor1(c0).resolve1(&v.s.Metadata.Palette, &v.s.CReg)
+	rgba1 := decodeColor1(c1).resolve1(&v.s.Metadata.Palette, &v.s.CReg)
+	h := uint32(t) ^ (rgbaHash(rgba0) * 31) ^ (rgbaHash(rgba1) * 37)
+	e := &v.blends[(h^(h>>16))&(blendCacheSize-1)]
+	if (e.t != t) || (e.rgba0 != rgba0) || (e.rgba1 != rgba1) {
+		*e = blendCacheEntry{t, rgba0, rgba1, blendRGBA(t, rgba0, rgba1)}
+	}
+	return e.rgba
+}
+
+func rgbaHash(c color.RGBA) uint32 {
+	return uint32(c.R) | uint32(c.G)<<8 | uint32(c.B)<<16 | uint32(c.A)<<24
+}
+
+func (v *VM) rel(x, y float32) f32.Vec2 {
+	return f32.Vec2{v.s.Pen[0] + x, v.s.Pen[1] + y}
+}
+
+// moveTo moves the pen to p, after a moveTo, lineTo or arcTo, none of which
+// have a control point to reflect.
+func (v *VM) moveTo(p f32.Vec2) {
+	v.s.Pen, v.s.Smooth = p, p
+}
+
+// curveTo moves the pen to p, after a quadTo or cubeTo whose last control
+// point is c.
+func (v *VM) curveTo(c, p f32.Vec2) {
+	v.s.Pen = p
+	v.s.Smooth = f32.Vec2{2*p[0] - c[0], 2*p[1] - c[1]}
+}
@@ -0,0 +1,298 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lowlevel_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+func TestVMResolvesBlends(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	blue := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})
+	// blend is halfway between CREG[1] and CREG[2].
+	blend := lowlevel.BlendColor(0x80, 0xc1, 0xc2)
+
+	type setCReg struct {
+		cSel uint8
+		c    lowlevel.Color
+	}
+	testCases := []struct {
+		desc string
+		sets []setCReg
+	}{{
+		desc: "blend once",
+		sets: []setCReg{{1, red}, {2, blue}, {0, blend}},
+	}, {
+		desc: "blend the same colors twice",
+		sets: []setCReg{{1, red}, {2, blue}, {0, blend}, {3, blend}},
+	}, {
+		desc: "blend again after a component changes",
+		sets: []setCReg{{1, red}, {2, blue}, {0, blend}, {2, red}, {3, blend}},
+	}, {
+		desc: "blend of transparent black",
+		sets: []setCReg{{1, lowlevel.RGBAColor(color.RGBA{})}, {2, lowlevel.RGBAColor(color.RGBA{})}, {0, blend}},
+	}, {
+		desc: "blend of a palette entry",
+		sets: []setCReg{{1, lowlevel.PaletteIndexColor(5)}, {0, lowlevel.BlendColor(0x40, 0xc1, 0x85)}},
+	}, {
+		desc: "blend of a blend",
+		sets: []setCReg{{1, red}, {2, blue}, {1, blend}, {0, blend}},
+	}}
+
+	m := lowlevel.Metadata{ViewBox: lowlevel.DefaultViewBox, Palette: lowlevel.DefaultPalette}
+	vm := lowlevel.NewVM(nil)
+	for _, tc := range testCases {
+		// The VM is reused, so that its cached blends carry over from one
+		// test case to the next, as they do from one graphic to the next.
+		vm.Reset(m)
+		want := [64]color.RGBA(m.Palette)
+		for _, s := range tc.sets {
+			vm.SetCSel(s.cSel)
+			vm.SetCReg(0, false, s.c)
+			want[s.cSel] = s.c.Resolve(&m.Palette, &want)
+		}
+		if got := vm.State().CReg; got != want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, want)
+		}
+	}
+}
+
+func TestVMState(t *testing.T) {
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	// The default palette, and so each CREG, is opaque black.
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	testCases := []struct {
+		desc string
+		ops  func(vm *lowlevel.VM)
+		want func(s *lowlevel.VMState)
+	}{{
+		desc: "reset",
+		ops:  func(vm *lowlevel.VM) {},
+		want: func(s *lowlevel.VMState) {},
+	}, {
+		desc: "selectors wrap",
+		ops: func(vm *lowlevel.VM) {
+			vm.SetCSel(65)
+			vm.SetNSel(63)
+			vm.SetNReg(0, true, 1.5)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.CSel, s.NSel = 1, 0
+			s.NReg[63] = 1.5
+		},
+	}, {
+		desc: "set CREG and start a path",
+		ops: func(vm *lowlevel.VM) {
+			vm.SetCSel(3)
+			vm.SetCReg(1, false, lowlevel.RGBAColor(red))
+			vm.SetLOD(0, 64)
+			vm.StartPath(1, 4, 5)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.CSel = 3
+			s.CReg[2] = red
+			s.LOD0, s.LOD1 = 0, 64
+			s.InPath, s.NumPaths, s.PathFill = true, 1, red
+			s.Pen, s.Smooth = f32.Vec2{4, 5}, f32.Vec2{4, 5}
+		},
+	}, {
+		desc: "relative line",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 1, 2)
+			vm.RelLineTo(3, 4)
+			vm.RelHLineTo(1)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{5, 6}, f32.Vec2{5, 6}
+		},
+	}, {
+		desc: "quad reflects its control point",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 0, 0)
+			vm.AbsQuadTo(1, 1, 2, 0)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{2, 0}, f32.Vec2{3, -1}
+		},
+	}, {
+		desc: "smooth quad uses the reflection",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 0, 0)
+			vm.AbsQuadTo(1, 1, 2, 0)
+			vm.AbsSmoothQuadTo(4, 0)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{4, 0}, f32.Vec2{5, 1}
+		},
+	}, {
+		desc: "line resets the reflection",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 0, 0)
+			vm.AbsCubeTo(0, 1, 1, 1, 2, 0)
+			vm.AbsVLineTo(3)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{2, 3}, f32.Vec2{2, 3}
+		},
+	}, {
+		desc: "relative move after close is relative to the pen",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 1, 1)
+			vm.AbsLineTo(5, 5)
+			vm.ClosePathRelMoveTo(1, 1)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{6, 6}, f32.Vec2{6, 6}
+		},
+	}, {
+		desc: "arc",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 1, 1)
+			vm.RelArcTo(2, 2, 0, false, true, 4, 0)
+		},
+		want: func(s *lowlevel.VMState) {
+			s.InPath, s.NumPaths, s.PathFill = true, 1, black
+			s.Pen, s.Smooth = f32.Vec2{5, 1}, f32.Vec2{5, 1}
+		},
+	}, {
+		desc: "two paths",
+		ops: func(vm *lowlevel.VM) {
+			vm.StartPath(0, 1, 1)
+			vm.ClosePathEndPath()
+			vm.StartPath(0, 2, 2)
+			vm.ClosePathEndPath()
+		},
+		want: func(s *lowlevel.VMState) {
+			s.NumPaths, s.PathFill = 2, black
+			s.Pen, s.Smooth = f32.Vec2{2, 2}, f32.Vec2{2, 2}
+		},
+	}}
+
+	m := lowlevel.Metadata{ViewBox: lowlevel.DefaultViewBox, Palette: lowlevel.DefaultPalette}
+	for _, tc := range testCases {
+		vm := lowlevel.NewVM(nil)
+		vm.Reset(m)
+		tc.ops(vm)
+		want := lowlevel.VMState{
+			Metadata: m,
+			CReg:     [64]color.RGBA(m.Palette),
+			LOD1:     float32(math.Inf(+1)),
+		}
+		tc.want(&want)
+		got := vm.State()
+		if g, w := vmSummary(&got), vmSummary(&want); g != w {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.desc, g, w)
+		}
+		if !reflect.DeepEqual(got.Metadata, want.Metadata) || (got.CReg != want.CReg) || (got.NReg != want.NReg) {
+			t.Errorf("%s: metadata or registers differ", tc.desc)
+		}
+	}
+}
+
+// vmSummary formats a VMState's fields other than its metadata and data
+// registers.
+func vmSummary(s *lowlevel.VMState) string {
+	return fmt.Sprintf("CSEL=%d NSEL=%d LOD=[%g, %g) InPath=%t NumPaths=%d PathFill=%v Pen=%v Smooth=%v",
+		s.CSel, s.NSel, s.LOD0, s.LOD1, s.InPath, s.NumPaths, s.PathFill, s.Pen, s.Smooth)
+}
+
+func TestVMForwards(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/cowbell.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &floatRecorder{}
+	if err := lowlevel.Decode(want, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := &floatRecorder{}
+	vm := lowlevel.NewVM(got)
+	maxPaths := 0
+	err = lowlevel.Decode(vm, src, &lowlevel.DecodeOptions{
+		OnInstruction: func(offset int) {
+			if n := vm.State().NumPaths; maxPaths < n {
+				maxPaths = n
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.calls, want.calls) {
+		t.Errorf("forwarded calls differ")
+	}
+	if s := vm.State(); s.NumPaths != maxPaths || s.InPath {
+		t.Errorf("got %d paths (in path: %t), want %d (false)", s.NumPaths, s.InPath, maxPaths)
+	}
+}
+
+func TestDisassembleWithState(t *testing.T) {
+	src, err := os.ReadFile("../../../test/data/action-info.lores.ivg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, withState := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := lowlevel.Disassemble(plain, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := lowlevel.DisassembleWithState(withState, src); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the state lines leaves the plain disassembly.
+	var lines, stateLines []string
+	for _, line := range strings.SplitAfter(withState.String(), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "=>") {
+			stateLines = append(stateLines, line)
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	if got, want := strings.Join(lines, ""), plain.String(); got != want {
+		t.Errorf("without state lines:\ngot  %q\nwant %q", got, want)
+	}
+
+	for _, want := range []string{
+		"=> path 0, filled with RGBA ",
+		"=> pen = (",
+	} {
+		found := false
+		for _, line := range stateLines {
+			found = found || strings.HasPrefix(strings.TrimSpace(line), want)
+		}
+		if !found {
+			t.Errorf("got no %q state line", want)
+		}
+	}
+
+	if err := lowlevel.DisassembleWithState(&bytes.Buffer{}, []byte("\x8a\x49\x56\x48\x00")); err == nil {
+		t.Errorf("bad magic: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package materialgen generates Go packages of Material Design icons, encoded
+// as IconVG, from the icons' upstream sources: either the SVG files of the
+// github.com/google/material-design-icons repository or an icon font, such as
+// MaterialIcons-Regular.ttf, and its codepoints file.
+//
+// The generated package has a string constant per icon, holding its IconVG
+// encoding, and an Index that maps icon names to those constants:
+//
+//	const ActionInfo = "\x89IVG..."
+//
+//	var Index = map[string]string{
+//		"action/info": ActionInfo,
+//		...
+//	}
+//
+// The cmd/gen-material command wraps this package.
+package materialgen
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/iconvg/src/go/font2ivg"
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/svgconv"
+)
+
+var errNoIcons = errors.New("materialgen: no icons found")
+
+// Icon is a Material Design icon.
+type Icon struct {
+	// Name is the icon's slash-separated name. For SVG sources, it is the
+	// icon's category and name, such as "action/info". For font sources, it
+	// is the name in the codepoints file, such as "info".
+	Name string
+
+	// Data is the icon's IconVG encoding.
+	Data []byte
+}
+
+// Options are the optional parameters to FromSVG and FromFont.
+type Options struct {
+	// Style is the directory name, in the SVG sources, of the icon style to
+	// convert, such as "materialiconsoutlined". The empty string means
+	// "materialicons", the filled style. It does not affect FromFont, as
+	// each font file holds one style.
+	Style string
+
+	// Size is the size, in pixels, of the SVG sources' design grid to
+	// convert, as in their "24px.svg" file names. Zero means 24. It does not
+	// affect FromFont.
+	Size int
+
+	// ViewBox is the icons' viewBox. The zero value means to keep the SVG
+	// sources' viewBox, such as 0..24, or, for FromFont, to use
+	// lowlevel.DefaultViewBox.
+	ViewBox lowlevel.Rectangle
+
+	// MaxError, if positive, makes the encoding lossy, as per the
+	// ivg.Encoder's QuantizeCoordinates method, which makes for a smaller
+	// package.
+	MaxError float32
+}
+
+// FromSVG converts the SVG sources in fsys, which is laid out like the "src"
+// directory of the github.com/google/material-design-icons repository, with
+// each icon at category/name/style/sizepx.svg. For example, the filled, 24
+// pixel "info" icon is at "action/info/materialicons/24px.svg". Icons that
+// are missing in the requested style or size are skipped.
+//
+// opts may be nil, which means to use the default options.
+func FromSVG(fsys fs.FS, opts *Options) ([]Icon, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	style, size := opts.Style, opts.Size
+	if style == "" {
+		style = "materialicons"
+	}
+	if size == 0 {
+		size = 24
+	}
+
+	categories, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	icons := []Icon(nil)
+	for _, category := range categories {
+		if !category.IsDir() {
+			continue
+		}
+		names, err := fs.ReadDir(fsys, category.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !name.IsDir() {
+				continue
+			}
+			filename := path.Join(category.Name(), name.Name(), style, strconv.Itoa(size)+"px.svg")
+			src, err := fs.ReadFile(fsys, filename)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			data, err := svgconv.Convert(src, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			if opts.ViewBox != (lowlevel.Rectangle{}) {
+				if data, err = ivg.Refit(data, opts.ViewBox, 0); err != nil {
+					return nil, fmt.Errorf("%s: %v", filename, err)
+				}
+			}
+			if data, err = finish(data, opts); err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			icons = append(icons, Icon{
+				Name: category.Name() + "/" + name.Name(),
+				Data: data,
+			})
+		}
+	}
+	if len(icons) == 0 {
+		return nil, errNoIcons
+	}
+	return icons, nil
+}
+
+// FromFont converts the glyphs of a TrueType icon font that are named in its
+// codepoints file, whose lines each hold an icon's name and its hexadecimal
+// code point, such as "info e88e". Names that share a code point are
+// aliases, each converted separately.
+//
+// opts may be nil, which means to use the default options.
+func FromFont(font []byte, codepoints []byte, opts *Options) ([]Icon, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	f, err := font2ivg.Parse(font)
+	if err != nil {
+		return nil, err
+	}
+	icons := []Icon(nil)
+	s := bufio.NewScanner(bytes.NewReader(codepoints))
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 2 {
+			return nil, fmt.Errorf("materialgen: codepoints line %d: want a name and a code point", line)
+		}
+		r, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("materialgen: codepoints line %d: invalid code point %q", line, fields[1])
+		}
+		glyph := f.GlyphIndex(rune(r))
+		if glyph == 0 {
+			return nil, fmt.Errorf("materialgen: codepoints line %d: font has no glyph for %U", line, rune(r))
+		}
+		data, err := font2ivg.Encode(f, glyph, &font2ivg.Options{ViewBox: opts.ViewBox})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fields[0], err)
+		}
+		if data, err = finish(data, opts); err != nil {
+			return nil, fmt.Errorf("%s: %v", fields[0], err)
+		}
+		icons = append(icons, Icon{Name: fields[0], Data: data})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(icons) == 0 {
+		return nil, errNoIcons
+	}
+	return icons, nil
+}
+
+// finish re-encodes an icon with the optimizing, and possibly lossy, encoder.
+func finish(data []byte, opts *Options) ([]byte, error) {
+	e := &ivg.Encoder{Optimize: true}
+	e.QuantizeCoordinates(opts.MaxError)
+	return e.Reencode(data)
+}
+
+// GoSource returns formatted Go source code, for a package named pkg, that
+// holds the icons as constants and an Index of them. Each constant's name is
+// the icon's name in CamelCase, such as ActionInfo for "action/info", and a
+// name that would start with a digit is prefixed with "Icon". It returns an
+// error if two icons have the same name or constant name.
+func GoSource(pkg string, icons []Icon) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("materialgen: invalid package name %q", pkg)
+	}
+	sorted := append([]Icon(nil), icons...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	idents := make([]string, len(sorted))
+	seen := map[string]string{}
+	for i, ic := range sorted {
+		idents[i] = ident(ic.Name)
+		if other, ok := seen[idents[i]]; ok {
+			return nil, fmt.Errorf("materialgen: icons %q and %q both map to %s", other, ic.Name, idents[i])
+		} else if !token.IsIdentifier(idents[i]) {
+			return nil, fmt.Errorf("materialgen: invalid icon name %q", ic.Name)
+		}
+		seen[idents[i]] = ic.Name
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "// Code generated by gen-material. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+	for i, ic := range sorted {
+		fmt.Fprintf(b, "// %s is the IconVG encoding of the %q icon.\n", idents[i], ic.Name)
+		fmt.Fprintf(b, "const %s = %s\n\n", idents[i], quote(ic.Data))
+	}
+	fmt.Fprintf(b, "// Index maps each icon's name to its IconVG encoding.\n")
+	fmt.Fprintf(b, "var Index = map[string]string{\n")
+	for i, ic := range sorted {
+		fmt.Fprintf(b, "%q: %s,\n", ic.Name, idents[i])
+	}
+	fmt.Fprintf(b, "}\n")
+	return format.Source(b.Bytes())
+}
+
+// ident returns the CamelCase Go identifier for an icon name.
+func ident(name string) string {
+	b := &strings.Builder{}
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		} else if (b.Len() == 0) && unicode.IsDigit(r) {
+			b.WriteString("Icon")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	return b.String()
+}
+
+// quote returns a Go string literal for data, split over several lines. It
+// escapes every byte that is not printable ASCII, unlike strconv.Quote, which
+// keeps valid UTF-8 sequences as is.
+func quote(data []byte) string {
+	const bytesPerLine = 32
+	b := &strings.Builder{}
+	for i := 0; i < len(data); i += bytesPerLine {
+		if i > 0 {
+			b.WriteString(" +\n\t")
+		}
+		j := i + bytesPerLine
+		if j > len(data) {
+			j = len(data)
+		}
+		b.WriteByte('"')
+		for _, c := range data[i:j] {
+			if (c < 0x20) || (c >= 0x7f) || (c == '"') || (c == '\\') {
+				fmt.Fprintf(b, "\\x%02x", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+	}
+	if len(data) == 0 {
+		return `""`
+	}
+	return b.String()
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package materialgen_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/materialgen"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/f32"
+)
+
+const square = `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24" viewBox="0 0 24 24">` +
+	`<path d="M4 4h16v16H4z"/></svg>`
+
+// svgSources returns SVG sources laid out like the material-design-icons
+// repository.
+func svgSources() fstest.MapFS {
+	return fstest.MapFS{
+		"README.md":                                      {Data: []byte("not an icon")},
+		"action/info/materialicons/24px.svg":             {Data: []byte(square)},
+		"action/info/materialiconsoutlined/24px.svg":     {Data: []byte(square)},
+		"action/home/materialicons/24px.svg":             {Data: []byte(square)},
+		"action/home/materialicons/20px.svg":             {Data: []byte(square)},
+		"av/3d_rotation/materialiconsoutlined/24px.svg":  {Data: []byte(square)},
+		"av/3d_rotation/materialiconsoutlined/notes.txt": {Data: []byte("not an icon")},
+		"content/add/materialicons/20px.svg":             {Data: []byte(square)},
+		"content/remove/materialiconsoutlined/20px.svg":  {Data: []byte(square)},
+	}
+}
+
+func TestFromSVG(t *testing.T) {
+	vb := lowlevel.Rectangle{Min: f32.Vec2{-32, -32}, Max: f32.Vec2{32, 32}}
+	testCases := []struct {
+		desc        string
+		opts        *materialgen.Options
+		wantNames   string
+		wantViewBox lowlevel.Rectangle
+	}{{
+		desc:        "default",
+		opts:        nil,
+		wantNames:   "action/home action/info",
+		wantViewBox: lowlevel.Rectangle{Max: f32.Vec2{24, 24}},
+	}, {
+		desc:        "outlined",
+		opts:        &materialgen.Options{Style: "materialiconsoutlined"},
+		wantNames:   "action/info av/3d_rotation",
+		wantViewBox: lowlevel.Rectangle{Max: f32.Vec2{24, 24}},
+	}, {
+		desc:        "size 20",
+		opts:        &materialgen.Options{Size: 20},
+		wantNames:   "action/home content/add",
+		wantViewBox: lowlevel.Rectangle{Max: f32.Vec2{24, 24}},
+	}, {
+		desc:        "viewBox",
+		opts:        &materialgen.Options{ViewBox: vb},
+		wantNames:   "action/home action/info",
+		wantViewBox: vb,
+	}}
+	for _, tc := range testCases {
+		icons, err := materialgen.FromSVG(svgSources(), tc.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		names := []string(nil)
+		for _, ic := range icons {
+			names = append(names, ic.Name)
+			m, err := lowlevel.DecodeMetadata(ic.Data)
+			if err != nil {
+				t.Errorf("%s: %s: %v", tc.desc, ic.Name, err)
+			} else if m.ViewBox != tc.wantViewBox {
+				t.Errorf("%s: %s: viewBox: got %v, want %v", tc.desc, ic.Name, m.ViewBox, tc.wantViewBox)
+			}
+		}
+		if got := strings.Join(names, " "); got != tc.wantNames {
+			t.Errorf("%s: got %q, want %q", tc.desc, got, tc.wantNames)
+		}
+	}
+}
+
+func TestFromSVGErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		fsys fstest.MapFS
+		opts *materialgen.Options
+	}{
+		{"no icons", fstest.MapFS{}, nil},
+		{"no icons in style", svgSources(), &materialgen.Options{Style: "materialiconsround"}},
+		{"invalid SVG", fstest.MapFS{"action/info/materialicons/24px.svg": {Data: []byte("<svg")}}, nil},
+	}
+	for _, tc := range testCases {
+		if _, err := materialgen.FromSVG(tc.fsys, tc.opts); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestFromFont(t *testing.T) {
+	// Names that share a code point are aliases.
+	icons, err := materialgen.FromFont(goregular.TTF, []byte("a 61\n\ncopyright a9\n(c) a9\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string(nil)
+	for _, ic := range icons {
+		names = append(names, ic.Name)
+		m, err := lowlevel.DecodeMetadata(ic.Data)
+		if err != nil {
+			t.Errorf("%s: %v", ic.Name, err)
+		} else if m.ViewBox != lowlevel.DefaultViewBox {
+			t.Errorf("%s: viewBox: got %v, want %v", ic.Name, m.ViewBox, lowlevel.DefaultViewBox)
+		}
+	}
+	if got, want := strings.Join(names, " "), "a copyright (c)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFromFontErrors(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		font       []byte
+		codepoints string
+	}{
+		{"invalid font", []byte("not a font"), "a 61"},
+		{"no icons", goregular.TTF, ""},
+		{"one field", goregular.TTF, "a"},
+		{"three fields", goregular.TTF, "a 61 62"},
+		{"invalid code point", goregular.TTF, "a 6z"},
+		{"missing glyph", goregular.TTF, "a f0000"},
+	}
+	for _, tc := range testCases {
+		if _, err := materialgen.FromFont(tc.font, []byte(tc.codepoints), nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	icons := []materialgen.Icon{
+		{Name: "av/3d_rotation", Data: []byte("\x89IVG\"\\\x00")},
+		{Name: "action/info", Data: []byte(strings.Repeat("0123456789", 7))},
+		{Name: "empty", Data: nil},
+	}
+	src, err := materialgen.GoSource("icons", icons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "data.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v\n%s", err, src)
+	}
+	if f.Name.Name != "icons" {
+		t.Errorf("package: got %q, want %q", f.Name.Name, "icons")
+	}
+
+	// Every constant, whose string literal is split over several lines,
+	// must hold its icon's data.
+	want := map[string]string{
+		"ActionInfo":   string(icons[1].Data),
+		"Av3dRotation": string(icons[0].Data),
+		"Empty":        "",
+	}
+	got := map[string]string{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.ValueSpec); ok && (len(spec.Values) == 1) {
+			if s, ok := concat(spec.Values[0]); ok {
+				got[spec.Names[0].Name] = s
+			}
+		}
+		return true
+	})
+	for name, w := range want {
+		if g, ok := got[name]; !ok {
+			t.Errorf("%s: missing", name)
+		} else if g != w {
+			t.Errorf("%s: got %q, want %q", name, g, w)
+		}
+	}
+	if !strings.Contains(string(src), "\t\"av/3d_rotation\": Av3dRotation,\n") {
+		t.Errorf("Index is missing av/3d_rotation:\n%s", src)
+	}
+	if !strings.HasPrefix(string(src), "// Code generated by gen-material. DO NOT EDIT.\n") {
+		t.Errorf("missing the generated code comment:\n%s", src)
+	}
+}
+
+func TestGoSourceErrors(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		pkg   string
+		names []string
+	}{
+		{"invalid package", "not a package", []string{"a"}},
+		{"same name", "icons", []string{"a", "a"}},
+		{"same constant name", "icons", []string{"action/info", "action_info"}},
+		{"invalid name", "icons", []string{"-"}},
+	}
+	for _, tc := range testCases {
+		icons := []materialgen.Icon(nil)
+		for _, name := range tc.names {
+			icons = append(icons, materialgen.Icon{Name: name})
+		}
+		if _, err := materialgen.GoSource(tc.pkg, icons); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+// concat returns the value of a string literal or of a concatenation of
+// string literals.
+func concat(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		s, err := strconv.Unquote(expr.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		x, ok0 := concat(expr.X)
+		y, ok1 := concat(expr.Y)
+		return x + y, ok0 && ok1 && (expr.Op == token.ADD)
+	}
+	return "", false
+}
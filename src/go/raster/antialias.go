@@ -0,0 +1,149 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Antialiasing is how a Rasterizer computes the coverage of a pixel that a
+// path's edge crosses.
+type Antialiasing uint8
+
+const (
+	// AntialiasingAnalytic computes the exact fraction of each pixel that the
+	// path covers. It gives the best quality, and is the default.
+	AntialiasingAnalytic Antialiasing = iota
+	// AntialiasingNone makes each pixel either fully covered or not, by
+	// whether the path covers at least half of it. It suits displays without
+	// intermediate levels, and pixel art, but shows jagged edges.
+	AntialiasingNone
+	// Antialiasing4x samples each pixel's coverage at a 2×2 grid of
+	// sub-pixels, each of which is AntialiasingNone covered or not.
+	Antialiasing4x
+	// Antialiasing16x samples each pixel's coverage at a 4×4 grid of
+	// sub-pixels, each of which is AntialiasingNone covered or not.
+	Antialiasing16x
+)
+
+var antialiasingNames = [...]string{
+	AntialiasingAnalytic: "analytic",
+	AntialiasingNone:     "none",
+	Antialiasing4x:       "4x",
+	Antialiasing16x:      "16x",
+}
+
+// String returns the mode's name: "analytic", "none", "4x" or "16x".
+func (a Antialiasing) String() string {
+	if int(a) < len(antialiasingNames) {
+		return antialiasingNames[a]
+	}
+	return "invalid"
+}
+
+// SetAntialiasing sets how the Rasterizer anti-aliases edges, which is
+// AntialiasingAnalytic by default.
+//
+// The supersampling modes give the 5 or 17 levels of coverage of renderers,
+// such as GPUs, that use multisample anti-aliasing, so that icons can match
+// their output. They rasterize each path at 4 or 16 times as many pixels,
+// and so are slower than AntialiasingAnalytic. Every mode other than
+// AntialiasingAnalytic rasterizes through an alpha mask, even where the
+// analytic mode would draw directly.
+func (z *Rasterizer) SetAntialiasing(a Antialiasing) {
+	z.antialiasing = a
+	z.ssScale = 1
+	switch a {
+	case Antialiasing4x:
+		z.ssScale = 2
+	case Antialiasing16x:
+		z.ssScale = 4
+	}
+}
+
+// scaledSink scales a path's pixel coordinates by a supersampling factor.
+type scaledSink struct {
+	dst pathSink
+	k   float32
+}
+
+func (s *scaledSink) MoveTo(ax, ay float32) { s.dst.MoveTo(ax*s.k, ay*s.k) }
+func (s *scaledSink) LineTo(bx, by float32) { s.dst.LineTo(bx*s.k, by*s.k) }
+func (s *scaledSink) ClosePath()            { s.dst.ClosePath() }
+
+func (s *scaledSink) QuadTo(bx, by, cx, cy float32) {
+	s.dst.QuadTo(bx*s.k, by*s.k, cx*s.k, cy*s.k)
+}
+
+func (s *scaledSink) CubeTo(bx, by, cx, cy, dx, dy float32) {
+	s.dst.CubeTo(bx*s.k, by*s.k, cx*s.k, cy*s.k, dx*s.k, dy*s.k)
+}
+
+// drawCoverage sets mask, whose size must match the fill rule's rasterizer,
+// to the current path's analytic coverage.
+func (z *Rasterizer) drawCoverage(mask *image.Alpha) {
+	if z.fillRule == lowlevel.FillRuleEvenOdd {
+		z.evenOdd.drawMask(mask)
+	} else {
+		z.z.DrawOp = draw.Src
+		z.z.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+	}
+}
+
+// supersample sets mask to the current path's coverage, sampled at ssScale²
+// sub-pixels per pixel, each of which is covered or not.
+func (z *Rasterizer) supersample(mask *image.Alpha) {
+	k := z.ssScale
+	w, h := mask.Rect.Dx(), mask.Rect.Dy()
+	sub := &z.ssMask
+	if n := w * h * k * k; cap(sub.Pix) < n {
+		sub.Pix = make([]uint8, n)
+	} else {
+		sub.Pix = sub.Pix[:n]
+	}
+	sub.Stride = w * k
+	sub.Rect = image.Rectangle{Max: image.Point{w * k, h * k}}
+	z.drawCoverage(sub)
+
+	n := k * k
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			covered := 0
+			for sy := 0; sy < k; sy++ {
+				row := sub.Pix[(y*k+sy)*sub.Stride+x*k:]
+				for sx := 0; sx < k; sx++ {
+					if row[sx] >= 0x80 {
+						covered++
+					}
+				}
+			}
+			mask.Pix[y*mask.Stride+x] = uint8((covered*0xff + n/2) / n)
+		}
+	}
+}
+
+// threshold makes each of mask's pixels fully covered or not.
+func threshold(mask *image.Alpha) {
+	for i, a := range mask.Pix {
+		if a >= 0x80 {
+			mask.Pix[i] = 0xff
+		} else {
+			mask.Pix[i] = 0x00
+		}
+	}
+}
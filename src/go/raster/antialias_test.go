@@ -0,0 +1,105 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+func TestAntialiasingString(t *testing.T) {
+	testCases := []struct {
+		a    raster.Antialiasing
+		want string
+	}{
+		{raster.AntialiasingAnalytic, "analytic"},
+		{raster.AntialiasingNone, "none"},
+		{raster.Antialiasing4x, "4x"},
+		{raster.Antialiasing16x, "16x"},
+		{raster.Antialiasing16x + 1, "invalid"},
+	}
+	for _, tc := range testCases {
+		if got := tc.a.String(); got != tc.want {
+			t.Errorf("%d: got %q, want %q", tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestAntialiasing(t *testing.T) {
+	// The triangle's diagonal edge crosses pixels at every fraction of
+	// coverage.
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\nviewBox 0 0 48 48\n" +
+		"path [csel] 4 4\nL 44 4 4 41\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// levels returns the coverage levels of n sub-pixel samples.
+	levels := func(n int) map[uint8]bool {
+		m := map[uint8]bool{}
+		for c := 0; c <= n; c++ {
+			m[uint8((c*0xff+n/2)/n)] = true
+		}
+		return m
+	}
+	testCases := []struct {
+		a    raster.Antialiasing
+		want map[uint8]bool
+	}{
+		{raster.AntialiasingAnalytic, nil},
+		{raster.AntialiasingNone, levels(1)},
+		{raster.Antialiasing4x, levels(4)},
+		{raster.Antialiasing16x, levels(16)},
+	}
+	for _, fillRule := range []lowlevel.FillRule{lowlevel.FillRuleNonZero, lowlevel.FillRuleEvenOdd} {
+		for _, tc := range testCases {
+			dst := image.NewRGBA(image.Rect(0, 0, 48, 48))
+			z := &raster.Rasterizer{}
+			z.SetDstImage(dst, dst.Bounds(), draw.Over)
+			z.SetAntialiasing(tc.a)
+			if err := lowlevel.Decode(z, src, &lowlevel.DecodeOptions{FillRule: fillRule}); err != nil {
+				t.Errorf("%v, %v: %v", fillRule, tc.a, err)
+				continue
+			}
+			got := map[uint8]bool{}
+			for i := 3; i < len(dst.Pix); i += 4 {
+				got[dst.Pix[i]] = true
+			}
+			if !got[0x00] || !got[0xff] {
+				t.Errorf("%v, %v: got levels %v, want both 0x00 and 0xff", fillRule, tc.a, got)
+				continue
+			}
+			if tc.want == nil {
+				if len(got) <= len(levels(16)) {
+					t.Errorf("%v, %v: got %d levels, want more than %d", fillRule, tc.a, len(got), len(levels(16)))
+				}
+				continue
+			}
+			for a := range got {
+				if !tc.want[a] {
+					t.Errorf("%v, %v: got level %#02x, want one of %v", fillRule, tc.a, a, tc.want)
+					break
+				}
+			}
+			if (len(tc.want) > 2) && (len(got) <= 2) {
+				t.Errorf("%v, %v: got levels %v, want intermediate levels", fillRule, tc.a, got)
+			}
+		}
+	}
+}
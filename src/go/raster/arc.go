@@ -0,0 +1,35 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"github.com/google/iconvg/src/go/internal/arc"
+	"golang.org/x/image/math/f32"
+)
+
+// arcTo approximates an elliptical arc by one or more cubic Bézier curves.
+func (z *Rasterizer) arcTo(radiusX, radiusY, xAxisRotation float32, largeArc, sweep bool, final f32.Vec2) {
+	arc.ToCubics((*arcSink)(z), z.pen, f32.Vec2{radiusX, radiusY}, xAxisRotation, largeArc, sweep, final, 0)
+	// Like the C implementation, the pen moves to the arc's nominal end point,
+	// not to the approximating curves' end point.
+	z.pen, z.smooth = final, final
+}
+
+// arcSink is an arc.Sink that adds an arc's approximation to a Rasterizer's
+// path.
+type arcSink Rasterizer
+
+func (s *arcSink) LineTo(p f32.Vec2)         { (*Rasterizer)(s).lineTo(p) }
+func (s *arcSink) CubeTo(c0, c1, p f32.Vec2) { (*Rasterizer)(s).cubeToNoSmooth(c0, c1, p) }
@@ -0,0 +1,149 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// benchmarkCorpus is the test/data graphics that the benchmarks run on, from
+// simple to complex: a one-path icon, an illustration with gradients and an
+// illustration with many paths and blends.
+var benchmarkCorpus = []string{
+	"action-info.lores",
+	"cowbell",
+	"favicon",
+}
+
+// benchmarkSizes are the rasterization sizes, in pixels: a typical toolbar
+// icon and a large, high-DPI icon.
+var benchmarkSizes = []int{24, 256}
+
+// BenchmarkRender measures decoding and rasterizing each graphic in the
+// benchmark corpus, at each benchmark size, onto an existing image.
+func BenchmarkRender(b *testing.B) {
+	for _, name := range benchmarkCorpus {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, size := range benchmarkSizes {
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				dst := image.NewRGBA(image.Rect(0, 0, size, size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := raster.Render(dst, dst.Bounds(), src, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRenderReuse is like BenchmarkRender but reuses a Rasterizer, as a
+// GUI toolkit that draws many icons would.
+func BenchmarkRenderReuse(b *testing.B) {
+	for _, name := range benchmarkCorpus {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, size := range benchmarkSizes {
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				dst := image.NewRGBA(image.Rect(0, 0, size, size))
+				z := &raster.Rasterizer{}
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					z.SetDstImage(dst, dst.Bounds(), draw.Over)
+					if err := lowlevel.Decode(z, src, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkSpanRGBA measures compositing an opaque flat color onto a row of an
+// *image.RGBA, through a mask row like those of a typical icon's path, before
+// ("perpixel") and after ("fill8") filling fully covered runs eight pixels at
+// a time.
+func BenchmarkSpanRGBA(b *testing.B) {
+	m := iconRow(200)
+	c := color.RGBA{0x12, 0x80, 0xff, 0xff}
+	for _, bc := range []struct {
+		name string
+		f    func(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool)
+	}{
+		{"perpixel", raster.SpanRGBAPerPixel},
+		{"fill8", raster.SpanRGBA},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := make([]uint8, 4*len(m))
+			b.SetBytes(int64(len(p)))
+			for i := 0; i < b.N; i++ {
+				bc.f(p, m, c, nil, true)
+			}
+		})
+	}
+}
+
+// BenchmarkDrawGradient measures rasterizing a rectangle filled with a linear
+// gradient onto an *image.RGBA. Each row of a "vertical" gradient is one
+// color, so it takes the flat color span functions, whereas a "diagonal"
+// gradient's colors are calculated pixel by pixel.
+func BenchmarkDrawGradient(b *testing.B) {
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0x12, 0x80, 0xff, 0xff})},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0x80, 0x12, 0xff})},
+	}
+	for _, bc := range []struct {
+		name string
+		x2   float32
+		y2   float32
+	}{
+		{"vertical", -32, +32},
+		{"diagonal", +32, +32},
+	} {
+		src, err := ivg.NewBuilder().
+			MoveTo(-30, -30).LineTo(+30, -30).LineTo(+30, +30).LineTo(-30, +30).ClosePath().
+			FillPaint(ivg.LinearGradient(stops, -32, -32, bc.x2, bc.y2, ivg.GradientSpreadPad)).
+			Bytes()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bc.name, func(b *testing.B) {
+			dst := image.NewRGBA(image.Rect(0, 0, 256, 256))
+			z := &raster.Rasterizer{}
+			b.SetBytes(int64(len(dst.Pix)))
+			for i := 0; i < b.N; i++ {
+				z.SetDstImage(dst, dst.Bounds(), draw.Over)
+				if err := lowlevel.Decode(z, src, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f64"
+)
+
+// PathCache holds the coverage masks of a graphic's paths, as rasterized by a
+// Rasterizer, so that the graphic can be drawn again with different colors,
+// such as after a palette change, without flattening and rasterizing its
+// paths again. Only the paint is re-computed: the CREG and NREG values, and
+// from those, the flat color or gradient.
+//
+// A PathCache is filled by the first graphic decoded by a Rasterizer whose
+// SetPathCache method was passed it. Subsequent graphics decoded by that
+// Rasterizer re-use those masks, path by path, instead of the paths' segments,
+// which may therefore be omitted. The graphics must all have the same paths,
+// in the same order, and differ only in their colors. The cache empties
+// itself if the destination rectangle's size, the transformation, the fill
+// rule, the Porter-Duff operator, whether pixel snapping applies or the
+// anti-aliasing mode changes, but not if the paths change: call Reset when
+// switching to a different graphic.
+//
+// The zero value is an empty cache. A PathCache should not be used by more
+// than one Rasterizer at a time.
+type PathCache struct {
+	masks []image.Alpha
+
+	// The masks were rasterized with this size, transformation (which
+	// includes the fitting of the viewBox), fill rule, pixel snapping and
+	// anti-aliasing, and are cropped if drawOp is draw.Over.
+	w, h         int
+	s2d          f64.Aff3
+	fillRule     lowlevel.FillRule
+	drawOp       draw.Op
+	snapping     bool
+	antialiasing Antialiasing
+}
+
+// Reset empties the cache, keeping its buffers for re-use.
+func (c *PathCache) Reset() {
+	c.masks = c.masks[:0]
+}
+
+// validate empties the cache if its masks do not match z's geometry.
+func (c *PathCache) validate(z *Rasterizer) {
+	w, h := z.r.Dx(), z.r.Dy()
+	if (c.w != w) || (c.h != h) || (c.s2d != z.s2d) || (c.fillRule != z.fillRule) || (c.drawOp != z.drawOp) ||
+		(c.snapping != z.snapping) || (c.antialiasing != z.antialiasing) {
+		c.Reset()
+		c.w, c.h, c.s2d, c.fillRule, c.drawOp = w, h, z.s2d, z.fillRule, z.drawOp
+		c.snapping, c.antialiasing = z.snapping, z.antialiasing
+	}
+}
+
+// store sets m to a copy of the full size mask src. If crop is true, the copy
+// is cropped to the bounds of src's non-zero pixels, which is only valid
+// when compositing with the draw.Over operator.
+func (c *PathCache) store(m *image.Alpha, src *image.Alpha, crop bool) {
+	b := src.Rect
+	if crop {
+		b = opaqueBounds(src)
+	}
+	w, h := b.Dx(), b.Dy()
+	if n := w * h; cap(m.Pix) < n {
+		m.Pix = make([]uint8, n)
+	} else {
+		m.Pix = m.Pix[:n]
+	}
+	m.Stride = w
+	m.Rect = b
+	for y := 0; y < h; y++ {
+		i := src.PixOffset(b.Min.X, b.Min.Y+y)
+		copy(m.Pix[y*w:(y+1)*w], src.Pix[i:i+w])
+	}
+}
+
+// opaqueBounds returns the smallest rectangle holding m's non-zero pixels.
+func opaqueBounds(m *image.Alpha) image.Rectangle {
+	r := image.Rectangle{}
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		i := m.PixOffset(m.Rect.Min.X, y)
+		row := m.Pix[i : i+m.Rect.Dx()]
+		x0, x1 := 0, len(row)
+		for ; (x0 < x1) && (row[x0] == 0); x0++ {
+		}
+		for ; (x1 > x0) && (row[x1-1] == 0); x1-- {
+		}
+		if x0 < x1 {
+			r = r.Union(image.Rect(m.Rect.Min.X+x0, y, m.Rect.Min.X+x1, y+1))
+		}
+	}
+	return r
+}
+
+// SetPathCache sets the cache for the masks of the paths of subsequently
+// decoded graphics. Nil, the default, means to not cache them.
+func (z *Rasterizer) SetPathCache(c *PathCache) {
+	z.cache = c
+}
+
+// startCachedPath looks up the current path in z.cache. If the cache holds
+// the path's mask then the path's segments are ignored. Otherwise, the path
+// is added to the cache, and is rasterized if it is within the level of detail
+// bounds, even if its paint is invalid or transparent, as it may not be when
+// the graphic is drawn again with other colors.
+func (z *Rasterizer) startCachedPath(lod bool) {
+	c := z.cache
+	i := z.pathIndex
+	z.pathIndex++
+	if i < len(c.masks) {
+		z.cached = true
+		return
+	}
+	if n := len(c.masks); n < cap(c.masks) {
+		c.masks = c.masks[:n+1]
+	} else {
+		c.masks = append(c.masks, image.Alpha{})
+	}
+	c.masks[i].Rect = image.Rectangle{}
+	z.disabled = !lod
+}
+
+// discardSink is the pathSink for paths whose masks come from a PathCache.
+type discardSink struct{}
+
+func (discardSink) MoveTo(ax, ay float32)                 {}
+func (discardSink) LineTo(bx, by float32)                 {}
+func (discardSink) QuadTo(bx, by, cx, cy float32)         {}
+func (discardSink) CubeTo(bx, by, cx, cy, dx, dy float32) {}
+func (discardSink) ClosePath()                            {}
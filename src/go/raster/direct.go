@@ -0,0 +1,98 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// RasterizeMask rasterizes the IconVG graphic src to a new *image.Alpha whose
+// bounds are r, stretching the graphic's viewBox to fill r. Each pixel is the
+// graphic's coverage (its alpha), regardless of its colors. Such a mask can
+// tint an icon with a color chosen at draw time, for example by passing it as
+// the mask to draw.DrawMask with an *image.Uniform source.
+//
+// opts may be nil, which means to use the default options.
+func RasterizeMask(r image.Rectangle, src []byte, opts *lowlevel.DecodeOptions) (*image.Alpha, error) {
+	dst := image.NewAlpha(r)
+	if err := Render(dst, r, src, opts); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// isDirectDst returns whether drawDirect composites directly onto dst's
+// pixels. For other image types, the vector.Rasterizer and the image/draw
+// package call the At and Set methods, which allocate, for every pixel.
+func isDirectDst(dst draw.Image) bool {
+	switch dst.(type) {
+	case *image.RGBA, *image.Alpha, *image.Gray, *image.NRGBA:
+		return true
+	}
+	return false
+}
+
+// drawDirect composites the current path's paint, a flat color or a
+// gradient, through mask onto z.dst's rectangle z.r (or the part of it that
+// is the mask's bounds), for a destination image type that isDirectDst
+// accepts. The arithmetic is the image/draw package's, with 16 bits per
+// channel, but it works a row (a span) of pixels at a time. See span.go.
+func (z *Rasterizer) drawDirect(mask *image.Alpha) {
+	over := z.drawOp == draw.Over
+	b := mask.Rect
+	w := b.Dx()
+
+	// colors, for a gradient, holds the colors of the row's pixels.
+	colors := []color.RGBA(nil)
+	if z.fill == &z.gradient {
+		if cap(z.spanColors) < w {
+			z.spanColors = make([]color.RGBA, w)
+		}
+		colors = z.spanColors[:w]
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := mask.PixOffset(b.Min.X, y)
+		m := mask.Pix[i : i+w]
+		c, cs := z.flatColor, colors
+		if colors != nil {
+			if rc, ok := z.gradient.rowColor(y); ok {
+				c, cs = rc, nil
+			} else {
+				z.gradient.span(colors, m, b.Min.X, y, over)
+			}
+		}
+		px, py := z.r.Min.X+b.Min.X, z.r.Min.Y+y
+
+		switch dst := z.dst.(type) {
+		case *image.RGBA:
+			i := dst.PixOffset(px, py)
+			spanRGBA(dst.Pix[i:i+4*w], m, c, cs, over)
+		case *image.NRGBA:
+			i := dst.PixOffset(px, py)
+			spanNRGBA(dst.Pix[i:i+4*w], m, c, cs, over)
+		case *image.Alpha:
+			i := dst.PixOffset(px, py)
+			spanAlpha(dst.Pix[i:i+w], m, c, cs, over)
+		case *image.Gray:
+			i := dst.PixOffset(px, py)
+			spanGray(dst.Pix[i:i+w], m, c, cs, over)
+		}
+	}
+}
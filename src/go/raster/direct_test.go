@@ -0,0 +1,113 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// slowImage hides the concrete type of its embedded draw.Image, so that the
+// Rasterizer composites onto it through the image/draw package, instead of
+// directly.
+type slowImage struct {
+	draw.Image
+}
+
+// TestDirect checks that compositing directly onto *image.Alpha, *image.Gray,
+// *image.NRGBA and *image.RGBA images gives the same pixels as compositing
+// through the image/draw package. For draw.Over, the vector.Rasterizer
+// rounds its coverage differently, so they can differ by one level per
+// channel, or by two after converting to non-premultiplied alpha.
+func TestDirect(t *testing.T) {
+	r := image.Rect(0, 0, 48, 48)
+	background := color.NRGBA{0x40, 0x80, 0xc0, 0x80}
+	testCases := []struct {
+		desc      string
+		newImg    func() (draw.Image, []uint8)
+		tolerance int
+	}{
+		{"Alpha", func() (draw.Image, []uint8) { m := image.NewAlpha(r); return m, m.Pix }, 1},
+		{"Gray", func() (draw.Image, []uint8) { m := image.NewGray(r); return m, m.Pix }, 1},
+		{"NRGBA", func() (draw.Image, []uint8) { m := image.NewNRGBA(r); return m, m.Pix }, 2},
+		{"RGBA", func() (draw.Image, []uint8) { m := image.NewRGBA(r); return m, m.Pix }, 1},
+	}
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range testCases {
+			for _, op := range []draw.Op{draw.Over, draw.Src} {
+				got, gotPix := tc.newImg()
+				want, wantPix := tc.newImg()
+				draw.Draw(got, r, image.NewUniform(background), image.Point{}, draw.Src)
+				draw.Draw(want, r, image.NewUniform(background), image.Point{}, draw.Src)
+
+				z := &raster.Rasterizer{}
+				z.SetDstImage(got, r, op)
+				if err := lowlevel.Decode(z, src, nil); err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				z.SetDstImage(slowImage{want}, r, op)
+				if err := lowlevel.Decode(z, src, nil); err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				for i := range gotPix {
+					if d := int(gotPix[i]) - int(wantPix[i]); (d < -tc.tolerance) || (tc.tolerance < d) {
+						t.Errorf("%s, %s, op=%d: byte #%d: got %#02x, want %#02x",
+							filename, tc.desc, op, i, gotPix[i], wantPix[i])
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRasterizeMask(t *testing.T) {
+	r := image.Rect(0, 0, 48, 48)
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := raster.RasterizeMask(r, src, nil)
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		want := image.NewRGBA(r)
+		if err := raster.Render(want, r, src, nil); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if got.Bounds() != r {
+			t.Errorf("%s: bounds: got %v, want %v", filename, got.Bounds(), r)
+			continue
+		}
+		for i, a := range got.Pix {
+			if w := want.Pix[4*i+3]; a != w {
+				t.Errorf("%s: pixel (%d, %d): got alpha %#02x, want %#02x", filename, i%48, i/48, a, w)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// SetFillRule sets the rule for filling subsequent paths. It is
+// lowlevel.FillRuleNonZero by default, as the specification says, and
+// lowlevel.Decode resets it to the DecodeOptions' FillRule.
+//
+// The vector.Rasterizer that fills non-zero paths does not implement the
+// even-odd rule, so even-odd paths are rasterized by this package's own port
+// of its algorithm, which is slower.
+func (z *Rasterizer) SetFillRule(r lowlevel.FillRule) {
+	z.fillRule = r
+}
+
+// pathSink is implemented by the vector.Rasterizer, by evenOdd and by
+// flattener.
+type pathSink interface {
+	MoveTo(ax, ay float32)
+	LineTo(bx, by float32)
+	QuadTo(bx, by, cx, cy float32)
+	CubeTo(bx, by, cx, cy, dx, dy float32)
+	ClosePath()
+}
+
+// sink returns where the current path's segments, in pixel coordinates, go.
+func (z *Rasterizer) sink() pathSink {
+	if z.cached {
+		return discardSink{}
+	} else if z.snapping {
+		return &z.snap
+	}
+	return z.fillSink()
+}
+
+// fillSink returns the rasterizer for the current fill rule, scaling the
+// path when supersampling, or the flattener for a PathFiller.
+func (z *Rasterizer) fillSink() pathSink {
+	if z.filler != nil {
+		return &z.flat
+	}
+	dst := pathSink(&z.z)
+	if z.fillRule == lowlevel.FillRuleEvenOdd {
+		dst = &z.evenOdd
+	}
+	if k := z.supersampling(); k > 1 {
+		z.scaled = scaledSink{dst: dst, k: float32(k)}
+		return &z.scaled
+	}
+	return dst
+}
+
+// evenOdd rasterizes a path with the even-odd fill rule. It follows the
+// vector.Rasterizer's floating point algorithm, accumulating each pixel's
+// signed area coverage, except that an accumulated coverage c becomes an
+// alpha of (c mod 2), reflected about 1, instead of |c| clamped to 1.
+type evenOdd struct {
+	w, h  int
+	buf   []float32
+	first f32.Vec2
+	pen   f32.Vec2
+}
+
+func (e *evenOdd) Reset(w, h int) {
+	e.w, e.h = w, h
+	if n := w * h; cap(e.buf) < n {
+		e.buf = make([]float32, n)
+	} else {
+		e.buf = e.buf[:n]
+		for i := range e.buf {
+			e.buf[i] = 0
+		}
+	}
+	e.first, e.pen = f32.Vec2{}, f32.Vec2{}
+}
+
+func (e *evenOdd) MoveTo(ax, ay float32) {
+	e.first = f32.Vec2{ax, ay}
+	e.pen = e.first
+}
+
+func (e *evenOdd) ClosePath() {
+	e.LineTo(e.first[0], e.first[1])
+}
+
+// QuadTo and CubeTo flatten curves into the same number of lines as the
+// vector.Rasterizer does.
+func (e *evenOdd) QuadTo(bx, by, cx, cy float32) {
+	flattenQuad(e, e.pen, f32.Vec2{bx, by}, f32.Vec2{cx, cy})
+}
+
+func (e *evenOdd) CubeTo(bx, by, cx, cy, dx, dy float32) {
+	flattenCube(e, e.pen, f32.Vec2{bx, by}, f32.Vec2{cx, cy}, f32.Vec2{dx, dy})
+}
+
+// flattenQuad calls s.LineTo for the lines that approximate the quadratic
+// Bézier curve (a, b, c), where a is s's pen position.
+func flattenQuad(s pathSink, a, b, c f32.Vec2) {
+	if n := flattenCount(devSquared(a, b, c)); n > 1 {
+		for i := 1; i < n; i++ {
+			t := float32(i) / float32(n)
+			p := lerpVec2(t, lerpVec2(t, a, b), lerpVec2(t, b, c))
+			s.LineTo(p[0], p[1])
+		}
+	}
+	s.LineTo(c[0], c[1])
+}
+
+// flattenCube is like flattenQuad but for the cubic Bézier curve (a, b, c,
+// d).
+func flattenCube(s pathSink, a, b, c, d f32.Vec2) {
+	if n := flattenCount(float32(math.Max(float64(devSquared(a, b, d)), float64(devSquared(a, c, d))))); n > 1 {
+		for i := 1; i < n; i++ {
+			t := float32(i) / float32(n)
+			ab, bc, cd := lerpVec2(t, a, b), lerpVec2(t, b, c), lerpVec2(t, c, d)
+			p := lerpVec2(t, lerpVec2(t, ab, bc), lerpVec2(t, bc, cd))
+			s.LineTo(p[0], p[1])
+		}
+	}
+	s.LineTo(d[0], d[1])
+}
+
+// flattenCount returns how many lines approximate a curve whose deviation
+// (see devSquared) is the square root of devsq.
+func flattenCount(devsq float32) int {
+	if devsq < 0.333 {
+		return 1
+	}
+	const tol = 3
+	return 1 + int(math.Sqrt(math.Sqrt(tol*float64(devsq))))
+}
+
+// devSquared returns a measure of how curvy the sequence (a, b, c) is.
+func devSquared(a, b, c f32.Vec2) float32 {
+	dx := a[0] - 2*b[0] + c[0]
+	dy := a[1] - 2*b[1] + c[1]
+	return dx*dx + dy*dy
+}
+
+// lerpVec2 interpolates between p and q.
+func lerpVec2(t float32, p, q f32.Vec2) f32.Vec2 {
+	return f32.Vec2{p[0] + t*(q[0]-p[0]), p[1] + t*(q[1]-p[1])}
+}
+
+// LineTo accumulates the signed area coverage of the line from the pen to b.
+func (e *evenOdd) LineTo(bx, by float32) {
+	ax, ay := e.pen[0], e.pen[1]
+	e.pen = f32.Vec2{bx, by}
+	dir := float32(1)
+	if ay > by {
+		dir, ax, ay, bx, by = -1, bx, by, ax, ay
+	}
+	// Like the vector.Rasterizer, treat almost horizontal lines as
+	// horizontal, which yield no change in coverage.
+	if by-ay <= 0.000001 {
+		return
+	}
+	dxdy := (bx - ax) / (by - ay)
+
+	x := ax
+	y := int(math.Floor(float64(ay)))
+	yMax := int(math.Ceil(float64(by)))
+	if yMax > e.h {
+		yMax = e.h
+	}
+	width := e.w
+
+	for ; y < yMax; y++ {
+		dy := float32(math.Min(float64(y+1), float64(by)) - math.Max(float64(y), float64(ay)))
+		xNext := x + float32(dy*dxdy)
+		if y < 0 {
+			x = xNext
+			continue
+		}
+		buf := e.buf[y*width:]
+		d := float32(dy * dir)
+		x0, x1 := x, xNext
+		if x > xNext {
+			x0, x1 = x1, x0
+		}
+		x0i := int(math.Floor(float64(x0)))
+		x0Floor := float32(x0i)
+		x1i := int(math.Ceil(float64(x1)))
+		x1Ceil := float32(x1i)
+
+		if x1i <= x0i+1 {
+			xmf := float32(0.5*(x+xNext)) - x0Floor
+			if i := clampIndex(x0i+0, width); i < len(buf) {
+				buf[i] += d - float32(d*xmf)
+			}
+			if i := clampIndex(x0i+1, width); i < len(buf) {
+				buf[i] += float32(d * xmf)
+			}
+		} else {
+			s := 1 / (x1 - x0)
+			x0f := x0 - x0Floor
+			oneMinusX0f := 1 - x0f
+			a0 := float32(0.5 * s * oneMinusX0f * oneMinusX0f)
+			x1f := x1 - x1Ceil + 1
+			am := float32(0.5 * s * x1f * x1f)
+
+			if i := clampIndex(x0i, width); i < len(buf) {
+				buf[i] += float32(d * a0)
+			}
+			if x1i == x0i+2 {
+				if i := clampIndex(x0i+1, width); i < len(buf) {
+					buf[i] += float32(d * (1 - a0 - am))
+				}
+			} else {
+				a1 := float32(s * (1.5 - x0f))
+				if i := clampIndex(x0i+1, width); i < len(buf) {
+					buf[i] += float32(d * (a1 - a0))
+				}
+				dTimesS := float32(d * s)
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					if i := clampIndex(xi, width); i < len(buf) {
+						buf[i] += dTimesS
+					}
+				}
+				a2 := a1 + float32(s*float32(x1i-x0i-3))
+				if i := clampIndex(x1i-1, width); i < len(buf) {
+					buf[i] += float32(d * (1 - a2 - am))
+				}
+			}
+			if i := clampIndex(x1i, width); i < len(buf) {
+				buf[i] += float32(d * am)
+			}
+		}
+		x = xNext
+	}
+}
+
+// clampIndex clamps i to the range [0, width].
+func clampIndex(i, width int) int {
+	if i < 0 {
+		return 0
+	} else if i > width {
+		return width
+	}
+	return i
+}
+
+// drawMask sets mask, whose bounds must be the same size as the path's, to
+// the path's coverage.
+func (e *evenOdd) drawMask(mask *image.Alpha) {
+	// almost256 scales [0, 1] to [0x00, 0xff], as for the vector.Rasterizer.
+	const almost256 = 255.99998
+
+	acc := float32(0)
+	for y := 0; y < e.h; y++ {
+		row := mask.Pix[y*mask.Stride : y*mask.Stride+e.w]
+		for x, v := range e.buf[y*e.w : (y+1)*e.w] {
+			acc += v
+			a := float32(math.Mod(math.Abs(float64(acc)), 2))
+			if a > 1 {
+				a = 2 - a
+			}
+			row[x] = uint8(almost256 * a)
+		}
+	}
+}
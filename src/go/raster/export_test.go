@@ -0,0 +1,53 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import "image/color"
+
+// SpanRGBA exports spanRGBA for the external tests and benchmarks.
+var SpanRGBA = spanRGBA
+
+// SpanRGBAPerPixel is spanRGBA without its filling of fully covered runs
+// eight pixels at a time: it sets one pixel at a time. It is the reference
+// that SpanRGBA is tested and benchmarked against.
+func SpanRGBAPerPixel(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool) {
+	p = p[:4*len(m)]
+	for x := 0; x < len(m); x++ {
+		if over {
+			if x = skipZeros(m, x); x == len(m) {
+				break
+			}
+		}
+		if colors != nil {
+			c = colors[x]
+		}
+		q := p[4*x : 4*x+4 : 4*x+4]
+		if (m[x] == 0xff) && ((c.A == 0xff) || !over) {
+			q[0], q[1], q[2], q[3] = c.R, c.G, c.B, c.A
+			continue
+		}
+		sr, sg, sb, sa, ma, a := weights(c, m[x], over)
+		q[0] = uint8(((uint32(q[0])*0x101*a + sr*ma) / 0xffff) >> 8)
+		q[1] = uint8(((uint32(q[1])*0x101*a + sg*ma) / 0xffff) >> 8)
+		q[2] = uint8(((uint32(q[2])*0x101*a + sb*ma) / 0xffff) >> 8)
+		q[3] = uint8(((uint32(q[3])*0x101*a + sa*ma) / 0xffff) >> 8)
+	}
+}
+
+// SnapEdges and Warp export snapEdges and warp for the external tests.
+var (
+	SnapEdges = snapEdges
+	Warp      = warp
+)
@@ -0,0 +1,252 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f64"
+)
+
+const (
+	gradientShapeLinear = 0
+	gradientShapeRadial = 1
+)
+
+const (
+	gradientSpreadNone    = 0
+	gradientSpreadPad     = 1
+	gradientSpreadReflect = 2
+	gradientSpreadRepeat  = 3
+)
+
+// gradient is an image.Image whose colors are those of an IconVG gradient.
+// Its pixel coordinates are those of the Rasterizer's destination rectangle,
+// relative to that rectangle's top-left corner.
+type gradient struct {
+	shape  uint8
+	spread uint8
+
+	// pix2pat maps from pixel coordinates to pattern coordinates, where
+	// linear gradients range from x=0 to x=1 and radial gradients are the
+	// unit circle centered on the origin.
+	pix2pat f64.Aff3
+
+	nStops  int
+	offsets [64]float64
+	colors  [64]color.RGBA
+
+	// linear is whether to interpolate in linear light, in which case
+	// linColors holds the colors' alpha-premultiplied linear light values.
+	linear    bool
+	linColors [64][4]float32
+
+	// quantization is the Rasterizer's GradientQuantization.
+	quantization GradientQuantization
+}
+
+// initGradient initializes z.gradient from a CREG value that describes a
+// gradient, and from the NREG and other CREG values that it refers to.
+func (z *Rasterizer) initGradient(c color.RGBA) {
+	g := &z.gradient
+	g.shape = (c.B >> 6) & 0x01
+	g.spread = c.G >> 6
+	g.nStops = int(c.R & 0x3f)
+	cBase := c.G & 0x3f
+	nBase := c.B & 0x3f
+	for i := 0; i < g.nStops; i++ {
+		g.offsets[i] = float64(z.nReg[(nBase+uint8(i))&0x3f])
+		g.colors[i] = z.cReg[(cBase+uint8(i))&0x3f]
+	}
+	g.linear = z.interpolation == InterpolationLinear
+	if g.linear {
+		for i, c := range g.colors[:g.nStops] {
+			g.linColors[i] = toLinear(c)
+		}
+	}
+
+	// The gradient's matrix maps from the graphic's coordinate space to
+	// pattern coordinate space. Compose it with the mapping from pixel
+	// coordinates to the graphic's coordinate space, the inverse of s2d.
+	s2p := f64.Aff3{}
+	for i := range s2p {
+		s2p[i] = float64(z.nReg[(nBase-6+uint8(i))&0x3f])
+	}
+	d2s, _ := lowlevel.InvertAff3(z.s2d)
+	g.pix2pat = mul(&s2p, &d2s)
+}
+
+func (g *gradient) ColorModel() color.Model { return color.RGBAModel }
+
+func (g *gradient) Bounds() image.Rectangle {
+	return image.Rectangle{
+		Min: image.Point{-1e9, -1e9},
+		Max: image.Point{+1e9, +1e9},
+	}
+}
+
+func (g *gradient) At(x, y int) color.Color {
+	return g.quantize(g.rgbaAt(float64(x)+0.5, float64(y)+0.5), x, y)
+}
+
+// RGBA64At implements the image.RGBA64Image interface, which lets the
+// image/draw package composite the gradient without allocating per pixel.
+func (g *gradient) RGBA64At(x, y int) color.RGBA64 {
+	c := g.quantize(g.rgbaAt(float64(x)+0.5, float64(y)+0.5), x, y)
+	return color.RGBA64{
+		R: uint16(c.R) * 0x101,
+		G: uint16(c.G) * 0x101,
+		B: uint16(c.B) * 0x101,
+		A: uint16(c.A) * 0x101,
+	}
+}
+
+func (g *gradient) rgbaAt(x, y float64) color.RGBA {
+	if g.nStops == 0 {
+		return color.RGBA{}
+	}
+	m := &g.pix2pat
+	px := m[0]*x + m[1]*y + m[2]
+	t := px
+	if g.shape == gradientShapeRadial {
+		py := m[3]*x + m[4]*y + m[5]
+		t = math.Sqrt(px*px + py*py)
+	}
+
+	switch g.spread {
+	case gradientSpreadNone:
+		if t < 0 || 1 < t {
+			return color.RGBA{}
+		}
+	case gradientSpreadPad:
+		// No-op. Offsets beyond the first or last stop take that stop's color.
+	case gradientSpreadReflect:
+		t = math.Abs(math.Mod(t, 2))
+		if t > 1 {
+			t = 2 - t
+		}
+	case gradientSpreadRepeat:
+		t -= math.Floor(t)
+	}
+	if !(t == t) {
+		return color.RGBA{}
+	}
+
+	if t <= g.offsets[0] {
+		return g.colors[0]
+	}
+	n := g.nStops
+	if t >= g.offsets[n-1] {
+		return g.colors[n-1]
+	}
+	for i := 1; i < n; i++ {
+		o0, o1 := g.offsets[i-1], g.offsets[i]
+		if t >= o1 {
+			continue
+		}
+		// Interpolate in alpha-premultiplied color space.
+		f := (t - o0) / (o1 - o0)
+		if g.linear {
+			return fromLinear(float32(f), &g.linColors[i-1], &g.linColors[i])
+		}
+		c0, c1 := g.colors[i-1], g.colors[i]
+		return color.RGBA{
+			R: lerp(f, c0.R, c1.R),
+			G: lerp(f, c0.G, c1.G),
+			B: lerp(f, c0.B, c1.B),
+			A: lerp(f, c0.A, c1.A),
+		}
+	}
+	return g.colors[n-1]
+}
+
+func lerp(f float64, a, b uint8) uint8 {
+	return uint8(float64(a)*(1-f) + float64(b)*f + 0.5)
+}
+
+// srgbToLinearTable maps 8-bit sRGB values to linear light.
+var srgbToLinearTable = func() (t [256]float32) {
+	for i := range t {
+		v := float64(i) / 0xff
+		if v <= 0.04045 {
+			t[i] = float32(v / 12.92)
+		} else {
+			t[i] = float32(math.Pow((v+0.055)/1.055, 2.4))
+		}
+	}
+	return t
+}()
+
+// linearToSRGBTable maps linear light, quantized to 12 bits, to sRGB values
+// in the range [0, 1].
+var linearToSRGBTable = func() (t [4096]float32) {
+	for i := range t {
+		v := float64(i) / 4095
+		if v <= 0.0031308 {
+			t[i] = float32(v * 12.92)
+		} else {
+			t[i] = float32(1.055*math.Pow(v, 1/2.4) - 0.055)
+		}
+	}
+	return t
+}()
+
+// toLinear converts an alpha-premultiplied sRGB color to alpha-premultiplied
+// linear light.
+func toLinear(c color.RGBA) [4]float32 {
+	if c.A == 0 {
+		return [4]float32{}
+	}
+	a := float32(c.A) / 0xff
+	ch := func(u uint8) float32 {
+		if u >= c.A {
+			return a
+		}
+		return srgbToLinearTable[(uint32(u)*0xff+uint32(c.A)/2)/uint32(c.A)] * a
+	}
+	return [4]float32{ch(c.R), ch(c.G), ch(c.B), a}
+}
+
+// fromLinear interpolates between two alpha-premultiplied linear light
+// colors, and converts the result to alpha-premultiplied sRGB.
+func fromLinear(f float32, c0, c1 *[4]float32) color.RGBA {
+	a := c0[3]*(1-f) + c1[3]*f
+	if !(a > 0) {
+		return color.RGBA{}
+	}
+	ch := func(k int) uint8 {
+		v := (c0[k]*(1-f) + c1[k]*f) / a
+		if !(v < 1) {
+			v = 1
+		}
+		return uint8(linearToSRGBTable[int(v*4095+0.5)]*a*0xff + 0.5)
+	}
+	return color.RGBA{ch(0), ch(1), ch(2), uint8(a*0xff + 0.5)}
+}
+
+// mul returns the affine transformation that applies b and then a.
+func mul(a, b *f64.Aff3) f64.Aff3 {
+	return f64.Aff3{
+		a[0]*b[0] + a[1]*b[3],
+		a[0]*b[1] + a[1]*b[4],
+		a[0]*b[2] + a[1]*b[5] + a[2],
+		a[3]*b[0] + a[4]*b[3],
+		a[3]*b[1] + a[4]*b[4],
+		a[3]*b[2] + a[4]*b[5] + a[5],
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f64"
+)
+
+func TestGradientSpread(t *testing.T) {
+	// The gradient goes from opaque black at offset 0 to opaque white at
+	// offset 1. Pixel x (or, for a radial gradient, pixel distance x from the
+	// origin) is at offset x/100.
+	g := gradient{
+		pix2pat: f64.Aff3{0.01, 0, 0, 0, 0.01, 0},
+		nStops:  2,
+		offsets: [64]float64{0, 1},
+		colors: [64]color.RGBA{
+			{0x00, 0x00, 0x00, 0xff},
+			{0xff, 0xff, 0xff, 0xff},
+		},
+	}
+	clear := color.RGBA{}
+	gray := func(y uint8) color.RGBA { return color.RGBA{y, y, y, 0xff} }
+
+	testCases := []struct {
+		shape  uint8
+		spread uint8
+		x      float64
+		want   color.RGBA
+	}{
+		{gradientShapeLinear, gradientSpreadNone, -25, clear},
+		{gradientShapeLinear, gradientSpreadNone, +50, gray(0x80)},
+		{gradientShapeLinear, gradientSpreadNone, 125, clear},
+		{gradientShapeLinear, gradientSpreadPad, -25, gray(0x00)},
+		{gradientShapeLinear, gradientSpreadPad, +50, gray(0x80)},
+		{gradientShapeLinear, gradientSpreadPad, 125, gray(0xff)},
+		{gradientShapeLinear, gradientSpreadReflect, -25, gray(0x40)},
+		{gradientShapeLinear, gradientSpreadReflect, +50, gray(0x80)},
+		{gradientShapeLinear, gradientSpreadReflect, 125, gray(0xbf)},
+		{gradientShapeLinear, gradientSpreadRepeat, -25, gray(0xbf)},
+		{gradientShapeLinear, gradientSpreadRepeat, +50, gray(0x80)},
+		{gradientShapeLinear, gradientSpreadRepeat, 125, gray(0x40)},
+		{gradientShapeRadial, gradientSpreadNone, 150, clear},
+		{gradientShapeRadial, gradientSpreadPad, 150, gray(0xff)},
+		{gradientShapeRadial, gradientSpreadReflect, 150, gray(0x80)},
+		{gradientShapeRadial, gradientSpreadReflect, 225, gray(0x40)},
+		{gradientShapeRadial, gradientSpreadRepeat, 150, gray(0x80)},
+		{gradientShapeRadial, gradientSpreadRepeat, 225, gray(0x40)},
+	}
+	for _, tc := range testCases {
+		g.shape, g.spread = tc.shape, tc.spread
+		if got := g.rgbaAt(tc.x, 0); got != tc.want {
+			t.Errorf("shape=%d, spread=%d, x=%g: got %v, want %v", tc.shape, tc.spread, tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestGradientQuantization(t *testing.T) {
+	// is565 returns whether c is an RGB565 color, expanded to 8 bits.
+	is565 := func(c color.RGBA) bool {
+		return (c.R>>5 == c.R&0x07) && (c.G>>6 == c.G&0x03) && (c.B>>5 == c.B&0x07)
+	}
+
+	for v := 0; v < 0x100; v++ {
+		g := gradient{quantization: GradientQuantizationRGB565}
+		c := color.RGBA{uint8(v), uint8(v), uint8(v), 0xff}
+		if got := g.quantize(c, 0, 0); !is565(got) {
+			t.Errorf("v=0x%02x: rgb565: got %v, not an RGB565 color", v, got)
+		}
+
+		// Over a 4×4 block, the dithered colors average to the original
+		// color, to within a quarter of a 5-bit step.
+		g.quantization = GradientQuantizationRGB565Dither
+		sum := 0
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				got := g.quantize(c, x, y)
+				if !is565(got) {
+					t.Fatalf("v=0x%02x, x=%d, y=%d: rgb565-dither: got %v, not an RGB565 color", v, x, y, got)
+				}
+				sum += int(got.R)
+			}
+		}
+		if mean := float64(sum) / 16; math.Abs(mean-float64(v)) > 255.0/31/4 {
+			t.Errorf("v=0x%02x: rgb565-dither: mean red %g is too far", v, mean)
+		}
+	}
+}
+
+func TestGradientRowColor(t *testing.T) {
+	// The gradient goes from opaque black at offset 0 to opaque white at
+	// offset 1, over 100 pixels.
+	g := gradient{
+		nStops:  2,
+		offsets: [64]float64{0, 1},
+		colors: [64]color.RGBA{
+			{0x00, 0x00, 0x00, 0xff},
+			{0xff, 0xff, 0xff, 0xff},
+		},
+	}
+	testCases := []struct {
+		desc         string
+		shape        uint8
+		pix2pat      f64.Aff3
+		quantization GradientQuantization
+		wantOK       bool
+	}{
+		{"vertical", gradientShapeLinear, f64.Aff3{0, 0.01, 0, 0, 0, 0}, GradientQuantizationNone, true},
+		{"vertical, rgb565", gradientShapeLinear, f64.Aff3{0, 0.01, 0, 0, 0, 0}, GradientQuantizationRGB565, true},
+		{"vertical, rgb565-dither", gradientShapeLinear, f64.Aff3{0, 0.01, 0, 0, 0, 0}, GradientQuantizationRGB565Dither, false},
+		{"horizontal", gradientShapeLinear, f64.Aff3{0.01, 0, 0, 0, 0, 0}, GradientQuantizationNone, false},
+		{"radial", gradientShapeRadial, f64.Aff3{0, 0.01, 0, 0, 0, 0}, GradientQuantizationNone, false},
+	}
+	for _, tc := range testCases {
+		g.shape, g.pix2pat, g.quantization = tc.shape, tc.pix2pat, tc.quantization
+		for _, y := range []int{-10, 0, 37, 150} {
+			c, ok := g.rowColor(y)
+			if ok != tc.wantOK {
+				t.Errorf("%s, y=%d: got ok=%t, want %t", tc.desc, y, ok, tc.wantOK)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			for _, x := range []int{-5, 0, 63} {
+				if want := g.At(x, y).(color.RGBA); c != want {
+					t.Errorf("%s, x=%d, y=%d: got %v, want %v", tc.desc, x, y, c, want)
+				}
+			}
+		}
+	}
+}
+
+// TestGradientGolden checks rasterizing the gradient-heavy test/data files,
+// which use every spread, against their PNG renderings.
+func TestGradientGolden(t *testing.T) {
+	for _, name := range []string{"gradient", "gradient-spreads"} {
+		src, err := os.ReadFile("../../../test/data/" + name + ".ivg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open("../../../test/data/" + name + ".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := want.Bounds()
+		got := image.NewRGBA(b)
+		z := &Rasterizer{}
+		z.SetDstImage(got, b, draw.Over)
+		if err := lowlevel.Decode(z, src, nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// The PNG files' rasterizers may round differently, so allow a small
+		// difference in each (alpha-premultiplied) channel.
+		const tolerance = 2
+		nBad := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c0 := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+				c1 := got.RGBAAt(x, y)
+				if !near(c0.R, c1.R, tolerance) || !near(c0.G, c1.G, tolerance) ||
+					!near(c0.B, c1.B, tolerance) || !near(c0.A, c1.A, tolerance) {
+					if nBad++; nBad <= 5 {
+						t.Errorf("%s: pixel (%d, %d): got %v, want %v", name, x, y, c1, c0)
+					}
+				}
+			}
+		}
+	}
+}
+
+func near(a, b, tolerance uint8) bool {
+	if a < b {
+		a, b = b, a
+	}
+	return a-b <= tolerance
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+func init() {
+	image.RegisterFormat("iconvg", "\x89IVG", Decode, DecodeConfig)
+}
+
+// DefaultSize is the size, in pixels, of the square that Decode and
+// DecodeConfig fit images into. The image's width or height, whichever is
+// larger, equals DefaultSize. The other dimension preserves the aspect ratio
+// of the graphic's viewBox.
+//
+// IconVG graphics are scalable, so there is no intrinsic image size. Programs
+// that use image.Decode can set DefaultSize before decoding.
+var DefaultSize = 64
+
+// Decode reads an IconVG graphic from r and rasterizes it to an *image.RGBA
+// whose size is determined by DefaultSize.
+func Decode(r io.Reader) (image.Image, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		return nil, err
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: size(&m)})
+	if err := Render(dst, dst.Bounds(), src, nil); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DecodeConfig returns the color model and dimensions of the image that
+// Decode would return, without rasterizing the IconVG graphic.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	m, err := lowlevel.DecodeMetadataReader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	sz := size(&m)
+	return image.Config{
+		ColorModel: color.RGBAModel,
+		Width:      sz.X,
+		Height:     sz.Y,
+	}, nil
+}
+
+// size returns the size of an image that fits the viewBox into a square of
+// DefaultSize pixels.
+func size(m *lowlevel.Metadata) image.Point {
+	n := DefaultSize
+	if n <= 0 {
+		return image.Point{}
+	}
+	dx, dy := m.ViewBox.AspectRatio()
+	if !(dx > 0) || !(dy > 0) {
+		return image.Point{n, n}
+	}
+	if dx >= dy {
+		return image.Point{n, clampSize(float64(n) * float64(dy) / float64(dx))}
+	}
+	return image.Point{clampSize(float64(n) * float64(dx) / float64(dy)), n}
+}
+
+func clampSize(f float64) int {
+	if i := int(math.Round(f)); i > 1 {
+		return i
+	}
+	return 1
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/vector"
+)
+
+// Path is a flattened path: a sequence of polygons, each implicitly closed.
+// Its coordinates are pixel coordinates, relative to the top-left corner of
+// the Rasterizer's destination rectangle.
+type Path struct {
+	// Points are the polygons' vertices. The i'th polygon is
+	// Points[Ends[i-1]:Ends[i]], where Ends[-1] is taken to be zero. Every
+	// polygon has at least three vertices.
+	Points []f32.Vec2
+	Ends   []int
+
+	// FillRule is the rule that the polygons are filled with.
+	FillRule lowlevel.FillRule
+}
+
+// PathFiller fills a Rasterizer's paths, in place of the Rasterizer's own
+// coverage calculation and compositing. See Rasterizer.SetPathFiller.
+type PathFiller interface {
+	// FillPath fills p with paint, which is either an *image.Uniform, for a
+	// flat color, or a gradient. paint's coordinates are p's. Both are only
+	// valid during the call, as the Rasterizer re-uses them for the next
+	// path.
+	FillPath(p *Path, paint image.Image)
+}
+
+// SetPathFiller sets the Rasterizer to pass each path that it would draw,
+// flattened to polygons, and the path's paint to f, instead of drawing them
+// onto its destination image, which may then be nil. Nil, the default, means
+// for the Rasterizer to draw paths itself.
+//
+// The Rasterizer still fits the graphic to the destination rectangle, applies
+// the level of detail bounds, the transform and pixel snapping, and resolves
+// colors and gradients, but how the polygons are anti-aliased and composited
+// is up to f. The Antialiasing setting, the draw.Op and any PathCache are
+// ignored.
+func (z *Rasterizer) SetPathFiller(f PathFiller) {
+	z.filler = f
+}
+
+// FillPath fills p with paint, compositing onto dst with the given Porter-Duff
+// operator, the way that a Rasterizer with analytic anti-aliasing and no
+// PathFiller does. p's and paint's coordinates are relative to the top-left
+// corner of dst's bounds.
+//
+// It lets a PathFiller fall back to software rasterization.
+func FillPath(dst draw.Image, p *Path, paint image.Image, op draw.Op) {
+	b := dst.Bounds()
+	if b.Empty() || (len(p.Ends) == 0) {
+		return
+	}
+	w, h := b.Dx(), b.Dy()
+	f := pathFills.Get().(*pathFill)
+	defer pathFills.Put(f)
+
+	sink := pathSink(&f.z)
+	if p.FillRule == lowlevel.FillRuleEvenOdd {
+		f.evenOdd.Reset(w, h)
+		sink = &f.evenOdd
+	} else {
+		f.z.Reset(w, h)
+	}
+	start := 0
+	for _, end := range p.Ends {
+		q := p.Points[start:end]
+		sink.MoveTo(q[0][0], q[0][1])
+		for _, v := range q[1:] {
+			sink.LineTo(v[0], v[1])
+		}
+		sink.ClosePath()
+		start = end
+	}
+
+	mask := &f.mask
+	if n := w * h; cap(mask.Pix) < n {
+		mask.Pix = make([]uint8, n)
+	} else {
+		mask.Pix = mask.Pix[:n]
+	}
+	mask.Stride = w
+	mask.Rect = image.Rectangle{Max: image.Point{w, h}}
+	if p.FillRule == lowlevel.FillRuleEvenOdd {
+		f.evenOdd.drawMask(mask)
+	} else {
+		f.z.DrawOp = draw.Src
+		f.z.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+	}
+	draw.DrawMask(dst, b, paint, image.Point{}, mask, image.Point{}, op)
+}
+
+// pathFill is FillPath's scratch space, which is re-used from call to call.
+type pathFill struct {
+	z       vector.Rasterizer
+	evenOdd evenOdd
+	mask    image.Alpha
+}
+
+var pathFills = sync.Pool{
+	New: func() interface{} { return &pathFill{} },
+}
+
+// flattener is a pathSink that records a path, flattened to polygons, for a
+// PathFiller.
+type flattener struct {
+	path  Path
+	first f32.Vec2
+	pen   f32.Vec2
+}
+
+func (f *flattener) reset(r lowlevel.FillRule) {
+	f.path.Points = f.path.Points[:0]
+	f.path.Ends = f.path.Ends[:0]
+	f.path.FillRule = r
+}
+
+// start returns the index of the current polygon's first vertex.
+func (f *flattener) start() int {
+	if n := len(f.path.Ends); n > 0 {
+		return f.path.Ends[n-1]
+	}
+	return 0
+}
+
+func (f *flattener) MoveTo(ax, ay float32) {
+	f.ClosePath()
+	f.first = f32.Vec2{ax, ay}
+	f.pen = f.first
+	f.path.Points = append(f.path.Points, f.pen)
+}
+
+func (f *flattener) LineTo(bx, by float32) {
+	// A LineTo after a ClosePath, without a MoveTo, starts a new polygon at
+	// the previous one's first vertex.
+	if len(f.path.Points) == f.start() {
+		f.path.Points = append(f.path.Points, f.pen)
+	}
+	f.pen = f32.Vec2{bx, by}
+	f.path.Points = append(f.path.Points, f.pen)
+}
+
+func (f *flattener) QuadTo(bx, by, cx, cy float32) {
+	flattenQuad(f, f.pen, f32.Vec2{bx, by}, f32.Vec2{cx, cy})
+}
+
+func (f *flattener) CubeTo(bx, by, cx, cy, dx, dy float32) {
+	flattenCube(f, f.pen, f32.Vec2{bx, by}, f32.Vec2{cx, cy}, f32.Vec2{dx, dy})
+}
+
+// ClosePath ends the current polygon, dropping it if it has fewer than three
+// vertices, as it then has no area.
+func (f *flattener) ClosePath() {
+	start := f.start()
+	if n := len(f.path.Points); n-start >= 3 {
+		f.path.Ends = append(f.path.Ends, n)
+	} else {
+		f.path.Points = f.path.Points[:start]
+	}
+	f.pen = f.first
+}
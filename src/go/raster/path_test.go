@@ -0,0 +1,131 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+	"golang.org/x/image/math/f32"
+)
+
+func TestFillPath(t *testing.T) {
+	// outer and inner are two squares with the same winding, so that the
+	// inner one is a hole under the even-odd fill rule.
+	outer := []f32.Vec2{{2, 2}, {14, 2}, {14, 14}, {2, 14}}
+	inner := []f32.Vec2{{6, 6}, {10, 6}, {10, 10}, {6, 10}}
+	nested := append(append([]f32.Vec2(nil), outer...), inner...)
+	testCases := []struct {
+		desc     string
+		path     raster.Path
+		origin   image.Point
+		wantEdge uint8
+		wantHole uint8
+	}{
+		{"empty", raster.Path{}, image.Point{}, 0x00, 0x00},
+		{"square", raster.Path{Points: outer, Ends: []int{4}}, image.Point{}, 0xff, 0xff},
+		{"square, offset dst", raster.Path{Points: outer, Ends: []int{4}}, image.Point{10, 20}, 0xff, 0xff},
+		{"nested, non-zero", raster.Path{Points: nested, Ends: []int{4, 8}}, image.Point{}, 0xff, 0xff},
+		{"nested, even-odd", raster.Path{Points: nested, Ends: []int{4, 8}, FillRule: lowlevel.FillRuleEvenOdd}, image.Point{}, 0xff, 0x00},
+	}
+	for _, tc := range testCases {
+		r := image.Rectangle{Min: tc.origin, Max: tc.origin.Add(image.Point{16, 16})}
+		dst := image.NewRGBA(r)
+		raster.FillPath(dst, &tc.path, image.NewUniform(color.RGBA{0x00, 0x00, 0xff, 0xff}), draw.Over)
+		for _, p := range []struct {
+			x, y int
+			want uint8
+		}{
+			{1, 1, 0x00},
+			{3, 3, tc.wantEdge},
+			{8, 8, tc.wantHole},
+			{15, 15, 0x00},
+		} {
+			if got := dst.RGBAAt(tc.origin.X+p.x, tc.origin.Y+p.y); (got.B != p.want) || (got.A != p.want) {
+				t.Errorf("%s: pixel (%d, %d): got %v, want alpha %#02x", tc.desc, p.x, p.y, got, p.want)
+			}
+		}
+	}
+}
+
+// checkingFiller is a raster.PathFiller that checks each Path's invariants
+// before filling it with FillPath.
+type checkingFiller struct {
+	t        *testing.T
+	dst      *image.RGBA
+	fillRule lowlevel.FillRule
+	n        int
+}
+
+func (f *checkingFiller) FillPath(p *raster.Path, paint image.Image) {
+	f.n++
+	if p.FillRule != f.fillRule {
+		f.t.Errorf("path %d: FillRule: got %v, want %v", f.n, p.FillRule, f.fillRule)
+	}
+	start := 0
+	for _, end := range p.Ends {
+		if end-start < 3 {
+			f.t.Errorf("path %d: polygon has %d vertices, want at least 3", f.n, end-start)
+		}
+		start = end
+	}
+	if start != len(p.Points) {
+		f.t.Errorf("path %d: last end: got %d, want %d", f.n, start, len(p.Points))
+	}
+	raster.FillPath(f.dst, p, paint, draw.Over)
+}
+
+func TestSetPathFiller(t *testing.T) {
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "elliptical.ivg", "gradient.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fillRule := range []lowlevel.FillRule{lowlevel.FillRuleNonZero, lowlevel.FillRuleEvenOdd} {
+			r := image.Rect(0, 0, 64, 64)
+			opts := &lowlevel.DecodeOptions{FillRule: fillRule}
+			want := image.NewRGBA(r)
+			z := &raster.Rasterizer{}
+			z.SetDstImage(want, r, draw.Over)
+			if err := lowlevel.Decode(z, src, opts); err != nil {
+				t.Fatalf("%s: %v", filename, err)
+			}
+
+			f := &checkingFiller{t: t, dst: image.NewRGBA(r), fillRule: fillRule}
+			z = &raster.Rasterizer{}
+			z.SetDstImage(nil, r, draw.Over)
+			z.SetPathFiller(f)
+			if err := lowlevel.Decode(z, src, opts); err != nil {
+				t.Fatalf("%s: %v", filename, err)
+			}
+			if f.n == 0 {
+				t.Errorf("%s: no paths were filled", filename)
+			}
+			// Flattening curves to polygons makes for small differences.
+			for i := range want.Pix {
+				if d := int(want.Pix[i]) - int(f.dst.Pix[i]); (d < -8) || (8 < d) {
+					t.Errorf("%s, %v: pixel (%d, %d): got %v, want %v", filename, fillRule,
+						(i/4)%64, (i/4)/64, f.dst.Pix[i], want.Pix[i])
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"image/color"
+)
+
+// GradientQuantization is how a Rasterizer quantizes gradients' colors for
+// displays with fewer than 8 bits per channel.
+type GradientQuantization uint8
+
+const (
+	// GradientQuantizationNone leaves gradients' colors at 8 bits per
+	// channel. It is the default.
+	GradientQuantizationNone GradientQuantization = iota
+	// GradientQuantizationRGB565 rounds each gradient color to the nearest
+	// RGB565 color: 5 bits of red, 6 of green and 5 of blue. The gradient is
+	// banded, but predictably so, unlike converting its 8-bit colors later.
+	GradientQuantizationRGB565
+	// GradientQuantizationRGB565Dither quantizes each gradient color to
+	// RGB565 with ordered (4×4 Bayer matrix) dithering, which turns bands
+	// into a fine, regular pattern.
+	GradientQuantizationRGB565Dither
+)
+
+var gradientQuantizationNames = [...]string{
+	GradientQuantizationNone:         "none",
+	GradientQuantizationRGB565:       "rgb565",
+	GradientQuantizationRGB565Dither: "rgb565-dither",
+}
+
+// String returns the mode's name: "none", "rgb565" or "rgb565-dither".
+func (q GradientQuantization) String() string {
+	if int(q) < len(gradientQuantizationNames) {
+		return gradientQuantizationNames[q]
+	}
+	return "invalid"
+}
+
+// SetGradientQuantization sets how the Rasterizer quantizes gradients'
+// colors, which is GradientQuantizationNone by default.
+//
+// For e-ink and embedded displays, whose RGB565 or similar frame buffers
+// band smooth 8-bit gradients badly, quantizing (and dithering) while
+// rasterizing gives the best gradients that the display can show. Only
+// gradients are quantized: flat colors are left alone, as are anti-aliased
+// edges. A translucent gradient color is quantized before its alpha is
+// premultiplied, so that, when drawn over an opaque RGB565 background, the
+// result is close to, but not necessarily exactly, an RGB565 color.
+func (z *Rasterizer) SetGradientQuantization(q GradientQuantization) {
+	z.gradient.quantization = q
+}
+
+// bayer4 is the 4×4 Bayer matrix, whose elements are thresholds in units of
+// 1/16.
+var bayer4 = [4][4]uint8{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// quantize quantizes c, the gradient's color at pixel (x, y).
+func (g *gradient) quantize(c color.RGBA, x int, y int) color.RGBA {
+	if (g.quantization == GradientQuantizationNone) || (c.A == 0x00) {
+		return c
+	}
+	// t is the rounding threshold, in units of 1/32: 16 (one half) for plain
+	// rounding, or a Bayer matrix threshold, centered in its 1/16 step.
+	t := uint32(16)
+	if g.quantization == GradientQuantizationRGB565Dither {
+		t = 2*uint32(bayer4[y&3][x&3]) + 1
+	}
+	if c.A == 0xff {
+		return color.RGBA{
+			R: quantizeChannel(c.R, 5, t),
+			G: quantizeChannel(c.G, 6, t),
+			B: quantizeChannel(c.B, 5, t),
+			A: 0xff,
+		}
+	}
+	// Un-premultiply, quantize and re-premultiply.
+	a := uint32(c.A)
+	unpre := func(v uint8) uint8 { return uint8((uint32(v)*0xff + a/2) / a) }
+	pre := func(v uint8) uint8 { return uint8((uint32(v)*a + 0x7f) / 0xff) }
+	return color.RGBA{
+		R: pre(quantizeChannel(unpre(c.R), 5, t)),
+		G: pre(quantizeChannel(unpre(c.G), 6, t)),
+		B: pre(quantizeChannel(unpre(c.B), 5, t)),
+		A: c.A,
+	}
+}
+
+// quantizeChannel quantizes the 8-bit v to n bits, rounding up if its
+// fractional part, in units of 1/32, is at least t. It returns the 8-bit
+// equivalent of the quantized value, replicating its high bits into its low
+// bits.
+func quantizeChannel(v uint8, n uint, t uint32) uint8 {
+	levels := uint32(1)<<n - 1
+	scaled := uint32(v) * levels * 32 / 0xff
+	q := scaled / 32
+	if scaled%32 >= t {
+		q++
+	}
+	return uint8(q<<(8-n) | q>>(2*n-8))
+}
@@ -0,0 +1,583 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package raster renders IconVG graphics to pixels.
+//
+// Importing this package also registers the IconVG format with the standard
+// library's image package, so that image.Decode can decode IconVG graphics.
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/vector"
+)
+
+var positiveInfinity = float32(math.Inf(+1))
+
+// Render renders the IconVG graphic src onto the rectangle r of dst, using
+// the draw.Over operator. The graphic's viewBox is stretched to fill r. Use a
+// Rasterizer for other aspect ratio policies and for other transformations.
+//
+// opts may be nil, which means to use the default options.
+func Render(dst draw.Image, r image.Rectangle, src []byte, opts *lowlevel.DecodeOptions) error {
+	z := &Rasterizer{}
+	z.SetDstImage(dst, r, draw.Over)
+	return lowlevel.Decode(z, src, opts)
+}
+
+// WithPalette returns options, for Render or for a Rasterizer's decoding, that
+// render with the custom palette pal instead of the graphic's suggested
+// palette. This can theme an IconVG graphic without re-encoding it.
+//
+// lowlevel.LoadPartialPalette builds a full palette from a partial one.
+func WithPalette(pal [64]color.RGBA) *lowlevel.DecodeOptions {
+	p := lowlevel.Palette(pal)
+	return &lowlevel.DecodeOptions{Palette: &p}
+}
+
+// AspectRatio is a policy for fitting a graphic's viewBox to a destination
+// rectangle whose aspect ratio may differ. It corresponds to SVG's
+// preserveAspectRatio attribute, with the viewBox centered in the rectangle
+// (SVG's xMidYMid alignment).
+type AspectRatio uint8
+
+const (
+	// AspectRatioStretch scales the viewBox non-uniformly to fill the
+	// destination rectangle exactly. It is SVG's "none".
+	AspectRatioStretch AspectRatio = iota
+	// AspectRatioMeet scales the viewBox uniformly to be as large as possible
+	// while fitting entirely within the destination rectangle.
+	AspectRatioMeet
+	// AspectRatioSlice scales the viewBox uniformly to be as small as possible
+	// while covering the entire destination rectangle. The parts of the
+	// graphic outside of the rectangle are clipped.
+	AspectRatioSlice
+)
+
+// Interpolation is the color space in which blend colors and gradients
+// interpolate between colors.
+type Interpolation uint8
+
+const (
+	// InterpolationSRGB interpolates between sRGB color values, as the
+	// specification says.
+	InterpolationSRGB Interpolation = iota
+	// InterpolationLinear interpolates in linear light, which gives lighter
+	// midpoints, matching what most design tools produce.
+	InterpolationLinear
+)
+
+// Rasterizer is a lowlevel.Destination that draws to a destination image. It
+// is also a lowlevel.FillRuleDestination.
+//
+// The zero value is usable, in that it has no destination image, but
+// SetDstImage must be called before the Rasterizer is passed to
+// lowlevel.Decode.
+//
+// A Rasterizer reuses its buffers from one graphic to the next. Reusing a
+// Rasterizer and a lowlevel.Decoder to render many graphics to an
+// *image.RGBA, *image.NRGBA, *image.Alpha or *image.Gray makes no heap
+// allocations once those buffers are large enough.
+type Rasterizer struct {
+	z vector.Rasterizer
+
+	// evenOdd replaces z when fillRule is lowlevel.FillRuleEvenOdd.
+	evenOdd  evenOdd
+	fillRule lowlevel.FillRule
+
+	dst    draw.Image
+	r      image.Rectangle
+	drawOp draw.Op
+
+	aspectRatio   AspectRatio
+	interpolation Interpolation
+
+	// transform, if hasTransform, is applied after fitting the viewBox to r.
+	transform    f64.Aff3
+	hasTransform bool
+
+	// s2d maps from the graphic's (source) coordinate space to the (local)
+	// pixel coordinate space of the destination rectangle, whose origin is
+	// r.Min. lodHeight is the height, in pixels, of the fitted viewBox.
+	s2d       f64.Aff3
+	lodHeight float32
+
+	metadata lowlevel.Metadata
+	lod0     float32
+	lod1     float32
+	cSel     uint8
+	nSel     uint8
+	cReg     [64]color.RGBA
+	nReg     [64]float32
+
+	// disabled is whether the current path is outside of the level of detail
+	// bounds, or has an invalid paint, and so should not be drawn. painted is
+	// the opposite, except that a path being added to a PathCache is
+	// rasterized, but not drawn, even if its paint is invalid.
+	disabled bool
+	painted  bool
+
+	// cache, if non-nil, holds the masks of the paths drawn so far, indexed
+	// by pathIndex. cached is whether the current path's mask comes from the
+	// cache, in which case its segments are ignored.
+	cache     *PathCache
+	pathIndex int
+	cached    bool
+
+	// fill is the current path's paint: either &flatImage or &gradient.
+	// flatImage.C points to flatColor, so that changing the color does not
+	// allocate.
+	fill      image.Image
+	flatImage image.Uniform
+	flatColor color.RGBA
+	gradient  gradient
+
+	// mask is scratch space, reused from path to path, for compositing paint
+	// through. spanColors is scratch space for a row of gradient colors.
+	mask       image.Alpha
+	spanColors []color.RGBA
+
+	// antialiasing is the SetAntialiasing mode. ssScale is its supersampling
+	// factor in each dimension, or 1 if it does not supersample, in which
+	// case ssMask and scaled are unused. A path's segments are scaled, by
+	// scaled, to fill the ssMask scratch space.
+	antialiasing Antialiasing
+	ssScale      int
+	ssMask       image.Alpha
+	scaled       scaledSink
+
+	// snapMaxSize is the SetPixelSnapping bound. snapping is whether it
+	// applies to the current transform, in which case each path is recorded
+	// in snap before being rasterized.
+	snapMaxSize int
+	snapping    bool
+	snap        snapper
+
+	// filler, if non-nil, fills each path, which is recorded in flat, in
+	// place of the rest of the Rasterizer's machinery. See SetPathFiller.
+	filler PathFiller
+	flat   flattener
+
+	// pen and smooth are in the graphic's coordinate space. pen is the
+	// current point. smooth is the implicit control point for a subsequent
+	// smooth quadTo or cubeTo.
+	pen    f32.Vec2
+	smooth f32.Vec2
+}
+
+// SetDstImage sets the Rasterizer to draw onto the rectangle r of dst, using
+// the given Porter-Duff operator. The graphic's viewBox is fit to r according
+// to the Rasterizer's AspectRatio, which is AspectRatioStretch by default.
+func (z *Rasterizer) SetDstImage(dst draw.Image, r image.Rectangle, drawOp draw.Op) {
+	z.dst = dst
+	z.drawOp = drawOp
+	z.SetDstRect(r)
+}
+
+// SetDstRect sets the destination rectangle that the graphic's viewBox is fit
+// to. Drawing is clipped to this rectangle.
+//
+// Like the other Set methods, it should be called before decoding, not during.
+func (z *Rasterizer) SetDstRect(r image.Rectangle) {
+	if r.Empty() {
+		r = image.Rectangle{}
+	}
+	z.r = r
+	z.recalcTransform()
+}
+
+// SetAspectRatio sets the policy for fitting the graphic's viewBox to the
+// destination rectangle.
+func (z *Rasterizer) SetAspectRatio(a AspectRatio) {
+	z.aspectRatio = a
+	z.recalcTransform()
+}
+
+// SetInterpolation sets the color space for interpolating blend colors and
+// gradients, which is InterpolationSRGB by default.
+func (z *Rasterizer) SetInterpolation(i Interpolation) {
+	z.interpolation = i
+}
+
+// SetTransform sets an additional affine transformation, applied after the
+// graphic's viewBox is fit to the destination rectangle. It maps from and to
+// the destination image's pixel coordinate space, so that, for example, a
+// rotation about the center of the destination rectangle rotates the
+// graphic in place. Drawing is still clipped to the destination rectangle.
+//
+// The default is the identity transformation.
+func (z *Rasterizer) SetTransform(t f64.Aff3) {
+	z.transform = t
+	z.hasTransform = t != f64.Aff3{1, 0, 0, 0, 1, 0}
+	z.recalcTransform()
+}
+
+// recalcTransform recalculates s2d after a change to the destination
+// rectangle, the aspect ratio policy, the transform or the metadata's
+// viewBox.
+func (z *Rasterizer) recalcTransform() {
+	vb := &z.metadata.ViewBox
+	vw, vh := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	rw, rh := float64(z.r.Dx()), float64(z.r.Dy())
+	sx, sy := 1.0, 1.0
+	if vw > 0 && vh > 0 {
+		sx, sy = rw/vw, rh/vh
+		switch z.aspectRatio {
+		case AspectRatioMeet:
+			sx = math.Min(sx, sy)
+			sy = sx
+		case AspectRatioSlice:
+			sx = math.Max(sx, sy)
+			sy = sx
+		}
+	} else {
+		vw, vh = 0, 0
+	}
+
+	// fit maps the viewBox to the center of r, in dst's pixel coordinates.
+	fit := f64.Aff3{
+		sx, 0, float64(z.r.Min.X) + (rw-vw*sx)/2 - float64(vb.Min[0])*sx,
+		0, sy, float64(z.r.Min.Y) + (rh-vh*sy)/2 - float64(vb.Min[1])*sy,
+	}
+	if z.hasTransform {
+		fit = mul(&z.transform, &fit)
+	}
+	fit[2] -= float64(z.r.Min.X)
+	fit[5] -= float64(z.r.Min.Y)
+	z.s2d = fit
+	z.lodHeight = float32(vh * sy)
+	z.snapping = (z.snapMaxSize > 0) && (z.heightInPixels() <= float32(z.snapMaxSize)) &&
+		(fit[1] == 0) && (fit[3] == 0)
+}
+
+// heightInPixels is the height that the level of detail bounds are compared
+// against: the height of the fitted viewBox, before any additional transform.
+func (z *Rasterizer) heightInPixels() float32 {
+	if z.lodHeight > 0 {
+		return z.lodHeight
+	}
+	return float32(z.r.Dy())
+}
+
+// maxPixelCoordinate bounds the projected coordinates passed to the
+// vector.Rasterizer, whose fixed point arithmetic overflows (and whose curve
+// flattening slows down) for enormous coordinates. Clamping only distorts
+// geometry that is very far outside of the destination rectangle.
+const maxPixelCoordinate = 1 << 18
+
+// project maps a point in the graphic's coordinate space to the destination
+// rectangle's pixel coordinate space.
+func (z *Rasterizer) project(p f32.Vec2) (x, y float32) {
+	px, py := float64(p[0]), float64(p[1])
+	return clampPixelCoordinate(z.s2d[0]*px + z.s2d[1]*py + z.s2d[2]),
+		clampPixelCoordinate(z.s2d[3]*px + z.s2d[4]*py + z.s2d[5])
+}
+
+// clampPixelCoordinate clamps f to ±maxPixelCoordinate. NaN becomes zero.
+func clampPixelCoordinate(f float64) float32 {
+	if f != f {
+		return 0
+	} else if f < -maxPixelCoordinate {
+		return -maxPixelCoordinate
+	} else if f > +maxPixelCoordinate {
+		return +maxPixelCoordinate
+	}
+	return float32(f)
+}
+
+func (z *Rasterizer) Reset(m lowlevel.Metadata) {
+	z.metadata = m
+	z.lod0 = 0
+	z.lod1 = positiveInfinity
+	z.cSel = 0
+	z.nSel = 0
+	z.cReg = m.Palette
+	z.nReg = [64]float32{}
+	z.recalcTransform()
+	z.pathIndex = 0
+	if z.cache != nil {
+		z.cache.validate(z)
+	}
+}
+
+func (z *Rasterizer) SetCSel(cSel uint8) { z.cSel = cSel & 0x3f }
+func (z *Rasterizer) SetNSel(nSel uint8) { z.nSel = nSel & 0x3f }
+
+func (z *Rasterizer) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	if z.interpolation == InterpolationLinear {
+		z.cReg[(z.cSel-adj)&0x3f] = c.ResolveLinear(&z.metadata.Palette, &z.cReg)
+	} else {
+		z.cReg[(z.cSel-adj)&0x3f] = c.Resolve(&z.metadata.Palette, &z.cReg)
+	}
+	if incr {
+		z.cSel = (z.cSel + 1) & 0x3f
+	}
+}
+
+func (z *Rasterizer) SetNReg(adj uint8, incr bool, f float32) {
+	z.nReg[(z.nSel-adj)&0x3f] = f
+	if incr {
+		z.nSel = (z.nSel + 1) & 0x3f
+	}
+}
+
+func (z *Rasterizer) SetLOD(lod0, lod1 float32) {
+	z.lod0, z.lod1 = lod0, lod1
+}
+
+func (z *Rasterizer) StartPath(adj uint8, x, y float32) {
+	h := z.heightInPixels()
+	lod := z.lod0 <= h && h < z.lod1
+	z.painted = lod && z.initPaint(z.cReg[(z.cSel-adj)&0x3f])
+	z.disabled = !z.painted
+	z.cached = false
+	if (z.cache != nil) && (z.filler == nil) {
+		z.startCachedPath(lod)
+	}
+	if !z.disabled && !z.cached {
+		if z.snapping {
+			z.snap.reset()
+		}
+		k := z.supersampling()
+		if z.filler != nil {
+			z.flat.reset(z.fillRule)
+		} else if z.fillRule == lowlevel.FillRuleEvenOdd {
+			z.evenOdd.Reset(k*z.r.Dx(), k*z.r.Dy())
+		} else {
+			z.z.Reset(k*z.r.Dx(), k*z.r.Dy())
+			z.z.DrawOp = z.drawOp
+		}
+	}
+	z.moveTo(f32.Vec2{x, y})
+}
+
+// initPaint sets z.fill from a CREG value, which is either a flat color or a
+// gradient. It returns false if the value is neither.
+func (z *Rasterizer) initPaint(c color.RGBA) bool {
+	if validAlphaPremulColor(c) {
+		z.flatColor = c
+		z.flatImage.C = &z.flatColor
+		z.fill = &z.flatImage
+		return c.A != 0x00 || z.drawOp != draw.Over
+	}
+	if (c.A == 0x00) && (c.B&0x80 != 0) {
+		z.initGradient(c)
+		z.fill = &z.gradient
+		return true
+	}
+	return false
+}
+
+func (z *Rasterizer) ClosePathEndPath() {
+	if z.disabled {
+		return
+	} else if z.cached {
+		if z.dst != nil {
+			z.composite(&z.cache.masks[z.pathIndex-1])
+		}
+		return
+	}
+	z.sink().ClosePath()
+	if z.snapping {
+		z.snap.replay(z.fillSink())
+	}
+	if z.filler != nil {
+		z.filler.FillPath(&z.flat.path, z.fill)
+		return
+	} else if z.cache != nil {
+		z.rasterizeMask(&z.mask)
+		m := &z.cache.masks[z.pathIndex-1]
+		z.cache.store(m, &z.mask, z.drawOp == draw.Over)
+		if z.painted && (z.dst != nil) {
+			z.composite(m)
+		}
+		return
+	} else if z.dst == nil {
+		return
+	} else if (z.fill != &z.gradient) && !isDirectDst(z.dst) && (z.fillRule != lowlevel.FillRuleEvenOdd) &&
+		(z.antialiasing == AntialiasingAnalytic) {
+		z.z.Draw(z.dst, z.r, z.fill, image.Point{})
+		return
+	}
+
+	// Rasterize the path to an alpha mask and then composite the paint
+	// through that mask, a span at a time (see drawDirect) or with the
+	// image/draw package. This is faster than the vector.Rasterizer's Draw
+	// method, which composites every pixel of the destination rectangle, even
+	// those that the path does not cover, and which calls the source and
+	// destination images' At methods, allocating, for every pixel, unless
+	// the source is uniform and the destination is an *image.RGBA. An
+	// even-odd path is always rasterized to a mask, as only the
+	// vector.Rasterizer can draw directly, as is a path that is not
+	// analytically anti-aliased.
+	z.rasterizeMask(&z.mask)
+	z.composite(&z.mask)
+}
+
+// rasterizeMask sets mask, re-using its buffer, to the current path's
+// coverage of the destination rectangle. The mask's bounds are the size of
+// that rectangle, with their origin at (0, 0).
+func (z *Rasterizer) rasterizeMask(mask *image.Alpha) {
+	w, h := z.r.Dx(), z.r.Dy()
+	if n := w * h; cap(mask.Pix) < n {
+		mask.Pix = make([]uint8, n)
+	} else {
+		mask.Pix = mask.Pix[:n]
+	}
+	mask.Stride = w
+	mask.Rect = image.Rectangle{Max: image.Point{w, h}}
+	if z.supersampling() > 1 {
+		z.supersample(mask)
+		return
+	}
+	z.drawCoverage(mask)
+	if z.antialiasing == AntialiasingNone {
+		threshold(mask)
+	}
+}
+
+// supersampling returns the supersampling factor in each dimension, which
+// is 1 for the zero value Rasterizer.
+func (z *Rasterizer) supersampling() int {
+	if z.ssScale > 1 {
+		return z.ssScale
+	}
+	return 1
+}
+
+// composite composites the current path's paint through mask onto the
+// destination rectangle. The mask's bounds, relative to that rectangle, may
+// be a sub-rectangle of it, outside of which nothing is drawn.
+func (z *Rasterizer) composite(mask *image.Alpha) {
+	if isDirectDst(z.dst) {
+		z.drawDirect(mask)
+		return
+	}
+	b := mask.Rect
+	draw.DrawMask(z.dst, b.Add(z.r.Min), z.fill, b.Min, mask, b.Min, z.drawOp)
+}
+
+func (z *Rasterizer) ClosePathAbsMoveTo(x, y float32) {
+	z.closePath()
+	z.moveTo(f32.Vec2{x, y})
+}
+
+func (z *Rasterizer) ClosePathRelMoveTo(x, y float32) {
+	z.closePath()
+	z.moveTo(z.rel(x, y))
+}
+
+func (z *Rasterizer) AbsHLineTo(x float32) { z.lineTo(f32.Vec2{x, z.pen[1]}) }
+func (z *Rasterizer) RelHLineTo(x float32) { z.lineTo(f32.Vec2{z.pen[0] + x, z.pen[1]}) }
+func (z *Rasterizer) AbsVLineTo(y float32) { z.lineTo(f32.Vec2{z.pen[0], y}) }
+func (z *Rasterizer) RelVLineTo(y float32) { z.lineTo(f32.Vec2{z.pen[0], z.pen[1] + y}) }
+
+func (z *Rasterizer) AbsLineTo(x, y float32) { z.lineTo(f32.Vec2{x, y}) }
+func (z *Rasterizer) RelLineTo(x, y float32) { z.lineTo(z.rel(x, y)) }
+
+func (z *Rasterizer) AbsSmoothQuadTo(x, y float32) { z.quadTo(z.smooth, f32.Vec2{x, y}) }
+func (z *Rasterizer) RelSmoothQuadTo(x, y float32) { z.quadTo(z.smooth, z.rel(x, y)) }
+
+func (z *Rasterizer) AbsQuadTo(x1, y1, x, y float32) { z.quadTo(f32.Vec2{x1, y1}, f32.Vec2{x, y}) }
+func (z *Rasterizer) RelQuadTo(x1, y1, x, y float32) { z.quadTo(z.rel(x1, y1), z.rel(x, y)) }
+
+func (z *Rasterizer) AbsSmoothCubeTo(x2, y2, x, y float32) {
+	z.cubeTo(z.smooth, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (z *Rasterizer) RelSmoothCubeTo(x2, y2, x, y float32) {
+	z.cubeTo(z.smooth, z.rel(x2, y2), z.rel(x, y))
+}
+
+func (z *Rasterizer) AbsCubeTo(x1, y1, x2, y2, x, y float32) {
+	z.cubeTo(f32.Vec2{x1, y1}, f32.Vec2{x2, y2}, f32.Vec2{x, y})
+}
+
+func (z *Rasterizer) RelCubeTo(x1, y1, x2, y2, x, y float32) {
+	z.cubeTo(z.rel(x1, y1), z.rel(x2, y2), z.rel(x, y))
+}
+
+func (z *Rasterizer) AbsArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	z.arcTo(rx, ry, xAxisRotation, largeArc, sweep, f32.Vec2{x, y})
+}
+
+func (z *Rasterizer) RelArcTo(rx, ry, xAxisRotation float32, largeArc, sweep bool, x, y float32) {
+	z.arcTo(rx, ry, xAxisRotation, largeArc, sweep, z.rel(x, y))
+}
+
+func (z *Rasterizer) rel(x, y float32) f32.Vec2 {
+	return f32.Vec2{z.pen[0] + x, z.pen[1] + y}
+}
+
+// closePath closes the current sub-path. Like the C implementation, it does
+// not move the pen back to the start of the sub-path.
+func (z *Rasterizer) closePath() {
+	if !z.disabled {
+		z.sink().ClosePath()
+	}
+}
+
+func (z *Rasterizer) moveTo(p f32.Vec2) {
+	if !z.disabled {
+		z.sink().MoveTo(z.project(p))
+	}
+	z.pen, z.smooth = p, p
+}
+
+func (z *Rasterizer) lineTo(p f32.Vec2) {
+	if !z.disabled {
+		z.sink().LineTo(z.project(p))
+	}
+	z.pen, z.smooth = p, p
+}
+
+func (z *Rasterizer) quadTo(c, p f32.Vec2) {
+	if !z.disabled {
+		cx, cy := z.project(c)
+		px, py := z.project(p)
+		z.sink().QuadTo(cx, cy, px, py)
+	}
+	z.pen, z.smooth = p, reflect(c, p)
+}
+
+func (z *Rasterizer) cubeTo(c0, c1, p f32.Vec2) {
+	z.cubeToNoSmooth(c0, c1, p)
+	z.smooth = reflect(c1, p)
+}
+
+func (z *Rasterizer) cubeToNoSmooth(c0, c1, p f32.Vec2) {
+	if !z.disabled {
+		c0x, c0y := z.project(c0)
+		c1x, c1y := z.project(c1)
+		px, py := z.project(p)
+		z.sink().CubeTo(c0x, c0y, c1x, c1y, px, py)
+	}
+	z.pen = p
+}
+
+// reflect returns the reflection of the control point c through p.
+func reflect(c, p f32.Vec2) f32.Vec2 {
+	return f32.Vec2{2*p[0] - c[0], 2*p[1] - c[1]}
+}
+
+func validAlphaPremulColor(c color.RGBA) bool {
+	return c.R <= c.A && c.G <= c.A && c.B <= c.A
+}
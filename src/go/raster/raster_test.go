@@ -0,0 +1,150 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+	"golang.org/x/image/math/f64"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	testCases := []struct {
+		filename string
+		want     image.Point
+	}{
+		{"action-info.lores.ivg", image.Point{64, 64}},
+		{"blank.ivg", image.Point{64, 64}},
+		{"cowbell.ivg", image.Point{64, 64}},
+		{"video-005.primitive.ivg", image.Point{64, 48}},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		if format != "iconvg" {
+			t.Errorf("%s: format: got %q, want %q", tc.filename, format, "iconvg")
+		}
+		if got := (image.Point{cfg.Width, cfg.Height}); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.filename, got, tc.want)
+		}
+		m, _, err := image.Decode(bytes.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		if got := m.Bounds().Size(); got != tc.want {
+			t.Errorf("%s: decoded size: got %v, want %v", tc.filename, got, tc.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	transparent := color.RGBA{}
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	testCases := []struct {
+		filename string
+		p        image.Point
+		want     color.RGBA
+	}{
+		{"blank.ivg", image.Point{24, 24}, transparent},
+		// action-info.lores's viewBox is 48 units square, so that one unit
+		// is one pixel, with (-24, -24) at the top left.
+		{"action-info.lores.ivg", image.Point{2, 2}, transparent},
+		{"action-info.lores.ivg", image.Point{24, 6}, black},
+		{"action-info.lores.ivg", image.Point{24, 16}, transparent},
+		{"action-info.lores.ivg", image.Point{24, 28}, transparent},
+		{"action-info.lores.ivg", image.Point{30, 28}, black},
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, 48, 48))
+		if err := raster.Render(dst, dst.Bounds(), src, nil); err != nil {
+			t.Errorf("%s: %v", tc.filename, err)
+			continue
+		}
+		if got := dst.RGBAAt(tc.p.X, tc.p.Y); got != tc.want {
+			t.Errorf("%s at %v: got %v, want %v", tc.filename, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	// The graphic is a 2 by 2 square in the middle of a 10 by 10 viewBox,
+	// fit to a 30 by 10 destination rectangle.
+	src, err := ivg.NewBuilder().SetViewBox(0, 0, 10, 10).
+		MoveTo(4, 4).LineTo(6, 4).LineTo(6, 6).LineTo(4, 6).ClosePath().
+		Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})).
+		Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := f64.Aff3{1, 0, 0, 0, 1, 0}
+	testCases := []struct {
+		desc      string
+		aspect    raster.AspectRatio
+		r         image.Rectangle
+		transform f64.Aff3
+		want      image.Rectangle
+	}{
+		{"stretch", raster.AspectRatioStretch, image.Rect(0, 0, 30, 10), identity, image.Rect(12, 4, 18, 6)},
+		{"meet", raster.AspectRatioMeet, image.Rect(0, 0, 30, 10), identity, image.Rect(14, 4, 16, 6)},
+		{"slice", raster.AspectRatioSlice, image.Rect(0, 0, 30, 10), identity, image.Rect(12, 2, 18, 8)},
+		{"meet, offset rect", raster.AspectRatioMeet, image.Rect(20, 0, 30, 10), identity, image.Rect(24, 4, 26, 6)},
+		{"meet, translated", raster.AspectRatioMeet, image.Rect(0, 0, 30, 10), f64.Aff3{1, 0, 5, 0, 1, -2}, image.Rect(19, 2, 21, 4)},
+		{"meet, scaled", raster.AspectRatioMeet, image.Rect(0, 0, 30, 10), f64.Aff3{2, 0, 0, 0, 2, 0}, image.Rect(28, 8, 30, 10)},
+	}
+	for _, tc := range testCases {
+		dst := image.NewRGBA(image.Rect(0, 0, 30, 10))
+		z := &raster.Rasterizer{}
+		z.SetDstImage(dst, tc.r, draw.Over)
+		z.SetAspectRatio(tc.aspect)
+		z.SetTransform(tc.transform)
+		if err := lowlevel.Decode(z, src, nil); err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		var got image.Rectangle
+		for y := 0; y < 10; y++ {
+			for x := 0; x < 30; x++ {
+				if a := dst.RGBAAt(x, y).A; a == 0xff {
+					got = got.Union(image.Rect(x, y, x+1, y+1))
+				} else if a != 0 {
+					t.Errorf("%s: pixel (%d, %d): got alpha %#x, want 0x00 or 0xff", tc.desc, x, y, a)
+				}
+			}
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/image/math/f32"
+)
+
+// SetPixelSnapping sets the largest height, in pixels, of the fitted viewBox
+// at which the Rasterizer snaps edges to pixel boundaries. Zero, the default,
+// disables snapping.
+//
+// Small renderings, such as 16×16 or 20×20 toolbar icons, of a graphic
+// designed on a larger grid have blurry, anti-aliased horizontal and
+// vertical edges. Snapping trades exactness for crispness, like hinting a
+// font: each path's horizontal and vertical line segments are moved to the
+// nearest pixel boundaries, and the rest of the path is stretched, between
+// those boundaries, to follow them. Two edges that are at least half a pixel
+// apart stay at least one pixel apart, so that thin strokes do not vanish.
+//
+// Snapping only applies when the transform is axis-aligned: a combination of
+// scaling and translation, without rotation or skew.
+func (z *Rasterizer) SetPixelSnapping(maxSize int) {
+	z.snapMaxSize = maxSize
+	z.recalcTransform()
+}
+
+// snapper is a pathSink that records a path, in pixel coordinates, so that
+// replay can snap it once all of its edges are known.
+type snapper struct {
+	ops []snapOp
+
+	// xs and ys are the coordinates of the path's vertical and horizontal
+	// line segments. sxs and sys are what they snap to.
+	xs, ys   []float32
+	sxs, sys []float32
+
+	first f32.Vec2
+	pen   f32.Vec2
+}
+
+// snapOp is a recorded path segment. n is the number of points: 0 for a
+// ClosePath, 1 for a MoveTo or LineTo, 2 for a QuadTo and 3 for a CubeTo. A
+// MoveTo has move set.
+type snapOp struct {
+	n    uint8
+	move bool
+	p    [3]f32.Vec2
+}
+
+func (s *snapper) reset() {
+	s.ops = s.ops[:0]
+	s.xs = s.xs[:0]
+	s.ys = s.ys[:0]
+	s.first, s.pen = f32.Vec2{}, f32.Vec2{}
+}
+
+func (s *snapper) MoveTo(ax, ay float32) {
+	s.ops = append(s.ops, snapOp{n: 1, move: true, p: [3]f32.Vec2{{ax, ay}}})
+	s.first, s.pen = f32.Vec2{ax, ay}, f32.Vec2{ax, ay}
+}
+
+func (s *snapper) LineTo(bx, by float32) {
+	s.ops = append(s.ops, snapOp{n: 1, p: [3]f32.Vec2{{bx, by}}})
+	s.edge(f32.Vec2{bx, by})
+}
+
+func (s *snapper) QuadTo(bx, by, cx, cy float32) {
+	s.ops = append(s.ops, snapOp{n: 2, p: [3]f32.Vec2{{bx, by}, {cx, cy}}})
+	s.pen = f32.Vec2{cx, cy}
+}
+
+func (s *snapper) CubeTo(bx, by, cx, cy, dx, dy float32) {
+	s.ops = append(s.ops, snapOp{n: 3, p: [3]f32.Vec2{{bx, by}, {cx, cy}, {dx, dy}}})
+	s.pen = f32.Vec2{dx, dy}
+}
+
+func (s *snapper) ClosePath() {
+	s.ops = append(s.ops, snapOp{})
+	s.edge(s.first)
+}
+
+// edge notes the line segment from the pen to p, if it is horizontal or
+// vertical, and moves the pen to p.
+func (s *snapper) edge(p f32.Vec2) {
+	if p[0] == s.pen[0] && p[1] != s.pen[1] {
+		s.xs = append(s.xs, p[0])
+	} else if p[1] == s.pen[1] && p[0] != s.pen[0] {
+		s.ys = append(s.ys, p[1])
+	}
+	s.pen = p
+}
+
+// replay sends the recorded path, snapped, to dst.
+func (s *snapper) replay(dst pathSink) {
+	s.xs, s.sxs = snapEdges(s.xs, s.sxs)
+	s.ys, s.sys = snapEdges(s.ys, s.sys)
+	var q [3]f32.Vec2
+	for i := range s.ops {
+		op := &s.ops[i]
+		for j := uint8(0); j < op.n; j++ {
+			q[j] = f32.Vec2{
+				warp(op.p[j][0], s.xs, s.sxs),
+				warp(op.p[j][1], s.ys, s.sys),
+			}
+		}
+		switch {
+		case op.move:
+			dst.MoveTo(q[0][0], q[0][1])
+		case op.n == 0:
+			dst.ClosePath()
+		case op.n == 1:
+			dst.LineTo(q[0][0], q[0][1])
+		case op.n == 2:
+			dst.QuadTo(q[0][0], q[0][1], q[1][0], q[1][1])
+		default:
+			dst.CubeTo(q[0][0], q[0][1], q[1][0], q[1][1], q[2][0], q[2][1])
+		}
+	}
+}
+
+// snapEdges sorts and de-duplicates edges, and returns them and what they
+// snap to, re-using snapped's buffer. The snapped values are non-decreasing,
+// so that warping does not fold the path over itself.
+func snapEdges(edges []float32, snapped []float32) ([]float32, []float32) {
+	sort.Slice(edges, func(i, j int) bool { return edges[i] < edges[j] })
+	n := 0
+	for i, e := range edges {
+		if (i == 0) || (e != edges[n-1]) {
+			edges[n] = e
+			n++
+		}
+	}
+	edges = edges[:n]
+
+	snapped = snapped[:0]
+	for i, e := range edges {
+		s := float32(math.Round(float64(e)))
+		if i > 0 {
+			prev := snapped[i-1]
+			if (s <= prev) && (e-edges[i-1] >= 0.5) {
+				s = prev + 1
+			} else if s < prev {
+				s = prev
+			}
+		}
+		snapped = append(snapped, s)
+	}
+	return edges, snapped
+}
+
+// warp maps v by the piecewise linear function that maps each edge to its
+// snapped value, and that translates values beyond the first or last edge
+// by that edge's displacement.
+func warp(v float32, edges []float32, snapped []float32) float32 {
+	n := len(edges)
+	if n == 0 {
+		return v
+	} else if v <= edges[0] {
+		return v + snapped[0] - edges[0]
+	} else if v >= edges[n-1] {
+		return v + snapped[n-1] - edges[n-1]
+	}
+	i := sort.Search(n, func(i int) bool { return edges[i] > v }) - 1
+	t := (v - edges[i]) / (edges[i+1] - edges[i])
+	return snapped[i] + t*(snapped[i+1]-snapped[i])
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+func TestSnapEdges(t *testing.T) {
+	testCases := []struct {
+		edges       []float32
+		wantEdges   []float32
+		wantSnapped []float32
+	}{
+		{nil, []float32{}, []float32{}},
+		{[]float32{1.4, 2.6}, []float32{1.4, 2.6}, []float32{1, 3}},
+		// Edges are sorted and de-duplicated.
+		{[]float32{5.2, 1.7, 5.2, 1.7}, []float32{1.7, 5.2}, []float32{2, 5}},
+		// Edges at least half a pixel apart stay at least one pixel apart.
+		{[]float32{2.1, 2.6}, []float32{2.1, 2.6}, []float32{2, 3}},
+		{[]float32{2.1, 2.4, 2.9}, []float32{2.1, 2.4, 2.9}, []float32{2, 2, 3}},
+		{[]float32{0.6, 1.2, 1.4}, []float32{0.6, 1.2, 1.4}, []float32{1, 2, 2}},
+	}
+	for _, tc := range testCases {
+		desc := fmt.Sprint(tc.edges)
+		edges := append([]float32(nil), tc.edges...)
+		gotEdges, gotSnapped := raster.SnapEdges(edges, nil)
+		if got, want := fmt.Sprint(gotEdges), fmt.Sprint(tc.wantEdges); got != want {
+			t.Errorf("%s: edges: got %v, want %v", desc, got, want)
+		}
+		if got, want := fmt.Sprint(gotSnapped), fmt.Sprint(tc.wantSnapped); got != want {
+			t.Errorf("%s: snapped: got %v, want %v", desc, got, want)
+		}
+	}
+}
+
+func TestWarp(t *testing.T) {
+	edges := []float32{1.5, 4.5}
+	snapped := []float32{2, 4}
+	testCases := []struct {
+		v, want float32
+	}{
+		{0, 0.5},
+		{1.5, 2},
+		{3, 3},
+		{3.75, 3.5},
+		{4.5, 4},
+		{6, 5.5},
+	}
+	for _, tc := range testCases {
+		if got := raster.Warp(tc.v, edges, snapped); got != tc.want {
+			t.Errorf("v=%v: got %v, want %v", tc.v, got, tc.want)
+		}
+	}
+	if got := raster.Warp(7, nil, nil); got != 7 {
+		t.Errorf("no edges: got %v, want %v", got, 7)
+	}
+}
+
+func TestPixelSnapping(t *testing.T) {
+	// The square's edges, at 5 and 43 in a 48 unit viewBox, are not on pixel
+	// boundaries at 16 or 32 pixels.
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\nviewBox 0 0 48 48\n" +
+		"path [csel] 5 5\nL 43 5 43 43 5 43\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		size, maxSize int
+		wantCrisp     bool
+	}{
+		{16, 0, false},
+		{16, 16, true},
+		{16, 24, true},
+		{32, 16, false},
+		{32, 32, true},
+	}
+	for _, tc := range testCases {
+		dst := image.NewRGBA(image.Rect(0, 0, tc.size, tc.size))
+		z := &raster.Rasterizer{}
+		z.SetDstImage(dst, dst.Bounds(), draw.Over)
+		z.SetPixelSnapping(tc.maxSize)
+		if err := lowlevel.Decode(z, src, nil); err != nil {
+			t.Errorf("size=%d, maxSize=%d: %v", tc.size, tc.maxSize, err)
+			continue
+		}
+		crisp := true
+		for i := 3; i < len(dst.Pix); i += 4 {
+			if a := dst.Pix[i]; (a != 0x00) && (a != 0xff) {
+				crisp = false
+				break
+			}
+		}
+		if crisp != tc.wantCrisp {
+			t.Errorf("size=%d, maxSize=%d: crisp: got %t, want %t", tc.size, tc.maxSize, crisp, tc.wantCrisp)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster
+
+import (
+	"encoding/binary"
+	"image/color"
+)
+
+// This file holds the inner loops that composite a row of paint through a
+// row of mask coverage values onto a row of destination pixels. An icon's
+// path typically covers few of its destination rectangle's pixels, and
+// covers most of those fully, so the loops skip runs of zero coverage eight
+// mask values at a time and store a fully covered, opaque color without any
+// arithmetic. For a flat color and an RGBA destination, runs of full coverage
+// are also filled eight pixels at a time. A row of a gradient that has the
+// same color at every pixel, such as a vertical one, counts as a flat color.
+// Other pixels get the image/draw package's arithmetic.
+//
+// Each span function takes the mask row m and either a flat color c or, if
+// colors is non-nil, one color per pixel. over is whether the Porter-Duff
+// operator is draw.Over instead of draw.Src. With draw.Src, zero coverage
+// still clears a pixel, so nothing is skipped.
+
+// skipZeros returns the index of the first non-zero element of m at or after
+// i, or len(m) if there is none.
+func skipZeros(m []uint8, i int) int {
+	for ; (i+8 <= len(m)) && (binary.LittleEndian.Uint64(m[i:]) == 0); i += 8 {
+	}
+	for ; (i < len(m)) && (m[i] == 0); i++ {
+	}
+	return i
+}
+
+// fillOnes sets the RGBA pixels of p that correspond to the run of 0xff
+// values of m starting at i, eight at a time, to c2, which is a color
+// repeated twice. It returns the index of the first element of m, at or after
+// i, that it did not fill for: the end of the run, or up to seven elements
+// before it.
+func fillOnes(p []uint8, m []uint8, i int, c2 uint64) int {
+	for ; (i+8 <= len(m)) && (binary.LittleEndian.Uint64(m[i:]) == 0xffffffff_ffffffff); i += 8 {
+		q := p[4*i : 4*i+32 : 4*i+32]
+		binary.LittleEndian.PutUint64(q[0:], c2)
+		binary.LittleEndian.PutUint64(q[8:], c2)
+		binary.LittleEndian.PutUint64(q[16:], c2)
+		binary.LittleEndian.PutUint64(q[24:], c2)
+	}
+	return i
+}
+
+// weights returns c's 16-bit channels, the 16-bit coverage ma and how much,
+// a, of the destination color remains after compositing.
+func weights(c color.RGBA, m uint8, over bool) (sr, sg, sb, sa, ma, a uint32) {
+	sr = uint32(c.R) * 0x101
+	sg = uint32(c.G) * 0x101
+	sb = uint32(c.B) * 0x101
+	sa = uint32(c.A) * 0x101
+	ma = uint32(m) * 0x101
+	if over {
+		a = 0xffff - (sa * ma / 0xffff)
+	}
+	return sr, sg, sb, sa, ma, a
+}
+
+func spanRGBA(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool) {
+	p = p[:4*len(m)]
+	// fill is whether runs of fully covered pixels are set to the flat color
+	// c, eight pixels at a time, as four uint64 stores of two pixels each.
+	fill := (colors == nil) && ((c.A == 0xff) || !over)
+	c2 := (uint64(c.R) | uint64(c.G)<<8 | uint64(c.B)<<16 | uint64(c.A)<<24) * 0x00000001_00000001
+	for x := 0; x < len(m); x++ {
+		if over {
+			if x = skipZeros(m, x); x == len(m) {
+				break
+			}
+		}
+		if fill {
+			x = fillOnes(p, m, x, c2)
+			if x == len(m) {
+				break
+			}
+		}
+		if colors != nil {
+			c = colors[x]
+		}
+		q := p[4*x : 4*x+4 : 4*x+4]
+		if (m[x] == 0xff) && ((c.A == 0xff) || !over) {
+			q[0], q[1], q[2], q[3] = c.R, c.G, c.B, c.A
+			continue
+		}
+		sr, sg, sb, sa, ma, a := weights(c, m[x], over)
+		q[0] = uint8(((uint32(q[0])*0x101*a + sr*ma) / 0xffff) >> 8)
+		q[1] = uint8(((uint32(q[1])*0x101*a + sg*ma) / 0xffff) >> 8)
+		q[2] = uint8(((uint32(q[2])*0x101*a + sb*ma) / 0xffff) >> 8)
+		q[3] = uint8(((uint32(q[3])*0x101*a + sa*ma) / 0xffff) >> 8)
+	}
+}
+
+func spanNRGBA(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool) {
+	p = p[:4*len(m)]
+	for x := 0; x < len(m); x++ {
+		if over {
+			if x = skipZeros(m, x); x == len(m) {
+				break
+			}
+		}
+		if colors != nil {
+			c = colors[x]
+		}
+		q := p[4*x : 4*x+4 : 4*x+4]
+		if (m[x] == 0xff) && (c.A == 0xff) {
+			q[0], q[1], q[2], q[3] = c.R, c.G, c.B, 0xff
+			continue
+		}
+		sr, sg, sb, sa, ma, a := weights(c, m[x], over)
+		da := uint32(q[3]) * 0x101
+		dr := uint32(q[0]) * 0x101 * da / 0xffff
+		dg := uint32(q[1]) * 0x101 * da / 0xffff
+		db := uint32(q[2]) * 0x101 * da / 0xffff
+		or := (dr*a + sr*ma) / 0xffff
+		og := (dg*a + sg*ma) / 0xffff
+		ob := (db*a + sb*ma) / 0xffff
+		oa := (da*a + sa*ma) / 0xffff
+		switch oa {
+		case 0:
+			q[0], q[1], q[2], q[3] = 0, 0, 0, 0
+		case 0xffff:
+			q[0], q[1], q[2], q[3] = uint8(or>>8), uint8(og>>8), uint8(ob>>8), 0xff
+		default:
+			q[0] = uint8(((or * 0xffff) / oa) >> 8)
+			q[1] = uint8(((og * 0xffff) / oa) >> 8)
+			q[2] = uint8(((ob * 0xffff) / oa) >> 8)
+			q[3] = uint8(oa >> 8)
+		}
+	}
+}
+
+func spanAlpha(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool) {
+	p = p[:len(m)]
+	for x := 0; x < len(m); x++ {
+		if over {
+			if x = skipZeros(m, x); x == len(m) {
+				break
+			}
+		}
+		if colors != nil {
+			c = colors[x]
+		}
+		if (m[x] == 0xff) && ((c.A == 0xff) || !over) {
+			p[x] = c.A
+			continue
+		}
+		_, _, _, sa, ma, a := weights(c, m[x], over)
+		da := uint32(p[x]) * 0x101
+		p[x] = uint8(((da*a + sa*ma) / 0xffff) >> 8)
+	}
+}
+
+// spanGray is like the other span functions but, like the image.Gray color
+// model, it ignores the result's alpha and converts its (alpha-premultiplied)
+// RGB to luma, as a Gray image is opaque.
+func spanGray(p []uint8, m []uint8, c color.RGBA, colors []color.RGBA, over bool) {
+	p = p[:len(m)]
+	for x := 0; x < len(m); x++ {
+		if over {
+			if x = skipZeros(m, x); x == len(m) {
+				break
+			}
+		}
+		if colors != nil {
+			c = colors[x]
+		}
+		sr, sg, sb, _, ma, a := weights(c, m[x], over)
+		dy := uint32(p[x]) * 0x101
+		or := (dy*a + sr*ma) / 0xffff
+		og := (dy*a + sg*ma) / 0xffff
+		ob := (dy*a + sb*ma) / 0xffff
+		p[x] = uint8((19595*or + 38470*og + 7471*ob + 1<<15) >> 24)
+	}
+}
+
+// span sets colors[i] to the gradient's color at the center of the pixel
+// (x0+i, y), skipping the pixels that would not be drawn: those whose
+// coverage, m[i], is zero when compositing with draw.Over.
+func (g *gradient) span(colors []color.RGBA, m []uint8, x0 int, y int, over bool) {
+	fy := float64(y) + 0.5
+	for i := 0; i < len(m); i++ {
+		if over {
+			if i = skipZeros(m, i); i == len(m) {
+				break
+			}
+		}
+		colors[i] = g.quantize(g.rgbaAt(float64(x0+i)+0.5, fy), x0+i, y)
+	}
+}
+
+// rowColor returns the gradient's color along row y, and whether every pixel
+// of that row has that color. That is so for a linear gradient whose offset
+// depends only on y, such as a vertical gradient in an unrotated graphic,
+// unless it is dithered. Such a row can then take the span functions' flat
+// color paths, including filling fully covered runs eight pixels at a time.
+func (g *gradient) rowColor(y int) (color.RGBA, bool) {
+	if (g.shape != gradientShapeLinear) || (g.pix2pat[0] != 0) ||
+		(g.quantization == GradientQuantizationRGB565Dither) {
+		return color.RGBA{}, false
+	}
+	return g.quantize(g.rgbaAt(0.5, float64(y)+0.5), 0, y), true
+}
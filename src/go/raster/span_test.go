@@ -0,0 +1,72 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raster_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// iconRow returns a mask row like those of a typical icon's path: uncovered
+// pixels, an anti-aliased edge, a fully covered run of n pixels, another edge
+// and more uncovered pixels.
+func iconRow(n int) []uint8 {
+	m := []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0x40}
+	m = append(m, bytes.Repeat([]uint8{0xff}, n)...)
+	return append(m, 0xc0, 0x10, 0, 0, 0)
+}
+
+// TestSpanRGBA checks that SpanRGBA, which fills fully covered runs eight
+// pixels at a time, gives the same results as SpanRGBAPerPixel.
+func TestSpanRGBA(t *testing.T) {
+	ramp := make([]uint8, 256)
+	for i := range ramp {
+		ramp[i] = uint8(i)
+	}
+	masks := []struct {
+		desc string
+		m    []uint8
+	}{
+		{"ramp", ramp},
+		{"covered", bytes.Repeat([]uint8{0xff}, 37)},
+		{"icon row, 7", iconRow(7)},
+		{"icon row, 8", iconRow(8)},
+		{"icon row, 100", iconRow(100)},
+	}
+	colors := []struct {
+		desc string
+		c    color.RGBA
+	}{
+		{"opaque", color.RGBA{0x12, 0x80, 0xff, 0xff}},
+		{"semi-transparent", color.RGBA{0x10, 0x40, 0x7f, 0x80}},
+		{"transparent", color.RGBA{}},
+	}
+	for _, mc := range masks {
+		for _, cc := range colors {
+			for _, over := range []bool{false, true} {
+				got := bytes.Repeat([]uint8{0x20, 0x40, 0x60, 0x80}, len(mc.m))
+				want := append([]uint8(nil), got...)
+				raster.SpanRGBA(got, mc.m, cc.c, nil, over)
+				raster.SpanRGBAPerPixel(want, mc.m, cc.c, nil, over)
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s, %s, over=%t: got %v, want %v", mc.desc, cc.desc, over, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"io"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// Frames rasterizes each frame of the encoded lowlevel.Animation src to a new
+// size×size image, like Image.
+func Frames(src []byte, size int, opts *Options) (*lowlevel.Animation, []*image.RGBA, error) {
+	a, err := lowlevel.DecodeAnimation(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	ms := make([]*image.RGBA, len(a.Frames))
+	for i, f := range a.Frames {
+		if ms[i], err = Image(f.Graphic, size, opts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, ms, nil
+}
+
+// gifPalette is the web-safe palette plus, at index 0, transparent.
+var gifPalette = append(color.Palette{color.Transparent}, palette.WebSafe...)
+
+// GIF is like Frames but writes the frames to w as an animated GIF.
+//
+// GIF images have at most 256 colors and no partial transparency, so each
+// pixel becomes the nearest web-safe color, or transparent if it is less than
+// half opaque. Setting opts' Background avoids the latter. GIF frame delays
+// are in hundredths of a second. APNG has neither limitation.
+func GIF(w io.Writer, src []byte, size int, opts *Options) error {
+	a, ms, err := Frames(src, size, opts)
+	if err != nil {
+		return err
+	}
+	g := &gif.GIF{
+		Image:    make([]*image.Paletted, len(ms)),
+		Delay:    make([]int, len(ms)),
+		Disposal: make([]byte, len(ms)),
+	}
+	// A GIF's LoopCount is the number of times to repeat, after the first
+	// time, or -1 to play once.
+	if a.LoopCount > 0 {
+		g.LoopCount = a.LoopCount - 1
+		if g.LoopCount == 0 {
+			g.LoopCount = -1
+		}
+	}
+	for i, m := range ms {
+		p := image.NewPaletted(m.Rect, gifPalette)
+		for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+			for x := m.Rect.Min.X; x < m.Rect.Max.X; x++ {
+				if c := m.RGBAAt(x, y); c.A >= 0x80 {
+					p.SetColorIndex(x, y, uint8(gifPalette.Index(color.NRGBAModel.Convert(c))))
+				}
+			}
+		}
+		g.Image[i] = p
+		g.Delay[i] = int((a.Frames[i].Duration.Milliseconds() + 5) / 10)
+		g.Disposal[i] = gif.DisposalBackground
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// APNG is like Frames but writes the frames to w as an animated PNG.
+func APNG(w io.Writer, src []byte, size int, opts *Options) error {
+	a, ms, err := Frames(src, size, opts)
+	if err != nil {
+		return err
+	}
+	if len(ms) == 0 {
+		// A PNG file has at least one image.
+		return errNoFrames
+	}
+
+	e := &apngEncoder{w: bufio.NewWriter(w)}
+	e.w.WriteString("\x89PNG\r\n\x1a\n")
+	e.chunk("IHDR", e.uint32s(uint32(size), uint32(size)), []byte{
+		8, // Bit depth.
+		6, // Color type: RGBA.
+		0, // Compression method.
+		0, // Filter method.
+		0, // Interlace method.
+	})
+	e.chunk("acTL", e.uint32s(uint32(len(ms)), uint32(a.LoopCount)))
+	for i, m := range ms {
+		num, den := apngDelay(a.Frames[i].Duration.Milliseconds())
+		e.chunk("fcTL", e.uint32s(e.seq, uint32(size), uint32(size), 0, 0), []byte{
+			uint8(num >> 8), uint8(num),
+			uint8(den >> 8), uint8(den),
+			1, // Dispose op: background.
+			0, // Blend op: source.
+		})
+		e.seq++
+		data, err := apngData(m)
+		if err != nil {
+			return err
+		}
+		// The first frame is also the default image, shown by decoders that
+		// do not support animation.
+		if i == 0 {
+			e.chunk("IDAT", data)
+		} else {
+			e.chunk("fdAT", e.uint32s(e.seq), data)
+			e.seq++
+		}
+	}
+	e.chunk("IEND")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// apngEncoder writes PNG chunks. seq is the APNG sequence number, shared by
+// the fcTL and fdAT chunks.
+type apngEncoder struct {
+	w   *bufio.Writer
+	err error
+	seq uint32
+}
+
+func (e *apngEncoder) uint32s(us ...uint32) []byte {
+	b := make([]byte, 4*len(us))
+	for i, u := range us {
+		binary.BigEndian.PutUint32(b[4*i:], u)
+	}
+	return b
+}
+
+func (e *apngEncoder) chunk(name string, data ...[]byte) {
+	if e.err != nil {
+		return
+	}
+	n := 0
+	for _, d := range data {
+		n += len(d)
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(n))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	e.w.Write(header[:])
+	for _, d := range data {
+		crc.Write(d)
+		e.w.Write(d)
+	}
+	_, e.err = e.w.Write(e.uint32s(crc.Sum32()))
+}
+
+// apngDelay returns a frame delay, in milliseconds, as a fraction whose
+// numerator and denominator fit in 16 bits.
+func apngDelay(ms int64) (num, den int64) {
+	num, den = ms, 1000
+	for (num > 0xffff) && (den > 1) {
+		num, den = num/10, den/10
+	}
+	if num > 0xffff {
+		num = 0xffff
+	}
+	return num, den
+}
+
+// apngData returns m's compressed, non-alpha-premultiplied pixels, each row
+// using the Sub filter, which suits the flat colors and smooth gradients of
+// icons.
+func apngData(m *image.RGBA) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	b := m.Rect
+	row := make([]byte, 1+4*b.Dx())
+	row[0] = 1 // The Sub filter.
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var prev color.NRGBA
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.RGBAAt(x, y)).(color.NRGBA)
+			i := 1 + 4*(x-b.Min.X)
+			row[i+0] = c.R - prev.R
+			row[i+1] = c.G - prev.G
+			row[i+2] = c.B - prev.B
+			row[i+3] = c.A - prev.A
+			prev = c
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+func testAnimation(t *testing.T, loopCount int) []byte {
+	t.Helper()
+	src, err := lowlevel.EncodeAnimation(&lowlevel.Animation{
+		LoopCount: loopCount,
+		Frames: []lowlevel.Frame{
+			{Duration: 100 * time.Millisecond, Graphic: readTestData(t, "action-info.lores.ivg")},
+			{Duration: 250 * time.Millisecond, Graphic: readTestData(t, "cowbell.ivg")},
+			{Duration: 70 * time.Second, Graphic: readTestData(t, "favicon.ivg")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestFrames(t *testing.T) {
+	src := testAnimation(t, 0)
+	a, ms, err := render.Frames(src, 32, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != len(a.Frames) {
+		t.Fatalf("got %d images, want %d", len(ms), len(a.Frames))
+	}
+	for i, m := range ms {
+		want, err := render.Image(a.Frames[i].Graphic, 32, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Pix, want.Pix) {
+			t.Errorf("frame #%d: images differ", i)
+		}
+	}
+
+	if _, _, err := render.Frames(readTestData(t, "cowbell.ivg"), 32, nil); err == nil {
+		t.Errorf("graphic: got nil error, want non-nil")
+	}
+}
+
+func TestGIF(t *testing.T) {
+	testCases := []struct {
+		loopCount     int
+		wantLoopCount int
+	}{
+		{0, 0},
+		{1, -1},
+		{3, 2},
+	}
+	for _, tc := range testCases {
+		buf := &bytes.Buffer{}
+		if err := render.GIF(buf, testAnimation(t, tc.loopCount), 32, nil); err != nil {
+			t.Errorf("loop count %d: %v", tc.loopCount, err)
+			continue
+		}
+		g, err := gif.DecodeAll(buf)
+		if err != nil {
+			t.Errorf("loop count %d: DecodeAll: %v", tc.loopCount, err)
+			continue
+		}
+		if g.LoopCount != tc.wantLoopCount {
+			t.Errorf("loop count %d: got GIF loop count %d, want %d", tc.loopCount, g.LoopCount, tc.wantLoopCount)
+		}
+		if got, want := fmt.Sprint(g.Delay), "[10 25 7000]"; got != want {
+			t.Errorf("loop count %d: got delays %v, want %v", tc.loopCount, got, want)
+		}
+		if b := g.Image[0].Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+			t.Errorf("loop count %d: got bounds %v, want 32×32", tc.loopCount, b)
+		}
+	}
+}
+
+// pngChunk is a PNG chunk's name and data.
+type pngChunk struct {
+	name string
+	data []byte
+}
+
+func pngChunks(t *testing.T, b []byte) []pngChunk {
+	t.Helper()
+	if !bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Fatal("missing PNG signature")
+	}
+	b = b[8:]
+	var chunks []pngChunk
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatal("truncated PNG chunk")
+		}
+		n := int(binary.BigEndian.Uint32(b))
+		if len(b) < 12+n {
+			t.Fatal("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{string(b[4:8]), b[8 : 8+n]})
+		b = b[12+n:]
+	}
+	return chunks
+}
+
+func TestAPNG(t *testing.T) {
+	src := testAnimation(t, 2)
+	buf := &bytes.Buffer{}
+	if err := render.APNG(buf, src, 32, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	var delays [][2]uint16
+	for _, c := range pngChunks(t, buf.Bytes()) {
+		names = append(names, c.name)
+		switch c.name {
+		case "acTL":
+			if got := binary.BigEndian.Uint32(c.data[0:]); got != 3 {
+				t.Errorf("acTL: got %d frames, want 3", got)
+			}
+			if got := binary.BigEndian.Uint32(c.data[4:]); got != 2 {
+				t.Errorf("acTL: got %d plays, want 2", got)
+			}
+		case "fcTL":
+			delays = append(delays, [2]uint16{
+				binary.BigEndian.Uint16(c.data[20:]),
+				binary.BigEndian.Uint16(c.data[22:]),
+			})
+		}
+	}
+	wantNames := "IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND"
+	if got := fmt.Sprint(names); got != "["+wantNames+"]" {
+		t.Errorf("chunks: got %s, want [%s]", got, wantNames)
+	}
+	// 70 seconds is too many milliseconds for 16 bits, so it is 7000/100.
+	wantDelays := [][2]uint16{{100, 1000}, {250, 1000}, {7000, 100}}
+	if fmt.Sprint(delays) != fmt.Sprint(wantDelays) {
+		t.Errorf("delays: got %v, want %v", delays, wantDelays)
+	}
+
+	// Decoders that do not support animation show the first frame.
+	m, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := render.Image(readTestData(t, "action-info.lores.ivg"), 32, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			got := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+			w := color.RGBAModel.Convert(color.NRGBAModel.Convert(want.RGBAAt(x, y))).(color.RGBA)
+			if got != w {
+				t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, got, w)
+			}
+		}
+	}
+}
+
+func TestAPNGErrors(t *testing.T) {
+	empty, err := lowlevel.EncodeAnimation(&lowlevel.Animation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		desc string
+		src  []byte
+	}{
+		{"no frames", empty},
+		{"graphic", readTestData(t, "cowbell.ivg")},
+	}
+	for _, tc := range testCases {
+		if err := render.APNG(&bytes.Buffer{}, tc.src, 32, nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package atlas caches rasterized IconVG icons in a shared texture atlas.
+//
+// Game and GUI toolkits draw the same icons, at the same sizes, frame after
+// frame. An Atlas rasterizes each (icon, size) pair once, into a rectangle of
+// a single *image.RGBA that can be uploaded as one texture, and only
+// rasterizes again when that rectangle has been evicted to make room for
+// other icons, or when the icon or the palette changes.
+//
+// The atlas is divided into horizontal shelves, each holding square slots of
+// a single size. When the atlas is full, the least recently used icons are
+// evicted, and shelves that become empty are merged and reused for other
+// sizes.
+package atlas
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+)
+
+var (
+	errIconTooLarge = errors.New("atlas: icon is too large for the atlas")
+	errInvalidSize  = errors.New("atlas: invalid size")
+	errUnknownIcon  = errors.New("atlas: unknown icon")
+)
+
+// Options are the optional parameters to New.
+type Options struct {
+	// Padding is the number of transparent pixels kept around each icon, so
+	// that sampling the texture with bilinear filtering does not bleed one
+	// icon into its neighbors.
+	Padding int
+}
+
+// Key identifies a rasterized icon: an icon name and a size in pixels.
+type Key struct {
+	Name string
+	Size int
+}
+
+// Atlas is a cache of rasterized IconVG icons.
+//
+// An Atlas is not safe for concurrent use by multiple goroutines.
+type Atlas struct {
+	img     *image.RGBA
+	padding int
+
+	icons   map[string]*icon
+	palette map[uint8]color.RGBA
+
+	// paletteGen is incremented by SetPalette. An entry whose paletteGen
+	// differs is stale.
+	paletteGen uint64
+
+	// entries are the cached rasterizations. lru orders them from most to
+	// least recently used.
+	entries map[Key]*entry
+	lru     list.List
+
+	// shelves are ordered from top to bottom. The space below the last shelf
+	// is unallocated.
+	shelves []*shelf
+
+	dirty image.Rectangle
+}
+
+type icon struct {
+	src []byte
+	gen uint64
+}
+
+type entry struct {
+	key        Key
+	rect       image.Rectangle
+	shelf      *shelf
+	slot       int
+	iconGen    uint64
+	paletteGen uint64
+	elem       *list.Element
+}
+
+// shelf is a horizontal strip of the atlas, holding square slots whose width
+// and height (including padding) are slotSize. An empty shelf has a zero
+// slotSize and can be reused for any slot size up to its height.
+type shelf struct {
+	y, height int
+	slotSize  int
+	used      []bool
+	numUsed   int
+}
+
+// New returns an empty Atlas whose image is width×height pixels.
+//
+// opts may be nil, which means to use the default options.
+func New(width, height int, opts *Options) *Atlas {
+	if width < 0 {
+		width = 0
+	}
+	if height < 0 {
+		height = 0
+	}
+	a := &Atlas{
+		img:     image.NewRGBA(image.Rectangle{Max: image.Point{width, height}}),
+		icons:   map[string]*icon{},
+		entries: map[Key]*entry{},
+	}
+	if (opts != nil) && (opts.Padding > 0) {
+		a.padding = opts.Padding
+	}
+	return a
+}
+
+// Image returns the atlas image. Its pixels change as icons are rasterized:
+// see Dirty.
+func (a *Atlas) Image() *image.RGBA {
+	return a.img
+}
+
+// Add adds the IconVG graphic src to the atlas under the given name, or
+// replaces the graphic previously added under that name. Replacing a graphic
+// re-rasterizes it, in place, the next time that Get is called for it.
+//
+// The Atlas retains src, which should not be modified afterwards.
+func (a *Atlas) Add(name string, src []byte) error {
+	if _, err := lowlevel.DecodeMetadata(src); err != nil {
+		return err
+	}
+	if ic := a.icons[name]; ic != nil {
+		ic.src = src
+		ic.gen++
+		return nil
+	}
+	a.icons[name] = &icon{src: src}
+	return nil
+}
+
+// Remove removes the named icon, and frees its rasterizations' space.
+func (a *Atlas) Remove(name string) {
+	if a.icons[name] == nil {
+		return
+	}
+	delete(a.icons, name)
+	for k, e := range a.entries {
+		if k.Name == name {
+			a.evict(e)
+		}
+	}
+}
+
+// SetPalette sets the overrides for the icons' suggested palettes, keyed by
+// palette index, like render.Options.Palette. Every cached icon is
+// re-rasterized, in place, the next time that Get is called for it.
+func (a *Atlas) SetPalette(palette map[uint8]color.RGBA) {
+	a.palette = palette
+	a.paletteGen++
+}
+
+// Get returns the rectangle, within the atlas image, that holds the named
+// icon rasterized at size×size pixels. It rasterizes the icon if it is not
+// already cached, or if it is stale, evicting the least recently used icons
+// if there is no room for it.
+//
+// The rectangle remains valid until the icon is evicted, which can only
+// happen during a later call to Get (for a different Key) or Remove. If an
+// atlas has room for every icon that a frame needs, then a frame's Get calls
+// never evict each other's icons.
+func (a *Atlas) Get(name string, size int) (image.Rectangle, error) {
+	if size <= 0 {
+		return image.Rectangle{}, errInvalidSize
+	}
+	ic := a.icons[name]
+	if ic == nil {
+		return image.Rectangle{}, errUnknownIcon
+	}
+	k := Key{name, size}
+	e := a.entries[k]
+	if e != nil {
+		a.lru.MoveToFront(e.elem)
+		if (e.iconGen == ic.gen) && (e.paletteGen == a.paletteGen) {
+			return e.rect, nil
+		}
+	} else {
+		s, slot, err := a.alloc(size + 2*a.padding)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+		x, y := slot*s.slotSize+a.padding, s.y+a.padding
+		e = &entry{
+			key:   k,
+			rect:  image.Rect(x, y, x+size, y+size),
+			shelf: s,
+			slot:  slot,
+		}
+		e.elem = a.lru.PushFront(e)
+		a.entries[k] = e
+	}
+
+	if err := a.rasterize(e, ic); err != nil {
+		a.evict(e)
+		return image.Rectangle{}, err
+	}
+	return e.rect, nil
+}
+
+// Rects returns the rectangles, within the atlas image, of every cached
+// icon. Stale icons, whose rectangles will be re-rasterized by the next Get,
+// are included.
+func (a *Atlas) Rects() map[Key]image.Rectangle {
+	m := make(map[Key]image.Rectangle, len(a.entries))
+	for k, e := range a.entries {
+		m[k] = e.rect
+	}
+	return m
+}
+
+// Dirty returns the smallest rectangle that contains every pixel of the
+// atlas image changed since the previous call to Dirty, and resets it. A
+// toolkit can upload just that part of its texture after a frame's Get
+// calls.
+func (a *Atlas) Dirty() image.Rectangle {
+	r := a.dirty
+	a.dirty = image.Rectangle{}
+	return r
+}
+
+func (a *Atlas) rasterize(e *entry, ic *icon) error {
+	// Clear the whole slot, including the padding, as it may hold the
+	// remains of an evicted icon.
+	slot := e.rect.Inset(-a.padding)
+	draw.Draw(a.img, slot, image.Transparent, image.Point{}, draw.Src)
+	a.dirty = a.dirty.Union(slot)
+
+	opts := (*render.Options)(nil)
+	if len(a.palette) > 0 {
+		opts = &render.Options{Palette: a.palette}
+	}
+	_, err := render.Batch(context.Background(), []render.Job{{
+		Src:      ic.src,
+		Size:     e.key.Size,
+		Options:  opts,
+		Dst:      a.img,
+		DstPoint: e.rect.Min,
+	}}, 1)
+	if err != nil {
+		return err
+	}
+	e.iconGen, e.paletteGen = ic.gen, a.paletteGen
+	return nil
+}
+
+// alloc returns a free slot of the given size, evicting the least recently
+// used entries until there is one.
+func (a *Atlas) alloc(slotSize int) (*shelf, int, error) {
+	b := a.img.Bounds()
+	if (slotSize > b.Dx()) || (slotSize > b.Dy()) {
+		return nil, 0, errIconTooLarge
+	}
+	for {
+		if s, slot := a.findSlot(slotSize); s != nil {
+			s.used[slot] = true
+			s.numUsed++
+			return s, slot, nil
+		}
+		back := a.lru.Back()
+		if back == nil {
+			return nil, 0, errIconTooLarge
+		}
+		a.evict(back.Value.(*entry))
+	}
+}
+
+// findSlot returns a free slot of the given size, from (in order of
+// preference) a shelf of that slot size, the smallest sufficiently tall
+// empty shelf or a new shelf. It returns a nil shelf if there is no room.
+func (a *Atlas) findSlot(slotSize int) (*shelf, int) {
+	best := -1
+	for i, s := range a.shelves {
+		if s.slotSize == slotSize {
+			if s.numUsed < len(s.used) {
+				for slot, used := range s.used {
+					if !used {
+						return s, slot
+					}
+				}
+			}
+		} else if (s.slotSize == 0) && (s.height >= slotSize) {
+			if (best < 0) || (s.height < a.shelves[best].height) {
+				best = i
+			}
+		}
+	}
+
+	if best >= 0 {
+		s := a.shelves[best]
+		if s.height > slotSize {
+			// Split off the rest of the empty shelf.
+			rest := &shelf{y: s.y + slotSize, height: s.height - slotSize}
+			a.shelves = append(a.shelves, nil)
+			copy(a.shelves[best+2:], a.shelves[best+1:])
+			a.shelves[best+1] = rest
+			s.height = slotSize
+		}
+		s.init(slotSize, a.img.Bounds().Dx())
+		return s, 0
+	}
+
+	top := 0
+	if n := len(a.shelves); n > 0 {
+		top = a.shelves[n-1].y + a.shelves[n-1].height
+	}
+	if top+slotSize > a.img.Bounds().Dy() {
+		return nil, 0
+	}
+	s := &shelf{y: top, height: slotSize}
+	s.init(slotSize, a.img.Bounds().Dx())
+	a.shelves = append(a.shelves, s)
+	return s, 0
+}
+
+func (s *shelf) init(slotSize int, width int) {
+	s.slotSize = slotSize
+	s.used = make([]bool, width/slotSize)
+	s.numUsed = 0
+}
+
+// evict removes e from the cache. If that empties its shelf, the shelf is
+// merged with any adjacent empty shelves, and with the unallocated space if
+// it is the last shelf.
+func (a *Atlas) evict(e *entry) {
+	delete(a.entries, e.key)
+	a.lru.Remove(e.elem)
+	s := e.shelf
+	s.used[e.slot] = false
+	if s.numUsed--; s.numUsed > 0 {
+		return
+	}
+	s.slotSize, s.used = 0, nil
+
+	i := 0
+	for a.shelves[i] != s {
+		i++
+	}
+	if (i+1 < len(a.shelves)) && (a.shelves[i+1].slotSize == 0) {
+		s.height += a.shelves[i+1].height
+		a.shelves = append(a.shelves[:i+1], a.shelves[i+2:]...)
+	}
+	if (i > 0) && (a.shelves[i-1].slotSize == 0) {
+		a.shelves[i-1].height += s.height
+		a.shelves = append(a.shelves[:i], a.shelves[i+1:]...)
+		i--
+	}
+	if i == len(a.shelves)-1 {
+		a.shelves = a.shelves[:i]
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atlas_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/render"
+	"github.com/google/iconvg/src/go/render/atlas"
+)
+
+func readTestData(t *testing.T, filename string) []byte {
+	t.Helper()
+	src, err := os.ReadFile("../../../../test/data/" + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGet(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	testCases := []struct {
+		padding  int
+		wantRect image.Rectangle
+	}{
+		{0, image.Rect(0, 0, 24, 24)},
+		{2, image.Rect(2, 2, 26, 26)},
+	}
+	for _, tc := range testCases {
+		a := atlas.New(64, 64, &atlas.Options{Padding: tc.padding})
+		if err := a.Add("info", src); err != nil {
+			t.Fatal(err)
+		}
+		r, err := a.Get("info", 24)
+		if err != nil {
+			t.Errorf("padding %d: %v", tc.padding, err)
+			continue
+		}
+		if r != tc.wantRect {
+			t.Errorf("padding %d: rect: got %v, want %v", tc.padding, r, tc.wantRect)
+		}
+		if got, want := a.Dirty(), tc.wantRect.Inset(-tc.padding); got != want {
+			t.Errorf("padding %d: Dirty: got %v, want %v", tc.padding, got, want)
+		}
+
+		// A cached icon is neither moved nor re-rasterized.
+		if r2, err := a.Get("info", 24); err != nil || r2 != r {
+			t.Errorf("padding %d: second Get: got %v, %v, want %v, nil", tc.padding, r2, err, r)
+		}
+		if got := a.Dirty(); !got.Empty() {
+			t.Errorf("padding %d: second Dirty: got %v, want empty", tc.padding, got)
+		}
+
+		want, err := render.Image(src, 24, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := a.Image().SubImage(r).(*image.RGBA)
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 24; x++ {
+				if g, w := got.RGBAAt(r.Min.X+x, r.Min.Y+y), want.RGBAAt(x, y); g != w {
+					t.Fatalf("padding %d: pixel (%d, %d): got %v, want %v", tc.padding, x, y, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestEviction(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	// The atlas has room for two 16×16 icons.
+	a := atlas.New(32, 16, nil)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := a.Add(name, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testCases := []struct {
+		get  string
+		want []string
+	}{
+		{"a", []string{"a"}},
+		{"b", []string{"a", "b"}},
+		{"a", []string{"a", "b"}},
+		{"c", []string{"a", "c"}},
+		{"b", []string{"b", "c"}},
+	}
+	for i, tc := range testCases {
+		if _, err := a.Get(tc.get, 16); err != nil {
+			t.Fatalf("#%d: Get(%q): %v", i, tc.get, err)
+		}
+		rects := a.Rects()
+		if len(rects) != len(tc.want) {
+			t.Errorf("#%d: Rects: got %v, want %v", i, rects, tc.want)
+			continue
+		}
+		for _, name := range tc.want {
+			if _, ok := rects[atlas.Key{Name: name, Size: 16}]; !ok {
+				t.Errorf("#%d: Rects: got %v, want %v", i, rects, tc.want)
+			}
+		}
+	}
+
+	// Once every icon is removed, the whole atlas can be reused for a
+	// different size.
+	for _, name := range []string{"a", "b", "c"} {
+		a.Remove(name)
+	}
+	if err := a.Add("big", src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get("big", 16); err != nil {
+		t.Errorf("after Remove: %v", err)
+	}
+	a.Remove("big")
+	if err := a.Add("small", src); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{8, 4, 8} {
+		if _, err := a.Get("small", size); err != nil {
+			t.Errorf("after Remove, size %d: %v", size, err)
+		}
+	}
+}
+
+func TestSetPalette(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	a := atlas.New(48, 48, nil)
+	if err := a.Add("info", src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get("info", 48); err != nil {
+		t.Fatal(err)
+	}
+	a.Dirty()
+
+	// At size 48, (24, 6) is inside of action-info's circle.
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	a.SetPalette(map[uint8]color.RGBA{0: red})
+	if got := a.Dirty(); !got.Empty() {
+		t.Errorf("Dirty before Get: got %v, want empty", got)
+	}
+	if _, err := a.Get("info", 48); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.Dirty(), image.Rect(0, 0, 48, 48); got != want {
+		t.Errorf("Dirty after Get: got %v, want %v", got, want)
+	}
+	if got := a.Image().RGBAAt(24, 6); got != red {
+		t.Errorf("pixel: got %v, want %v", got, red)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	a := atlas.New(32, 32, &atlas.Options{Padding: 1})
+	if err := a.Add("info", src); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add("invalid", []byte("not IconVG")); err == nil {
+		t.Errorf("Add invalid: got nil error, want non-nil")
+	}
+	testCases := []struct {
+		desc string
+		name string
+		size int
+	}{
+		{"zero size", "info", 0},
+		{"negative size", "info", -1},
+		{"unknown icon", "other", 16},
+		{"too large", "info", 32},
+	}
+	for _, tc := range testCases {
+		if _, err := a.Get(tc.name, tc.size); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+	if got := len(a.Rects()); got != 0 {
+		t.Errorf("Rects: got %d entries, want 0", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// Backend fills flattened paths onto an image. It lets another rasterizer,
+// such as a GPU tessellator or a C library called via cgo, draw IconVG
+// graphics, while this package and package raster still decode them, fit
+// them to the image, apply their levels of detail and resolve their colors
+// and gradients.
+//
+// A Backend may be used by several goroutines at once, such as by Batch.
+type Backend interface {
+	// Fill fills p with paint, compositing it over dst (the Porter-Duff
+	// "over" operator). dst's bounds are the rendering's rectangle, with
+	// their origin at (0, 0), and p's and paint's coordinates are dst's.
+	// paint is either an *image.Uniform, for a flat color, or a gradient.
+	// Neither p nor paint is valid after Fill returns.
+	//
+	// Rendering stops with the first error that Fill returns.
+	Fill(dst *image.RGBA, p *raster.Path, paint image.Image) error
+}
+
+// Software is the default Backend, package raster's software rasterizer. An
+// Options.Backend that is nil or Software means for the raster.Rasterizer to
+// draw paths itself, which is faster than filling their flattened polygons,
+// and which supports Options such as Antialiasing.
+var Software Backend = software{}
+
+type software struct{}
+
+func (software) Fill(dst *image.RGBA, p *raster.Path, paint image.Image) error {
+	raster.FillPath(dst, p, paint, draw.Over)
+	return nil
+}
+
+// usesBackend returns whether opts select a Backend other than Software.
+func usesBackend(opts *Options) bool {
+	return (opts != nil) && (opts.Backend != nil) && (opts.Backend != Software)
+}
+
+// backendFiller is a raster.PathFiller that passes paths to a Backend.
+type backendFiller struct {
+	b   Backend
+	dst *image.RGBA
+	err error
+}
+
+func (f *backendFiller) FillPath(p *raster.Path, paint image.Image) {
+	if f.err == nil {
+		f.err = f.b.Fill(f.dst, p, paint)
+	}
+}
+
+// originView returns an image that shares m's pixels within r, but whose
+// bounds are translated so that their origin is r.Min.
+func originView(m *image.RGBA, r image.Rectangle) *image.RGBA {
+	r = r.Intersect(m.Rect)
+	v := m.SubImage(r).(*image.RGBA)
+	v.Rect = v.Rect.Sub(r.Min)
+	return v
+}
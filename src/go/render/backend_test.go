@@ -0,0 +1,110 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/raster"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// softwareBackend is a render.Backend that counts its paths and fills them
+// with render.Software, unless err is non-nil.
+type softwareBackend struct {
+	n   int
+	err error
+}
+
+func (b *softwareBackend) Fill(dst *image.RGBA, p *raster.Path, paint image.Image) error {
+	b.n++
+	if b.err != nil {
+		return b.err
+	}
+	return render.Software.Fill(dst, p, paint)
+}
+
+func TestBackend(t *testing.T) {
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg", "lod-polygon.ivg"} {
+		src := readTestData(t, filename)
+		want, err := render.Image(src, 48, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		// Software is the same as no Backend.
+		got, err := render.Image(src, 48, &render.Options{Backend: render.Software})
+		if err != nil {
+			t.Fatalf("%s: Software: %v", filename, err)
+		} else if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s: Software: images differ", filename)
+		}
+
+		b := &softwareBackend{}
+		got, err = render.Image(src, 48, &render.Options{Backend: b})
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if b.n == 0 {
+			t.Errorf("%s: the Backend filled no paths", filename)
+		}
+		// Flattening curves to polygons makes for small differences.
+		for i := range want.Pix {
+			if d := int(want.Pix[i]) - int(got.Pix[i]); (d < -8) || (8 < d) {
+				t.Errorf("%s: pixel (%d, %d): got %v, want %v", filename, (i/4)%48, (i/4)/48, got.Pix[i], want.Pix[i])
+				break
+			}
+		}
+	}
+}
+
+func TestBackendBackground(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	m, err := render.Image(src, 48, &render.Options{
+		Backend:    &softwareBackend{},
+		Background: color.White,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.RGBAAt(0, 0), (color.RGBA{0xff, 0xff, 0xff, 0xff}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	errBackend := errors.New("backend error")
+	src := readTestData(t, "cowbell.ivg")
+	b := &softwareBackend{err: errBackend}
+	if _, err := render.Image(src, 48, &render.Options{Backend: b}); !errors.Is(err, errBackend) {
+		t.Errorf("Image: got %v, want %v", err, errBackend)
+	}
+	// Rendering stops with the first error.
+	if b.n != 1 {
+		t.Errorf("Image: got %d calls to Fill, want 1", b.n)
+	}
+
+	c, err := render.NewCached(src, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Image(&render.Options{Backend: b}); !errors.Is(err, errBackend) {
+		t.Errorf("Cached.Image: got %v, want %v", err, errBackend)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+)
+
+var errJobOutsideDst = errors.New("render: job rectangle is outside of Dst")
+
+// Job is a single rasterization for Batch.
+type Job struct {
+	// Src is the IconVG graphic.
+	Src []byte
+
+	// Size is the width and height, in pixels, of the rasterization.
+	Size int
+
+	// Options may be nil, which means to use the default options.
+	Options *Options
+
+	// Dst, if non-nil, is the image to draw onto, with the rasterization's
+	// top-left corner at DstPoint. Jobs may share a Dst, such as a sprite
+	// sheet, as long as their rectangles do not overlap. If Dst is nil, Batch
+	// allocates a new image.
+	Dst      *image.RGBA
+	DstPoint image.Point
+}
+
+// Batch rasterizes many IconVG graphics, using up to workers goroutines. Each
+// goroutine reuses its rasterizer's buffers from job to job. A non-positive
+// workers means to use runtime.GOMAXPROCS(0) goroutines.
+//
+// The i'th returned image holds the i'th job's rasterization. For a job with a
+// Dst, it is a sub-image of that Dst.
+//
+// Batch stops early if ctx is done or if a job fails, abandoning the jobs in
+// progress as ImageContext does. The error returned is then ctx's error or
+// the failing job's error (the lowest-indexed one, if several jobs fail).
+func Batch(ctx context.Context, jobs []Job, workers int) ([]*image.RGBA, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	images := make([]*image.RGBA, len(jobs))
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := renderers.Get().(*renderer)
+			defer renderers.Put(r)
+			for j := range indexes {
+				if images[j], errs[j] = r.job(stopCtx, &jobs[j]); errs[j] != nil {
+					stop()
+				}
+			}
+		}()
+	}
+
+loop:
+	for j := range jobs {
+		select {
+		case indexes <- j:
+		case <-stopCtx.Done():
+			break loop
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for j, err := range errs {
+		// Jobs abandoned because of another job's failure, or because ctx
+		// is done, failed with stopCtx's error.
+		if (err != nil) && (err != stopCtx.Err()) {
+			return nil, fmt.Errorf("job %d: %w", j, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (x *renderer) job(ctx context.Context, j *Job) (*image.RGBA, error) {
+	if j.Size <= 0 {
+		return nil, errInvalidSize
+	}
+	r := image.Rectangle{Max: image.Point{j.Size, j.Size}}
+	dst := j.Dst
+	if dst == nil {
+		dst = image.NewRGBA(r)
+	} else {
+		r = r.Add(j.DstPoint)
+		if !r.In(dst.Bounds()) {
+			return nil, errJobOutsideDst
+		}
+		dst = dst.SubImage(r).(*image.RGBA)
+	}
+	if err := x.render(ctx, dst, r, j.Src, j.Options); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
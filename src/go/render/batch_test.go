@@ -0,0 +1,111 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"testing"
+
+	"github.com/google/iconvg/src/go/render"
+)
+
+func TestBatch(t *testing.T) {
+	var jobs []render.Job
+	for _, filename := range []string{"action-info.hires.ivg", "cowbell.ivg", "favicon.ivg", "gradient.ivg"} {
+		src := readTestData(t, filename)
+		jobs = append(jobs, render.Job{Src: src, Size: 24}, render.Job{Src: src, Size: 40})
+	}
+	want := make([]*image.RGBA, len(jobs))
+	for i, j := range jobs {
+		m, err := render.Image(j.Src, j.Size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = m
+	}
+
+	for _, workers := range []int{0, 1, 3, 100} {
+		got, err := render.Batch(context.Background(), jobs, workers)
+		if err != nil {
+			t.Errorf("workers=%d: %v", workers, err)
+			continue
+		}
+		if len(got) != len(want) {
+			t.Errorf("workers=%d: got %d images, want %d", workers, len(got), len(want))
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i].Pix, want[i].Pix) {
+				t.Errorf("workers=%d: job %d: pixels differ from Image", workers, i)
+			}
+		}
+	}
+}
+
+func TestBatchSharedDst(t *testing.T) {
+	src := readTestData(t, "action-info.hires.ivg")
+	sheet := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	jobs := []render.Job{
+		{Src: src, Size: 32, Dst: sheet, DstPoint: image.Point{0, 0}},
+		{Src: src, Size: 32, Dst: sheet, DstPoint: image.Point{32, 0}},
+	}
+	got, err := render.Batch(context.Background(), jobs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := render.Image(src, 32, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range got {
+		p := jobs[i].DstPoint
+		if wantBounds := want.Bounds().Add(p); m.Bounds() != wantBounds {
+			t.Errorf("job %d: got bounds %v, want %v", i, m.Bounds(), wantBounds)
+			continue
+		}
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				if g, w := sheet.RGBAAt(p.X+x, p.Y+y), want.RGBAAt(x, y); g != w {
+					t.Fatalf("job %d: pixel (%d, %d): got %v, want %v", i, x, y, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	src := readTestData(t, "action-info.hires.ivg")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		desc string
+		ctx  context.Context
+		jobs []render.Job
+	}{
+		{"invalid size", context.Background(), []render.Job{{Src: src, Size: 32}, {Src: src, Size: 0}}},
+		{"outside of Dst", context.Background(), []render.Job{
+			{Src: src, Size: 32, Dst: image.NewRGBA(image.Rect(0, 0, 32, 32)), DstPoint: image.Point{1, 0}},
+		}},
+		{"invalid graphic", context.Background(), []render.Job{{Src: []byte("\x89IVH\x00"), Size: 32}}},
+		{"cancelled", cancelled, []render.Job{{Src: src, Size: 32}}},
+	}
+	for _, tc := range testCases {
+		if _, err := render.Batch(tc.ctx, tc.jobs, 2); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
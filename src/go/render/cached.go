@@ -0,0 +1,239 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+// Cached rasterizes one IconVG graphic, at one size, again and again with
+// different colors, such as when toggling an icon between light and dark
+// themes.
+//
+// The first Image call decodes the graphic and rasterizes its paths, keeping
+// the rasterized paths (see raster.PathCache) and the graphic's styling
+// instructions. Later calls only re-execute those styling instructions, to
+// resolve the new colors, and composite those colors through the kept paths.
+// They skip decoding and path flattening, unless the FillRule, PixelSnapping
+// or Antialiasing option changes.
+//
+// A Cached is not safe for concurrent use.
+type Cached struct {
+	src      []byte
+	size     int
+	metadata lowlevel.Metadata
+	indices  uint64
+
+	z     raster.Rasterizer
+	paths raster.PathCache
+	rec   recorder
+
+	// recorded is whether rec and paths hold the whole graphic, as rasterized
+	// with the options in key.
+	recorded bool
+	key      cachedKey
+}
+
+// cachedKey is the Options that change how paths are rasterized, rather than
+// only how they are painted.
+type cachedKey struct {
+	fillRule      lowlevel.FillRule
+	pixelSnapping int
+	antialiasing  raster.Antialiasing
+}
+
+// NewCached returns a Cached that rasterizes the IconVG graphic src to
+// size×size images. It retains src, which should not be modified.
+func NewCached(src []byte, size int) (*Cached, error) {
+	if size <= 0 {
+		return nil, errInvalidSize
+	}
+	m, err := lowlevel.DecodeMetadata(src)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := lowlevel.PaletteIndices(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cached{
+		src:      src,
+		size:     size,
+		metadata: m,
+		indices:  indices,
+	}
+	c.z.SetPathCache(&c.paths)
+	c.rec.Rasterizer = &c.z
+	return c, nil
+}
+
+// Image rasterizes the graphic to a new image, like the Image function.
+// Options with a Backend other than Software bypass the cache.
+//
+// opts may be nil, which means to use the default options.
+func (c *Cached) Image(opts *Options) (*image.RGBA, error) {
+	if usesBackend(opts) {
+		return Image(c.src, c.size, opts)
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: image.Point{c.size, c.size}})
+	if (opts != nil) && (opts.Background != nil) {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	// Like lowlevel.LoadPartialPalette, but without walking the graphic to
+	// find its palette indices every time.
+	m := c.metadata
+	key := cachedKey{}
+	if opts != nil {
+		for i, color := range opts.Palette {
+			if (i < 64) && (c.indices&(1<<i) != 0) {
+				m.Palette[i] = color
+			}
+		}
+		key = cachedKey{opts.FillRule, opts.PixelSnapping, opts.Antialiasing}
+	}
+
+	c.z.SetDstImage(dst, dst.Bounds(), draw.Over)
+	c.z.SetAspectRatio(raster.AspectRatioMeet)
+	c.z.SetInterpolation(raster.InterpolationSRGB)
+	if (opts != nil) && opts.LinearInterpolation {
+		c.z.SetInterpolation(raster.InterpolationLinear)
+	}
+	c.z.SetPixelSnapping(0)
+	c.z.SetAntialiasing(raster.AntialiasingAnalytic)
+	c.z.SetGradientQuantization(raster.GradientQuantizationNone)
+	if opts != nil {
+		c.z.SetPixelSnapping(opts.PixelSnapping)
+		c.z.SetAntialiasing(opts.Antialiasing)
+		c.z.SetGradientQuantization(opts.GradientQuantization)
+	}
+	defer c.z.SetDstImage(nil, image.Rectangle{}, draw.Over)
+
+	if c.recorded && (c.key == key) {
+		c.z.Reset(m)
+		c.rec.replay()
+		return dst, nil
+	}
+
+	c.recorded = false
+	c.paths.Reset()
+	err := lowlevel.Decode(&c.rec, c.src, &lowlevel.DecodeOptions{
+		Palette:  &m.Palette,
+		FillRule: key.fillRule,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.recorded, c.key = true, key
+	return dst, nil
+}
+
+// recorder is a Destination that passes everything on to a
+// raster.Rasterizer, recording the styling instructions and the starts and
+// ends of paths, but not the paths' segments, for replaying later.
+type recorder struct {
+	*raster.Rasterizer
+	ops []styleOp
+}
+
+// styleOp is a recorded Destination method call.
+type styleOp struct {
+	method styleMethod
+	adj    uint8
+	incr   bool
+	c      lowlevel.Color
+	f0, f1 float32
+}
+
+type styleMethod uint8
+
+const (
+	methodSetCSel styleMethod = iota
+	methodSetNSel
+	methodSetCReg
+	methodSetNReg
+	methodSetLOD
+	methodStartPath
+	methodClosePathEndPath
+)
+
+func (r *recorder) Reset(m lowlevel.Metadata) {
+	r.ops = r.ops[:0]
+	r.Rasterizer.Reset(m)
+}
+
+func (r *recorder) SetCSel(cSel uint8) {
+	r.ops = append(r.ops, styleOp{method: methodSetCSel, adj: cSel})
+	r.Rasterizer.SetCSel(cSel)
+}
+
+func (r *recorder) SetNSel(nSel uint8) {
+	r.ops = append(r.ops, styleOp{method: methodSetNSel, adj: nSel})
+	r.Rasterizer.SetNSel(nSel)
+}
+
+func (r *recorder) SetCReg(adj uint8, incr bool, c lowlevel.Color) {
+	r.ops = append(r.ops, styleOp{method: methodSetCReg, adj: adj, incr: incr, c: c})
+	r.Rasterizer.SetCReg(adj, incr, c)
+}
+
+func (r *recorder) SetNReg(adj uint8, incr bool, f float32) {
+	r.ops = append(r.ops, styleOp{method: methodSetNReg, adj: adj, incr: incr, f0: f})
+	r.Rasterizer.SetNReg(adj, incr, f)
+}
+
+func (r *recorder) SetLOD(lod0, lod1 float32) {
+	r.ops = append(r.ops, styleOp{method: methodSetLOD, f0: lod0, f1: lod1})
+	r.Rasterizer.SetLOD(lod0, lod1)
+}
+
+func (r *recorder) StartPath(adj uint8, x, y float32) {
+	r.ops = append(r.ops, styleOp{method: methodStartPath, adj: adj})
+	r.Rasterizer.StartPath(adj, x, y)
+}
+
+func (r *recorder) ClosePathEndPath() {
+	r.ops = append(r.ops, styleOp{method: methodClosePathEndPath})
+	r.Rasterizer.ClosePathEndPath()
+}
+
+// replay calls the Rasterizer's methods for the recorded instructions. The
+// paths' starting points are irrelevant, as their segments are not replayed.
+func (r *recorder) replay() {
+	z := r.Rasterizer
+	for i := range r.ops {
+		o := &r.ops[i]
+		switch o.method {
+		case methodSetCSel:
+			z.SetCSel(o.adj)
+		case methodSetNSel:
+			z.SetNSel(o.adj)
+		case methodSetCReg:
+			z.SetCReg(o.adj, o.incr, o.c)
+		case methodSetNReg:
+			z.SetNReg(o.adj, o.incr, o.f0)
+		case methodSetLOD:
+			z.SetLOD(o.f0, o.f1)
+		case methodStartPath:
+			z.StartPath(o.adj, 0, 0)
+		case methodClosePathEndPath:
+			z.ClosePathEndPath()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+	"github.com/google/iconvg/src/go/render"
+)
+
+func TestCached(t *testing.T) {
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	blue := color.RGBA{0x00, 0x00, 0xff, 0xff}
+	// Each test case's options are used in turn, re-using one Cached, and
+	// every image must match the uncached rendering.
+	testCases := []struct {
+		filename string
+		opts     []*render.Options
+	}{{
+		filename: "action-info.lores.ivg",
+		opts: []*render.Options{
+			nil,
+			{Palette: map[uint8]color.RGBA{0: red}},
+			{Palette: map[uint8]color.RGBA{0: blue}},
+			{Palette: map[uint8]color.RGBA{0: blue}, Background: color.White},
+			nil,
+		},
+	}, {
+		filename: "cowbell.ivg",
+		opts: []*render.Options{
+			nil,
+			{LinearInterpolation: true},
+			{FillRule: lowlevel.FillRuleEvenOdd},
+			{PixelSnapping: 48},
+			{Antialiasing: raster.Antialiasing4x},
+			{Antialiasing: raster.AntialiasingNone, Palette: map[uint8]color.RGBA{0: red}},
+			{Backend: &softwareBackend{}},
+			nil,
+		},
+	}, {
+		filename: "gradient.ivg",
+		opts: []*render.Options{
+			nil,
+			{LinearInterpolation: true},
+			nil,
+		},
+	}, {
+		filename: "lod-polygon.ivg",
+		opts: []*render.Options{
+			nil,
+			nil,
+		},
+	}}
+	for _, tc := range testCases {
+		src := readTestData(t, tc.filename)
+		c, err := render.NewCached(src, 48)
+		if err != nil {
+			t.Fatalf("%s: NewCached: %v", tc.filename, err)
+		}
+		for i, opts := range tc.opts {
+			got, err := c.Image(opts)
+			if err != nil {
+				t.Errorf("%s #%d: Cached.Image: %v", tc.filename, i, err)
+				continue
+			}
+			want, err := render.Image(src, 48, opts)
+			if err != nil {
+				t.Fatalf("%s #%d: Image: %v", tc.filename, i, err)
+			}
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("%s #%d: cached and uncached images differ", tc.filename, i)
+			}
+		}
+	}
+}
+
+func TestNewCachedErrors(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	testCases := []struct {
+		desc string
+		src  []byte
+		size int
+	}{
+		{"zero size", src, 0},
+		{"negative size", src, -1},
+		{"empty src", nil, 48},
+		{"bad magic", []byte("\x8a\x49\x56\x48\x00"), 48},
+	}
+	for _, tc := range testCases {
+		if _, err := render.NewCached(tc.src, tc.size); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
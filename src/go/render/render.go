@@ -0,0 +1,189 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render rasterizes IconVG graphics to fixed size, square images,
+// such as the PNG files used by icon pipelines.
+//
+// It is a convenience layer over package raster, adding background colors,
+// partial palette overrides and pluggable rasterization Backends. It also
+// exports the experimental lowlevel.Animation container as animated GIF and
+// APNG images.
+package render
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sync"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/raster"
+)
+
+var (
+	errNoFrames    = errors.New("render: animation has no frames")
+	errInvalidSize = errors.New("render: invalid size")
+)
+
+// Options are the optional parameters to Image and PNG.
+type Options struct {
+	// Background is the color that the graphic is drawn over. Nil means
+	// transparent.
+	Background color.Color
+
+	// Palette overrides entries of the graphic's suggested palette, keyed by
+	// palette index. Entries that the graphic does not refer to are ignored.
+	// See lowlevel.LoadPartialPalette.
+	Palette map[uint8]color.RGBA
+
+	// LinearInterpolation is whether blend colors and gradients interpolate
+	// in linear light instead of in sRGB. See raster.InterpolationLinear.
+	LinearInterpolation bool
+
+	// FillRule is the rule that paths are filled with. The default, like
+	// the specification, is non-zero. See lowlevel.DecodeOptions.FillRule.
+	FillRule lowlevel.FillRule
+
+	// PixelSnapping is the largest size at which horizontal and vertical
+	// edges are snapped to pixel boundaries, for crisper small icons. Zero
+	// means to never snap. See raster.Rasterizer.SetPixelSnapping.
+	PixelSnapping int
+
+	// Antialiasing is how edges are anti-aliased. The default is exact,
+	// analytic coverage. See raster.Rasterizer.SetAntialiasing.
+	Antialiasing raster.Antialiasing
+
+	// GradientQuantization quantizes gradients' colors, with or without
+	// dithering, for low bit depth displays. The default is to not quantize.
+	// See raster.Rasterizer.SetGradientQuantization.
+	GradientQuantization raster.GradientQuantization
+
+	// Backend fills the graphic's paths. Nil means Software. Options that
+	// configure the software rasterizer, such as Antialiasing, are ignored
+	// by other Backends.
+	Backend Backend
+}
+
+// Image rasterizes the IconVG graphic src to a new size×size image. The
+// graphic's viewBox is scaled to fit, preserving its aspect ratio, and is
+// centered.
+//
+// opts may be nil, which means to use the default options.
+func Image(src []byte, size int, opts *Options) (*image.RGBA, error) {
+	return ImageContext(context.Background(), src, size, opts)
+}
+
+// ImageContext is like Image but stops, returning ctx's error, once ctx is
+// done. ctx is checked before each of the graphic's opcodes, so that a
+// server can abandon rendering an adversarial graphic, with very many paths
+// or path segments, when its client disconnects.
+func ImageContext(ctx context.Context, src []byte, size int, opts *Options) (*image.RGBA, error) {
+	if size <= 0 {
+		return nil, errInvalidSize
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: image.Point{size, size}})
+	r := renderers.Get().(*renderer)
+	defer renderers.Put(r)
+	if err := r.render(ctx, dst, dst.Bounds(), src, opts); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// renderer holds the state for rasterizing graphics. Reusing it avoids
+// re-allocating the rasterizer's buffers.
+type renderer struct {
+	z raster.Rasterizer
+	d lowlevel.Decoder
+	f backendFiller
+}
+
+var renderers = sync.Pool{
+	New: func() interface{} { return &renderer{} },
+}
+
+// render rasterizes src onto the rectangle r of dst.
+func (x *renderer) render(ctx context.Context, dst *image.RGBA, r image.Rectangle, src []byte, opts *Options) error {
+	if (opts != nil) && (opts.Background != nil) {
+		draw.Draw(dst, r, image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	decodeOpts := (*lowlevel.DecodeOptions)(nil)
+	if (opts != nil) && (len(opts.Palette) > 0) {
+		pal, err := lowlevel.LoadPartialPalette(src, opts.Palette)
+		if err != nil {
+			return err
+		}
+		decodeOpts = raster.WithPalette(pal)
+	}
+	if (opts != nil) && (opts.FillRule != lowlevel.FillRuleNonZero) {
+		if decodeOpts == nil {
+			decodeOpts = &lowlevel.DecodeOptions{}
+		}
+		decodeOpts.FillRule = opts.FillRule
+	}
+	if ctx.Done() != nil {
+		if decodeOpts == nil {
+			decodeOpts = &lowlevel.DecodeOptions{}
+		}
+		decodeOpts.Context = ctx
+	}
+
+	x.z.SetDstImage(dst, r, draw.Over)
+	x.z.SetAspectRatio(raster.AspectRatioMeet)
+	x.z.SetInterpolation(raster.InterpolationSRGB)
+	if (opts != nil) && opts.LinearInterpolation {
+		x.z.SetInterpolation(raster.InterpolationLinear)
+	}
+	x.z.SetPixelSnapping(0)
+	x.z.SetAntialiasing(raster.AntialiasingAnalytic)
+	x.z.SetGradientQuantization(raster.GradientQuantizationNone)
+	if opts != nil {
+		x.z.SetPixelSnapping(opts.PixelSnapping)
+		x.z.SetAntialiasing(opts.Antialiasing)
+		x.z.SetGradientQuantization(opts.GradientQuantization)
+	}
+	if usesBackend(opts) {
+		x.f = backendFiller{b: opts.Backend, dst: originView(dst, r)}
+		x.z.SetPathFiller(&x.f)
+	}
+	err := x.d.Decode(&x.z, src, decodeOpts)
+	if err == nil {
+		err = x.f.err
+	}
+	// Don't keep dst, or the Backend, alive while x is in the pool.
+	x.z.SetDstImage(nil, image.Rectangle{}, draw.Over)
+	x.z.SetPathFiller(nil)
+	x.f = backendFiller{}
+	return err
+}
+
+// PNG is like Image but writes the image to w in the PNG format.
+func PNG(w io.Writer, src []byte, size int, opts *Options) error {
+	return PNGContext(context.Background(), w, src, size, opts)
+}
+
+// PNGContext is like ImageContext but writes the image to w in the PNG
+// format.
+func PNGContext(ctx context.Context, w io.Writer, src []byte, size int, opts *Options) error {
+	m, err := ImageContext(ctx, src, size, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, m)
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/render"
+)
+
+func readTestData(t *testing.T, filename string) []byte {
+	t.Helper()
+	src, err := os.ReadFile("../../../test/data/" + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestImage(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	transparent := color.RGBA{}
+	black := color.RGBA{0x00, 0x00, 0x00, 0xff}
+	red := color.RGBA{0xff, 0x00, 0x00, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	// action-info.lores's viewBox is 48 units square. At size 48, (2, 2) is
+	// outside of its circle and (24, 6) is inside.
+	testCases := []struct {
+		desc       string
+		opts       *render.Options
+		wantCorner color.RGBA
+		wantCircle color.RGBA
+	}{
+		{"nil options", nil, transparent, black},
+		{"background", &render.Options{Background: white}, white, black},
+		{"palette", &render.Options{Palette: map[uint8]color.RGBA{0: red}}, transparent, red},
+		{"unused palette entry", &render.Options{Palette: map[uint8]color.RGBA{9: red}}, transparent, black},
+	}
+	for _, tc := range testCases {
+		m, err := render.Image(src, 48, tc.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if got := m.Bounds(); got != image.Rect(0, 0, 48, 48) {
+			t.Errorf("%s: bounds: got %v, want %v", tc.desc, got, image.Rect(0, 0, 48, 48))
+		}
+		if got := m.RGBAAt(2, 2); got != tc.wantCorner {
+			t.Errorf("%s: corner: got %v, want %v", tc.desc, got, tc.wantCorner)
+		}
+		if got := m.RGBAAt(24, 6); got != tc.wantCircle {
+			t.Errorf("%s: circle: got %v, want %v", tc.desc, got, tc.wantCircle)
+		}
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	testCases := []struct {
+		desc string
+		src  []byte
+		size int
+	}{
+		{"zero size", src, 0},
+		{"negative size", src, -1},
+		{"bad magic identifier", []byte("\x89IVH\x00"), 48},
+		{"truncated instruction", src[:len(src)-2], 48},
+	}
+	for _, tc := range testCases {
+		if _, err := render.Image(tc.src, tc.size, nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
+
+func TestImageContext(t *testing.T) {
+	src := readTestData(t, "cowbell.ivg")
+	want, err := render.Image(src, 48, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := render.ImageContext(context.Background(), src, 48, nil)
+	if err != nil {
+		t.Fatalf("background: %v", err)
+	} else if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("background: images differ")
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, opts := range []*render.Options{nil, {Background: color.White}, {Backend: render.Software}} {
+		if _, err := render.ImageContext(cancelled, src, 48, opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("ImageContext, opts=%+v: got %v, want %v", opts, err, context.Canceled)
+		}
+		if err := render.PNGContext(cancelled, io.Discard, src, 48, opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("PNGContext, opts=%+v: got %v, want %v", opts, err, context.Canceled)
+		}
+	}
+	if _, err := render.Batch(cancelled, []render.Job{{Src: src, Size: 48}}, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Batch: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	for _, filename := range []string{"action-info.hires.ivg", "cowbell.ivg", "video-005.primitive.ivg"} {
+		src := readTestData(t, filename)
+		want, err := render.Image(src, 32, nil)
+		if err != nil {
+			t.Errorf("%s: Image: %v", filename, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := render.PNG(buf, src, 32, nil); err != nil {
+			t.Errorf("%s: PNG: %v", filename, err)
+			continue
+		}
+		got, err := png.Decode(buf)
+		if err != nil {
+			t.Errorf("%s: png.Decode: %v", filename, err)
+			continue
+		}
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				if g, w := color.NRGBAModel.Convert(got.At(x, y)), color.NRGBAModel.Convert(want.At(x, y)); g != w {
+					t.Fatalf("%s: pixel (%d, %d): got %v, want %v", filename, x, y, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestLinearInterpolation(t *testing.T) {
+	// Interpolating in linear light gives lighter midpoints than in sRGB,
+	// and so a lighter image overall.
+	for _, filename := range []string{"gradient.ivg", "gradient-spreads.ivg"} {
+		src := readTestData(t, filename)
+		var sums [2]int
+		for i, linear := range []bool{false, true} {
+			m, err := render.Image(src, 64, &render.Options{LinearInterpolation: linear})
+			if err != nil {
+				t.Fatalf("%s: %v", filename, err)
+			}
+			for j, p := range m.Pix {
+				if j%4 != 3 {
+					sums[i] += int(p)
+				}
+			}
+		}
+		if sums[1] <= sums[0] {
+			t.Errorf("%s: got sums %d (linear) and %d (sRGB), want linear > sRGB", filename, sums[1], sums[0])
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var errInvalidSpread = errors.New("render: invalid spread")
+
+// SDF computes a size×size signed distance field of the IconVG graphic data:
+// the distance from each pixel's center to the outline of the area that the
+// graphic paints, regardless of color. The graphic's viewBox is fit to the
+// image as for Image.
+//
+// spread is the largest distance, in pixels, that the field represents. A
+// pixel's value is 0x80 on the outline, rising to 0xff at spread or more
+// pixels inside and falling to 0x00 at spread or more pixels outside.
+// Sampling such a field with bilinear filtering and thresholding at one half,
+// as game engines' text and icon shaders do, draws the graphic crisply at
+// any scale.
+func SDF(data []byte, size int, spread float32) (*image.Gray, error) {
+	f, err := newDistanceField(data, size, spread)
+	if err != nil {
+		return nil, err
+	}
+	dst := image.NewGray(image.Rectangle{Max: image.Point{size, size}})
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := f.center(x, y)
+			d := f.distance(p, allChannels)
+			if !f.inside(p) {
+				d = -d
+			}
+			dst.Pix[y*dst.Stride+x] = f.quantize(d)
+		}
+	}
+	return dst, nil
+}
+
+// MSDF is like SDF but computes a multi-channel signed distance field, which
+// keeps the graphic's corners sharp when magnified. Each of the red, green
+// and blue channels is the distance to a subset of the outline's edges, and
+// a shader reconstructs the outline from their median. The alpha channel is
+// opaque.
+//
+// The edges are assigned to channels, and the distances signed, as by the
+// msdfgen tool (https://github.com/Chlumsky/msdfgen), without its error
+// correction.
+func MSDF(data []byte, size int, spread float32) (*image.NRGBA, error) {
+	f, err := newDistanceField(data, size, spread)
+	if err != nil {
+		return nil, err
+	}
+	f.colorEdges()
+	dst := image.NewNRGBA(image.Rectangle{Max: image.Point{size, size}})
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := f.center(x, y)
+			i := y*dst.Stride + 4*x
+			for c := 0; c < 3; c++ {
+				dst.Pix[i+c] = f.quantize(f.pseudoDistance(p, 1<<uint(c)))
+			}
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst, nil
+}
+
+// Channel masks, for the edges of a multi-channel signed distance field.
+const (
+	red         = 1
+	green       = 2
+	blue        = 4
+	allChannels = red | green | blue
+)
+
+// distanceField is the outline of a graphic, as polygons in pixel
+// coordinates, for computing distances to it.
+type distanceField struct {
+	// segs are the polygons' edges, in order around each polygon, as wound
+	// by ivg.Union. The i'th polygon is segs[ends[i-1]:ends[i]], where
+	// ends[-1] is taken to be zero.
+	segs   []fieldSeg
+	ends   []int
+	spread float64
+	// originX and originY are the graphic coordinates of the image's
+	// top-left corner, and scale is the number of pixels per graphic unit.
+	originX, originY float64
+	scale            float64
+}
+
+// fieldSeg is a line segment of a distanceField's outline.
+type fieldSeg struct {
+	a, b [2]float64
+	// channels is the segment's MSDF edge color. corner0 and corner1 are
+	// whether a and b are corners, where an MSDF edge starts or ends.
+	channels         uint8
+	corner0, corner1 bool
+}
+
+func newDistanceField(data []byte, size int, spread float32) (*distanceField, error) {
+	if size <= 0 {
+		return nil, errInvalidSize
+	} else if !(spread > 0) || math.IsInf(float64(spread), 0) {
+		return nil, errInvalidSpread
+	}
+	g, err := ivg.Decode(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fit the viewBox as render does, with raster.AspectRatioMeet.
+	vb := g.Metadata.ViewBox
+	vw, vh := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	f := &distanceField{spread: float64(spread), scale: 1}
+	if (vw > 0) && (vh > 0) {
+		f.scale = math.Min(float64(size)/vw, float64(size)/vh)
+	} else {
+		vw, vh = 0, 0
+	}
+	f.originX = float64(vb.Min[0]) - (float64(size)/f.scale-vw)/2
+	f.originY = float64(vb.Min[1]) - (float64(size)/f.scale-vh)/2
+
+	// Combine the painted Shapes' areas. ivg.Union fills its result with
+	// the non-zero rule, and winds the polygons that it returns with filled
+	// areas on the same side, so that concatenating them and taking their
+	// union again combines their areas.
+	tol := float32(1 / (16 * f.scale))
+	lodHeight := float32(vh * f.scale)
+	all := ivg.Path(nil)
+	for _, s := range g.Shapes {
+		if !((s.LOD0 <= lodHeight) && (lodHeight < s.LOD1)) || !painted(&s.Paint) {
+			continue
+		}
+		p := s.Path
+		if s.FillRule == lowlevel.FillRuleEvenOdd {
+			p = ivg.EvenOddToNonZero(p, tol)
+		}
+		all = append(all, ivg.Union(p, nil, tol)...)
+	}
+	f.addPolygons(ivg.Union(all, nil, tol))
+	return f, nil
+}
+
+// painted returns whether p paints anything: whether it is a gradient or a
+// flat color that is not fully transparent.
+func painted(p *ivg.Paint) bool {
+	if p.Gradient != nil {
+		return true
+	}
+	c, ok := p.Color.RGBA()
+	return !ok || (c.A != 0)
+}
+
+// addPolygons adds the closed polygons of p, as returned by ivg.Union, to
+// f's outline.
+func (f *distanceField) addPolygons(p ivg.Path) {
+	poly := [][2]float64(nil)
+	flush := func() {
+		n := len(poly)
+		if n < 3 {
+			poly = poly[:0]
+			return
+		}
+		for i := range poly {
+			f.segs = append(f.segs, fieldSeg{a: poly[i], b: poly[(i+1)%n], channels: allChannels})
+		}
+		f.ends = append(f.ends, len(f.segs))
+		poly = poly[:0]
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			flush()
+			poly = append(poly, f.pixel(seg.To))
+		case ivg.LineTo:
+			poly = append(poly, f.pixel(seg.To))
+		case ivg.ClosePath:
+			flush()
+		}
+	}
+	flush()
+}
+
+// pixel converts from graphic coordinates to pixel coordinates.
+func (f *distanceField) pixel(v f32.Vec2) [2]float64 {
+	return [2]float64{
+		(float64(v[0]) - f.originX) * f.scale,
+		(float64(v[1]) - f.originY) * f.scale,
+	}
+}
+
+// center returns the center of the pixel (x, y).
+func (f *distanceField) center(x, y int) [2]float64 {
+	return [2]float64{float64(x) + 0.5, float64(y) + 0.5}
+}
+
+// quantize maps a signed distance, in pixels, to a pixel value.
+func (f *distanceField) quantize(d float64) uint8 {
+	v := 0.5 + 0.5*d/f.spread
+	if v <= 0 {
+		return 0x00
+	} else if v >= 1 {
+		return 0xff
+	}
+	return uint8(v*0xff + 0.5)
+}
+
+// inside returns whether p is inside the outline.
+func (f *distanceField) inside(p [2]float64) bool {
+	w := 0
+	for i := range f.segs {
+		a, b := f.segs[i].a, f.segs[i].b
+		if (a[1] <= p[1]) != (b[1] <= p[1]) {
+			x := a[0] + (p[1]-a[1])*(b[0]-a[0])/(b[1]-a[1])
+			if x > p[0] {
+				if a[1] < b[1] {
+					w++
+				} else {
+					w--
+				}
+			}
+		}
+	}
+	return w != 0
+}
+
+// distance returns the unsigned distance from p to the nearest segment
+// whose channels intersect the given mask.
+func (f *distanceField) distance(p [2]float64, mask uint8) float64 {
+	best := math.Inf(+1)
+	for i := range f.segs {
+		if s := &f.segs[i]; s.channels&mask != 0 {
+			if d, _, _ := s.nearest(p); d < best {
+				best = d
+			}
+		}
+	}
+	return best
+}
+
+// nearest returns the distance from p to s, the parameter t, in [0, 1], of
+// the nearest point on s, and how far p is from perpendicular to s there,
+// for breaking ties between segments that meet at that point.
+func (s *fieldSeg) nearest(p [2]float64) (d float64, t float64, oblique float64) {
+	dx, dy := s.b[0]-s.a[0], s.b[1]-s.a[1]
+	px, py := p[0]-s.a[0], p[1]-s.a[1]
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, (px*dx+py*dy)/l2))
+	}
+	qx, qy := px-t*dx, py-t*dy
+	d = math.Hypot(qx, qy)
+	if (d > 0) && (t == 0 || t == 1) {
+		oblique = math.Abs(qx*dx+qy*dy) / (d * math.Hypot(dx, dy))
+	}
+	return d, t, oblique
+}
+
+// pseudoDistance returns the signed pseudo-distance from p to the nearest
+// segment whose channels intersect the given mask. It is positive inside.
+// Past a corner, it is the distance to the segment's line, extended, which
+// is what makes corners sharp.
+func (f *distanceField) pseudoDistance(p [2]float64, mask uint8) float64 {
+	// Segments that meet at the nearest point are equally near, give or
+	// take rounding error, within eps.
+	const eps = 1e-9
+	best, bestOblique, bestT, bestSeg := math.Inf(+1), 0.0, 0.0, (*fieldSeg)(nil)
+	for i := range f.segs {
+		s := &f.segs[i]
+		if s.channels&mask == 0 {
+			continue
+		}
+		d, t, oblique := s.nearest(p)
+		if (d < best-eps) || ((d <= best+eps) && (oblique < bestOblique)) {
+			best, bestOblique, bestT, bestSeg = d, oblique, t, s
+		}
+	}
+	if bestSeg == nil {
+		return math.Inf(-1)
+	}
+	s := bestSeg
+	dx, dy := s.b[0]-s.a[0], s.b[1]-s.a[1]
+	cross := dx*(p[1]-s.a[1]) - dy*(p[0]-s.a[0])
+	// ivg.Union winds its polygons so that filled areas are where cross is
+	// positive.
+	sign := 1.0
+	if cross < 0 {
+		sign = -1
+	}
+	if ((bestT == 0) && s.corner0) || ((bestT == 1) && s.corner1) {
+		if l := math.Hypot(dx, dy); l > 0 {
+			return cross / l
+		}
+	}
+	return sign * best
+}
+
+// colorEdges splits each polygon of f's outline into edges at its corners and
+// assigns each edge two of the three channels, so that the edges either side
+// of a corner differ, as msdfgen's simple edge coloring does. A polygon
+// without corners keeps all three channels.
+func (f *distanceField) colorEdges() {
+	// sin(3 radians), msdfgen's default corner threshold, is the sine of the
+	// smallest turn, between consecutive segments, that makes a corner.
+	const crossThreshold = 0.14112000805986721
+	colors := [3]uint8{red | blue, green | blue, red | green}
+	start := 0
+	for _, end := range f.ends {
+		poly := f.segs[start:end]
+		start = end
+
+		corners := []int(nil)
+		for i := range poly {
+			prev := &poly[(i+len(poly)-1)%len(poly)]
+			if isCorner(prev, &poly[i], crossThreshold) {
+				corners = append(corners, i)
+				prev.corner1, poly[i].corner0 = true, true
+			}
+		}
+		if len(corners) == 0 {
+			continue
+		}
+		// Each edge runs from one corner to the next. With only one corner,
+		// split its edge in three, as msdfgen does, so that no corner has
+		// the same color on both sides.
+		nEdges := len(corners)
+		if nEdges == 1 {
+			nEdges = 3
+		}
+		for i := range poly {
+			j := (i - corners[0] + len(poly)) % len(poly)
+			e := 0
+			if len(corners) == 1 {
+				e = 3 * j / len(poly)
+			} else {
+				for (e+1 < len(corners)) && ((corners[e+1]-corners[0]+len(poly))%len(poly) <= j) {
+					e++
+				}
+			}
+			c := colors[e%3]
+			// The last edge must differ from the first, at corners[0].
+			if (e == nEdges-1) && (e%3 == 0) {
+				c = colors[1]
+			}
+			poly[i].channels = c
+		}
+	}
+}
+
+// isCorner returns whether the turn from segment s to segment t, where s ends
+// and t starts, is sharp.
+func isCorner(s, t *fieldSeg, crossThreshold float64) bool {
+	ax, ay := s.b[0]-s.a[0], s.b[1]-s.a[1]
+	bx, by := t.b[0]-t.a[0], t.b[1]-t.a[1]
+	la, lb := math.Hypot(ax, ay), math.Hypot(bx, by)
+	if (la == 0) || (lb == 0) {
+		return false
+	}
+	ax, ay, bx, by = ax/la, ay/la, bx/lb, by/lb
+	return (ax*bx+ay*by <= 0) || (math.Abs(ax*by-ay*bx) > crossThreshold)
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivgasm"
+	"github.com/google/iconvg/src/go/render"
+)
+
+// square is a graphic whose 32 unit square is 16 pixels from each edge of a
+// 64×64 image.
+func square(t *testing.T) []byte {
+	t.Helper()
+	src, err := ivgasm.Assemble([]byte("magic\nmetadata 1\nviewBox 0 0 64 64\n" +
+		"path [csel] 16 16\nL 48 16 48 48 16 48\nz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// median returns the median of an MSDF pixel's red, green and blue values.
+func median(pix []uint8) uint8 {
+	v := []int{int(pix[0]), int(pix[1]), int(pix[2])}
+	sort.Ints(v)
+	return uint8(v[1])
+}
+
+func TestSDF(t *testing.T) {
+	sdf, err := render.SDF(square(t), 64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msdf, err := render.MSDF(square(t), 64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A pixel's value is 0xff×(1/2 + d/8), rounded and clamped, for a
+	// distance d inside the outline, measured from the pixel's center.
+	testCases := []struct {
+		x, y int
+		want uint8
+	}{
+		{0, 0, 0x00},
+		{32, 32, 0xff},
+		{17, 32, 0xaf},
+		{14, 32, 0x50},
+		{32, 46, 0xaf},
+		{32, 49, 0x50},
+		{15, 32, 0x70},
+		{16, 32, 0x8f},
+		{10, 32, 0x00},
+	}
+	for _, tc := range testCases {
+		if got := sdf.GrayAt(tc.x, tc.y).Y; got != tc.want {
+			t.Errorf("SDF: (%d, %d): got %#02x, want %#02x", tc.x, tc.y, got, tc.want)
+		}
+		i := msdf.PixOffset(tc.x, tc.y)
+		if got := median(msdf.Pix[i : i+3]); got != tc.want {
+			t.Errorf("MSDF: (%d, %d): got %#02x, want %#02x", tc.x, tc.y, got, tc.want)
+		}
+		if got := msdf.Pix[i+3]; got != 0xff {
+			t.Errorf("MSDF: (%d, %d): alpha: got %#02x, want 0xff", tc.x, tc.y, got)
+		}
+	}
+}
+
+func TestSDFTestData(t *testing.T) {
+	// Pixels that Image paints fully are inside, and those that it leaves
+	// transparent are outside.
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "lod-polygon.ivg"} {
+		src := readTestData(t, filename)
+		m, err := render.Image(src, 48, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		sdf, err := render.SDF(src, 48, 2)
+		if err != nil {
+			t.Fatalf("%s: SDF: %v", filename, err)
+		}
+		msdf, err := render.MSDF(src, 48, 2)
+		if err != nil {
+			t.Fatalf("%s: MSDF: %v", filename, err)
+		}
+		for y := 0; y < 48; y++ {
+			for x := 0; x < 48; x++ {
+				a := m.RGBAAt(x, y).A
+				if (a != 0x00) && (a != 0xff) {
+					continue
+				}
+				if got, want := sdf.GrayAt(x, y).Y >= 0x80, a == 0xff; got != want {
+					t.Errorf("%s: SDF: (%d, %d): got inside %t, want %t", filename, x, y, got, want)
+				}
+				i := msdf.PixOffset(x, y)
+				if got, want := median(msdf.Pix[i:i+3]) >= 0x80, a == 0xff; got != want {
+					t.Errorf("%s: MSDF: (%d, %d): got inside %t, want %t", filename, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSDFErrors(t *testing.T) {
+	src := square(t)
+	testCases := []struct {
+		desc   string
+		src    []byte
+		size   int
+		spread float32
+	}{
+		{"zero size", src, 0, 4},
+		{"zero spread", src, 64, 0},
+		{"negative spread", src, 64, -1},
+		{"NaN spread", src, 64, float32(math.NaN())},
+		{"infinite spread", src, 64, float32(math.Inf(+1))},
+		{"bad magic", []byte("\x89IVH\x00"), 64, 4},
+	}
+	for _, tc := range testCases {
+		if _, err := render.SDF(tc.src, tc.size, tc.spread); err == nil {
+			t.Errorf("%s: SDF: got nil error, want non-nil", tc.desc)
+		}
+		if _, err := render.MSDF(tc.src, tc.size, tc.spread); err == nil {
+			t.Errorf("%s: MSDF: got nil error, want non-nil", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// clipRegion returns the region, in the graphic's coordinate space, that n's
+// clip-path property clips n's content to. ctx is the context for n's
+// content, including n's transform. It returns ok == false if n is not
+// clipped: if the property is unset, or if, like SVG, it does not refer to a
+// clipPath element.
+//
+// The region is the union of the clipPath's shapes, each filled with its
+// clip-rule. The clipPath's own clip-path property, and its children's, are
+// ignored, as are its text and use elements.
+func (c *converter) clipRegion(n *node, ctx context) (region ivg.Path, ok bool, err error) {
+	s := n.prop("clip-path")
+	if s == "" || s == "none" {
+		return nil, false, nil
+	}
+	id, isURL := parseURL(s)
+	cp := c.ids[id]
+	if !isURL || cp == nil || cp.name != "clipPath" {
+		return nil, false, nil
+	}
+
+	toUser := ctx.transform
+	if cp.attrs["clipPathUnits"] == "objectBoundingBox" {
+		min, max, nonEmpty, err := c.objectBounds(n, identity)
+		if err != nil {
+			return nil, false, err
+		} else if !nonEmpty {
+			return nil, true, nil
+		}
+		w, h := float64(max[0]-min[0]), float64(max[1]-min[1])
+		toUser = toUser.mul(aff{w, 0, float64(min[0]), 0, h, float64(min[1])})
+	}
+	if s := cp.attrs["transform"]; s != "" {
+		t, err := parseTransform(s)
+		if err != nil {
+			return nil, false, err
+		}
+		toUser = toUser.mul(t)
+	}
+
+	for _, child := range cp.children {
+		if !isShape(child.name) || child.prop("display") == "none" {
+			continue
+		}
+		p, err := c.geometry(child)
+		if err != nil {
+			return nil, false, err
+		} else if len(p) == 0 {
+			continue
+		}
+		m := toUser
+		if s := child.attrs["transform"]; s != "" {
+			t, err := parseTransform(s)
+			if err != nil {
+				return nil, false, err
+			}
+			m = m.mul(t)
+		}
+		rule := child.prop("clip-rule")
+		if rule == "" || rule == "inherit" {
+			rule = cp.prop("clip-rule")
+		}
+		if rule == "evenodd" {
+			p = ivg.EvenOddToNonZero(p, 0)
+		}
+		q := make(ivg.Path, len(p))
+		for i, seg := range p {
+			q[i] = transformSegment(seg, m)
+		}
+		region = ivg.Union(region, q, 0)
+	}
+	return region, true, nil
+}
+
+// objectBounds returns the bounding box, after transforming by m, of the
+// shapes of n and its descendants, for a clipPath whose clipPathUnits is
+// objectBoundingBox. nonEmpty is false if there are no such shapes.
+func (c *converter) objectBounds(n *node, m aff) (min, max f32.Vec2, nonEmpty bool, err error) {
+	if n.prop("display") == "none" {
+		return min, max, false, nil
+	}
+	if isShape(n.name) {
+		p, err := c.geometry(n)
+		if err != nil || len(p) == 0 {
+			return min, max, false, err
+		}
+		q := make(ivg.Path, len(p))
+		for i, seg := range p {
+			q[i] = transformSegment(seg, m)
+		}
+		min, max = bounds(q)
+		return min, max, true, nil
+	}
+
+	switch n.name {
+	case "svg", "g", "a", "switch":
+	default:
+		return min, max, false, nil
+	}
+	for _, child := range n.children {
+		cm := m
+		if s := child.attrs["transform"]; s != "" {
+			t, err := parseTransform(s)
+			if err != nil {
+				return min, max, false, err
+			}
+			cm = cm.mul(t)
+		}
+		cmin, cmax, ok, err := c.objectBounds(child, cm)
+		if err != nil {
+			return min, max, false, err
+		} else if !ok {
+			continue
+		}
+		if !nonEmpty {
+			min, max, nonEmpty = cmin, cmax, true
+			continue
+		}
+		for i := range min {
+			min[i] = float32(math.Min(float64(min[i]), float64(cmin[i])))
+			max[i] = float32(math.Max(float64(max[i]), float64(cmax[i])))
+		}
+	}
+	return min, max, nonEmpty, nil
+}
+
+// clipShape clips p, a path in the graphic's coordinate space filled with
+// the given rule, to the region. A shape that is entirely within the region
+// is returned unchanged. Otherwise, the result is flattened (see
+// ivg.Intersect) and filled with the nonzero winding rule.
+func clipShape(p ivg.Path, rule lowlevel.FillRule, region ivg.Path) (ivg.Path, lowlevel.FillRule) {
+	min, max := bounds(p)
+	box := ivg.Path{
+		ivg.MoveTo{To: min},
+		ivg.LineTo{To: f32.Vec2{max[0], min[1]}},
+		ivg.LineTo{To: max},
+		ivg.LineTo{To: f32.Vec2{min[0], max[1]}},
+		ivg.ClosePath{},
+	}
+	if len(ivg.Subtract(box, region, 0)) == 0 {
+		return p, rule
+	}
+	if rule == lowlevel.FillRuleEvenOdd {
+		p = ivg.EvenOddToNonZero(p, 0)
+	}
+	return ivg.Intersect(p, region, 0), lowlevel.FillRuleNonZero
+}
+
+// isShape returns whether name is that of a basic shape or path element.
+func isShape(name string) bool {
+	switch name {
+	case "path", "rect", "circle", "ellipse", "line", "polygon", "polyline":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/svgconv"
+	"golang.org/x/image/math/f32"
+)
+
+// clipArea returns the area filled by a Path of MoveTo, LineTo and ClosePath
+// segments, with holes subtracted, and the bounds of its points. It returns
+// ok == false if the Path has curves.
+func clipArea(p ivg.Path) (a float64, min, max f32.Vec2, ok bool) {
+	min, max = f32.Vec2{+1e9, +1e9}, f32.Vec2{-1e9, -1e9}
+	start, pen := f32.Vec2{}, f32.Vec2{}
+	add := func(q f32.Vec2) {
+		a += float64(pen[0])*float64(q[1]) - float64(q[0])*float64(pen[1])
+		pen = q
+	}
+	for _, seg := range p {
+		var q f32.Vec2
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			start, pen, q = seg.To, seg.To, seg.To
+		case ivg.LineTo:
+			q = seg.To
+			add(q)
+		case ivg.ClosePath:
+			add(start)
+			continue
+		default:
+			return 0, min, max, false
+		}
+		for i := range q {
+			min[i] = float32(math.Min(float64(min[i]), float64(q[i])))
+			max[i] = float32(math.Max(float64(max[i]), float64(q[i])))
+		}
+	}
+	return math.Abs(a / 2), min, max, true
+}
+
+func TestParseClipPath(t *testing.T) {
+	const square = `<rect x="0" y="0" width="24" height="24" fill="#f00" `
+	testCases := []struct {
+		desc      string
+		body      string
+		wantShape bool
+		wantArea  float64
+		// wantMin and wantMax bound the clipped shape's path.
+		wantMin, wantMax f32.Vec2
+	}{{
+		desc:      "rect",
+		body:      `<clipPath id="c"><rect x="4" y="4" width="8" height="8"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  64,
+		wantMin:   f32.Vec2{4, 4},
+		wantMax:   f32.Vec2{12, 12},
+	}, {
+		desc:      "union",
+		body:      `<clipPath id="c"><rect x="0" y="0" width="4" height="4"/><rect x="2" y="2" width="4" height="4"/></clipPath>` + square + `style="clip-path:url(#c)"/>`,
+		wantShape: true,
+		wantArea:  28,
+		wantMin:   f32.Vec2{0, 0},
+		wantMax:   f32.Vec2{6, 6},
+	}, {
+		desc:      "disjoint",
+		body:      `<clipPath id="c"><rect x="30" y="30" width="4" height="4"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: false,
+	}, {
+		desc:      "empty clipPath",
+		body:      `<clipPath id="c"></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: false,
+	}, {
+		desc:      "clip-rule evenodd",
+		body:      `<clipPath id="c"><path clip-rule="evenodd" d="M0 0H12V12H0Z M4 4H8V8H4Z"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  144 - 16,
+		wantMin:   f32.Vec2{0, 0},
+		wantMax:   f32.Vec2{12, 12},
+	}, {
+		desc:      "inherited clip-rule",
+		body:      `<clipPath id="c" clip-rule="evenodd"><path d="M0 0H12V12H0Z M4 4H8V8H4Z"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  144 - 16,
+		wantMin:   f32.Vec2{0, 0},
+		wantMax:   f32.Vec2{12, 12},
+	}, {
+		desc:      "clipPath transform",
+		body:      `<clipPath id="c" transform="translate(10 2)"><rect x="0" y="0" width="4" height="4"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  16,
+		wantMin:   f32.Vec2{10, 2},
+		wantMax:   f32.Vec2{14, 6},
+	}, {
+		desc:      "element transform",
+		body:      `<clipPath id="c"><rect x="0" y="0" width="4" height="4"/></clipPath>` + square + `transform="translate(2 3)" clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  16,
+		wantMin:   f32.Vec2{2, 3},
+		wantMax:   f32.Vec2{6, 7},
+	}, {
+		desc:      "objectBoundingBox",
+		body:      `<clipPath id="c" clipPathUnits="objectBoundingBox"><rect x="0" y="0" width="0.5" height="1"/></clipPath><rect x="4" y="4" width="16" height="8" fill="#f00" clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  64,
+		wantMin:   f32.Vec2{4, 4},
+		wantMax:   f32.Vec2{12, 12},
+	}, {
+		desc:      "objectBoundingBox group",
+		body:      `<clipPath id="c" clipPathUnits="objectBoundingBox"><rect x="0.5" y="0" width="0.5" height="1"/></clipPath><g clip-path="url(#c)"><rect x="0" y="0" width="4" height="4" fill="#f00"/><rect x="8" y="0" width="8" height="4" fill="#0f0"/></g>`,
+		wantShape: true,
+		wantArea:  32,
+		wantMin:   f32.Vec2{8, 0},
+		wantMax:   f32.Vec2{16, 4},
+	}, {
+		desc:      "nested",
+		body:      `<clipPath id="a"><rect x="0" y="0" width="10" height="10"/></clipPath><clipPath id="b"><rect x="5" y="5" width="10" height="10"/></clipPath><g clip-path="url(#a)">` + square + `clip-path="url(#b)"/></g>`,
+		wantShape: true,
+		wantArea:  25,
+		wantMin:   f32.Vec2{5, 5},
+		wantMax:   f32.Vec2{10, 10},
+	}, {
+		desc:      "hidden clipPath child",
+		body:      `<clipPath id="c"><rect x="4" y="4" width="8" height="8"/><rect x="0" y="0" width="24" height="24" display="none"/></clipPath>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  64,
+		wantMin:   f32.Vec2{4, 4},
+		wantMax:   f32.Vec2{12, 12},
+	}, {
+		desc:      "not a clipPath",
+		body:      `<rect id="c" x="4" y="4" width="8" height="8"/>` + square + `clip-path="url(#c)"/>`,
+		wantShape: true,
+		wantArea:  576,
+		wantMin:   f32.Vec2{0, 0},
+		wantMax:   f32.Vec2{24, 24},
+	}, {
+		desc:      "none",
+		body:      square + `clip-path="none"/>`,
+		wantShape: true,
+		wantArea:  576,
+		wantMin:   f32.Vec2{0, 0},
+		wantMax:   f32.Vec2{24, 24},
+	}}
+	for _, tc := range testCases {
+		g, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+tc.body+`</svg>`), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		var last *ivg.Shape
+		for i := range g.Shapes {
+			last = &g.Shapes[i]
+		}
+		if gotShape := last != nil; gotShape != tc.wantShape {
+			t.Errorf("%s: got shape %t, want %t", tc.desc, gotShape, tc.wantShape)
+			continue
+		} else if !gotShape {
+			continue
+		}
+		a, min, max, ok := clipArea(last.Path)
+		if !ok {
+			t.Errorf("%s: got curves, want lines", tc.desc)
+			continue
+		}
+		if math.Abs(a-tc.wantArea) > 0.01 {
+			t.Errorf("%s: area: got %v, want %v", tc.desc, a, tc.wantArea)
+		}
+		if min != tc.wantMin || max != tc.wantMax {
+			t.Errorf("%s: bounds: got %v-%v, want %v-%v", tc.desc, min, max, tc.wantMin, tc.wantMax)
+		}
+	}
+}
+
+func TestParseClipPathUnchanged(t *testing.T) {
+	// A shape that is entirely within the clip region keeps its curves.
+	const circle = `<circle cx="12" cy="12" r="4" fill="#f00"`
+	clipped, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+
+		`<clipPath id="c"><rect x="2" y="2" width="20" height="20"/></clipPath>`+
+		circle+` clip-path="url(#c)"/></svg>`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unclipped, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+circle+`/></svg>`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := clipped.Shapes[0].Path, unclipped.Shapes[0].Path
+	if len(got) != len(want) {
+		t.Fatalf("got %d segments, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("segment #%d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"math"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+// maxHrefDepth bounds the length of a chain of gradients that inherit from
+// each other via href attributes, which also guards against cycles.
+const maxHrefDepth = 16
+
+// gradientAttr returns the named attribute of the gradient n, or of the
+// gradients that n inherits from.
+func (c *converter) gradientAttr(n *node, name string) string {
+	for i := 0; n != nil && i < maxHrefDepth; i++ {
+		if s, ok := n.attrs[name]; ok {
+			return s
+		}
+		n = c.href(n)
+	}
+	return ""
+}
+
+// gradientStops returns the stop elements of the gradient n, or of the
+// gradients that n inherits from.
+func (c *converter) gradientStops(n *node) []*node {
+	for i := 0; n != nil && i < maxHrefDepth; i++ {
+		stops := []*node(nil)
+		for _, child := range n.children {
+			if child.name == "stop" {
+				stops = append(stops, child)
+			}
+		}
+		if len(stops) > 0 {
+			return stops
+		}
+		n = c.href(n)
+	}
+	return nil
+}
+
+func (c *converter) href(n *node) *node {
+	id := n.attrs["href"]
+	if len(id) < 2 || id[0] != '#' {
+		return nil
+	}
+	if m := c.ids[id[1:]]; m != nil && (m.name == "linearGradient" || m.name == "radialGradient") {
+		return m
+	}
+	return nil
+}
+
+// gradient returns the Paint for a shape, with the given user-space path,
+// filled by the gradient n.
+func (c *converter) gradient(n *node, ctx context, alpha float64, p ivg.Path) (paint ivg.Paint, ok bool, err error) {
+	stopNodes := c.gradientStops(n)
+	if len(stopNodes) == 0 {
+		return ivg.Paint{}, false, nil
+	}
+	stops := make([]ivg.GradientStop, len(stopNodes))
+	prevOffset := 0.0
+	firstClear, lastClear := false, false
+	for i, s := range stopNodes {
+		offset, err := parseLength(s.attrs["offset"], 1)
+		if err != nil {
+			return ivg.Paint{}, false, err
+		}
+		offset = math.Max(prevOffset, clamp01(offset))
+		prevOffset = offset
+
+		// stop-color is not inherited, so an unset stop-color is black. The
+		// stop's custom properties are approximated by those of the shape
+		// that the gradient fills.
+		stopColor, stopVar, ok := resolveVar(s.prop("stop-color"), declareVars(s, ctx.vars))
+		if !ok || stopColor == "" {
+			stopColor, stopVar = "black", ""
+		}
+		stopAlpha := alpha
+		if so := s.prop("stop-opacity"); so != "" {
+			f, err := parseOpacity(so)
+			if err != nil {
+				return ivg.Paint{}, false, err
+			}
+			stopAlpha *= f
+		}
+		col, rgba, err := c.flatColor(ctx, stopColor, stopVar, stopAlpha)
+		if err != nil {
+			return ivg.Paint{}, false, err
+		}
+		stops[i] = ivg.GradientStop{
+			Offset: float32(offset),
+			Color:  col,
+		}
+		if i == 0 {
+			firstClear = rgba.A == 0
+		}
+		lastClear = rgba.A == 0
+	}
+	if len(stops) == 1 {
+		return ivg.Paint{Color: stops[0].Color}, true, nil
+	}
+
+	// toUser maps from gradient space to the graphic's coordinate space.
+	toUser := ctx.transform
+	units := c.gradientAttr(n, "gradientUnits")
+	bbox := units != "userSpaceOnUse"
+	refW, refH := 1.0, 1.0
+	if bbox {
+		min, max := bounds(p)
+		w, h := float64(max[0]-min[0]), float64(max[1]-min[1])
+		toUser = toUser.mul(aff{w, 0, float64(min[0]), 0, h, float64(min[1])})
+	} else {
+		vb := &c.g.Metadata.ViewBox
+		refW, refH = float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	}
+	if s := c.gradientAttr(n, "gradientTransform"); s != "" {
+		t, err := parseTransform(s)
+		if err != nil {
+			return ivg.Paint{}, false, err
+		}
+		toUser = toUser.mul(t)
+	}
+	fromUser, invertible := toUser.invert()
+	if !invertible {
+		return ivg.Paint{Color: stops[len(stops)-1].Color}, true, nil
+	}
+
+	length := func(name string, ref float64, dflt string) (float64, error) {
+		s := c.gradientAttr(n, name)
+		if s == "" {
+			s = dflt
+		}
+		if bbox && s[len(s)-1] == '%' {
+			ref = 1
+		}
+		return parseLength(s, ref)
+	}
+	refDiag := math.Sqrt((refW*refW + refH*refH) / 2)
+
+	g := &ivg.Gradient{
+		Spread: ivg.GradientSpreadPad,
+		Stops:  stops,
+	}
+	switch c.gradientAttr(n, "spreadMethod") {
+	case "reflect":
+		g.Spread = ivg.GradientSpreadReflect
+	case "repeat":
+		g.Spread = ivg.GradientSpreadRepeat
+	default:
+		// SVG has no equivalent to IconVG's "none" spread, which package
+		// ivg2svg emulates with the "pad" spread and transparent stops at
+		// offsets 0 and 1. Convert that back.
+		if k := len(stops) - 1; firstClear && lastClear && (k >= 3) &&
+			(stops[1].Offset == 0) && (stops[k-1].Offset == 1) {
+			g.Spread = ivg.GradientSpreadNone
+			g.Stops = stops[1:k]
+		}
+	}
+
+	// norm maps from gradient space to IconVG's normalized gradient space:
+	// where a linear gradient's x coordinate goes from 0 to 1, or where a
+	// radial gradient is the unit circle centered on the origin.
+	norm := aff{}
+	if n.name == "linearGradient" {
+		v := [4]float64{}
+		for i, name := range splitNames("x1 y1 x2 y2") {
+			ref, dflt := refW, "0%"
+			if i&1 != 0 {
+				ref = refH
+			}
+			if name == "x2" {
+				dflt = "100%"
+			}
+			if v[i], err = length(name, ref, dflt); err != nil {
+				return ivg.Paint{}, false, err
+			}
+		}
+		dx, dy := v[2]-v[0], v[3]-v[1]
+		d2 := dx*dx + dy*dy
+		if d2 == 0 {
+			return ivg.Paint{Color: stops[len(stops)-1].Color}, true, nil
+		}
+		g.Shape = ivg.GradientShapeLinear
+		norm = aff{dx / d2, dy / d2, -(v[0]*dx + v[1]*dy) / d2, 0, 0, 0}
+	} else {
+		v := [3]float64{}
+		for i, name := range splitNames("cx cy r") {
+			ref := [3]float64{refW, refH, refDiag}[i]
+			if v[i], err = length(name, ref, "50%"); err != nil {
+				return ivg.Paint{}, false, err
+			}
+		}
+		r := v[2]
+		if !(r > 0) {
+			return ivg.Paint{Color: stops[len(stops)-1].Color}, true, nil
+		}
+		// The focal point defaults to the center.
+		f := [2]float64{}
+		for i, name := range splitNames("fx fy") {
+			if c.gradientAttr(n, name) == "" {
+				f[i] = v[i]
+			} else if f[i], err = length(name, [2]float64{refW, refH}[i], ""); err != nil {
+				return ivg.Paint{}, false, err
+			}
+		}
+		g.Shape = ivg.GradientShapeRadial
+		g.Focal = f32.Vec2{float32((f[0] - v[0]) / r), float32((f[1] - v[1]) / r)}
+		norm = aff{1 / r, 0, -v[0] / r, 0, 1 / r, -v[1] / r}
+	}
+	g.Transform = norm.mul(fromUser).toF32()
+	return ivg.Paint{Gradient: g}, true, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"fmt"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// flattenOpacity applies n's opacity property, if less than 1, to the shapes
+// converted from n's content, c.g.Shapes[start:]. Those shapes' paints are
+// already multiplied by the opacity. If no two of them overlap, that is
+// exact: compositing them at full opacity and then applying the opacity gives
+// the same result.
+//
+// Otherwise, where a shape is covered by later shapes that are opaque, apart
+// from n's opacity property and its ancestors', it is cut away, so that, like
+// SVG, it does not show through them. That is exact if every covering shape
+// is an opaque flat color, and is otherwise an approximation, which is
+// reported as a warning.
+//
+// Afterwards, n's content counts as translucent, for n's ancestors.
+func (c *converter) flattenOpacity(n *node, start int) {
+	s := n.prop("opacity")
+	if s == "" || s == "inherit" {
+		return
+	} else if f, err := parseOpacity(s); err != nil || f >= 1 {
+		return
+	}
+	if overlaps(c.g.Shapes[start:]) {
+		c.cutAway(n, start)
+	}
+	for i := start; i < len(c.opaque); i++ {
+		c.opaque[i] = false
+	}
+}
+
+// cutAway cuts away the parts of c.g.Shapes[start:] that are covered by later
+// opaque shapes, as per flattenOpacity, and drops the shapes that are then
+// empty.
+func (c *converter) cutAway(n *node, start int) {
+	shapes := c.g.Shapes[start:]
+	exact := true
+	cover := ivg.Path(nil)
+	for i := len(shapes) - 1; i >= 0; i-- {
+		sh := &shapes[i]
+		if len(cover) > 0 {
+			p := ivg.Subtract(nonZero(sh), cover, 0)
+			for j, seg := range p {
+				p[j] = c.quantize(seg)
+			}
+			sh.Path, sh.FillRule = p, lowlevel.FillRuleNonZero
+		}
+		if c.opaque[start+i] {
+			cover = ivg.Union(cover, nonZero(sh), 0)
+		} else if i > 0 {
+			exact = false
+		}
+	}
+	if !exact {
+		c.warnf("svgconv: %s opacity is approximated, as its content overlaps", describe(n))
+	}
+
+	// Drop the shapes that are entirely covered.
+	j := start
+	for i := start; i < len(c.g.Shapes); i++ {
+		if len(c.g.Shapes[i].Path) > 0 {
+			c.g.Shapes[j], c.opaque[j] = c.g.Shapes[i], c.opaque[i]
+			j++
+		}
+	}
+	c.g.Shapes, c.opaque = c.g.Shapes[:j], c.opaque[:j]
+}
+
+// overlaps returns whether any two of the shapes overlap.
+func overlaps(shapes []ivg.Shape) bool {
+	for j := 1; j < len(shapes); j++ {
+		min1, max1 := bounds(shapes[j].Path)
+		for i := 0; i < j; i++ {
+			min0, max0 := bounds(shapes[i].Path)
+			if (max0[0] <= min1[0]) || (max1[0] <= min0[0]) ||
+				(max0[1] <= min1[1]) || (max1[1] <= min0[1]) {
+				continue
+			}
+			if len(ivg.Intersect(nonZero(&shapes[i]), nonZero(&shapes[j]), 0)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nonZero returns the path that, filled with the nonzero winding rule, fills
+// the same area as the shape.
+func nonZero(s *ivg.Shape) ivg.Path {
+	if s.FillRule == lowlevel.FillRuleEvenOdd {
+		return ivg.EvenOddToNonZero(s.Path, 0)
+	}
+	return s.Path
+}
+
+// isOpaque returns whether the fill or stroke property value s, with the
+// given fill-opacity or stroke-opacity, paints an opaque flat color, not
+// counting the opacity properties of the element and its ancestors.
+func isOpaque(ctx context, s string, opacity float64) bool {
+	if _, isURL := parseURL(s); isURL || (opacity < 1) {
+		return false
+	}
+	rgba, err := parseColor(s, ctx.color, 1)
+	return (err == nil) && (rgba.A == 0xff)
+}
+
+// describe returns a short description of n, such as `<g id="badge">`, for
+// warnings.
+func describe(n *node) string {
+	if id := n.attrs["id"]; id != "" {
+		return fmt.Sprintf("<%s id=%q>", n.name, id)
+	}
+	return "<" + n.name + ">"
+}
+
+func (c *converter) warnf(format string, args ...interface{}) {
+	if c.opts.OnWarning != nil {
+		c.opts.OnWarning(fmt.Sprintf(format, args...))
+	}
+}
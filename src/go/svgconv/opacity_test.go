@@ -0,0 +1,114 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/svgconv"
+)
+
+func TestGroupOpacity(t *testing.T) {
+	const (
+		red   = `<rect x="0" y="0" width="12" height="12" fill="#f00"/>`
+		blue  = `<rect x="6" y="0" width="12" height="12" fill="#00f"/>`
+		green = `<rect x="20" y="0" width="4" height="4" fill="#0f0"/>`
+	)
+	testCases := []struct {
+		desc        string
+		body        string
+		wantAreas   []float64
+		wantWarning bool
+	}{
+		{"opaque group", `<g>` + red + blue + `</g>`, []float64{144, 144}, false},
+		{"opacity 1", `<g opacity="1">` + red + blue + `</g>`, []float64{144, 144}, false},
+		{"disjoint", `<g opacity="0.5">` + red + green + `</g>`, []float64{144, 16}, false},
+		{"overlapping", `<g opacity="0.5">` + red + blue + `</g>`, []float64{72, 144}, false},
+		{"style", `<g style="opacity:0.5">` + red + blue + `</g>`, []float64{72, 144}, false},
+		{"covered", `<g opacity="0.5"><rect x="8" y="4" width="4" height="4" fill="#f00"/>` + blue + `</g>`,
+			[]float64{144}, false},
+		{"translucent cover", `<g opacity="0.5">` + red +
+			`<rect x="6" y="0" width="12" height="12" fill="#00f" fill-opacity="0.5"/></g>`,
+			[]float64{144, 144}, true},
+		{"gradient cover", `<linearGradient id="lg"><stop offset="0" stop-color="#000"/><stop offset="1" stop-color="#fff"/></linearGradient>` +
+			`<g opacity="0.5">` + red + `<rect x="6" y="0" width="12" height="12" fill="url(#lg)"/></g>`,
+			[]float64{144, 144}, true},
+		// A cover whose own opacity property is less than 1 is translucent
+		// within the group.
+		{"translucent element cover", `<g opacity="0.5">` + red +
+			`<rect x="6" y="0" width="12" height="12" fill="#00f" opacity="0.5"/></g>`,
+			[]float64{144, 144}, true},
+		{"translucent group cover", `<g opacity="0.5">` + red + `<g opacity="0.5">` + blue + `</g></g>`,
+			[]float64{144, 144}, true},
+		// The element's own stroke covers its fill.
+		{"fill and stroke", `<rect x="2" y="2" width="8" height="8" fill="#f00" stroke="#00f" stroke-width="2" opacity="0.5"/>`,
+			[]float64{36, 100 - 36}, false},
+		{"nested", `<g opacity="0.5"><g opacity="0.5">` + red + blue + `</g>` + green + `</g>`,
+			[]float64{72, 144, 16}, false},
+	}
+	for _, tc := range testCases {
+		gotWarning := false
+		g, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+tc.body+`</svg>`), &svgconv.Options{
+			OnWarning: func(message string) { gotWarning = true },
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if gotWarning != tc.wantWarning {
+			t.Errorf("%s: got warning %t, want %t", tc.desc, gotWarning, tc.wantWarning)
+		}
+		if len(g.Shapes) != len(tc.wantAreas) {
+			t.Errorf("%s: got %d shapes, want %d", tc.desc, len(g.Shapes), len(tc.wantAreas))
+			continue
+		}
+		for i, s := range g.Shapes {
+			a, _, _, ok := clipArea(s.Path)
+			if !ok {
+				t.Errorf("%s: shape #%d: got curves, want lines", tc.desc, i)
+				continue
+			}
+			if math.Abs(a-tc.wantAreas[i]) > 0.01 {
+				t.Errorf("%s: shape #%d: area: got %v, want %v", tc.desc, i, a, tc.wantAreas[i])
+			}
+		}
+	}
+}
+
+func TestGroupOpacityWarning(t *testing.T) {
+	var got []string
+	_, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24"><g id="badge" opacity="0.5">`+
+		`<rect x="0" y="0" width="12" height="12" fill="#f00"/>`+
+		`<rect x="6" y="0" width="12" height="12" fill="#00f" fill-opacity="0.5"/>`+
+		`</g></svg>`), &svgconv.Options{
+		OnWarning: func(message string) { got = append(got, message) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `svgconv: <g id="badge"> opacity is approximated, as its content overlaps`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want [%q]", got, want)
+	}
+
+	// A nil OnWarning is not called.
+	if _, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24"><g opacity="0.5">`+
+		`<rect x="0" y="0" width="12" height="12" fill="#f00"/>`+
+		`<rect x="6" y="0" width="12" height="12" fill="#00f" fill-opacity="0.5"/>`+
+		`</g></svg>`), nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"image/color"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+// paletteVarPrefix is the prefix of the CSS custom properties that package
+// ivg2svg uses for the suggested palette. A property named with this prefix
+// and a palette index, such as --iconvg-palette-3, maps to that index.
+const paletteVarPrefix = "--iconvg-palette-"
+
+// maxVarDepth bounds the length of a chain of CSS custom properties that refer
+// to each other via var(), which also guards against cycles.
+const maxVarDepth = 16
+
+// paletteKey is what a suggested palette entry was allocated for: a color
+// value and, for a themable color, the name of the custom property (or
+// "currentColor") that it was set from.
+type paletteKey struct {
+	name string
+	rgba color.RGBA
+}
+
+// reservePalette reserves the palette indices that s refers to by name, such
+// as the 3 in "var(--iconvg-palette-3)", so that they are not allocated to
+// other colors.
+func (c *converter) reservePalette(s string) {
+	for {
+		i := strings.Index(s, paletteVarPrefix)
+		if i < 0 {
+			return
+		}
+		s = s[i+len(paletteVarPrefix):]
+		if j, ok := paletteVarIndex(s); ok {
+			c.reserved |= 1 << j
+		}
+	}
+}
+
+// paletteVarIndex parses the leading decimal palette index of s.
+func paletteVarIndex(s string) (i uint8, ok bool) {
+	n := 0
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		n++
+	}
+	u, err := strconv.ParseUint(s[:n], 10, 8)
+	if err != nil || u >= uint64(len(lowlevel.Palette{})) {
+		return 0, false
+	}
+	return uint8(u), true
+}
+
+// paletteVarName returns the palette index i if name is paletteVarPrefix
+// followed by i, in decimal.
+func paletteVarName(name string) (i uint8, ok bool) {
+	if !strings.HasPrefix(name, paletteVarPrefix) {
+		return 0, false
+	}
+	i, ok = paletteVarIndex(name[len(paletteVarPrefix):])
+	return i, ok && (name == paletteVarPrefix+strconv.Itoa(int(i)))
+}
+
+// paletteIndex returns the suggested palette index for the given key,
+// allocating one if necessary. A key named like --iconvg-palette-3 gets that
+// index, unless it is already taken by a different color. Other keys get the
+// lowest index that is neither allocated nor reserved. It returns ok == false
+// if there is no such index.
+func (c *converter) paletteIndex(key paletteKey) (i uint8, ok bool) {
+	if i, ok := c.palette[key]; ok {
+		return i, true
+	}
+	if j, named := paletteVarName(key.name); named {
+		if c.allocated&(1<<j) != 0 {
+			return 0, false
+		}
+		i = j
+	} else {
+		free := ^(c.allocated | c.reserved)
+		if free == 0 {
+			return 0, false
+		}
+		i = uint8(bits.TrailingZeros64(free))
+	}
+	if c.palette == nil {
+		c.palette = map[paletteKey]uint8{}
+	}
+	c.palette[key] = i
+	c.allocated |= 1 << i
+	c.g.Metadata.Palette[i] = key.rgba
+	return i, true
+}
+
+// flatColor returns the Color for the color property value s, with its alpha
+// scaled by alpha, and that Color's RGBA value. name is the custom property, if
+// any, that s was set from. Colors set from a custom property or from
+// currentColor are themable, and refer to a suggested palette entry, so that
+// an IconVG renderer can re-color them with a custom palette.
+func (c *converter) flatColor(ctx context, s string, name string, alpha float64) (lowlevel.Color, color.RGBA, error) {
+	rgba, err := parseColor(s, ctx.color, alpha)
+	if err != nil {
+		return lowlevel.Color{}, rgba, err
+	} else if rgba.A == 0 {
+		return c.color(rgba), rgba, nil
+	}
+	if name == "" && s == "currentColor" {
+		if name = ctx.colorVar; name == "" {
+			name = "currentColor"
+		}
+	}
+	if name == "" {
+		return c.color(rgba), rgba, nil
+	}
+
+	base, err := parseColor(s, ctx.color, 1)
+	if err != nil {
+		return lowlevel.Color{}, rgba, err
+	}
+	i, ok := c.paletteIndex(paletteKey{name, base})
+	if !ok {
+		return lowlevel.RGBAColor(rgba), rgba, nil
+	}
+	t := uint8(math.Round(0xff * (1 - clamp01(alpha))))
+	if t == 0 {
+		return lowlevel.PaletteIndexColor(i), rgba, nil
+	}
+	// Blend from the palette color towards transparent black. The arguments
+	// to BlendColor are 1 byte color encodings, in which 0x80 + i is palette
+	// index i and 0x7f is transparent black.
+	return lowlevel.BlendColor(t, 0x80|i, 0x7f), rgba, nil
+}
+
+// declareVars returns the CSS custom properties in effect for n, given those
+// in effect for n's parent.
+func declareVars(n *node, vars map[string]string) map[string]string {
+	copied := false
+	for k, v := range n.style {
+		if !strings.HasPrefix(k, "--") {
+			continue
+		}
+		if !copied {
+			copied = true
+			m := make(map[string]string, len(vars)+1)
+			for k0, v0 := range vars {
+				m[k0] = v0
+			}
+			vars = m
+		}
+		vars[k] = v
+	}
+	return vars
+}
+
+// resolveVar substitutes the custom properties referred to by a property
+// value, such as "var(--accent, red)". It returns the substituted value and
+// the name of the outermost custom property, or s itself and "" if s is not a
+// var() reference. It returns ok == false if s refers to a custom property
+// that is not defined and has no fallback value, in which case the property
+// is "unset": inherited properties inherit and others take their initial
+// value.
+func resolveVar(s string, vars map[string]string) (value string, name string, ok bool) {
+	for i := 0; i < maxVarDepth; i++ {
+		if !strings.HasPrefix(s, "var(") || !strings.HasSuffix(s, ")") {
+			return s, name, true
+		}
+		ref, fallback, hasFallback := s[4:len(s)-1], "", false
+		if j := strings.IndexByte(ref, ','); j >= 0 {
+			ref, fallback, hasFallback = ref[:j], ref[j+1:], true
+		}
+		ref = strings.TrimSpace(ref)
+		if name == "" {
+			name = ref
+		}
+		if v, defined := vars[ref]; defined && v != "" {
+			s = v
+		} else if hasFallback {
+			s = strings.TrimSpace(fallback)
+		} else {
+			return "", "", false
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,276 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+var errNoRootElement = errors.New("svgconv: no root element")
+
+// node is an XML element. Attribute names exclude any namespace prefix, so
+// that "xlink:href" and "href" are both keyed by "href". text is the
+// element's own character data, excluding that of its children.
+type node struct {
+	name     string
+	attrs    map[string]string
+	style    map[string]string
+	text     string
+	children []*node
+}
+
+// prop returns the value of the named property, from n's style attribute or
+// else from its presentation attribute.
+func (n *node) prop(name string) string {
+	if s, ok := n.style[name]; ok {
+		return s
+	}
+	return n.attrs[name]
+}
+
+func parseXML(src []byte) (*node, error) {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	root, stack := (*node)(nil), []*node(nil)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{
+				name:  tok.Name.Local,
+				attrs: make(map[string]string, len(tok.Attr)),
+			}
+			for _, a := range tok.Attr {
+				n.attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+			}
+			if s := n.attrs["style"]; s != "" {
+				n.style = parseStyle(s)
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errNoRootElement
+	}
+	return root, nil
+}
+
+// parseStyle parses a style attribute, such as "fill:red; opacity:0.5".
+func parseStyle(s string) map[string]string {
+	m := map[string]string{}
+	for _, decl := range strings.Split(s, ";") {
+		if i := strings.IndexByte(decl, ':'); i >= 0 {
+			k := strings.TrimSpace(decl[:i])
+			v := strings.TrimSpace(decl[i+1:])
+			v = strings.TrimSpace(strings.TrimSuffix(v, "!important"))
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// parseNumbers parses a comma or whitespace separated list of numbers.
+func parseNumbers(s string) ([]float64, error) {
+	p := pathScanner{s: s}
+	ret := []float64(nil)
+	for p.skipSeparators(); !p.done(); p.skipSeparators() {
+		f, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// parseLength parses a length. Percentages are relative to ref. Units other
+// than "px" are not supported.
+func parseLength(s string, ref float64) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s[:len(s)-1]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("svgconv: invalid length %q", s)
+		}
+		return f * ref / 100, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "px")), 64)
+	if err != nil {
+		return 0, fmt.Errorf("svgconv: invalid length %q", s)
+	}
+	return f, nil
+}
+
+// parseDashArray parses a stroke-dasharray, a comma or whitespace separated
+// list of lengths, or "none". Percentages are relative to ref.
+func parseDashArray(s string, ref float64) ([]float64, error) {
+	if s == "none" {
+		return nil, nil
+	}
+	ret := []float64(nil)
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool {
+		return (r == ',') || (r == ' ') || (r == '\t') || (r == '\n') || (r == '\r')
+	}) {
+		f, err := parseLength(t, ref)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// parseOpacity parses an opacity, clamped to the range [0, 1].
+func parseOpacity(s string) (float64, error) {
+	f, err := parseLength(s, 1)
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(0, math.Min(1, f)), nil
+}
+
+// parseURL parses a "url(#id)" paint reference.
+func parseURL(s string) (id string, ok bool) {
+	if !strings.HasPrefix(s, "url(") {
+		return "", false
+	}
+	s = s[len("url("):]
+	if i := strings.IndexByte(s, ')'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.Trim(strings.TrimSpace(s), `"'`)
+	return strings.TrimPrefix(s, "#"), true
+}
+
+// parseColor parses a CSS color, returning it alpha-premultiplied after
+// multiplying its alpha by the given alpha. currentColor is the value of the
+// "currentColor" keyword.
+func parseColor(s string, currentColor string, alpha float64) (color.RGBA, error) {
+	if s == "currentColor" {
+		if currentColor == "currentColor" {
+			currentColor = "black"
+		}
+		return parseColor(currentColor, "", alpha)
+	}
+
+	r, g, b, a := 0.0, 0.0, 0.0, 1.0
+	switch {
+	case strings.HasPrefix(s, "#"):
+		h := s[1:]
+		if len(h) == 3 || len(h) == 4 {
+			h2 := make([]byte, 0, 2*len(h))
+			for i := 0; i < len(h); i++ {
+				h2 = append(h2, h[i], h[i])
+			}
+			h = string(h2)
+		}
+		if len(h) != 6 && len(h) != 8 {
+			return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+		}
+		u, err := strconv.ParseUint(h, 16, 32)
+		if err != nil {
+			return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+		}
+		if len(h) == 6 {
+			u = u<<8 | 0xff
+		}
+		r, g, b, a = float64(u>>24)/0xff, float64(u>>16&0xff)/0xff, float64(u>>8&0xff)/0xff, float64(u&0xff)/0xff
+
+	case strings.HasPrefix(s, "rgb(") || strings.HasPrefix(s, "rgba("):
+		i, j := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+		if j < i {
+			return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+		}
+		args := strings.FieldsFunc(s[i+1:j], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '/'
+		})
+		if len(args) != 3 && len(args) != 4 {
+			return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+		}
+		v := [4]float64{0, 0, 0, 1}
+		for k, arg := range args {
+			ref := 0xff
+			if k == 3 {
+				ref = 1
+			}
+			f, err := parseLength(arg, float64(ref))
+			if err != nil {
+				return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+			}
+			v[k] = f / float64(ref)
+		}
+		r, g, b, a = v[0], v[1], v[2], v[3]
+
+	case s == "transparent":
+		return color.RGBA{}, nil
+
+	default:
+		c, ok := colornames.Map[strings.ToLower(s)]
+		if !ok {
+			return color.RGBA{}, fmt.Errorf("svgconv: invalid color %q", s)
+		}
+		r, g, b = float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff
+	}
+
+	a = clamp01(a * alpha)
+	return color.RGBA{
+		R: uint8(math.Round(clamp01(r) * a * 0xff)),
+		G: uint8(math.Round(clamp01(g) * a * 0xff)),
+		B: uint8(math.Round(clamp01(b) * a * 0xff)),
+		A: uint8(math.Round(a * 0xff)),
+	}, nil
+}
+
+func clamp01(f float64) float64 {
+	return math.Max(0, math.Min(1, f))
+}
+
+func roundTo(f float32, precision float32) float32 {
+	return float32(math.Round(float64(f)/float64(precision)) * float64(precision))
+}
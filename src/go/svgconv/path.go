@@ -0,0 +1,519 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"golang.org/x/image/math/f32"
+)
+
+var errInvalidPathData = errors.New("svgconv: invalid path data")
+
+// geometry returns the user-space path for a shape element.
+func (c *converter) geometry(n *node) (ivg.Path, error) {
+	vb := &c.g.Metadata.ViewBox
+	w, h := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	diag := math.Sqrt((w*w + h*h) / 2)
+
+	lengths := func(names string, refs ...float64) ([]float64, error) {
+		ret := make([]float64, len(refs))
+		for i, name := range splitNames(names) {
+			f, err := parseLength(n.attrs[name], refs[i])
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = f
+		}
+		return ret, nil
+	}
+
+	b := &pathBuilder{}
+	switch n.name {
+	case "path":
+		if err := b.parse(n.attrs["d"]); err != nil {
+			return nil, err
+		}
+
+	case "rect":
+		v, err := lengths("x y width height", w, h, w, h)
+		if err != nil {
+			return nil, err
+		}
+		x, y, width, height := v[0], v[1], v[2], v[3]
+		if !(width > 0) || !(height > 0) {
+			return nil, nil
+		}
+		rx, err := parseLength(n.attrs["rx"], w)
+		if err != nil {
+			return nil, err
+		}
+		ry, err := parseLength(n.attrs["ry"], h)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := n.attrs["rx"]; !ok {
+			rx = ry
+		} else if _, ok := n.attrs["ry"]; !ok {
+			ry = rx
+		}
+		rx = math.Max(0, math.Min(rx, width/2))
+		ry = math.Max(0, math.Min(ry, height/2))
+		if rx == 0 || ry == 0 {
+			b.moveTo(x, y)
+			b.lineTo(x+width, y)
+			b.lineTo(x+width, y+height)
+			b.lineTo(x, y+height)
+		} else {
+			b.moveTo(x+rx, y)
+			b.lineTo(x+width-rx, y)
+			b.arcTo(rx, ry, 0, false, true, x+width, y+ry)
+			b.lineTo(x+width, y+height-ry)
+			b.arcTo(rx, ry, 0, false, true, x+width-rx, y+height)
+			b.lineTo(x+rx, y+height)
+			b.arcTo(rx, ry, 0, false, true, x, y+height-ry)
+			b.lineTo(x, y+ry)
+			b.arcTo(rx, ry, 0, false, true, x+rx, y)
+		}
+		b.closePath()
+
+	case "circle", "ellipse":
+		v, err := lengths("cx cy", w, h)
+		if err != nil {
+			return nil, err
+		}
+		cx, cy := v[0], v[1]
+		rx, ry := 0.0, 0.0
+		if n.name == "circle" {
+			rx, err = parseLength(n.attrs["r"], diag)
+			ry = rx
+		} else {
+			v, err = lengths("rx ry", w, h)
+			if err == nil {
+				rx, ry = v[0], v[1]
+			}
+		}
+		if err != nil {
+			return nil, err
+		} else if !(rx > 0) || !(ry > 0) {
+			return nil, nil
+		}
+		b.moveTo(cx+rx, cy)
+		b.arcTo(rx, ry, 0, false, true, cx-rx, cy)
+		b.arcTo(rx, ry, 0, false, true, cx+rx, cy)
+		b.closePath()
+
+	case "line":
+		v, err := lengths("x1 y1 x2 y2", w, h, w, h)
+		if err != nil {
+			return nil, err
+		}
+		b.moveTo(v[0], v[1])
+		b.lineTo(v[2], v[3])
+
+	case "polygon", "polyline":
+		v, err := parseNumbers(n.attrs["points"])
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(v); i += 2 {
+			if i == 0 {
+				b.moveTo(v[i], v[i+1])
+			} else {
+				b.lineTo(v[i], v[i+1])
+			}
+		}
+		if (len(v) >= 2) && (n.name == "polygon") {
+			b.closePath()
+		}
+	}
+	return b.path, nil
+}
+
+func splitNames(s string) []string {
+	ret := []string(nil)
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] != ' ' {
+			j++
+		}
+		ret = append(ret, s[i:j])
+		i = j + 1
+	}
+	return ret
+}
+
+// pathBuilder builds an absolute ivg.Path from SVG path data.
+type pathBuilder struct {
+	path ivg.Path
+
+	// pen is the current point. start is the start of the current sub-path.
+	// lastCtrl is the final control point of the previous segment, and
+	// lastCmd is its (upper case) SVG command, for smooth curves.
+	pen      f32.Vec2
+	start    f32.Vec2
+	lastCtrl f32.Vec2
+	lastCmd  byte
+}
+
+func (b *pathBuilder) moveTo(x, y float64) {
+	b.pen = f32.Vec2{float32(x), float32(y)}
+	b.start = b.pen
+	b.path = append(b.path, ivg.MoveTo{To: b.pen})
+	b.lastCmd = 'M'
+}
+
+// implicitMoveTo starts a sub-path at the pen position, if there is no current
+// path.
+func (b *pathBuilder) implicitMoveTo() {
+	if len(b.path) == 0 {
+		b.path = append(b.path, ivg.MoveTo{To: b.pen})
+		b.start = b.pen
+	}
+}
+
+func (b *pathBuilder) lineTo(x, y float64) {
+	b.implicitMoveTo()
+	b.pen = f32.Vec2{float32(x), float32(y)}
+	b.path = append(b.path, ivg.LineTo{To: b.pen})
+	b.lastCmd = 'L'
+}
+
+func (b *pathBuilder) quadTo(x1, y1, x, y float64) {
+	b.implicitMoveTo()
+	b.lastCtrl = f32.Vec2{float32(x1), float32(y1)}
+	b.pen = f32.Vec2{float32(x), float32(y)}
+	b.path = append(b.path, ivg.QuadTo{Ctrl: b.lastCtrl, To: b.pen})
+	b.lastCmd = 'Q'
+}
+
+func (b *pathBuilder) cubeTo(x1, y1, x2, y2, x, y float64) {
+	b.implicitMoveTo()
+	b.lastCtrl = f32.Vec2{float32(x2), float32(y2)}
+	b.pen = f32.Vec2{float32(x), float32(y)}
+	b.path = append(b.path, ivg.CubeTo{
+		Ctrl0: f32.Vec2{float32(x1), float32(y1)},
+		Ctrl1: b.lastCtrl,
+		To:    b.pen,
+	})
+	b.lastCmd = 'C'
+}
+
+func (b *pathBuilder) arcTo(rx, ry, xAxisRotation float64, largeArc, sweep bool, x, y float64) {
+	b.implicitMoveTo()
+	to := f32.Vec2{float32(x), float32(y)}
+	if to == b.pen {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		b.lineTo(x, y)
+		return
+	}
+	b.pen = to
+	b.path = append(b.path, ivg.ArcTo{
+		Radii:         f32.Vec2{float32(rx), float32(ry)},
+		XAxisRotation: float32(xAxisRotation / 360),
+		LargeArc:      largeArc,
+		Sweep:         sweep,
+		To:            to,
+	})
+	b.lastCmd = 'A'
+}
+
+func (b *pathBuilder) closePath() {
+	if len(b.path) > 0 {
+		if _, ok := b.path[len(b.path)-1].(ivg.ClosePath); !ok {
+			b.path = append(b.path, ivg.ClosePath{})
+		}
+	}
+	b.pen = b.start
+	b.lastCmd = 'Z'
+}
+
+// smoothCtrl returns the implicit first control point of a smooth curve: the
+// reflection of the previous segment's final control point, if the previous
+// segment was the same kind of curve, or else the pen.
+func (b *pathBuilder) smoothCtrl(kind byte) (x, y float64) {
+	if b.lastCmd != kind {
+		return float64(b.pen[0]), float64(b.pen[1])
+	}
+	return float64(2*b.pen[0] - b.lastCtrl[0]), float64(2*b.pen[1] - b.lastCtrl[1])
+}
+
+// parse parses SVG path data, such as "M 10 10 L 20 20 Z".
+func (b *pathBuilder) parse(d string) error {
+	s := pathScanner{s: d}
+	cmd := byte(0)
+	for s.skipSeparators(); !s.done(); s.skipSeparators() {
+		if c := s.s[s.i]; isCommand(c) {
+			cmd = c
+			s.i++
+		} else if cmd == 0 {
+			return errInvalidPathData
+		} else if cmd == 'M' {
+			// Coordinate pairs after the first in a moveTo are lineTos.
+			cmd = 'L'
+		} else if cmd == 'm' {
+			cmd = 'l'
+		} else if cmd == 'Z' || cmd == 'z' {
+			return errInvalidPathData
+		}
+
+		rel := 'a' <= cmd && cmd <= 'z'
+		ox, oy := 0.0, 0.0
+		if rel {
+			ox, oy = float64(b.pen[0]), float64(b.pen[1])
+		}
+
+		switch cmd {
+		case 'Z', 'z':
+			b.closePath()
+			continue
+		case 'A', 'a':
+			v, err := s.numbers(3)
+			if err != nil {
+				return err
+			}
+			largeArc, err := s.flag()
+			if err != nil {
+				return err
+			}
+			sweep, err := s.flag()
+			if err != nil {
+				return err
+			}
+			xy, err := s.numbers(2)
+			if err != nil {
+				return err
+			}
+			b.arcTo(v[0], v[1], v[2], largeArc, sweep, ox+xy[0], oy+xy[1])
+			continue
+		}
+
+		n := 0
+		switch cmd {
+		case 'H', 'h', 'V', 'v':
+			n = 1
+		case 'M', 'm', 'L', 'l', 'T', 't':
+			n = 2
+		case 'Q', 'q', 'S', 's':
+			n = 4
+		case 'C', 'c':
+			n = 6
+		}
+		v, err := s.numbers(n)
+		if err != nil {
+			return err
+		}
+
+		switch cmd {
+		case 'M', 'm':
+			b.moveTo(ox+v[0], oy+v[1])
+		case 'L', 'l':
+			b.lineTo(ox+v[0], oy+v[1])
+		case 'H', 'h':
+			b.lineTo(ox+v[0], float64(b.pen[1]))
+		case 'V', 'v':
+			b.lineTo(float64(b.pen[0]), oy+v[0])
+		case 'T', 't':
+			x1, y1 := b.smoothCtrl('Q')
+			b.quadTo(x1, y1, ox+v[0], oy+v[1])
+		case 'Q', 'q':
+			b.quadTo(ox+v[0], oy+v[1], ox+v[2], oy+v[3])
+		case 'S', 's':
+			x1, y1 := b.smoothCtrl('C')
+			b.cubeTo(x1, y1, ox+v[0], oy+v[1], ox+v[2], oy+v[3])
+		case 'C', 'c':
+			b.cubeTo(ox+v[0], oy+v[1], ox+v[2], oy+v[3], ox+v[4], oy+v[5])
+		}
+	}
+	return nil
+}
+
+func isCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'Z', 'z', 'L', 'l', 'H', 'h', 'V', 'v',
+		'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a':
+		return true
+	}
+	return false
+}
+
+// pathScanner scans the numbers in SVG path data and similar lists.
+type pathScanner struct {
+	s string
+	i int
+}
+
+func (p *pathScanner) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *pathScanner) skipSeparators() {
+	for ; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case ' ', '\t', '\r', '\n', ',':
+			continue
+		}
+		break
+	}
+}
+
+func (p *pathScanner) numbers(n int) ([]float64, error) {
+	ret := make([]float64, n)
+	for i := range ret {
+		p.skipSeparators()
+		f, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = f
+	}
+	return ret, nil
+}
+
+// number scans a number, such as "-1.5e3". Numbers need not be separated by
+// whitespace or commas: "1-2" is two numbers, as is "1.5.5".
+func (p *pathScanner) number() (float64, error) {
+	start := p.i
+	if p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+		p.i++
+	}
+	digits, dot := 0, false
+	for ; p.i < len(p.s); p.i++ {
+		if c := p.s[p.i]; '0' <= c && c <= '9' {
+			digits++
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+	}
+	if digits == 0 {
+		return 0, fmt.Errorf("svgconv: invalid number at %q", p.s[start:])
+	}
+	if p.i < len(p.s) && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+		j := p.i + 1
+		if j < len(p.s) && (p.s[j] == '+' || p.s[j] == '-') {
+			j++
+		}
+		if j < len(p.s) && '0' <= p.s[j] && p.s[j] <= '9' {
+			for p.i = j; p.i < len(p.s) && '0' <= p.s[p.i] && p.s[p.i] <= '9'; p.i++ {
+			}
+		}
+	}
+	return strconv.ParseFloat(p.s[start:p.i], 64)
+}
+
+// flag scans an arc flag, which is a single "0" or "1" character that need
+// not be followed by a separator.
+func (p *pathScanner) flag() (bool, error) {
+	p.skipSeparators()
+	if p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '0':
+			p.i++
+			return false, nil
+		case '1':
+			p.i++
+			return true, nil
+		}
+	}
+	return false, errInvalidPathData
+}
+
+// bounds returns the bounding box of p's points, including control points
+// and the full ellipses of any arcs. It is not necessarily tight, but it is
+// exact for rectangles, circles and ellipses.
+func bounds(p ivg.Path) (min, max f32.Vec2) {
+	x0, y0 := math.Inf(+1), math.Inf(+1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	add := func(x, y float64) {
+		x0, x1 = math.Min(x0, x), math.Max(x1, x)
+		y0, y1 = math.Min(y0, y), math.Max(y1, y)
+	}
+	addVec2 := func(v f32.Vec2) {
+		add(float64(v[0]), float64(v[1]))
+	}
+
+	pen, start := f32.Vec2{}, f32.Vec2{}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			start = seg.To
+			addVec2(seg.To)
+		case ivg.LineTo:
+			addVec2(seg.To)
+		case ivg.QuadTo:
+			addVec2(seg.Ctrl)
+			addVec2(seg.To)
+		case ivg.CubeTo:
+			addVec2(seg.Ctrl0)
+			addVec2(seg.Ctrl1)
+			addVec2(seg.To)
+		case ivg.ArcTo:
+			cx, cy, rx, ry := arcCenter(pen, seg)
+			sin, cos := math.Sincos(2 * math.Pi * float64(seg.XAxisRotation))
+			ex := math.Hypot(rx*cos, ry*sin)
+			ey := math.Hypot(rx*sin, ry*cos)
+			add(cx-ex, cy-ey)
+			add(cx+ex, cy+ey)
+		}
+		pen = seg.EndPoint(pen, start)
+	}
+	return f32.Vec2{float32(x0), float32(y0)}, f32.Vec2{float32(x1), float32(y1)}
+}
+
+// arcCenter returns the center of the ellipse of an arc from the point from,
+// and its radii, scaled up if they are too small for the arc to reach its end
+// point. See the SVG specification's "Conversion from endpoint to center
+// parameterization".
+func arcCenter(from f32.Vec2, a ivg.ArcTo) (cx, cy, rx, ry float64) {
+	x1, y1 := float64(from[0]), float64(from[1])
+	x2, y2 := float64(a.To[0]), float64(a.To[1])
+	rx, ry = math.Abs(float64(a.Radii[0])), math.Abs(float64(a.Radii[1]))
+	sin, cos := math.Sincos(2 * math.Pi * float64(a.XAxisRotation))
+
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p := +cos*dx + sin*dy
+	y1p := -sin*dx + cos*dy
+	if rx == 0 || ry == 0 {
+		return (x1 + x2) / 2, (y1 + y2) / 2, rx, ry
+	}
+	if lambda := (x1p*x1p)/(rx*rx) + (y1p*y1p)/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := 0.0
+	if den > 0 && num > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if a.LargeArc == a.Sweep {
+		coef = -coef
+	}
+	cxp := +coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+	cx = cos*cxp - sin*cyp + (x1+x2)/2
+	cy = sin*cxp + cos*cyp + (y1+y2)/2
+	return cx, cy, rx, ry
+}
@@ -0,0 +1,535 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package svgconv converts SVG graphics to IconVG.
+//
+// Only a subset of SVG is supported: the path, rect, circle, ellipse, line,
+// polygon and polyline elements, grouped by g elements, filled and stroked
+// with flat colors or linearGradient and radialGradient paint servers, and
+// positioned by the transform attribute. Presentation attributes and the
+// style attribute are both recognized, but style sheets are not.
+//
+// IconVG has no strokes, so strokes are converted to fills by
+// ivg.DashedStrokeToFill, with their curves flattened and each dash of a
+// dashed stroke filled as its own outline. IconVG has no clip paths, so
+// shapes are clipped geometrically to their clip-path property's region (see
+// ivg.Intersect), flattening the curves of those that cross its boundary.
+// IconVG has no masks, filters or text, so the corresponding SVG elements and
+// properties are ignored. Shapes whose fill-rule is evenodd are converted to
+// the non-zero winding rule, the only one in IconVG byte code, when encoded
+// (see ivg.EvenOddToNonZero). Group opacity is applied to each of the group's
+// shapes, with the parts of shapes that are covered by later, opaque shapes in
+// the group cut away, so that they do not show through. That is exact unless
+// the covering shapes are translucent or gradients, in which case
+// Options.OnWarning is called. A radialGradient's focal point (its fx and fy
+// attributes) is kept, and, when encoded, approximated (see ivg.Gradient's
+// Focal field).
+//
+// Themable colors map onto the suggested palette. A color that is set from a
+// CSS custom property, such as "var(--accent)", or from currentColor gets its
+// own palette entry, keyed by the custom property's name (or by
+// "currentColor") and holding the color's resolved value. The custom
+// properties named --iconvg-palette-0, --iconvg-palette-1, etc., which package
+// ivg2svg writes, map onto those palette indices, so that the suggested
+// palette survives a round trip through SVG. Opacity applied to a themable
+// color becomes a blend of the palette entry and transparent black.
+//
+// The root svg element's title and desc children, if any, become the
+// graphic's title and description metadata.
+package svgconv
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	errMissingViewBox = errors.New("svgconv: missing viewBox, width or height")
+	errNotSVG         = errors.New("svgconv: root element is not svg")
+)
+
+// Options are the optional parameters to the Convert and Parse functions.
+type Options struct {
+	// Precision, if positive, rounds every coordinate to the nearest multiple
+	// of Precision. Coarser coordinates usually encode more compactly. For
+	// example, multiples of 1/64 in the range [-128, +128) take 2 bytes each,
+	// instead of 4, but a 1/64 precision is only appropriate for graphics
+	// whose viewBox is tens of units across.
+	Precision float32
+
+	// ExtractPalette is whether to collect the graphic's distinct colors into
+	// its suggested palette, in order of first use, and to refer to those
+	// colors by palette index. Doing so lets an IconVG renderer re-color the
+	// graphic with a custom palette. Only as many distinct colors as there
+	// are free palette entries are extracted. Any others are left as direct
+	// colors. Themable colors, set from CSS custom properties or currentColor,
+	// always refer to the palette, regardless of ExtractPalette.
+	ExtractPalette bool
+
+	// Optimize is whether Convert encodes with the ivg.Encoder's Optimize
+	// option. It does not affect Parse.
+	Optimize bool
+
+	// OnWarning, if non-nil, is called with a message for each construct that
+	// is converted only approximately, such as the opacity of a group whose
+	// shapes overlap.
+	OnWarning func(message string)
+}
+
+// Convert converts the SVG graphic src to IconVG. A gradient with more stops
+// than IconVG can encode is resampled, as per the ivg.Encoder's
+// ResampleGradients option.
+//
+// opts may be nil, which means to use the default options.
+func Convert(src []byte, opts *Options) ([]byte, error) {
+	g, err := Parse(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	e := &ivg.Encoder{
+		Optimize:          (opts != nil) && opts.Optimize,
+		ResampleGradients: true,
+	}
+	return e.Encode(g)
+}
+
+// Parse is like Convert but returns the converted graphic as an ivg.Graphic,
+// which can be modified before being encoded.
+func Parse(src []byte, opts *Options) (*ivg.Graphic, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	root, err := parseXML(src)
+	if err != nil {
+		return nil, err
+	}
+	if root.name != "svg" {
+		return nil, errNotSVG
+	}
+
+	c := &converter{
+		opts: opts,
+		g:    ivg.NewGraphic(),
+		ids:  map[string]*node{},
+	}
+	c.collectIDs(root)
+	if err := c.setViewBox(root); err != nil {
+		return nil, err
+	}
+	c.setText(root)
+	if err := c.walk(root, defaultContext); err != nil {
+		return nil, err
+	}
+	return c.g, nil
+}
+
+type converter struct {
+	opts *Options
+	g    *ivg.Graphic
+
+	// ids maps element IDs to elements, for resolving url(#id) references.
+	ids map[string]*node
+
+	// opaque[i] is whether g.Shapes[i] is opaque, not counting the opacity
+	// properties of the elements being converted. See flattenOpacity.
+	opaque []bool
+
+	// palette maps extracted and themable colors to their suggested palette
+	// index. allocated and reserved are bitmasks of the palette indices that
+	// have been allocated, and that are reserved for colors that refer to them
+	// by name.
+	palette   map[paletteKey]uint8
+	allocated uint64
+	reserved  uint64
+}
+
+// context holds the inherited properties in effect for an element.
+type context struct {
+	transform        aff
+	color            string
+	fill             string
+	fillOpacity      float64
+	fillRule         lowlevel.FillRule
+	stroke           string
+	strokeOpacity    float64
+	strokeWidth      float64
+	strokeLinecap    ivg.LineCap
+	strokeLinejoin   ivg.LineJoin
+	strokeMiterlimit float64
+	strokeDasharray  []float64
+	strokeDashoffset float64
+	opacity          float64
+
+	// clip is the region, in the graphic's coordinate space, that the
+	// content is clipped to, if clipped is true. It is the intersection of
+	// the clip-path properties of the element and its ancestors.
+	clip    ivg.Path
+	clipped bool
+
+	// vars holds the CSS custom properties in effect. colorVar, fillVar and
+	// strokeVar are the names of the custom properties, if any, that the
+	// color, fill and stroke properties were set from.
+	vars      map[string]string
+	colorVar  string
+	fillVar   string
+	strokeVar string
+}
+
+var defaultContext = context{
+	transform:        identity,
+	color:            "black",
+	fill:             "black",
+	fillOpacity:      1,
+	stroke:           "none",
+	strokeOpacity:    1,
+	strokeWidth:      1,
+	strokeLinecap:    ivg.LineCapButt,
+	strokeLinejoin:   ivg.LineJoinMiter,
+	strokeMiterlimit: 4,
+	opacity:          1,
+}
+
+func (c *converter) collectIDs(n *node) {
+	if id := n.attrs["id"]; id != "" {
+		if _, ok := c.ids[id]; !ok {
+			c.ids[id] = n
+		}
+	}
+	for _, v := range n.attrs {
+		c.reservePalette(v)
+	}
+	for _, child := range n.children {
+		c.collectIDs(child)
+	}
+}
+
+func (c *converter) setViewBox(root *node) error {
+	if s := root.attrs["viewBox"]; s != "" {
+		v, err := parseNumbers(s)
+		if err != nil {
+			return err
+		} else if len(v) != 4 || !(v[2] > 0) || !(v[3] > 0) {
+			return errors.New("svgconv: invalid viewBox")
+		}
+		c.g.Metadata.ViewBox = lowlevel.Rectangle{
+			Min: f32.Vec2{float32(v[0]), float32(v[1])},
+			Max: f32.Vec2{float32(v[0] + v[2]), float32(v[1] + v[3])},
+		}
+		return nil
+	}
+
+	w, err := parseLength(root.attrs["width"], 0)
+	if err != nil {
+		return err
+	}
+	h, err := parseLength(root.attrs["height"], 0)
+	if err != nil {
+		return err
+	}
+	if !(w > 0) || !(h > 0) {
+		return errMissingViewBox
+	}
+	c.g.Metadata.ViewBox = lowlevel.Rectangle{
+		Max: f32.Vec2{float32(w), float32(h)},
+	}
+	return nil
+}
+
+// setText sets the title and description metadata from root's first title and
+// desc children. Runs of white space are collapsed, as SVG user agents do.
+func (c *converter) setText(root *node) {
+	for _, n := range root.children {
+		dst := (*string)(nil)
+		switch n.name {
+		case "title":
+			dst = &c.g.Metadata.Title
+		case "desc":
+			dst = &c.g.Metadata.Description
+		}
+		if (dst != nil) && (*dst == "") {
+			*dst = strings.Join(strings.Fields(strings.ToValidUTF8(n.text, "\uFFFD")), " ")
+		}
+	}
+}
+
+func (c *converter) walk(n *node, ctx context) error {
+	if n.prop("display") == "none" {
+		return nil
+	}
+	ctx, err := c.inherit(n, ctx)
+	if err != nil {
+		return err
+	}
+
+	start := len(c.g.Shapes)
+	switch n.name {
+	case "svg", "g", "a", "switch":
+		for _, child := range n.children {
+			if err := c.walk(child, ctx); err != nil {
+				return err
+			}
+		}
+	case "path", "rect", "circle", "ellipse", "line", "polygon", "polyline":
+		if err := c.convertShape(n, ctx); err != nil {
+			return err
+		}
+	}
+	c.flattenOpacity(n, start)
+	return nil
+}
+
+// inherit returns the context for n's content, given the context of n's
+// parent.
+func (c *converter) inherit(n *node, ctx context) (context, error) {
+	ctx.vars = declareVars(n, ctx.vars)
+	if s := n.prop("color"); s != "" && s != "inherit" {
+		if v, name, ok := resolveVar(s, ctx.vars); ok {
+			ctx.color, ctx.colorVar = v, name
+		}
+	}
+	if s := n.prop("fill"); s != "" && s != "inherit" {
+		if v, name, ok := resolveVar(s, ctx.vars); ok {
+			ctx.fill, ctx.fillVar = v, name
+		}
+	}
+	if s := n.prop("fill-opacity"); s != "" && s != "inherit" {
+		f, err := parseOpacity(s)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.fillOpacity = f
+	}
+	switch n.prop("fill-rule") {
+	case "nonzero":
+		ctx.fillRule = lowlevel.FillRuleNonZero
+	case "evenodd":
+		ctx.fillRule = lowlevel.FillRuleEvenOdd
+	}
+	if s := n.prop("stroke"); s != "" && s != "inherit" {
+		if v, name, ok := resolveVar(s, ctx.vars); ok {
+			ctx.stroke, ctx.strokeVar = v, name
+		}
+	}
+	if s := n.prop("stroke-opacity"); s != "" && s != "inherit" {
+		f, err := parseOpacity(s)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.strokeOpacity = f
+	}
+	vb := &c.g.Metadata.ViewBox
+	vbW, vbH := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	diagonal := math.Sqrt((vbW*vbW + vbH*vbH) / 2)
+	if s := n.prop("stroke-width"); s != "" && s != "inherit" {
+		f, err := parseLength(s, diagonal)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.strokeWidth = f
+	}
+	if s := n.prop("stroke-dasharray"); s != "" && s != "inherit" {
+		d, err := parseDashArray(s, diagonal)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.strokeDasharray = d
+	}
+	if s := n.prop("stroke-dashoffset"); s != "" && s != "inherit" {
+		f, err := parseLength(s, diagonal)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.strokeDashoffset = f
+	}
+	switch n.prop("stroke-linecap") {
+	case "butt":
+		ctx.strokeLinecap = ivg.LineCapButt
+	case "round":
+		ctx.strokeLinecap = ivg.LineCapRound
+	case "square":
+		ctx.strokeLinecap = ivg.LineCapSquare
+	}
+	switch n.prop("stroke-linejoin") {
+	case "miter", "miter-clip", "arcs":
+		ctx.strokeLinejoin = ivg.LineJoinMiter
+	case "round":
+		ctx.strokeLinejoin = ivg.LineJoinRound
+	case "bevel":
+		ctx.strokeLinejoin = ivg.LineJoinBevel
+	}
+	if s := n.prop("stroke-miterlimit"); s != "" && s != "inherit" {
+		f, err := parseLength(s, 1)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.strokeMiterlimit = f
+	}
+	if s := n.prop("opacity"); s != "" && s != "inherit" {
+		f, err := parseOpacity(s)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.opacity *= f
+	}
+	if s := n.attrs["transform"]; s != "" {
+		t, err := parseTransform(s)
+		if err != nil {
+			return context{}, err
+		}
+		ctx.transform = ctx.transform.mul(t)
+	}
+	region, ok, err := c.clipRegion(n, ctx)
+	if err != nil {
+		return context{}, err
+	} else if ok {
+		if ctx.clipped {
+			region = ivg.Intersect(ctx.clip, region, 0)
+		}
+		ctx.clip, ctx.clipped = region, true
+	}
+	return ctx, nil
+}
+
+func (c *converter) convertShape(n *node, ctx context) error {
+	p, err := c.geometry(n)
+	if err != nil {
+		return err
+	} else if len(p) == 0 {
+		return nil
+	}
+
+	// A line has no interior, so it is never filled.
+	if n.name != "line" {
+		paint, ok, err := c.paint(ctx, ctx.fill, ctx.fillVar, ctx.fillOpacity, p)
+		if err != nil {
+			return err
+		} else if ok {
+			c.addShape(ctx, paint, p, ctx.fillRule, isOpaque(ctx, ctx.fill, ctx.fillOpacity))
+		}
+	}
+
+	// The stroke is painted over the fill. It is converted to a fill in user
+	// space, before transforming, so that a non-uniform scale also scales the
+	// pen. Like the fill, a gradient's bounding box is that of the geometry.
+	if ctx.strokeWidth > 0 {
+		paint, ok, err := c.paint(ctx, ctx.stroke, ctx.strokeVar, ctx.strokeOpacity, p)
+		if err != nil {
+			return err
+		} else if ok {
+			dashArray := make([]float32, len(ctx.strokeDasharray))
+			for i, d := range ctx.strokeDasharray {
+				dashArray[i] = float32(d)
+			}
+			sp := ivg.DashedStrokeToFill(p, float32(ctx.strokeWidth),
+				ctx.strokeLinecap, ctx.strokeLinejoin, float32(ctx.strokeMiterlimit),
+				dashArray, float32(ctx.strokeDashoffset))
+			if len(sp) > 0 {
+				c.addShape(ctx, paint, sp, lowlevel.FillRuleNonZero, isOpaque(ctx, ctx.stroke, ctx.strokeOpacity))
+			}
+		}
+	}
+	return nil
+}
+
+// addShape adds a shape with the given paint, user-space path and fill rule,
+// clipped to the context's clip region. opaque is as for c.opaque.
+func (c *converter) addShape(ctx context, paint ivg.Paint, p ivg.Path, rule lowlevel.FillRule, opaque bool) {
+	q := make(ivg.Path, len(p))
+	for i, seg := range p {
+		q[i] = transformSegment(seg, ctx.transform)
+	}
+	if ctx.clipped {
+		if q, rule = clipShape(q, rule, ctx.clip); len(q) == 0 {
+			return
+		}
+	}
+	for i, seg := range q {
+		q[i] = c.quantize(seg)
+	}
+	c.g.Shapes = append(c.g.Shapes, ivg.Shape{
+		Paint:    paint,
+		Path:     q,
+		LOD0:     ivg.DefaultLOD0,
+		LOD1:     ivg.DefaultLOD1,
+		FillRule: rule,
+	})
+	c.opaque = append(c.opaque, opaque)
+}
+
+// paint returns the Paint, for the fill or stroke property value s and its
+// opacity, for a shape with the given user-space path. name is the custom
+// property, if any, that s was set from. It returns ok == false if the fill or
+// stroke is invisible.
+func (c *converter) paint(ctx context, s string, name string, opacity float64, p ivg.Path) (paint ivg.Paint, ok bool, err error) {
+	alpha := opacity * ctx.opacity
+	if s == "none" || alpha <= 0 {
+		return ivg.Paint{}, false, nil
+	}
+	if id, isURL := parseURL(s); isURL {
+		if n := c.ids[id]; n != nil && (n.name == "linearGradient" || n.name == "radialGradient") {
+			return c.gradient(n, ctx, alpha, p)
+		}
+		return ivg.Paint{}, false, nil
+	}
+
+	col, rgba, err := c.flatColor(ctx, s, name, alpha)
+	if err != nil {
+		return ivg.Paint{}, false, err
+	} else if rgba.A == 0 {
+		return ivg.Paint{}, false, nil
+	}
+	return ivg.Paint{Color: col}, true, nil
+}
+
+// color returns the Color for rgba, extracting it to the suggested palette
+// if the options say so.
+func (c *converter) color(rgba color.RGBA) lowlevel.Color {
+	if c.opts.ExtractPalette {
+		if i, ok := c.paletteIndex(paletteKey{rgba: rgba}); ok {
+			return lowlevel.PaletteIndexColor(i)
+		}
+	}
+	return lowlevel.RGBAColor(rgba)
+}
+
+// quantize rounds seg's coordinates to the options' precision.
+func (c *converter) quantize(seg ivg.Segment) ivg.Segment {
+	if !(c.opts.Precision > 0) {
+		return seg
+	}
+	q := func(v f32.Vec2) f32.Vec2 {
+		return f32.Vec2{roundTo(v[0], c.opts.Precision), roundTo(v[1], c.opts.Precision)}
+	}
+	switch seg := seg.(type) {
+	case ivg.MoveTo:
+		return ivg.MoveTo{To: q(seg.To)}
+	case ivg.LineTo:
+		return ivg.LineTo{To: q(seg.To)}
+	case ivg.QuadTo:
+		return ivg.QuadTo{Ctrl: q(seg.Ctrl), To: q(seg.To)}
+	case ivg.CubeTo:
+		return ivg.CubeTo{Ctrl0: q(seg.Ctrl0), Ctrl1: q(seg.Ctrl1), To: q(seg.To)}
+	case ivg.ArcTo:
+		seg.Radii = q(seg.Radii)
+		seg.To = q(seg.To)
+		return seg
+	}
+	return seg
+}
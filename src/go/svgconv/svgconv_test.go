@@ -0,0 +1,391 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv_test
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/svgconv"
+	"golang.org/x/image/math/f32"
+)
+
+func TestParseGeometry(t *testing.T) {
+	triangle := ivg.Path{
+		ivg.MoveTo{To: f32.Vec2{2, 3}},
+		ivg.LineTo{To: f32.Vec2{6, 3}},
+		ivg.LineTo{To: f32.Vec2{6, 7}},
+		ivg.ClosePath{},
+	}
+	testCases := []struct {
+		desc string
+		elem string
+		want ivg.Path
+	}{{
+		desc: "path with absolute ops",
+		elem: `<path d="M2 2h20v20H2z"/>`,
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{2, 2}},
+			ivg.LineTo{To: f32.Vec2{22, 2}},
+			ivg.LineTo{To: f32.Vec2{22, 22}},
+			ivg.LineTo{To: f32.Vec2{2, 22}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "path with relative and smooth ops",
+		elem: `<path d="m2 2 10 0 0 10z M1,1 Q 5 5 9 1 T 17 1"/>`,
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{2, 2}},
+			ivg.LineTo{To: f32.Vec2{12, 2}},
+			ivg.LineTo{To: f32.Vec2{12, 12}},
+			ivg.ClosePath{},
+			ivg.MoveTo{To: f32.Vec2{1, 1}},
+			ivg.QuadTo{Ctrl: f32.Vec2{5, 5}, To: f32.Vec2{9, 1}},
+			ivg.QuadTo{Ctrl: f32.Vec2{13, -3}, To: f32.Vec2{17, 1}},
+		},
+	}, {
+		desc: "rect",
+		elem: `<rect x="2" y="4" width="6" height="8"/>`,
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{2, 4}},
+			ivg.LineTo{To: f32.Vec2{8, 4}},
+			ivg.LineTo{To: f32.Vec2{8, 12}},
+			ivg.LineTo{To: f32.Vec2{2, 12}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "circle",
+		elem: `<circle cx="12" cy="12" r="4"/>`,
+		want: ivg.Path{
+			ivg.MoveTo{To: f32.Vec2{16, 12}},
+			ivg.ArcTo{Radii: f32.Vec2{4, 4}, Sweep: true, To: f32.Vec2{8, 12}},
+			ivg.ArcTo{Radii: f32.Vec2{4, 4}, Sweep: true, To: f32.Vec2{16, 12}},
+			ivg.ClosePath{},
+		},
+	}, {
+		desc: "polygon",
+		elem: `<polygon points="2,3 6,3 6,7"/>`,
+		want: triangle,
+	}, {
+		desc: "translated group",
+		elem: `<g transform="translate(2 3)"><path d="M0 0L4 0L4 4Z"/></g>`,
+		want: triangle,
+	}}
+	for _, tc := range testCases {
+		g, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+tc.elem+`</svg>`), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if len(g.Shapes) != 1 {
+			t.Errorf("%s: got %d shapes, want 1", tc.desc, len(g.Shapes))
+			continue
+		}
+		if got := g.Shapes[0].Path; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestParseFill(t *testing.T) {
+	testCases := []struct {
+		attrs string
+		want  []lowlevel.Color
+	}{
+		{``, []lowlevel.Color{lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})}},
+		{`fill="#f00"`, []lowlevel.Color{lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})}},
+		{`style="fill:rgb(0,0,255);fill-opacity:0.5"`, []lowlevel.Color{lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x80, 0x80})}},
+		{`fill="none"`, nil},
+	}
+	for _, tc := range testCases {
+		src := `<svg viewBox="0 0 24 24"><path ` + tc.attrs + ` d="M0 0L4 0L4 4Z"/></svg>`
+		g, err := svgconv.Parse([]byte(src), nil)
+		if err != nil {
+			t.Errorf("%q: %v", tc.attrs, err)
+			continue
+		}
+		var got []lowlevel.Color
+		for _, s := range g.Shapes {
+			got = append(got, s.Paint.Color)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.attrs, got, tc.want)
+		}
+	}
+}
+
+func TestParseStroke(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	testCases := []struct {
+		desc      string
+		elem      string
+		wantPaint []lowlevel.Color
+		// wantMin and wantMax bound the last shape's path.
+		wantMin, wantMax f32.Vec2
+	}{{
+		desc:      "butt cap",
+		elem:      `<line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-width="2"/>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{2, 3},
+		wantMax:   f32.Vec2{10, 5},
+	}, {
+		desc:      "square cap",
+		elem:      `<line x1="2" y1="4" x2="10" y2="4" style="stroke:#f00;stroke-width:2;stroke-linecap:square"/>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{1, 3},
+		wantMax:   f32.Vec2{11, 5},
+	}, {
+		desc:      "stroke opacity",
+		elem:      `<line x1="2" y1="4" x2="10" y2="4" stroke="#00f" stroke-opacity="0.5"/>`,
+		wantPaint: []lowlevel.Color{lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x80, 0x80})},
+		wantMin:   f32.Vec2{2, 3.5},
+		wantMax:   f32.Vec2{10, 4.5},
+	}, {
+		desc: "fill and stroke",
+		elem: `<rect x="2" y="2" width="8" height="8" stroke="#f00" stroke-width="2"/>`,
+		wantPaint: []lowlevel.Color{
+			lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff}),
+			red,
+		},
+		wantMin: f32.Vec2{1, 1},
+		wantMax: f32.Vec2{11, 11},
+	}, {
+		desc:      "inherited stroke",
+		elem:      `<g stroke="#f00" stroke-width="4"><polyline points="4,4 12,4" fill="none"/></g>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{4, 2},
+		wantMax:   f32.Vec2{12, 6},
+	}, {
+		desc:      "dash array",
+		elem:      `<line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-width="2" stroke-dasharray="2 2"/>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{2, 3},
+		wantMax:   f32.Vec2{8, 5},
+	}, {
+		desc:      "dash offset",
+		elem:      `<line x1="2" y1="4" x2="10" y2="4" style="stroke:#f00;stroke-width:2;stroke-dasharray:2,2;stroke-dashoffset:3"/>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{3, 3},
+		wantMax:   f32.Vec2{9, 5},
+	}, {
+		desc:      "inherited dash array",
+		elem:      `<g stroke-dasharray="3 5"><line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-width="2"/></g>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{2, 3},
+		wantMax:   f32.Vec2{5, 5},
+	}, {
+		desc:      "dash array none",
+		elem:      `<g stroke-dasharray="2"><line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-width="2" stroke-dasharray="none"/></g>`,
+		wantPaint: []lowlevel.Color{red},
+		wantMin:   f32.Vec2{2, 3},
+		wantMax:   f32.Vec2{10, 5},
+	}}
+	for _, tc := range testCases {
+		g, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+tc.elem+`</svg>`), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		var gotPaint []lowlevel.Color
+		for _, s := range g.Shapes {
+			gotPaint = append(gotPaint, s.Paint.Color)
+		}
+		if !reflect.DeepEqual(gotPaint, tc.wantPaint) {
+			t.Errorf("%s: paint: got %v, want %v", tc.desc, gotPaint, tc.wantPaint)
+			continue
+		}
+		gotMin, gotMax := f32.Vec2{+1e9, +1e9}, f32.Vec2{-1e9, -1e9}
+		for _, seg := range g.Shapes[len(g.Shapes)-1].Path {
+			var p f32.Vec2
+			switch seg := seg.(type) {
+			case ivg.MoveTo:
+				p = seg.To
+			case ivg.LineTo:
+				p = seg.To
+			default:
+				continue
+			}
+			for i := range p {
+				if gotMin[i] > p[i] {
+					gotMin[i] = p[i]
+				}
+				if gotMax[i] < p[i] {
+					gotMax[i] = p[i]
+				}
+			}
+		}
+		if gotMin != tc.wantMin || gotMax != tc.wantMax {
+			t.Errorf("%s: bounds: got %v-%v, want %v-%v", tc.desc, gotMin, gotMax, tc.wantMin, tc.wantMax)
+		}
+	}
+}
+
+func TestParseStrokeErrors(t *testing.T) {
+	testCases := []string{
+		`<line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-dasharray="2 x"/>`,
+		`<line x1="2" y1="4" x2="10" y2="4" stroke="#f00" stroke-dashoffset="x"/>`,
+	}
+	for _, tc := range testCases {
+		if _, err := svgconv.Parse([]byte(`<svg viewBox="0 0 24 24">`+tc+`</svg>`), nil); err == nil {
+			t.Errorf("%s: got nil error, want non-nil", tc)
+		}
+	}
+}
+
+func TestParseViewBox(t *testing.T) {
+	testCases := []struct {
+		src     string
+		want    lowlevel.Rectangle
+		wantErr bool
+	}{
+		{`<svg viewBox="0 0 24 24"/>`, lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{24, 24}}, false},
+		{`<svg viewBox="-8,-4,16,8"/>`, lowlevel.Rectangle{Min: f32.Vec2{-8, -4}, Max: f32.Vec2{8, 4}}, false},
+		{`<svg width="24" height="12"/>`, lowlevel.Rectangle{Min: f32.Vec2{0, 0}, Max: f32.Vec2{24, 12}}, false},
+		{`<svg/>`, lowlevel.Rectangle{}, true},
+		{`<html/>`, lowlevel.Rectangle{}, true},
+		{`<svg viewBox="0 0 24 24"><path d="M0 0L4 q"/></svg>`, lowlevel.Rectangle{}, true},
+	}
+	for _, tc := range testCases {
+		g, err := svgconv.Parse([]byte(tc.src), nil)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.src, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got := g.Metadata.ViewBox; got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	testCases := []struct {
+		src             string
+		wantTitle       string
+		wantDescription string
+	}{
+		{`<svg viewBox="0 0 24 24"/>`, "", ""},
+		{`<svg viewBox="0 0 24 24"><title>Info</title><desc>More
+			details</desc></svg>`, "Info", "More details"},
+		// Only the root's first title counts, not those of its descendants.
+		{`<svg viewBox="0 0 24 24"><g><title>Group</title></g><title> A &amp; B </title>` +
+			`<title>Second</title></svg>`, "A & B", ""},
+		{`<svg viewBox="0 0 24 24"><desc>Only <b>some</b> text</desc></svg>`, "", "Only text"},
+	}
+	for _, tc := range testCases {
+		g, err := svgconv.Parse([]byte(tc.src), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if got := g.Metadata.Title; got != tc.wantTitle {
+			t.Errorf("%s: Title: got %q, want %q", tc.src, got, tc.wantTitle)
+		}
+		if got := g.Metadata.Description; got != tc.wantDescription {
+			t.Errorf("%s: Description: got %q, want %q", tc.src, got, tc.wantDescription)
+		}
+	}
+}
+
+func TestConvertTestData(t *testing.T) {
+	testCases := []string{
+		"action-info.svg",
+		"cowbell.svg",
+		"favicon.svg",
+		"video-005.primitive.svg",
+	}
+	for _, tc := range testCases {
+		src, err := os.ReadFile("../../../test/data/" + tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := svgconv.Convert(src, nil)
+		if err != nil {
+			t.Errorf("%s: Convert: %v", tc, err)
+			continue
+		}
+		g, err := ivg.Decode(dst, nil)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tc, err)
+			continue
+		}
+		if len(g.Shapes) == 0 {
+			t.Errorf("%s: got no shapes", tc)
+		}
+	}
+}
+
+func TestConvertManyStops(t *testing.T) {
+	// IconVG can encode at most ivg.MaxGradientStops stops, so Convert
+	// resamples this 100 stop gradient.
+	src := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 48 48">` +
+		`<linearGradient id="g" gradientUnits="userSpaceOnUse" x1="0" y1="0" x2="48" y2="0">`)
+	for i := 0; i < 100; i++ {
+		src = append(src, fmt.Sprintf(`<stop offset="%g" stop-color="#%02x0000"/>`, float64(i)/99, i*255/99)...)
+	}
+	src = append(src, `</linearGradient><rect width="48" height="48" fill="url(#g)"/></svg>`...)
+
+	dst, err := svgconv.Convert(src, nil)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	g, err := ivg.Decode(dst, nil)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(g.Shapes) != 1 || g.Shapes[0].Paint.Gradient == nil {
+		t.Fatalf("got %d shapes, want 1 with a gradient", len(g.Shapes))
+	}
+	if n := len(g.Shapes[0].Paint.Gradient.Stops); n != ivg.MaxGradientStops {
+		t.Errorf("got %d stops, want %d", n, ivg.MaxGradientStops)
+	}
+}
+
+func TestParseFocal(t *testing.T) {
+	testCases := []struct {
+		attrs string
+		want  f32.Vec2
+	}{
+		{`cx="24" cy="24" r="16"`, f32.Vec2{}},
+		{`cx="24" cy="24" r="16" fx="32" fy="16"`, f32.Vec2{0.5, -0.5}},
+		{`cx="24" cy="24" r="16" fx="28"`, f32.Vec2{0.25, 0}},
+		{`cx="24" cy="24" r="16" fy="40"`, f32.Vec2{0, 1}},
+	}
+	for _, tc := range testCases {
+		src := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 48 48">` +
+			`<radialGradient id="g" gradientUnits="userSpaceOnUse" ` + tc.attrs + `>` +
+			`<stop offset="0" stop-color="#000"/><stop offset="1" stop-color="#fff"/>` +
+			`</radialGradient><rect width="48" height="48" fill="url(#g)"/></svg>`
+		g, err := svgconv.Parse([]byte(src), nil)
+		if err != nil {
+			t.Errorf("%q: %v", tc.attrs, err)
+			continue
+		}
+		if len(g.Shapes) != 1 || g.Shapes[0].Paint.Gradient == nil {
+			t.Errorf("%q: got %d shapes, want 1 with a gradient", tc.attrs, len(g.Shapes))
+			continue
+		}
+		if got := g.Shapes[0].Paint.Gradient.Focal; got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.attrs, got, tc.want)
+		}
+	}
+}
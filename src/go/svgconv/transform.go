@@ -0,0 +1,184 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svgconv
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+	"golang.org/x/image/math/f64"
+)
+
+// aff is a 2-D affine transformation, laid out like f32.Aff3 (as a 2x3 matrix
+// in row-major order). Note that this differs from SVG's matrix(a b c d e f)
+// order, which is column-major.
+type aff [6]float64
+
+var identity = aff{1, 0, 0, 0, 1, 0}
+
+// mul returns the transformation that applies u and then t.
+func (t aff) mul(u aff) aff {
+	return aff{
+		t[0]*u[0] + t[1]*u[3],
+		t[0]*u[1] + t[1]*u[4],
+		t[0]*u[2] + t[1]*u[5] + t[2],
+		t[3]*u[0] + t[4]*u[3],
+		t[3]*u[1] + t[4]*u[4],
+		t[3]*u[2] + t[4]*u[5] + t[5],
+	}
+}
+
+func (t aff) det() float64 {
+	return t[0]*t[4] - t[1]*t[3]
+}
+
+// invert returns the inverse of t, and whether t is invertible.
+func (t aff) invert() (aff, bool) {
+	inv, ok := lowlevel.InvertAff3(f64.Aff3(t))
+	return aff(inv), ok
+}
+
+func (t aff) apply(v f32.Vec2) f32.Vec2 {
+	x, y := float64(v[0]), float64(v[1])
+	return f32.Vec2{
+		float32(t[0]*x + t[1]*y + t[2]),
+		float32(t[3]*x + t[4]*y + t[5]),
+	}
+}
+
+func (t aff) toF32() f32.Aff3 {
+	return f32.Aff3{
+		float32(t[0]), float32(t[1]), float32(t[2]),
+		float32(t[3]), float32(t[4]), float32(t[5]),
+	}
+}
+
+// parseTransform parses a transform attribute, such as
+// "translate(10 20) rotate(45)".
+func parseTransform(s string) (aff, error) {
+	t := identity
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, " \t\r\n,") {
+		i := strings.IndexByte(s, '(')
+		j := strings.IndexByte(s, ')')
+		if i < 0 || j < i {
+			return aff{}, fmt.Errorf("svgconv: invalid transform %q", s)
+		}
+		name := strings.TrimSpace(s[:i])
+		args, err := parseNumbers(s[i+1 : j])
+		if err != nil {
+			return aff{}, err
+		}
+		s = s[j+1:]
+
+		u, ok := aff{}, false
+		switch n := len(args); name {
+		case "matrix":
+			if ok = n == 6; ok {
+				u = aff{args[0], args[2], args[4], args[1], args[3], args[5]}
+			}
+		case "translate":
+			if ok = n == 1 || n == 2; ok {
+				u = aff{1, 0, args[0], 0, 1, 0}
+				if n == 2 {
+					u[5] = args[1]
+				}
+			}
+		case "scale":
+			if ok = n == 1 || n == 2; ok {
+				u = aff{args[0], 0, 0, 0, args[0], 0}
+				if n == 2 {
+					u[4] = args[1]
+				}
+			}
+		case "rotate":
+			if ok = n == 1 || n == 3; ok {
+				sin, cos := math.Sincos(args[0] * math.Pi / 180)
+				u = aff{cos, -sin, 0, sin, cos, 0}
+				if n == 3 {
+					u = aff{1, 0, args[1], 0, 1, args[2]}.mul(u).mul(aff{1, 0, -args[1], 0, 1, -args[2]})
+				}
+			}
+		case "skewX":
+			if ok = n == 1; ok {
+				u = aff{1, math.Tan(args[0] * math.Pi / 180), 0, 0, 1, 0}
+			}
+		case "skewY":
+			if ok = n == 1; ok {
+				u = aff{1, 0, 0, math.Tan(args[0] * math.Pi / 180), 1, 0}
+			}
+		}
+		if !ok {
+			return aff{}, fmt.Errorf("svgconv: invalid transform %q(...)", name)
+		}
+		t = t.mul(u)
+	}
+	return t, nil
+}
+
+// transformSegment applies t to seg.
+func transformSegment(seg ivg.Segment, t aff) ivg.Segment {
+	if t == identity {
+		return seg
+	}
+	switch seg := seg.(type) {
+	case ivg.MoveTo:
+		return ivg.MoveTo{To: t.apply(seg.To)}
+	case ivg.LineTo:
+		return ivg.LineTo{To: t.apply(seg.To)}
+	case ivg.QuadTo:
+		return ivg.QuadTo{Ctrl: t.apply(seg.Ctrl), To: t.apply(seg.To)}
+	case ivg.CubeTo:
+		return ivg.CubeTo{Ctrl0: t.apply(seg.Ctrl0), Ctrl1: t.apply(seg.Ctrl1), To: t.apply(seg.To)}
+	case ivg.ArcTo:
+		return transformArc(seg, t)
+	}
+	return seg
+}
+
+// transformArc applies t to an elliptical arc. An affine transformation maps
+// an ellipse to another ellipse, whose axes are found from the eigenvectors
+// of M*Mᵀ, where M maps the unit circle to the transformed ellipse.
+func transformArc(a ivg.ArcTo, t aff) ivg.Segment {
+	sin, cos := math.Sincos(2 * math.Pi * float64(a.XAxisRotation))
+	rx, ry := float64(a.Radii[0]), float64(a.Radii[1])
+	m := aff{cos * rx, -sin * ry, 0, sin * rx, cos * ry, 0}
+	m = aff{t[0], t[1], 0, t[3], t[4], 0}.mul(m)
+
+	p := m[0]*m[0] + m[1]*m[1]
+	q := m[0]*m[3] + m[1]*m[4]
+	r := m[3]*m[3] + m[4]*m[4]
+	mid := (p + r) / 2
+	disc := math.Sqrt((p-r)*(p-r)/4 + q*q)
+	l0, l1 := mid+disc, math.Max(0, mid-disc)
+	if l0 <= 0 || l1 <= 0 {
+		return ivg.LineTo{To: t.apply(a.To)}
+	}
+	theta := math.Atan2(2*q, p-r) / 2
+	if theta < 0 {
+		theta += math.Pi
+	}
+
+	a.Radii = f32.Vec2{float32(math.Sqrt(l0)), float32(math.Sqrt(l1))}
+	a.XAxisRotation = float32(theta / (2 * math.Pi))
+	if t.det() < 0 {
+		a.Sweep = !a.Sweep
+	}
+	a.To = t.apply(a.To)
+	return a
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tessellate converts IconVG graphics to triangle meshes, so that
+// GPU-based renderers, such as game engines, can upload an icon once and draw
+// it at any scale without rasterizing it again.
+//
+// Each Mesh is filled with one paint: a flat color, given in every vertex, or
+// a gradient, whose coordinates are given in every vertex, so that a fragment
+// shader can evaluate it. Drawing the Meshes in order, with premultiplied
+// alpha "over" blending, draws the graphic.
+package tessellate
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"golang.org/x/image/math/f32"
+)
+
+// Mesh is a set of triangles that are filled with the same paint and that
+// have the same level of detail bounds.
+type Mesh struct {
+	// Vertices are the triangles' corners. Indices, three per triangle, index
+	// Vertices. Triangles are wound clockwise, with the y axis pointing down,
+	// and do not overlap unless the graphic's shapes do.
+	Vertices []Vertex
+	Indices  []uint32
+
+	// Gradient is the mesh's gradient, or nil if the mesh is filled with the
+	// flat color in its vertices.
+	Gradient *Gradient
+
+	// LOD0 and LOD1 are the level of detail bounds, as for an ivg.Shape. The
+	// Mesh is only drawn when the height H in pixels of the rendering
+	// satisfies (LOD0 <= H) and (H < LOD1).
+	LOD0, LOD1 float32
+}
+
+// Vertex is a corner of a Mesh's triangles.
+type Vertex struct {
+	// Pos is the position, in the graphic's coordinate space (defined by the
+	// metadata's ViewBox).
+	Pos f32.Vec2
+
+	// Color is the alpha-premultiplied color of a flat Mesh. It is zero for a
+	// gradient Mesh.
+	Color color.RGBA
+
+	// GradientPos is the position in the gradient's coordinate space, where a
+	// linear gradient ranges from x=0 to x=1 and a radial gradient has center
+	// (0, 0) and radius 1. It is zero for a flat Mesh. As it is an affine
+	// function of Pos, it can be interpolated across a triangle.
+	GradientPos f32.Vec2
+}
+
+// Gradient is a Mesh's linear or radial gradient. Its offset at a point is
+// GradientPos's x for a linear gradient and GradientPos's length for a
+// radial one, unless it has a Focal point. The Spread says what happens past
+// offsets 0 and 1.
+type Gradient struct {
+	Shape  ivg.GradientShape
+	Spread ivg.GradientSpread
+	Stops  []Stop
+
+	// Focal is, for a radial gradient, its focal point, as for an
+	// ivg.Gradient. If it is non-zero, the offset at a point is the t such
+	// that GradientPos is on the circle with center (1-t)*Focal and radius
+	// t, which a fragment shader can solve for.
+	Focal f32.Vec2
+}
+
+// Stop is a color/offset stop of a Gradient. Its color is
+// alpha-premultiplied.
+type Stop struct {
+	Offset float32
+	Color  color.RGBA
+}
+
+// Decode decodes the IconVG graphic src and tessellates it. See Graphic.
+func Decode(src []byte, tolerance float32) ([]Mesh, error) {
+	g, err := ivg.Decode(src, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Graphic(g, tolerance), nil
+}
+
+// Graphic tessellates g, returning Meshes to be drawn in order. Consecutive
+// Shapes with the same paint and level of detail bounds share a Mesh.
+//
+// Curves and arcs are flattened to line segments that are within tolerance,
+// in graphic coordinate space, of the true curve. If tolerance is not
+// positive, it is 1/4096th of the larger dimension of each Shape's bounding
+// box. Colors that refer to the palette are resolved with g's suggested
+// palette.
+func Graphic(g *ivg.Graphic, tolerance float32) []Mesh {
+	meshes := []Mesh(nil)
+	// index maps the current Mesh's vertices to their indices.
+	index := map[Vertex]uint32(nil)
+	for i := range g.Shapes {
+		s := &g.Shapes[i]
+		flat, grad, ok := resolvePaint(&s.Paint, &g.Metadata.Palette)
+		if !ok {
+			continue
+		}
+		path := s.Path
+		if s.FillRule == lowlevel.FillRuleEvenOdd {
+			path = ivg.EvenOddToNonZero(path, tolerance)
+		}
+		traps := trapezoids(ivg.Union(path, nil, tolerance))
+		if len(traps) == 0 {
+			continue
+		}
+
+		if n := len(meshes); (n == 0) || !meshes[n-1].matches(flat, grad, s) {
+			meshes = append(meshes, Mesh{Gradient: grad, LOD0: s.LOD0, LOD1: s.LOD1})
+			index = map[Vertex]uint32{}
+		}
+		m := &meshes[len(meshes)-1]
+		vertex := func(x, y float64) uint32 {
+			v := Vertex{Pos: f32.Vec2{float32(x), float32(y)}}
+			if grad != nil {
+				t := &s.Paint.Gradient.Transform
+				v.GradientPos = f32.Vec2{
+					t[0]*v.Pos[0] + t[1]*v.Pos[1] + t[2],
+					t[3]*v.Pos[0] + t[4]*v.Pos[1] + t[5],
+				}
+			} else {
+				v.Color = flat
+			}
+			j, ok := index[v]
+			if !ok {
+				j = uint32(len(m.Vertices))
+				index[v] = j
+				m.Vertices = append(m.Vertices, v)
+			}
+			return j
+		}
+		for _, t := range traps {
+			tl, tr := vertex(t.l.x(t.y0), t.y0), vertex(t.r.x(t.y0), t.y0)
+			bl, br := vertex(t.l.x(t.y1), t.y1), vertex(t.r.x(t.y1), t.y1)
+			if tl != tr {
+				m.Indices = append(m.Indices, tl, tr, br)
+			}
+			if bl != br {
+				m.Indices = append(m.Indices, tl, br, bl)
+			}
+		}
+	}
+	return meshes
+}
+
+// resolvePaint returns p's flat color or gradient. It returns false if p is
+// invisible: a transparent flat color.
+func resolvePaint(p *ivg.Paint, pal *lowlevel.Palette) (flat color.RGBA, grad *Gradient, ok bool) {
+	cReg := [64]color.RGBA(*pal)
+	if p.Gradient == nil {
+		flat = p.Color.Resolve(pal, &cReg)
+		return flat, nil, flat.A != 0
+	}
+	grad = &Gradient{
+		Shape:  p.Gradient.Shape,
+		Spread: p.Gradient.Spread,
+		Stops:  make([]Stop, len(p.Gradient.Stops)),
+	}
+	if grad.Shape == ivg.GradientShapeRadial {
+		grad.Focal = p.Gradient.Focal
+	}
+	for i, s := range p.Gradient.Stops {
+		grad.Stops[i] = Stop{Offset: s.Offset, Color: s.Color.Resolve(pal, &cReg)}
+	}
+	return color.RGBA{}, grad, true
+}
+
+// matches returns whether a Shape with the given paint and level of detail
+// bounds can be added to m.
+func (m *Mesh) matches(flat color.RGBA, grad *Gradient, s *ivg.Shape) bool {
+	if (m.LOD0 != s.LOD0) || (m.LOD1 != s.LOD1) {
+		return false
+	} else if (m.Gradient == nil) || (grad == nil) {
+		return (m.Gradient == nil) && (grad == nil) && (m.Vertices[0].Color == flat)
+	}
+	g := m.Gradient
+	if (g.Shape != grad.Shape) || (g.Spread != grad.Spread) || (g.Focal != grad.Focal) ||
+		(len(g.Stops) != len(grad.Stops)) {
+		return false
+	}
+	for i := range g.Stops {
+		if g.Stops[i] != grad.Stops[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// edge is a non-horizontal polygon edge, from top to bottom. dir is +1 if the
+// polygon goes downwards along it and -1 if upwards.
+type edge struct {
+	x0, y0 float64
+	x1, y1 float64
+	dir    int
+}
+
+// x returns the edge's x coordinate at y.
+func (e *edge) x(y float64) float64 {
+	if y == e.y0 {
+		return e.x0
+	} else if y == e.y1 {
+		return e.x1
+	}
+	return e.x0 + (e.x1-e.x0)*(y-e.y0)/(e.y1-e.y0)
+}
+
+// trapezoid is the area between the edges l and r, from y0 down to y1.
+type trapezoid struct {
+	l, r   *edge
+	y0, y1 float64
+}
+
+// trapezoids decomposes the area that p's polygons fill, with the non-zero
+// rule, into trapezoids with horizontal tops and bottoms. p's polygons'
+// edges, as returned by ivg.Union, must only meet at their end points.
+//
+// Cutting the plane into horizontal slabs at every vertex leaves no vertex
+// within a slab, so that the edges that cross a slab are in the same order
+// from its top to its bottom. A trapezoid spans consecutive slabs while its
+// two edges bound a filled span in each.
+func trapezoids(p ivg.Path) []trapezoid {
+	edges := []*edge(nil)
+	ys := []float64(nil)
+	start, pen := f32.Vec2{}, f32.Vec2{}
+	addEdge := func(a, b f32.Vec2) {
+		e := &edge{float64(a[0]), float64(a[1]), float64(b[0]), float64(b[1]), +1}
+		if e.y0 == e.y1 {
+			return
+		} else if e.y0 > e.y1 {
+			e.x0, e.y0, e.x1, e.y1, e.dir = e.x1, e.y1, e.x0, e.y0, -1
+		}
+		edges = append(edges, e)
+		ys = append(ys, e.y0, e.y1)
+	}
+	for _, seg := range p {
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			start, pen = seg.To, seg.To
+		case ivg.LineTo:
+			addEdge(pen, seg.To)
+			pen = seg.To
+		case ivg.ClosePath:
+			addEdge(pen, start)
+			pen = start
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	sort.Float64s(ys)
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	traps := []trapezoid(nil)
+	// open maps an edge pair to the index in traps of the trapezoid between
+	// them that ends at the current slab's top, if any.
+	open := map[[2]*edge]int{}
+	active := []*edge(nil)
+	next := 0
+	for i := 1; i < len(ys); i++ {
+		y0, y1 := ys[i-1], ys[i]
+		if y0 == y1 {
+			continue
+		}
+		// Update the active edges: those that span the slab.
+		kept := active[:0]
+		for _, e := range active {
+			if e.y1 > y0 {
+				kept = append(kept, e)
+			}
+		}
+		active = kept
+		for ; (next < len(edges)) && (edges[next].y0 <= y0); next++ {
+			active = append(active, edges[next])
+		}
+		ym := (y0 + y1) / 2
+		sort.Slice(active, func(i, j int) bool { return active[i].x(ym) < active[j].x(ym) })
+
+		w, left := 0, (*edge)(nil)
+		for _, e := range active {
+			w0 := w
+			w += e.dir
+			if w0 == 0 {
+				left = e
+			} else if w == 0 {
+				key := [2]*edge{left, e}
+				if j, ok := open[key]; ok && (traps[j].y1 == y0) {
+					traps[j].y1 = y1
+				} else {
+					open[key] = len(traps)
+					traps = append(traps, trapezoid{left, e, y0, y1})
+				}
+			}
+		}
+	}
+	return traps
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tessellate_test
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/tessellate"
+	"golang.org/x/image/math/f32"
+)
+
+// meshArea returns the total area of m's triangles. It fails the test if any
+// triangle is degenerate or wound anti-clockwise.
+func meshArea(t *testing.T, desc string, m *tessellate.Mesh) float64 {
+	t.Helper()
+	if len(m.Indices)%3 != 0 {
+		t.Fatalf("%s: got %d indices, want a multiple of 3", desc, len(m.Indices))
+	}
+	total := 0.0
+	for i := 0; i < len(m.Indices); i += 3 {
+		a := m.Vertices[m.Indices[i+0]].Pos
+		b := m.Vertices[m.Indices[i+1]].Pos
+		c := m.Vertices[m.Indices[i+2]].Pos
+		cross := float64(b[0]-a[0])*float64(c[1]-a[1]) - float64(b[1]-a[1])*float64(c[0]-a[0])
+		if cross <= 0 {
+			t.Errorf("%s: triangle %d, %v %v %v: not clockwise", desc, i/3, a, b, c)
+		}
+		total += cross / 2
+	}
+	return total
+}
+
+func TestGraphic(t *testing.T) {
+	red := lowlevel.RGBAColor(color.RGBA{0xff, 0x00, 0x00, 0xff})
+	blue := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0xff, 0xff})
+	clear := lowlevel.RGBAColor(color.RGBA{})
+	square := func(b *ivg.Builder, x, y, size float32) *ivg.Builder {
+		return b.MoveTo(x, y).LineTo(x+size, y).LineTo(x+size, y+size).LineTo(x, y+size).ClosePath()
+	}
+	testCases := []struct {
+		desc  string
+		build func(b *ivg.Builder)
+		// wantAreas are the Meshes' areas.
+		wantAreas []float64
+	}{{
+		desc: "square",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10).Fill(red)
+		},
+		wantAreas: []float64{100},
+	}, {
+		desc: "anti-clockwise square",
+		build: func(b *ivg.Builder) {
+			b.MoveTo(0, 0).LineTo(0, 10).LineTo(10, 10).LineTo(10, 0).ClosePath().Fill(red)
+		},
+		wantAreas: []float64{100},
+	}, {
+		desc: "overlapping squares, one paint",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10)
+			square(b, 5, 5, 10).Fill(red)
+		},
+		wantAreas: []float64{175},
+	}, {
+		desc: "nested squares, even-odd",
+		build: func(b *ivg.Builder) {
+			b.SetFillRule(lowlevel.FillRuleEvenOdd)
+			square(b, 0, 0, 10)
+			square(b, 3, 3, 4).Fill(red)
+		},
+		wantAreas: []float64{84},
+	}, {
+		desc: "consecutive shapes share a Mesh",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10).Fill(red)
+			square(b, 20, 0, 10).Fill(red)
+		},
+		wantAreas: []float64{200},
+	}, {
+		desc: "different paints",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10).Fill(red)
+			square(b, 20, 0, 10).Fill(blue)
+			square(b, 20, 20, 10).Fill(red)
+		},
+		wantAreas: []float64{100, 100, 100},
+	}, {
+		desc: "different levels of detail",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10).Fill(red)
+			b.SetLOD(0, 32)
+			square(b, 20, 0, 10).Fill(red)
+		},
+		wantAreas: []float64{100, 100},
+	}, {
+		desc: "transparent and empty shapes",
+		build: func(b *ivg.Builder) {
+			square(b, 0, 0, 10).Fill(clear)
+			b.MoveTo(0, 0).LineTo(10, 0).ClosePath().Fill(red)
+		},
+		wantAreas: nil,
+	}}
+	for _, tc := range testCases {
+		b := ivg.NewBuilder()
+		tc.build(b)
+		meshes := tessellate.Graphic(b.Graphic(), 0)
+		if len(meshes) != len(tc.wantAreas) {
+			t.Errorf("%s: got %d meshes, want %d", tc.desc, len(meshes), len(tc.wantAreas))
+			continue
+		}
+		for i := range meshes {
+			m := &meshes[i]
+			if got, want := meshArea(t, tc.desc, m), tc.wantAreas[i]; math.Abs(got-want) > 1e-3 {
+				t.Errorf("%s: mesh %d: area: got %g, want %g", tc.desc, i, got, want)
+			}
+			if m.Gradient != nil {
+				t.Errorf("%s: mesh %d: got a Gradient, want nil", tc.desc, i)
+			}
+			for _, v := range m.Vertices {
+				if v.Color != m.Vertices[0].Color {
+					t.Errorf("%s: mesh %d: vertex colors differ", tc.desc, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestGraphicCircle(t *testing.T) {
+	// Flattening a circle of radius 10 within the tolerance loses at most
+	// 2π×10×tolerance of its area.
+	b := ivg.NewBuilder()
+	b.MoveTo(10, 0).
+		ArcTo(10, 10, 0, false, true, -10, 0).
+		ArcTo(10, 10, 0, false, true, 10, 0).
+		ClosePath().
+		Fill(lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff}))
+	for _, tolerance := range []float32{0, 0.01, 0.1} {
+		meshes := tessellate.Graphic(b.Graphic(), tolerance)
+		if len(meshes) != 1 {
+			t.Fatalf("tolerance=%g: got %d meshes, want 1", tolerance, len(meshes))
+		}
+		got, want := meshArea(t, "circle", &meshes[0]), 100*math.Pi
+		maxLoss := 2 * math.Pi * 10 * float64(tolerance)
+		if tolerance <= 0 {
+			maxLoss = 2 * math.Pi * 10 * 20 / 4096
+		}
+		if (got > want+1e-3) || (got < want-maxLoss) {
+			t.Errorf("tolerance=%g: area: got %g, want within %g of %g", tolerance, got, maxLoss, want)
+		}
+	}
+}
+
+func TestGraphicGradient(t *testing.T) {
+	black := lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	white := lowlevel.RGBAColor(color.RGBA{0xff, 0xff, 0xff, 0xff})
+	grad := &ivg.Gradient{
+		Shape:  ivg.GradientShapeLinear,
+		Spread: ivg.GradientSpreadPad,
+		// The gradient runs from x=0 to x=10.
+		Transform: f32.Aff3{0.1, 0, 0, 0, 1, 0},
+		Stops: []ivg.GradientStop{
+			{Offset: 0, Color: black},
+			{Offset: 1, Color: white},
+		},
+	}
+	b := ivg.NewBuilder()
+	b.MoveTo(0, 0).LineTo(10, 0).LineTo(10, 10).LineTo(0, 10).ClosePath()
+	b.FillPaint(ivg.Paint{Gradient: grad})
+	meshes := tessellate.Graphic(b.Graphic(), 0)
+	if len(meshes) != 1 {
+		t.Fatalf("got %d meshes, want 1", len(meshes))
+	}
+	m := &meshes[0]
+	if m.Gradient == nil {
+		t.Fatal("got a nil Gradient, want non-nil")
+	}
+	if got := m.Gradient.Stops; (len(got) != 2) ||
+		(got[0] != tessellate.Stop{Offset: 0, Color: color.RGBA{0x00, 0x00, 0x00, 0xff}}) ||
+		(got[1] != tessellate.Stop{Offset: 1, Color: color.RGBA{0xff, 0xff, 0xff, 0xff}}) {
+		t.Errorf("Stops: got %v", got)
+	}
+	for _, v := range m.Vertices {
+		if v.Color != (color.RGBA{}) {
+			t.Errorf("vertex %v: Color: got %v, want zero", v.Pos, v.Color)
+		}
+		if want := (f32.Vec2{v.Pos[0] / 10, v.Pos[1]}); v.GradientPos != want {
+			t.Errorf("vertex %v: GradientPos: got %v, want %v", v.Pos, v.GradientPos, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, filename := range []string{"action-info.lores.ivg", "cowbell.ivg", "gradient.ivg", "lod-polygon.ivg"} {
+		src, err := os.ReadFile("../../../test/data/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meshes, err := tessellate.Decode(src, 0)
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		if len(meshes) == 0 {
+			t.Errorf("%s: got no meshes", filename)
+		}
+		for i := range meshes {
+			m := &meshes[i]
+			for _, j := range m.Indices {
+				if int(j) >= len(m.Vertices) {
+					t.Errorf("%s: mesh %d: index %d out of range", filename, i, j)
+					break
+				}
+			}
+			if a := meshArea(t, filename, m); a <= 0 {
+				t.Errorf("%s: mesh %d: area: got %g, want positive", filename, i, a)
+			}
+		}
+	}
+
+	if _, err := tessellate.Decode([]byte("\x89IVH\x00"), 0); err == nil {
+		t.Errorf("bad magic identifier: got nil error, want non-nil")
+	}
+}
+
+func TestGraphicFocalGradient(t *testing.T) {
+	stops := []ivg.GradientStop{
+		{Offset: 0, Color: lowlevel.RGBAColor(color.RGBA{0x00, 0x00, 0x00, 0xff})},
+		{Offset: 1, Color: lowlevel.RGBAColor(color.RGBA{0xff, 0xff, 0xff, 0xff})},
+	}
+	b := ivg.NewBuilder()
+	b.MoveTo(0, 0).LineTo(10, 0).LineTo(10, 10).LineTo(0, 10).ClosePath()
+	b.FillPaint(ivg.FocalRadialGradient(stops, 5, 5, 5, 7.5, 5, ivg.GradientSpreadPad))
+	b.MoveTo(10, 0).LineTo(20, 0).LineTo(20, 10).LineTo(10, 10).ClosePath()
+	b.FillPaint(ivg.RadialGradient(stops, 5, 5, 5, ivg.GradientSpreadPad))
+	meshes := tessellate.Graphic(b.Graphic(), 0)
+	if len(meshes) != 2 {
+		t.Fatalf("got %d meshes, want 2", len(meshes))
+	}
+	if got, want := meshes[0].Gradient.Focal, (f32.Vec2{0.5, 0}); got != want {
+		t.Errorf("mesh 0: Focal: got %v, want %v", got, want)
+	}
+	if got, want := meshes[1].Gradient.Focal, (f32.Vec2{}); got != want {
+		t.Errorf("mesh 1: Focal: got %v, want %v", got, want)
+	}
+}
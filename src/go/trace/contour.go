@@ -0,0 +1,121 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+// Directions, in order of turning clockwise, for an y axis that increases
+// downwards.
+const (
+	east  = 0
+	south = 1
+	west  = 2
+	north = 3
+)
+
+var deltas = [4][2]int{east: {+1, 0}, south: {0, +1}, west: {-1, 0}, north: {0, -1}}
+
+// loop is a closed polygon along pixel edges, as the pixel corners where it
+// changes direction. The pixels that it encloses are on its right, so that
+// outer boundaries are clockwise, holes are counter-clockwise, and a loop's
+// pixels are filled under the non-zero fill rule.
+type loop []vec
+
+// area returns the loop's signed area, in pixels: positive for outer
+// boundaries and negative for holes.
+func (l loop) area() float64 {
+	a := 0.0
+	for i, p := range l {
+		q := l[(i+1)%len(l)]
+		a += p[0]*q[1] - q[0]*p[1]
+	}
+	return a / 2
+}
+
+// traceLoops returns the boundaries of the pixels in mask, a w×h image in
+// row-major order.
+//
+// Where two inside pixels touch only diagonally, the boundaries turn right, to
+// keep the inside pixels separate. Either way would be valid, and this way
+// does not connect unrelated shapes, such as the diagonal steps of adjacent
+// lines.
+func traceLoops(mask []bool, w, h int) []loop {
+	inside := func(x, y int) bool {
+		return (0 <= x) && (x < w) && (0 <= y) && (y < h) && mask[y*w+x]
+	}
+
+	// edges[v] is the set, as a bitmask, of the directions of the boundary
+	// edges that start at the pixel corner v.
+	stride := w + 1
+	edges := make([]uint8, stride*(h+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !mask[y*w+x] {
+				continue
+			}
+			if !inside(x, y-1) {
+				edges[y*stride+x] |= 1 << east
+			}
+			if !inside(x+1, y) {
+				edges[y*stride+x+1] |= 1 << south
+			}
+			if !inside(x, y+1) {
+				edges[(y+1)*stride+x+1] |= 1 << west
+			}
+			if !inside(x-1, y) {
+				edges[(y+1)*stride+x] |= 1 << north
+			}
+		}
+	}
+
+	used := make([]uint8, len(edges))
+	loops := []loop(nil)
+	for v0, e := range edges {
+		for d0 := 0; d0 < 4; d0++ {
+			if (e&(1<<d0) == 0) || (used[v0]&(1<<d0) != 0) {
+				continue
+			}
+			var l loop
+			v, d, prev := v0, d0, -1
+			for {
+				used[v] |= 1 << d
+				if d != prev {
+					l = append(l, vec{float64(v % stride), float64(v / stride)})
+				}
+				v += deltas[d][1]*stride + deltas[d][0]
+				prev, d = d, nextDirection(edges[v], d)
+				if (v == v0) && (d == d0) {
+					break
+				}
+			}
+			// The start vertex is only a corner if the loop turns there.
+			if prev == d0 {
+				l = l[1:]
+			}
+			loops = append(loops, l)
+		}
+	}
+	return loops
+}
+
+// nextDirection returns the direction of the boundary edge that continues one
+// arriving in direction d, out of the edges that leave that vertex: turning
+// right, going straight or turning left, in order of preference.
+func nextDirection(edges uint8, d int) int {
+	for _, turn := range [3]int{1, 0, 3} {
+		if d1 := (d + turn) & 3; edges&(1<<d1) != 0 {
+			return d1
+		}
+	}
+	return d
+}
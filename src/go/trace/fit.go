@@ -0,0 +1,302 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"math"
+)
+
+// vec is a point or vector, in pixel coordinates.
+type vec [2]float64
+
+func (p vec) add(q vec) vec             { return vec{p[0] + q[0], p[1] + q[1]} }
+func (p vec) sub(q vec) vec             { return vec{p[0] - q[0], p[1] - q[1]} }
+func (p vec) scale(k float64) vec       { return vec{p[0] * k, p[1] * k} }
+func (p vec) dot(q vec) float64         { return p[0]*q[0] + p[1]*q[1] }
+func (p vec) length() float64           { return math.Hypot(p[0], p[1]) }
+func (p vec) lerp(q vec, t float64) vec { return p.add(q.sub(p).scale(t)) }
+
+// unit returns p scaled to unit length, or fallback if p is zero.
+func (p vec) unit(fallback vec) vec {
+	if n := p.length(); n > 0 {
+		return p.scale(1 / n)
+	}
+	return fallback
+}
+
+// segment is a straight line, if line is true, or a cubic Bézier curve, from
+// p0 to p3.
+type segment struct {
+	line           bool
+	p0, p1, p2, p3 vec
+}
+
+func (s *segment) at(t float64) vec {
+	if s.line {
+		return s.p0.lerp(s.p3, t)
+	}
+	u := 1 - t
+	return s.p0.scale(u * u * u).
+		add(s.p1.scale(3 * u * u * t)).
+		add(s.p2.scale(3 * u * t * t)).
+		add(s.p3.scale(t * t * t))
+}
+
+// point is a point on a loop's smoothed outline.
+type point struct {
+	p      vec
+	corner bool
+}
+
+// fitLoop returns the closed path, of lines and cubic Bézier curves, that
+// approximates the loop l to within tol.
+//
+// The loop's staircases are smoothed by replacing each straight run along
+// pixel edges by its midpoint. A corner between two runs is kept, as a sharp
+// corner, if both runs are at least two pixels long, unless it is part of a
+// staircase of two-pixel steps. Points where the smoothed outline turns
+// sharply are also corners. The outline between corners is fit with as few
+// segments as possible.
+func fitLoop(l loop, tol float64) []segment {
+	n := len(l)
+	run := func(i int) (vec, float64) {
+		d := l[(i+1)%n].sub(l[i%n])
+		return d, d.length()
+	}
+	turn := func(i int) float64 {
+		d0, _ := run(i + n - 1)
+		d1, _ := run(i)
+		return d0[0]*d1[1] - d0[1]*d1[0]
+	}
+
+	pts := make([]point, 0, 2*n)
+	for i := 0; i < n; i++ {
+		_, a := run(i + n - 1)
+		_, b := run(i)
+		if (a >= 2) && (b >= 2) {
+			t := turn(i)
+			staircase := (a == 2) && (b == 2) && (turn(i+n-1) == -t) && (turn(i+1) == -t)
+			if !staircase {
+				pts = append(pts, point{p: l[i], corner: true})
+			}
+		}
+		pts = append(pts, point{p: l[i].lerp(l[(i+1)%n], 0.5)})
+	}
+	m := len(pts)
+	at := func(i int) vec { return pts[((i%m)+m)%m].p }
+
+	// Find the sharp turns of the smoothed outline, which are local minima of
+	// the cosine of the angle turned through, over a distance of two points.
+	if m >= 5 {
+		cos := make([]float64, m)
+		for i := range pts {
+			if pts[i].corner {
+				cos[i] = -2
+				continue
+			}
+			u := at(i).sub(at(i - 2)).unit(vec{})
+			v := at(i + 2).sub(at(i)).unit(vec{})
+			cos[i] = u.dot(v)
+		}
+		for i := range pts {
+			c := cos[i]
+			if (c < 0.5) && (c < cos[(i+m-1)%m]) && (c < cos[(i+1)%m]) {
+				pts[i].corner = true
+			}
+		}
+	}
+
+	corners := []int(nil)
+	for i, p := range pts {
+		if p.corner {
+			corners = append(corners, i)
+		}
+	}
+	smoothStart := len(corners) == 0
+	if smoothStart {
+		corners = []int{0, m / 2}
+	}
+
+	segs := []segment(nil)
+	for k, c0 := range corners {
+		c1 := corners[0] + m
+		if k+1 < len(corners) {
+			c1 = corners[k+1]
+		}
+		span := make([]vec, 0, c1-c0+1)
+		for i := c0; i <= c1; i++ {
+			span = append(span, at(i))
+		}
+		chord := span[len(span)-1].sub(span[0])
+		d0 := span[1].sub(span[0]).unit(chord)
+		d1 := span[len(span)-1].sub(span[len(span)-2]).unit(chord)
+		if smoothStart {
+			d0 = at(c0 + 2).sub(at(c0 - 2)).unit(d0)
+			d1 = at(c1 + 2).sub(at(c1 - 2)).unit(d1)
+		}
+		segs = fitSpan(segs, span, d0.unit(vec{1, 0}), d1.unit(vec{1, 0}), tol)
+	}
+	return segs
+}
+
+// maxIterations is the maximum number of times that fitSpan refines its
+// curve's parameters before splitting the span.
+const maxIterations = 4
+
+// fitSpan appends to segs the segments that approximate the points pts to
+// within tol, with unit tangent vectors d0 and d1 at the start and end. It is
+// Philip J. Schneider's algorithm, from "An Algorithm for Automatically
+// Fitting Digitized Curves" in Graphics Gems (1990).
+func fitSpan(segs []segment, pts []vec, d0, d1 vec, tol float64) []segment {
+	n := len(pts)
+	p0, p3 := pts[0], pts[n-1]
+	line := segment{line: true, p0: p0, p3: p3}
+	if n == 2 {
+		return append(segs, line)
+	}
+	if e, _ := fitError(&line, pts, chordLengths(pts)); e <= tol {
+		return append(segs, line)
+	}
+
+	u := chordLengths(pts)
+	s := bezier(pts, u, d0, d1)
+	e, split := fitError(&s, pts, u)
+	for i := 0; (e > tol) && (e < 4*tol) && (i < maxIterations); i++ {
+		u = reparameterize(&s, pts, u)
+		s = bezier(pts, u, d0, d1)
+		e, split = fitError(&s, pts, u)
+	}
+	if e <= tol {
+		return append(segs, s)
+	}
+
+	if split < 1 {
+		split = 1
+	} else if split > n-2 {
+		split = n - 2
+	}
+	k := 2
+	if k > split {
+		k = split
+	}
+	if k > n-1-split {
+		k = n - 1 - split
+	}
+	dc := pts[split+k].sub(pts[split-k]).unit(p3.sub(p0).unit(d0))
+	segs = fitSpan(segs, pts[:split+1], d0, dc, tol)
+	return fitSpan(segs, pts[split:], dc, d1, tol)
+}
+
+// chordLengths returns the parameter of each point, proportional to the
+// distance along the polyline pts, from 0 to 1.
+func chordLengths(pts []vec) []float64 {
+	u := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		u[i] = u[i-1] + pts[i].sub(pts[i-1]).length()
+	}
+	if total := u[len(u)-1]; total > 0 {
+		for i := range u {
+			u[i] /= total
+		}
+	}
+	return u
+}
+
+// bezier returns the cubic Bézier curve, from the first to the last of pts,
+// with tangents d0 and d1 at its ends, that best fits pts, in the least
+// squares sense, at the parameters u.
+func bezier(pts []vec, u []float64, d0, d1 vec) segment {
+	n := len(pts)
+	p0, p3 := pts[0], pts[n-1]
+	var c00, c01, c11, x0, x1 float64
+	for i, p := range pts {
+		t := u[i]
+		s := 1 - t
+		b0, b1, b2, b3 := s*s*s, 3*s*s*t, 3*s*t*t, t*t*t
+		a0, a1 := d0.scale(b1), d1.scale(-b2)
+		c00 += a0.dot(a0)
+		c01 += a0.dot(a1)
+		c11 += a1.dot(a1)
+		r := p.sub(p0.scale(b0 + b1)).sub(p3.scale(b2 + b3))
+		x0 += a0.dot(r)
+		x1 += a1.dot(r)
+	}
+
+	// If the system is singular, or the solution does not have the control
+	// points in the tangents' directions, fall back to the heuristic of one
+	// third of the chord length.
+	dist := p3.sub(p0).length()
+	alpha0, alpha1 := dist/3, dist/3
+	if det := c00*c11 - c01*c01; det != 0 {
+		a0 := (x0*c11 - x1*c01) / det
+		a1 := (c00*x1 - c01*x0) / det
+		if eps := 1e-6 * dist; (a0 > eps) && (a1 > eps) {
+			alpha0, alpha1 = a0, a1
+		}
+	}
+	return segment{
+		p0: p0,
+		p1: p0.add(d0.scale(alpha0)),
+		p2: p3.sub(d1.scale(alpha1)),
+		p3: p3,
+	}
+}
+
+// fitError returns the maximum distance between s and pts, and the index of
+// the point where that is. As well as the distance from each point to s, at
+// its parameter, it measures the distance between the midpoints of s and of
+// each line between consecutive points, so that s cannot stray far between
+// them.
+func fitError(s *segment, pts []vec, u []float64) (float64, int) {
+	maxErr, index := 0.0, len(pts)/2
+	for i, p := range pts {
+		if e := s.at(u[i]).sub(p).length(); e > maxErr {
+			maxErr, index = e, i
+		}
+		if i == 0 {
+			continue
+		}
+		mid := pts[i-1].lerp(p, 0.5)
+		if e := s.at((u[i-1] + u[i]) / 2).sub(mid).length(); e > maxErr {
+			maxErr, index = e, i
+		}
+	}
+	return maxErr, index
+}
+
+// reparameterize returns better parameters for pts on s, taking one step of
+// Newton's method, for each point, towards the parameter of the nearest
+// point on s.
+func reparameterize(s *segment, pts []vec, u []float64) []float64 {
+	v := make([]float64, len(u))
+	for i, p := range pts {
+		t := u[i]
+		w := 1 - t
+		q := s.at(t)
+		// First and second derivatives of s at t.
+		q1 := s.p1.sub(s.p0).scale(3 * w * w).
+			add(s.p2.sub(s.p1).scale(6 * w * t)).
+			add(s.p3.sub(s.p2).scale(3 * t * t))
+		q2 := s.p2.sub(s.p1.scale(2)).add(s.p0).scale(6 * w).
+			add(s.p3.sub(s.p2.scale(2)).add(s.p1).scale(6 * t))
+		d := q.sub(p)
+		num, den := d.dot(q1), q1.dot(q1)+d.dot(q2)
+		v[i] = t
+		if den != 0 {
+			v[i] = math.Max(0, math.Min(1, t-num/den))
+		}
+	}
+	return v
+}
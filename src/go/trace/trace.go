@@ -0,0 +1,349 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace vectorizes raster icons, such as legacy PNG icon sets, into
+// IconVG graphics. It is experimental: its output, for a given image and
+// Options, may change in future versions.
+//
+// It suits flat-color images, with few distinct colors and anti-aliased
+// edges. Tracing a photograph, or an image with gradients, gives many small,
+// banded shapes. The steps are:
+//
+//   - quantize the image's colors to a small palette, the most frequent
+//     colors that are sufficiently distinct from each other. Anti-aliased
+//     edge pixels take the nearest palette color.
+//   - trace the boundaries between each color's pixels and the others, as
+//     closed polygons along pixel edges.
+//   - smooth the polygons' staircases, keeping sharp corners, and fit them
+//     with straight lines and cubic Bézier curves, to within a tolerance.
+//
+// Opaque colors are stacked, largest area first: each color's shape also
+// covers the pixels of the opaque colors above it, so that adjacent colors do
+// not leave hairline seams between them when rendered.
+package trace
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+)
+
+const (
+	// DefaultColors is the default value of Options.Colors.
+	DefaultColors = 16
+
+	// DefaultTolerance is the default value of Options.Tolerance.
+	DefaultTolerance = 0.5
+)
+
+// minColorDistance is the minimum Euclidean distance, in 8-bit
+// alpha-premultiplied RGBA space, between two palette colors. Colors nearer
+// to a more frequent palette color are assumed to be anti-aliasing.
+const minColorDistance = 48
+
+// Options are the optional parameters to Encode.
+type Options struct {
+	// ViewBox is the IconVG graphic's viewBox. The zero value means
+	// lowlevel.DefaultViewBox. The image is fit to the ViewBox, preserving
+	// its aspect ratio, and centered.
+	ViewBox lowlevel.Rectangle
+
+	// Colors is the maximum number of colors, including transparent, that
+	// the image is quantized to. Zero means DefaultColors.
+	Colors int
+
+	// Tolerance is the quality versus size trade-off: the maximum distance,
+	// in pixels, between the traced outlines and the fitted paths. Larger
+	// values give fewer path segments, and smaller files, that are less
+	// faithful to the image. Zero means DefaultTolerance.
+	Tolerance float64
+
+	// MinArea, if positive, drops any traced outline (or hole in one) that
+	// encloses fewer than MinArea pixels, which removes specks of noise.
+	MinArea float64
+}
+
+// Encode traces the image m as an IconVG graphic.
+//
+// opts may be nil, which means to use the default options.
+func Encode(m image.Image, opts *Options) ([]byte, error) {
+	return ivg.Encode(Graphic(m, opts))
+}
+
+// Graphic is like Encode but returns the unencoded graphic, for further
+// editing.
+func Graphic(m image.Image, opts *Options) *ivg.Graphic {
+	if opts == nil {
+		opts = &Options{}
+	}
+	vb := opts.ViewBox
+	if vb == (lowlevel.Rectangle{}) {
+		vb = lowlevel.DefaultViewBox
+	}
+	b := ivg.NewBuilder()
+	b.SetViewBox(vb.Min[0], vb.Min[1], vb.Max[0], vb.Max[1])
+
+	bounds := m.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return b.Graphic()
+	}
+	nColors := opts.Colors
+	if nColors <= 0 {
+		nColors = DefaultColors
+	}
+	tol := opts.Tolerance
+	if !(tol > 0) {
+		tol = DefaultTolerance
+	}
+
+	// Fit the image to the viewBox.
+	vw, vh := float64(vb.Max[0]-vb.Min[0]), float64(vb.Max[1]-vb.Min[1])
+	scale := vw / float64(w)
+	if s := vh / float64(h); scale > s {
+		scale = s
+	}
+	xf := transform{
+		scale: scale,
+		dx:    float64(vb.Min[0]) + (vw-float64(w)*scale)/2,
+		dy:    float64(vb.Min[1]) + (vh-float64(h)*scale)/2,
+	}
+
+	palette, classes := quantize(m, nColors)
+	for _, l := range layers(palette, classes) {
+		mask := make([]bool, w*h)
+		for i, c := range classes {
+			mask[i] = l.classes[c]
+		}
+		n := 0
+		for _, loop := range traceLoops(mask, w, h) {
+			if opts.MinArea > 0 && abs(loop.area()) < opts.MinArea {
+				continue
+			}
+			appendPath(b, fitLoop(loop, tol), xf)
+			n++
+		}
+		if n > 0 {
+			b.Fill(lowlevel.RGBAColor(l.color))
+		}
+	}
+	return b.Graphic()
+}
+
+// transform maps from pixel coordinates to viewBox coordinates.
+type transform struct {
+	scale  float64
+	dx, dy float64
+}
+
+func (t transform) apply(p vec) (float32, float32) {
+	return float32(p[0]*t.scale + t.dx), float32(p[1]*t.scale + t.dy)
+}
+
+// quantize returns m's palette, as alpha-premultiplied colors, and the index
+// into that palette of each pixel's nearest color, in row-major order.
+func quantize(m image.Image, nColors int) ([]color.RGBA, []int) {
+	bounds := m.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([]color.RGBA, 0, w*h)
+	counts := map[color.RGBA]int{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+			pixels = append(pixels, c)
+			counts[c]++
+		}
+	}
+
+	// The palette is the most frequent colors, ignoring those near to a more
+	// frequent one or to a blend of two more frequent ones, which are
+	// anti-aliasing. Ties are broken by the color values, so that the result
+	// does not depend on map iteration order.
+	type entry struct {
+		c color.RGBA
+		n int
+	}
+	entries := make([]entry, 0, len(counts))
+	for c, n := range counts {
+		entries = append(entries, entry{c, n})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if ei, ej := entries[i], entries[j]; ei.n != ej.n {
+			return ei.n > ej.n
+		} else {
+			return rgbaKey(ei.c) < rgbaKey(ej.c)
+		}
+	})
+	palette := []color.RGBA(nil)
+	for _, e := range entries {
+		if len(palette) == nColors {
+			break
+		}
+		if _, d := nearest(palette, e.c); d < minColorDistance*minColorDistance {
+			continue
+		} else if isBlend(palette, e.c) {
+			continue
+		}
+		palette = append(palette, e.c)
+	}
+
+	memo := map[color.RGBA]int{}
+	classes := make([]int, len(pixels))
+	for i, c := range pixels {
+		j, ok := memo[c]
+		if !ok {
+			j, _ = nearest(palette, c)
+			memo[c] = j
+		}
+		classes[i] = j
+	}
+	return palette, classes
+}
+
+// nearest returns the index of the palette color nearest to c, and the
+// squared distance between them. The distance is "infinite" if the palette is
+// empty.
+func nearest(palette []color.RGBA, c color.RGBA) (int, int) {
+	best, bestD := -1, int(^uint(0)>>1)
+	for i, p := range palette {
+		dr := int(p.R) - int(c.R)
+		dg := int(p.G) - int(c.G)
+		db := int(p.B) - int(c.B)
+		da := int(p.A) - int(c.A)
+		if d := dr*dr + dg*dg + db*db + da*da; d < bestD {
+			best, bestD = i, d
+		}
+	}
+	return best, bestD
+}
+
+// maxBlendDistance is the maximum Euclidean distance, in 8-bit
+// alpha-premultiplied RGBA space, between a blend of two colors and a color
+// that is taken to be that blend, allowing for rounding and for shallow
+// gradients.
+const maxBlendDistance = 16
+
+// isBlend returns whether c is near to a blend of two palette colors. In
+// alpha-premultiplied space, anti-aliasing's blends lie on the line segment
+// between the two colors.
+func isBlend(palette []color.RGBA, c color.RGBA) bool {
+	v := [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	for i, p := range palette {
+		a := [4]float64{float64(p.R), float64(p.G), float64(p.B), float64(p.A)}
+		for _, q := range palette[i+1:] {
+			b := [4]float64{float64(q.R), float64(q.G), float64(q.B), float64(q.A)}
+			ab, av, abab := 0.0, 0.0, 0.0
+			for k := range a {
+				ab += (b[k] - a[k]) * (v[k] - a[k])
+				abab += (b[k] - a[k]) * (b[k] - a[k])
+			}
+			t := ab / abab
+			if !(t > 0 && t < 1) {
+				continue
+			}
+			for k := range a {
+				d := v[k] - (a[k] + t*(b[k]-a[k]))
+				av += d * d
+			}
+			if av < maxBlendDistance*maxBlendDistance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func rgbaKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
+
+// layer is a shape to fill: the pixels of the palette colors in classes.
+type layer struct {
+	color   color.RGBA
+	classes []bool
+}
+
+// layers returns the shapes to fill, in painting order. Transparent colors are
+// not painted. Each opaque color's layer also includes the opaque colors
+// painted after it, which are painted in order of decreasing area.
+// Semi-transparent colors are painted last, each on its own, as painting them
+// over other colors would change their appearance.
+func layers(palette []color.RGBA, classes []int) []layer {
+	area := make([]int, len(palette))
+	for _, c := range classes {
+		area[c]++
+	}
+	opaque, translucent := []int(nil), []int(nil)
+	for i, c := range palette {
+		switch c.A {
+		case 0x00:
+		case 0xff:
+			opaque = append(opaque, i)
+		default:
+			translucent = append(translucent, i)
+		}
+	}
+	sort.SliceStable(opaque, func(i, j int) bool {
+		return area[opaque[i]] > area[opaque[j]]
+	})
+
+	ls := []layer(nil)
+	for k, i := range opaque {
+		l := layer{color: palette[i], classes: make([]bool, len(palette))}
+		for _, j := range opaque[k:] {
+			l.classes[j] = true
+		}
+		ls = append(ls, l)
+	}
+	for _, i := range translucent {
+		l := layer{color: palette[i], classes: make([]bool, len(palette))}
+		l.classes[i] = true
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+// appendPath adds the closed path segs, in pixel coordinates, to b's current
+// path. A final straight line, back to the start, is implied by closing the
+// path.
+func appendPath(b *ivg.Builder, segs []segment, xf transform) {
+	if len(segs) == 0 {
+		return
+	}
+	if segs[len(segs)-1].line {
+		segs = segs[:len(segs)-1]
+	}
+	b.MoveTo(xf.apply(segs[0].p0))
+	for _, s := range segs {
+		if s.line {
+			b.LineTo(xf.apply(s.p3))
+			continue
+		}
+		x1, y1 := xf.apply(s.p1)
+		x2, y2 := xf.apply(s.p2)
+		x3, y3 := xf.apply(s.p3)
+		b.CubeTo(x1, y1, x2, y2, x3, y3)
+	}
+	b.ClosePath()
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,266 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"github.com/google/iconvg/src/go/trace"
+)
+
+var (
+	red   = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	blue  = color.RGBA{0x00, 0x00, 0xff, 0xff}
+	green = color.RGBA{0x00, 0x80, 0x00, 0xff}
+)
+
+// newImage returns a 64x64 transparent image with the given rectangles
+// filled.
+func newImage(rects ...interface{}) *image.RGBA {
+	m := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := 0; i+1 < len(rects); i += 2 {
+		draw.Draw(m, rects[i].(image.Rectangle), image.NewUniform(rects[i+1].(color.RGBA)), image.Point{}, draw.Src)
+	}
+	return m
+}
+
+// disc returns a 64x64 image of an anti-aliased disc, of radius r, centered
+// in the image, over a transparent background.
+func disc(r float64, c color.RGBA) *image.RGBA {
+	m := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			// Supersample 4x4 points per pixel.
+			n := 0
+			for j := 0; j < 4; j++ {
+				for i := 0; i < 4; i++ {
+					dx := float64(x) + (float64(i)+0.5)/4 - 32
+					dy := float64(y) + (float64(j)+0.5)/4 - 32
+					if dx*dx+dy*dy < r*r {
+						n++
+					}
+				}
+			}
+			m.SetRGBA(x, y, color.RGBA{
+				uint8(int(c.R) * n / 16), uint8(int(c.G) * n / 16),
+				uint8(int(c.B) * n / 16), uint8(int(c.A) * n / 16),
+			})
+		}
+	}
+	return m
+}
+
+// mismatches returns how many of the 64x64 pixels of the rendered src differ
+// from m's by more than 0x40 in any channel.
+func mismatches(t *testing.T, src []byte, m *image.RGBA) int {
+	t.Helper()
+	got, err := render.Image(src, 64, nil)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	n := 0
+	for i := 0; i < len(m.Pix); i += 4 {
+		for k := 0; k < 4; k++ {
+			if d := int(got.Pix[i+k]) - int(m.Pix[i+k]); (d < -0x40) || (0x40 < d) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		m              *image.RGBA
+		wantShapes     []color.RGBA
+		wantMismatches int
+	}{
+		{"blank", newImage(), nil, 0},
+		{"square", newImage(image.Rect(16, 16, 48, 48), red), []color.RGBA{red}, 0},
+		{"full", newImage(image.Rect(0, 0, 64, 64), blue), []color.RGBA{blue}, 0},
+		{
+			// The larger blue area is painted first, under the red.
+			"adjacent",
+			newImage(image.Rect(8, 8, 24, 56), red, image.Rect(24, 8, 56, 56), blue),
+			[]color.RGBA{blue, red},
+			0,
+		},
+		{
+			// The hole in the ring is transparent.
+			"ring",
+			newImage(image.Rect(8, 8, 56, 56), green, image.Rect(24, 24, 40, 40), color.RGBA{}),
+			[]color.RGBA{green},
+			0,
+		},
+		{"translucent", newImage(image.Rect(16, 16, 48, 48), color.RGBA{0x40, 0x00, 0x00, 0x80}),
+			[]color.RGBA{{0x40, 0x00, 0x00, 0x80}}, 0},
+		// Only some of the disc's anti-aliased rim, about 125 pixels long,
+		// is quantized away.
+		{"disc", disc(20, red), []color.RGBA{red}, 48},
+	}
+	for _, tc := range testCases {
+		g := trace.Graphic(tc.m, nil)
+		if g.Metadata.ViewBox != lowlevel.DefaultViewBox {
+			t.Errorf("%s: ViewBox: got %v, want %v", tc.desc, g.Metadata.ViewBox, lowlevel.DefaultViewBox)
+		}
+		var got []color.RGBA
+		for _, s := range g.Shapes {
+			c, _ := s.Paint.Color.RGBA()
+			got = append(got, c)
+		}
+		if len(got) != len(tc.wantShapes) {
+			t.Errorf("%s: got %d shapes %v, want %d %v", tc.desc, len(got), got, len(tc.wantShapes), tc.wantShapes)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.wantShapes[i] {
+				t.Errorf("%s: shape %d: got %v, want %v", tc.desc, i, got[i], tc.wantShapes[i])
+			}
+		}
+
+		src, err := trace.Encode(tc.m, nil)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tc.desc, err)
+		}
+		if n := mismatches(t, src, tc.m); n > tc.wantMismatches {
+			t.Errorf("%s: got %d mismatched pixels, want at most %d", tc.desc, n, tc.wantMismatches)
+		}
+	}
+}
+
+func TestColors(t *testing.T) {
+	m := newImage(
+		image.Rect(0, 0, 32, 64), red,
+		image.Rect(32, 0, 64, 40), blue,
+		image.Rect(32, 40, 64, 64), green,
+	)
+	testCases := []struct {
+		colors int
+		want   int
+	}{
+		{0, 3},
+		{4, 3},
+		{3, 3},
+		{2, 2},
+		{1, 1},
+	}
+	for _, tc := range testCases {
+		g := trace.Graphic(m, &trace.Options{Colors: tc.colors})
+		if got := len(g.Shapes); got != tc.want {
+			t.Errorf("Colors %d: got %d shapes, want %d", tc.colors, got, tc.want)
+		}
+	}
+}
+
+func TestMinArea(t *testing.T) {
+	// A 2x2 speck beside a 32x32 square.
+	m := newImage(image.Rect(16, 16, 48, 48), red, image.Rect(2, 2, 4, 4), red)
+	testCases := []struct {
+		minArea   float64
+		wantLoops int
+	}{
+		{0, 2},
+		{4, 2},
+		{5, 1},
+		{1024, 1},
+		{1025, 0},
+	}
+	for _, tc := range testCases {
+		g := trace.Graphic(m, &trace.Options{MinArea: tc.minArea})
+		loops := 0
+		for _, s := range g.Shapes {
+			for _, seg := range s.Path {
+				if _, ok := seg.(ivg.MoveTo); ok {
+					loops++
+				}
+			}
+		}
+		if loops != tc.wantLoops {
+			t.Errorf("MinArea %g: got %d loops, want %d", tc.minArea, loops, tc.wantLoops)
+		}
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	m := disc(24, blue)
+	prev := math.MaxInt32
+	for _, tol := range []float64{0.25, 0.5, 1, 2, 4} {
+		g := trace.Graphic(m, &trace.Options{Tolerance: tol})
+		if len(g.Shapes) != 1 {
+			t.Fatalf("Tolerance %g: got %d shapes, want 1", tol, len(g.Shapes))
+		}
+		n := len(g.Shapes[0].Path)
+		if n > prev {
+			t.Errorf("Tolerance %g: got %d segments, want at most %d", tol, n, prev)
+		}
+		prev = n
+	}
+}
+
+func TestViewBox(t *testing.T) {
+	// A 64x32 image, fit to a square viewBox, is centered vertically.
+	m := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	draw.Draw(m, m.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	vb := lowlevel.Rectangle{Min: [2]float32{0, 0}, Max: [2]float32{128, 128}}
+	g := trace.Graphic(m, &trace.Options{ViewBox: vb})
+	if g.Metadata.ViewBox != vb {
+		t.Errorf("ViewBox: got %v, want %v", g.Metadata.ViewBox, vb)
+	}
+	if len(g.Shapes) != 1 {
+		t.Fatalf("got %d shapes, want 1", len(g.Shapes))
+	}
+	min := [2]float32{float32(math.Inf(+1)), float32(math.Inf(+1))}
+	max := [2]float32{float32(math.Inf(-1)), float32(math.Inf(-1))}
+	for _, seg := range g.Shapes[0].Path {
+		var p [2]float32
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			p = seg.To
+		case ivg.LineTo:
+			p = seg.To
+		default:
+			continue
+		}
+		for i := range p {
+			min[i] = float32(math.Min(float64(min[i]), float64(p[i])))
+			max[i] = float32(math.Max(float64(max[i]), float64(p[i])))
+		}
+	}
+	if want := [2]float32{0, 32}; min != want {
+		t.Errorf("min: got %v, want %v", min, want)
+	}
+	if want := [2]float32{128, 96}; max != want {
+		t.Errorf("max: got %v, want %v", max, want)
+	}
+}
+
+func TestEmptyImage(t *testing.T) {
+	g := trace.Graphic(image.NewRGBA(image.Rectangle{}), nil)
+	if len(g.Shapes) != 0 {
+		t.Errorf("got %d shapes, want 0", len(g.Shapes))
+	}
+	if _, err := trace.Encode(image.NewRGBA(image.Rectangle{}), nil); err != nil {
+		t.Errorf("Encode: %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js && wasm
+
+// Package wasm exposes the IconVG decoder and rasterizer to JavaScript, in
+// programs built with GOOS=js and GOARCH=wasm, such as the ivgwasm command.
+//
+// Browsers do not support IconVG natively, in <img> elements, so web pages
+// can use this package for progressive enhancement: if the WebAssembly module
+// loads, draw .ivg files onto <canvas> elements, and if not, fall back to PNG
+// or SVG versions.
+//
+// Register defines two global JavaScript functions:
+//
+//	decodeToCanvasPath2D(src, options)
+//	renderToImageData(src, size, options)
+//
+// src is a Uint8Array or ArrayBuffer holding an IconVG graphic. options may be
+// omitted. Its palette property, if present, is an array of CSS colors that
+// override the graphic's suggested palette, by index. Null or undefined
+// elements keep the suggested color.
+//
+// decodeToCanvasPath2D returns an object whose viewBox property is [minX,
+// minY, maxX, maxY] and whose shapes property is an array, in painter's order,
+// of objects with these properties:
+//
+//   - path is a Path2D, in graphic coordinate space.
+//   - lod0 and lod1 are the level of detail bounds, as for ivg.Shape.
+//   - fill, for a flat colored shape, is a CSS color.
+//   - gradient, for a gradient filled shape, is an object with shape
+//     ("linear" or "radial"), spread ("none", "pad", "reflect" or "repeat"),
+//     matrix and stops properties. matrix holds the arguments to a
+//     CanvasRenderingContext2D's transform method that map from gradient
+//     coordinate space, where a linear gradient runs from (0, 0) to (1, 0)
+//     and a radial gradient is the unit circle, to graphic coordinate space.
+//     stops is an array of objects with offset and color properties.
+//
+// Canvas gradients always pad, so drawing reflected or repeated gradients
+// exactly needs renderToImageData, which rasterizes the graphic to a new
+// size×size ImageData, like render.Image. Its options may also have a linear
+// property, like render.Options.LinearInterpolation.
+//
+// Neither function throws. On invalid input, they return an Error object.
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/google/iconvg/src/go/ivg"
+	"github.com/google/iconvg/src/go/lowlevel"
+	"github.com/google/iconvg/src/go/render"
+	"golang.org/x/image/math/f32"
+)
+
+var (
+	errInvalidPalette = errors.New("wasm: invalid palette")
+	errInvalidSize    = errors.New("wasm: invalid size")
+	errInvalidSource  = errors.New("wasm: source is not a Uint8Array or ArrayBuffer")
+)
+
+// Register defines the decodeToCanvasPath2D and renderToImageData global
+// JavaScript functions. The program should then block, such as with an empty
+// select statement, for as long as JavaScript may call them.
+func Register() {
+	js.Global().Set("decodeToCanvasPath2D", funcOf(decodeToCanvasPath2D))
+	js.Global().Set("renderToImageData", funcOf(renderToImageData))
+}
+
+// funcOf wraps f as a JavaScript function that returns an Error object instead
+// of a Go error.
+func funcOf(f func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		v, err := f(args)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return v
+	})
+}
+
+func decodeToCanvasPath2D(args []js.Value) (interface{}, error) {
+	src, err := source(arg(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := paletteOption(arg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	pal, err := lowlevel.LoadPartialPalette(src, overrides)
+	if err != nil {
+		return nil, err
+	}
+	// The decoded paints are resolved against pal, so that overriding the
+	// suggested palette changes them.
+	g, err := ivg.Decode(src, &lowlevel.DecodeOptions{Palette: &pal})
+	if err != nil {
+		return nil, err
+	}
+
+	path2D := js.Global().Get("Path2D")
+	shapes := []interface{}{}
+	for i := range g.Shapes {
+		s := &g.Shapes[i]
+		if len(s.Path) == 0 {
+			continue
+		}
+		o := map[string]interface{}{
+			"lod0": float64(s.LOD0),
+			"lod1": float64(s.LOD1),
+		}
+		if grad := s.Paint.Gradient; grad != nil {
+			v, ok := gradient(grad, &pal)
+			if !ok {
+				// A gradient with a singular transform is not drawn.
+				continue
+			}
+			o["gradient"] = v
+		} else {
+			o["fill"] = cssColor(s.Paint.Color.Resolve(&pal, nil))
+		}
+		o["path"] = path2D.New(pathData(s.Path))
+		shapes = append(shapes, o)
+	}
+
+	vb := &g.Metadata.ViewBox
+	return map[string]interface{}{
+		"viewBox": []interface{}{
+			float64(vb.Min[0]), float64(vb.Min[1]), float64(vb.Max[0]), float64(vb.Max[1]),
+		},
+		"shapes": shapes,
+	}, nil
+}
+
+func renderToImageData(args []js.Value) (interface{}, error) {
+	src, err := source(arg(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	size := arg(args, 1)
+	if size.Type() != js.TypeNumber {
+		return nil, errInvalidSize
+	}
+	opts := arg(args, 2)
+	overrides, err := paletteOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	ro := &render.Options{Palette: overrides}
+	if opts.Type() == js.TypeObject {
+		ro.LinearInterpolation = opts.Get("linear").Truthy()
+	}
+	m, err := render.Image(src, size.Int(), ro)
+	if err != nil {
+		return nil, err
+	}
+
+	// ImageData is not alpha-premultiplied.
+	pix := m.Pix
+	for i := 0; i+4 <= len(pix); i += 4 {
+		if a := uint32(pix[i+3]); (a != 0x00) && (a != 0xff) {
+			pix[i+0] = uint8((uint32(pix[i+0])*0xff + a/2) / a)
+			pix[i+1] = uint8((uint32(pix[i+1])*0xff + a/2) / a)
+			pix[i+2] = uint8((uint32(pix[i+2])*0xff + a/2) / a)
+		}
+	}
+	data := js.Global().Get("Uint8ClampedArray").New(len(pix))
+	js.CopyBytesToJS(data, pix)
+	w, h := m.Bounds().Dx(), m.Bounds().Dy()
+	return js.Global().Get("ImageData").New(data, w, h), nil
+}
+
+// arg returns args[i], or undefined if there are too few args.
+func arg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// source copies the IconVG graphic held by a Uint8Array or ArrayBuffer.
+func source(v js.Value) ([]byte, error) {
+	if v.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		v = js.Global().Get("Uint8Array").New(v)
+	} else if !v.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errInvalidSource
+	}
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+	return b, nil
+}
+
+// paletteOption returns the palette overrides held by an options object.
+func paletteOption(opts js.Value) (map[uint8]color.RGBA, error) {
+	if opts.Type() != js.TypeObject {
+		return nil, nil
+	}
+	p := opts.Get("palette")
+	if p.IsUndefined() || p.IsNull() {
+		return nil, nil
+	} else if (p.Type() != js.TypeObject) || (p.Length() > len(lowlevel.Palette{})) {
+		return nil, errInvalidPalette
+	}
+	m := map[uint8]color.RGBA{}
+	for i, n := 0, p.Length(); i < n; i++ {
+		e := p.Index(i)
+		if e.IsUndefined() || e.IsNull() {
+			continue
+		} else if e.Type() != js.TypeString {
+			return nil, errInvalidPalette
+		}
+		c, err := lowlevel.ParseColor(e.String())
+		if err != nil {
+			return nil, err
+		}
+		m[uint8(i)], _ = c.RGBA()
+	}
+	return m, nil
+}
+
+// gradient returns the JavaScript description of g, and whether its transform
+// is invertible.
+func gradient(g *ivg.Gradient, pal *lowlevel.Palette) (interface{}, bool) {
+	inv, ok := lowlevel.InvertGradientTransform(g.Transform, g.Shape == ivg.GradientShapeLinear)
+	if !ok {
+		return nil, false
+	}
+	shape := "linear"
+	if g.Shape == ivg.GradientShapeRadial {
+		shape = "radial"
+	}
+	spread := "none"
+	switch g.Spread {
+	case ivg.GradientSpreadPad:
+		spread = "pad"
+	case ivg.GradientSpreadReflect:
+		spread = "reflect"
+	case ivg.GradientSpreadRepeat:
+		spread = "repeat"
+	}
+	stops := make([]interface{}, len(g.Stops))
+	for i, s := range g.Stops {
+		stops[i] = map[string]interface{}{
+			"offset": float64(s.Offset),
+			"color":  cssColor(s.Color.Resolve(pal, nil)),
+		}
+	}
+	return map[string]interface{}{
+		"shape":  shape,
+		"spread": spread,
+		// The canvas transform method takes the matrix in column order.
+		"matrix": []interface{}{inv[0], inv[3], inv[1], inv[4], inv[2], inv[5]},
+		"stops":  stops,
+	}, true
+}
+
+// pathData returns p as SVG path data, which the Path2D constructor accepts.
+func pathData(p ivg.Path) string {
+	sb := &strings.Builder{}
+	for i, seg := range p {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		switch seg := seg.(type) {
+		case ivg.MoveTo:
+			sb.WriteString("M" + points(seg.To))
+		case ivg.LineTo:
+			sb.WriteString("L" + points(seg.To))
+		case ivg.QuadTo:
+			sb.WriteString("Q" + points(seg.Ctrl, seg.To))
+		case ivg.CubeTo:
+			sb.WriteString("C" + points(seg.Ctrl0, seg.Ctrl1, seg.To))
+		case ivg.ArcTo:
+			largeArc, sweep := "0", "0"
+			if seg.LargeArc {
+				largeArc = "1"
+			}
+			if seg.Sweep {
+				sweep = "1"
+			}
+			sb.WriteString("A" + ftoa(seg.Radii[0]) + " " + ftoa(seg.Radii[1]) + " " +
+				ftoa(seg.XAxisRotation*360) + " " + largeArc + " " + sweep + " " + points(seg.To))
+		case ivg.ClosePath:
+			sb.WriteString("Z")
+		}
+	}
+	return sb.String()
+}
+
+// cssColor returns an alpha-premultiplied color as a CSS hex color.
+func cssColor(c color.RGBA) string {
+	n := color.NRGBA{}
+	if c.A == 0xff {
+		n = color.NRGBA{c.R, c.G, c.B, c.A}
+	} else if (c.A != 0x00) && (c.R <= c.A) && (c.G <= c.A) && (c.B <= c.A) {
+		a := uint32(c.A)
+		n = color.NRGBA{
+			R: uint8((uint32(c.R)*0xff + a/2) / a),
+			G: uint8((uint32(c.G)*0xff + a/2) / a),
+			B: uint8((uint32(c.B)*0xff + a/2) / a),
+			A: c.A,
+		}
+	}
+	if n.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+func points(ps ...f32.Vec2) string {
+	s := ""
+	for i, p := range ps {
+		if i > 0 {
+			s += " "
+		}
+		s += ftoa(p[0]) + " " + ftoa(p[1])
+	}
+	return s
+}
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The IconVG Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js && wasm
+
+package wasm_test
+
+import (
+	"os"
+	"syscall/js"
+	"testing"
+
+	"github.com/google/iconvg/src/go/wasm"
+)
+
+func init() {
+	// Node.js, unlike browsers, has neither Path2D nor ImageData. These
+	// stand-ins record their constructor arguments.
+	js.Global().Set("Path2D", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return map[string]interface{}{"d": args[0]}
+	}))
+	js.Global().Set("ImageData", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return map[string]interface{}{"data": args[0], "width": args[1], "height": args[2]}
+	}))
+	wasm.Register()
+}
+
+func readTestData(t *testing.T, filename string) js.Value {
+	t.Helper()
+	src, err := os.ReadFile("../../../test/data/" + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := js.Global().Get("Uint8Array").New(len(src))
+	js.CopyBytesToJS(v, src)
+	return v
+}
+
+func isError(v js.Value) bool {
+	return v.InstanceOf(js.Global().Get("Error"))
+}
+
+func TestDecodeToCanvasPath2D(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	testCases := []struct {
+		desc     string
+		opts     interface{}
+		wantFill string
+	}{
+		{"no options", js.Undefined(), "#000000"},
+		{"empty options", map[string]interface{}{}, "#000000"},
+		{"palette", map[string]interface{}{"palette": []interface{}{"red"}}, "#ff0000"},
+		{"null palette entry", map[string]interface{}{"palette": []interface{}{nil}}, "#000000"},
+	}
+	for _, tc := range testCases {
+		v := js.Global().Call("decodeToCanvasPath2D", src, tc.opts)
+		if isError(v) {
+			t.Errorf("%s: %s", tc.desc, v.Get("message").String())
+			continue
+		}
+		if got, want := v.Get("viewBox").Length(), 4; got != want {
+			t.Errorf("%s: viewBox length: got %d, want %d", tc.desc, got, want)
+		}
+		shapes := v.Get("shapes")
+		if got, want := shapes.Length(), 1; got != want {
+			t.Errorf("%s: shapes: got %d, want %d", tc.desc, got, want)
+			continue
+		}
+		s := shapes.Index(0)
+		if got := s.Get("fill").String(); got != tc.wantFill {
+			t.Errorf("%s: fill: got %q, want %q", tc.desc, got, tc.wantFill)
+		}
+		if d := s.Get("path").Get("d").String(); (len(d) == 0) || (d[0] != 'M') {
+			t.Errorf("%s: path: got %q, want SVG path data", tc.desc, d)
+		}
+	}
+}
+
+func TestDecodeToCanvasPath2DGradient(t *testing.T) {
+	v := js.Global().Call("decodeToCanvasPath2D", readTestData(t, "gradient.ivg"))
+	if isError(v) {
+		t.Fatal(v.Get("message").String())
+	}
+	shapes := v.Get("shapes")
+	if shapes.Length() == 0 {
+		t.Fatal("got no shapes")
+	}
+	for i := 0; i < shapes.Length(); i++ {
+		g := shapes.Index(i).Get("gradient")
+		if g.IsUndefined() {
+			t.Errorf("shape %d: got no gradient", i)
+			continue
+		}
+		if shape := g.Get("shape").String(); (shape != "linear") && (shape != "radial") {
+			t.Errorf("shape %d: shape: got %q", i, shape)
+		}
+		if got, want := g.Get("matrix").Length(), 6; got != want {
+			t.Errorf("shape %d: matrix length: got %d, want %d", i, got, want)
+		}
+		if g.Get("stops").Length() < 2 {
+			t.Errorf("shape %d: stops: got %d, want at least 2", i, g.Get("stops").Length())
+		}
+	}
+}
+
+func TestRenderToImageData(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	// At size 48, (24, 6) is inside of action-info's circle.
+	const i = 4 * (6*48 + 24)
+	testCases := []struct {
+		desc string
+		opts interface{}
+		want [4]int
+	}{
+		{"no options", js.Undefined(), [4]int{0x00, 0x00, 0x00, 0xff}},
+		{"palette", map[string]interface{}{"palette": []interface{}{"#0000ff"}}, [4]int{0x00, 0x00, 0xff, 0xff}},
+		{"translucent palette", map[string]interface{}{"palette": []interface{}{"rgba(255, 0, 0, 0.5)"}},
+			[4]int{0xff, 0x00, 0x00, 0x80}},
+	}
+	for _, tc := range testCases {
+		v := js.Global().Call("renderToImageData", src, 48, tc.opts)
+		if isError(v) {
+			t.Errorf("%s: %s", tc.desc, v.Get("message").String())
+			continue
+		}
+		if w, h := v.Get("width").Int(), v.Get("height").Int(); (w != 48) || (h != 48) {
+			t.Errorf("%s: size: got %d×%d, want 48×48", tc.desc, w, h)
+		}
+		data := v.Get("data")
+		if got, want := data.Length(), 4*48*48; got != want {
+			t.Errorf("%s: data length: got %d, want %d", tc.desc, got, want)
+			continue
+		}
+		got := [4]int{data.Index(i).Int(), data.Index(i + 1).Int(), data.Index(i + 2).Int(), data.Index(i + 3).Int()}
+		if got != tc.want {
+			t.Errorf("%s: pixel: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	src := readTestData(t, "action-info.lores.ivg")
+	testCases := []struct {
+		desc string
+		fn   string
+		args []interface{}
+	}{
+		{"no source", "decodeToCanvasPath2D", nil},
+		{"string source", "decodeToCanvasPath2D", []interface{}{"not IconVG"}},
+		{"invalid graphic", "decodeToCanvasPath2D", []interface{}{js.Global().Get("Uint8Array").New(3)}},
+		{"numeric palette", "decodeToCanvasPath2D", []interface{}{src, map[string]interface{}{"palette": 7}}},
+		{"invalid palette color", "decodeToCanvasPath2D",
+			[]interface{}{src, map[string]interface{}{"palette": []interface{}{"notacolor"}}}},
+		{"no size", "renderToImageData", []interface{}{src}},
+		{"string size", "renderToImageData", []interface{}{src, "48"}},
+		{"zero size", "renderToImageData", []interface{}{src, 0}},
+	}
+	for _, tc := range testCases {
+		if v := js.Global().Call(tc.fn, tc.args...); !isError(v) {
+			t.Errorf("%s: got %v, want an Error", tc.desc, v)
+		}
+	}
+}